@@ -0,0 +1,68 @@
+"""Thin Python wrapper around the Go backtester's JSON contract.
+
+    from backtester import run
+    res = run({"portfolio": [{"Name": "A", "BuyingPower": 10000,
+                              "StartDate": "2020-01-01", "EndDate": "2023-01-01",
+                              "Tickers": ["AAPL"], "Strategy": "buyAndHold"}]})
+    res.metrics      # one row per portfolio
+    res.equity       # date-indexed equity curves, one column per portfolio
+    res.trades       # every trade, tagged with its portfolio
+
+The config dict uses the same keys as config.toml. The binary is run from
+its src/ directory so it finds ../stock_data.db.
+"""
+
+import json
+import os
+import subprocess
+from dataclasses import dataclass
+
+import pandas as pd
+
+SCHEMA_VERSION = 1
+SRC_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)), "..", "src")
+
+
+@dataclass
+class Results:
+    raw: dict
+    metrics: pd.DataFrame
+    equity: pd.DataFrame
+    trades: pd.DataFrame
+
+
+def run(config, binary=None, src_dir=SRC_DIR):
+    """Run a backtest for config (a dict) and return Results."""
+    cmd = [binary] if binary else ["go", "run", "./cmd/backtester"]
+    out = subprocess.run(
+        cmd + ["-json", "-config", "-"],
+        input=json.dumps(config),
+        capture_output=True,
+        text=True,
+        cwd=src_dir,
+        check=True,
+    )
+    return load(json.loads(out.stdout))
+
+
+def load(doc):
+    """Convert a parsed results document into DataFrames."""
+    if doc.get("schema_version") != SCHEMA_VERSION:
+        raise ValueError(f"unsupported schema_version {doc.get('schema_version')}")
+    results = doc["results"]
+    metrics = pd.DataFrame(
+        [{"portfolio": r["portfolio"], "strategy": r["strategy"],
+          "effective_start": r.get("effective_start", ""),
+          "effective_end": r.get("effective_end", ""), **r["metrics"]}
+         for r in results]
+    ).set_index("portfolio") if results else pd.DataFrame()
+    equity = pd.DataFrame(
+        {r["portfolio"]: pd.Series(r["equity_curve"], index=pd.to_datetime(r["dates"]))
+         for r in results}
+    )
+    trades = pd.DataFrame(
+        [{"portfolio": r["portfolio"], **t} for r in results for t in r["trades"]],
+        columns=["portfolio", "date", "ticker", "side", "amount", "price"],
+    )
+    trades["date"] = pd.to_datetime(trades["date"])
+    return Results(doc, metrics, equity, trades)
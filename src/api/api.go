@@ -0,0 +1,501 @@
+// Package api serves a JSON HTTP API for driving the backtester from
+// other tools: a client posts a config, the run waits its turn in a
+// queue, and the client polls or streams its status, metrics and trades.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"my-backtester/src/backtest"
+	"my-backtester/src/logging"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// QueueLimit is how many runs may wait for the worker before POST /runs
+// is refused, and Retain how many finished runs are kept for GET.
+var (
+	QueueLimit = 64
+	Retain     = 256
+)
+
+// MaxConfigBytes bounds a posted config.
+const MaxConfigBytes = 1 << 20
+
+// StreamInterval is how often a streamed run's progress is sent while
+// nothing else about it changes.
+var StreamInterval = time.Second
+
+var logger = logging.For("api")
+
+// Status is where a run is in its life.
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Done      Status = "done"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+)
+
+func (s Status) finished() bool { return s == Done || s == Failed || s == Cancelled }
+
+// RunJSON is a run's status as GET /runs/{id} returns it. Progress is
+// set while the run is running, Results once it has finished.
+type RunJSON struct {
+	ID         string                     `json:"id"`
+	Status     Status                     `json:"status"`
+	Error      string                     `json:"error,omitempty"`
+	Portfolios []string                   `json:"portfolios"`
+	Submitted  time.Time                  `json:"submitted"`
+	Started    *time.Time                 `json:"started,omitempty"`
+	Finished   *time.Time                 `json:"finished,omitempty"`
+	Progress   *backtest.ProgressSnapshot `json:"progress,omitempty"`
+	Results    []ResultJSON               `json:"results,omitempty"`
+}
+
+// ResultJSON is one portfolio's metrics in a RunJSON.
+type ResultJSON struct {
+	Portfolio string               `json:"portfolio"`
+	Strategy  string               `json:"strategy"`
+	Metrics   backtest.MetricsJSON `json:"metrics"`
+}
+
+// TradesJSON is one portfolio's trades, as GET /runs/{id}/trades
+// returns them.
+type TradesJSON struct {
+	Portfolio string               `json:"portfolio"`
+	Trades    []backtest.TradeJSON `json:"trades"`
+}
+
+// run is one submitted config. Everything below cancel is guarded by
+// Server.mu.
+type run struct {
+	id         string
+	cfg        *backtest.Config
+	portfolios []*backtest.Portfolio
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	status    Status
+	err       error
+	submitted time.Time
+	started   time.Time
+	finished  time.Time
+	results   []backtest.Result
+	// changed is closed, and replaced, whenever status changes, to wake
+	// the streams following the run.
+	changed chan struct{}
+}
+
+// Server queues posted runs and executes them one at a time against its
+// store, each with Run's pool of workers to itself.
+type Server struct {
+	store backtest.Store
+	ctx   context.Context
+	queue chan *run
+	mux   *http.ServeMux
+
+	mu    sync.Mutex
+	runs  map[string]*run
+	order []string // ids, oldest first
+	next  int
+}
+
+// New returns a Server running configs against store until ctx is done,
+// which also cancels the run in flight.
+func New(ctx context.Context, store backtest.Store) *Server {
+	s := &Server{
+		store: store,
+		ctx:   ctx,
+		queue: make(chan *run, QueueLimit),
+		mux:   http.NewServeMux(),
+		runs:  make(map[string]*run),
+	}
+	s.mux.HandleFunc("POST /runs", s.submit)
+	s.mux.HandleFunc("GET /runs", s.list)
+	s.mux.HandleFunc("GET /runs/{id}", s.get)
+	s.mux.HandleFunc("DELETE /runs/{id}", s.delete)
+	s.mux.HandleFunc("GET /runs/{id}/trades", s.trades)
+	go s.work()
+	return s
+}
+
+// ServeHTTP serves the API:
+//
+//	POST   /runs              queue the config in the body; 202 with its RunJSON
+//	GET    /runs              every run, oldest first
+//	GET    /runs/{id}         a run's RunJSON, streamed as server-sent
+//	                          events for Accept: text/event-stream
+//	DELETE /runs/{id}         cancel a queued or running run
+//	GET    /runs/{id}/trades  a finished run's trades
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Errors from Server's methods other than a config's own.
+var (
+	ErrQueueFull   = errors.New("too many queued runs")
+	ErrNoRun       = errors.New("no such run")
+	ErrNotFinished = errors.New("run not finished")
+)
+
+// Submit parses cfgText, a config in format ("toml", "json", "yaml" or
+// "" to guess, as for backtest.ParseConfig), and queues it, returning
+// the new run's status. The server's store is used whatever [Database]
+// says, and results stay in memory rather than going where [Output]
+// says. Errors other than ErrQueueFull are the config's.
+func (s *Server) Submit(cfgText, format string) (RunJSON, error) {
+	cfg, err := backtest.ParseConfig(cfgText, format)
+	if err != nil {
+		return RunJSON{}, err
+	}
+	cfg.ApplySeed()
+	cfg.Output, cfg.Database = nil, nil
+	if len(cfg.Portfolios) == 0 {
+		return RunJSON{}, errors.New("config defines no portfolios")
+	}
+	portfolios := make([]*backtest.Portfolio, 0, len(cfg.Portfolios))
+	for _, pc := range cfg.Portfolios {
+		p, err := pc.ToPortfolio()
+		if err != nil {
+			return RunJSON{}, fmt.Errorf("portfolio %q: %w", pc.Name, err)
+		}
+		portfolios = append(portfolios, p)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ru := &run{
+		id:         strconv.Itoa(s.next + 1),
+		cfg:        cfg,
+		portfolios: portfolios,
+		ctx:        ctx,
+		cancel:     cancel,
+		status:     Queued,
+		changed:    make(chan struct{}),
+		submitted:  time.Now().UTC(),
+	}
+	select {
+	case s.queue <- ru:
+	default:
+		cancel()
+		return RunJSON{}, ErrQueueFull
+	}
+	s.next++
+	s.runs[ru.id] = ru
+	s.order = append(s.order, ru.id)
+	s.prune()
+	return s.runJSON(ru), nil
+}
+
+// Run reports run id's status.
+func (s *Server) Run(id string) (RunJSON, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ru, ok := s.runs[id]
+	if !ok {
+		return RunJSON{}, fmt.Errorf("%w %q", ErrNoRun, id)
+	}
+	return s.runJSON(ru), nil
+}
+
+// Runs reports every run kept, oldest first.
+func (s *Server) Runs() []RunJSON {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := make([]RunJSON, 0, len(s.order))
+	for _, id := range s.order {
+		docs = append(docs, s.runJSON(s.runs[id]))
+	}
+	return docs
+}
+
+// Watch calls fn with run id's status now, whenever it changes and
+// every StreamInterval while the run runs, until the run finishes, ctx
+// is done or fn returns an error, which Watch returns.
+func (s *Server) Watch(ctx context.Context, id string, fn func(RunJSON) error) error {
+	ticker := time.NewTicker(StreamInterval)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		ru, ok := s.runs[id]
+		if !ok {
+			s.mu.Unlock()
+			return fmt.Errorf("%w %q", ErrNoRun, id)
+		}
+		doc, changed := s.runJSON(ru), ru.changed
+		s.mu.Unlock()
+		if err := fn(doc); err != nil || doc.Status.finished() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cancel cancels run id: at once if it is queued, or at its next bar if
+// it is running. Finished runs are left as they are.
+func (s *Server) Cancel(id string) (RunJSON, error) {
+	s.mu.Lock()
+	ru, ok := s.runs[id]
+	s.mu.Unlock()
+	if !ok {
+		return RunJSON{}, fmt.Errorf("%w %q", ErrNoRun, id)
+	}
+	// A queued run is finished here; a running one when Run returns.
+	s.transition(ru, Queued, Cancelled, nil, context.Canceled)
+	ru.cancel()
+	return s.Run(id)
+}
+
+// Results returns the results of run id once it has finished: every
+// portfolio's for a done run, those that finished for a cancelled one.
+func (s *Server) Results(id string) ([]backtest.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ru, ok := s.runs[id]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w %q", ErrNoRun, id)
+	case !ru.status.finished():
+		return nil, fmt.Errorf("%w: run %s is %s", ErrNotFinished, id, ru.status)
+	}
+	return ru.results, nil
+}
+
+// submit queues the posted config, TOML, JSON or YAML as its
+// Content-Type says or ParseConfig guesses.
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxConfigBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	doc, err := s.Submit(string(body), configFormat(r.Header.Get("Content-Type")))
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Location", "/runs/"+doc.ID)
+	writeJSON(w, http.StatusAccepted, doc)
+}
+
+func configFormat(contentType string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch mt {
+	case "application/json":
+		return "json"
+	case "application/toml":
+		return "toml"
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return "yaml"
+	}
+	return ""
+}
+
+// prune forgets the oldest finished runs beyond Retain. s.mu is held.
+func (s *Server) prune() {
+	excess := len(s.order) - Retain
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if excess > 0 && s.runs[id].status.finished() {
+			delete(s.runs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+// work executes queued runs in turn until s.ctx is done.
+func (s *Server) work() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case ru := <-s.queue:
+			s.execute(ru)
+		}
+	}
+}
+
+func (s *Server) execute(ru *run) {
+	defer ru.cancel()
+	if !s.transition(ru, Queued, Running, nil, nil) {
+		return // cancelled while queued
+	}
+	results, err := backtest.Run(ru.ctx, s.store, ru.portfolios, nil)
+	status := Done
+	switch {
+	case ru.ctx.Err() != nil:
+		status = Cancelled
+	case err != nil:
+		status = Failed
+	}
+	s.transition(ru, Running, status, results, err)
+	if status == Done {
+		if err := backtest.PostRunSignals(s.ctx, ru.cfg.Webhook, results); err != nil {
+			logger.Warn("webhook", "run", ru.id, "err", err)
+		}
+	}
+}
+
+// transition moves ru from status from to status to, recording results
+// and err, and wakes its streams. It reports false, changing nothing, if
+// ru wasn't in status from.
+func (s *Server) transition(ru *run, from, to Status, results []backtest.Result, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ru.status != from {
+		return false
+	}
+	now := time.Now().UTC()
+	if to == Running {
+		ru.started = now
+	} else {
+		ru.finished = now
+	}
+	ru.status, ru.results, ru.err = to, results, err
+	close(ru.changed)
+	ru.changed = make(chan struct{})
+	return true
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Runs())
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Accept")); mt == "text/event-stream" {
+		s.stream(w, r, id)
+		return
+	}
+	doc, err := s.Run(id)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// stream sends run id's RunJSON as a server-sent event as Watch calls
+// with it.
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusNotAcceptable)
+		return
+	}
+	if _, err := s.Run(id); err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	err := s.Watch(r.Context(), id, func(doc RunJSON) error {
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", doc.Status, b); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		logger.Warn("stream run", "err", err)
+	}
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.Cancel(r.PathValue("id"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, doc)
+}
+
+func (s *Server) trades(w http.ResponseWriter, r *http.Request) {
+	results, err := s.Results(r.PathValue("id"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	docs := make([]TradesJSON, 0, len(results))
+	for _, res := range results {
+		docs = append(docs, TradesJSON{Portfolio: res.PortfolioName, Trades: backtest.NewResultJSON(res).Trades})
+	}
+	writeJSON(w, http.StatusOK, docs)
+}
+
+// httpError answers with err and the status its kind calls for.
+func httpError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNoRun):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrNotFinished):
+		code = http.StatusConflict
+	}
+	http.Error(w, err.Error(), code)
+}
+
+// runJSON reports ru. s.mu is held.
+func (s *Server) runJSON(ru *run) RunJSON {
+	doc := RunJSON{ID: ru.id, Status: ru.status, Submitted: ru.submitted}
+	for _, p := range ru.portfolios {
+		doc.Portfolios = append(doc.Portfolios, p.Pname)
+	}
+	if ru.err != nil && !errors.Is(ru.err, context.Canceled) {
+		doc.Error = ru.err.Error()
+	}
+	if t := ru.started; !t.IsZero() {
+		doc.Started = &t
+	}
+	if t := ru.finished; !t.IsZero() {
+		doc.Finished = &t
+	}
+	if ru.status == Running {
+		// Runs execute one at a time, so the run in flight is ru.
+		if p, ok := backtest.CurrentProgress(); ok {
+			doc.Progress = &p
+		}
+	}
+	for _, res := range ru.results {
+		doc.Results = append(doc.Results, ResultJSON{
+			Portfolio: res.PortfolioName,
+			Strategy:  res.Strategy,
+			Metrics:   backtest.NewResultJSON(res).Metrics,
+		})
+	}
+	return doc
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("write response", "err", err)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"my-backtester/src/data"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStore struct{ bars map[string][]data.AssetData }
+
+func (f fakeStore) QueryAssetsForTickers(_ context.Context, tickers []string, start, end time.Time) map[string][]data.AssetData {
+	out := make(map[string][]data.AssetData)
+	for _, t := range tickers {
+		for _, b := range f.bars[t] {
+			if !b.Date.Before(start) && !b.Date.After(end) {
+				out[t] = append(out[t], b)
+			}
+		}
+		data.FillReturns(out[t])
+	}
+	return out
+}
+
+func (fakeStore) GetRiskFreeRates(context.Context, time.Time, time.Time) map[int64]float64 {
+	return nil
+}
+
+func (fakeStore) QueryMacro(context.Context, string, time.Time) []data.MacroPoint { return nil }
+
+const config = `
+[[portfolio]]
+Name = "hold"
+Tickers = ["A"]
+Strategy = "buyAndHold:greedy"
+BuyingPower = 10000
+StartDate = "2024-01-01"
+EndDate = "2024-12-31"
+`
+
+func TestServer(t *testing.T) {
+	store := fakeStore{bars: map[string][]data.AssetData{}}
+	for i := range 40 {
+		px := 100 + float64(i)
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC),
+			Open: px, High: px, Low: px, Close: px, Volume: 1000,
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(New(ctx, store))
+	defer srv.Close()
+	do := func(method, path, accept, body string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	code, body := do("POST", "/runs", "", config)
+	var posted RunJSON
+	if err := json.Unmarshal([]byte(body), &posted); code != http.StatusAccepted || err != nil || posted.ID != "1" {
+		t.Fatalf("POST /runs (%d): %s", code, body)
+	}
+	if code, _ := do("POST", "/runs", "", "[[portfolio]]\nName = \"x\"\nStrategy = \"nope\"\n"); code != http.StatusBadRequest {
+		t.Errorf("bad config: status %d", code)
+	}
+
+	// The stream ends with the finished run.
+	code, body = do("GET", "/runs/1", "text/event-stream", "")
+	var last RunJSON
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		if d, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			if err := json.Unmarshal([]byte(d), &last); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if code != 200 || last.Status != Done || !strings.HasSuffix(strings.TrimSpace(body), "}") {
+		t.Fatalf("stream (%d):\n%s", code, body)
+	}
+
+	code, body = do("GET", "/runs/1", "", "")
+	var got RunJSON
+	if err := json.Unmarshal([]byte(body), &got); code != 200 || err != nil || got.Status != Done ||
+		len(got.Results) != 1 || got.Results[0].Portfolio != "hold" || got.Results[0].Metrics.AnnualReturn <= 0 {
+		t.Errorf("GET /runs/1 (%d): %s", code, body)
+	}
+
+	code, body = do("GET", "/runs/1/trades", "", "")
+	var trades []TradesJSON
+	if err := json.Unmarshal([]byte(body), &trades); code != 200 || err != nil || len(trades) != 1 ||
+		len(trades[0].Trades) == 0 || trades[0].Trades[0].Side != "BUY" {
+		t.Errorf("trades (%d): %s", code, body)
+	}
+
+	if code, _ := do("GET", "/runs/9", "", ""); code != http.StatusNotFound {
+		t.Errorf("unknown run: status %d", code)
+	}
+	code, body = do("GET", "/runs", "", "")
+	var all []RunJSON
+	if err := json.Unmarshal([]byte(body), &all); code != 200 || err != nil || len(all) != 1 {
+		t.Errorf("GET /runs (%d): %s", code, body)
+	}
+}
+
+func TestServer_CancelQueued(t *testing.T) {
+	// Left out of the queue, so the worker never picks the run up.
+	s := New(context.Background(), nil)
+	ru := &run{id: "1", status: Queued, changed: make(chan struct{}), cancel: func() {}}
+	s.runs["1"], s.order = ru, []string{"1"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	req, _ := http.NewRequest("DELETE", srv.URL+"/runs/1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || ru.status != Cancelled {
+		t.Errorf("status %d, run %s", resp.StatusCode, ru.status)
+	}
+	// The worker then skips it.
+	s.execute(ru)
+	if ru.status != Cancelled || ru.results != nil {
+		t.Errorf("cancelled run executed: %s", ru.status)
+	}
+}
@@ -0,0 +1,295 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"sort"
+	"time"
+)
+
+// PriceMode chooses how dividends and splits (see data.CorporateAction)
+// reach a backtest.
+type PriceMode string
+
+const (
+	// PricesRaw trades the bars as stored: the portfolio is credited
+	// each dividend in cash on its ex-date (a short pays it) and its
+	// positions and open orders are rescaled by each split.
+	PricesRaw PriceMode = "raw"
+	// PricesAdjusted trades bars back-adjusted for splits and dividends
+	// (see data.AdjustBars), so dividends are reinvested in the price and
+	// no cash changes hands.
+	PricesAdjusted PriceMode = "adjusted"
+)
+
+// ParsePriceMode maps a config value to a PriceMode; "" is PricesRaw.
+func ParsePriceMode(s string) (PriceMode, error) {
+	switch PriceMode(s) {
+	case "", PricesRaw:
+		return PricesRaw, nil
+	case PricesAdjusted:
+		return PricesAdjusted, nil
+	}
+	return "", fmt.Errorf("price mode %q: must be raw or adjusted", s)
+}
+
+// WithPrices sets how corporate actions are applied (see PriceMode).
+func WithPrices(m PriceMode) Option {
+	return func(p *Portfolio) error {
+		if _, err := ParsePriceMode(string(m)); err != nil {
+			return err
+		}
+		p.Prices = m
+		return nil
+	}
+}
+
+// ActionStore is a Store that also has dividends and splits. Runs
+// against one apply them as the portfolio's Prices say; data.Store is
+// one.
+type ActionStore interface {
+	QueryActions(ctx context.Context, tickers []string, start, end time.Time) map[string][]data.CorporateAction
+}
+
+// SymbolStore is a Store that also has symbol changes; data.Store is
+// one. Runs against one simulate a renamed ticker, under either symbol,
+// on the bars and actions of every symbol it went by (see
+// data.SymbolHistory).
+type SymbolStore interface {
+	SymbolChanges(ctx context.Context) ([]data.SymbolChange, error)
+}
+
+// loadSymbols returns the symbol history of each of tickers that was
+// renamed, from store if it is a SymbolStore.
+func loadSymbols(ctx context.Context, store Store, tickers []string) map[string][]data.SymbolSpan {
+	ss, ok := store.(SymbolStore)
+	if !ok {
+		return nil
+	}
+	changes, err := ss.SymbolChanges(ctx)
+	if err != nil {
+		runLogger.Error("load symbol changes", "err", err)
+		return nil
+	}
+	var out map[string][]data.SymbolSpan
+	for _, t := range tickers {
+		if spans := data.SymbolHistory(changes, t); len(spans) > 1 {
+			if out == nil {
+				out = make(map[string][]data.SymbolSpan)
+			}
+			out[t] = spans
+		}
+	}
+	return out
+}
+
+// withAliases adds the other symbols in symbols to tickers.
+func withAliases(tickers []string, symbols map[string][]data.SymbolSpan) []string {
+	seen := make(map[string]bool, len(tickers))
+	for _, t := range tickers {
+		seen[t] = true
+	}
+	for _, t := range tickers {
+		for _, s := range symbols[t] {
+			if !seen[s.Ticker] {
+				seen[s.Ticker] = true
+				tickers = append(tickers, s.Ticker)
+			}
+		}
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+// withSpinOffs adds the tickers spun off in actions to tickers, so their
+// shares can be valued.
+func withSpinOffs(tickers []string, actions map[string][]data.CorporateAction) []string {
+	seen := make(map[string]bool, len(tickers))
+	for _, t := range tickers {
+		seen[t] = true
+	}
+	for _, as := range actions {
+		for _, a := range as {
+			if a.SpinOff != "" && !seen[a.SpinOff] {
+				seen[a.SpinOff] = true
+				tickers = append(tickers, a.SpinOff)
+			}
+		}
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+// stitchSymbols returns hist and actions with each renamed ticker in
+// symbols given the bars and actions of every symbol it went by, each
+// within its span. The maps are copied; the series in them aren't
+// changed.
+func stitchSymbols(
+	hist map[string][]data.AssetData, actions map[string][]data.CorporateAction,
+	symbols map[string][]data.SymbolSpan,
+) (map[string][]data.AssetData, map[string][]data.CorporateAction) {
+	if len(symbols) == 0 {
+		return hist, actions
+	}
+	outHist := make(map[string][]data.AssetData, len(hist))
+	for t, series := range hist {
+		outHist[t] = series
+	}
+	outActions := make(map[string][]data.CorporateAction, len(actions))
+	for t, as := range actions {
+		outActions[t] = as
+	}
+	for t, spans := range symbols {
+		outHist[t] = data.StitchSymbols(hist, spans)
+		var merged []data.CorporateAction
+		for _, s := range spans {
+			for _, a := range actions[s.Ticker] {
+				if (s.From.IsZero() || !a.Date.Before(s.From)) && (s.Until.IsZero() || a.Date.Before(s.Until)) {
+					merged = append(merged, a)
+				}
+			}
+		}
+		outActions[t] = merged
+	}
+	return outHist, outActions
+}
+
+// valueSpinOffs returns actions with each spin-off's shares valued at
+// the spun-off ticker's open in hist on its ex-date, or its first bar
+// after, and added to the action's Dividend: raw prices credit them as
+// cash in lieu of the shares, and adjusted ones take them out of the
+// parent's earlier prices. Only the slices with spin-offs are copied. A
+// spin-off without bars is left unvalued, with a warning.
+func valueSpinOffs(
+	actions map[string][]data.CorporateAction, hist map[string][]data.AssetData,
+) map[string][]data.CorporateAction {
+	var out map[string][]data.CorporateAction
+	for t, as := range actions {
+		for i, a := range as {
+			if a.SpinOff == "" || a.SpinOffRatio <= 0 {
+				continue
+			}
+			child := hist[a.SpinOff]
+			j := sort.Search(len(child), func(k int) bool { return !child[k].Date.Before(a.Date) })
+			if j == len(child) || !(child[j].Open > 0) {
+				runLogger.Warn("spin-off has no price; left unvalued",
+					"ticker", t, "spin_off", a.SpinOff, "date", formatDate(a.Date))
+				continue
+			}
+			if out == nil {
+				out = make(map[string][]data.CorporateAction, len(actions))
+				for t, as := range actions {
+					out[t] = as
+				}
+			}
+			if &out[t][0] == &as[0] {
+				out[t] = append([]data.CorporateAction(nil), as...)
+			}
+			out[t][i].Dividend += a.SpinOffRatio * child[j].Open
+		}
+	}
+	if out == nil {
+		return actions
+	}
+	return out
+}
+
+// loadActions reads the corporate actions of p's tickers over its window
+// from p's store, unless Run already has.
+func (p *Portfolio) loadActions(ctx context.Context) {
+	if p.actions != nil {
+		return
+	}
+	if as, ok := p.store.(ActionStore); ok {
+		p.actions = as.QueryActions(ctx, p.Tickers, p.EffectiveStart, p.EffectiveEnd)
+	}
+}
+
+// adjustHist returns hist with tickers' series back-adjusted for their
+// actions.
+func adjustHist(
+	hist map[string][]data.AssetData, tickers []string,
+	actions map[string][]data.CorporateAction,
+) map[string][]data.AssetData {
+	out := make(map[string][]data.AssetData, len(hist))
+	for t, series := range hist {
+		out[t] = series
+	}
+	for _, t := range tickers {
+		if len(actions[t]) > 0 {
+			out[t] = data.AdjustBars(hist[t], actions[t])
+		}
+	}
+	return out
+}
+
+// applyActions credits the dividends and applies the splits of every
+// action dated after the previous bar up to bar day; actions on or
+// before the first bar are already in its prices.
+func (p *Portfolio) applyActions(hist map[string][]data.AssetData, day int) {
+	if p.Prices == PricesAdjusted || len(p.actions) == 0 {
+		return
+	}
+	if p.nextAction == nil {
+		p.nextAction = make(map[string]int, len(p.Tickers))
+	}
+	for _, t := range p.Tickers {
+		series := hist[t]
+		if day >= len(series) {
+			continue
+		}
+		date := series[day].Date
+		actions := p.actions[t]
+		i := p.nextAction[t]
+		for ; i < len(actions) && !actions[i].Date.After(date); i++ {
+			if day > 0 {
+				p.applyAction(t, actions[i], date)
+			}
+		}
+		p.nextAction[t] = i
+	}
+}
+
+func (p *Portfolio) applyAction(ticker string, a data.CorporateAction, date time.Time) {
+	pos, ok := p.Positions[ticker]
+	if ok && pos.Amount != 0 && a.Dividend > 0 {
+		cash := pos.Amount * a.Dividend
+		p.adjustCash(cash)
+		if p.dividends == nil {
+			p.dividends = make(map[bookKey]float64)
+		}
+		p.dividends[bookKey{ticker, pos.Amount < 0}] += cash
+		msg := "DIVIDEND"
+		if a.SpinOff != "" {
+			msg = "SPIN-OFF"
+		}
+		p.txLog().Debug(msg, "portfolio", p.Pname, "ticker", ticker, "cash", cash, "shares", pos.Amount, "date", formatDate(date))
+	}
+	if a.Split <= 0 || a.Split == 1 {
+		return
+	}
+	if ok && pos.Amount != 0 {
+		pos.Amount *= a.Split
+		pos.AveragePrice /= a.Split
+		pos.CurrentPrice /= a.Split
+		for i := range pos.Lots {
+			pos.Lots[i].Amount *= a.Split
+			pos.Lots[i].Price /= a.Split
+		}
+		p.txLog().Debug("SPLIT", "portfolio", p.Pname, "ticker", ticker, "ratio", a.Split, "shares", pos.Amount, "date", formatDate(date))
+	}
+	for i := range p.pending {
+		if o := &p.pending[i]; o.Ticker == ticker {
+			o.Amount *= a.Split
+		}
+	}
+	for _, r := range p.resting {
+		if r.Ticker == ticker {
+			r.Amount *= a.Split
+			r.Limit /= a.Split
+			r.Stop /= a.Split
+			r.mark /= a.Split
+		}
+	}
+}
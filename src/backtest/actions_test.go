@@ -0,0 +1,102 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// A 2-for-1 split and a $1 dividend leave a holder's wealth unchanged
+// whether they are applied as they happen or are already in the prices.
+func TestCorporateActions(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	bar := func(i int, c float64) data.AssetData {
+		return data.AssetData{Date: day(i), Open: c, High: c, Low: c, Close: c, Volume: 100}
+	}
+	store := &fakeStore{
+		bars:  map[string][]data.AssetData{"A": {bar(0, 100), bar(1, 100), bar(2, 50), bar(3, 49)}},
+		rates: map[int64]float64{},
+		actions: map[string][]data.CorporateAction{"A": {
+			{Date: day(0), Dividend: 5}, // before the run: ignored
+			{Date: day(2), Split: 2},
+			{Date: day(3), Dividend: 1},
+		}},
+	}
+	for _, c := range []struct {
+		mode   PriceMode
+		bought float64
+	}{
+		{PricesRaw, 10},
+		{PricesAdjusted, 20},
+	} {
+		p, err := NewPortfolio("actions", 1000, []string{"A"}, "greedy",
+			WithWindow(day(0), day(3)), WithPrices(c.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := results[0]
+		if len(r.Trades) != 1 || r.Trades[0].Amount != c.bought {
+			t.Errorf("%s: trades = %+v, want one buy of %v", c.mode, r.Trades, c.bought)
+		}
+		for i, ret := range r.Returns {
+			if !closeTo(ret, 0) {
+				t.Errorf("%s: return on %s = %v, want 0", c.mode, r.Dates[i], ret)
+			}
+		}
+		if final := r.EquityCurve[len(r.EquityCurve)-1]; !closeTo(final, 1000) {
+			t.Errorf("%s: final value = %v, want 1000", c.mode, final)
+		}
+	}
+
+	if _, err := ParsePriceMode("total"); err == nil {
+		t.Error("expected error for an unknown price mode")
+	}
+}
+
+// A renamed ticker is simulated on both symbols' bars, and a spin-off is
+// worth its shares' opening price either as cash or in the prices.
+func TestCorporateActions_SymbolChangeAndSpinOff(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	bar := func(i int, c float64) data.AssetData {
+		return data.AssetData{Date: day(i), Open: c, High: c, Low: c, Close: c, Volume: 100}
+	}
+	store := &fakeStore{
+		bars: map[string][]data.AssetData{
+			"OLD": {bar(0, 100), bar(1, 100)},
+			"NEW": {bar(2, 100), bar(3, 90)},
+			"KID": {bar(3, 20)},
+		},
+		rates: map[int64]float64{},
+		actions: map[string][]data.CorporateAction{
+			"OLD": {{Date: day(2), NewTicker: "NEW"}},
+			"NEW": {{Date: day(3), SpinOff: "KID", SpinOffRatio: 0.5}},
+		},
+		symbols: []data.SymbolChange{{Ticker: "OLD", NewTicker: "NEW", Date: day(2)}},
+	}
+	for _, mode := range []PriceMode{PricesRaw, PricesAdjusted} {
+		p, err := NewPortfolio("renamed", 1000, []string{"OLD"}, "greedy",
+			WithWindow(day(0), day(3)), WithPrices(mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := results[0]
+		if r.EffectiveEnd != "2024-01-05" {
+			t.Errorf("%s: run ended %s, want NEW's last bar", mode, r.EffectiveEnd)
+		}
+		if final := r.EquityCurve[len(r.EquityCurve)-1]; !closeTo(final, 1000) {
+			t.Errorf("%s: final value = %v, want 1000", mode, final)
+		}
+	}
+	if store.actions["NEW"][0].Dividend != 0 {
+		t.Error("valuing the spin-off changed the store's actions")
+	}
+}
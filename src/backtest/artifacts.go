@@ -0,0 +1,213 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WriteArtifacts writes every result's equity curve, trade blotter, closed
+// lots and rolling metrics into dir as <portfolio>_equity.csv,
+// _trades.csv, _lots.csv and _rolling.csv, and its sector exposures, if it
+// has any, as _sectors.csv, creating dir if needed. Unlike the [Output]
+// file they are written for every run, whatever its filter.
+func WriteArtifacts(dir string, results []Result) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("artifacts: %w", err)
+	}
+	for _, r := range results {
+		base := filepath.Join(dir, ArtifactName(r.PortfolioName))
+		if err := writeCSVFile(base+"_equity.csv", r, WriteEquityCSV); err != nil {
+			return err
+		}
+		if err := writeCSVFile(base+"_trades.csv", r, WriteTradesCSV); err != nil {
+			return err
+		}
+		if err := writeCSVFile(base+"_lots.csv", r, WriteLotsCSV); err != nil {
+			return err
+		}
+		if err := writeCSVFile(base+"_rolling.csv", r, WriteRollingCSV); err != nil {
+			return err
+		}
+		if len(r.Sectors) > 0 {
+			if err := writeCSVFile(base+"_sectors.csv", r, WriteSectorsCSV); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeCSVFile(path string, r Result, write func(io.Writer, Result) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("artifacts: %w", err)
+	}
+	if err := write(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("artifacts: %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// ArtifactName makes a portfolio name safe to use as a file name, as
+// WriteArtifacts does.
+func ArtifactName(name string) string {
+	if name == "" {
+		return "portfolio"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// WriteEquityCSV writes r's equity curve as date,value,return rows, one
+// per simulated day after the first.
+func WriteEquityCSV(w io.Writer, r Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "value", "return"})
+	for i, date := range r.Dates {
+		ret := ""
+		if i < len(r.Returns) {
+			ret = strconv.FormatFloat(r.Returns[i], 'f', -1, 64)
+		}
+		cw.Write([]string{date, strconv.FormatFloat(r.EquityCurve[i], 'f', 2, 64), ret})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteRollingCSV writes r's rolling metrics with one row per date and
+// sharpe_<w>, volatility_<w> and drawdown_<w> columns per window w,
+// empty until the window has filled.
+func WriteRollingCSV(w io.Writer, r Result) error {
+	cw := csv.NewWriter(w)
+	header := []string{"date"}
+	for _, ro := range r.Rolling {
+		n := strconv.Itoa(ro.Window)
+		header = append(header, "sharpe_"+n, "volatility_"+n, "drawdown_"+n)
+	}
+	cw.Write(header)
+	for i, date := range r.Dates {
+		row := []string{date}
+		for _, ro := range r.Rolling {
+			j := i - ro.Window + 1
+			if j < 0 || j >= len(ro.Sharpe) {
+				row = append(row, "", "", "")
+				continue
+			}
+			row = append(row,
+				strconv.FormatFloat(ro.Sharpe[j], 'f', -1, 64),
+				strconv.FormatFloat(ro.Volatility[j], 'f', -1, 64),
+				strconv.FormatFloat(ro.Drawdown[j], 'f', -1, 64),
+			)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSectorsCSV writes r's sector exposures with one row per date and
+// a column per sector: its net exposure in percent of the portfolio's
+// value.
+func WriteSectorsCSV(w io.Writer, r Result) error {
+	cw := csv.NewWriter(w)
+	header := []string{"date"}
+	for _, s := range r.Sectors {
+		header = append(header, s.Sector)
+	}
+	cw.Write(header)
+	for i, date := range r.Dates {
+		row := []string{date}
+		for _, s := range r.Sectors {
+			v := ""
+			if i < len(s.Exposure) {
+				v = strconv.FormatFloat(s.Exposure[i], 'f', -1, 64)
+			}
+			row = append(row, v)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTradesCSV writes r's trades as a blotter. pnl is each fill's
+// realized profit net of its fee: a sell earns its price less the
+// position's average cost per share, and a buy costs its fee. note marks
+// the trades the run made itself, such as closing a delisted position.
+func WriteTradesCSV(w io.Writer, r Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "ticker", "side", "qty", "price", "fee", "pnl", "note"})
+	type lot struct{ qty, avg float64 }
+	held := make(map[string]lot)
+	for _, t := range r.Trades {
+		l := held[t.Ticker]
+		pnl := -t.Fee
+		if t.Side == "SELL" {
+			pnl += (t.Price - l.avg) * t.Amount
+			l.qty -= t.Amount
+		} else {
+			l.avg = (l.avg*l.qty + t.Price*t.Amount) / (l.qty + t.Amount)
+			l.qty += t.Amount
+		}
+		held[t.Ticker] = l
+		cw.Write([]string{
+			formatDate(t.Date), t.Ticker, t.Side,
+			strconv.FormatFloat(t.Amount, 'f', -1, 64),
+			strconv.FormatFloat(t.Price, 'f', -1, 64),
+			strconv.FormatFloat(t.Fee, 'f', -1, 64),
+			strconv.FormatFloat(pnl, 'f', 2, 64),
+			t.Note,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// LongTermDays is the holding period past which WriteLotsCSV calls a
+// closed lot's gain long-term, as US tax rules do.
+var LongTermDays = 365.0
+
+// WriteLotsCSV writes r's closed trades, one row per closed lot under
+// FIFO or LIFO lot accounting: when it was opened and closed, its cost
+// basis and proceeds, realized pnl net of fees, return, holding period,
+// and whether that period makes the gain short- or long-term.
+func WriteLotsCSV(w io.Writer, r Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"ticker", "side", "opened", "closed", "qty", "entry_price", "exit_price",
+		"cost_basis", "proceeds", "pnl", "return", "holding_days", "term"})
+	for _, c := range r.ClosedTrades {
+		side, basis, proceeds := "long", c.EntryPrice*c.Amount, c.ExitPrice*c.Amount
+		if c.Short {
+			side, basis, proceeds = "short", proceeds, basis
+		}
+		term := "short"
+		if c.HoldingDays > LongTermDays {
+			term = "long"
+		}
+		cw.Write([]string{
+			c.Ticker, side, formatDate(c.Entry), formatDate(c.Exit),
+			strconv.FormatFloat(c.Amount, 'f', -1, 64),
+			strconv.FormatFloat(c.EntryPrice, 'f', -1, 64),
+			strconv.FormatFloat(c.ExitPrice, 'f', -1, 64),
+			strconv.FormatFloat(basis, 'f', 2, 64),
+			strconv.FormatFloat(proceeds, 'f', 2, 64),
+			strconv.FormatFloat(c.PnL, 'f', 2, 64),
+			strconv.FormatFloat(c.Return, 'f', -1, 64),
+			strconv.FormatFloat(c.HoldingDays, 'f', 0, 64),
+			term,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
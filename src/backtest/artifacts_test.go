@@ -0,0 +1,64 @@
+package backtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteArtifacts(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	r := Result{
+		PortfolioName: "a/b c",
+		EquityCurve:   []float64{1000, 1100},
+		Dates:         []string{"2024-01-02", "2024-01-03"},
+		Returns:       []float64{0, 0.1},
+		Trades: []Trade{
+			{Date: day(0), Ticker: "A", Side: "BUY", Amount: 10, Price: 10, Fee: 1},
+			{Date: day(1), Ticker: "A", Side: "BUY", Amount: 10, Price: 20, Fee: 1},
+			{Date: day(2), Ticker: "A", Side: "SELL", Amount: 5, Price: 25, Fee: 1},
+		},
+		ClosedTrades: []ClosedTrade{
+			{Ticker: "A", Entry: day(0), Exit: day(400), Amount: 5, EntryPrice: 10, ExitPrice: 25, PnL: 73.5, HoldingDays: 400, Return: 1.47},
+			{Ticker: "B", Entry: day(0), Exit: day(2), Amount: 2, EntryPrice: 10, ExitPrice: 8, PnL: 4, Short: true, HoldingDays: 2, Return: 0.2},
+		},
+	}
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := WriteArtifacts(dir, []Result{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	equity, err := os.ReadFile(filepath.Join(dir, "a_b_c_equity.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "date,value,return\n2024-01-02,1000.00,0\n2024-01-03,1100.00,0.1\n"; string(equity) != want {
+		t.Errorf("equity =\n%s\nwant\n%s", equity, want)
+	}
+
+	trades, err := os.ReadFile(filepath.Join(dir, "a_b_c_trades.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sell is against an average cost of 15: 5 * 10 - 1.
+	want := "date,ticker,side,qty,price,fee,pnl,note\n" +
+		"2024-01-01,A,BUY,10,10,1,-1.00,\n" +
+		"2024-01-02,A,BUY,10,20,1,-1.00,\n" +
+		"2024-01-03,A,SELL,5,25,1,49.00,\n"
+	if string(trades) != want {
+		t.Errorf("trades =\n%s\nwant\n%s", trades, want)
+	}
+
+	lots, err := os.ReadFile(filepath.Join(dir, "a_b_c_lots.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A short's basis is what covering it cost.
+	want = "ticker,side,opened,closed,qty,entry_price,exit_price,cost_basis,proceeds,pnl,return,holding_days,term\n" +
+		"A,long,2024-01-01,2025-02-04,5,10,25,50.00,125.00,73.50,1.47,400,long\n" +
+		"B,short,2024-01-01,2024-01-03,2,10,8,16.00,20.00,4.00,0.2,2,short\n"
+	if string(lots) != want {
+		t.Errorf("lots =\n%s\nwant\n%s", lots, want)
+	}
+}
@@ -0,0 +1,167 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// Attribution decomposes a portfolio's return over its run three ways:
+// by ticker, by strategy, and by long and short book. Each row's
+// Contribution is in percentage points of the initial capital. The
+// Tickers, like the Books, sum with Other to Total; the Strategies sum to
+// Total on their own.
+type Attribution struct {
+	// Tickers holds each traded ticker's closed round trips, open
+	// position marked at the last close and dividends, net of fees.
+	Tickers []AttributionRow
+	// Strategies holds each sleeve's part (see WithSleeves), or the one
+	// strategy's whole return.
+	Strategies []AttributionRow
+	// Books splits the tickers' rows into "long" and "short".
+	Books []AttributionRow
+	// Other is the return no ticker explains: interest on cash and
+	// margin, borrow fees and taxes.
+	Other AttributionRow
+	Total AttributionRow
+}
+
+// AttributionRow is one part of an Attribution: its PnL in the
+// portfolio's currency and its Contribution in percentage points.
+type AttributionRow struct {
+	Name         string
+	PnL          float64
+	Contribution float64
+}
+
+// bookKey is one ticker's long or short book.
+type bookKey struct {
+	ticker string
+	short  bool
+}
+
+// bookPnL is p's profit and loss by ticker and book: its closed trades,
+// its open positions at their last price and the dividends they were
+// paid, net of fees. A portfolio made of other runs, its sleeves or
+// stitched windows, has theirs added up.
+func (p *Portfolio) bookPnL() map[bookKey]float64 {
+	if p.books != nil {
+		return p.books
+	}
+	out := make(map[bookKey]float64)
+	for _, c := range p.ClosedTrades {
+		out[bookKey{c.Ticker, c.Short}] += c.PnL
+	}
+	for t, pos := range p.Positions {
+		if pos.Amount != 0 {
+			out[bookKey{t, pos.Amount < 0}] += (pos.CurrentPrice-pos.AveragePrice)*pos.Amount - pos.Fees
+		}
+	}
+	for k, v := range p.dividends {
+		out[k] += v
+	}
+	return out
+}
+
+// attribution decomposes p's return once it has run; nil if it has no
+// capital to measure against.
+func (p *Portfolio) attribution() *Attribution {
+	if !(p.InitialBuyingPower > 0) {
+		return nil
+	}
+	points := func(pnl float64) float64 { return 100 * pnl / p.InitialBuyingPower }
+	final := p.InitialBuyingPower
+	if n := len(p.PortfolioCloseValues); n > 0 {
+		final = p.PortfolioCloseValues[n-1]
+	}
+	total := final - p.InitialBuyingPower
+	for _, f := range p.CashFlows {
+		total -= f.Amount
+	}
+	a := &Attribution{Total: AttributionRow{"total", total, points(total)}}
+
+	// Summed in a fixed order, so a run's figures repeat exactly.
+	books := p.bookPnL()
+	keys := make([]bookKey, 0, len(books))
+	for k := range books {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ticker != keys[j].ticker {
+			return keys[i].ticker < keys[j].ticker
+		}
+		return !keys[i].short && keys[j].short
+	})
+	tickers := map[string]float64{}
+	var long, short, traded float64
+	for _, k := range keys {
+		v := books[k]
+		tickers[k.ticker] += v
+		if k.short {
+			short += v
+		} else {
+			long += v
+		}
+		traded += v
+	}
+	// Configured tickers first, in order, then any others by name.
+	seen := map[string]bool{}
+	for _, t := range p.Tickers {
+		if v, ok := tickers[t]; ok && !seen[t] {
+			a.Tickers = append(a.Tickers, AttributionRow{t, v, points(v)})
+			seen[t] = true
+		}
+	}
+	var rest []string
+	for t := range tickers {
+		if !seen[t] {
+			rest = append(rest, t)
+		}
+	}
+	sort.Strings(rest)
+	for _, t := range rest {
+		a.Tickers = append(a.Tickers, AttributionRow{t, tickers[t], points(tickers[t])})
+	}
+	a.Books = []AttributionRow{{"long", long, points(long)}, {"short", short, points(short)}}
+	a.Other = AttributionRow{"other", total - traded, points(total - traded)}
+
+	if len(p.sleeveParts) > 0 {
+		for _, s := range p.sleeveParts {
+			pnl := s.Contribution * p.InitialBuyingPower / 100
+			a.Strategies = append(a.Strategies, AttributionRow{s.Strategy, pnl, s.Contribution})
+		}
+	} else {
+		a.Strategies = []AttributionRow{{p.Strategy.Name(), total, points(total)}}
+	}
+	return a
+}
+
+// WriteAttribution renders each of results' Attribution as a plain-text
+// table: its tickers, strategies and books, then what is left over and
+// the total. Results without one are skipped.
+func WriteAttribution(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "portfolio\tby\tname\tpnl\tcontribution %")
+	for _, r := range results {
+		a := r.Attribution
+		if a == nil {
+			continue
+		}
+		row := func(by string, x AttributionRow) {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%.2f\n", r.PortfolioName, by, x.Name, x.PnL, x.Contribution)
+		}
+		for _, x := range a.Tickers {
+			row("ticker", x)
+		}
+		for _, x := range a.Strategies {
+			row("strategy", x)
+		}
+		for _, x := range a.Books {
+			row("book", x)
+		}
+		row("", a.Other)
+		row("", a.Total)
+	}
+	return tw.Flush()
+}
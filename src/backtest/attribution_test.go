@@ -0,0 +1,105 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Long a rising ticker and short a falling one, half the long closed
+// part way: each ticker earns its own moves less its fees, the long
+// book is A's and the short B's, and with no interest nothing is left
+// over.
+func TestAttribution(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 20; i++ {
+		a, b := 10+float64(i), 30-float64(i)
+		store.bars["A"] = append(store.bars["A"], data.AssetData{Date: day(i), Open: a, High: a, Low: a, Close: a, Volume: 100})
+		store.bars["B"] = append(store.bars["B"], data.AssetData{Date: day(i), Open: b, High: b, Low: b, Close: b, Volume: 100})
+		store.rates[day(i).Unix()] = 0
+	}
+	script := filepath.Join(t.TempDir(), "longshort.lua")
+	if err := os.WriteFile(script, []byte(`
+n = 0
+function step(day)
+  n = n + 1
+  if n == 1 then
+    buy("A", 10, close_at("A", day), day)
+    sell("B", 10, close_at("B", day), day)
+  elseif n == 10 then
+    sell("A", 5, close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPortfolio("ls", 1000, []string{"A", "B"}, "lua:"+script,
+		WithWindow(day(0), day(19)), WithShorting(), WithCommission(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("run: %v, %d results", err, len(results))
+	}
+	r := results[0]
+	a := r.Attribution
+	if a == nil || len(a.Tickers) != 2 || len(a.Strategies) != 1 || len(a.Books) != 2 {
+		t.Fatalf("attribution = %+v", a)
+	}
+
+	var entryA, entryB float64
+	for _, tr := range r.Trades {
+		if tr.Ticker == "A" && entryA == 0 {
+			entryA = tr.Price
+		}
+		if tr.Ticker == "B" {
+			entryB = tr.Price
+		}
+	}
+	var exitA float64
+	for _, c := range r.ClosedTrades {
+		exitA = c.ExitPrice
+	}
+	// A: 5 shares closed and 5 held to the last close at 29, less two
+	// fees; B: 10 shares short to the last close at 11, less one.
+	wantA := 5*(exitA-entryA) + 5*(29-entryA) - 2
+	wantB := 10*(entryB-11) - 1
+	if x := a.Tickers[0]; x.Name != "A" || !closeTo(x.PnL, wantA) || !closeTo(x.Contribution, wantA/10) {
+		t.Errorf("A = %+v, want pnl %v", x, wantA)
+	}
+	if x := a.Tickers[1]; x.Name != "B" || !closeTo(x.PnL, wantB) {
+		t.Errorf("B = %+v, want pnl %v", x, wantB)
+	}
+	if l, s := a.Books[0], a.Books[1]; l.Name != "long" || !closeTo(l.PnL, wantA) || s.Name != "short" || !closeTo(s.PnL, wantB) {
+		t.Errorf("books = %+v, want A's long and B's short", a.Books)
+	}
+	final := r.EquityCurve[len(r.EquityCurve)-1]
+	if !closeTo(a.Total.PnL, final-1000) || !closeTo(a.Other.PnL+wantA+wantB, a.Total.PnL) || !closeTo(a.Other.PnL, 0) {
+		t.Errorf("other %+v, total %+v; want the tickers to explain the %v earned", a.Other, a.Total, final-1000)
+	}
+	if s := a.Strategies[0]; s.Name != r.Strategy || s.Contribution != a.Total.Contribution {
+		t.Errorf("strategy = %+v, want the whole return", s)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAttribution(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "book") || !strings.Contains(out, "short") || strings.Count(out, "\n") != 8 {
+		t.Errorf("table:\n%s", out)
+	}
+	buf.Reset()
+	if err := WriteHTMLReport(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<h3>Attribution</h3>") {
+		t.Error("html report has no attribution")
+	}
+}
@@ -0,0 +1,177 @@
+package backtest
+
+import (
+	"fmt"
+	"my-backtester/src/data"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LookaheadError reports a strategy reading a bar later than the one
+// being processed, caught when Portfolio.AuditLookahead is set. Such a
+// read lets a decision depend on prices it could not have known, so the
+// run's metrics are not trustworthy.
+type LookaheadError struct {
+	Portfolio string
+	Strategy  string
+	Day       int       // index of the bar being processed
+	Date      time.Time // its date
+	Ticker    string    // ticker read, if known
+	Read      int       // bar index read, or -1 if unknown
+	Detail    string    // e.g. the recovered index-out-of-range panic
+}
+
+func (e *LookaheadError) Error() string {
+	read := "a later bar"
+	if e.Read >= 0 {
+		read = fmt.Sprintf("bar %d", e.Read)
+	}
+	if e.Ticker != "" {
+		read += " of " + e.Ticker
+	}
+	msg := fmt.Sprintf("lookahead: %s (%s) read %s while processing bar %d (%s)",
+		e.Portfolio, e.Strategy, read, e.Day, e.Date.Format("2006-01-02"))
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// checkLookahead is called by the data accessors exposed to strategies
+// (the Lua helpers, FillPrice via Order) before they read ticker's bar at
+// day. Under audit it returns and records a *LookaheadError for a bar
+// after the current one; otherwise it returns nil.
+func (p *Portfolio) checkLookahead(ticker string, day int) error {
+	if !p.AuditLookahead || day <= p.bar {
+		return nil
+	}
+	err := p.lookaheadError()
+	err.Ticker, err.Read = ticker, day
+	p.noteLookahead(err)
+	return err
+}
+
+// checkSameBarFill is called by Order while an audited strategy is
+// stepping. Under audit it returns and records a *LookaheadError for an
+// order on the bar being decided that would fill at that bar's prices,
+// as every FillModel but FillNextOpen does: its close wasn't known until
+// the bar was over, when the decision was made.
+func (p *Portfolio) checkSameBarFill(ticker string, day int) error {
+	if !p.AuditLookahead || !p.deciding || day != p.bar || p.Fill == FillNextOpen {
+		return nil
+	}
+	err := p.lookaheadError()
+	err.Ticker, err.Read = ticker, day
+	err.Detail = fmt.Sprintf("order filled at the %s of the bar it was decided on; use FillPrice = %q", fillName(p.Fill), FillNextOpen)
+	p.noteLookahead(err)
+	return err
+}
+
+// fillName is m as a FillPrice value; "" is FillClose.
+func fillName(m FillModel) string {
+	if m == "" {
+		return string(FillClose)
+	}
+	return string(m)
+}
+
+func (p *Portfolio) lookaheadError() *LookaheadError {
+	name := ""
+	if p.Strategy != nil {
+		name = p.Strategy.Name()
+	}
+	return &LookaheadError{
+		Portfolio: p.Pname, Strategy: name,
+		Day: p.bar, Date: p.barDate, Read: -1,
+	}
+}
+
+// noteLookahead keeps the first violation of the run.
+func (p *Portfolio) noteLookahead(err *LookaheadError) {
+	if p.Lookahead == nil {
+		p.Lookahead = err
+	}
+}
+
+// auditView presents each series truncated to the current bar, with its
+// capacity capped too so it cannot be resliced past it. The same map is
+// updated in place every day, because strategies such as LuaStrategy
+// keep the map they were first stepped with.
+type auditView struct {
+	full map[string][]data.AssetData
+	view map[string][]data.AssetData
+}
+
+func newAuditView(hist map[string][]data.AssetData) *auditView {
+	return &auditView{full: hist, view: make(map[string][]data.AssetData, len(hist))}
+}
+
+func (v *auditView) at(day int) map[string][]data.AssetData {
+	for t, series := range v.full {
+		n := day + 1
+		if n > len(series) {
+			n = len(series)
+		}
+		v.view[t] = series[:n:n]
+	}
+	return v.view
+}
+
+// viewRead is an index or slice bound past the end of a series: the
+// runtime's "index out of range [7] with length 3" or "slice bounds out
+// of range [:7] with capacity 3".
+var viewRead = regexp.MustCompile(`out of range \[:?(\d+)\] with (?:length|capacity) (\d+)`)
+
+// lookahead reports whether re is the panic of reading a series of
+// v's view past the current bar: its bound is the length of one of the
+// view's series and its index a bar the full series has. It returns the
+// ticker and bar read.
+func (v *auditView) lookahead(re runtime.Error) (string, int, bool) {
+	m := viewRead.FindStringSubmatch(re.Error())
+	if m == nil {
+		return "", 0, false
+	}
+	read, _ := strconv.Atoi(m[1])
+	n, _ := strconv.Atoi(m[2])
+	tickers := make([]string, 0, len(v.view))
+	for t := range v.view {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	for _, t := range tickers {
+		if len(v.view[t]) == n && read >= n && read < len(v.full[t]) {
+			return t, read, true
+		}
+	}
+	return "", 0, false
+}
+
+// stepAudited steps the strategy on view's series truncated at day,
+// turning the panic of a read past the current bar into a recorded
+// LookaheadError. Any other panic, even one out of range of some other
+// slice, is re-raised.
+func (p *Portfolio) stepAudited(view *auditView, day int) {
+	p.deciding = true
+	defer func() {
+		p.deciding = false
+		r := recover()
+		if r == nil {
+			return
+		}
+		re, ok := r.(runtime.Error)
+		if !ok {
+			panic(r)
+		}
+		ticker, read, ok := view.lookahead(re)
+		if !ok {
+			panic(r)
+		}
+		err := p.lookaheadError()
+		err.Ticker, err.Read, err.Detail = ticker, read, re.Error()
+		p.noteLookahead(err)
+	}()
+	p.Strategy.Step(p, view.at(day), day)
+}
@@ -0,0 +1,186 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// peekStrategy buys on day 0 if the next bar closes higher — the classic
+// accidental lookahead.
+type peekStrategy struct{}
+
+func (peekStrategy) Name() string { return "peek" }
+
+func (peekStrategy) Step(p *Portfolio, hist map[string][]data.AssetData, day int) {
+	series := hist[p.Tickers[0]]
+	if series[day+1].Close > series[day].Close {
+		p.Order(p.Tickers[0], "BUY", 1, hist, day)
+	}
+}
+
+func auditHist() map[string][]data.AssetData {
+	series := make([]data.AssetData, 6)
+	for i := range series {
+		series[i] = data.AssetData{
+			Date:  time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC),
+			Open:  100 + float64(i),
+			Close: 100 + float64(i),
+		}
+	}
+	return map[string][]data.AssetData{"A": series}
+}
+
+func auditPortfolio(t *testing.T, spec string) *Portfolio {
+	t.Helper()
+	p, err := InitializePortfolio(
+		10_000, time.Time{}, time.Time{}, "audit", []string{"A"}, spec, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.AuditLookahead = true
+	return p
+}
+
+func TestAudit_CatchesIndexPastCurrentBar(t *testing.T) {
+	p := auditPortfolio(t, "greedy")
+	p.Strategy = peekStrategy{}
+	runOne(context.Background(), p, auditHist(), nil)
+
+	if p.Lookahead == nil {
+		t.Fatal("peeking strategy passed the audit")
+	}
+	if l := p.Lookahead; l.Day != 0 || l.Ticker != "A" || l.Read != 1 || !strings.Contains(l.Detail, "out of range") {
+		t.Errorf("violation = %v, want an out-of-range read of bar 1 of A on bar 0", p.Lookahead)
+	}
+	if res := newResult(p); !strings.Contains(res.Lookahead, "lookahead: audit (peek)") {
+		t.Errorf("Result.Lookahead = %q", res.Lookahead)
+	}
+	if len(p.DailyReturns) != 0 {
+		t.Errorf("run continued for %d bars after the violation", len(p.DailyReturns))
+	}
+}
+
+func TestAudit_CatchesFutureOrder(t *testing.T) {
+	p := auditPortfolio(t, "greedy")
+	hist := auditHist()
+	p.bar = 2
+	err := p.Order("A", "BUY", 1, hist, 3)
+	var le *LookaheadError
+	if !errors.As(err, &le) || le.Read != 3 || le.Ticker != "A" {
+		t.Fatalf("err = %v, want a LookaheadError reading bar 3 of A", err)
+	}
+	if len(p.Trades) != 0 {
+		t.Error("future-priced order was filled")
+	}
+}
+
+func TestAudit_CleanStrategyMatchesUnaudited(t *testing.T) {
+	tickers, hist := generateBenchData()
+	run := func(audit bool) Result {
+		p, err := InitializePortfolio(
+			100_000, time.Time{}, time.Time{}, "sma", tickers,
+			"smaCross:5:20:equalWeights", nil,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.AuditLookahead = audit
+		p.Fill = FillNextOpen
+		runOne(context.Background(), p, hist, nil)
+		return newResult(p)
+	}
+	plain, audited := run(false), run(true)
+	if audited.Lookahead != "" {
+		t.Fatalf("SMACross flagged: %s", audited.Lookahead)
+	}
+	if len(plain.Trades) != len(audited.Trades) || !reflect.DeepEqual(plain.Metrics, audited.Metrics) {
+		t.Error("auditing changed the run")
+	}
+}
+
+func TestAudit_LuaHelpersGated(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "peek.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  if close_at("A", day + 1) > close_at("A", day) then
+    buy("A", 1, close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := auditPortfolio(t, "lua:"+script)
+	runOne(context.Background(), p, auditHist(), nil)
+	if p.Lookahead == nil || p.Lookahead.Read != 1 {
+		t.Fatalf("violation = %v, want a read of bar 1 on bar 0", p.Lookahead)
+	}
+	if len(p.Trades) != 0 {
+		t.Error("lookahead step still traded")
+	}
+}
+
+// closeTrader buys on every bar at that bar's close, having seen it.
+type closeTrader struct{}
+
+func (closeTrader) Name() string { return "close" }
+
+func (closeTrader) Step(p *Portfolio, hist map[string][]data.AssetData, day int) {
+	p.Order(p.Tickers[0], "BUY", 1, hist, day)
+}
+
+// Deciding on a bar and filling at its own close is lookahead too;
+// filling at the next open is not.
+func TestAudit_CatchesSameBarFill(t *testing.T) {
+	for _, fill := range []FillModel{FillClose, FillTypical, FillNextOpen} {
+		p := auditPortfolio(t, "greedy")
+		p.Strategy = closeTrader{}
+		p.Fill = fill
+		runOne(context.Background(), p, auditHist(), nil)
+		if fill == FillNextOpen {
+			if p.Lookahead != nil || len(p.Trades) == 0 {
+				t.Errorf("next_open: violation %v, %d trades; want a clean run", p.Lookahead, len(p.Trades))
+			}
+			continue
+		}
+		if l := p.Lookahead; l == nil || l.Day != 0 || l.Read != 0 || !strings.Contains(l.Detail, "next_open") {
+			t.Errorf("%s: violation = %v, want the first bar's same-bar fill", fill, l)
+		}
+		if len(p.Trades) != 0 {
+			t.Errorf("%s: same-bar order was filled", fill)
+		}
+	}
+}
+
+// brokenStrategy indexes past the end of a slice of its own.
+type brokenStrategy struct{}
+
+func (brokenStrategy) Name() string { return "broken" }
+
+func (brokenStrategy) Step(p *Portfolio, hist map[string][]data.AssetData, day int) {
+	var weights []float64
+	_ = weights[day+3]
+}
+
+// A bug that panics out of range of something other than the audited
+// history is the bug, not lookahead.
+func TestAudit_RepanicsOtherOutOfRange(t *testing.T) {
+	p := auditPortfolio(t, "greedy")
+	p.Strategy = brokenStrategy{}
+	defer func() {
+		if recover() == nil {
+			t.Error("strategy's own panic was swallowed")
+		}
+		if p.Lookahead != nil {
+			t.Errorf("reported as %v", p.Lookahead)
+		}
+	}()
+	runOne(context.Background(), p, auditHist(), nil)
+}
@@ -0,0 +1,267 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// DefaultBaselineRuns is how many random portfolios RunBaselines draws
+// when its config sets no RandomRuns.
+const DefaultBaselineRuns = 20
+
+// BaselinePercentiles are the percentiles of the random portfolios'
+// metrics a Baselines reports.
+var BaselinePercentiles = []float64{5, 50, 95}
+
+// BaselineConfig tunes the reference portfolios run alongside a backtest
+// (see RunBaselines). All fields are optional.
+type BaselineConfig struct {
+	Disable bool `toml:"disable"` // run no baselines
+	// Stock and Bond are the 60/40 portfolio's tickers; default SPY and
+	// AGG.
+	Stock string `toml:"stock"`
+	Bond  string `toml:"bond"`
+	// RandomRuns is how many random portfolios, each with its own seed,
+	// the random baseline's band is drawn from; default
+	// DefaultBaselineRuns.
+	RandomRuns int `toml:"random_runs"`
+}
+
+// Baselines are the results of the reference portfolios RunBaselines
+// ran: what holding the same tickers without a strategy, or a plain
+// 60/40 split, or picking among them at random, returned over the same
+// window. Each is nil, or empty, when it had no bars to run on.
+type Baselines struct {
+	EqualWeight *Result
+	SixtyForty  *Result
+	// Random are the random portfolios' results in seed order, and
+	// RandomBand their metrics at each of BaselinePercentiles.
+	Random     []Result
+	RandomBand []BaselineBand
+}
+
+// BaselineBand is the random portfolios' metrics at one percentile.
+// Every metric is ranked on its own, so a band is not one portfolio.
+type BaselineBand struct {
+	Percentile   float64
+	TotalReturn  float64 // percent
+	AnnualReturn float64 // percent
+	SharpeRatio  float64
+	MaxDrawdown  float64 // percent
+}
+
+// Names of the baseline portfolios; random ones are numbered from 1.
+const (
+	baselineEqualWeight = "baseline:equal-weight"
+	baselineSixtyForty  = "baseline:60/40"
+	baselineRandom      = "baseline:random-"
+)
+
+// baselinePortfolios builds the reference portfolios for portfolios, all
+// over their combined window with the first one's cash, costs, calendar
+// and price mode:
+//   - an equal weight of every ticker they hold, rebalanced monthly (or
+//     of the first one's Universe, if it has one),
+//   - 60% in cfg.Stock and 40% in cfg.Bond, rebalanced monthly,
+//   - cfg.RandomRuns draws of half the same tickers, redrawn monthly and
+//     seeded one after the first portfolio's Seed.
+func baselinePortfolios(portfolios []*Portfolio, cfg BaselineConfig) ([]*Portfolio, error) {
+	if len(portfolios) == 0 {
+		return nil, nil
+	}
+	if cfg.Stock == "" {
+		cfg.Stock = "SPY"
+	}
+	if cfg.Bond == "" {
+		cfg.Bond = "AGG"
+	}
+	if cfg.RandomRuns == 0 {
+		cfg.RandomRuns = DefaultBaselineRuns
+	}
+	if cfg.RandomRuns < 0 {
+		return nil, fmt.Errorf("baselines random_runs %d: must not be negative", cfg.RandomRuns)
+	}
+	if cfg.Stock == cfg.Bond {
+		return nil, fmt.Errorf("baselines stock and bond are both %s", cfg.Stock)
+	}
+	first := portfolios[0]
+	start, end := dateRange(portfolios)
+	var tickers []string
+	if first.Universe != nil {
+		tickers = first.Tickers
+	} else {
+		seen := make(map[string]bool)
+		for _, p := range portfolios {
+			for _, t := range p.Tickers {
+				if !seen[t] {
+					seen[t] = true
+					tickers = append(tickers, t)
+				}
+			}
+		}
+		sort.Strings(tickers)
+	}
+
+	var out []*Portfolio
+	// add appends a baseline, with the first portfolio's Universe when
+	// it holds the same tickers.
+	add := func(name string, tickers []string, spec string, seed int64, sameTickers bool) error {
+		b, err := NewPortfolio(name, first.InitialBuyingPower, tickers, spec, WithWindow(start, end), WithSeed(seed))
+		if err != nil {
+			return fmt.Errorf("baseline %s: %w", name, err)
+		}
+		b.Calendar, b.Timeframe, b.Prices = first.Calendar, first.Timeframe, first.Prices
+		b.Fill, b.Commission, b.SlippageBps, b.Costs = first.Fill, first.Commission, first.SlippageBps, first.Costs
+		if sameTickers && first.Universe != nil {
+			u := *first.Universe
+			b.Universe = &u
+		}
+		out = append(out, b)
+		return nil
+	}
+	sameTickers := len(tickers) > 0 || first.Universe != nil
+	if sameTickers {
+		if err := add(baselineEqualWeight, tickers, "rebalance:month", first.Seed, true); err != nil {
+			return nil, err
+		}
+	}
+	sixtyForty := fmt.Sprintf("rebalance:month:%s=60,%s=40", cfg.Stock, cfg.Bond)
+	if err := add(baselineSixtyForty, []string{cfg.Stock, cfg.Bond}, sixtyForty, first.Seed, false); err != nil {
+		return nil, err
+	}
+	for i := 1; sameTickers && i <= cfg.RandomRuns; i++ {
+		if err := add(fmt.Sprintf("%s%02d", baselineRandom, i), tickers, "random:month", first.Seed+int64(i), true); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// RunBaselines runs reference portfolios over the tickers and window of
+// portfolios (see baselinePortfolios) against store, so a backtest's
+// results can be read against what no strategy at all would have made.
+// Their results aren't written anywhere; see WriteBaselines. A nil cfg
+// runs the defaults, and one with Disable set nothing.
+func RunBaselines(ctx context.Context, store Store, portfolios []*Portfolio, cfg *BaselineConfig) (*Baselines, error) {
+	var c BaselineConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Disable {
+		return nil, nil
+	}
+	bs, err := baselinePortfolios(portfolios, c)
+	if err != nil || len(bs) == 0 {
+		return nil, err
+	}
+	results, err := Run(ctx, store, bs, nil)
+	if err != nil {
+		return nil, fmt.Errorf("baselines: %w", err)
+	}
+	out := &Baselines{}
+	for i := range results {
+		r := results[i]
+		if len(r.EquityCurve) < 2 {
+			continue // no bars to run on
+		}
+		switch {
+		case r.PortfolioName == baselineEqualWeight:
+			out.EqualWeight = &r
+		case r.PortfolioName == baselineSixtyForty:
+			out.SixtyForty = &r
+		case strings.HasPrefix(r.PortfolioName, baselineRandom):
+			out.Random = append(out.Random, r)
+		}
+	}
+	out.RandomBand = baselineBands(out.Random)
+	return out, nil
+}
+
+// totalReturn is r's return over its whole run, in percent.
+func totalReturn(r Result) float64 {
+	if len(r.EquityCurve) == 0 || r.EquityCurve[0] == 0 {
+		return 0
+	}
+	return (r.EquityCurve[len(r.EquityCurve)-1]/r.EquityCurve[0] - 1) * 100
+}
+
+// baselineBands ranks each of results' metrics on its own and returns
+// them at BaselinePercentiles; nil without results.
+func baselineBands(results []Result) []BaselineBand {
+	if len(results) == 0 {
+		return nil
+	}
+	var total, annual, sharpe, maxDD []float64
+	for _, r := range results {
+		total = append(total, totalReturn(r))
+		annual = append(annual, r.Metrics.AnnualReturn)
+		sharpe = append(sharpe, r.Metrics.SharpeRatio)
+		maxDD = append(maxDD, r.Metrics.MaxDrawdown)
+	}
+	for _, s := range [][]float64{total, annual, sharpe, maxDD} {
+		sort.Float64s(s)
+	}
+	var bands []BaselineBand
+	for _, pc := range BaselinePercentiles {
+		q := pc / 100
+		bands = append(bands, BaselineBand{
+			Percentile:   pc,
+			TotalReturn:  stat.Quantile(q, stat.Empirical, total, nil),
+			AnnualReturn: stat.Quantile(q, stat.Empirical, annual, nil),
+			SharpeRatio:  stat.Quantile(q, stat.Empirical, sharpe, nil),
+			MaxDrawdown:  stat.Quantile(q, stat.Empirical, maxDD, nil),
+		})
+	}
+	return bands
+}
+
+// RandomRank is the percentage of b's random portfolios whose total
+// return r beat; -1 without any.
+func (b *Baselines) RandomRank(r Result) float64 {
+	if b == nil || len(b.Random) == 0 {
+		return -1
+	}
+	ours, beaten := totalReturn(r), 0
+	for _, rr := range b.Random {
+		if ours > totalReturn(rr) {
+			beaten++
+		}
+	}
+	return 100 * float64(beaten) / float64(len(b.Random))
+}
+
+// WriteBaselines renders b as a plain-text table, the baselines first and
+// then each of results with the share of random portfolios it beat.
+func WriteBaselines(w io.Writer, b *Baselines, results []Result) error {
+	if b == nil {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "portfolio\ttotal %\tannual %\tsharpe\tmax dd %\tbeats random %")
+	row := func(name string, total, annual, sharpe, maxDD float64, rank string) {
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%s\n", name, total, annual, sharpe, maxDD, rank)
+	}
+	for _, r := range []*Result{b.EqualWeight, b.SixtyForty} {
+		if r != nil {
+			row(r.PortfolioName, totalReturn(*r), r.Metrics.AnnualReturn, r.Metrics.SharpeRatio, r.Metrics.MaxDrawdown, "")
+		}
+	}
+	for _, band := range b.RandomBand {
+		row(fmt.Sprintf("%s p%g (of %d)", strings.TrimSuffix(baselineRandom, "-"), band.Percentile, len(b.Random)),
+			band.TotalReturn, band.AnnualReturn, band.SharpeRatio, band.MaxDrawdown, "")
+	}
+	for _, r := range results {
+		rank := "-"
+		if pc := b.RandomRank(r); pc >= 0 {
+			rank = fmt.Sprintf("%.0f", pc)
+		}
+		row(r.PortfolioName, totalReturn(r), r.Metrics.AnnualReturn, r.Metrics.SharpeRatio, r.Metrics.MaxDrawdown, rank)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,76 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"my-backtester/src/data"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBaselines(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	bars := map[string][]data.AssetData{}
+	for i := 0; i < 90; i++ {
+		for j, tk := range []string{"A", "B", "C", "D", "S", "G"} {
+			c := 10 + float64(i*(j%3))/10 // A and D flat, the rest rising at different rates
+			bars[tk] = append(bars[tk], data.AssetData{Date: day(i), Open: c, High: c, Low: c, Close: c, Volume: 100})
+		}
+	}
+	store := &fakeStore{bars: bars, rates: map[int64]float64{}}
+	p, err := NewPortfolio("mine", 1000, []string{"A", "B", "C", "D"}, "greedy", WithWindow(day(0), day(89)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &BaselineConfig{Stock: "S", Bond: "G", RandomRuns: 5}
+	b, err := RunBaselines(context.Background(), store, []*Portfolio{p}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.EqualWeight == nil || !reflect.DeepEqual(b.EqualWeight.Tickers, []string{"A", "B", "C", "D"}) {
+		t.Fatalf("equal weight = %+v", b.EqualWeight)
+	}
+	if b.SixtyForty == nil || len(b.SixtyForty.Trades) < 2 ||
+		b.SixtyForty.Trades[0].Amount != 60 || b.SixtyForty.Trades[1].Amount != 40 {
+		t.Fatalf("60/40 trades = %+v, want 60 S and 40 G at 10", b.SixtyForty)
+	}
+	if len(b.Random) != 5 || len(b.RandomBand) != len(BaselinePercentiles) {
+		t.Fatalf("%d random runs, %d bands", len(b.Random), len(b.RandomBand))
+	}
+	if lo, mid, hi := b.RandomBand[0], b.RandomBand[1], b.RandomBand[2]; lo.TotalReturn > mid.TotalReturn || mid.TotalReturn > hi.TotalReturn {
+		t.Errorf("bands out of order: %+v", b.RandomBand)
+	}
+	// Seeds repeat the draws.
+	again, err := RunBaselines(context.Background(), store, []*Portfolio{p}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.RandomBand, b.RandomBand) {
+		t.Errorf("bands %+v, then %+v", b.RandomBand, again.RandomBand)
+	}
+
+	var out bytes.Buffer
+	if err := WriteBaselines(&out, b, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"baseline:equal-weight", "baseline:60/40", "baseline:random p50 (of 5)", "mine"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	if b, err := RunBaselines(context.Background(), store, []*Portfolio{p}, &BaselineConfig{Disable: true}); b != nil || err != nil {
+		t.Errorf("disabled baselines ran: %+v, %v", b, err)
+	}
+	for _, spec := range []string{"rebalance:month:S=60,S=40", "rebalance:month:S", "rebalance:month:S=-1", "random:month:0", "random:fortnight"} {
+		if _, err := NewStrategy(spec, nil); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
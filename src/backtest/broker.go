@@ -0,0 +1,38 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+)
+
+// Executor is the engine's order interface: it receives each Trade the
+// simulated Portfolio accepts and submits the equivalent order to an
+// external venue. Signal logic stays in the Strategy, so a strategy
+// validated in backtests places the same orders live. Implementations
+// submit market orders; the venue's actual fill price is not fed back
+// into the simulated Portfolio. ctx bounds the request to the venue.
+type Executor interface {
+	Execute(ctx context.Context, t Trade) error
+}
+
+// BrokerConfig selects and configures the Executor used in paper mode.
+type BrokerConfig struct {
+	Name    string         `toml:"name"` // "" (simulate only), "alpaca", or "binance"
+	Alpaca  *AlpacaConfig  `toml:"Alpaca"`
+	Binance *BinanceConfig `toml:"Binance"`
+}
+
+// NewExecutor builds the Executor named by cfg. A nil cfg or empty name
+// returns a nil Executor, meaning trades stay inside the simulation.
+func NewExecutor(cfg *BrokerConfig) (Executor, error) {
+	if cfg == nil || cfg.Name == "" {
+		return nil, nil
+	}
+	switch cfg.Name {
+	case "alpaca":
+		return NewAlpacaExecutor(cfg.Alpaca)
+	case "binance":
+		return NewBinanceExecutor(cfg.Binance)
+	}
+	return nil, fmt.Errorf("unknown broker %q", cfg.Name)
+}
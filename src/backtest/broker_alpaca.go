@@ -0,0 +1,112 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AlpacaPaperURL = "https://paper-api.alpaca.markets"
+	AlpacaLiveURL  = "https://api.alpaca.markets"
+)
+
+// AlpacaConfig holds Alpaca API credentials. Empty keys fall back to the
+// APCA_API_KEY_ID / APCA_API_SECRET_KEY environment variables used by
+// Alpaca's own tooling.
+type AlpacaConfig struct {
+	KeyID     Secret `toml:"key_id"`
+	SecretKey Secret `toml:"secret_key"`
+	Live      bool   `toml:"live"`     // trade the live account instead of paper
+	BaseURL   string `toml:"base_url"` // overrides Live; mainly for tests
+}
+
+// AlpacaExecutor submits each Trade as a day market order through
+// Alpaca's v2 orders API.
+type AlpacaExecutor struct {
+	BaseURL   string
+	KeyID     Secret
+	SecretKey Secret
+	Client    *http.Client
+}
+
+func NewAlpacaExecutor(cfg *AlpacaConfig) (*AlpacaExecutor, error) {
+	if cfg == nil {
+		cfg = &AlpacaConfig{}
+	}
+	a := &AlpacaExecutor{
+		BaseURL:   AlpacaPaperURL,
+		KeyID:     cfg.KeyID,
+		SecretKey: cfg.SecretKey,
+		Client:    &http.Client{Timeout: 15 * time.Second},
+	}
+	if cfg.Live {
+		a.BaseURL = AlpacaLiveURL
+	}
+	if cfg.BaseURL != "" {
+		a.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	}
+	if a.KeyID == "" {
+		a.KeyID = Secret(os.Getenv("APCA_API_KEY_ID"))
+	}
+	if a.SecretKey == "" {
+		a.SecretKey = Secret(os.Getenv("APCA_API_SECRET_KEY"))
+	}
+	if a.KeyID == "" || a.SecretKey == "" {
+		return nil, fmt.Errorf("alpaca: key_id and secret_key are required")
+	}
+	return a, nil
+}
+
+type alpacaOrder struct {
+	Symbol      string `json:"symbol"`
+	Qty         string `json:"qty"`
+	Side        string `json:"side"`
+	Type        string `json:"type"`
+	TimeInForce string `json:"time_in_force"`
+}
+
+func (a *AlpacaExecutor) Execute(ctx context.Context, t Trade) error {
+	side := strings.ToLower(t.Side)
+	if side != "buy" && side != "sell" {
+		return fmt.Errorf("alpaca: unsupported side %q", t.Side)
+	}
+	body, err := json.Marshal(alpacaOrder{
+		Symbol:      t.Ticker,
+		Qty:         strconv.FormatFloat(t.Amount, 'f', -1, 64),
+		Side:        side,
+		Type:        "market",
+		TimeInForce: "day",
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, a.BaseURL+"/v2/orders", bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("APCA-API-KEY-ID", string(a.KeyID))
+	req.Header.Set("APCA-API-SECRET-KEY", string(a.SecretKey))
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alpaca: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("alpaca: order %s %s: %s: %s",
+			side, t.Ticker, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAlpacaExecutor_SubmitsMarketOrder(t *testing.T) {
+	var got alpacaOrder
+	var keyID, secret, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		keyID = r.Header.Get("APCA-API-KEY-ID")
+		secret = r.Header.Get("APCA-API-SECRET-KEY")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode order: %v", err)
+		}
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	a, err := NewAlpacaExecutor(&AlpacaConfig{
+		KeyID: "key", SecretKey: "secret", BaseURL: srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Execute(context.Background(), Trade{
+		Date: time.Now(), Ticker: "AAPL", Side: "BUY", Amount: 3, Price: 190,
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if path != "/v2/orders" || keyID != "key" || secret != "secret" {
+		t.Errorf("request path=%q key=%q secret=%q", path, keyID, secret)
+	}
+	want := alpacaOrder{
+		Symbol: "AAPL", Qty: "3", Side: "buy", Type: "market", TimeInForce: "day",
+	}
+	if got != want {
+		t.Errorf("order = %+v, want %+v", got, want)
+	}
+}
+
+func TestAlpacaExecutor_RejectedOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"insufficient buying power"}`))
+	}))
+	defer srv.Close()
+
+	a, err := NewAlpacaExecutor(&AlpacaConfig{
+		KeyID: "key", SecretKey: "secret", BaseURL: srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Execute(context.Background(), Trade{Ticker: "AAPL", Side: "SELL", Amount: 1}); err == nil {
+		t.Fatal("expected an error for a rejected order")
+	}
+}
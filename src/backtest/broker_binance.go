@@ -0,0 +1,106 @@
+package backtest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BinanceTestnetURL = "https://testnet.binance.vision"
+	BinanceLiveURL    = "https://api.binance.com"
+)
+
+// BinanceConfig holds Binance spot API credentials. Empty keys fall back
+// to the BINANCE_API_KEY / BINANCE_SECRET_KEY environment variables.
+type BinanceConfig struct {
+	APIKey    Secret `toml:"api_key"`
+	SecretKey Secret `toml:"secret_key"`
+	Live      bool   `toml:"live"`     // trade the live account instead of the spot testnet
+	BaseURL   string `toml:"base_url"` // overrides Live; mainly for tests
+}
+
+// BinanceExecutor submits each Trade as a signed spot MARKET order. The
+// quantity is sent as-is, so it must already respect the symbol's
+// LOT_SIZE step; Binance rejects it otherwise.
+type BinanceExecutor struct {
+	BaseURL   string
+	APIKey    Secret
+	SecretKey Secret
+	Client    *http.Client
+}
+
+func NewBinanceExecutor(cfg *BinanceConfig) (*BinanceExecutor, error) {
+	if cfg == nil {
+		cfg = &BinanceConfig{}
+	}
+	b := &BinanceExecutor{
+		BaseURL:   BinanceTestnetURL,
+		APIKey:    cfg.APIKey,
+		SecretKey: cfg.SecretKey,
+		Client:    &http.Client{Timeout: 15 * time.Second},
+	}
+	if cfg.Live {
+		b.BaseURL = BinanceLiveURL
+	}
+	if cfg.BaseURL != "" {
+		b.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	}
+	if b.APIKey == "" {
+		b.APIKey = Secret(os.Getenv("BINANCE_API_KEY"))
+	}
+	if b.SecretKey == "" {
+		b.SecretKey = Secret(os.Getenv("BINANCE_SECRET_KEY"))
+	}
+	if b.APIKey == "" || b.SecretKey == "" {
+		return nil, fmt.Errorf("binance: api_key and secret_key are required")
+	}
+	return b, nil
+}
+
+func (b *BinanceExecutor) Execute(ctx context.Context, t Trade) error {
+	side := strings.ToUpper(t.Side)
+	if side != "BUY" && side != "SELL" {
+		return fmt.Errorf("binance: unsupported side %q", t.Side)
+	}
+	q := url.Values{}
+	q.Set("symbol", t.Ticker)
+	q.Set("side", side)
+	q.Set("type", "MARKET")
+	q.Set("quantity", strconv.FormatFloat(t.Amount, 'f', -1, 64))
+	q.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	payload := q.Encode()
+	mac := hmac.New(sha256.New, []byte(string(b.SecretKey)))
+	mac.Write([]byte(payload))
+	payload += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, b.BaseURL+"/api/v3/order", strings.NewReader(payload),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-MBX-APIKEY", string(b.APIKey))
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("binance: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("binance: order %s %s: %s: %s",
+			side, t.Ticker, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package backtest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBinanceExecutor_SubmitsSignedMarketOrder(t *testing.T) {
+	var method, path, apiKey, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		apiKey = r.Header.Get("X-MBX-APIKEY")
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte(`{"orderId":1}`))
+	}))
+	defer srv.Close()
+
+	b, err := NewBinanceExecutor(&BinanceConfig{APIKey: "key", SecretKey: "secret", BaseURL: srv.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().UnixMilli()
+	if err := b.Execute(context.Background(), Trade{
+		Date: time.Now(), Ticker: "BTCUSDT", Side: "sell", Amount: 0.015, Price: 42000,
+	}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if method != http.MethodPost || path != "/api/v3/order" || apiKey != "key" {
+		t.Errorf("request %s %s key=%q", method, path, apiKey)
+	}
+
+	// The signature is the HMAC-SHA256 of everything before it.
+	payload, sig, ok := strings.Cut(body, "&signature=")
+	if !ok {
+		t.Fatalf("body %q has no signature", body)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(payload))
+	if want := hex.EncodeToString(mac.Sum(nil)); sig != want {
+		t.Errorf("signature = %s, want %s", sig, want)
+	}
+	q, err := url.ParseQuery(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		"symbol": "BTCUSDT", "side": "SELL", "type": "MARKET", "quantity": "0.015",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if ts, err := strconv.ParseInt(q.Get("timestamp"), 10, 64); err != nil || ts < before || ts > time.Now().UnixMilli() {
+		t.Errorf("timestamp = %q, want the submission time in ms", q.Get("timestamp"))
+	}
+}
+
+func TestBinanceExecutor_RejectedOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":-1013,"msg":"Filter failure: LOT_SIZE"}`))
+	}))
+	defer srv.Close()
+
+	b, err := NewBinanceExecutor(&BinanceConfig{APIKey: "key", SecretKey: "secret", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Execute(context.Background(), Trade{Ticker: "BTCUSDT", Side: "BUY", Amount: 0.0001})
+	if err == nil || !strings.Contains(err.Error(), "LOT_SIZE") {
+		t.Fatalf("err = %v, want Binance's rejection", err)
+	}
+	if err := b.Execute(context.Background(), Trade{Ticker: "BTCUSDT", Side: "HOLD", Amount: 1}); err == nil {
+		t.Error("expected an error for an unsupported side")
+	}
+}
+
+func TestNewBinanceExecutor(t *testing.T) {
+	t.Setenv("BINANCE_API_KEY", "")
+	t.Setenv("BINANCE_SECRET_KEY", "")
+	if _, err := NewBinanceExecutor(nil); err == nil {
+		t.Error("expected an error without credentials")
+	}
+	t.Setenv("BINANCE_API_KEY", "env-key")
+	t.Setenv("BINANCE_SECRET_KEY", "env-secret")
+	for _, c := range []struct {
+		cfg  *BinanceConfig
+		want string
+	}{
+		{nil, BinanceTestnetURL},
+		{&BinanceConfig{Live: true}, BinanceLiveURL},
+		{&BinanceConfig{Live: true, BaseURL: "http://localhost:9/"}, "http://localhost:9"},
+	} {
+		b, err := NewBinanceExecutor(c.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.BaseURL != c.want || b.APIKey != "env-key" || b.SecretKey != "env-secret" {
+			t.Errorf("%+v: BaseURL %q, key %q", c.cfg, b.BaseURL, b.APIKey)
+		}
+	}
+}
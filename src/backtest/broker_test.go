@@ -0,0 +1,81 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubExecutor fails every trade with err, or accepts them all if nil.
+type stubExecutor struct {
+	err    error
+	trades []Trade
+}
+
+func (s *stubExecutor) Execute(_ context.Context, t Trade) error {
+	s.trades = append(s.trades, t)
+	return s.err
+}
+
+// A trade the broker fails is refused, not booked, so the simulation
+// stays in step with the account, and the Result counts it.
+func TestRecord_ExecutionFailure(t *testing.T) {
+	broker := &stubExecutor{err: errors.New("insufficient buying power")}
+	p := newPropPortfolio(1000, AccountingFloat)
+	p.Executor = broker
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	err := p.Buy("A", 5, 10, date)
+	var oe *OrderError
+	if !errors.As(err, &oe) || !errors.Is(err, ErrExecution) || !errors.Is(err, broker.err) {
+		t.Fatalf("Buy err = %v, want an OrderError wrapping ErrExecution and the broker's error", err)
+	}
+	if len(p.Trades) != 0 || len(p.Positions) != 0 || p.BuyingPower != 1000 {
+		t.Errorf("failed buy booked: %d trades, %d positions, cash %v", len(p.Trades), len(p.Positions), p.BuyingPower)
+	}
+
+	broker.err = nil
+	if err := p.Buy("A", 5, 10, date); err != nil {
+		t.Fatal(err)
+	}
+	broker.err = errors.New("market closed")
+	if err := p.Sell("A", 5, 12, date); !errors.Is(err, ErrExecution) {
+		t.Fatalf("Sell err = %v, want ErrExecution", err)
+	}
+	if pos := p.Positions["A"]; pos == nil || pos.Amount != 5 || len(p.Trades) != 1 || p.BuyingPower != 950 {
+		t.Errorf("failed sell booked: position %+v, %d trades, cash %v", pos, len(p.Trades), p.BuyingPower)
+	}
+
+	if p.ExecutionFailures != 2 || len(broker.trades) != 3 {
+		t.Errorf("ExecutionFailures = %d after %d submissions, want 2 of 3", p.ExecutionFailures, len(broker.trades))
+	}
+	if p.Strategy, err = NewStrategy("buyAndHold", nil); err != nil {
+		t.Fatal(err)
+	}
+	if r := newResult(p); r.ExecutionFailures != 2 || NewResultJSON(r).ExecutionFailures != 2 {
+		t.Errorf("Result.ExecutionFailures = %d", r.ExecutionFailures)
+	}
+}
+
+// A failing webhook doesn't refuse trades, and one the broker fails
+// isn't posted.
+func TestExecutors_BrokerThenNotifier(t *testing.T) {
+	broker := &stubExecutor{}
+	hook := &stubExecutor{err: errors.New("webhook down")}
+	p := newPropPortfolio(1000, AccountingFloat)
+	p.Executor = Executors{broker, notifier{hook}}
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	if err := p.Buy("A", 5, 10, date); err != nil {
+		t.Fatalf("failing webhook refused the trade: %v", err)
+	}
+	broker.err = errors.New("rejected")
+	if err := p.Buy("A", 5, 10, date); !errors.Is(err, ErrExecution) {
+		t.Fatalf("err = %v, want ErrExecution", err)
+	}
+	if len(hook.trades) != 1 || len(p.Trades) != 1 || p.ExecutionFailures != 1 {
+		t.Errorf("webhook saw %d trades, ledger %d, failures %d; want 1, 1, 1",
+			len(hook.trades), len(p.Trades), p.ExecutionFailures)
+	}
+}
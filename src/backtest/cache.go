@@ -0,0 +1,233 @@
+package backtest
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// barSize is the memory one cached bar takes.
+const barSize = int64(unsafe.Sizeof(data.AssetData{}))
+
+// BarCache keeps the bars Stores return in memory, keyed by source,
+// ticker and window, so repeated runs over the same data (the UI's
+// re-runs, grid searches) skip the database. It holds at most its budget
+// in bytes of bars, evicting the least recently used series first, and
+// is safe for concurrent use. Cached series are shared between runs and
+// must not be modified; the engine only reads them.
+type BarCache struct {
+	mu      sync.Mutex
+	budget  int64
+	used    int64
+	order   *list.List // of *barEntry, most recently used first
+	entries map[barKey]*list.Element
+	hits    int
+	misses  int
+}
+
+type barKey struct {
+	source, ticker string
+	start, end     int64 // Unix nanoseconds; 0 when unbounded
+}
+
+type barEntry struct {
+	key  barKey
+	bars []data.AssetData
+}
+
+// NewBarCache returns a cache holding up to budget bytes of bars.
+func NewBarCache(budget int64) *BarCache {
+	return &BarCache{budget: budget, order: list.New(), entries: map[barKey]*list.Element{}}
+}
+
+// Wrap returns store with its bar queries served through the cache.
+// source names the data store behind it (e.g. its DuckDB path), so
+// several stores can share one cache; anything else store does passes
+// through.
+func (c *BarCache) Wrap(source string, store Store) Store {
+	return &cachedStore{Store: store, cache: c, source: source}
+}
+
+// SharedBarCache is the cache RunFromConfigText uses when the config's
+// [Database] sets cache_mb, so it lives across the runs of one process.
+var SharedBarCache = NewBarCache(0)
+
+// SetBudget changes the cache's budget, evicting the least recently
+// used series until it fits.
+func (c *BarCache) SetBudget(budget int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+	c.evict(0)
+}
+
+// Stats reports the cache's lookups so far and the bytes it holds.
+func (c *BarCache) Stats() (hits, misses int, used int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, c.used
+}
+
+// Clear empties the cache, e.g. after new bars are ingested.
+func (c *BarCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[barKey]*list.Element{}
+	c.used = 0
+}
+
+func (c *BarCache) get(k barKey) ([]data.AssetData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[k]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*barEntry).bars, true
+}
+
+// put caches bars under k unless they alone exceed the budget, evicting
+// older series to make room.
+func (c *BarCache) put(k barKey, bars []data.AssetData) {
+	size := int64(len(bars)) * barSize
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size > c.budget {
+		return
+	}
+	if e, ok := c.entries[k]; ok {
+		c.used -= int64(len(e.Value.(*barEntry).bars)) * barSize
+		c.order.Remove(e)
+	}
+	c.evict(size)
+	c.entries[k] = c.order.PushFront(&barEntry{k, bars})
+	c.used += size
+}
+
+// evict drops the least recently used series until room more bytes fit
+// in the budget.
+func (c *BarCache) evict(room int64) {
+	for c.used+room > c.budget && c.order.Len() > 0 {
+		oldest := c.order.Back()
+		ent := oldest.Value.(*barEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, ent.key)
+		c.used -= int64(len(ent.bars)) * barSize
+	}
+}
+
+type cachedStore struct {
+	Store
+	cache  *BarCache
+	source string
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func (s *cachedStore) QueryAssetsForTickers(
+	ctx context.Context, tickers []string, start, end time.Time,
+) map[string][]data.AssetData {
+	out, _ := s.QueryAssets(ctx, tickers, start, end)
+	return out
+}
+
+// QueryAssets answers from the cache what it can and queries the rest
+// in one batch. A ticker the Store has no bars for is cached as empty
+// too; nothing is cached from a failed or cancelled query. The error is
+// the Store's if it is a CheckedStore.
+func (s *cachedStore) QueryAssets(
+	ctx context.Context, tickers []string, start, end time.Time,
+) (map[string][]data.AssetData, error) {
+	out := make(map[string][]data.AssetData, len(tickers))
+	var missing []string
+	for _, t := range tickers {
+		bars, ok := s.cache.get(s.key(t, start, end))
+		switch {
+		case !ok:
+			missing = append(missing, t)
+		case len(bars) > 0:
+			out[t] = bars
+		}
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+	var fetched map[string][]data.AssetData
+	var err error
+	if cs, ok := s.Store.(CheckedStore); ok {
+		fetched, err = cs.QueryAssets(ctx, missing, start, end)
+	} else {
+		fetched = s.Store.QueryAssetsForTickers(ctx, missing, start, end)
+	}
+	if err != nil || ctx.Err() != nil {
+		// The query may have returned only part of the data.
+		for t, bars := range fetched {
+			out[t] = bars
+		}
+		return out, err
+	}
+	for _, t := range missing {
+		bars := fetched[t]
+		s.cache.put(s.key(t, start, end), bars)
+		if len(bars) > 0 {
+			out[t] = bars
+		}
+	}
+	return out, nil
+}
+
+// QueryRiskFree passes through to the Store, so caching bars doesn't
+// hide its errors from Run.
+func (s *cachedStore) QueryRiskFree(ctx context.Context, start, end time.Time) (map[int64]float64, error) {
+	if cs, ok := s.Store.(CheckedStore); ok {
+		return cs.QueryRiskFree(ctx, start, end)
+	}
+	return s.Store.GetRiskFreeRates(ctx, start, end), nil
+}
+
+func (s *cachedStore) key(ticker string, start, end time.Time) barKey {
+	return barKey{s.source, ticker, unixNano(start), unixNano(end)}
+}
+
+// InsertResults passes through to the Store, so a cached run can still
+// save its results (see SaveResults).
+func (s *cachedStore) InsertResults(ctx context.Context, rows []data.ResultRow) error {
+	ins, ok := s.Store.(ResultInserter)
+	if !ok {
+		return fmt.Errorf("%T cannot store results", s.Store)
+	}
+	return ins.InsertResults(ctx, rows)
+}
+
+// QueryActions passes through to the Store, so caching doesn't hide its
+// corporate actions.
+func (s *cachedStore) QueryActions(
+	ctx context.Context, tickers []string, start, end time.Time,
+) map[string][]data.CorporateAction {
+	if as, ok := s.Store.(ActionStore); ok {
+		return as.QueryActions(ctx, tickers, start, end)
+	}
+	return nil
+}
+
+// SymbolChanges passes through to the Store, so caching doesn't hide
+// its renames.
+func (s *cachedStore) SymbolChanges(ctx context.Context) ([]data.SymbolChange, error) {
+	if ss, ok := s.Store.(SymbolStore); ok {
+		return ss.SymbolChanges(ctx)
+	}
+	return nil, nil
+}
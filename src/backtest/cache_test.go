@@ -0,0 +1,94 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBarCache(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}}
+	for _, tk := range []string{"A", "B", "C"} {
+		for i := 0; i < 10; i++ {
+			store.bars[tk] = append(store.bars[tk], data.AssetData{Date: day(i), Close: 10 + float64(i)})
+		}
+	}
+	ctx := context.Background()
+	// Room for two 10-bar series.
+	cache := NewBarCache(20 * barSize)
+	cached := cache.Wrap("db", store)
+
+	first := cached.QueryAssetsForTickers(ctx, []string{"A", "B"}, day(0), day(9))
+	again := cached.QueryAssetsForTickers(ctx, []string{"A", "B", "X"}, day(0), day(9))
+	if !reflect.DeepEqual(store.queried, []string{"A", "B", "X"}) {
+		t.Errorf("queried %v, want A and B once and the unknown X", store.queried)
+	}
+	if !reflect.DeepEqual(first, again) || len(again["A"]) != 10 {
+		t.Errorf("cached bars differ: %v vs %v", first, again)
+	}
+	if _, _, used := cache.Stats(); used != 20*barSize {
+		t.Errorf("used %d bytes, want %d", used, 20*barSize)
+	}
+
+	// Another window is another entry; loading C then evicts the least
+	// recently used series, B.
+	cached.QueryAssetsForTickers(ctx, []string{"A"}, day(0), day(4))
+	cached.QueryAssetsForTickers(ctx, []string{"A"}, day(0), day(9))
+	cached.QueryAssetsForTickers(ctx, []string{"C"}, day(0), day(9))
+	store.queried = nil
+	cached.QueryAssetsForTickers(ctx, []string{"A", "B"}, day(0), day(9))
+	if !reflect.DeepEqual(store.queried, []string{"B"}) {
+		t.Errorf("after eviction queried %v, want B", store.queried)
+	}
+
+	// Sources don't share entries.
+	store.queried = nil
+	cache.Wrap("other.db", store).QueryAssetsForTickers(ctx, []string{"A"}, day(0), day(9))
+	if len(store.queried) != 1 {
+		t.Errorf("another source was served from the cache")
+	}
+
+	cache.SetBudget(0)
+	if _, _, used := cache.Stats(); used != 0 {
+		t.Errorf("used %d bytes after shrinking the budget to 0", used)
+	}
+}
+
+func TestBarCache_Concurrent(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &lockedStore{fakeStore: &fakeStore{bars: map[string][]data.AssetData{
+		"A": {{Date: day(0), Close: 1}, {Date: day(1), Close: 2}},
+	}}}
+	cached := NewBarCache(1<<20).Wrap("db", store)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got := cached.QueryAssetsForTickers(context.Background(), []string{"A"}, day(0), day(j%2)); len(got["A"]) != 1+j%2 {
+					t.Errorf("got %v", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// lockedStore serializes fakeStore's queries, which record what they
+// were asked for.
+type lockedStore struct {
+	mu sync.Mutex
+	*fakeStore
+}
+
+func (s *lockedStore) QueryAssetsForTickers(ctx context.Context, tickers []string, start, end time.Time) map[string][]data.AssetData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fakeStore.QueryAssetsForTickers(ctx, tickers, start, end)
+}
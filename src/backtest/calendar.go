@@ -0,0 +1,121 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/calendar"
+	"time"
+)
+
+// Calendar names the trading-session convention a portfolio's market
+// follows. Metrics annualize by its PeriodsPerYear, so a 24/7 crypto
+// series isn't scaled as if it traded 252 days a year.
+type Calendar string
+
+const (
+	// CalendarEquities is a weekday exchange calendar: 252 sessions a
+	// year. The default.
+	CalendarEquities Calendar = "equities"
+	// CalendarCrypto trades every day: 365 sessions a year.
+	CalendarCrypto Calendar = "crypto"
+	// CalendarNYSE is the NYSE's own calendar (calendar.NYSE): metrics
+	// annualize by the sessions it had in the years simulated, and bars
+	// dated on days it was closed are dropped.
+	CalendarNYSE Calendar = "nyse"
+)
+
+// ParseCalendar validates a Calendar config value; "" selects
+// CalendarEquities.
+func ParseCalendar(s string) (Calendar, error) {
+	switch c := Calendar(s); c {
+	case "":
+		return CalendarEquities, nil
+	case CalendarEquities, CalendarCrypto, CalendarNYSE:
+		return c, nil
+	}
+	return "", fmt.Errorf("calendar %q: must be equities, crypto or nyse", s)
+}
+
+// Exchange is c's session calendar: weekdays for CalendarEquities.
+func (c Calendar) Exchange() *calendar.Exchange {
+	switch c {
+	case CalendarCrypto:
+		return calendar.AlwaysOpen
+	case CalendarNYSE:
+		return calendar.NYSE
+	}
+	return calendar.Weekdays
+}
+
+// PeriodsPerYear is the number of daily bars in a year. Unknown values
+// fall back to the equities convention, as does CalendarNYSE, whose
+// count depends on the years (see periodsPerYear).
+func (c Calendar) PeriodsPerYear() float64 {
+	if c == CalendarCrypto {
+		return 365
+	}
+	return 252
+}
+
+// SessionLength is how long c's market trades each session: the NYSE's
+// 9:30 to 4 pm regular session for CalendarEquities and CalendarNYSE,
+// the whole day for CalendarCrypto. Intraday bars are annualized by how
+// many fit in one.
+func (c Calendar) SessionLength() time.Duration {
+	if c == CalendarCrypto {
+		return 24 * time.Hour
+	}
+	return 6*time.Hour + 30*time.Minute
+}
+
+// periodsPerYear is c's annualization factor for bars from start to
+// end: under CalendarNYSE the mean sessions a year over those years,
+// otherwise PeriodsPerYear.
+func (c Calendar) periodsPerYear(start, end time.Time) float64 {
+	if c != CalendarNYSE || start.IsZero() || end.IsZero() {
+		return c.PeriodsPerYear()
+	}
+	return calendar.NYSE.SessionsPerYear(start, end)
+}
+
+// sessionFilter is the test alignWindow drops bars by: nil, keeping
+// every bar, except under CalendarNYSE.
+func (c Calendar) sessionFilter() func(time.Time) bool {
+	if c != CalendarNYSE {
+		return nil
+	}
+	return calendar.NYSE.IsOpen
+}
+
+// periodsPerYear is p's annualization factor over the bars it simulated:
+// its Timeframe's, for bars longer than a day, or the sessions a year
+// times the bars in a session for intraday ones.
+func (p *Portfolio) periodsPerYear() float64 {
+	if n := p.Timeframe.PeriodsPerYear(); n > 0 {
+		return n
+	}
+	return p.Calendar.periodsPerYear(p.EffectiveStart, p.EffectiveEnd) * p.barsPerSession()
+}
+
+// barsPerSession is the number of p's bars in a session: 1 for daily
+// bars, those starting within the session for intraday ones.
+func (p *Portfolio) barsPerSession() float64 {
+	if p.Interval <= 0 {
+		return 1
+	}
+	return math.Ceil(float64(p.Calendar.SessionLength()) / float64(p.Interval))
+}
+
+// barRates scales daily risk-free rates to p's bars, which may be
+// shorter or longer than a day; rates itself for daily bars.
+func (p *Portfolio) barRates(rates map[int64]float64) map[int64]float64 {
+	scale := p.Calendar.periodsPerYear(p.EffectiveStart, p.EffectiveEnd) / p.periodsPerYear()
+	if scale == 1 || len(rates) == 0 {
+		return rates
+	}
+	scaled := make(map[int64]float64, len(rates))
+	for ts, r := range rates {
+		scaled[ts] = r * scale
+	}
+	return scaled
+}
@@ -0,0 +1,96 @@
+package backtest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the engine's source of "now". Backtests and replays run on a
+// SimClock the engine advances to each bar's date; live paper trading
+// runs on RealClock. Time-dependent logic asks the portfolio's clock
+// (Portfolio.Now) instead of calling time.Now, so it behaves the same in
+// every mode.
+type Clock interface {
+	Now() time.Time
+	// After is time.After in the clock's time.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is wall-clock time in UTC.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time                         { return time.Now().UTC() }
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SimClock is simulated time, moved only by Set. After fires once Set
+// reaches the deadline, so waits take no wall-clock time.
+type SimClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []simWaiter
+}
+
+type simWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func NewSimClock(start time.Time) *SimClock { return &SimClock{now: start} }
+
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to t, firing every After whose deadline has
+// passed. Moving backwards is ignored.
+func (c *SimClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.Before(c.now) {
+		return
+	}
+	c.now = t
+	kept := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			kept = append(kept, w)
+			continue
+		}
+		w.ch <- t
+	}
+	c.waiters = kept
+}
+
+func (c *SimClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	at := c.now.Add(d)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, simWaiter{at, ch})
+	return ch
+}
+
+// WithClock sets the clock the portfolio's engine runs on. Without one,
+// backtests get a fresh SimClock.
+func WithClock(c Clock) Option {
+	return func(p *Portfolio) error {
+		p.Clock = c
+		return nil
+	}
+}
+
+// Now is the current time on the portfolio's clock: the date of the bar
+// being processed in a backtest, or wall-clock time when trading live.
+// It is the zero time before a run starts.
+func (p *Portfolio) Now() time.Time {
+	if p.Clock == nil {
+		return time.Time{}
+	}
+	return p.Clock.Now()
+}
@@ -0,0 +1,70 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestSimClock_AfterFiresOnSet(t *testing.T) {
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	c := NewSimClock(start)
+	ch := c.After(48 * time.Hour)
+	c.Set(start.Add(24 * time.Hour))
+	select {
+	case <-ch:
+		t.Fatal("fired a day early")
+	default:
+	}
+	c.Set(start) // backwards: ignored
+	if !c.Now().Equal(start.Add(24 * time.Hour)) {
+		t.Errorf("Now = %v after moving backwards", c.Now())
+	}
+	c.Set(start.Add(72 * time.Hour))
+	select {
+	case got := <-ch:
+		if !got.Equal(start.Add(72 * time.Hour)) {
+			t.Errorf("fired with %v", got)
+		}
+	default:
+		t.Fatal("did not fire once the deadline passed")
+	}
+}
+
+// clockStrategy records the portfolio clock on every step.
+type clockStrategy struct{ seen []time.Time }
+
+func (s *clockStrategy) Name() string { return "clock" }
+
+func (s *clockStrategy) Step(p *Portfolio, hist map[string][]data.AssetData, day int) {
+	s.seen = append(s.seen, p.Now())
+}
+
+func TestEngine_AdvancesSimClock(t *testing.T) {
+	hist := auditHist()
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &clockStrategy{}
+	p.Strategy = s
+	runOne(context.Background(), p, hist, nil)
+	if len(s.seen) != len(hist["A"]) {
+		t.Fatalf("stepped %d times", len(s.seen))
+	}
+	for i, bar := range hist["A"] {
+		if !s.seen[i].Equal(bar.Date) {
+			t.Errorf("day %d: Now = %v, want the bar's date %v", i, s.seen[i], bar.Date)
+		}
+	}
+
+	// Clones get their own SimClock rather than sharing the run's.
+	c, err := p.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Clock != nil {
+		t.Errorf("clone shares clock %v", c.Clock)
+	}
+}
@@ -0,0 +1,223 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// StrategyComparison sets the results of several portfolios run over the
+// same data side by side: the `compare` command's report (see
+// CompareStrategies).
+type StrategyComparison struct {
+	Portfolios []string
+	// Metrics are each result's own, 1:1 with Portfolios.
+	Metrics []ComparedMetrics
+	// Dates are the dates every result has a value on, and Equity each
+	// result's value on them scaled to start at 100, 1:1 with
+	// Portfolios, so curves from different starting cash overlay.
+	Dates  []string
+	Equity [][]float64
+	// Correlation is the correlation of each two results' daily returns
+	// over Dates, Correlation[i][j] for Portfolios i and j, and Pairs the
+	// same pairs' other statistics, each pair once.
+	Correlation [][]float64
+	Pairs       []StrategyPair
+}
+
+// ComparedMetrics are one result's headline numbers in a
+// StrategyComparison.
+type ComparedMetrics struct {
+	TotalReturn  float64 // percent
+	AnnualReturn float64 // percent
+	SharpeRatio  float64
+	SortinoRatio float64
+	MaxDrawdown  float64 // percent
+	CalmarRatio  float64
+	Trades       int
+}
+
+// StrategyPair compares two results' daily returns over a
+// StrategyComparison's Dates.
+type StrategyPair struct {
+	A, B        string
+	Correlation float64
+	// TrackingError is the annualized standard deviation of A's daily
+	// return less B's, in percent.
+	TrackingError float64
+	// AWins is the percentage of days A returned more than B.
+	AWins float64
+}
+
+// CompareStrategies lines results up on the dates they all have and
+// compares them, in results' order. Duplicate portfolio names are told
+// apart by their position.
+func CompareStrategies(results []Result) StrategyComparison {
+	var c StrategyComparison
+	seen := make(map[string]int, len(results))
+	for _, r := range results {
+		name := r.PortfolioName
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, seen[name])
+		}
+		c.Portfolios = append(c.Portfolios, name)
+		c.Metrics = append(c.Metrics, ComparedMetrics{
+			TotalReturn:  totalReturn(r),
+			AnnualReturn: r.Metrics.AnnualReturn,
+			SharpeRatio:  r.Metrics.SharpeRatio,
+			SortinoRatio: r.Metrics.SortinoRatio,
+			MaxDrawdown:  r.Metrics.MaxDrawdown,
+			CalmarRatio:  r.Metrics.CalmarRatio,
+			Trades:       len(r.Trades),
+		})
+	}
+	if len(results) == 0 {
+		return c
+	}
+
+	// Dates every result has, in the first one's order.
+	count := make(map[string]int)
+	for _, r := range results {
+		for _, d := range r.Dates {
+			count[d]++
+		}
+	}
+	for _, d := range results[0].Dates {
+		if count[d] == len(results) {
+			c.Dates = append(c.Dates, d)
+		}
+	}
+	returns := make([][]float64, len(results))
+	for i, r := range results {
+		byDate := make(map[string]float64, len(r.Dates))
+		for j, d := range r.Dates {
+			if j < len(r.EquityCurve) {
+				byDate[d] = r.EquityCurve[j]
+			}
+		}
+		equity := make([]float64, len(c.Dates))
+		for j, d := range c.Dates {
+			equity[j] = byDate[d]
+		}
+		returns[i] = returnsFromEquity(equity)
+		if len(equity) > 0 && equity[0] != 0 {
+			for j := len(equity) - 1; j >= 0; j-- {
+				equity[j] = equity[j] / equity[0] * 100
+			}
+		}
+		c.Equity = append(c.Equity, equity)
+	}
+
+	c.Correlation = make([][]float64, len(results))
+	for i := range c.Correlation {
+		c.Correlation[i] = make([]float64, len(results))
+		c.Correlation[i][i] = 1
+	}
+	for i := range results {
+		for j := i + 1; j < len(results); j++ {
+			p := comparePair(returns[i], returns[j])
+			p.A, p.B = c.Portfolios[i], c.Portfolios[j]
+			c.Correlation[i][j], c.Correlation[j][i] = p.Correlation, p.Correlation
+			c.Pairs = append(c.Pairs, p)
+		}
+	}
+	return c
+}
+
+// comparePair compares two series of daily returns on the same dates,
+// skipping the first, which has no return.
+func comparePair(a, b []float64) StrategyPair {
+	var p StrategyPair
+	if len(a) < 3 {
+		return p
+	}
+	a, b = a[1:], b[1:]
+	p.Correlation = stat.Correlation(a, b, nil)
+	if math.IsNaN(p.Correlation) {
+		p.Correlation = 0 // a flat series
+	}
+	diff := make([]float64, len(a))
+	wins := 0
+	for i := range a {
+		diff[i] = a[i] - b[i]
+		if a[i] > b[i] {
+			wins++
+		}
+	}
+	p.TrackingError = stat.StdDev(diff, nil) * math.Sqrt(DefaultPeriodsPerYear) * 100
+	p.AWins = 100 * float64(wins) / float64(len(a))
+	return p
+}
+
+// WriteStrategyComparison renders c as plain-text tables: the metrics
+// with a column per portfolio, the correlation matrix and the pairs.
+func WriteStrategyComparison(w io.Writer, c StrategyComparison) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := func(first string) {
+		fmt.Fprintf(tw, "%s\t%s\n", first, strings.Join(c.Portfolios, "\t"))
+	}
+	row := func(name string, value func(ComparedMetrics) string) {
+		cells := make([]string, len(c.Metrics))
+		for i, m := range c.Metrics {
+			cells[i] = value(m)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, strings.Join(cells, "\t"))
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	header("metric")
+	row("total %", func(m ComparedMetrics) string { return f(m.TotalReturn) })
+	row("annual %", func(m ComparedMetrics) string { return f(m.AnnualReturn) })
+	row("sharpe", func(m ComparedMetrics) string { return f(m.SharpeRatio) })
+	row("sortino", func(m ComparedMetrics) string { return f(m.SortinoRatio) })
+	row("max dd %", func(m ComparedMetrics) string { return f(m.MaxDrawdown) })
+	row("calmar", func(m ComparedMetrics) string { return f(m.CalmarRatio) })
+	row("trades", func(m ComparedMetrics) string { return strconv.Itoa(m.Trades) })
+	if len(c.Portfolios) > 1 {
+		fmt.Fprintln(tw)
+		header(fmt.Sprintf("correlation (%d days)", len(c.Dates)))
+		for i, name := range c.Portfolios {
+			cells := make([]string, len(c.Correlation[i]))
+			for j, v := range c.Correlation[i] {
+				cells[j] = f(v)
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", name, strings.Join(cells, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		// The pairs have their own columns.
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "pair\tcorrelation\ttracking error %\tfirst wins %")
+		for _, p := range c.Pairs {
+			fmt.Fprintf(tw, "%s vs %s\t%s\t%s\t%s\n", p.A, p.B, f(p.Correlation), f(p.TrackingError), f(p.AWins))
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteComparisonEquityCSV writes c's scaled equity curves as CSV, a
+// date column and one per portfolio, for overlaying elsewhere.
+func WriteComparisonEquityCSV(w io.Writer, c StrategyComparison) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"date"}, c.Portfolios...)); err != nil {
+		return err
+	}
+	for i, d := range c.Dates {
+		rec := []string{d}
+		for _, equity := range c.Equity {
+			rec = append(rec, strconv.FormatFloat(equity[i], 'f', -1, 64))
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
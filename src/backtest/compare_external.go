@@ -0,0 +1,357 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// ExternalResult is a run exported from another backtester, normalized to
+// daily dates (YYYY-MM-DD), a daily return series 1:1 with them, and
+// optionally the fills. Users migrating a strategy compare it against the
+// equivalent Result with CompareExternal.
+type ExternalResult struct {
+	Source  string
+	Dates   []string
+	Returns []float64 // simple return vs. the previous date; 0 on the first
+	Trades  []Trade
+}
+
+// LoadExternal reads an export in the named format:
+//   - "backtrader", "zipline", "csv": a CSV with a date column (date,
+//     datetime, dt, or an unnamed first column, as written by pandas
+//     to_csv) and either an equity column (portfolio_value, equity,
+//     value) or a returns column (returns, return). Backtrader's
+//     TimeReturn/pyfolio output and zipline's perf DataFrame both fit.
+//   - "quantconnect": a backtest result JSON; the "Strategy Equity" chart
+//     and the orders map are used.
+//
+// Intraday rows are collapsed to the last value of each day.
+func LoadExternal(path, format string) (*ExternalResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ext *ExternalResult
+	switch format {
+	case "backtrader", "zipline", "csv":
+		ext, err = readExternalCSV(f)
+	case "quantconnect":
+		ext, err = readQuantConnect(f)
+	default:
+		return nil, fmt.Errorf("external format %q: must be backtrader, zipline, quantconnect, or csv", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s export %q: %w", format, path, err)
+	}
+	ext.Source = format
+	return ext, nil
+}
+
+func readExternalCSV(r io.Reader) (*ExternalResult, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("no data rows")
+	}
+	dateCol, equityCol, returnCol := -1, -1, -1
+	for i, h := range rows[0] {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "date", "datetime", "dt", "time":
+			dateCol = i
+		case "portfolio_value", "equity", "value":
+			equityCol = i
+		case "returns", "return":
+			returnCol = i
+		case "":
+			if i == 0 {
+				dateCol = 0
+			}
+		}
+	}
+	if dateCol < 0 {
+		return nil, fmt.Errorf("no date column")
+	}
+	if equityCol < 0 && returnCol < 0 {
+		return nil, fmt.Errorf("need an equity (portfolio_value) or returns column")
+	}
+	col := equityCol
+	if col < 0 {
+		col = returnCol
+	}
+
+	var dates []string
+	var vals []float64
+	for i, row := range rows[1:] {
+		d, err := parseTradeTime(strings.TrimSpace(row[dateCol]))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+2, err)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[col]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+2, err)
+		}
+		dates, vals = appendDaily(dates, vals, d.Format("2006-01-02"), v)
+	}
+	if equityCol >= 0 {
+		return &ExternalResult{Dates: dates, Returns: returnsFromEquity(vals)}, nil
+	}
+	return &ExternalResult{Dates: dates, Returns: vals}, nil
+}
+
+// appendDaily appends (day, v), replacing the previous value when day
+// repeats so intraday exports keep each day's last observation.
+func appendDaily(dates []string, vals []float64, day string, v float64) ([]string, []float64) {
+	if n := len(dates); n > 0 && dates[n-1] == day {
+		vals[n-1] = v
+		return dates, vals
+	}
+	return append(dates, day), append(vals, v)
+}
+
+func returnsFromEquity(equity []float64) []float64 {
+	rets := make([]float64, len(equity))
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1] != 0 {
+			rets[i] = equity[i]/equity[i-1] - 1
+		}
+	}
+	return rets
+}
+
+// qcPoint accepts both chart point encodings QuantConnect has used:
+// {"x": unix, "y": value} and [unix, open, high, low, close].
+type qcPoint struct {
+	X int64
+	Y float64
+}
+
+func (p *qcPoint) UnmarshalJSON(b []byte) error {
+	var obj struct {
+		X int64   `json:"x"`
+		Y float64 `json:"y"`
+	}
+	if err := json.Unmarshal(b, &obj); err == nil {
+		p.X, p.Y = obj.X, obj.Y
+		return nil
+	}
+	var arr []float64
+	if err := json.Unmarshal(b, &arr); err != nil || len(arr) < 2 {
+		return fmt.Errorf("chart point %s", b)
+	}
+	p.X, p.Y = int64(arr[0]), arr[len(arr)-1]
+	return nil
+}
+
+func readQuantConnect(r io.Reader) (*ExternalResult, error) {
+	var raw struct {
+		Charts map[string]struct {
+			Series map[string]struct {
+				Values []qcPoint `json:"values"`
+			} `json:"series"`
+		} `json:"charts"`
+		Orders map[string]struct {
+			Symbol struct {
+				Value string `json:"value"`
+			} `json:"symbol"`
+			Quantity float64   `json:"quantity"`
+			Price    float64   `json:"price"`
+			Time     time.Time `json:"time"`
+			Status   int       `json:"status"`
+		} `json:"orders"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	points := raw.Charts["Strategy Equity"].Series["Equity"].Values
+	if len(points) == 0 {
+		return nil, fmt.Errorf(`no "Strategy Equity" chart`)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].X < points[j].X })
+	var dates []string
+	var equity []float64
+	for _, pt := range points {
+		day := time.Unix(pt.X, 0).UTC().Format("2006-01-02")
+		dates, equity = appendDaily(dates, equity, day, pt.Y)
+	}
+	ext := &ExternalResult{Dates: dates, Returns: returnsFromEquity(equity)}
+	// Orders are keyed by numeric id; visit them in id order so trades
+	// sharing a timestamp keep a stable order.
+	ids := make([]string, 0, len(raw.Orders))
+	for id := range raw.Orders {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		o := raw.Orders[id]
+		// Status 3 is Filled; other orders never traded.
+		if o.Status != 3 || o.Quantity == 0 {
+			continue
+		}
+		t := Trade{Date: o.Time, Ticker: o.Symbol.Value, Side: "BUY", Amount: o.Quantity, Price: o.Price}
+		if o.Quantity < 0 {
+			t.Side, t.Amount = "SELL", -o.Quantity
+		}
+		ext.Trades = append(ext.Trades, t)
+	}
+	sort.SliceStable(ext.Trades, func(i, j int) bool {
+		return ext.Trades[i].Date.Before(ext.Trades[j].Date)
+	})
+	return ext, nil
+}
+
+// SideMetrics are the headline numbers computed identically for both
+// runs over their common dates, so differences come from the simulations
+// rather than from metric definitions (risk-free rate is taken as 0).
+type SideMetrics struct {
+	TotalReturn  float64 // percent
+	AnnualReturn float64 // percent, CAGR over DefaultPeriodsPerYear bars a year
+	SharpeRatio  float64 // annualized, rf = 0
+	MaxDrawdown  float64 // percent
+	Trades       int
+}
+
+// Comparison summarizes how closely an external run matches ours.
+type Comparison struct {
+	Portfolio, Source string
+	CommonDays        int
+	OnlyOurs          int // days present only in our run
+	OnlyExternal      int // days present only in the export
+	Ours, External    SideMetrics
+
+	ReturnCorrelation float64 // of daily returns
+	TrackingError     float64 // annualized stdev of the daily return difference
+	MaxDailyDiff      float64 // largest |ours - external| daily return
+	MaxDailyDiffDate  string
+
+	// MatchedTrades counts external fills with an identical date, ticker,
+	// side and quantity in ours; only set when the export has trades.
+	MatchedTrades int
+}
+
+// CompareExternal aligns ours and ext on their common dates and compares
+// their daily returns, headline metrics and fills.
+func CompareExternal(ours Result, ext *ExternalResult) Comparison {
+	c := Comparison{Portfolio: ours.PortfolioName, Source: ext.Source}
+	ourRets := returnsFromEquity(ours.EquityCurve)
+	extByDate := make(map[string]float64, len(ext.Dates))
+	for i, d := range ext.Dates {
+		extByDate[d] = ext.Returns[i]
+	}
+
+	var a, b []float64
+	seen := make(map[string]bool, len(ours.Dates))
+	for i, d := range ours.Dates {
+		seen[d] = true
+		r, ok := extByDate[d]
+		if !ok {
+			c.OnlyOurs++
+			continue
+		}
+		// The first common day has no comparable prior close on both
+		// sides, so only its presence is counted.
+		if c.CommonDays > 0 {
+			a = append(a, ourRets[i])
+			b = append(b, r)
+			if diff := math.Abs(ourRets[i] - r); diff > c.MaxDailyDiff {
+				c.MaxDailyDiff, c.MaxDailyDiffDate = diff, d
+			}
+		}
+		c.CommonDays++
+	}
+	for _, d := range ext.Dates {
+		if !seen[d] {
+			c.OnlyExternal++
+		}
+	}
+
+	c.Ours = sideMetrics(a)
+	c.Ours.Trades = len(ours.Trades)
+	c.External = sideMetrics(b)
+	c.External.Trades = len(ext.Trades)
+	if len(a) > 1 {
+		c.ReturnCorrelation = stat.Correlation(a, b, nil)
+		diff := make([]float64, len(a))
+		for i := range a {
+			diff[i] = a[i] - b[i]
+		}
+		c.TrackingError = stat.StdDev(diff, nil) * math.Sqrt(DefaultPeriodsPerYear)
+	}
+
+	type key struct {
+		date, ticker, side string
+		amount             float64
+	}
+	ourFills := make(map[key]int, len(ours.Trades))
+	for _, t := range ours.Trades {
+		ourFills[key{t.Date.Format("2006-01-02"), t.Ticker, t.Side, t.Amount}]++
+	}
+	for _, t := range ext.Trades {
+		k := key{t.Date.Format("2006-01-02"), t.Ticker, t.Side, t.Amount}
+		if ourFills[k] > 0 {
+			ourFills[k]--
+			c.MatchedTrades++
+		}
+	}
+	return c
+}
+
+func sideMetrics(rets []float64) SideMetrics {
+	var m SideMetrics
+	if len(rets) == 0 {
+		return m
+	}
+	equity := make([]float64, len(rets)+1)
+	equity[0] = 1
+	for i, r := range rets {
+		equity[i+1] = equity[i] * (1 + r)
+	}
+	m.TotalReturn = (equity[len(equity)-1] - 1) * 100
+	m.AnnualReturn = GetAnnualReturn(rets)
+	m.MaxDrawdown = GetMaxDrawdown(equity)
+	if sd := stat.StdDev(rets, nil); sd > 0 {
+		m.SharpeRatio = stat.Mean(rets, nil) / sd * math.Sqrt(DefaultPeriodsPerYear)
+	}
+	return m
+}
+
+// WriteComparison renders c as a plain-text report.
+func WriteComparison(w io.Writer, c Comparison) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Portfolio %s vs %s export\n", c.Portfolio, c.Source)
+	fmt.Fprintf(&b, "Days: %d common, %d only ours, %d only external\n",
+		c.CommonDays, c.OnlyOurs, c.OnlyExternal)
+	fmt.Fprintf(&b, "%-14s %12s %12s %12s\n", "", "ours", "external", "diff")
+	row := func(name string, x, y float64) {
+		fmt.Fprintf(&b, "%-14s %12.4f %12.4f %12.4f\n", name, x, y, x-y)
+	}
+	row("TotalReturn", c.Ours.TotalReturn, c.External.TotalReturn)
+	row("AnnualReturn", c.Ours.AnnualReturn, c.External.AnnualReturn)
+	row("SharpeRatio", c.Ours.SharpeRatio, c.External.SharpeRatio)
+	row("MaxDrawdown", c.Ours.MaxDrawdown, c.External.MaxDrawdown)
+	fmt.Fprintf(&b, "%-14s %12d %12d\n", "Trades", c.Ours.Trades, c.External.Trades)
+	fmt.Fprintf(&b, "Daily returns: correlation %.6f, tracking error %.4f%%, max diff %.6f on %s\n",
+		c.ReturnCorrelation, c.TrackingError*100, c.MaxDailyDiff, c.MaxDailyDiffDate)
+	if c.External.Trades > 0 {
+		fmt.Fprintf(&b, "Fills matched: %d of %d\n", c.MatchedTrades, c.External.Trades)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,99 @@
+package backtest
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTemp(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExternal_ZiplineEquity(t *testing.T) {
+	path := writeTemp(t, "perf.csv",
+		",portfolio_value,returns\n"+
+			"2024-01-02 21:00:00+00:00,100,0\n"+
+			"2024-01-03 21:00:00+00:00,110,0.1\n"+
+			"2024-01-04 21:00:00+00:00,99,-0.1\n")
+	ext, err := LoadExternal(path, "zipline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{0, 0.1, -0.1}
+	if strings.Join(ext.Dates, ",") != "2024-01-02,2024-01-03,2024-01-04" {
+		t.Errorf("dates = %v", ext.Dates)
+	}
+	for i := range want {
+		if math.Abs(ext.Returns[i]-want[i]) > 1e-12 {
+			t.Errorf("returns = %v, want %v", ext.Returns, want)
+			break
+		}
+	}
+}
+
+func TestLoadExternal_QuantConnect(t *testing.T) {
+	d1 := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC).Unix()
+	d2 := d1 + 86400
+	path := writeTemp(t, "qc.json", `{
+  "charts": {"Strategy Equity": {"series": {"Equity": {"values": [
+    [`+strconv.FormatInt(d2, 10)+`, 100, 120, 90, 110], [`+strconv.FormatInt(d1, 10)+`, 100, 100, 100, 100]]}}}},
+  "orders": {
+    "1": {"symbol": {"value": "SPY"}, "quantity": 2, "price": 50, "time": "2024-01-02T15:00:00Z", "status": 3},
+    "2": {"symbol": {"value": "SPY"}, "quantity": -2, "price": 55, "time": "2024-01-03T15:00:00Z", "status": 5}
+  }}`)
+	ext, err := LoadExternal(path, "quantconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ext.Dates) != 2 || math.Abs(ext.Returns[1]-0.1) > 1e-12 {
+		t.Errorf("dates %v returns %v", ext.Dates, ext.Returns)
+	}
+	if len(ext.Trades) != 1 || ext.Trades[0].Side != "BUY" || ext.Trades[0].Amount != 2 {
+		t.Errorf("trades = %+v, want the one filled buy", ext.Trades)
+	}
+}
+
+func TestCompareExternal_IdenticalRuns(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	ours := Result{
+		PortfolioName: "P",
+		Dates:         []string{"2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"},
+		EquityCurve:   []float64{1000, 1010, 990, 1020},
+		Trades:        []Trade{{Date: day(2), Ticker: "SPY", Side: "BUY", Amount: 2, Price: 50}},
+	}
+	ext := &ExternalResult{
+		Source:  "csv",
+		Dates:   []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"},
+		Returns: []float64{0, 0, 0.01, 990.0/1010 - 1, 1020.0/990 - 1},
+		Trades:  []Trade{{Date: day(2).Add(15 * time.Hour), Ticker: "SPY", Side: "BUY", Amount: 2, Price: 50.1}},
+	}
+	c := CompareExternal(ours, ext)
+	if c.CommonDays != 4 || c.OnlyOurs != 0 || c.OnlyExternal != 1 {
+		t.Errorf("days = %d/%d/%d", c.CommonDays, c.OnlyOurs, c.OnlyExternal)
+	}
+	if c.MaxDailyDiff > 1e-12 || math.Abs(c.ReturnCorrelation-1) > 1e-9 {
+		t.Errorf("max diff %v corr %v", c.MaxDailyDiff, c.ReturnCorrelation)
+	}
+	if math.Abs(c.Ours.TotalReturn-2) > 1e-9 ||
+		math.Abs(c.Ours.SharpeRatio-c.External.SharpeRatio) > 1e-9 ||
+		math.Abs(c.Ours.MaxDrawdown-c.External.MaxDrawdown) > 1e-9 {
+		t.Errorf("ours %+v external %+v", c.Ours, c.External)
+	}
+	if c.MatchedTrades != 1 {
+		t.Errorf("matched = %d", c.MatchedTrades)
+	}
+	var b strings.Builder
+	if err := WriteComparison(&b, c); err != nil || !strings.Contains(b.String(), "Fills matched: 1 of 1") {
+		t.Errorf("report:\n%s", b.String())
+	}
+}
@@ -0,0 +1,59 @@
+package backtest
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareStrategies(t *testing.T) {
+	results := []Result{
+		{PortfolioName: "up", Dates: []string{"d1", "d2", "d3", "d4"}, EquityCurve: []float64{1000, 1100, 1210, 1331},
+			Trades: []Trade{{Ticker: "A"}}},
+		{PortfolioName: "same", Dates: []string{"d1", "d2", "d3", "d4"}, EquityCurve: []float64{500, 550, 605, 665.5}},
+		// Missing d3: compared over d1, d2 and d4 only.
+		{PortfolioName: "mixed", Dates: []string{"d1", "d2", "d4"}, EquityCurve: []float64{100, 90, 99}},
+	}
+	c := CompareStrategies(results)
+	if !reflect.DeepEqual(c.Dates, []string{"d1", "d2", "d4"}) {
+		t.Fatalf("dates = %v, want those all three share", c.Dates)
+	}
+	if c.Equity[1][0] != 100 || !closeTo(c.Equity[0][2], 133.1) {
+		t.Errorf("equity = %v, want each scaled to start at 100", c.Equity)
+	}
+	if m := c.Metrics[0]; !closeTo(m.TotalReturn, 33.1) || m.Trades != 1 {
+		t.Errorf("up's metrics = %+v", m)
+	}
+	if len(c.Pairs) != 3 || c.Pairs[0].A != "up" || c.Pairs[0].B != "same" {
+		t.Fatalf("pairs = %+v", c.Pairs)
+	}
+	// Identical returns: perfectly correlated, no tracking error and
+	// neither ahead on any day.
+	if p := c.Pairs[0]; !closeTo(p.Correlation, 1) || !closeTo(p.TrackingError, 0) || p.AWins != 0 {
+		t.Errorf("up vs same = %+v", p)
+	}
+	if c.Correlation[0][1] != c.Correlation[1][0] || c.Correlation[2][2] != 1 {
+		t.Errorf("correlation matrix = %v", c.Correlation)
+	}
+	if p := c.Pairs[1]; p.AWins != 100 {
+		t.Errorf("up vs mixed = %+v, want up ahead on both days", p)
+	}
+
+	var out bytes.Buffer
+	if err := WriteStrategyComparison(&out, c); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"total %", "correlation (3 days)", "up vs mixed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, out.String())
+		}
+	}
+	out.Reset()
+	if err := WriteComparisonEquityCSV(&out, c); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 4 || lines[0] != "date,up,same,mixed" {
+		t.Errorf("csv = %q", out.String())
+	}
+}
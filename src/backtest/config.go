@@ -1,26 +1,135 @@
 package backtest
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"my-backtester/src/data"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Portfolios []PortfolioConfig `toml:"portfolio"`
-	Output     *OutputConfig     `toml:"Output"`
+	// SchemaVersion is the config format version (ConfigSchemaVersion
+	// when current). Files that omit it are read as the first version.
+	SchemaVersion int               `toml:"SchemaVersion"`
+	Portfolios    []PortfolioConfig `toml:"portfolio"`
+	Output        *OutputConfig     `toml:"Output"`
+	Database      *DatabaseConfig   `toml:"Database"`
+	Paper         *PaperConfig      `toml:"Paper"`
+	Webhook       *WebhookConfig    `toml:"Webhook"`
+	// WalkForward configures `walkforward` runs (see RunWalkForward).
+	WalkForward *WalkForwardConfig `toml:"WalkForward"`
+	// Optimize configures `optimize` runs (see RunOptimize).
+	Optimize *OptimizeConfig `toml:"Optimize"`
+	// CrossValidation configures `crossvalidate` runs (see
+	// RunCrossValidate).
+	CrossValidation *CrossValidationConfig `toml:"CrossValidation"`
+	// Baselines tunes the reference portfolios `run` compares the
+	// results with (see RunBaselines).
+	Baselines *BaselineConfig `toml:"Baselines"`
+	// Seed, when non-zero, seeds every portfolio that sets no Seed of
+	// its own (see ApplySeed).
+	Seed int64 `toml:"Seed"`
+}
+
+// PaperConfig controls paper-trading mode (see RunPaper). All fields are
+// optional.
+type PaperConfig struct {
+	Source       string `toml:"source"`        // "yahoo" (default), "binance", or "replay" (DB history)
+	PollInterval string `toml:"poll_interval"` // time between quote polls, e.g. "1m" (default)
+	WarmupDays   int    `toml:"warmup_days"`   // calendar days of DB history preloaded for indicators
+	ReplayDelay  string `toml:"replay_delay"`  // with source "replay": wait between bars, e.g. "1s"; empty replays at full speed
+
+	// Broker mirrors the simulated trades to a real (paper or live)
+	// account. An absent [Paper.Broker] block keeps trading simulated.
+	Broker *BrokerConfig `toml:"Broker"`
+}
+
+// DatabaseConfig tunes the DuckDB connection. All fields are optional; an
+// absent [Database] block keeps DuckDB's defaults.
+type DatabaseConfig struct {
+	// Provider is where bars and risk-free rates come from: "duckdb"
+	// (default), or "csv" for a directory of CSV files at Path (see
+	// data.CSVDir), which the DuckDB settings below don't apply to.
+	Provider      string `toml:"provider"`
+	Path          string `toml:"path"` // DuckDB file; the CLI defaults to ../stock_data.db
+	Threads       int    `toml:"threads"`
+	MemoryLimit   string `toml:"memory_limit"`   // e.g. "4GB"
+	TempDirectory string `toml:"temp_directory"` // where DuckDB spills to disk
+	MaxOpenConns  int    `toml:"max_open_conns"` // 0 means unlimited
+	// RiskFreeSeries, when set, is a FRED series (e.g. "DTB3") that runs
+	// download into 3MTreasuryYields before they start (see
+	// data.Store.RefreshRiskFree).
+	RiskFreeSeries string `toml:"risk_free_series"`
+	// CacheMB, when positive, keeps up to that many megabytes of bars in
+	// SharedBarCache between RunFromConfigText calls.
+	CacheMB int `toml:"cache_mb"`
+}
+
+// Options converts the block into data.Options. Safe on a nil receiver.
+func (dc *DatabaseConfig) Options() data.Options {
+	if dc == nil {
+		return data.Options{}
+	}
+	return data.Options{
+		Threads:       dc.Threads,
+		MemoryLimit:   dc.MemoryLimit,
+		TempDirectory: dc.TempDirectory,
+		MaxOpenConns:  dc.MaxOpenConns,
+	}
+}
+
+// OpenStore opens the provider the block names at path; release closes
+// it. Safe on a nil receiver, which opens path as DuckDB.
+func (dc *DatabaseConfig) OpenStore(path string) (store Store, release func() error, err error) {
+	provider := ""
+	if dc != nil {
+		provider = strings.ToLower(dc.Provider)
+	}
+	switch provider {
+	case "", "duckdb":
+		db, err := data.OpenWithOptions(path, dc.Options())
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Close, nil
+	case "csv":
+		if st, err := os.Stat(path); err != nil || !st.IsDir() {
+			return nil, nil, fmt.Errorf("csv provider: %q is not a directory", path)
+		}
+		return ProviderStore(data.NewCSVDir(path, data.ImportOptions{})), func() error { return nil }, nil
+	}
+	return nil, nil, fmt.Errorf("database provider %q: must be duckdb or csv", dc.Provider)
 }
 
 // OutputConfig controls how backtest Results are persisted.
 // All fields are optional; an absent [Output] block disables file output.
 type OutputConfig struct {
 	Path   string   `toml:"path"`
-	Format string   `toml:"format"` // "txt" (default), "csv", "json"
-	Fields []string `toml:"fields"` // result fields to emit, in order
-	Filter string   `toml:"filter"` // Go-style expression, e.g. "SharpeRatio > 0.5 && AnnualReturn > 5"
+	Format string   `toml:"format"`  // "txt" (default), "csv", "json", "ndjson"
+	Fields []string `toml:"fields"`  // result fields to emit, in order
+	Filter string   `toml:"filter"`  // Go-style expression, e.g. "SharpeRatio > 0.5 && AnnualReturn > 5"
 	SortBy string   `toml:"sort_by"` // result field to sort by; empty disables sorting
 	Order  string   `toml:"order"`   // "asc" or "desc" (default "desc")
 	Limit  int      `toml:"limit"`   // emit at most N results; 0 means unlimited
+
+	// Results are batched in memory and written out once BatchSize have
+	// accumulated, every FlushInterval (e.g. "5s"), and at shutdown.
+	BatchSize     int    `toml:"batch_size"`     // 0 means DefaultBatchSize
+	FlushInterval string `toml:"flush_interval"` // empty disables timed flushes
+	Fsync         bool   `toml:"fsync"`          // fsync the file after every flush
+
+	// Dir, when set, receives every run's equity curve and trade blotter
+	// as CSV files, unfiltered (see WriteArtifacts).
+	Dir string `toml:"dir"`
+	// Database, when true, also appends every result, unfiltered, to
+	// the results table of the run's DuckDB database.
+	Database bool `toml:"database"`
 }
 
 type PortfolioConfig struct {
@@ -31,17 +140,268 @@ type PortfolioConfig struct {
 	Tickers     []string       `toml:"Tickers"`
 	Strategy    string         `toml:"Strategy"`
 	Params      map[string]any `toml:"Params"`
+	FillPrice   string         `toml:"FillPrice"`  // "close" (default), "next_open", "typical", "vwap"
+	FillWindow  int            `toml:"FillWindow"` // bars averaged by "vwap"; default 1
+	Accounting  string         `toml:"Accounting"` // "float" (default) or "cents"
+	LotMethod   string         `toml:"LotMethod"`  // "average" (default), "fifo" or "lifo"
+	// AuditLookahead fails the portfolio if its strategy reads a bar
+	// later than the one being processed, or fills an order at that
+	// bar's own prices (also set by -audit-lookahead).
+	AuditLookahead bool `toml:"AuditLookahead"`
+	// AllowShort lets sells go past the shares held into short positions.
+	AllowShort bool `toml:"AllowShort"`
+
+	Commission  float64 `toml:"Commission"`  // flat fee per fill, in dollars
+	SlippageBps float64 `toml:"SlippageBps"` // basis points each fill moves against the trade
+	// CommissionPerShare is charged per share, at least CommissionMin a
+	// fill; VolumeImpact moves a fill by that fraction times its share of
+	// the bar's volume (see PerShareFee, VolumeSlippage).
+	CommissionPerShare float64 `toml:"CommissionPerShare"`
+	CommissionMin      float64 `toml:"CommissionMin"`
+	VolumeImpact       float64 `toml:"VolumeImpact"`
+	Calendar           string  `toml:"Calendar"`  // "equities" (default, 252 days/yr), "crypto" (365) or "nyse"
+	Benchmark          string  `toml:"Benchmark"` // ticker whose buy-and-hold return is reported
+	Timeframe          string  `toml:"Timeframe"` // "daily" (default), "weekly" or "monthly" bars
+	Interval           string  `toml:"Interval"`  // intraday bar length such as "5m" or "1h"; default daily
+	// DataGaps is "skip", "forward_fill" or "abort" (see DataGaps);
+	// DelistingHaircut is the fraction of a delisted position's last close
+	// lost closing it under forward_fill.
+	DataGaps         string  `toml:"DataGaps"`
+	DelistingHaircut float64 `toml:"DelistingHaircut"`
+	// VaRConfidence lists the confidence levels VaR and CVaR are
+	// reported at; default [0.95].
+	VaRConfidence []float64 `toml:"VaRConfidence"`
+	// RollingWindows are the windows, in bars, of the rolling Sharpe,
+	// volatility and drawdown series; default [63, 252].
+	RollingWindows []int `toml:"RollingWindows"`
+	// MonteCarlo, a [portfolio.MonteCarlo] block, resamples the finished
+	// run into percentile bands of its metrics (see WithMonteCarlo).
+	MonteCarlo *MonteCarloConfig `toml:"MonteCarlo"`
+	// Significance, a [portfolio.Significance] block, adds bootstrap
+	// confidence intervals to the metrics and sets the trials the
+	// deflated Sharpe ratio allows for (see WithSignificance).
+	Significance *SignificanceConfig `toml:"Significance"`
+	// RandomEntry, a [portfolio.RandomEntry] block, measures the run's
+	// Sharpe ratio against runs with random entries and exits on the
+	// same tickers (see WithRandomEntry).
+	RandomEntry *RandomEntryConfig `toml:"RandomEntry"`
+	// Contributions, a [portfolio.Contributions] block, deposits or
+	// withdraws cash on a schedule (see WithContributions).
+	Contributions *ContributionSchedule `toml:"Contributions"`
+	// Risk, a [portfolio.Risk] block, sets stop-losses, take-profits and
+	// a drawdown kill switch (see WithRisk).
+	Risk *RiskConfig `toml:"Risk"`
+	// Margin, a [portfolio.Margin] block, lets buys borrow up to a
+	// leverage limit at the risk-free rate plus a spread (see WithMargin).
+	Margin *MarginConfig `toml:"Margin"`
+	// Tax, a [portfolio.Tax] block, charges capital-gains tax on
+	// realized gains (see WithTax).
+	Tax *TaxConfig `toml:"Tax"`
+	// Universe, a [portfolio.Universe] block, selects the tickers from
+	// the database by coverage, dollar volume, price, sector and list
+	// file (see WithUniverse).
+	Universe *UniverseConfig `toml:"Universe"`
+	// SettlementDays holds sale proceeds back from buys until they
+	// settle, e.g. 2 for T+2 (see WithSettlement).
+	SettlementDays int `toml:"SettlementDays"`
+	// Prices is "raw" (default), paying dividends and applying splits as
+	// they happen, or "adjusted", trading back-adjusted prices.
+	Prices   string `toml:"Prices"`
+	Seed     int64  `toml:"Seed"`     // seeds the strategy's random source
+	RiskFree string `toml:"RiskFree"` // "db" (default, 3MTreasuryYields), "zero", or a daily rate
+	// Sleeves, [[portfolio.Sleeves]] blocks, split the capital among
+	// several strategies by weight in place of Strategy (see
+	// WithSleeves).
+	Sleeves []Sleeve `toml:"Sleeves"`
+}
+
+// Environment variables layered over the config file by ApplyEnv, so
+// containers and schedulers can repoint a run without editing it. API
+// keys are read from each provider's own variables (APCA_API_KEY_ID,
+// BINANCE_API_KEY, FRED_API_KEY, ...) when the config leaves them empty.
+const (
+	EnvConfigPath = "BACKTESTER_CONFIG"
+	EnvDBPath     = "BACKTESTER_DB_PATH"
+	EnvOutputPath = "BACKTESTER_OUTPUT_PATH"
+	EnvWebhookURL = "BACKTESTER_WEBHOOK_URL"
+)
+
+// ApplyEnv overrides config values with any of the BACKTESTER_*
+// variables that getenv reports as set, creating the enclosing block
+// when the file omitted it.
+func (c *Config) ApplyEnv(getenv func(string) string) {
+	if v := getenv(EnvDBPath); v != "" {
+		if c.Database == nil {
+			c.Database = &DatabaseConfig{}
+		}
+		c.Database.Path = v
+	}
+	if v := getenv(EnvOutputPath); v != "" {
+		if c.Output == nil {
+			c.Output = &OutputConfig{}
+		}
+		c.Output.Path = v
+	}
+	if v := getenv(EnvWebhookURL); v != "" {
+		if c.Webhook == nil {
+			c.Webhook = &WebhookConfig{}
+		}
+		c.Webhook.URL = Secret(v)
+	}
 }
 
+// ApplySeed gives every portfolio without a Seed of its own the config's
+// Seed plus its index, so one number reproduces the whole run while the
+// portfolios still draw different streams. Seeds follow config order, not
+// which worker runs a portfolio, so results don't depend on scheduling.
+// A zero Seed leaves the portfolios alone.
+func (c *Config) ApplySeed() {
+	if c.Seed == 0 {
+		return
+	}
+	for i := range c.Portfolios {
+		if c.Portfolios[i].Seed == 0 {
+			c.Portfolios[i].Seed = c.Seed + int64(i)
+		}
+	}
+}
+
+// LoadConfig reads a config file. Files ending in .json are parsed as
+// JSON (see ParseConfig); anything else as TOML.
 func LoadConfig(filepath string) (*Config, error) {
-	var config Config
-	_, err := toml.DecodeFile(filepath, &config)
+	b, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
+	format := "toml"
+	switch lower := strings.ToLower(filepath); {
+	case strings.HasSuffix(lower, ".json"):
+		format = "json"
+	case strings.HasSuffix(lower, ".yaml"), strings.HasSuffix(lower, ".yml"):
+		format = "yaml"
+	}
+	return ParseConfig(string(b), format)
+}
+
+// ParseConfig decodes a config from text. format is "toml", "json",
+// "yaml", or "" to detect JSON by a leading '{'. JSON and YAML configs use
+// exactly the TOML key names and nesting, e.g. {"portfolio": [{"Name":
+// "A", ...}], "Output": {"path": "..."}}. Configs of an earlier
+// SchemaVersion are migrated to the current one.
+func ParseConfig(text, format string) (*Config, error) {
+	if format == "" {
+		format = "toml"
+		if strings.HasPrefix(strings.TrimSpace(text), "{") {
+			format = "json"
+		}
+	}
+	switch format {
+	case "toml":
+	case "json":
+		var err error
+		if text, err = jsonToTOML(text); err != nil {
+			return nil, fmt.Errorf("parse json: %w", err)
+		}
+	case "yaml":
+		var err error
+		if text, err = yamlToTOML(text); err != nil {
+			return nil, fmt.Errorf("parse yaml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("config format %q: must be toml, json or yaml", format)
+	}
+	var raw map[string]any
+	if _, err := toml.Decode(text, &raw); err != nil {
+		return nil, fmt.Errorf("parse toml: %w", err)
+	}
+	if v, _ := schemaVersion(raw["SchemaVersion"]); v != ConfigSchemaVersion {
+		if err := migrate(raw, "SchemaVersion", ConfigSchemaVersion, configMigrations); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		text = buf.String()
+	}
+	var config Config
+	if _, err := toml.Decode(text, &config); err != nil {
+		return nil, fmt.Errorf("parse toml: %w", err)
+	}
 	return &config, nil
 }
 
+// jsonToTOML re-encodes a JSON document as TOML so both formats share one
+// set of struct tags. Integral JSON numbers stay integers, matching what
+// the TOML decoder would produce for the same config (e.g. Lua Params).
+func jsonToTOML(text string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(fromJSONNumbers(doc)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// yamlToTOML re-encodes a YAML document as TOML, like jsonToTOML. An
+// unquoted date such as StartDate: 2020-01-01 stays the string the
+// config expects rather than becoming a timestamp.
+func yamlToTOML(text string) (string, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(fromYAML(doc)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func fromYAML(v any) any {
+	switch x := v.(type) {
+	case time.Time:
+		if x.Equal(x.Truncate(24 * time.Hour)) {
+			return x.Format("2006-01-02")
+		}
+		return x.Format(time.RFC3339)
+	case map[string]any:
+		for k, e := range x {
+			x[k] = fromYAML(e)
+		}
+	case []any:
+		for i, e := range x {
+			x[i] = fromYAML(e)
+		}
+	}
+	return v
+}
+
+func fromJSONNumbers(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]any:
+		for k, e := range x {
+			x[k] = fromJSONNumbers(e)
+		}
+	case []any:
+		for i, e := range x {
+			x[i] = fromJSONNumbers(e)
+		}
+	}
+	return v
+}
+
 func (pc *PortfolioConfig) ToPortfolio() (*Portfolio, error) {
 	startTime, err := time.Parse("2006-01-02", pc.StartTime)
 	if err != nil {
@@ -53,13 +413,103 @@ func (pc *PortfolioConfig) ToPortfolio() (*Portfolio, error) {
 		return nil, err
 	}
 
-	return InitializePortfolio(
-		pc.BuyingPower,
-		startTime,
-		endTime,
-		pc.Name,
-		pc.Tickers,
-		pc.Strategy,
-		pc.Params,
-	)
+	fill, err := ParseFillModel(pc.FillPrice)
+	if err != nil {
+		return nil, err
+	}
+	accounting, err := ParseAccounting(pc.Accounting)
+	if err != nil {
+		return nil, err
+	}
+	lots, err := ParseLotMethod(pc.LotMethod)
+	if err != nil {
+		return nil, err
+	}
+	calendar, err := ParseCalendar(pc.Calendar)
+	if err != nil {
+		return nil, err
+	}
+	timeframe, err := ParseTimeframe(pc.Timeframe)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := data.ParseInterval(pc.Interval)
+	if err != nil {
+		return nil, err
+	}
+	gaps, err := ParseDataGaps(pc.DataGaps)
+	if err != nil {
+		return nil, err
+	}
+	riskFree, err := ParseRiskFree(pc.RiskFree)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := ParsePriceMode(pc.Prices)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []Option{
+		WithWindow(startTime, endTime),
+		WithParams(pc.Params),
+		WithFill(fill, pc.FillWindow),
+		WithAccounting(accounting),
+		WithLotMethod(lots),
+		WithCommission(pc.Commission),
+		WithSlippage(pc.SlippageBps),
+		WithCalendar(calendar),
+		WithTimeframe(timeframe),
+		WithInterval(interval),
+		WithDataGaps(gaps, pc.DelistingHaircut),
+		WithBenchmark(pc.Benchmark),
+		WithVaRLevels(pc.VaRConfidence...),
+		WithRollingWindows(pc.RollingWindows...),
+		WithSeed(pc.Seed),
+		WithRiskFree(riskFree),
+		WithPrices(prices),
+	}
+	if pc.CommissionPerShare != 0 || pc.CommissionMin != 0 {
+		opts = append(opts, WithCosts(PerShareFee{PerShare: pc.CommissionPerShare, Min: pc.CommissionMin}))
+	}
+	if pc.VolumeImpact != 0 {
+		opts = append(opts, WithCosts(VolumeSlippage{Impact: pc.VolumeImpact}))
+	}
+	if pc.AuditLookahead {
+		opts = append(opts, WithAuditLookahead())
+	}
+	if pc.AllowShort {
+		opts = append(opts, WithShorting())
+	}
+	if pc.MonteCarlo != nil {
+		opts = append(opts, WithMonteCarlo(*pc.MonteCarlo))
+	}
+	if pc.Significance != nil {
+		opts = append(opts, WithSignificance(*pc.Significance))
+	}
+	if pc.RandomEntry != nil {
+		opts = append(opts, WithRandomEntry(*pc.RandomEntry))
+	}
+	if pc.Contributions != nil {
+		opts = append(opts, WithContributions(*pc.Contributions))
+	}
+	if pc.Risk != nil {
+		opts = append(opts, WithRisk(*pc.Risk))
+	}
+	if pc.Margin != nil {
+		opts = append(opts, WithMargin(*pc.Margin))
+	}
+	if pc.Tax != nil {
+		opts = append(opts, WithTax(*pc.Tax))
+	}
+	if pc.Universe != nil {
+		opts = append(opts, WithUniverse(*pc.Universe))
+	}
+	if pc.SettlementDays != 0 {
+		opts = append(opts, WithSettlement(pc.SettlementDays))
+	}
+	if len(pc.Sleeves) > 0 {
+		opts = append(opts, WithSleeves(pc.Sleeves...))
+	}
+	return NewPortfolio(pc.Name, pc.BuyingPower, pc.Tickers, pc.Strategy, opts...)
 }
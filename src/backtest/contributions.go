@@ -0,0 +1,111 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/calendar"
+	"time"
+)
+
+// ContributionSchedule adds Amount dollars of outside cash to the
+// portfolio on the first bar of every period after the first, e.g. $500
+// on the first trading day of each month. A negative Amount withdraws,
+// never more than the cash on hand.
+type ContributionSchedule struct {
+	Amount float64 `toml:"amount"`
+	Every  string  `toml:"every"` // "week", "month" (default), "quarter" or "year"
+}
+
+// CashFlow is outside money added to (positive) or withdrawn from the
+// portfolio before trading on Date.
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// WithContributions makes regular deposits or withdrawals (see
+// ContributionSchedule). Returns stay time-weighted, net of the flows,
+// and Metrics.IRR reports the money-weighted return.
+func WithContributions(s ContributionSchedule) Option {
+	return func(p *Portfolio) error {
+		if s.Amount == 0 || math.IsNaN(s.Amount) || math.IsInf(s.Amount, 0) {
+			return fmt.Errorf("contribution amount %v: must be a finite, non-zero number", s.Amount)
+		}
+		switch s.Every {
+		case "":
+			s.Every = "month"
+		case "week", "month", "quarter", "year":
+		default:
+			return fmt.Errorf("contribution period %q: must be week, month, quarter or year", s.Every)
+		}
+		p.Contributions = &s
+		return nil
+	}
+}
+
+// period numbers the week, month, quarter or year d falls in, so
+// consecutive bars with different numbers straddle a boundary.
+func (s *ContributionSchedule) period(d time.Time) int {
+	return calendar.Period(d, s.Every)
+}
+
+// contribute pays the schedule's contribution if the bar dated date
+// opens a new period since the bar dated prev. The engine calls it
+// before anything else on a bar, so the cash is there to trade with.
+func (p *Portfolio) contribute(prev, date time.Time) {
+	s := p.Contributions
+	if s == nil || prev.IsZero() || s.period(prev) == s.period(date) {
+		return
+	}
+	p.CashFlow(s.Amount, date)
+}
+
+// CashFlow deposits amount of outside cash, or withdraws it if negative,
+// recording it in CashFlows. Unlike Deposit and Withdraw, which move the
+// portfolio's own money, the flow is taken out of the day's return. A
+// withdrawal larger than the cash on hand takes only the cash.
+func (p *Portfolio) CashFlow(amount float64, date time.Time) {
+	if amount < 0 && -amount > p.BuyingPower {
+		logger.Warn("withdrawal cut to the cash held", "portfolio", p.Pname,
+			"withdrawal", -amount, "date", formatDate(date), "cash", p.BuyingPower)
+		amount = -math.Max(p.BuyingPower, 0)
+	}
+	if amount == 0 {
+		return
+	}
+	p.adjustCash(amount)
+	p.flow += amount
+	p.CashFlows = append(p.CashFlows, CashFlow{Date: date, Amount: amount})
+	p.txLog().Debug("CASHFLOW", "portfolio", p.Pname, "amount", amount, "date", formatDate(date))
+}
+
+// irr is the money-weighted annual return, in percent, of putting in
+// initial on start and flows after it and holding final on end: the rate
+// at which their present values sum to zero, with years of 365 days. It
+// is 0 when there is no such rate in (-100%, 1e6%).
+func irr(initial float64, start time.Time, flows []CashFlow, final float64, end time.Time) float64 {
+	if initial <= 0 || !end.After(start) {
+		return 0
+	}
+	years := func(d time.Time) float64 { return d.Sub(start).Hours() / 24 / 365 }
+	npv := func(rate float64) float64 {
+		v := -initial
+		for _, f := range flows {
+			v -= f.Amount / math.Pow(1+rate, years(f.Date))
+		}
+		return v + final/math.Pow(1+rate, years(end))
+	}
+	lo, hi := -0.999999, 1e4
+	if npv(lo)*npv(hi) > 0 {
+		return 0
+	}
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if npv(lo)*npv(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return (lo + hi) / 2 * 100
+}
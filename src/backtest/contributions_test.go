@@ -0,0 +1,88 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// $500 arrives on the first bar of February and of March and dca
+// invests each deposit as it lands. The price never moves, so the
+// deposits must not show up as returns.
+func TestContributions_DCA(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 91; i++ {
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: day(i), Open: 10, High: 10, Low: 10, Close: 10, Volume: 100,
+		})
+	}
+	p, err := NewPortfolio("dca", 1000, []string{"A"}, "dca:greedy",
+		WithWindow(day(0), day(90)), WithContributions(ContributionSchedule{Amount: 500}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	want := []Trade{
+		{Date: day(0), Ticker: "A", Side: "BUY", Amount: 100, Price: 10},
+		{Date: day(31), Ticker: "A", Side: "BUY", Amount: 50, Price: 10},
+		{Date: day(60), Ticker: "A", Side: "BUY", Amount: 50, Price: 10},
+	}
+	if len(r.Trades) != len(want) {
+		t.Fatalf("trades = %+v", r.Trades)
+	}
+	for i, tr := range r.Trades {
+		if tr != want[i] {
+			t.Errorf("trade %d = %+v, want %+v", i, tr, want[i])
+		}
+	}
+	for i, ret := range r.Returns {
+		if ret != 0 {
+			t.Fatalf("return on %s = %v, want 0 on a flat price", r.Dates[i], ret)
+		}
+	}
+	m := r.Metrics
+	if m.NetContributions != 1000 || r.EquityCurve[len(r.EquityCurve)-1] != 2000 {
+		t.Errorf("net contributions %v, final value %v; want 1000 and 2000", m.NetContributions, r.EquityCurve[len(r.EquityCurve)-1])
+	}
+	if !closeTo(m.IRR, 0) || m.AnnualReturn != 0 || m.MaxDrawdown != 0 {
+		t.Errorf("IRR %v, annual %v, drawdown %v; want all 0", m.IRR, m.AnnualReturn, m.MaxDrawdown)
+	}
+}
+
+func TestContributions_Withdrawal(t *testing.T) {
+	day := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	p := newPropPortfolio(300, AccountingFloat)
+	p.CashFlow(-200, day)
+	p.CashFlow(-200, day)
+	if p.BuyingPower != 0 || p.flow != -300 || len(p.CashFlows) != 2 || p.CashFlows[1].Amount != -100 {
+		t.Errorf("cash %v, flow %v, flows %+v; want the second withdrawal cut to 100", p.BuyingPower, p.flow, p.CashFlows)
+	}
+
+	for _, bad := range []ContributionSchedule{{}, {Amount: 100, Every: "day"}} {
+		if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithContributions(bad)); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
+
+func TestIRR(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	year := func(n int) time.Time { return start.AddDate(0, 0, 365*n) }
+	if got := irr(1000, start, nil, 1100, year(1)); !closeTo(got, 10) {
+		t.Errorf("no flows: %v, want 10", got)
+	}
+	// 100 grows to 121 and a second 100 a year in to 110.
+	flows := []CashFlow{{Date: year(1), Amount: 100}}
+	if got := irr(100, start, flows, 231, year(2)); !closeTo(got, 10) {
+		t.Errorf("one deposit: %v, want 10", got)
+	}
+	if got := irr(100, start, nil, 1, year(1)); !closeTo(got, -99) {
+		t.Errorf("99%% loss: %v, want -99", got)
+	}
+}
@@ -23,30 +23,10 @@ func closesOnly(series []data.AssetData, n int) []float64 {
 	return closes
 }
 
-// returnsFromCloses returns the n-1 simple daily returns derived from the
-// first n Close prices. Series shorter than n are truncated to their own
-// length; n < 2 yields nil.
-func returnsFromCloses(series []data.AssetData, n int) []float64 {
-	if n > len(series) {
-		n = len(series)
-	}
-	if n < 2 {
-		return nil
-	}
-	returns := make([]float64, n-1)
-	for i := 1; i < n; i++ {
-		prev := series[i-1].Close
-		if prev == 0 {
-			continue
-		}
-		returns[i-1] = (series[i].Close - prev) / prev
-	}
-	return returns
-}
-
 // AvgPairwiseCorrelation is the mean Pearson correlation of daily returns
-// across every distinct ticker pair in the portfolio. Returns 0 when
-// fewer than two tickers carry usable data.
+// across every distinct ticker pair in the portfolio, using the returns
+// precomputed at load time (see data.FillReturns). Returns 0 when fewer
+// than two tickers carry usable data.
 func AvgPairwiseCorrelation(
 	tickers []string,
 	hist map[string][]data.AssetData,
@@ -54,7 +34,7 @@ func AvgPairwiseCorrelation(
 ) float64 {
 	returns := make([][]float64, 0, len(tickers))
 	for _, t := range tickers {
-		r := returnsFromCloses(hist[t], dataLen)
+		r := data.Returns(hist[t], dataLen)
 		if len(r) >= 2 {
 			returns = append(returns, r)
 		}
@@ -88,16 +68,36 @@ func AvgPairwiseCorrelation(
 	return total / float64(count)
 }
 
+// engleGranger5pct is MacKinnon's asymptotic Engle-Granger 5% critical
+// value for two variables with a constant in the cointegrating
+// regression.
+const engleGranger5pct = -3.34
+
 // engleGrangerCointegrated tests whether two price series cointegrate at
-// the 5% level. Step 1 runs OLS y = α + β·x to extract residuals; step 2
-// runs an ADF(1) regression Δe_t = ρ·e_{t-1} + γ·Δe_{t-1} + ε on those
-// residuals (no constant — OLS residuals have zero mean by construction)
-// and compares the t-stat on ρ to MacKinnon's asymptotic Engle-Granger 5%
-// critical value of −3.34 (two variables, constant in step 1).
+// the 5% level (see engleGranger).
 func engleGrangerCointegrated(y, x []float64) bool {
+	eg, ok := engleGranger(y, x)
+	return ok && eg.tStat < engleGranger5pct
+}
+
+// egFit is an Engle-Granger test: the cointegrating regression
+// y = alpha + beta·x, its residuals, and the ADF t-statistic on them.
+type egFit struct {
+	alpha, beta float64
+	res         []float64
+	tStat       float64
+}
+
+// engleGranger runs the two-step Engle-Granger test. Step 1 runs OLS
+// y = α + β·x to extract residuals; step 2 runs an ADF(1) regression
+// Δe_t = ρ·e_{t-1} + γ·Δe_{t-1} + ε on those residuals (no constant — OLS
+// residuals have zero mean by construction) and reports the t-stat on ρ,
+// to be compared with engleGranger5pct. It is false when the series are
+// mismatched, shorter than 30, or degenerate.
+func engleGranger(y, x []float64) (egFit, bool) {
 	n := len(y)
 	if n != len(x) || n < 30 {
-		return false
+		return egFit{}, false
 	}
 
 	meanY := stat.Mean(y, nil)
@@ -110,7 +110,7 @@ func engleGrangerCointegrated(y, x []float64) bool {
 		sxy += dx * dy
 	}
 	if sxx == 0 {
-		return false
+		return egFit{}, false
 	}
 	beta := sxy / sxx
 	alpha := meanY - beta*meanX
@@ -121,7 +121,7 @@ func engleGrangerCointegrated(y, x []float64) bool {
 
 	m := n - 2
 	if m < 5 {
-		return false
+		return egFit{}, false
 	}
 	var s11, s22, s12, s1y, s2y float64
 	for i := 2; i < n; i++ {
@@ -136,7 +136,7 @@ func engleGrangerCointegrated(y, x []float64) bool {
 	}
 	det := s11*s22 - s12*s12
 	if det == 0 {
-		return false
+		return egFit{}, false
 	}
 	rho := (s22*s1y - s12*s2y) / det
 	gamma := (s11*s2y - s12*s1y) / det
@@ -151,15 +151,14 @@ func engleGrangerCointegrated(y, x []float64) bool {
 	}
 	df := m - 2
 	if df <= 0 {
-		return false
+		return egFit{}, false
 	}
 	sigma2 := rss / float64(df)
 	seRho := math.Sqrt(sigma2 * s22 / det)
 	if seRho == 0 {
-		return false
+		return egFit{}, false
 	}
-	tStat := rho / seRho
-	return tStat < -3.34
+	return egFit{alpha: alpha, beta: beta, res: res, tStat: rho / seRho}, true
 }
 
 // CountCointegratedPairs returns the number of ticker pairs whose Close
@@ -0,0 +1,171 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+)
+
+// Execution is a fill as a CostModel sees it: the order, its quoted
+// price before costs, and the volume of the bar it fills on (0 when
+// unknown, e.g. a Buy made outside a run).
+type Execution struct {
+	Side   string // "BUY" or "SELL"
+	Ticker string
+	Amount float64
+	Quoted float64
+	Volume float64
+}
+
+// CostModel prices the frictions of a fill. Every fill the Portfolio
+// makes, in backtests and paper trading alike, goes through its models:
+// Commission and SlippageBps first, then each of Costs in order.
+type CostModel interface {
+	// Price returns the per-share price e executes at, given price, the
+	// quote after any earlier model's slippage.
+	Price(e Execution, price float64) float64
+	// Fee returns the commission, in dollars, on e executed at price.
+	Fee(e Execution, price float64) float64
+}
+
+// Costs chains models: each one's Price feeds the next, and the fees of
+// all of them are charged on the final price.
+type Costs []CostModel
+
+func (c Costs) Price(e Execution, price float64) float64 {
+	for _, m := range c {
+		price = m.Price(e, price)
+	}
+	return price
+}
+
+func (c Costs) Fee(e Execution, price float64) float64 {
+	fee := 0.0
+	for _, m := range c {
+		fee += m.Fee(e, price)
+	}
+	return fee
+}
+
+// FixedFee is a flat commission, in dollars, per fill.
+type FixedFee float64
+
+func (f FixedFee) Price(_ Execution, price float64) float64 { return price }
+func (f FixedFee) Fee(Execution, float64) float64           { return float64(f) }
+
+// PerShareFee charges PerShare dollars a share, but never less than Min
+// a fill.
+type PerShareFee struct {
+	PerShare float64
+	Min      float64
+}
+
+func (f PerShareFee) Price(_ Execution, price float64) float64 { return price }
+func (f PerShareFee) Fee(e Execution, _ float64) float64 {
+	return math.Max(f.PerShare*e.Amount, f.Min)
+}
+
+// BpsSlippage moves every fill a fixed number of basis points against
+// the trade: buys pay more, sells receive less.
+type BpsSlippage float64
+
+func (s BpsSlippage) Price(e Execution, price float64) float64 {
+	return slip(e.Side, price, float64(s)/10_000)
+}
+func (s BpsSlippage) Fee(Execution, float64) float64 { return 0 }
+
+// VolumeSlippage models market impact: a fill moves the price against
+// the trade by Impact times its share of the bar's volume, so taking the
+// whole bar (or more) costs Impact, e.g. 0.01 for 1%. Fills on bars with
+// no recorded volume are not moved.
+type VolumeSlippage struct {
+	Impact float64
+}
+
+func (s VolumeSlippage) Price(e Execution, price float64) float64 {
+	if e.Volume <= 0 {
+		return price
+	}
+	return slip(e.Side, price, s.Impact*math.Min(e.Amount/e.Volume, 1))
+}
+func (s VolumeSlippage) Fee(Execution, float64) float64 { return 0 }
+
+// slip moves price by frac against side.
+func slip(side string, price, frac float64) float64 {
+	if frac == 0 {
+		return price
+	}
+	if side == "BUY" {
+		return price * (1 + frac)
+	}
+	return price * (1 - frac)
+}
+
+// WithCosts adds cost models applied to every fill after Commission and
+// SlippageBps. Slippage models must move prices by less than 100%.
+func WithCosts(models ...CostModel) Option {
+	return func(p *Portfolio) error {
+		for _, m := range models {
+			if err := checkCostModel(m); err != nil {
+				return err
+			}
+		}
+		p.Costs = append(p.Costs, models...)
+		return nil
+	}
+}
+
+func checkCostModel(m CostModel) error {
+	switch m := m.(type) {
+	case FixedFee:
+		if !(m >= 0) || math.IsInf(float64(m), 1) {
+			return fmt.Errorf("fixed fee %v: must be a finite amount >= 0", float64(m))
+		}
+	case PerShareFee:
+		if !(m.PerShare >= 0) || !(m.Min >= 0) || math.IsInf(m.PerShare+m.Min, 1) {
+			return fmt.Errorf("per-share fee %+v: must be finite amounts >= 0", m)
+		}
+	case BpsSlippage:
+		if !(m >= 0 && m < 10_000) {
+			return fmt.Errorf("slippage %v bps: must be in [0, 10000)", float64(m))
+		}
+	case VolumeSlippage:
+		if !(m.Impact >= 0 && m.Impact < 1) {
+			return fmt.Errorf("volume slippage impact %v: must be in [0, 1)", m.Impact)
+		}
+	}
+	return nil
+}
+
+// costs is every model p's fills go through.
+func (p *Portfolio) costs() Costs {
+	c := make(Costs, 0, 2+len(p.Costs))
+	if p.Commission != 0 {
+		c = append(c, FixedFee(p.Commission))
+	}
+	if p.SlippageBps != 0 {
+		c = append(c, BpsSlippage(p.SlippageBps))
+	}
+	return append(c, p.Costs...)
+}
+
+// execute prices a side's fill of amount shares of ticker quoted at
+// quoted, returning the price it executes at and the fee charged.
+func (p *Portfolio) execute(side, ticker string, amount, quoted float64) (price, fee float64) {
+	e := Execution{Side: side, Ticker: ticker, Amount: amount, Quoted: quoted, Volume: p.barVolume(ticker)}
+	c := p.costs()
+	price = c.Price(e, quoted)
+	return price, c.Fee(e, price)
+}
+
+// barVolume is the volume of ticker's bar being processed, or 0 outside
+// a run.
+func (p *Portfolio) barVolume(ticker string) float64 {
+	if p.engine == nil {
+		return 0
+	}
+	series := p.engine.bar.Hist[ticker]
+	if p.bar < 0 || p.bar >= len(series) {
+		return 0
+	}
+	return series[p.bar].Volume
+}
@@ -0,0 +1,88 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestCostModels(t *testing.T) {
+	buy := Execution{Side: "BUY", Amount: 50, Quoted: 10, Volume: 100}
+	sell := Execution{Side: "SELL", Amount: 200, Quoted: 10, Volume: 100}
+	for _, c := range []struct {
+		name  string
+		model CostModel
+		e     Execution
+		price float64
+		fee   float64
+	}{
+		{"fixed", FixedFee(2), buy, 10, 2},
+		{"per share", PerShareFee{PerShare: 0.1, Min: 1}, buy, 10, 5},
+		{"per share min", PerShareFee{PerShare: 0.01, Min: 1}, buy, 10, 1},
+		{"bps", BpsSlippage(100), sell, 9.9, 0},
+		{"volume half bar", VolumeSlippage{Impact: 0.02}, buy, 10.1, 0},
+		{"volume capped", VolumeSlippage{Impact: 0.02}, sell, 9.8, 0},
+		{"volume unknown", VolumeSlippage{Impact: 0.02}, Execution{Side: "BUY", Amount: 1}, 10, 0},
+		{"chain", Costs{BpsSlippage(100), PerShareFee{PerShare: 0.1}}, buy, 10.1, 5},
+	} {
+		price := c.model.Price(c.e, 10)
+		if math.Abs(price-c.price) > 1e-9 {
+			t.Errorf("%s: price = %v, want %v", c.name, price, c.price)
+		}
+		if fee := c.model.Fee(c.e, price); math.Abs(fee-c.fee) > 1e-9 {
+			t.Errorf("%s: fee = %v, want %v", c.name, fee, c.fee)
+		}
+	}
+
+	if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy",
+		WithCosts(VolumeSlippage{Impact: 1})); err == nil {
+		t.Error("expected error for a 100% impact")
+	}
+}
+
+// Sizing shrinks until a fee that grows with the order is still covered.
+func TestMaxBuy_PerShareFee(t *testing.T) {
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy",
+		WithCosts(PerShareFee{PerShare: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := p.maxBuy("A", 10, "greedy")
+	if amount != 90 {
+		t.Errorf("maxBuy = %v, want 90 (90 shares + $90 fee)", amount)
+	}
+	if err := p.Buy("A", amount, 10, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if p.BuyingPower != 10 || p.Trades[0].Fee != 90 {
+		t.Errorf("cash = %v, trade = %+v", p.BuyingPower, p.Trades[0])
+	}
+}
+
+// During a run, volume slippage sees the volume of the bar being filled.
+func TestRun_VolumeSlippage(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{
+		bars: map[string][]data.AssetData{"A": {
+			{Date: day(0), Open: 10, High: 10, Low: 10, Close: 10, Volume: 200},
+			{Date: day(1), Open: 10, High: 10, Low: 10, Close: 10, Volume: 200},
+		}},
+		rates: map[int64]float64{},
+	}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy",
+		WithWindow(day(0), day(1)), WithCosts(VolumeSlippage{Impact: 0.1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := results[0].Trades[0]
+	want := 10 * (1 + 0.1*tr.Amount/200)
+	if tr.Amount == 0 || math.Abs(tr.Price-want) > 1e-9 || tr.Amount*tr.Price > 1000 {
+		t.Errorf("trade = %+v, want price %v within cash", tr, want)
+	}
+}
@@ -0,0 +1,215 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// DefaultFolds is how many blocks CrossValidate cuts a history into when
+// its config sets no Folds.
+const DefaultFolds = 5
+
+// CrossValidationConfig controls K-fold cross-validation (see
+// CrossValidate). All fields are optional.
+type CrossValidationConfig struct {
+	Folds int `toml:"folds"` // contiguous blocks; default DefaultFolds, at least 2
+	// Objective, Strategies and Grid are as in WalkForwardConfig. With
+	// neither Strategies nor Grid, the portfolio's own configuration is
+	// the one candidate.
+	Objective  string           `toml:"objective"`
+	Strategies []string         `toml:"strategies"`
+	Grid       map[string][]any `toml:"grid"`
+}
+
+// CrossValidationFold is one fold of a cross-validation run: the
+// held-out block, the candidate that scored best on average over the
+// other blocks and that average as InSampleScore, and its Score and
+// Metrics on the held-out block.
+type CrossValidationFold struct {
+	Start, End    string // YYYY-MM-DD
+	Strategy      string
+	Params        map[string]any
+	InSampleScore float64
+	Score         float64
+	Metrics       Metrics
+}
+
+func (c *CrossValidationConfig) objective() (field string, minimize bool, err error) {
+	field, minimize, err = objectiveField(c.Objective)
+	if err != nil {
+		return "", false, fmt.Errorf("cross-validation %w", err)
+	}
+	return field, minimize, nil
+}
+
+func (c *CrossValidationConfig) folds() int {
+	if c.Folds == 0 {
+		return DefaultFolds
+	}
+	return c.Folds
+}
+
+func (c *CrossValidationConfig) validate() error {
+	if c.Folds < 0 || c.Folds == 1 {
+		return fmt.Errorf("cross-validation folds %d: need at least 2", c.Folds)
+	}
+	_, _, err := c.objective()
+	return err
+}
+
+// CrossValidate runs K-fold cross-validation of p: its history is cut
+// into cfg.Folds contiguous blocks of about equal length, and every
+// candidate is backtested on each block on its own, starting flat with
+// p's cash. Each block in turn is then held out: the candidate with the
+// best mean cfg.Objective over the other blocks is run on it, starting
+// with the cash the previous held-out run ended with and entering at the
+// close of the bar before it. The returned Result stitches those runs
+// together in date order, so its equity curve and metrics cover the
+// whole history out of sample, and Folds records each fold.
+// Metrics.FoldScore and FoldScoreStdDev are the mean and standard
+// deviation of the held-out scores, and FoldInSampleScore the mean of
+// the scores each fold's candidate was picked on: a held-out score far
+// below that, or spread widely across folds, flags a choice fitted to
+// noise.
+//
+// Unlike WalkForward's, a fold's candidate is picked on blocks both
+// before and after the one it's tested on, so this measures how stable
+// a choice is across regimes rather than how it would have traded.
+func CrossValidate(ctx context.Context, store Store, p *Portfolio, cfg *CrossValidationConfig) (Result, error) {
+	if err := cfg.validate(); err != nil {
+		return Result{}, err
+	}
+	field, minimize, _ := cfg.objective()
+	k := cfg.folds()
+
+	hist, rf, lead, err := p.history(ctx, store)
+	if err != nil {
+		return Result{}, fmt.Errorf("cross-validation %s: %w", p.Pname, err)
+	}
+	if len(lead) < 2*k {
+		return Result{}, fmt.Errorf("cross-validation %s: %d bars is fewer than 2 per fold for %d folds",
+			p.Pname, len(lead), k)
+	}
+	tmpl := *p
+	tmpl.store = store
+
+	// Block i covers bars [cut[i], cut[i+1]), and its runs open on the
+	// bar before it so every one of its bars has a return.
+	cut := make([]int, k+1)
+	for i := range cut {
+		cut[i] = i * len(lead) / k
+	}
+	span := func(i int) (start, end int) {
+		return max(cut[i]-1, 0), cut[i+1] - 1
+	}
+
+	wf := WalkForwardConfig{Strategies: cfg.Strategies, Grid: cfg.Grid}
+	cands := wf.candidates(p.StrategySpec, p.StrategyParams)
+	runs := make([]*Portfolio, 0, len(cands)*k)
+	for _, c := range cands {
+		for i := 0; i < k; i++ {
+			start, end := span(i)
+			v, err := tmpl.variant(c, lead[start].Date, lead[end].Date, p.InitialBuyingPower)
+			if err != nil {
+				return Result{}, fmt.Errorf("cross-validation %s: candidate %s: %w", p.Pname, c.spec, err)
+			}
+			runs = append(runs, v)
+		}
+	}
+	scored := runPortfolios(ctx, runs, hist, rf, nil, nil)
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	// scores[c][i] is candidate c's score on block i, highest best.
+	scores := make([][]float64, len(cands))
+	for c := range cands {
+		scores[c] = make([]float64, k)
+		for i := 0; i < k; i++ {
+			v, _ := resultValue(scored[c*k+i], field)
+			scores[c][i] = v.(float64)
+			if minimize {
+				scores[c][i] = -scores[c][i]
+			}
+		}
+	}
+
+	stitched := p.stitched()
+	cash := p.InitialBuyingPower
+	folds := make([]CrossValidationFold, 0, k)
+	held := make([]float64, 0, k)
+	inSample := make([]float64, 0, k)
+	for i := 0; i < k; i++ {
+		best, bestScore := -1, math.Inf(-1)
+		for c := range cands {
+			sum := 0.0
+			for j, s := range scores[c] {
+				if j != i {
+					sum += s
+				}
+			}
+			if mean := sum / float64(k-1); best < 0 || mean > bestScore {
+				best, bestScore = c, mean
+			}
+		}
+		if minimize {
+			bestScore = -bestScore
+		}
+
+		start, end := span(i)
+		out, err := tmpl.variant(cands[best], lead[start].Date, lead[end].Date, cash)
+		if err != nil {
+			return Result{}, fmt.Errorf("cross-validation %s: %w", p.Pname, err)
+		}
+		if !runOne(ctx, out, hist, rf) {
+			return Result{}, ctx.Err()
+		}
+		v, _ := resultValue(newResult(out), field)
+		score := v.(float64)
+		runLogger.Info("cross-validation fold",
+			"portfolio", p.Pname, "fold", i+1, "start", formatDate(lead[cut[i]].Date), "end", formatDate(lead[end].Date),
+			"strategy", cands[best].spec, "params", cands[best].params, "objective", field,
+			"in_score", bestScore, "out_score", score)
+		folds = append(folds, CrossValidationFold{
+			Start: formatDate(lead[cut[i]].Date), End: formatDate(lead[end].Date),
+			Strategy: cands[best].spec, Params: cands[best].params,
+			InSampleScore: bestScore, Score: score, Metrics: out.Metrics,
+		})
+		held, inSample = append(held, score), append(inSample, bestScore)
+
+		stitched.stitch(out)
+		if n := len(out.PortfolioCloseValues); n > 0 {
+			cash = out.PortfolioCloseValues[n-1]
+		}
+	}
+
+	r := p.finishStitched(ctx, stitched, hist, rf)
+	r.Strategy = "crossValidate"
+	r.Folds = folds
+	r.Metrics.Folds = k
+	r.Metrics.FoldScore, r.Metrics.FoldScoreStdDev = stat.MeanStdDev(held, nil)
+	r.Metrics.FoldInSampleScore = stat.Mean(inSample, nil)
+	runLogger.Info("cross-validation",
+		"portfolio", p.Pname, "folds", k, "objective", field, "in_score", r.Metrics.FoldInSampleScore,
+		"out_score", r.Metrics.FoldScore, "out_stddev", r.Metrics.FoldScoreStdDev)
+	return r, nil
+}
+
+// RunCrossValidate runs CrossValidate for every portfolio, in order,
+// and writes the stitched Results through output as Run does.
+func RunCrossValidate(
+	ctx context.Context,
+	store Store,
+	portfolios []*Portfolio,
+	cfg *CrossValidationConfig,
+	output *OutputConfig,
+) ([]Result, error) {
+	if cfg == nil {
+		cfg = &CrossValidationConfig{}
+	}
+	return runEach(ctx, store, portfolios, output, func(ctx context.Context, store Store, p *Portfolio) (Result, error) {
+		return CrossValidate(ctx, store, p, cfg)
+	})
+}
@@ -0,0 +1,76 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A ticker that rises over three of four blocks and falls over the
+// last: holding it wins on average wherever it's held out, and the
+// held-out scores spread as the market does.
+func TestCrossValidate(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 40; i++ {
+		price := 10 + float64(i)
+		if i >= 30 {
+			price = 39 - 2*float64(i-29)
+		}
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: day(i), Open: price, High: price, Low: price, Close: price, Volume: 100,
+		})
+		store.rates[day(i).Unix()] = 0
+	}
+	script := filepath.Join(t.TempDir(), "hold.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  if params.hold == 1 and position("A") == nil then
+    buy("A", 10, close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := InitializePortfolio(1000, day(0), day(39), "cv", []string{"A"}, "lua:"+script, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &CrossValidationConfig{
+		Folds: 4, Objective: "AnnualReturn",
+		Grid: map[string][]any{"hold": {int64(0), int64(1)}},
+	}
+	r, err := CrossValidate(context.Background(), store, p, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Folds) != 4 || r.Metrics.Folds != 4 || r.Strategy != "crossValidate" {
+		t.Fatalf("folds = %d (%d), strategy %q", len(r.Folds), r.Metrics.Folds, r.Strategy)
+	}
+	for i, f := range r.Folds {
+		if f.Params["hold"] != int64(1) {
+			t.Errorf("fold %d (%s..%s) picked hold=%v, want 1", i, f.Start, f.End, f.Params["hold"])
+		}
+	}
+	if f := r.Folds[3]; f.Start != "2024-01-31" || f.End != "2024-02-09" || f.Score >= 0 {
+		t.Errorf("last fold = %+v, want the falling block, scoring below 0", f)
+	}
+	if len(r.Dates) != 39 || r.Dates[0] != "2024-01-02" || r.Dates[38] != "2024-02-09" {
+		t.Errorf("dates = %d from %s, want every bar after the first", len(r.Dates), r.Dates[0])
+	}
+	m := r.Metrics
+	if m.FoldScoreStdDev <= 0 || m.FoldScore >= m.FoldInSampleScore {
+		t.Errorf("fold score %v ± %v against %v in sample; want it lower and spread",
+			m.FoldScore, m.FoldScoreStdDev, m.FoldInSampleScore)
+	}
+
+	for _, bad := range []CrossValidationConfig{{Folds: 1}, {Folds: -2}, {Objective: "Luck"}, {Folds: 30}} {
+		if _, err := CrossValidate(context.Background(), store, p, &bad); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"strings"
+	"time"
+)
+
+// CheckedStore is a Store whose queries also return their errors;
+// *data.Store is one. Run uses it to retry failed queries and to skip
+// the portfolios whose bars couldn't be read, rather than simulate them
+// over missing data.
+type CheckedStore interface {
+	QueryAssets(ctx context.Context, tickers []string, start, end time.Time) (map[string][]data.AssetData, error)
+	QueryRiskFree(ctx context.Context, start, end time.Time) (map[int64]float64, error)
+}
+
+var _ CheckedStore = (*data.Store)(nil)
+
+// DataError is a query that still failed after its retries.
+type DataError struct {
+	// Ticker is the ticker whose bars failed; "" for the risk-free rates.
+	Ticker string
+	Err    error
+}
+
+func (e DataError) Error() string {
+	if e.Ticker == "" {
+		return fmt.Sprintf("risk-free rates: %v", e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Ticker, e.Err)
+}
+
+func (e DataError) Unwrap() error { return e.Err }
+
+// queryAttempts is how many times a failed query is tried, waiting
+// retryBackoff before the first retry and twice as long before each
+// one after.
+var (
+	queryAttempts = 3
+	retryBackoff  = 250 * time.Millisecond
+)
+
+// retry runs query until it succeeds, ctx is done or it has failed
+// queryAttempts times, and returns its last answer.
+func retry[T any](ctx context.Context, query func() (T, error)) (T, error) {
+	wait := retryBackoff
+	for attempt := 1; ; attempt++ {
+		v, err := query()
+		if err == nil || attempt >= queryAttempts || ctx.Err() != nil {
+			return v, err
+		}
+		select {
+		case <-ctx.Done():
+			return v, err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// loadBars queries tickers' bars in one batch. If a CheckedStore's batch
+// fails, each ticker is queried on its own, with retries, so one bad
+// ticker doesn't cost the others their bars; those that still fail are
+// returned as DataErrors. A cancelled ctx is not a data error. Other
+// Stores are queried as before, logging their own failures.
+func loadBars(
+	ctx context.Context, store Store, tickers []string, start, end time.Time,
+) (map[string][]data.AssetData, []DataError) {
+	cs, ok := store.(CheckedStore)
+	if !ok {
+		return store.QueryAssetsForTickers(ctx, tickers, start, end), nil
+	}
+	bars, err := cs.QueryAssets(ctx, tickers, start, end)
+	if err == nil || ctx.Err() != nil {
+		return bars, nil
+	}
+	runLogger.Warn("querying tickers failed; retrying one at a time", "tickers", len(tickers), "err", err)
+	bars = make(map[string][]data.AssetData, len(tickers))
+	var errs []DataError
+	for _, t := range tickers {
+		one, err := retry(ctx, func() (map[string][]data.AssetData, error) {
+			return cs.QueryAssets(ctx, []string{t}, start, end)
+		})
+		if ctx.Err() != nil {
+			return bars, nil
+		}
+		if err != nil {
+			errs = append(errs, DataError{t, err})
+			continue
+		}
+		if series, ok := one[t]; ok {
+			bars[t] = series
+		}
+	}
+	return bars, errs
+}
+
+// loadRiskFree queries the Store's risk-free rates, retrying a
+// CheckedStore's failed query. Rates that still can't be read are
+// returned as a DataError along with whatever was read.
+func loadRiskFree(ctx context.Context, store Store, start, end time.Time) (map[int64]float64, []DataError) {
+	cs, ok := store.(CheckedStore)
+	if !ok {
+		return DBRiskFree{store}.RiskFreeRates(ctx, start, end), nil
+	}
+	rates, err := retry(ctx, func() (map[int64]float64, error) {
+		return cs.QueryRiskFree(ctx, start, end)
+	})
+	if err != nil && ctx.Err() == nil {
+		return rates, []DataError{{Err: err}}
+	}
+	return rates, nil
+}
+
+// logDataErrors summarizes a run's data errors and the portfolios they
+// caused to be skipped.
+func logDataErrors(errs []DataError, skipped []string) {
+	if len(errs) == 0 {
+		return
+	}
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	runLogger.Error("data errors", "count", len(errs), "errors", strings.Join(msgs, "; "))
+	if len(skipped) > 0 {
+		runLogger.Warn("skipped portfolios for missing data", "count", len(skipped), "portfolios", strings.Join(skipped, ", "))
+	}
+}
+
+// needsFailed reports whether p trades a ticker in failed. A failed
+// benchmark only costs the portfolio its BenchmarkReturn.
+func needsFailed(p *Portfolio, failed map[string]bool) bool {
+	for _, t := range p.Tickers {
+		if failed[t] {
+			return true
+		}
+	}
+	return false
+}
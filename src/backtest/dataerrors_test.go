@@ -0,0 +1,86 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"my-backtester/src/data"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyStore fails a query naming a ticker in fail that many more times,
+// and every query of more than one ticker while any failures remain.
+type flakyStore struct {
+	*fakeStore
+	fail     map[string]int
+	attempts map[string]int
+}
+
+func (s *flakyStore) QueryAssets(
+	ctx context.Context, tickers []string, start, end time.Time,
+) (map[string][]data.AssetData, error) {
+	for _, t := range tickers {
+		s.attempts[t]++
+		if s.fail[t] > 0 {
+			if len(tickers) == 1 {
+				s.fail[t]--
+			}
+			return nil, errors.New("IO Error: connection reset")
+		}
+	}
+	return s.fakeStore.QueryAssetsForTickers(ctx, tickers, start, end), nil
+}
+
+func (s *flakyStore) QueryRiskFree(ctx context.Context, start, end time.Time) (map[int64]float64, error) {
+	return s.fakeStore.GetRiskFreeRates(ctx, start, end), nil
+}
+
+// A failed batch is retried ticker by ticker: a ticker that recovers is
+// simulated, and a portfolio trading one that never does is skipped.
+func TestRun_RetriesFailedTickers(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = 0
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}}
+	for _, tk := range []string{"A", "B", "C"} {
+		for i := 0; i < 10; i++ {
+			store.bars[tk] = append(store.bars[tk], data.AssetData{Date: day(i), Close: 10 + float64(i)})
+		}
+	}
+	flaky := &flakyStore{store, map[string]int{"A": 1, "B": queryAttempts}, map[string]int{}}
+	var portfolios []*Portfolio
+	for _, tk := range []string{"A", "B", "C"} {
+		p, err := NewPortfolio("p"+tk, 1000, []string{tk}, "buyAndHold", WithWindow(day(0), day(9)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		portfolios = append(portfolios, p)
+	}
+
+	results, err := Run(context.Background(), flaky, portfolios, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.PortfolioName)
+	}
+	if got := strings.Join(names, ","); got != "pA,pC" {
+		t.Errorf("results for %s, want pA,pC", got)
+	}
+	// The batch stops at A; then A twice, B until it gives up and C once.
+	if flaky.attempts["A"] != 3 || flaky.attempts["B"] != queryAttempts || flaky.attempts["C"] != 1 {
+		t.Errorf("attempts = %v", flaky.attempts)
+	}
+
+	_, errs := loadBars(context.Background(), flaky, []string{"B"}, day(0), day(9))
+	if len(errs) != 0 {
+		t.Errorf("B's failures are used up, yet it failed: %v", errs)
+	}
+	flaky.fail["C"] = queryAttempts + 1
+	_, errs = loadBars(context.Background(), flaky, []string{"A", "C"}, day(0), day(9))
+	if len(errs) != 1 || errs[0].Ticker != "C" || !strings.Contains(errs[0].Error(), "C: IO Error") {
+		t.Errorf("errs = %v, want C's", errs)
+	}
+}
@@ -0,0 +1,27 @@
+// Package backtest is the simulation engine: portfolios, strategies
+// (built-in, Lua and trade-list replay), fill models, metrics, result
+// reporting, and paper trading. It is the importable core behind the
+// CLI (package cli, built by cmd/backtester) and the desktop UI.
+//
+// An embedding program opens a data.Store, builds Portfolios from
+// PortfolioConfig values (or a whole Config with ParseConfig), and calls
+// Run with the store:
+//
+//	store, err := data.Open("stock_data.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//	cfg, err := backtest.ParseConfig(text, "toml")
+//	...
+//	p, err := cfg.Portfolios[0].ToPortfolio()
+//	...
+//	results, err := backtest.Run(ctx, store, []*backtest.Portfolio{p}, nil)
+//
+// Anything implementing Store can stand in for the database, and any
+// data.DataProvider can through ProviderStore. The context
+// reaches every query, quote and broker request and is checked between
+// bars, so callers can bound a run with a deadline or cancel it.
+// RunFromConfigText does all of that from in-memory config text, and
+// NewResultsDocument converts results to their stable JSON form.
+package backtest
@@ -0,0 +1,291 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"time"
+)
+
+// The simulation is driven by events. The engine's Run publishes a
+// BarEvent for each bar; its handlers fill queued orders, step the
+// strategy and mark the portfolio to market. A strategy may trade with
+// Order, which executes an OrderEvent through the portfolio's OrderRouter
+// at once, or publish a SignalEvent, which becomes one after the step;
+// each trade that makes a FillEvent. Handlers can be added for any event
+// type (see WithEngineHook), so features such as risk rules or trade
+// logging plug in without touching the loop, and backtests and paper
+// trading share it.
+
+// BarEvent announces bar day (dated Date) of Hist.
+type BarEvent struct {
+	Day  int
+	Date time.Time
+	Hist map[string][]data.AssetData
+}
+
+// SignalEvent is a strategy's intent to trade Amount shares of Ticker.
+type SignalEvent struct {
+	Day    int
+	Ticker string
+	Side   string // "BUY" or "SELL"
+	Amount float64
+}
+
+// OrderEvent is an order to be executed under the portfolio's FillModel.
+type OrderEvent struct {
+	Day    int
+	Ticker string
+	Side   string
+	Amount float64
+}
+
+// FillEvent reports a trade the portfolio executed.
+type FillEvent struct {
+	Day   int
+	Trade Trade
+}
+
+// Engine dispatches events for one Portfolio. Handlers run in the order
+// they were added; events they publish are queued and dispatched after
+// the current bar handler returns, before the next one runs, so a
+// signal raised by the strategy is filled before the bar is marked to
+// market.
+type Engine struct {
+	p      *Portfolio
+	ctx    context.Context
+	bar    BarEvent
+	queue  []any
+	router OrderRouter
+
+	onBar    []func(BarEvent)
+	onSignal []func(SignalEvent)
+	onOrder  []func(OrderEvent)
+	onFill   []func(FillEvent)
+
+	started bool
+	prev    float64
+}
+
+// EngineHook adds handlers to a portfolio's Engine when a run builds it.
+type EngineHook func(*Engine)
+
+// WithEngineHook installs h on every Engine built for the portfolio.
+func WithEngineHook(h EngineHook) Option {
+	return func(p *Portfolio) error {
+		p.hooks = append(p.hooks, h)
+		return nil
+	}
+}
+
+// newEngine builds p's engine with the default pipeline — settle sale
+// proceeds, charge margin interest, pay scheduled contributions, pay
+// dividends and apply splits, close delisted positions, apply stop-losses
+// and take-profits, fill pending and resting orders, step (the strategy),
+// check the drawdown kill switch, mark to market; a halted portfolio skips
+// the fills and the step. Signals become orders and orders execute through
+// p's OrderRouter — followed by p's hooks. A portfolio without a Clock
+// gets a SimClock.
+func newEngine(p *Portfolio, step func(hist map[string][]data.AssetData, day int)) *Engine {
+	if p.Clock == nil {
+		p.Clock = NewSimClock(time.Time{})
+	}
+	e := &Engine{p: p}
+	e.OnBar(func(b BarEvent) {
+		p.settle(b.Date)
+		p.chargeMargin(b.Day)
+		if b.Day > 0 {
+			p.contribute(b.Hist[p.Tickers[0]][b.Day-1].Date, b.Date)
+		}
+		p.applyActions(b.Hist, b.Day)
+		p.liquidateDelisted(b.Hist, b.Day)
+	})
+	e.OnBar(func(b BarEvent) { p.stopPositions(b.Hist, b.Day) })
+	e.OnBar(func(b BarEvent) {
+		if p.Halted.IsZero() {
+			p.FillPending(b.Hist, b.Day)
+			p.FillResting(b.Hist, b.Day)
+		}
+	})
+	e.OnBar(func(b BarEvent) {
+		if p.Halted.IsZero() {
+			step(b.Hist, b.Day)
+		}
+	})
+	e.OnBar(func(b BarEvent) { p.checkDrawdown(b.Hist, b.Day) })
+	e.OnBar(e.markToMarket)
+	e.OnSignal(func(s SignalEvent) {
+		e.Publish(OrderEvent{Day: s.Day, Ticker: s.Ticker, Side: s.Side, Amount: s.Amount})
+	})
+	e.router = p.Router
+	if e.router == nil {
+		e.router = SimRouter{}
+	}
+	for _, h := range p.hooks {
+		h(e)
+	}
+	p.engine = e
+	return e
+}
+
+func (e *Engine) Portfolio() *Portfolio { return e.p }
+
+// Context is the context of the bar being dispatched, for handlers that
+// call out to a store or venue. It is context.Background() between bars.
+func (e *Engine) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+func (e *Engine) OnBar(h func(BarEvent))       { e.onBar = append(e.onBar, h) }
+func (e *Engine) OnSignal(h func(SignalEvent)) { e.onSignal = append(e.onSignal, h) }
+func (e *Engine) OnOrder(h func(OrderEvent))   { e.onOrder = append(e.onOrder, h) }
+func (e *Engine) OnFill(h func(FillEvent))     { e.onFill = append(e.onFill, h) }
+
+// Publish queues a SignalEvent, OrderEvent or FillEvent for dispatch.
+func (e *Engine) Publish(ev any) { e.queue = append(e.queue, ev) }
+
+// Bar dispatches bar day of hist to every bar handler, draining queued
+// events after each. A SimClock is first advanced to the bar's date.
+// Handlers see ctx through Context; a bar that has started is always
+// dispatched in full, so callers check ctx between bars.
+func (e *Engine) Bar(ctx context.Context, hist map[string][]data.AssetData, day int, date time.Time) {
+	if c, ok := e.p.Clock.(*SimClock); ok {
+		c.Set(date)
+	}
+	e.ctx = ctx
+	defer func() { e.ctx = nil }()
+	e.bar = BarEvent{Day: day, Date: date, Hist: hist}
+	for _, h := range e.onBar {
+		if e.p.Lookahead != nil {
+			return
+		}
+		h(e.bar)
+		e.drain()
+	}
+}
+
+// Run is a backtest's clock: it dispatches a BarEvent for each bar of
+// the portfolio's first ticker in hist, in order, until the bars run
+// out or a lookahead stops the portfolio. It reports false if ctx is
+// done first.
+func (e *Engine) Run(ctx context.Context, hist map[string][]data.AssetData) bool {
+	p := e.p
+	lead := hist[p.Tickers[0]]
+	for day := 0; day < len(lead) && p.Lookahead == nil; day++ {
+		if ctx.Err() != nil {
+			return false
+		}
+		p.bar, p.barDate = day, lead[day].Date
+		e.Bar(ctx, hist, day, lead[day].Date)
+	}
+	return true
+}
+
+func (e *Engine) drain() {
+	for len(e.queue) > 0 {
+		ev := e.queue[0]
+		e.queue = e.queue[1:]
+		switch ev := ev.(type) {
+		case SignalEvent:
+			for _, h := range e.onSignal {
+				h(ev)
+			}
+		case OrderEvent:
+			if err := e.route(ev, e.bar.Hist); err != nil {
+				logger.Warn("order failed", "portfolio", e.p.Pname, "day", ev.Day, "err", err)
+			}
+		case FillEvent:
+			for _, h := range e.onFill {
+				h(ev)
+			}
+		default:
+			logger.Error("unknown event", "portfolio", e.p.Pname, "type", fmt.Sprintf("%T", ev))
+		}
+	}
+}
+
+// OrderRouter executes the orders a portfolio places during a run, from
+// Order or a SignalEvent, against the Portfolio, which records each trade
+// and publishes it as a FillEvent. SimRouter, the default, fills at the
+// portfolio's FillModel and costs; another can model execution it
+// doesn't, such as partial fills or a delay, without changing the
+// strategies (see WithOrderRouter). It is simulated execution, unlike an
+// Executor, which mirrors the trades to a real account.
+type OrderRouter interface {
+	Route(p *Portfolio, o OrderEvent, hist map[string][]data.AssetData) error
+}
+
+// SimRouter executes an order in full with Portfolio.Order.
+type SimRouter struct{}
+
+func (SimRouter) Route(p *Portfolio, o OrderEvent, hist map[string][]data.AssetData) error {
+	return p.Order(o.Ticker, o.Side, o.Amount, hist, o.Day)
+}
+
+// WithOrderRouter sets the OrderRouter that executes the portfolio's
+// orders. Clones share it, so it must be safe for concurrent runs.
+func WithOrderRouter(r OrderRouter) Option {
+	return func(p *Portfolio) error {
+		p.Router = r
+		return nil
+	}
+}
+
+// route passes o to the order handlers and then the router, whose calls
+// to Order fill directly. Under FillNextOpen it only queues o: FillPending
+// routes it at the next open, so the router sees each order once, when
+// it fills.
+func (e *Engine) route(o OrderEvent, hist map[string][]data.AssetData) error {
+	p := e.p
+	if p.Fill == FillNextOpen && !p.opening {
+		return p.queue(o.Ticker, o.Side, o.Amount)
+	}
+	for _, h := range e.onOrder {
+		h(o)
+	}
+	p.routing = true
+	defer func() { p.routing = false }()
+	return e.router.Route(p, o, hist)
+}
+
+// markToMarket values the portfolio at the bar's close and, from the
+// second bar on, records the day's return. Cash flowing in or out on
+// the bar counts as there from its start, so it isn't a return.
+func (e *Engine) markToMarket(b BarEvent) {
+	p := e.p
+	curr := p.GetPortfolioValue(p.Tickers, b.Hist, b.Day)
+	p.recordExposure(b.Hist, b.Day)
+	if e.started {
+		p.AdjustPortfolioParameters(p.Tickers, b.Hist, b.Day, e.prev+p.flow, curr)
+		p.recordSectors(b.Hist, b.Day)
+	}
+	e.started = true
+	e.prev = curr
+	p.flow = 0
+}
+
+// context is the context of the bar p's engine is dispatching, or
+// context.Background() outside one.
+func (p *Portfolio) context() context.Context {
+	if p.engine == nil {
+		return context.Background()
+	}
+	return p.engine.Context()
+}
+
+// Signal publishes a SignalEvent from a strategy's Step. Outside an
+// engine (e.g. a strategy driven by hand in a test) it places the order
+// directly.
+func (p *Portfolio) Signal(
+	ticker, side string, amount float64,
+	hist map[string][]data.AssetData, day int,
+) error {
+	if p.engine == nil {
+		return p.Order(ticker, side, amount, hist, day)
+	}
+	p.engine.Publish(SignalEvent{Day: day, Ticker: ticker, Side: side, Amount: amount})
+	return nil
+}
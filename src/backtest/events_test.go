@@ -0,0 +1,181 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"reflect"
+	"testing"
+)
+
+// signalStrategy buys on day 1 and sells on day 3, via signals or
+// direct orders.
+type signalStrategy struct{ viaSignal bool }
+
+func (s *signalStrategy) Name() string { return "signals" }
+
+func (s *signalStrategy) Step(p *Portfolio, hist map[string][]data.AssetData, day int) {
+	place := p.Order
+	if s.viaSignal {
+		place = p.Signal
+	}
+	switch day {
+	case 1:
+		place("A", "BUY", 10, hist, day)
+	case 3:
+		place("A", "SELL", 10, hist, day)
+	}
+}
+
+func TestEngine_SignalsMatchDirectOrders(t *testing.T) {
+	hist := auditHist()
+	run := func(viaSignal bool) *Portfolio {
+		p, err := NewPortfolio("p", 10_000, []string{"A"}, "greedy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Strategy = &signalStrategy{viaSignal}
+		runOne(context.Background(), p, hist, nil)
+		return p
+	}
+	direct, signalled := run(false), run(true)
+	if len(direct.Trades) != 2 {
+		t.Fatalf("direct trades = %+v", direct.Trades)
+	}
+	if !reflect.DeepEqual(direct.Trades, signalled.Trades) ||
+		!reflect.DeepEqual(direct.PortfolioCloseValues, signalled.PortfolioCloseValues) {
+		t.Error("signals filled differently from direct orders")
+	}
+}
+
+func TestEngine_HooksSeeEveryEvent(t *testing.T) {
+	hist := auditHist()
+	var bars, signals, orders []int
+	var fills []Trade
+	hook := func(e *Engine) {
+		e.OnBar(func(b BarEvent) { bars = append(bars, b.Day) })
+		e.OnSignal(func(s SignalEvent) { signals = append(signals, s.Day) })
+		e.OnOrder(func(o OrderEvent) { orders = append(orders, o.Day) })
+		e.OnFill(func(f FillEvent) { fills = append(fills, f.Trade) })
+	}
+	p, err := NewPortfolio("p", 10_000, []string{"A"}, "greedy", WithEngineHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Strategy = &signalStrategy{viaSignal: true}
+	runOne(context.Background(), c, hist, nil)
+
+	if len(bars) != len(hist["A"]) {
+		t.Errorf("bars = %v, want one per day", bars)
+	}
+	if !reflect.DeepEqual(signals, []int{1, 3}) || !reflect.DeepEqual(orders, []int{1, 3}) {
+		t.Errorf("signals = %v, orders = %v", signals, orders)
+	}
+	if !reflect.DeepEqual(fills, c.Trades) {
+		t.Errorf("fills = %+v, want the ledger %+v", fills, c.Trades)
+	}
+}
+
+// halfRouter fills half of every order.
+type halfRouter struct{}
+
+func (halfRouter) Route(p *Portfolio, o OrderEvent, hist map[string][]data.AssetData) error {
+	o.Amount /= 2
+	return SimRouter{}.Route(p, o, hist)
+}
+
+func TestEngine_OrderRouter(t *testing.T) {
+	hist := auditHist()
+	run := func(viaSignal bool) *Portfolio {
+		p, err := NewPortfolio("p", 10_000, []string{"A"}, "greedy", WithOrderRouter(halfRouter{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := p.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Strategy = &signalStrategy{viaSignal}
+		runOne(context.Background(), c, hist, nil)
+		return c
+	}
+	for _, viaSignal := range []bool{true, false} {
+		c := run(viaSignal)
+		if len(c.Trades) != 2 || c.Trades[0].Amount != 5 || c.Trades[1].Amount != 5 {
+			t.Errorf("signal %v: trades = %+v, want two of 5 shares", viaSignal, c.Trades)
+		}
+	}
+}
+
+// Under next_open an order reaches the router once, at the open it
+// fills at, whether placed directly or signalled.
+func TestEngine_RouterFillsNextOpen(t *testing.T) {
+	hist := auditHist()
+	for _, viaSignal := range []bool{true, false} {
+		router := &recordingRouter{}
+		p, err := NewPortfolio("p", 10_000, []string{"A"}, "greedy", WithOrderRouter(router), WithFill(FillNextOpen, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := p.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Strategy = &signalStrategy{viaSignal}
+		runOne(context.Background(), c, hist, nil)
+		if len(c.Trades) != 2 || len(router.orders) != 2 {
+			t.Fatalf("signal %v: routed %+v for trades %+v", viaSignal, router.orders, c.Trades)
+		}
+		for i, o := range router.orders {
+			tr := c.Trades[i]
+			if bar := hist["A"][o.Day]; tr.Date != bar.Date || tr.Price != bar.Open || o.Amount != tr.Amount {
+				t.Errorf("signal %v: order %+v filled as %+v, want at day %d's open", viaSignal, o, tr, o.Day)
+			}
+		}
+	}
+
+	// And a router that changes orders applies to them once.
+	p, err := NewPortfolio("p", 10_000, []string{"A"}, "greedy", WithOrderRouter(halfRouter{}), WithFill(FillNextOpen, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Strategy = &signalStrategy{}
+	runOne(context.Background(), c, hist, nil)
+	if len(c.Trades) != 2 || c.Trades[0].Amount != 5 || c.Trades[1].Amount != 5 {
+		t.Errorf("trades = %+v, want two of 5 shares", c.Trades)
+	}
+}
+
+// recordingRouter notes every order it routes before filling it.
+type recordingRouter struct{ orders []OrderEvent }
+
+func (r *recordingRouter) Route(p *Portfolio, o OrderEvent, hist map[string][]data.AssetData) error {
+	r.orders = append(r.orders, o)
+	return SimRouter{}.Route(p, o, hist)
+}
+
+// A built-in strategy's orders reach a custom router, one per trade.
+func TestEngine_RouterSeesBuiltInStrategy(t *testing.T) {
+	tickers, hist := generateBenchData()
+	router := &recordingRouter{}
+	p, err := NewPortfolio("sma", 100_000, tickers, "smaCross:5:20:equalWeights", WithOrderRouter(router))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runOne(context.Background(), p, hist, nil)
+	if len(p.Trades) == 0 || len(router.orders) != len(p.Trades) {
+		t.Fatalf("routed %d orders for %d trades", len(router.orders), len(p.Trades))
+	}
+	for i, o := range router.orders {
+		if tr := p.Trades[i]; o.Ticker != tr.Ticker || o.Side != tr.Side || o.Amount != tr.Amount {
+			t.Errorf("order %d = %+v, trade %+v", i, o, tr)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package backtest_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"my-backtester/src/backtest"
+	"my-backtester/src/data"
+	"os"
+)
+
+// Embedding the engine: open the database, build portfolios from config
+// text, run them and print the JSON results.
+func Example() {
+	store, err := data.Open("stock_data.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	cfg, err := backtest.ParseConfig(`
+[[portfolio]]
+Name = "spy"
+BuyingPower = 10000
+StartDate = "2020-01-01"
+EndDate = "2023-01-01"
+Tickers = ["SPY"]
+Strategy = "smaCross:20:50:greedy"
+`, "toml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	portfolios := make([]*backtest.Portfolio, 0, len(cfg.Portfolios))
+	for _, pc := range cfg.Portfolios {
+		p, err := pc.ToPortfolio()
+		if err != nil {
+			log.Fatal(err)
+		}
+		portfolios = append(portfolios, p)
+	}
+	results, err := backtest.Run(context.Background(), store, portfolios, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range results {
+		fmt.Printf("%s: Sharpe %.2f\n", r.PortfolioName, r.Metrics.SharpeRatio)
+	}
+	if err := backtest.WriteResultsJSON(os.Stdout, results); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// The same run from config text, as the desktop UI does it.
+func ExampleRunFromConfigText() {
+	results, err := backtest.RunFromConfigText(context.Background(), `
+[[portfolio]]
+Name = "spy"
+BuyingPower = 10000
+StartDate = "2020-01-01"
+EndDate = "2023-01-01"
+Tickers = ["SPY"]
+Strategy = "buyAndHold:greedy"
+`, "stock_data.db", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(results))
+}
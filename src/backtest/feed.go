@@ -0,0 +1,159 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"time"
+)
+
+// FeedEvent is one time step of a Feed: the newest bar for each
+// subscribed ticker.
+type FeedEvent struct {
+	Date time.Time
+	Bars map[string]data.AssetData
+	// Quote marks bars that are quotes of a session still trading, as
+	// LiveFeed's are, rather than finished bars. FeedTrader folds a
+	// session's quotes into one bar and steps it once the session ends.
+	Quote bool
+}
+
+// Feed delivers bars in time order. FeedTrader drives a strategy from any
+// Feed, so historical replay and live trading run the exact Step /
+// AdjustPortfolioParameters sequence that runOne runs for batch backtests.
+type Feed interface {
+	// Subscribe sets the tickers every subsequent event carries.
+	Subscribe(tickers []string) error
+	// Next blocks until the next event. ok is false once the feed is
+	// exhausted or ctx is done.
+	Next(ctx context.Context) (ev FeedEvent, ok bool)
+}
+
+// ReplayFeed replays stored history. Events step through the dates every
+// subscribed ticker has a bar on, matching runOne's day indexing (see
+// alignWindow). A non-zero Delay sleeps between events to simulate a
+// live session.
+type ReplayFeed struct {
+	Delay time.Duration
+
+	hist    map[string][]data.AssetData
+	tickers []string
+	pos     int
+}
+
+func NewReplayFeed(hist map[string][]data.AssetData, delay time.Duration) *ReplayFeed {
+	return &ReplayFeed{hist: hist, Delay: delay}
+}
+
+func (f *ReplayFeed) Subscribe(tickers []string) error {
+	if len(tickers) == 0 {
+		return fmt.Errorf("replay feed: no tickers")
+	}
+	for _, t := range tickers {
+		if _, ok := f.hist[t]; !ok {
+			return fmt.Errorf("replay feed: no history for %s", t)
+		}
+	}
+	f.tickers = tickers
+	f.hist = alignWindow(tickers, f.hist, time.Time{}, time.Time{}, nil)
+	return nil
+}
+
+func (f *ReplayFeed) Next(ctx context.Context) (FeedEvent, bool) {
+	lead := f.hist[f.tickers[0]]
+	if f.pos >= len(lead) || ctx.Err() != nil {
+		return FeedEvent{}, false
+	}
+	if f.pos > 0 && f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-ctx.Done():
+			return FeedEvent{}, false
+		}
+	}
+	ev := FeedEvent{
+		Date: lead[f.pos].Date,
+		Bars: make(map[string]data.AssetData, len(f.tickers)),
+	}
+	for _, t := range f.tickers {
+		if series := f.hist[t]; f.pos < len(series) {
+			ev.Bars[t] = series[f.pos]
+		}
+	}
+	f.pos++
+	return ev, true
+}
+
+// LiveFeed polls a QuoteSource every Interval and emits an event whenever
+// any subscribed ticker's quote is newer than the last event. A failed
+// quote skips the whole poll so every ticker advances together. Its
+// events are Quotes: several may fall in one session.
+type LiveFeed struct {
+	Quotes   data.QuoteSource
+	Interval time.Duration
+	Clock    Clock // times the polls; RealClock by default
+
+	tickers []string
+	last    time.Time
+	polled  bool
+}
+
+func NewLiveFeed(quotes data.QuoteSource, interval time.Duration) *LiveFeed {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &LiveFeed{Quotes: quotes, Interval: interval, Clock: RealClock{}}
+}
+
+func (f *LiveFeed) Subscribe(tickers []string) error {
+	if len(tickers) == 0 {
+		return fmt.Errorf("live feed: no tickers")
+	}
+	f.tickers = tickers
+	return nil
+}
+
+func (f *LiveFeed) Next(ctx context.Context) (FeedEvent, bool) {
+	for {
+		if f.polled {
+			select {
+			case <-ctx.Done():
+				return FeedEvent{}, false
+			case <-f.Clock.After(f.Interval):
+			}
+		}
+		f.polled = true
+		if ctx.Err() != nil {
+			return FeedEvent{}, false
+		}
+		if ev, ok := f.Poll(ctx); ok {
+			return ev, true
+		}
+	}
+}
+
+// Poll fetches one quote per ticker and reports whether any is newer
+// than the last event returned.
+func (f *LiveFeed) Poll(ctx context.Context) (FeedEvent, bool) {
+	ev := FeedEvent{Bars: make(map[string]data.AssetData, len(f.tickers)), Quote: true}
+	fresh := false
+	for _, t := range f.tickers {
+		bar, err := f.Quotes.Quote(ctx, t)
+		if err != nil {
+			runLogger.Error("live feed", "err", err)
+			return FeedEvent{}, false
+		}
+		if bar.Date.After(f.last) {
+			fresh = true
+		}
+		if bar.Date.After(ev.Date) {
+			ev.Date = bar.Date
+		}
+		ev.Bars[t] = bar
+	}
+	if !fresh {
+		return FeedEvent{}, false
+	}
+	f.last = ev.Date
+	return ev, true
+}
@@ -0,0 +1,79 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A replayed FeedTrader must reproduce a batch runOne exactly: same
+// trades, same equity curve, same metrics.
+func TestReplayFeed_MatchesBatchRun(t *testing.T) {
+	tickers := []string{"AAA", "BBB"}
+	hist := make(map[string][]data.AssetData)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for k, tk := range tickers {
+		var series []data.AssetData
+		for i := 0; i < 60; i++ {
+			c := 100 + float64((i*(k+3))%17) - float64(k*5)
+			series = append(series, data.AssetData{
+				Date: start.AddDate(0, 0, i),
+				Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 1000,
+			})
+		}
+		data.FillReturns(series)
+		hist[tk] = series
+	}
+	newPortfolio := func() *Portfolio {
+		p, err := InitializePortfolio(
+			10_000, start, start.AddDate(0, 0, 60), "p", tickers,
+			"smaCross:3:8:equalWeights", nil,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	batch := newPortfolio()
+	runOne(context.Background(), batch, hist, nil)
+
+	replayed := newPortfolio()
+	ft, err := NewFeedTrader(replayed, NewReplayFeed(hist, 0), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft.Run(context.Background())
+
+	if !reflect.DeepEqual(batch.Trades, replayed.Trades) {
+		t.Errorf("trades differ:\nbatch  %+v\nreplay %+v", batch.Trades, replayed.Trades)
+	}
+	if !reflect.DeepEqual(batch.PortfolioCloseValues, replayed.PortfolioCloseValues) {
+		t.Errorf("equity differs:\nbatch  %v\nreplay %v",
+			batch.PortfolioCloseValues, replayed.PortfolioCloseValues)
+	}
+	if len(batch.Trades) == 0 {
+		t.Error("strategy never traded; test data too flat")
+	}
+}
+
+func TestReplayFeed_CancelEndsEarly(t *testing.T) {
+	hist := map[string][]data.AssetData{"A": make([]data.AssetData, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	f := NewReplayFeed(hist, time.Hour)
+	if err := f.Subscribe([]string{"A"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Next(ctx); !ok {
+		t.Fatal("first event should not wait")
+	}
+	cancel()
+	if _, ok := f.Next(ctx); ok {
+		t.Error("Next after cancel should end the replay")
+	}
+	if err := f.Subscribe([]string{"missing"}); err == nil {
+		t.Error("expected error subscribing to unknown ticker")
+	}
+}
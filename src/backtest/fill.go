@@ -0,0 +1,165 @@
+package backtest
+
+import (
+	"fmt"
+	"my-backtester/src/data"
+)
+
+// FillModel decides the price a strategy's orders fill at. It is set per
+// portfolio (FillPrice in the config) so every strategy fills the same
+// way instead of inventing its own price, and is recorded on the Result.
+type FillModel string
+
+const (
+	// FillClose fills on the signal bar at its close. The default.
+	FillClose FillModel = "close"
+	// FillNextOpen queues orders and fills them at the next bar's open,
+	// so a signal computed from a bar's close never trades at that close.
+	FillNextOpen FillModel = "next_open"
+	// FillTypical fills on the signal bar at (high + low + close) / 3.
+	FillTypical FillModel = "typical"
+	// FillVWAP fills on the signal bar at the volume-weighted typical
+	// price of the trailing FillWindow bars (default 1), falling back to
+	// the typical price when those bars carry no volume.
+	FillVWAP FillModel = "vwap"
+)
+
+// ParseFillModel validates a FillPrice config value; "" selects FillClose.
+func ParseFillModel(s string) (FillModel, error) {
+	switch m := FillModel(s); m {
+	case "":
+		return FillClose, nil
+	case FillClose, FillNextOpen, FillTypical, FillVWAP:
+		return m, nil
+	}
+	return "", fmt.Errorf("fill price %q: must be close, next_open, typical, or vwap", s)
+}
+
+// pendingOrder is an order waiting for the next bar under FillNextOpen.
+type pendingOrder struct {
+	Ticker string
+	Side   string
+	Amount float64
+}
+
+func typicalPrice(b data.AssetData) float64 {
+	return (b.High + b.Low + b.Close) / 3
+}
+
+// FillPrice returns the price an order for ticker placed on day fills at
+// under the portfolio's FillModel, or 0 if there is no bar. Under
+// FillNextOpen the real price is unknown until the next bar, so the
+// day's close is returned as the sizing estimate.
+func (p *Portfolio) FillPrice(
+	ticker string, hist map[string][]data.AssetData, day int,
+) float64 {
+	series := hist[ticker]
+	if day < 0 || day >= len(series) {
+		return 0
+	}
+	bar := series[day]
+	switch p.Fill {
+	case FillTypical:
+		return typicalPrice(bar)
+	case FillVWAP:
+		window := p.FillWindow
+		if window < 1 {
+			window = 1
+		}
+		if window > day+1 {
+			window = day + 1
+		}
+		pv, vol := 0.0, 0.0
+		for i := day - window + 1; i <= day; i++ {
+			pv += typicalPrice(series[i]) * series[i].Volume
+			vol += series[i].Volume
+		}
+		if vol == 0 {
+			return typicalPrice(bar)
+		}
+		return pv / vol
+	}
+	return bar.Close
+}
+
+// Order places a buy or sell of amount shares of ticker from a strategy's
+// Step on day, filling according to the portfolio's FillModel, and
+// returns the Buy or Sell error. Under FillNextOpen the order is
+// validated and queued for FillPending; it can still be rejected there.
+// During a run the order goes through the portfolio's OrderRouter, which
+// returns its error instead; under FillNextOpen it does so at the open.
+func (p *Portfolio) Order(
+	ticker, side string, amount float64,
+	hist map[string][]data.AssetData, day int,
+) error {
+	if p.engine != nil && !p.routing {
+		return p.engine.route(OrderEvent{Day: day, Ticker: ticker, Side: side, Amount: amount}, hist)
+	}
+	if p.opening {
+		return p.fillAtOpen(ticker, side, amount, hist, day)
+	}
+	if p.Fill == FillNextOpen {
+		return p.queue(ticker, side, amount)
+	}
+	if err := p.checkLookahead(ticker, day); err != nil {
+		return err
+	}
+	if err := p.checkSameBarFill(ticker, day); err != nil {
+		return err
+	}
+	price := p.FillPrice(ticker, hist, day)
+	if err := validateOrder(side, ticker, amount, price); err != nil {
+		return err
+	}
+	date := hist[ticker][day].Date
+	if side == "SELL" {
+		return p.Sell(ticker, amount, price, date)
+	}
+	return p.Buy(ticker, amount, price, date)
+}
+
+// queue validates an order and holds it for FillPending.
+func (p *Portfolio) queue(ticker, side string, amount float64) error {
+	// The open is unknown yet; 1 stands in for a valid price.
+	if err := validateOrder(side, ticker, amount, 1); err != nil {
+		return err
+	}
+	p.pending = append(p.pending, pendingOrder{ticker, side, amount})
+	return nil
+}
+
+// FillPending executes orders queued under FillNextOpen at day's open,
+// through the OrderRouter during a run, as Order does. The runner calls
+// it before stepping the strategy on day.
+func (p *Portfolio) FillPending(hist map[string][]data.AssetData, day int) {
+	if len(p.pending) == 0 {
+		return
+	}
+	orders := p.pending
+	p.pending = nil
+	p.opening = true
+	defer func() { p.opening = false }()
+	for _, o := range orders {
+		if err := p.Order(o.Ticker, o.Side, o.Amount, hist, day); err != nil {
+			logger.Warn("pending order failed", "portfolio", p.Pname, "day", day, "err", err)
+		}
+	}
+}
+
+// fillAtOpen fills a queued order at day's open. A buy the portfolio can
+// no longer afford after an overnight gap is cut to the whole shares it
+// can afford.
+func (p *Portfolio) fillAtOpen(ticker, side string, amount float64, hist map[string][]data.AssetData, day int) error {
+	series := hist[ticker]
+	if day >= len(series) {
+		return &OrderError{side, ticker, amount, 0, fmt.Errorf("no bar on day %d", day)}
+	}
+	bar := series[day]
+	if side == "SELL" {
+		return p.Sell(ticker, amount, bar.Open, bar.Date)
+	}
+	if price, fee := p.execute("BUY", ticker, amount, bar.Open); !p.canAfford(amount*price + fee) {
+		amount = p.maxBuy(ticker, bar.Open, "greedy")
+	}
+	return p.Buy(ticker, amount, bar.Open, bar.Date)
+}
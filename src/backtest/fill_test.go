@@ -0,0 +1,56 @@
+package backtest
+
+import (
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func fillTestHist() map[string][]data.AssetData {
+	d := func(i int) time.Time { return time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	return map[string][]data.AssetData{"A": {
+		{Date: d(0), Open: 9, High: 12, Low: 8, Close: 10, Volume: 100},
+		{Date: d(1), Open: 11, High: 14, Low: 10, Close: 12, Volume: 300},
+	}}
+}
+
+func TestFillPrice_Models(t *testing.T) {
+	hist := fillTestHist()
+	for _, c := range []struct {
+		model  FillModel
+		window int
+		want   float64
+	}{
+		{FillClose, 0, 12},
+		{FillNextOpen, 0, 12}, // sizing estimate only
+		{FillTypical, 0, 12},
+		{FillVWAP, 1, 12},
+		{FillVWAP, 2, (10*100 + 12*300) / 400.0},
+	} {
+		p := &Portfolio{Fill: c.model, FillWindow: c.window}
+		if got := p.FillPrice("A", hist, 1); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s/%d: price = %v, want %v", c.model, c.window, got, c.want)
+		}
+	}
+	if _, err := ParseFillModel("mid"); err == nil {
+		t.Error("expected error for unknown model")
+	}
+}
+
+func TestOrder_NextOpenFillsOnFollowingBar(t *testing.T) {
+	hist := fillTestHist()
+	p := &Portfolio{
+		BuyingPower: 100, Positions: map[string]*Position{}, Fill: FillNextOpen,
+	}
+	p.Order("A", "BUY", 10, hist, 0)
+	if len(p.Trades) != 0 {
+		t.Fatalf("next_open filled on the signal bar: %+v", p.Trades)
+	}
+	p.FillPending(hist, 1)
+	// 10 @ 11 costs 110 > 100 cash, so the gap cuts it to 9 shares.
+	want := Trade{Date: hist["A"][1].Date, Ticker: "A", Side: "BUY", Amount: 9, Price: 11}
+	if len(p.Trades) != 1 || p.Trades[0] != want {
+		t.Errorf("trades = %+v, want [%+v]", p.Trades, want)
+	}
+}
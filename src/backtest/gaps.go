@@ -0,0 +1,151 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"my-backtester/src/data"
+	"time"
+)
+
+// DataGaps is how a portfolio is simulated over dates some of its
+// tickers have no bar on: a ticker that listed late, skipped a day or
+// stopped trading part way through the window.
+type DataGaps string
+
+const (
+	// GapsSkip simulates only the dates every ticker has a bar on (see
+	// alignWindow), so a delisting ends the run early.
+	GapsSkip DataGaps = "skip"
+	// GapsForwardFill simulates every date any ticker has a bar on (see
+	// alignUnion). A missing bar repeats the ticker's last close, and a
+	// ticker whose bars stop before the run ends is delisted: a position
+	// in it is closed at its last close on the next bar, less the
+	// DelistingHaircut, and it can't be traded again.
+	GapsForwardFill DataGaps = "forward_fill"
+	// GapsAbort skips the portfolio, as for data that couldn't be read,
+	// if any of its tickers lacks a bar on a date another has one on.
+	GapsAbort DataGaps = "abort"
+)
+
+// ErrDataGap is the DataError of a GapsAbort portfolio with a gap.
+var ErrDataGap = errors.New("missing bar")
+
+// ParseDataGaps validates a DataGaps config value. "" is left for the
+// portfolio to choose: GapsForwardFill with an index universe, where
+// members come and go, and GapsSkip otherwise.
+func ParseDataGaps(s string) (DataGaps, error) {
+	switch g := DataGaps(s); g {
+	case "", GapsSkip, GapsForwardFill, GapsAbort:
+		return g, nil
+	}
+	return "", fmt.Errorf("data gaps %q: must be skip, forward_fill or abort", s)
+}
+
+// WithDataGaps sets how p treats missing bars, and with GapsForwardFill
+// the fraction, in [0, 1), of a delisted position's last close lost
+// closing it: a long is sold that much below it and a short covered that
+// much above.
+func WithDataGaps(g DataGaps, haircut float64) Option {
+	return func(p *Portfolio) error {
+		if _, err := ParseDataGaps(string(g)); err != nil {
+			return err
+		}
+		if !(haircut >= 0 && haircut < 1) {
+			return fmt.Errorf("delisting haircut %v: must be in [0, 1)", haircut)
+		}
+		p.DataGaps, p.DelistingHaircut = g, haircut
+		return nil
+	}
+}
+
+// gaps is p's DataGaps with "" resolved.
+func (p *Portfolio) gaps() DataGaps {
+	switch {
+	case p.DataGaps != "":
+		return p.DataGaps
+	case p.Universe != nil && p.Universe.Index != "":
+		return GapsForwardFill
+	}
+	return GapsSkip
+}
+
+// checkGaps returns the DataError for p's first missing bar in hist if
+// it aborts on gaps, judged on the daily bars in its window.
+func (p *Portfolio) checkGaps(hist map[string][]data.AssetData) *DataError {
+	if p.gaps() != GapsAbort {
+		return nil
+	}
+	ticker, date, ok := firstGap(p.Tickers, hist, p.StartTime, p.EndTime, p.Calendar.sessionFilter())
+	if !ok {
+		return nil
+	}
+	return &DataError{ticker, fmt.Errorf("%w on %s, and portfolio %s aborts on gaps", ErrDataGap, formatDate(date), p.Pname)}
+}
+
+// delisting is the bar a delisted ticker is liquidated on and the last
+// close it is liquidated at.
+type delisting struct {
+	bar   int
+	close float64
+}
+
+// delist finds the tickers in aligned, p's forward-filled bars, whose
+// own bars in full stop before aligned's last date, records the first
+// bar after as the one each is liquidated on, and blanks the bars from
+// then on so FillPrice won't trade them. Only those series are copied.
+func (p *Portfolio) delist(full, aligned map[string][]data.AssetData, start, end time.Time) {
+	p.delisted = nil
+	lead := aligned[p.Tickers[0]]
+	if len(lead) == 0 {
+		return
+	}
+	for _, t := range p.Tickers {
+		own := clipSeries(full[t], start, end)
+		if len(own) == 0 {
+			continue
+		}
+		last := own[len(own)-1].Date
+		day := len(lead)
+		for day > 0 && lead[day-1].Date.After(last) {
+			day--
+		}
+		if day == len(lead) {
+			continue
+		}
+		series := append([]data.AssetData(nil), aligned[t]...)
+		if p.delisted == nil {
+			p.delisted = make(map[string]delisting)
+		}
+		p.delisted[t] = delisting{day, series[day].Close}
+		for i := day; i < len(series); i++ {
+			series[i] = data.AssetData{Date: series[i].Date}
+		}
+		aligned[t] = series
+	}
+}
+
+// liquidateDelisted closes any position in a ticker delisted on bar day
+// at its last close, less the DelistingHaircut, noting the trade as
+// "delisted".
+func (p *Portfolio) liquidateDelisted(hist map[string][]data.AssetData, day int) {
+	for _, t := range p.Tickers {
+		d, ok := p.delisted[t]
+		if !ok || d.bar != day {
+			continue
+		}
+		pos, ok := p.Positions[t]
+		if !ok || pos.Amount == 0 {
+			continue
+		}
+		last := d.close
+		price := last * (1 - p.DelistingHaircut)
+		if pos.Amount < 0 {
+			price = last * (1 + p.DelistingHaircut)
+		}
+		date := hist[t][day].Date
+		p.txLog().Debug("DELISTED", "portfolio", p.Pname, "ticker", t, "price", price, "date", formatDate(date))
+		p.note = "delisted"
+		p.flatten(t, price, date)
+		p.note = ""
+	}
+}
@@ -0,0 +1,92 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"my-backtester/src/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// gapStore has A trading every day of Jan 1-8 and B, at 20, delisted
+// after Jan 4th.
+func gapStore() *fakeStore {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	bars := map[string][]data.AssetData{}
+	for i := 0; i < 8; i++ {
+		bars["A"] = append(bars["A"], data.AssetData{Date: day(i), Open: 10, High: 10, Low: 10, Close: 10, Volume: 100})
+		if i < 4 {
+			bars["B"] = append(bars["B"], data.AssetData{Date: day(i), Open: 20, High: 20, Low: 20, Close: 20, Volume: 100})
+		}
+	}
+	return &fakeStore{bars: bars, rates: map[int64]float64{}}
+}
+
+func TestDataGaps_ForwardFillDelists(t *testing.T) {
+	p, err := NewPortfolio("ff", 1000, []string{"A", "B"}, "rebalance:100",
+		WithWindow(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)),
+		WithDataGaps(GapsForwardFill, 0.25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), gapStore(), []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if r.EffectiveEnd != "2024-01-08" {
+		t.Errorf("run ended %s, want B's delisting not to shorten it", r.EffectiveEnd)
+	}
+	// 50 A and 25 B bought on the first bar; B's 25 are closed on the
+	// bar after its last, at 20 less the 25% haircut.
+	var got []Trade
+	for _, tr := range r.Trades {
+		tr.Fee = 0
+		got = append(got, tr)
+	}
+	want := []Trade{
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Ticker: "A", Side: "BUY", Amount: 50, Price: 10},
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Ticker: "B", Side: "BUY", Amount: 25, Price: 20},
+		{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Ticker: "B", Side: "SELL", Amount: 25, Price: 15, Note: "delisted"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("trades = %+v, want %+v", got, want)
+	}
+	if v := r.EquityCurve[len(r.EquityCurve)-1]; v != 875 {
+		t.Errorf("final value = %v, want 500 in A and 375 cash", v)
+	}
+}
+
+func TestDataGaps_SkipAndAbort(t *testing.T) {
+	window := WithWindow(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC))
+	skip, err := NewPortfolio("skip", 1000, []string{"A", "B"}, "rebalance:100", window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abort, err := NewPortfolio("abort", 1000, []string{"A", "B"}, "rebalance:100", window, WithDataGaps(GapsAbort, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), gapStore(), []*Portfolio{skip, abort}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].PortfolioName != "skip" {
+		t.Fatalf("ran %d portfolios, want only skip", len(results))
+	}
+	if end := results[0].EffectiveEnd; end != "2024-01-04" {
+		t.Errorf("skip ended %s, want B's last bar", end)
+	}
+
+	gap := abort.checkGaps(gapStore().bars)
+	if gap == nil || gap.Ticker != "B" || !errors.Is(gap, ErrDataGap) {
+		t.Errorf("gap = %v, want B's missing bar", gap)
+	}
+	if _, err := ParseDataGaps("interpolate"); err == nil {
+		t.Error("ParseDataGaps accepted interpolate")
+	}
+	if _, err := NewPortfolio("x", 1000, nil, "greedy", WithDataGaps(GapsForwardFill, 1)); err == nil {
+		t.Error("WithDataGaps accepted a 100% haircut")
+	}
+}
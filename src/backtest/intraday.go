@@ -0,0 +1,57 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"time"
+)
+
+// IntradayStore is a Store that also holds intraday bars, such as
+// *data.Store's intraday_bars table. Portfolios with an Interval read
+// their bars from it rather than the daily ones Run loads for everyone.
+type IntradayStore interface {
+	QueryIntraday(ctx context.Context, tickers []string, interval time.Duration, start, end time.Time) (map[string][]data.AssetData, error)
+}
+
+// WithInterval simulates bars of interval, such as 5 minutes, read from
+// an IntradayStore; 0 keeps daily bars. Metrics annualize by the bars in
+// a year: the Calendar's sessions times the bars in its SessionLength.
+func WithInterval(interval time.Duration) Option {
+	return func(p *Portfolio) error {
+		if interval < 0 || interval >= 24*time.Hour {
+			return fmt.Errorf("bar interval %s: must be under a day", interval)
+		}
+		p.Interval = interval
+		return nil
+	}
+}
+
+// windowEnd is the last instant of p's window: for intraday bars an
+// EndTime at midnight takes in that whole day.
+func (p *Portfolio) windowEnd() time.Time {
+	end := p.EndTime
+	if p.Interval > 0 && !end.IsZero() && end.Equal(end.Truncate(24*time.Hour)) {
+		end = end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return end
+}
+
+// loadIntraday reads the intraday bars of p's tickers and benchmark over
+// its window from p's store, logging why there are none if it can't.
+func (p *Portfolio) loadIntraday(ctx context.Context) map[string][]data.AssetData {
+	is, ok := p.store.(IntradayStore)
+	if !ok {
+		runLogger.Error("intraday bars: store has none", "portfolio", p.Pname, "interval", p.Interval)
+		return nil
+	}
+	tickers := p.Tickers
+	if p.Benchmark != "" && p.BenchmarkSource == nil {
+		tickers = append(tickers[:len(tickers):len(tickers)], p.Benchmark)
+	}
+	hist, err := is.QueryIntraday(ctx, tickers, p.Interval, p.StartTime, p.windowEnd())
+	if err != nil && ctx.Err() == nil {
+		runLogger.Error("intraday bars", "portfolio", p.Pname, "interval", p.Interval, "err", err)
+	}
+	return hist
+}
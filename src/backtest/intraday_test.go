@@ -0,0 +1,58 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// intradayStore serves its bars as intraday ones of any interval.
+type intradayStore struct {
+	*fakeStore
+	intraday map[string][]data.AssetData
+}
+
+func (s intradayStore) QueryIntraday(
+	ctx context.Context, tickers []string, interval time.Duration, start, end time.Time,
+) (map[string][]data.AssetData, error) {
+	return (&fakeStore{bars: s.intraday}).QueryAssetsForTickers(ctx, tickers, start, end), nil
+}
+
+func TestIntraday(t *testing.T) {
+	var bars []data.AssetData
+	price := 100.0
+	for _, day := range []int{2, 3} {
+		open := time.Date(2024, 1, day, 9, 30, 0, 0, time.UTC)
+		for bar := open; bar.Before(open.Add(390 * time.Minute)); bar = bar.Add(5 * time.Minute) {
+			bars = append(bars, data.AssetData{Date: bar, Open: price, High: price, Low: price, Close: price, Volume: 100})
+			price += 0.01
+		}
+	}
+	store := intradayStore{&fakeStore{rates: map[int64]float64{}}, map[string][]data.AssetData{"A": bars}}
+	p, err := NewPortfolio("5m", 1000, []string{"A"}, "greedy", WithInterval(5*time.Minute),
+		WithWindow(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	// The whole of the window's last day is simulated, 78 bars a day.
+	if len(r.Dates) != 155 || r.Dates[0] != "2024-01-02 09:35:00" || r.EffectiveEnd != "2024-01-03 15:55:00" {
+		t.Errorf("returns %d, from %s, ending %s", len(r.Dates), r.Dates[0], r.EffectiveEnd)
+	}
+	if n := p.barsPerSession(); n != 78 {
+		t.Errorf("bars per session = %v, want 78", n)
+	}
+	// About 0.01% a bar compounds over 252*78 bars a year to some 600%.
+	if a := r.Metrics.AnnualReturn; a < 500 || a > 700 {
+		t.Errorf("annual return = %v, want 5-minute bars annualized", a)
+	}
+
+	if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithInterval(24*time.Hour)); err == nil {
+		t.Error("WithInterval accepted a day")
+	}
+}
@@ -0,0 +1,115 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// LotMethod selects which shares a closing fill takes from a position
+// bought (or shorted) over several fills, and so each ClosedTrade's
+// entry date, entry price and fees.
+type LotMethod string
+
+const (
+	// LotAverage closes shares at the position's average cost, dated
+	// from the fill that opened it: one ClosedTrade per closing fill. The
+	// default.
+	LotAverage LotMethod = "average"
+	// LotFIFO closes the oldest lots first, LotLIFO the newest; a closing
+	// fill that spans several lots records a ClosedTrade for each.
+	LotFIFO LotMethod = "fifo"
+	LotLIFO LotMethod = "lifo"
+)
+
+// ParseLotMethod validates a LotMethod config value; "" selects
+// LotAverage.
+func ParseLotMethod(s string) (LotMethod, error) {
+	switch m := LotMethod(s); m {
+	case "":
+		return LotAverage, nil
+	case LotAverage, LotFIFO, LotLIFO:
+		return m, nil
+	}
+	return "", fmt.Errorf("lot method %q: must be average, fifo or lifo", s)
+}
+
+// Lot is the open part of one opening fill. Amount is unsigned; the
+// position's sign says long or short. Fees are the fill's fees not yet
+// charged to a ClosedTrade.
+type Lot struct {
+	Opened time.Time
+	Amount float64
+	Price  float64
+	Fees   float64
+}
+
+// tracksLots reports whether p keeps each position's Lots.
+func (p *Portfolio) tracksLots() bool {
+	return p.LotMethod == LotFIFO || p.LotMethod == LotLIFO
+}
+
+// closeLots records closing shares of pos, on ticker, sold (or covered)
+// at price on date for closeFee, as ClosedTrades per p.LotMethod, and
+// takes their entry fees, and under FIFO or LIFO their lots, out of pos.
+// The caller adjusts pos.Amount.
+func (p *Portfolio) closeLots(ticker string, pos *Position, closing, price, closeFee float64, date time.Time) {
+	short := pos.Amount < 0
+	if !p.tracksLots() || len(pos.Lots) == 0 {
+		entryFees := pos.Fees * closing / math.Abs(pos.Amount)
+		pos.Fees -= entryFees
+		p.ClosedTrades = append(p.ClosedTrades,
+			closedTrade(ticker, pos.Opened, date, closing, pos.AveragePrice, price, entryFees+closeFee, short))
+		return
+	}
+	for closing > 0 && len(pos.Lots) > 0 {
+		i := 0
+		if p.LotMethod == LotLIFO {
+			i = len(pos.Lots) - 1
+		}
+		lot := &pos.Lots[i]
+		n := math.Min(closing, lot.Amount)
+		entryFees := lot.Fees * n / lot.Amount
+		exitFee := closeFee * n / closing
+		p.ClosedTrades = append(p.ClosedTrades,
+			closedTrade(ticker, lot.Opened, date, n, lot.Price, price, entryFees+exitFee, short))
+		lot.Amount -= n
+		lot.Fees -= entryFees
+		closing -= n
+		closeFee -= exitFee
+		if lot.Amount <= 0 {
+			pos.Lots = append(pos.Lots[:i], pos.Lots[i+1:]...)
+		}
+	}
+	// The position's figures follow the lots left open.
+	var shares, cost, fees float64
+	for _, l := range pos.Lots {
+		shares += l.Amount
+		cost += l.Amount * l.Price
+		fees += l.Fees
+	}
+	pos.Fees = fees
+	if shares > 0 {
+		pos.AveragePrice = cost / shares
+		pos.Opened = pos.Lots[0].Opened
+	}
+}
+
+// closedTrade is a round trip of amount shares opened at entryPrice on
+// entry and closed at exitPrice on exit, charged fees in total.
+func closedTrade(ticker string, entry, exit time.Time, amount, entryPrice, exitPrice, fees float64, short bool) ClosedTrade {
+	gain := (exitPrice - entryPrice) * amount
+	if short {
+		gain = -gain
+	}
+	c := ClosedTrade{
+		Ticker: ticker, Entry: entry, Exit: exit, Amount: amount,
+		EntryPrice: entryPrice, ExitPrice: exitPrice,
+		PnL: gain - fees, Short: short,
+		HoldingDays: exit.Sub(entry).Hours() / 24,
+	}
+	if basis := entryPrice * amount; basis > 0 {
+		c.Return = c.PnL / basis
+	}
+	return c
+}
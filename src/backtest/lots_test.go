@@ -0,0 +1,97 @@
+package backtest
+
+import (
+	"my-backtester/src/data"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Two buys at 10 and 20, then one sell of 15 at 25 that spans both lots
+// under FIFO and LIFO, with a $1 commission per fill.
+func TestLotMethods(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		method LotMethod
+		want   []ClosedTrade
+		avg    float64 // of the 5 shares left
+		opened time.Time
+	}{
+		{LotAverage, []ClosedTrade{
+			{Entry: day(0), Amount: 15, EntryPrice: 15, PnL: 150 - 1 - 1.5},
+		}, 15, day(0)},
+		{LotFIFO, []ClosedTrade{
+			{Entry: day(0), Amount: 10, EntryPrice: 10, PnL: 150 - 1 - 1.0/1.5},
+			{Entry: day(10), Amount: 5, EntryPrice: 20, PnL: 25 - 0.5 - 0.5/1.5},
+		}, 20, day(10)},
+		{LotLIFO, []ClosedTrade{
+			{Entry: day(10), Amount: 10, EntryPrice: 20, PnL: 50 - 1 - 1.0/1.5},
+			{Entry: day(0), Amount: 5, EntryPrice: 10, PnL: 75 - 0.5 - 0.5/1.5},
+		}, 10, day(0)},
+	} {
+		t.Run(string(tc.method), func(t *testing.T) {
+			p, err := NewPortfolio("p", 10000, []string{"A"}, "greedy", WithCommission(1), WithLotMethod(tc.method))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, err := range []error{
+				p.Buy("A", 10, 10, day(0)),
+				p.Buy("A", 10, 20, day(10)),
+				p.Sell("A", 15, 25, day(400)),
+			} {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(p.ClosedTrades) != len(tc.want) {
+				t.Fatalf("closed trades = %+v", p.ClosedTrades)
+			}
+			for i, want := range tc.want {
+				got := p.ClosedTrades[i]
+				if !got.Entry.Equal(want.Entry) || got.Amount != want.Amount || got.EntryPrice != want.EntryPrice ||
+					!closeTo(got.PnL, want.PnL) || got.ExitPrice != 25 || got.HoldingDays != day(400).Sub(want.Entry).Hours()/24 ||
+					!closeTo(got.Return, want.PnL/(want.EntryPrice*want.Amount)) {
+					t.Errorf("closed trade %d = %+v, want %+v", i, got, want)
+				}
+			}
+			pos := p.Positions["A"]
+			if pos.Amount != 5 || pos.AveragePrice != tc.avg || !pos.Opened.Equal(tc.opened) || !closeTo(pos.Fees, 0.5) {
+				t.Errorf("position = %+v", pos)
+			}
+		})
+	}
+}
+
+// A split scales the open lots like the position.
+func TestLotMethods_Split(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	p, err := NewPortfolio("p", 10000, []string{"A"}, "greedy", WithLotMethod(LotFIFO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Buy("A", 10, 10, day(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Buy("A", 10, 20, day(1)); err != nil {
+		t.Fatal(err)
+	}
+	p.applyAction("A", data.CorporateAction{Split: 2}, day(2))
+	if err := p.Sell("A", 30, 12, day(3)); err != nil {
+		t.Fatal(err)
+	}
+	if c := p.ClosedTrades; len(c) != 2 || c[0].Amount != 20 || c[0].EntryPrice != 5 || c[1].Amount != 10 || c[1].EntryPrice != 10 {
+		t.Errorf("closed trades = %+v", c)
+	}
+	if pos := p.Positions["A"]; pos.Amount != 10 || pos.AveragePrice != 10 || len(pos.Lots) != 1 {
+		t.Errorf("position = %+v", pos)
+	}
+}
+
+func TestParseLotMethod(t *testing.T) {
+	if m, err := ParseLotMethod(""); err != nil || m != LotAverage {
+		t.Errorf(`ParseLotMethod("") = %q, %v`, m, err)
+	}
+	if _, err := ParseLotMethod("hifo"); err == nil || !strings.Contains(err.Error(), "fifo") {
+		t.Errorf("ParseLotMethod(hifo) err = %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"my-backtester/src/data"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Version identifies the engine build in manifests. Release builds set
+// it with -ldflags "-X my-backtester/src/backtest.Version=v1.2.3".
+var Version = "dev"
+
+// ManifestSchemaVersion is bumped whenever Manifest changes
+// incompatibly.
+const ManifestSchemaVersion = 1
+
+// Manifest records everything a backtest's results depend on — the
+// effective config, a fingerprint of every input series and strategy
+// file, and the engine build — together with a hash of each Result, so
+// VerifyManifest can later confirm the run reproduces bit for bit.
+type Manifest struct {
+	SchemaVersion int    `json:"schema_version"`
+	EngineVersion string `json:"engine_version"`
+	GoVersion     string `json:"go_version"`
+	CreatedAt     string `json:"created_at"` // RFC 3339, UTC
+	// Seed is the config's run-wide Seed; each portfolio's own seed is
+	// in Config.
+	Seed int64 `json:"seed"`
+	// Config is the effective config (after environment overrides) as
+	// TOML, with secrets redacted.
+	Config string `json:"config"`
+	// Data has one entry per ticker, then "risk_free" for the Store's
+	// rates and "risk_free/<portfolio>" for each portfolio with its own
+	// RiskFree provider (likewise "<benchmark>/<portfolio>" for
+	// BenchmarkSource).
+	Data  []data.Fingerprint `json:"data"`
+	Files []FileFingerprint  `json:"files"` // Lua scripts and trade lists
+	// DataHash hashes Data and Files together, so two manifests can be
+	// checked for identical inputs at a glance.
+	DataHash string              `json:"data_hash"`
+	Results  []ResultFingerprint `json:"results"`
+}
+
+type FileFingerprint struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// ResultFingerprint hashes the JSON form (ResultJSON) of one Result.
+type ResultFingerprint struct {
+	Portfolio string `json:"portfolio"`
+	Hash      string `json:"hash"`
+}
+
+// EngineVersion is Version plus the VCS revision the binary was built
+// from, when Go recorded one, marked "-dirty" for uncommitted changes.
+func EngineVersion() string {
+	v := Version
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	var rev, dirty string
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && s.Value != "":
+			rev = "+" + s.Value
+		case s.Key == "vcs.modified" && s.Value == "true":
+			dirty = "-dirty"
+		}
+	}
+	if rev == "" {
+		return v
+	}
+	return v + rev + dirty
+}
+
+// ManifestPath is where a run with cfg writes its manifest by default:
+// beside the [Output] file, or "" when the config has none.
+func ManifestPath(cfg *Config) string {
+	if cfg == nil || cfg.Output == nil || cfg.Output.Path == "" {
+		return ""
+	}
+	return cfg.Output.Path + ".manifest.json"
+}
+
+// NewManifest fingerprints the inputs portfolios were run on — reading
+// the same tickers and window Run reads from store — and the results
+// they produced.
+func NewManifest(ctx context.Context, store Store, cfg *Config, portfolios []*Portfolio, results []Result) (*Manifest, error) {
+	cfgText, err := configTOML(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fps, files, err := fingerprintInputs(ctx, store, portfolios)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		SchemaVersion: ManifestSchemaVersion,
+		EngineVersion: EngineVersion(),
+		GoVersion:     runtime.Version(),
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+		Seed:          cfg.Seed,
+		Config:        cfgText,
+		Data:          fps,
+		Files:         files,
+		DataHash:      inputsHash(fps, files),
+	}
+	if m.Results, err = fingerprintResults(results); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// configTOML encodes cfg as TOML that ParseConfig reads back. Secrets
+// encode redacted.
+func configTOML(cfg *Config) (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return "", fmt.Errorf("encode config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func fingerprintInputs(ctx context.Context, store Store, portfolios []*Portfolio) ([]data.Fingerprint, []FileFingerprint, error) {
+	if len(portfolios) == 0 {
+		return nil, nil, fmt.Errorf("no portfolios")
+	}
+	start, end := dateRange(portfolios)
+	tickers := allTickers(portfolios)
+	hist := store.QueryAssetsForTickers(ctx, tickers, start, end)
+	fps := make([]data.Fingerprint, 0, len(tickers)+1)
+	for _, t := range tickers {
+		fps = append(fps, data.FingerprintBars(t, hist[t]))
+	}
+	if usesStoreRiskFree(portfolios) {
+		fps = append(fps, data.FingerprintRates(
+			"risk_free", DBRiskFree{store}.RiskFreeRates(ctx, riskFreeStart(start), end),
+		))
+	}
+	for _, p := range portfolios {
+		if p.RiskFree != nil {
+			fps = append(fps, data.FingerprintRates(
+				"risk_free/"+p.Pname, p.RiskFree.RiskFreeRates(ctx, riskFreeStart(start), end),
+			))
+		}
+		if p.Benchmark != "" && p.BenchmarkSource != nil {
+			fps = append(fps, data.FingerprintBars(
+				p.Benchmark+"/"+p.Pname, p.BenchmarkSource.BenchmarkBars(ctx, p.Benchmark, start, end),
+			))
+		}
+	}
+	// A cancelled query reads short, which must not be recorded (or
+	// reported) as the data having changed.
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var files []FileFingerprint
+	seen := make(map[string]bool)
+	for _, p := range portfolios {
+		kind, path, _ := strings.Cut(p.StrategySpec, ":")
+		if (kind != "lua" && kind != "trades") || path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fingerprint %s: %w", path, err)
+		}
+		sum := sha256.Sum256(b)
+		files = append(files, FileFingerprint{Path: path, SHA256: hex.EncodeToString(sum[:])})
+	}
+	return fps, files, nil
+}
+
+func inputsHash(fps []data.Fingerprint, files []FileFingerprint) string {
+	h := sha256.New()
+	for _, fp := range fps {
+		fmt.Fprintf(h, "%s\x00%d\x00%s\n", fp.Name, fp.Rows, fp.Checksum)
+	}
+	for _, f := range files {
+		fmt.Fprintf(h, "%s\x00%s\n", f.Path, f.SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func fingerprintResults(results []Result) ([]ResultFingerprint, error) {
+	doc := NewResultsDocument(results)
+	out := make([]ResultFingerprint, 0, len(doc.Results))
+	for _, r := range doc.Results {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(b)
+		out = append(out, ResultFingerprint{Portfolio: r.Portfolio, Hash: hex.EncodeToString(sum[:])})
+	}
+	return out, nil
+}
+
+// WriteManifest writes m as indented JSON to path.
+func WriteManifest(path string, m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("manifest %q: %w", path, err)
+	}
+	if m.SchemaVersion != ManifestSchemaVersion {
+		return nil, fmt.Errorf("manifest %q: unsupported schema_version %d", path, m.SchemaVersion)
+	}
+	return &m, nil
+}
+
+// VerifyManifest re-runs m's config against store and reports every way
+// the inputs or results differ from the recorded ones; no differences
+// means the run reproduced bit for bit.
+// Output, broker and webhook settings are ignored so verifying has no
+// side effects. A different engine build is reported but does not by
+// itself fail verification.
+func VerifyManifest(ctx context.Context, store Store, m *Manifest) (diffs []string, notes []string, err error) {
+	cfg, err := ParseConfig(m.Config, "toml")
+	if err != nil {
+		return nil, nil, fmt.Errorf("manifest config: %w", err)
+	}
+	cfg.ApplySeed()
+	portfolios := make([]*Portfolio, 0, len(cfg.Portfolios))
+	for _, pc := range cfg.Portfolios {
+		p, err := pc.ToPortfolio()
+		if err != nil {
+			return nil, nil, fmt.Errorf("portfolio %q: %w", pc.Name, err)
+		}
+		portfolios = append(portfolios, p)
+	}
+
+	if v := EngineVersion(); v != m.EngineVersion {
+		notes = append(notes, fmt.Sprintf("engine %s, manifest recorded %s", v, m.EngineVersion))
+	}
+	if v := runtime.Version(); v != m.GoVersion {
+		notes = append(notes, fmt.Sprintf("built with %s, manifest recorded %s", v, m.GoVersion))
+	}
+
+	fps, files, err := fingerprintInputs(ctx, store, portfolios)
+	if err != nil {
+		return nil, nil, err
+	}
+	diffs = append(diffs, diffFingerprints(m.Data, fps)...)
+	diffs = append(diffs, diffFiles(m.Files, files)...)
+
+	results, err := Run(ctx, store, portfolios, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	got, err := fingerprintResults(results)
+	if err != nil {
+		return nil, nil, err
+	}
+	want := make(map[string]string, len(m.Results))
+	for _, r := range m.Results {
+		want[r.Portfolio] = r.Hash
+	}
+	for _, r := range got {
+		switch h, ok := want[r.Portfolio]; {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("result %s: not in manifest", r.Portfolio))
+		case h != r.Hash:
+			diffs = append(diffs, fmt.Sprintf("result %s: differs", r.Portfolio))
+		}
+		delete(want, r.Portfolio)
+	}
+	for _, r := range m.Results {
+		if _, missing := want[r.Portfolio]; missing {
+			diffs = append(diffs, fmt.Sprintf("result %s: not reproduced", r.Portfolio))
+		}
+	}
+	return diffs, notes, nil
+}
+
+func diffFingerprints(want, got []data.Fingerprint) []string {
+	byName := make(map[string]data.Fingerprint, len(got))
+	for _, fp := range got {
+		byName[fp.Name] = fp
+	}
+	var diffs []string
+	for _, w := range want {
+		g, ok := byName[w.Name]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("data %s: no longer read", w.Name))
+		case g.Rows == w.Rows && g.First == w.First && g.Last == w.Last && g.Checksum != w.Checksum:
+			diffs = append(diffs, fmt.Sprintf("data %s: values changed", w.Name))
+		case g != w:
+			diffs = append(diffs, fmt.Sprintf(
+				"data %s: %d rows %s..%s, manifest recorded %d rows %s..%s",
+				w.Name, g.Rows, g.First, g.Last, w.Rows, w.First, w.Last,
+			))
+		}
+	}
+	return diffs
+}
+
+func diffFiles(want, got []FileFingerprint) []string {
+	byPath := make(map[string]string, len(got))
+	for _, f := range got {
+		byPath[f.Path] = f.SHA256
+	}
+	var diffs []string
+	for _, w := range want {
+		if byPath[w.Path] != w.SHA256 {
+			diffs = append(diffs, fmt.Sprintf("file %s: contents changed", w.Path))
+		}
+	}
+	return diffs
+}
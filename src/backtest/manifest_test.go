@@ -0,0 +1,123 @@
+package backtest
+
+import (
+	"my-backtester/src/data"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigTOML_RoundTripsAndRedacts(t *testing.T) {
+	cfg, err := ParseConfig(`
+[[portfolio]]
+Name = "p"
+Tickers = ["A", "B"]
+Strategy = "smaCross:5:20:greedy"
+BuyingPower = 10000
+StartDate = "2024-01-01"
+EndDate = "2024-06-30"
+
+[Webhook]
+url = "https://hooks.example.com/T0KEN"
+`, "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := configTOML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(text, "T0KEN") {
+		t.Errorf("secret leaked into manifest config:\n%s", text)
+	}
+	back, err := ParseConfig(text, "toml")
+	if err != nil {
+		t.Fatalf("manifest config does not parse: %v\n%s", err, text)
+	}
+	if !reflect.DeepEqual(back.Portfolios, cfg.Portfolios) {
+		t.Errorf("portfolios = %+v, want %+v", back.Portfolios, cfg.Portfolios)
+	}
+}
+
+func TestFingerprintResults_SensitiveToEveryBit(t *testing.T) {
+	r := Result{
+		PortfolioName: "p",
+		Strategy:      "greedy",
+		Dates:         []string{"2024-01-02"},
+		EquityCurve:   []float64{10000},
+	}
+	a, err := fingerprintResults([]Result{r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := fingerprintResults([]Result{r})
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("not stable: %v vs %v", a, b)
+	}
+	r.EquityCurve = []float64{10000.000000000002}
+	c, _ := fingerprintResults([]Result{r})
+	if c[0].Hash == a[0].Hash {
+		t.Error("1-ulp equity change kept the hash")
+	}
+}
+
+func TestDiffFingerprints(t *testing.T) {
+	fp := data.Fingerprint{Name: "A", First: "2024-01-02", Last: "2024-06-28", Rows: 124, Checksum: "aa"}
+	changed := fp
+	changed.Checksum = "bb"
+	extended := fp
+	extended.Last, extended.Rows, extended.Checksum = "2024-07-01", 125, "cc"
+
+	cases := []struct {
+		got  []data.Fingerprint
+		want string
+	}{
+		{[]data.Fingerprint{fp}, ""},
+		{[]data.Fingerprint{changed}, "data A: values changed"},
+		{[]data.Fingerprint{extended}, "data A: 125 rows 2024-01-02..2024-07-01, manifest recorded 124 rows 2024-01-02..2024-06-28"},
+		{nil, "data A: no longer read"},
+	}
+	for _, c := range cases {
+		got := strings.Join(diffFingerprints([]data.Fingerprint{fp}, c.got), "; ")
+		if got != c.want {
+			t.Errorf("diff = %q, want %q", got, c.want)
+		}
+	}
+
+	files := []FileFingerprint{{Path: "s.lua", SHA256: "aa"}}
+	if d := diffFiles(files, files); len(d) != 0 {
+		t.Errorf("identical files differ: %v", d)
+	}
+	if d := diffFiles(files, nil); len(d) != 1 || d[0] != "file s.lua: contents changed" {
+		t.Errorf("diffFiles = %v", d)
+	}
+}
+
+func TestManifest_WriteLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.manifest.json")
+	m := &Manifest{
+		SchemaVersion: ManifestSchemaVersion,
+		EngineVersion: EngineVersion(),
+		Data:          []data.Fingerprint{{Name: "A", Rows: 1, Checksum: "aa"}},
+		Results:       []ResultFingerprint{{Portfolio: "p", Hash: "bb"}},
+	}
+	if err := WriteManifest(path, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("loaded %+v, want %+v", got, m)
+	}
+
+	m.SchemaVersion = ManifestSchemaVersion + 1
+	if err := WriteManifest(path, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadManifest(path); err == nil {
+		t.Error("future schema_version accepted")
+	}
+}
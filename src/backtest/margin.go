@@ -0,0 +1,177 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+)
+
+// MarginConfig lets the portfolio borrow: a buy may take cash below zero
+// as long as gross exposure (long plus short positions) stays within
+// Leverage times equity. Borrowed cash is charged interest on every bar
+// it is held overnight, at the bar's risk-free rate plus SpreadBps a
+// year.
+type MarginConfig struct {
+	Leverage  float64 `toml:"leverage"`   // at least 1; 2 is Reg T
+	SpreadBps float64 `toml:"spread_bps"` // annual, over the risk-free rate
+}
+
+// WithMargin lets the portfolio trade on margin (see MarginConfig).
+func WithMargin(m MarginConfig) Option {
+	return func(p *Portfolio) error {
+		if !(m.Leverage >= 1) || math.IsInf(m.Leverage, 1) {
+			return fmt.Errorf("margin leverage %v: must be at least 1", m.Leverage)
+		}
+		if !(m.SpreadBps >= 0) || math.IsInf(m.SpreadBps, 1) {
+			return fmt.Errorf("margin spread_bps %v: must be non-negative", m.SpreadBps)
+		}
+		p.Margin = &m
+		return nil
+	}
+}
+
+// exposure marks the portfolio to the close of the bar being processed,
+// or outside a run to its positions' last prices, and returns its
+// equity (cash plus positions) and its gross and net exposure in dollars.
+// It sums in ticker order, so a margin call or a refused order never turns
+// on the map's iteration order.
+func (p *Portfolio) exposure() (equity, gross, net float64) {
+	for _, t := range sortedKeys(p.Positions) {
+		pos := p.Positions[t]
+		if pos.Amount == 0 {
+			continue
+		}
+		mark := p.mark(t, pos)
+		net += pos.Amount * mark
+		gross += math.Abs(pos.Amount * mark)
+	}
+	return p.BuyingPower + net, gross, net
+}
+
+// mark is ticker's price as exposure marks pos: the close of the bar
+// being processed, or outside a run its last price.
+func (p *Portfolio) mark(ticker string, pos *Position) float64 {
+	if p.engine != nil {
+		if series := p.engine.bar.Hist[ticker]; p.bar < len(series) {
+			return series[p.bar].Close
+		}
+	}
+	if pos.CurrentPrice == 0 {
+		return pos.AveragePrice
+	}
+	return pos.CurrentPrice
+}
+
+// purchasingPower is the dollars a buy may spend: the settled cash, or
+// on margin whatever keeps gross exposure within Leverage times equity
+// if that is more. While the portfolio is short, a buy that adds to its
+// exposure is further held to shortLimit (see withinLeverage).
+func (p *Portfolio) purchasingPower() float64 {
+	if p.Margin == nil {
+		return p.BuyingPower - p.unsettledTotal()
+	}
+	equity, gross, _ := p.exposure()
+	return math.Max(p.BuyingPower, p.Margin.Leverage*equity-gross)
+}
+
+// leverage is the gross exposure p may hold per dollar of equity: its
+// Margin's Leverage, or 1 without margin.
+func (p *Portfolio) leverage() float64 {
+	if p.Margin == nil {
+		return 1
+	}
+	return p.Margin.Leverage
+}
+
+// shortLimit is what p may add to its gross exposure while it holds a
+// short, and whether it does. A short sale's proceeds are collateral for
+// it, not cash to spend, so gross exposure stays within leverage times
+// equity: without margin each dollar short ties up the proceeds and a
+// dollar of equity, and at 2x Reg T's 150%.
+func (p *Portfolio) shortLimit() (float64, bool) {
+	equity, gross, net := p.exposure()
+	if gross == net {
+		return 0, false
+	}
+	return p.leverage()*equity - gross, true
+}
+
+// withinLeverage reports whether moving ticker's position by shares
+// (negative to sell) at price with fee keeps a portfolio that is or
+// would be short within shortLimit. A trade that doesn't add to gross
+// exposure, such as covering a short, always is, and so is any trade of
+// a portfolio that stays long only, which its cash already limits.
+func (p *Portfolio) withinLeverage(ticker string, shares, price, fee float64) bool {
+	equity, gross, net := p.exposure()
+	held, mark := 0.0, price
+	if pos, ok := p.FindPosition(ticker); ok {
+		held, mark = pos.Amount, p.mark(ticker, pos)
+	}
+	if gross == net && held+shares >= 0 {
+		return true
+	}
+	after := gross - math.Abs(held*mark) + math.Abs((held+shares)*price)
+	return after <= gross || after <= p.leverage()*(equity-fee)
+}
+
+// chargeMargin charges interest on cash borrowed over the night before
+// bar day, at the previous bar's risk-free rate plus the spread.
+func (p *Portfolio) chargeMargin(day int) {
+	if p.Margin == nil || day == 0 || p.BuyingPower >= 0 {
+		return
+	}
+	rate := p.Margin.SpreadBps / 10_000 / p.periodsPerYear()
+	if day-1 < len(p.marginRates) {
+		rate += p.marginRates[day-1]
+	}
+	interest := -p.BuyingPower * rate
+	if !(interest > 0) {
+		return
+	}
+	p.adjustCash(-interest)
+	p.exposures.interest += interest
+	p.txLog().Debug("INTEREST", "portfolio", p.Pname, "interest", interest, "borrowed", -p.BuyingPower, "day", day)
+}
+
+// recordExposure adds bar day's close to the exposure statistics.
+func (p *Portfolio) recordExposure(hist map[string][]data.AssetData, day int) {
+	var gross, net float64
+	for _, t := range p.Tickers {
+		pos, ok := p.Positions[t]
+		if !ok || pos.Amount == 0 || day >= len(hist[t]) {
+			continue
+		}
+		v := pos.Amount * hist[t][day].Close
+		net += v
+		gross += math.Abs(v)
+	}
+	value := p.BuyingPower + net
+	if !(value > 0) {
+		return
+	}
+	e := &p.exposures
+	e.bars++
+	e.gross += gross / value
+	e.net += net / value
+	e.maxLeverage = math.Max(e.maxLeverage, gross/value)
+}
+
+// exposureStats accumulates gross and net exposure over equity, one
+// close at a time.
+type exposureStats struct {
+	bars        int
+	gross, net  float64 // sums
+	maxLeverage float64
+	interest    float64 // margin interest paid
+}
+
+// setExposure reports the exposure statistics in m.
+func (p *Portfolio) setExposure(m *Metrics) {
+	e := p.exposures
+	if e.bars > 0 {
+		m.AvgGrossExposure = e.gross / float64(e.bars) * 100
+		m.AvgNetExposure = e.net / float64(e.bars) * 100
+	}
+	m.MaxLeverage = e.maxLeverage
+	m.MarginInterest = e.interest
+}
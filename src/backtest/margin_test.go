@@ -0,0 +1,128 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// At 2x leverage greedy buys twice its cash and borrows the rest, paying
+// a risk-free 0.01% plus a 252 bps a year spread (another 0.01% a bar) on
+// the loan each night.
+func TestMargin_LeverageAndInterest(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 3; i++ {
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: day(i), Open: 10, High: 10, Low: 10, Close: 10, Volume: 100,
+		})
+	}
+	p, err := NewPortfolio("margin", 1000, []string{"A"}, "greedy",
+		WithWindow(day(0), day(2)), WithRiskFree(ConstantRiskFree(0.0001)),
+		WithMargin(MarginConfig{Leverage: 2, SpreadBps: 252}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if len(r.Trades) != 1 || r.Trades[0].Amount != 200 {
+		t.Fatalf("trades = %+v, want 200 shares on $1000", r.Trades)
+	}
+	m := r.Metrics
+	interest := 1000*0.0002 + 1000.2*0.0002
+	if !closeTo(m.MarginInterest, interest) || !closeTo(r.EquityCurve[len(r.EquityCurve)-1], 1000-interest) {
+		t.Errorf("interest %v, final value %v; want %v and %v",
+			m.MarginInterest, r.EquityCurve[len(r.EquityCurve)-1], interest, 1000-interest)
+	}
+	if !closeTo(m.MaxLeverage, 2000/(1000-interest)) || m.AvgGrossExposure != m.AvgNetExposure || m.AvgNetExposure < 200 {
+		t.Errorf("max leverage %v, gross %v%%, net %v%%", m.MaxLeverage, m.AvgGrossExposure, m.AvgNetExposure)
+	}
+
+	for _, bad := range []MarginConfig{{Leverage: 0.5}, {Leverage: 2, SpreadBps: -1}} {
+		if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithMargin(bad)); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
+
+// Gross exposure counts a short at its size and net exposure nets it
+// against the long.
+func TestExposure_LongShort(t *testing.T) {
+	hist := map[string][]data.AssetData{
+		"A": {{Close: 10}}, "B": {{Close: 20}},
+	}
+	p := newPropPortfolio(1000, AccountingFloat)
+	p.Tickers = []string{"A", "B"}
+	p.Positions["A"] = &Position{Amount: 50}
+	p.Positions["B"] = &Position{Amount: -25}
+	p.recordExposure(hist, 0)
+	var m Metrics
+	p.setExposure(&m)
+	if m.AvgGrossExposure != 100 || m.AvgNetExposure != 0 || m.MaxLeverage != 1 {
+		t.Errorf("gross %v%%, net %v%%, leverage %v; want 100, 0, 1", m.AvgGrossExposure, m.AvgNetExposure, m.MaxLeverage)
+	}
+}
+
+// Float sums depend on their order; exposure's must not depend on the
+// map's.
+func TestExposure_Deterministic(t *testing.T) {
+	p := newPropPortfolio(1000, AccountingFloat)
+	for i := 0; i < 40; i++ {
+		p.Positions[fmt.Sprintf("T%02d", i)] = &Position{Amount: 0.1 + float64(i)/3, CurrentPrice: 1.1 + float64(i)/7}
+	}
+	equity, gross, net := p.exposure()
+	for i := 0; i < 50; i++ {
+		if e, g, n := p.exposure(); e != equity || g != gross || n != net {
+			t.Fatalf("run %d: %v %v %v, first %v %v %v", i, e, g, n, equity, gross, net)
+		}
+	}
+}
+
+// A short's proceeds back it rather than fund more trades: without
+// margin gross exposure stays within equity, at 2x within twice it, and
+// covering is always allowed.
+func TestShort_Collateral(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	p := newPropPortfolio(1000, AccountingFloat)
+	p.AllowShort = true
+	if err := p.Sell("A", 101, 10, day); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("short past equity: err = %v", err)
+	}
+	if err := p.Sell("A", 100, 10, day); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Buy("B", 1, 10, day); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("buy on the short's proceeds: err = %v", err)
+	}
+	if err := p.Sell("C", 1, 10, day); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("second short on the first's proceeds: err = %v", err)
+	}
+	if err := p.Buy("A", 40, 10, day); err != nil {
+		t.Errorf("cover: %v", err)
+	}
+	if err := p.Buy("B", 40, 10, day); err != nil {
+		t.Errorf("buy within equity once part covered: %v", err)
+	}
+	if len(p.Trades) != 3 {
+		t.Errorf("trades = %+v", p.Trades)
+	}
+
+	p = newPropPortfolio(1000, AccountingFloat)
+	p.AllowShort = true
+	p.Margin = &MarginConfig{Leverage: 2}
+	if err := p.Sell("A", 201, 10, day); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("short past 2x: err = %v", err)
+	}
+	if err := p.Sell("A", 200, 10, day); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Buy("B", 1, 10, day); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("buy past 2x on the short's proceeds: err = %v", err)
+	}
+}
@@ -2,7 +2,11 @@ package backtest
 
 import (
 	"math"
+	"math/rand"
 	"my-backtester/src/data"
+	"my-backtester/src/metrics"
+	"sort"
+	"time"
 
 	"gonum.org/v1/gonum/stat"
 )
@@ -15,88 +19,229 @@ type Metrics struct {
 	StandardDev       float64
 	AvgCorrelation    float64
 	CointegratedPairs int
+	// Observations is the number of daily excess returns Sharpe and
+	// Sortino were computed from; RiskFreeFilled is how many of those
+	// days had no published risk-free rate and used a filled one.
+	Observations   int
+	RiskFreeFilled int
+	// BenchmarkReturn is the annualized return, in percent, of buying
+	// and holding the portfolio's Benchmark over the same bars; 0 when
+	// no benchmark is set or it has no data.
+	BenchmarkReturn float64
+	// CalmarRatio is AnnualReturn over MaxDrawdown; 0 with no drawdown.
+	CalmarRatio float64
+	// IRR is the money-weighted annual return, in percent, counting the
+	// initial cash and every CashFlow; AnnualReturn is time-weighted and
+	// unaffected by them. NetContributions is the CashFlows' total.
+	IRR              float64
+	NetContributions float64
+	// AvgGrossExposure and AvgNetExposure are the mean, over the bars'
+	// closes, of long plus short and long less short positions in
+	// percent of the portfolio's value, and MaxLeverage the highest gross
+	// exposure over value. MarginInterest is the interest paid on
+	// borrowed cash (see WithMargin).
+	AvgGrossExposure float64
+	AvgNetExposure   float64
+	MaxLeverage      float64
+	MarginInterest   float64
+	// MaxSectorExposure is the largest net exposure to any one sector at
+	// a close, in percent of the portfolio's value (see
+	// Result.Sectors); 0 without sectors.
+	MaxSectorExposure float64
+	// TaxesPaid is the capital-gains tax charged on realized gains (see
+	// WithTax), already out of the returns above. DeferredTax is what
+	// selling the positions left at the end would owe on top, and
+	// AfterTaxReturn the money-weighted annual return, in percent, of
+	// doing so: the figure to compare a buy-and-hold strategy, which
+	// defers its tax, with one that trades often.
+	TaxesPaid      float64
+	DeferredTax    float64
+	AfterTaxReturn float64
+	// UnsettledRejects counts buys refused because only cash still
+	// settling would have paid for them (see WithSettlement): each one a
+	// trade a cash account couldn't have made.
+	UnsettledRejects int
+
+	// TStat and PValue test whether the mean daily excess return is above
+	// zero (a one-sided t-test); DeflatedSharpe is the probability that
+	// the Sharpe ratio beats the best of Trials configurations without
+	// any edge (see deflatedSharpe). A Sharpe ratio that is only the
+	// luckiest of a grid search has a high Sharpe and a low
+	// DeflatedSharpe.
+	TStat          float64
+	PValue         float64
+	Trials         int
+	DeflatedSharpe float64
+	// SharpeLow and SharpeHigh, and AnnualReturnLow and AnnualReturnHigh
+	// (percent), bound bootstrap confidence intervals at Confidence; all
+	// 0 unless the portfolio asked for them (see WithSignificance).
+	Confidence       float64
+	SharpeLow        float64
+	SharpeHigh       float64
+	AnnualReturnLow  float64
+	AnnualReturnHigh float64
+	// RandomEntrySharpe is the Sharpe ratio at the configured percentile
+	// of RandomEntryRuns runs with random entries and exits on the same
+	// tickers, and RandomEntryRank the percentage of them this run's
+	// Sharpe ratio beat; all 0 unless the portfolio asked for them (see
+	// WithRandomEntry).
+	RandomEntryRuns   int
+	RandomEntrySharpe float64
+	RandomEntryRank   float64
+	// FoldScore and FoldScoreStdDev are the mean and standard deviation
+	// of the objective over the held-out blocks of a cross-validation run
+	// with Folds folds, and FoldInSampleScore its mean over the blocks
+	// each fold's candidate was picked on; all 0 otherwise (see
+	// CrossValidate).
+	Folds             int
+	FoldScore         float64
+	FoldScoreStdDev   float64
+	FoldInSampleScore float64
+	// PBO is the probability of backtest overfitting of the optimize run
+	// this is the best of: the share of ways of splitting the history in
+	// half in which the best of PBOConfigs configurations on one half
+	// ranks no better than the median on the other. Both are 0 unless
+	// an optimize run could estimate it (see OptimizeConfig.PBOBlocks).
+	PBO        float64
+	PBOConfigs int
+
+	// Trade statistics over the portfolio's ClosedTrades, in dollars
+	// unless noted. AvgLoss is negative; ProfitFactor, gross profit over
+	// gross loss, is 0 when nothing lost.
+	ClosedTrades   int
+	WinRate        float64 // percent of closed trades with a positive PnL
+	AvgWin         float64
+	AvgLoss        float64
+	ProfitFactor   float64
+	Expectancy     float64 // mean PnL per closed trade
+	AvgHoldingDays float64 // mean calendar days from Entry to Exit
+
+	// VaR holds one-day value at risk at each of the portfolio's
+	// VaRLevels, in order.
+	VaR []VaR
+
+	// MaxDrawdownDays and RecoveryDays are the calendar days from the
+	// deepest drawdown's peak to its trough and from its trough back to
+	// the peak's value; RecoveryDays is 0 if it never recovered.
+	// Drawdowns lists the TopDrawdowns deepest, deepest first.
+	MaxDrawdownDays int
+	RecoveryDays    int
+	Drawdowns       []Drawdown
 }
 
-func GetSortinoRatio(
-	riskFreeRates map[int64]float64,
-	dailyAvg map[int64]float64,
-) float64 {
-	excessReturns := make([]float64, 0, len(dailyAvg))
-	downsideReturns := make([]float64, 0)
-
-	for key, val := range dailyAvg {
-		if rate, ok := riskFreeRates[key]; ok {
-			excessReturn := val - rate
-			excessReturns = append(excessReturns, excessReturn)
-			if excessReturn < 0 {
-				downsideReturns = append(downsideReturns, excessReturn)
+// TopDrawdowns is how many drawdowns Metrics.Drawdowns lists.
+var TopDrawdowns = 5
+
+// Drawdown is one fall from a peak of the close-value series and its
+// recovery. Depth is in percent of the peak; Recovery is the zero time
+// while the series is still below the peak.
+type Drawdown struct {
+	Peak, Trough, Recovery time.Time
+	Depth                  float64
+}
+
+// Days is the calendar days from Peak to Trough.
+func (d Drawdown) Days() int { return days(d.Peak, d.Trough) }
+
+// RecoveryDays is the calendar days from Trough to Recovery, or 0 if it
+// hasn't recovered.
+func (d Drawdown) RecoveryDays() int {
+	if d.Recovery.IsZero() {
+		return 0
+	}
+	return days(d.Trough, d.Recovery)
+}
+
+func days(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// drawdowns returns every drawdown of values, whose dates are the
+// matching entries of dates, deepest first (earliest first among equal
+// depths). Like GetMaxDrawdown it only measures from a positive peak.
+func drawdowns(values []float64, dates []time.Time) []Drawdown {
+	var out []Drawdown
+	peak, trough := 0, 0
+	open := false
+	for i, v := range values {
+		switch {
+		case v >= values[peak]:
+			if open {
+				out = append(out, Drawdown{
+					Peak: dates[peak], Trough: dates[trough], Recovery: dates[i],
+					Depth: (values[peak] - values[trough]) / values[peak] * 100,
+				})
+				open = false
+			}
+			peak = i
+		case values[peak] > 0:
+			if !open || v < values[trough] {
+				trough = i
 			}
+			open = true
 		}
 	}
-
-	if len(downsideReturns) == 0 {
-		return 0.0 // Avoid division by zero if there are no negative returns
+	if open {
+		out = append(out, Drawdown{
+			Peak: dates[peak], Trough: dates[trough],
+			Depth: (values[peak] - values[trough]) / values[peak] * 100,
+		})
 	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Depth > out[j].Depth })
+	return out
+}
+
+// DefaultVaRLevels are the confidence levels VaR is reported at when a
+// portfolio sets none.
+var DefaultVaRLevels = []float64{0.95}
 
-	averageExcessReturn := stat.Mean(excessReturns, nil)
-	downsideDeviation := stat.StdDev(downsideReturns, nil)
+// VaR is the one-day loss, in percent of portfolio value, not exceeded
+// with probability Confidence (see metrics.VaR).
+type VaR = metrics.VaR
 
-	if downsideDeviation == 0 {
-		return 0.0 // Avoid division by zero
+// primaryVaR is VaR at the first confidence level, or zero if none was
+// computed.
+func (m Metrics) primaryVaR() VaR {
+	if len(m.VaR) == 0 {
+		return VaR{}
 	}
+	return m.VaR[0]
+}
+
+// DefaultPeriodsPerYear annualizes the exported metric helpers and
+// CompareExternal, which have no portfolio Calendar to consult: 252
+// daily bars a year unless a program sets it for other bars, such as
+// 252*78 for 5-minute equity bars.
+var DefaultPeriodsPerYear = CalendarEquities.PeriodsPerYear()
 
-	sortinoRatio := averageExcessReturn / downsideDeviation
-	// Annualize
-	annualizedSortino := sortinoRatio * math.Sqrt(252.0)
-	return annualizedSortino
+func GetSortinoRatio(
+	riskFreeRates map[int64]float64,
+	dailyAvg map[int64]float64,
+) float64 {
+	excessReturns, _ := metrics.ExcessReturns(riskFreeRates, dailyAvg)
+	return metrics.Sortino(excessReturns, DefaultPeriodsPerYear)
 }
 
+// GetAnnualReturn is the CAGR, in percent, of compounding dailyAvg over
+// len(dailyAvg)/DefaultPeriodsPerYear years. An empty series is 0; a
+// series that loses everything is -100 (the root of a non-positive value
+// is undefined).
 func GetAnnualReturn(dailyAvg []float64) float64 {
-	startValue := 1.0
-
-	for i := range dailyAvg {
-		startValue *= (1 + dailyAvg[i])
-	}
-	numYears := float64(len(dailyAvg)) / 252.0
-	// Compound Annual Growth Rate - (end/start) ^ 1/n - 1
-	CAGR := math.Pow(startValue, 1/numYears) - 1
-	return CAGR * 100
+	return metrics.AnnualReturn(dailyAvg, DefaultPeriodsPerYear)
 }
 
+// GetMaxDrawdown is the largest peak-to-trough fall of
+// portfolioCloseValues, in percent (see metrics.MaxDrawdown).
 func GetMaxDrawdown(portfolioCloseValues []float64) float64 {
-	if len(portfolioCloseValues) == 0 {
-		return 0.0
-	}
-	peak := portfolioCloseValues[0]
-	maxDrawdown := 0.0
-
-	for _, value := range portfolioCloseValues {
-		if value > peak {
-			peak = value
-		}
-		drawdown := (peak - value) / peak
-		if drawdown > maxDrawdown {
-			maxDrawdown = drawdown
-		}
-	}
-
-	return maxDrawdown * 100
+	return metrics.MaxDrawdown(portfolioCloseValues)
 }
 
 func GetSharpeRatio(
 	riskFreeRates map[int64]float64,
 	dailyAvg map[int64]float64,
 ) float64 {
-	excessReturns := make([]float64, 0, len(dailyAvg))
-	for key, val := range dailyAvg {
-		if rate, ok := riskFreeRates[key]; ok {
-			excessReturns = append(excessReturns, val-rate)
-		}
-	}
-	excessStdev := stat.StdDev(excessReturns, nil)
-	sharpeRatio := stat.Mean(excessReturns, nil) / excessStdev
-	annualizedSharpe := sharpeRatio * math.Sqrt(252.0)
-	return annualizedSharpe
+	excessReturns, _ := metrics.ExcessReturns(riskFreeRates, dailyAvg)
+	return metrics.Sharpe(excessReturns, DefaultPeriodsPerYear)
 }
 
 func (p *Portfolio) GetBacktestingData(
@@ -111,22 +256,165 @@ func (p *Portfolio) GetBacktestingData(
 		dailyAvgSlice = append(dailyAvgSlice, dr.Return)
 	}
 
-	// annualize standard deviation
-	standardDev := stat.StdDev(dailyAvgSlice, nil) * math.Sqrt(252.0)
-	sharpeRatio := GetSharpeRatio(riskFreeRates, dailyAvg)
-	sortinoRatio := GetSortinoRatio(riskFreeRates, dailyAvg)
-	annualReturn := GetAnnualReturn(dailyAvgSlice)
-	maxDrawdown := GetMaxDrawdown(p.PortfolioCloseValues)
+	periods := p.periodsPerYear()
+	// annualize standard deviation; undefined below two observations
+	standardDev := 0.0
+	if len(dailyAvgSlice) >= 2 {
+		standardDev = stat.StdDev(dailyAvgSlice, nil) * math.Sqrt(periods)
+	}
+	excessReturns, filled := metrics.ExcessReturns(p.barRates(riskFreeRates), dailyAvg)
+	annual := metrics.AnnualReturn(dailyAvgSlice, periods)
+	// Outside cash moves the close values without being a gain or loss,
+	// so drawdowns are then taken from the growth of the daily returns.
+	values := p.PortfolioCloseValues
+	if len(p.CashFlows) > 0 {
+		values = metrics.Growth(dailyAvgSlice)
+	}
+	maxDrawdown := GetMaxDrawdown(values)
 	avgCorrelation := AvgPairwiseCorrelation(p.Tickers, hist, dataLen)
 	cointegratedPairs := CountCointegratedPairs(p.Tickers, hist, dataLen)
-	metrics := Metrics{
+	m := Metrics{
 		StandardDev:       standardDev,
-		SharpeRatio:       sharpeRatio,
-		SortinoRatio:      sortinoRatio,
+		SharpeRatio:       metrics.Sharpe(excessReturns, periods),
+		SortinoRatio:      metrics.Sortino(excessReturns, periods),
 		MaxDrawdown:       maxDrawdown,
-		AnnualReturn:      annualReturn,
+		AnnualReturn:      annual,
 		AvgCorrelation:    avgCorrelation,
 		CointegratedPairs: cointegratedPairs,
+		Observations:      len(excessReturns),
+		RiskFreeFilled:    filled,
+		CalmarRatio:       metrics.Calmar(annual, maxDrawdown),
+	}
+	final := p.InitialBuyingPower
+	if n := len(p.PortfolioCloseValues); n > 0 {
+		final = p.PortfolioCloseValues[n-1]
+	}
+	m.IRR = irr(p.InitialBuyingPower, p.EffectiveStart, p.CashFlows, final, p.EffectiveEnd)
+	for _, f := range p.CashFlows {
+		m.NetContributions += f.Amount
+	}
+	p.setExposure(&m)
+	p.setSectorExposure(&m)
+	m.UnsettledRejects = p.refused
+	if p.Tax != nil {
+		m.TaxesPaid = p.taxes.paid
+		m.DeferredTax = p.deferredTax(p.EffectiveEnd)
+		m.AfterTaxReturn = irr(p.InitialBuyingPower, p.EffectiveStart, p.CashFlows, final-m.DeferredTax, p.EffectiveEnd)
+	}
+	m.setTradeStats(p.ClosedTrades)
+	levels := p.VaRLevels
+	if len(levels) == 0 {
+		levels = DefaultVaRLevels
+	}
+	for _, c := range levels {
+		m.VaR = append(m.VaR, metrics.ValueAtRisk(dailyAvgSlice, c))
+	}
+	// Close values are recorded alongside DailyReturns, one per date.
+	if len(p.PortfolioCloseValues) == len(p.DailyReturns) {
+		excess := excessReturns
+		if len(excess) != len(dailyAvgSlice) {
+			excess = nil
+		}
+		windows := p.RollingWindows
+		if len(windows) == 0 {
+			windows = DefaultRollingWindows
+		}
+		p.Rolling = nil
+		for _, w := range windows {
+			p.Rolling = append(p.Rolling,
+				rolling(w, dailyAvgSlice, excess, values, periods))
+		}
+
+		dates := make([]time.Time, len(p.DailyReturns))
+		for i, dr := range p.DailyReturns {
+			dates[i] = dr.Date
+		}
+		dd := drawdowns(values, dates)
+		if len(dd) > 0 {
+			m.MaxDrawdownDays = dd[0].Days()
+			m.RecoveryDays = dd[0].RecoveryDays()
+		}
+		if len(dd) > TopDrawdowns {
+			dd = dd[:TopDrawdowns]
+		}
+		m.Drawdowns = dd
+	}
+	p.setSignificance(&m, dailyAvgSlice, excessReturns, periods)
+	if p.MonteCarloConfig != nil {
+		excess := excessReturns
+		if len(excess) != len(dailyAvgSlice) {
+			excess = nil
+		}
+		// Its own source, so the draws don't depend on the strategy's.
+		rng := rand.New(rand.NewSource(p.Seed))
+		p.MonteCarlo = monteCarlo(p.MonteCarloConfig, rng, dailyAvgSlice, excess,
+			p.ClosedTrades, p.InitialBuyingPower, periods)
+	}
+	p.Metrics = m
+}
+
+// setTradeStats fills m's trade statistics from closed.
+func (m *Metrics) setTradeStats(closed []ClosedTrade) {
+	m.ClosedTrades = len(closed)
+	if len(closed) == 0 {
+		return
+	}
+	var wins, losses int
+	var grossWin, grossLoss, held float64
+	for _, c := range closed {
+		switch {
+		case c.PnL > 0:
+			wins++
+			grossWin += c.PnL
+		case c.PnL < 0:
+			losses++
+			grossLoss -= c.PnL
+		}
+		held += c.Exit.Sub(c.Entry).Hours() / 24
+	}
+	n := float64(len(closed))
+	m.WinRate = float64(wins) / n * 100
+	if wins > 0 {
+		m.AvgWin = grossWin / float64(wins)
+	}
+	if losses > 0 {
+		m.AvgLoss = -grossLoss / float64(losses)
+		m.ProfitFactor = grossWin / grossLoss
+	}
+	m.Expectancy = (grossWin - grossLoss) / n
+	m.AvgHoldingDays = held / n
+}
+
+// benchmarkReturn is the annualized return, in percent, of holding
+// series from its first bar to its last.
+func benchmarkReturn(series []data.AssetData, periodsPerYear float64) float64 {
+	if len(series) < 2 {
+		return 0
+	}
+	return metrics.AnnualReturn(data.Returns(series, len(series)), periodsPerYear)
+}
+
+// benchmarkCurve is the value, on each of dates (YYYY-MM-DD), of putting
+// equity's first value into series on the first date, carrying the last
+// close over dates series has no bar on; nil when there is nothing to
+// compare.
+func benchmarkCurve(series []data.AssetData, dates []string, equity []float64) []float64 {
+	if len(series) == 0 || len(equity) == 0 || len(dates) == 0 {
+		return nil
+	}
+	curve := make([]float64, len(dates))
+	last, base, j := series[0].Close, 0.0, 0
+	for i, d := range dates {
+		for ; j < len(series) && formatDate(series[j].Date) <= d; j++ {
+			last = series[j].Close
+		}
+		if i == 0 {
+			base = last
+		}
+		if base <= 0 {
+			return nil
+		}
+		curve[i] = equity[0] * last / base
 	}
-	p.Metrics = metrics
+	return curve
 }
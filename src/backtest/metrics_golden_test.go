@@ -0,0 +1,106 @@
+package backtest
+
+import (
+	"encoding/json"
+	"math"
+	"my-backtester/src/data"
+	"os"
+	"testing"
+	"time"
+)
+
+// goldenCase is one entry of testdata/metrics_golden.json, whose expected
+// values come from the independent Python implementation beside it.
+type goldenCase struct {
+	Name         string    `json:"name"`
+	Returns      []float64 `json:"returns"`
+	RiskFree     []float64 `json:"risk_free"`
+	Equity       []float64 `json:"equity"`
+	SharpeRatio  float64   `json:"sharpe_ratio"`
+	SortinoRatio float64   `json:"sortino_ratio"`
+	AnnualReturn float64   `json:"annual_return"`
+	MaxDrawdown  float64   `json:"max_drawdown"`
+	StandardDev  float64   `json:"standard_dev"`
+}
+
+// goldenMetrics runs c through GetBacktestingData, the path every
+// backtest takes.
+func goldenMetrics(c goldenCase) Metrics {
+	p := &Portfolio{PortfolioCloseValues: c.Equity}
+	rf := make(map[int64]float64, len(c.RiskFree))
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, r := range c.Returns {
+		d := start.AddDate(0, 0, i)
+		p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: d, Return: r})
+		rf[d.Unix()] = c.RiskFree[i]
+	}
+	p.GetBacktestingData(rf, map[string][]data.AssetData{}, 0)
+	return p.Metrics
+}
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want))
+}
+
+func TestMetrics_Golden(t *testing.T) {
+	raw, err := os.ReadFile("testdata/metrics_golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden struct {
+		Cases []goldenCase `json:"cases"`
+	}
+	if err := json.Unmarshal(raw, &golden); err != nil {
+		t.Fatal(err)
+	}
+	if len(golden.Cases) == 0 {
+		t.Fatal("no golden cases")
+	}
+	for _, c := range golden.Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			m := goldenMetrics(c)
+			for _, f := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"SharpeRatio", m.SharpeRatio, c.SharpeRatio},
+				{"SortinoRatio", m.SortinoRatio, c.SortinoRatio},
+				{"AnnualReturn", m.AnnualReturn, c.AnnualReturn},
+				{"MaxDrawdown", m.MaxDrawdown, c.MaxDrawdown},
+				{"StandardDev", m.StandardDev, c.StandardDev},
+			} {
+				if !closeTo(f.got, f.want) {
+					t.Errorf("%s = %.15g, want %.15g", f.name, f.got, f.want)
+				}
+			}
+		})
+	}
+}
+
+// Values worked out by hand, so the golden file itself can't drift.
+func TestMetrics_HandVerified(t *testing.T) {
+	// mean 0.006, sample stddev sqrt(0.00172/4) ≈ 0.0207364.
+	returns := []float64{0.01, -0.02, 0.03, -0.01, 0.02}
+	sd := math.Sqrt(0.00172 / 4)
+	if got, want := GetSharpeRatio(byDay(0, 0, 0, 0, 0), byDay(returns...)),
+		0.006/sd*math.Sqrt(252); !closeTo(got, want) {
+		t.Errorf("Sharpe = %v, want %v", got, want)
+	}
+	// Downside returns -0.02 and -0.01 have sample stddev sqrt(0.00005).
+	if got, want := GetSortinoRatio(byDay(0, 0, 0, 0, 0), byDay(returns...)),
+		0.006/math.Sqrt(0.00005)*math.Sqrt(252); !closeTo(got, want) {
+		t.Errorf("Sortino = %v, want %v", got, want)
+	}
+	// 130 -> 65 is the deepest fall.
+	if got := GetMaxDrawdown([]float64{100, 120, 90, 130, 65, 80}); !closeTo(got, 50) {
+		t.Errorf("MaxDrawdown = %v, want 50", got)
+	}
+	// Doubling over 504 trading days is sqrt(2)-1 a year.
+	twoYears := make([]float64, 504)
+	for i := range twoYears {
+		twoYears[i] = math.Pow(2, 1.0/504) - 1
+	}
+	if got, want := GetAnnualReturn(twoYears), (math.Sqrt2-1)*100; !closeTo(got, want) {
+		t.Errorf("AnnualReturn = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package backtest
+
+import (
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// byDay keys returns by consecutive day, as GetBacktestingData does.
+func byDay(returns ...float64) map[int64]float64 {
+	m := make(map[int64]float64, len(returns))
+	for i, r := range returns {
+		m[int64(i)*86400] = r
+	}
+	return m
+}
+
+func finite(t *testing.T, name string, v float64) {
+	t.Helper()
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		t.Errorf("%s = %v, want a finite value", name, v)
+	}
+}
+
+func TestMetrics_DegenerateSeries(t *testing.T) {
+	rf := byDay(0, 0, 0, 0, 0)
+	cases := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"max drawdown, empty", GetMaxDrawdown(nil), 0},
+		{"max drawdown, zero start", GetMaxDrawdown([]float64{0, 0, 10, 5}), 50},
+		{"max drawdown, non-positive", GetMaxDrawdown([]float64{-5, -10}), 0},
+		{"annual return, empty", GetAnnualReturn(nil), 0},
+		{"annual return, wiped out", GetAnnualReturn([]float64{0.1, -1, 0.2}), -100},
+		{"sharpe, empty", GetSharpeRatio(rf, nil), 0},
+		{"sharpe, single return", GetSharpeRatio(rf, byDay(0.01)), 0},
+		{"sharpe, constant returns", GetSharpeRatio(rf, byDay(0.01, 0.01, 0.01)), 0},
+		{"sharpe, no risk-free rates", GetSharpeRatio(nil, byDay(0.01, -0.02)), 0},
+		{"sortino, no down days", GetSortinoRatio(rf, byDay(0.01, 0.02)), 0},
+		{"sortino, one down day", GetSortinoRatio(rf, byDay(0.01, -0.02, 0.03)), 0},
+		{"sortino, equal down days", GetSortinoRatio(rf, byDay(-0.01, 0.02, -0.01)), 0},
+		{"rsi, no changes", RSI(nil, 14), 50},
+		{"rsi, flat", RSI([]float64{0, 0, 0}, 3), 50},
+		{"rsi, no down days", RSI([]float64{1, 2, 0}, 3), 100},
+		{"rsi, zero period", RSI([]float64{1, -1}, 0), 50},
+		{"rsi, equal up and down", RSI([]float64{1, -1}, 2), 50},
+		{"sma, empty", SMA(nil), 0},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestGetBacktestingData_TooFewObservations(t *testing.T) {
+	d := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for n := 0; n <= 1; n++ {
+		p := &Portfolio{Tickers: []string{"A"}}
+		for i := 0; i < n; i++ {
+			p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: d, Return: 0.01})
+			p.PortfolioCloseValues = append(p.PortfolioCloseValues, 100)
+		}
+		p.GetBacktestingData(map[int64]float64{d.Unix(): 0}, map[string][]data.AssetData{}, 0)
+		m := p.Metrics
+		for name, v := range map[string]float64{
+			"SharpeRatio": m.SharpeRatio, "SortinoRatio": m.SortinoRatio,
+			"MaxDrawdown": m.MaxDrawdown, "AnnualReturn": m.AnnualReturn,
+			"StandardDev": m.StandardDev, "AvgCorrelation": m.AvgCorrelation,
+		} {
+			finite(t, name, v)
+		}
+	}
+}
+
+func TestGetBacktestingData_ReportsObservations(t *testing.T) {
+	p := &Portfolio{Tickers: []string{"A"}}
+	rf := map[int64]float64{}
+	for d := 1; d <= 10; d++ {
+		date := time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+		p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: date, Return: float64(d%3-1) * 0.01})
+		p.PortfolioCloseValues = append(p.PortfolioCloseValues, 100)
+		if d%4 != 0 {
+			rf[date.Unix()] = 0.0001
+		}
+	}
+	p.GetBacktestingData(rf, map[string][]data.AssetData{}, 0)
+	if p.Metrics.Observations != 10 || p.Metrics.RiskFreeFilled != 2 {
+		t.Errorf("observations = %d, filled = %d; want 10 and 2",
+			p.Metrics.Observations, p.Metrics.RiskFreeFilled)
+	}
+}
+
+// Each Sell closes a round trip priced at the position's average cost,
+// charged its share of the buys' fees.
+func TestGetBacktestingData_TradeStats(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	p, err := NewPortfolio("p", 10000, []string{"A", "B"}, "greedy", WithCommission(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []func() error{
+		func() error { return p.Buy("A", 10, 10, day(0)) },
+		func() error { return p.Buy("B", 1, 100, day(0)) },
+		func() error { return p.Sell("B", 1, 100, day(1)) },
+		func() error { return p.Buy("A", 10, 20, day(2)) },
+		func() error { return p.Sell("A", 10, 25, day(4)) },
+		func() error { return p.Sell("A", 10, 10, day(6)) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.PortfolioCloseValues = []float64{100, 80, 120}
+	p.DailyReturns = []DailyReturn{{Date: day(1), Return: -0.2}, {Date: day(2), Return: 0.5}}
+	p.GetBacktestingData(byDay(0, 0), map[string][]data.AssetData{}, 0)
+
+	// B: 0 - 2 fees. A: (25-15)*10 - 2 and (10-15)*10 - 2.
+	if got := p.ClosedTrades[1]; got.PnL != 98 || got.EntryPrice != 15 || !got.Entry.Equal(day(0)) {
+		t.Errorf("closed trade = %+v", got)
+	}
+	m := p.Metrics
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"ClosedTrades", float64(m.ClosedTrades), 3},
+		{"WinRate", m.WinRate, 100.0 / 3},
+		{"AvgWin", m.AvgWin, 98},
+		{"AvgLoss", m.AvgLoss, -27},
+		{"ProfitFactor", m.ProfitFactor, 98.0 / 54},
+		{"Expectancy", m.Expectancy, 44.0 / 3},
+		{"AvgHoldingDays", m.AvgHoldingDays, 11.0 / 3},
+		{"CalmarRatio", m.CalmarRatio, m.AnnualReturn / 20},
+	} {
+		if !closeTo(c.got, c.want) {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestValueAtRisk_Levels(t *testing.T) {
+	returns := make([]float64, 20) // -5% .. +14%
+	for i := range returns {
+		returns[i] = float64(i-5) / 100
+	}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithVaRLevels(0.9, 0.99))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range returns {
+		p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: time.Unix(int64(i)*86400, 0), Return: r})
+	}
+	p.GetBacktestingData(map[int64]float64{}, map[string][]data.AssetData{}, 0)
+	if len(p.Metrics.VaR) != 2 || p.Metrics.VaR[1].Confidence != 0.99 {
+		t.Errorf("VaR = %+v, want one per level", p.Metrics.VaR)
+	}
+	if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithVaRLevels(1)); err == nil {
+		t.Error("expected error for a confidence of 1")
+	}
+}
+
+func TestDrawdowns(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	values := []float64{100, 90, 95, 100, 120, 60, 80, 70}
+	p := &Portfolio{PortfolioCloseValues: values}
+	for i := range values {
+		p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: day(i)})
+	}
+	p.GetBacktestingData(map[int64]float64{}, map[string][]data.AssetData{}, 0)
+
+	m := p.Metrics
+	// 120 -> 60 on days 4..5, never recovered; 100 -> 90 on days 0..1,
+	// back on day 3.
+	want := []Drawdown{
+		{Peak: day(4), Trough: day(5), Depth: 50},
+		{Peak: day(0), Trough: day(1), Recovery: day(3), Depth: 10},
+	}
+	if len(m.Drawdowns) != len(want) {
+		t.Fatalf("drawdowns = %+v", m.Drawdowns)
+	}
+	for i, d := range m.Drawdowns {
+		if d != want[i] {
+			t.Errorf("drawdown %d = %+v, want %+v", i, d, want[i])
+		}
+	}
+	if m.MaxDrawdownDays != 1 || m.RecoveryDays != 0 || m.Drawdowns[1].RecoveryDays() != 2 {
+		t.Errorf("days = %d, recovery = %d, second recovery = %d",
+			m.MaxDrawdownDays, m.RecoveryDays, m.Drawdowns[1].RecoveryDays())
+	}
+	if !closeTo(m.MaxDrawdown, m.Drawdowns[0].Depth) {
+		t.Errorf("MaxDrawdown = %v, deepest = %v", m.MaxDrawdown, m.Drawdowns[0].Depth)
+	}
+}
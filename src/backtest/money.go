@@ -0,0 +1,72 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+)
+
+// Accounting selects how a Portfolio tracks cash.
+type Accounting string
+
+const (
+	// AccountingFloat keeps cash as a float64 updated in place. The
+	// default; over thousands of trades it accumulates rounding drift.
+	AccountingFloat Accounting = "float"
+	// AccountingCents keeps cash as an exact int64 count of cents. Every
+	// trade's notional is rounded half-to-even to the cent at the trade
+	// boundary, and BuyingPower is re-derived from the integer after each
+	// change, so cash never drifts and always reconciles with the ledger.
+	AccountingCents Accounting = "cents"
+)
+
+// ParseAccounting validates an Accounting config value; "" selects
+// AccountingFloat.
+func ParseAccounting(s string) (Accounting, error) {
+	switch a := Accounting(s); a {
+	case "":
+		return AccountingFloat, nil
+	case AccountingFloat, AccountingCents:
+		return a, nil
+	}
+	return "", fmt.Errorf("accounting %q: must be float or cents", s)
+}
+
+// toCents converts a dollar amount to whole cents, rounding half to even
+// so rounding errors don't bias in one direction across many trades.
+func toCents(v float64) int64 {
+	return int64(math.RoundToEven(v * 100))
+}
+
+// SetAccounting switches p's cash tracking. Switching to cents rounds the
+// current BuyingPower to the cent.
+func (p *Portfolio) SetAccounting(a Accounting) {
+	p.Accounting = a
+	if a == AccountingCents {
+		p.cashCents = toCents(p.BuyingPower)
+		p.BuyingPower = float64(p.cashCents) / 100
+	}
+}
+
+// canAfford reports whether settled cash, or margin, covers a purchase
+// of notional dollars.
+func (p *Portfolio) canAfford(notional float64) bool {
+	if p.Margin != nil {
+		return notional <= p.purchasingPower()
+	}
+	unsettled := p.unsettledTotal()
+	if p.Accounting == AccountingCents {
+		return toCents(notional) <= p.cashCents-toCents(unsettled)
+	}
+	return notional <= p.BuyingPower-unsettled
+}
+
+// adjustCash adds delta dollars (negative to spend) to cash under the
+// portfolio's Accounting mode.
+func (p *Portfolio) adjustCash(delta float64) {
+	if p.Accounting == AccountingCents {
+		p.cashCents += toCents(delta)
+		p.BuyingPower = float64(p.cashCents) / 100
+		return
+	}
+	p.BuyingPower += delta
+}
@@ -0,0 +1,77 @@
+package backtest
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// Thousands of odd-priced trades under cents accounting must leave cash
+// exactly equal to the initial cash plus the cent-rounded ledger, and
+// never negative.
+func TestAccountingCents_ReconcilesExactly(t *testing.T) {
+	p := &Portfolio{
+		BuyingPower: 10_000.005, InitialBuyingPower: 10_000.005,
+		Positions: map[string]*Position{},
+	}
+	p.SetAccounting(AccountingCents)
+	startCents := p.cashCents
+	if startCents != 1_000_000 {
+		t.Fatalf("seeded %d cents, want 1000000 (half-to-even)", startCents)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	tickers := []string{"A", "B", "C"}
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5000; i++ {
+		tk := tickers[rng.Intn(len(tickers))]
+		price := 1 + rng.Float64()*300
+		if rng.Intn(2) == 0 {
+			p.Buy(tk, float64(1+rng.Intn(20)), price, date)
+		} else if pos, ok := p.FindPosition(tk); ok {
+			p.Sell(tk, float64(1+rng.Intn(int(pos.Amount))), price, date)
+		}
+		if p.cashCents < 0 {
+			t.Fatalf("trade %d: cash went negative: %d cents", i, p.cashCents)
+		}
+		if p.BuyingPower != float64(p.cashCents)/100 {
+			t.Fatalf("trade %d: BuyingPower %v out of sync with %d cents",
+				i, p.BuyingPower, p.cashCents)
+		}
+	}
+
+	ledger := startCents
+	for _, tr := range p.Trades {
+		if tr.Side == "BUY" {
+			ledger -= toCents(tr.Amount * tr.Price)
+		} else {
+			ledger += toCents(tr.Amount * tr.Price)
+		}
+	}
+	if ledger != p.cashCents {
+		t.Errorf("cash %d cents, ledger says %d", p.cashCents, ledger)
+	}
+	if len(p.Trades) < 1000 {
+		t.Errorf("only %d trades executed; test is not exercising much", len(p.Trades))
+	}
+}
+
+func TestClone_KeepsCentsAccounting(t *testing.T) {
+	p, err := InitializePortfolio(
+		100.004, time.Time{}, time.Time{}, "p", []string{"A"}, "greedy", nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetAccounting(AccountingCents)
+	c, err := p.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Accounting != AccountingCents || c.cashCents != 10000 || c.BuyingPower != 100 {
+		t.Errorf("clone accounting %q, %d cents, %v cash", c.Accounting, c.cashCents, c.BuyingPower)
+	}
+	if _, err := ParseAccounting("decimal"); err == nil {
+		t.Error("expected error for unknown accounting mode")
+	}
+}
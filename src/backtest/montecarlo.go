@@ -0,0 +1,191 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"my-backtester/src/metrics"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// DefaultMonteCarloPercentiles are the bands a Monte Carlo analysis
+// reports when its config names none.
+var DefaultMonteCarloPercentiles = []float64{5, 25, 50, 75, 95}
+
+// MonteCarloConfig asks for a Monte Carlo robustness analysis of a run
+// (see WithMonteCarlo). Only Runs is required.
+type MonteCarloConfig struct {
+	Runs int `toml:"runs"` // resampled paths
+	// Method is "returns" (default), which bootstraps the daily returns,
+	// or "trades", which bootstraps the closed trades' profit and loss.
+	Method string `toml:"method"`
+	// Block is how many consecutive days "returns" draws at a time, to
+	// keep some of their autocorrelation; default 1.
+	Block       int       `toml:"block"`
+	Percentiles []float64 `toml:"percentiles"` // default DefaultMonteCarloPercentiles
+}
+
+// MonteCarlo is the distribution of a run's metrics over paths resampled
+// from it: how much of its result could be the luck of the order its
+// days or trades came in.
+type MonteCarlo struct {
+	Method string
+	Runs   int
+	// LossProbability is the share of paths ending below where they
+	// started.
+	LossProbability float64
+	Bands           []MonteCarloBand // in Percentiles order
+}
+
+// MonteCarloBand is each metric's value at one percentile of the paths.
+// Every metric is ranked on its own, so a band is not one path.
+type MonteCarloBand struct {
+	Percentile   float64
+	AnnualReturn float64 // percent
+	MaxDrawdown  float64 // percent
+	SharpeRatio  float64
+}
+
+// WithMonteCarlo resamples the run cfg.Runs times once it finishes and
+// reports the result as Portfolio.MonteCarlo. Paths are drawn from a
+// source seeded with the portfolio's Seed, so they repeat exactly.
+func WithMonteCarlo(cfg MonteCarloConfig) Option {
+	return func(p *Portfolio) error {
+		if cfg.Runs < 1 {
+			return fmt.Errorf("monte carlo runs %d: must be at least 1", cfg.Runs)
+		}
+		switch cfg.Method {
+		case "", "returns", "trades":
+		default:
+			return fmt.Errorf("monte carlo method %q: must be returns or trades", cfg.Method)
+		}
+		if cfg.Block < 0 {
+			return fmt.Errorf("monte carlo block %d: must not be negative", cfg.Block)
+		}
+		for _, pc := range cfg.Percentiles {
+			if !(pc >= 0 && pc <= 100) {
+				return fmt.Errorf("monte carlo percentile %v: must be in [0, 100]", pc)
+			}
+		}
+		p.MonteCarloConfig = &cfg
+		return nil
+	}
+}
+
+// monteCarlo runs cfg over a finished run's daily returns, their excess
+// over the risk-free rate (nil when there are none) and closed trades.
+func monteCarlo(
+	cfg *MonteCarloConfig, rng *rand.Rand, returns, excess []float64,
+	closed []ClosedTrade, initial, periodsPerYear float64,
+) *MonteCarlo {
+	method := cfg.Method
+	if method == "" {
+		method = "returns"
+	}
+	mc := &MonteCarlo{Method: method, Runs: cfg.Runs}
+	annual := make([]float64, cfg.Runs)
+	maxDD := make([]float64, cfg.Runs)
+	sharpe := make([]float64, cfg.Runs)
+	losses := 0
+	for i := 0; i < cfg.Runs; i++ {
+		var path []float64
+		if method == "trades" {
+			path, annual[i], sharpe[i] = tradePath(rng, closed, initial, float64(len(returns))/periodsPerYear)
+		} else {
+			path, annual[i], sharpe[i] = returnPath(rng, cfg.Block, returns, excess, periodsPerYear)
+		}
+		maxDD[i] = GetMaxDrawdown(path)
+		if n := len(path); n > 0 && path[n-1] < path[0] {
+			losses++
+		}
+	}
+	mc.LossProbability = float64(losses) / float64(cfg.Runs)
+
+	for _, s := range [][]float64{annual, maxDD, sharpe} {
+		sort.Float64s(s)
+	}
+	percentiles := cfg.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = DefaultMonteCarloPercentiles
+	}
+	for _, pc := range percentiles {
+		q := pc / 100
+		mc.Bands = append(mc.Bands, MonteCarloBand{
+			Percentile:   pc,
+			AnnualReturn: stat.Quantile(q, stat.Empirical, annual, nil),
+			MaxDrawdown:  stat.Quantile(q, stat.Empirical, maxDD, nil),
+			SharpeRatio:  stat.Quantile(q, stat.Empirical, sharpe, nil),
+		})
+	}
+	return mc
+}
+
+// returnPath draws len(returns) daily returns in blocks of block
+// consecutive days, with replacement, and returns the growth of 1 over
+// them with its annual return and Sharpe ratio. Excess returns are drawn
+// for the same days.
+func returnPath(
+	rng *rand.Rand, block int, returns, excess []float64, periodsPerYear float64,
+) (path []float64, annual, sharpe float64) {
+	n := len(returns)
+	if n == 0 {
+		return nil, 0, 0
+	}
+	if block < 1 {
+		block = 1
+	}
+	if block > n {
+		block = n
+	}
+	drawn := make([]float64, 0, n)
+	var drawnExcess []float64
+	path = append(make([]float64, 0, n+1), 1)
+	for len(drawn) < n {
+		start := rng.Intn(n - block + 1)
+		for j := start; j < start+block && len(drawn) < n; j++ {
+			drawn = append(drawn, returns[j])
+			if excess != nil {
+				drawnExcess = append(drawnExcess, excess[j])
+			}
+			path = append(path, path[len(path)-1]*(1+returns[j]))
+		}
+	}
+	if excess != nil {
+		sharpe = metrics.Sharpe(drawnExcess, periodsPerYear)
+	}
+	return path, metrics.AnnualReturn(drawn, periodsPerYear), sharpe
+}
+
+// tradePath draws len(closed) trades' profit and loss, with replacement,
+// and returns the equity they take initial to. Its annual return is over
+// the run's years; its Sharpe ratio is of each trade's return on the
+// equity before it, annualized by trades per year.
+func tradePath(
+	rng *rand.Rand, closed []ClosedTrade, initial, years float64,
+) (path []float64, annual, sharpe float64) {
+	if len(closed) == 0 || initial <= 0 {
+		return nil, 0, 0
+	}
+	path = append(make([]float64, 0, len(closed)+1), initial)
+	rets := make([]float64, 0, len(closed))
+	for range closed {
+		pnl := closed[rng.Intn(len(closed))].PnL
+		equity := path[len(path)-1]
+		if equity > 0 {
+			rets = append(rets, pnl/equity)
+		}
+		path = append(path, equity+pnl)
+	}
+	if years > 0 {
+		growth := path[len(path)-1] / initial
+		if growth <= 0 {
+			annual = -100
+		} else {
+			annual = (math.Pow(growth, 1/years) - 1) * 100
+		}
+		sharpe = metrics.Sharpe(rets, float64(len(closed))/years)
+	}
+	return path, annual, sharpe
+}
@@ -0,0 +1,77 @@
+package backtest
+
+import (
+	"math/rand"
+	"my-backtester/src/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonteCarlo(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	returns := []float64{0.01, 0.02, -0.03, 0.01, 0.02, -0.01, 0.03, -0.02}
+	newRun := func(cfg MonteCarloConfig) *Portfolio {
+		p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithMonteCarlo(cfg), WithSeed(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := 1000.0
+		for i, r := range returns {
+			value *= 1 + r
+			p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: day(i), Return: r})
+			p.PortfolioCloseValues = append(p.PortfolioCloseValues, value)
+		}
+		p.GetBacktestingData(nil, map[string][]data.AssetData{}, 0)
+		return p
+	}
+
+	a := newRun(MonteCarloConfig{Runs: 200, Block: 2})
+	mc := a.MonteCarlo
+	if mc == nil || mc.Method != "returns" || len(mc.Bands) != len(DefaultMonteCarloPercentiles) {
+		t.Fatalf("monte carlo = %+v", mc)
+	}
+	for i := 1; i < len(mc.Bands); i++ {
+		lo, hi := mc.Bands[i-1], mc.Bands[i]
+		if hi.AnnualReturn < lo.AnnualReturn || hi.MaxDrawdown < lo.MaxDrawdown {
+			t.Errorf("bands not ordered: %+v then %+v", lo, hi)
+		}
+	}
+	if mc.LossProbability <= 0 || mc.LossProbability >= 1 {
+		t.Errorf("loss probability = %v, want strictly between 0 and 1", mc.LossProbability)
+	}
+	if b := newRun(MonteCarloConfig{Runs: 200, Block: 2}); !reflect.DeepEqual(b.MonteCarlo, mc) {
+		t.Error("the same seed drew different paths")
+	}
+
+	// A block as long as the run redraws it unchanged.
+	whole := newRun(MonteCarloConfig{Runs: 10, Block: len(returns), Percentiles: []float64{50}}).MonteCarlo
+	if b := whole.Bands[0]; !closeTo(b.AnnualReturn, a.Metrics.AnnualReturn) || !closeTo(b.MaxDrawdown, a.Metrics.MaxDrawdown) {
+		t.Errorf("whole-run block = %+v, want the run's own metrics", b)
+	}
+
+	for _, bad := range []MonteCarloConfig{
+		{Runs: 0},
+		{Runs: 10, Method: "days"},
+		{Runs: 10, Block: -1},
+		{Runs: 10, Percentiles: []float64{101}},
+	} {
+		if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithMonteCarlo(bad)); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
+
+func TestTradePath(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	wins := []ClosedTrade{{PnL: 100}, {PnL: 50}}
+	path, annual, _ := tradePath(rng, wins, 1000, 1)
+	if len(path) != 3 || path[2] < 1100 || !closeTo(annual, (path[2]/1000-1)*100) {
+		t.Errorf("path = %v, annual = %v; want two winning trades over a year", path, annual)
+	}
+	mc := monteCarlo(&MonteCarloConfig{Runs: 20, Method: "trades"}, rng, make([]float64, 252),
+		nil, []ClosedTrade{{PnL: -50}}, 1000, 252)
+	if mc.LossProbability != 1 || !closeTo(mc.Bands[2].AnnualReturn, -5) || !closeTo(mc.Bands[2].MaxDrawdown, 5) {
+		t.Errorf("one losing trade = %+v", mc)
+	}
+}
@@ -0,0 +1,576 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// SearchMethod is how Optimize searches a space of configurations.
+type SearchMethod string
+
+const (
+	// SearchGenetic breeds each round's configurations from the best of
+	// the last (see geneticSearch).
+	SearchGenetic SearchMethod = "genetic"
+	// SearchBayes fits a Gaussian process to the scores so far and runs
+	// the configurations it expects to improve on them most, for
+	// strategies slow enough that every backtest counts (see
+	// bayesSearch).
+	SearchBayes SearchMethod = "bayes"
+	// SearchGrid runs every configuration, with each range cut into
+	// Steps values.
+	SearchGrid SearchMethod = "grid"
+)
+
+// ParseSearchMethod maps a config value to a SearchMethod; "" is
+// SearchGenetic.
+func ParseSearchMethod(s string) (SearchMethod, error) {
+	switch SearchMethod(s) {
+	case "", SearchGenetic:
+		return SearchGenetic, nil
+	case SearchBayes, SearchGrid:
+		return SearchMethod(s), nil
+	}
+	return "", fmt.Errorf("search method %q: must be genetic, bayes or grid", s)
+}
+
+// OptimizeConfig controls a search for a portfolio's best strategy
+// configuration (see Optimize). Strategies, Grid or Ranges gives it
+// something to search; the rest default as documented.
+type OptimizeConfig struct {
+	// Method is the search; default SearchGenetic.
+	Method SearchMethod `toml:"method"`
+	// Objective is the result field a configuration is scored by,
+	// highest first, or lowest first with a leading "-", as in
+	// WalkForwardConfig. Default "SharpeRatio".
+	Objective string `toml:"objective"`
+	// DrawdownPenalty is taken off the score per percent of
+	// MaxDrawdown, so a deep fall costs even an objective that ignores
+	// it; default 0.
+	DrawdownPenalty float64 `toml:"drawdown_penalty"`
+	// Strategies, Grid and Ranges are the search space: a configuration
+	// is one strategy with one value of each Grid key and one of each
+	// Ranges key within it, over the portfolio's own Params. Strategies
+	// and Grid are as in WalkForwardConfig.
+	Strategies []string              `toml:"strategies"`
+	Grid       map[string][]any      `toml:"grid"`
+	Ranges     map[string]ParamRange `toml:"ranges"`
+	// Steps is how many evenly spaced values SearchGrid tries across
+	// each of Ranges; default 5.
+	Steps int `toml:"steps"`
+	// Population is how many configurations each round runs: each
+	// generation of SearchGenetic (default 20), or the random first
+	// round of SearchBayes (default 10), each later one running Batch
+	// (default 1). Generations is the most rounds (default 20).
+	Population  int `toml:"population"`
+	Generations int `toml:"generations"`
+	Batch       int `toml:"batch"`
+	// Elite is how many of the best pass into SearchGenetic's next
+	// generation unchanged (default 2). CrossoverRate is the chance a
+	// child mixes two parents' genes rather than copying one (default
+	// 0.9), and MutationRate the chance each of its genes then changes
+	// (default 0.1).
+	Elite         int     `toml:"elite"`
+	CrossoverRate float64 `toml:"crossover_rate"`
+	MutationRate  float64 `toml:"mutation_rate"`
+	// Patience stops the search after that many rounds without a better
+	// score (default 5).
+	Patience int `toml:"patience"`
+	// PBOBlocks is how many blocks the configurations' returns are cut
+	// into to estimate the search's probability of backtest overfitting
+	// (see Metrics.PBO): an even number from 2 to 20, default
+	// DefaultPBOBlocks.
+	PBOBlocks int `toml:"pbo_blocks"`
+}
+
+// ParamRange is a continuous Params value searched between Min and Max,
+// inclusive; with Int, only whole numbers, passed as integers.
+type ParamRange struct {
+	Min float64 `toml:"min"`
+	Max float64 `toml:"max"`
+	Int bool    `toml:"int"`
+}
+
+// OptimizeGeneration is one round of a search: a generation of
+// SearchGenetic. It has the round's best and mean score, the best
+// configuration found so far, and how many configurations it ran that
+// no earlier round had.
+type OptimizeGeneration struct {
+	Generation int // from 1
+	Best       float64
+	Mean       float64
+	Strategy   string
+	Params     map[string]any
+	Evaluated  int
+}
+
+// optimizeDefaults fills c's unset fields and checks the rest.
+func (c *OptimizeConfig) optimizeDefaults() error {
+	method, err := ParseSearchMethod(string(c.Method))
+	if err != nil {
+		return fmt.Errorf("optimize %w", err)
+	}
+	c.Method = method
+	if c.Population == 0 {
+		c.Population = 20
+		if c.Method == SearchBayes {
+			c.Population = 10
+		}
+	}
+	if c.Generations == 0 {
+		c.Generations = 20
+	}
+	if c.Batch == 0 {
+		c.Batch = 1
+	}
+	if c.Steps == 0 {
+		c.Steps = 5
+	}
+	if c.Elite == 0 {
+		c.Elite = 2
+	}
+	if c.CrossoverRate == 0 {
+		c.CrossoverRate = 0.9
+	}
+	if c.MutationRate == 0 {
+		c.MutationRate = 0.1
+	}
+	if c.Patience == 0 {
+		c.Patience = 5
+	}
+	if c.PBOBlocks == 0 {
+		c.PBOBlocks = DefaultPBOBlocks
+	}
+	switch {
+	case c.Population < 2:
+		return fmt.Errorf("optimize population %d: must be at least 2", c.Population)
+	case c.Generations < 1:
+		return fmt.Errorf("optimize generations %d: must be at least 1", c.Generations)
+	case c.Batch < 1:
+		return fmt.Errorf("optimize batch %d: must be at least 1", c.Batch)
+	case c.Steps < 2:
+		return fmt.Errorf("optimize steps %d: must be at least 2", c.Steps)
+	case c.Elite < 0 || c.Elite >= c.Population:
+		return fmt.Errorf("optimize elite %d: must be in [0, population)", c.Elite)
+	case !(c.CrossoverRate >= 0 && c.CrossoverRate <= 1):
+		return fmt.Errorf("optimize crossover_rate %v: must be in [0, 1]", c.CrossoverRate)
+	case !(c.MutationRate >= 0 && c.MutationRate <= 1):
+		return fmt.Errorf("optimize mutation_rate %v: must be in [0, 1]", c.MutationRate)
+	case c.Patience < 1:
+		return fmt.Errorf("optimize patience %d: must be at least 1", c.Patience)
+	case c.PBOBlocks < 2 || c.PBOBlocks > maxPBOBlocks || c.PBOBlocks%2 != 0:
+		return fmt.Errorf("optimize pbo_blocks %d: must be even, from 2 to %d", c.PBOBlocks, maxPBOBlocks)
+	case c.DrawdownPenalty < 0:
+		return fmt.Errorf("optimize drawdown_penalty %v: must not be negative", c.DrawdownPenalty)
+	}
+	for k, vs := range c.Grid {
+		if len(vs) == 0 {
+			return fmt.Errorf("optimize grid %q: no values", k)
+		}
+	}
+	for k, r := range c.Ranges {
+		if _, ok := c.Grid[k]; ok {
+			return fmt.Errorf("optimize ranges %q: also in grid", k)
+		}
+		if !(r.Min <= r.Max) {
+			return fmt.Errorf("optimize ranges %q: min %v is above max %v", k, r.Min, r.Max)
+		}
+		if r.Int && math.Ceil(r.Min) > math.Floor(r.Max) {
+			return fmt.Errorf("optimize ranges %q: no whole number in [%v, %v]", k, r.Min, r.Max)
+		}
+	}
+	if _, _, err := objectiveField(c.Objective); err != nil {
+		return fmt.Errorf("optimize %w", err)
+	}
+	return nil
+}
+
+// A point is a configuration as coordinates: for the strategy and each
+// Grid key an index into its values, and for each of Ranges the value.
+type point []float64
+
+// dimension is one coordinate of a point: a choice among values, or a
+// range when values is nil.
+type dimension struct {
+	name     string // the Params key; "" for the strategy
+	values   []any
+	min, max float64
+	integer  bool
+}
+
+// searchSpace decodes points into candidates: the strategy first, then
+// the Grid keys and then the Ranges keys, each in sorted order.
+type searchSpace struct {
+	dims []dimension
+	base map[string]any
+}
+
+func newSearchSpace(cfg *OptimizeConfig, spec string, base map[string]any) searchSpace {
+	specs := cfg.Strategies
+	if len(specs) == 0 {
+		specs = []string{spec}
+	}
+	strategies := dimension{}
+	for _, s := range specs {
+		strategies.values = append(strategies.values, s)
+	}
+	s := searchSpace{dims: []dimension{strategies}, base: base}
+	for _, k := range sortedKeys(cfg.Grid) {
+		s.dims = append(s.dims, dimension{name: k, values: cfg.Grid[k]})
+	}
+	for _, k := range sortedKeys(cfg.Ranges) {
+		r := cfg.Ranges[k]
+		s.dims = append(s.dims, dimension{name: k, min: r.Min, max: r.Max, integer: r.Int})
+	}
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// snap rounds x into d: to a valid index of a choice, or into a range
+// and to a whole number when it is one.
+func (d dimension) snap(x float64) float64 {
+	if d.values != nil {
+		return math.Max(0, math.Min(float64(len(d.values)-1), math.Round(x)))
+	}
+	if d.integer {
+		x = math.Round(x)
+		return math.Max(math.Ceil(d.min), math.Min(math.Floor(d.max), x))
+	}
+	return math.Max(d.min, math.Min(d.max, x))
+}
+
+// unit maps x in d onto [0, 1], for comparing distances across
+// dimensions.
+func (d dimension) unit(x float64) float64 {
+	lo, hi := d.min, d.max
+	if d.values != nil {
+		lo, hi = 0, float64(len(d.values)-1)
+	}
+	if hi == lo {
+		return 0
+	}
+	return (x - lo) / (hi - lo)
+}
+
+func (s searchSpace) snap(pt point) point {
+	out := make(point, len(pt))
+	for i, d := range s.dims {
+		out[i] = d.snap(pt[i])
+	}
+	return out
+}
+
+func (s searchSpace) key(pt point) string { return fmt.Sprint([]float64(pt)) }
+
+func (s searchSpace) candidate(pt point) candidate {
+	params := make(map[string]any, len(s.base)+len(s.dims)-1)
+	for k, v := range s.base {
+		params[k] = v
+	}
+	for i, d := range s.dims[1:] {
+		switch x := pt[i+1]; {
+		case d.values != nil:
+			params[d.name] = d.values[int(x)]
+		case d.integer:
+			params[d.name] = int64(x)
+		default:
+			params[d.name] = x
+		}
+	}
+	return candidate{s.dims[0].values[int(pt[0])].(string), params}
+}
+
+func (s searchSpace) random(rng *rand.Rand) point {
+	pt := make(point, len(s.dims))
+	for i, d := range s.dims {
+		if d.values != nil {
+			pt[i] = float64(rng.Intn(len(d.values)))
+		} else {
+			pt[i] = d.snap(d.min + rng.Float64()*(d.max-d.min))
+		}
+	}
+	return pt
+}
+
+// grid is every point, with each range cut into steps values, in order,
+// or nil once there would be more than limit.
+func (s searchSpace) grid(steps, limit int) []point {
+	out := []point{{}}
+	for _, d := range s.dims {
+		var xs []float64
+		if d.values != nil {
+			for i := range d.values {
+				xs = append(xs, float64(i))
+			}
+		} else {
+			seen := make(map[float64]bool)
+			for i := 0; i < steps; i++ {
+				x := d.snap(d.min + (d.max-d.min)*float64(i)/float64(steps-1))
+				if !seen[x] {
+					seen[x] = true
+					xs = append(xs, x)
+				}
+			}
+		}
+		if len(out)*len(xs) > limit {
+			return nil
+		}
+		var next []point
+		for _, pt := range out {
+			for _, x := range xs {
+				next = append(next, append(append(point(nil), pt...), x))
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+// A searcher is one search method of Optimize: it proposes the points
+// to run each round and is told their scores before the next.
+type searcher interface {
+	// propose returns the next round's points; none ends the search.
+	propose() []point
+	// observe records the score of each point propose last returned, in
+	// order; -Inf for one that couldn't be scored.
+	observe(pts []point, scores []float64)
+}
+
+// maxGrid is the most points SearchGrid runs.
+const maxGrid = 100000
+
+// gridSearch proposes every point in one round.
+type gridSearch struct {
+	points []point
+	done   bool
+}
+
+func (g *gridSearch) propose() []point {
+	if g.done {
+		return nil
+	}
+	g.done = true
+	return g.points
+}
+
+func (g *gridSearch) observe([]point, []float64) {}
+
+// newSearcher builds cfg's searcher over space, drawing from rng. A
+// genetic search over a space no bigger than its population is a grid
+// search.
+func newSearcher(cfg *OptimizeConfig, space searchSpace, rng *rand.Rand) (searcher, error) {
+	if cfg.Method == SearchGenetic && len(cfg.Ranges) == 0 {
+		if pts := space.grid(cfg.Steps, cfg.Population); pts != nil {
+			return &gridSearch{points: pts}, nil
+		}
+	}
+	switch cfg.Method {
+	case SearchGrid:
+		pts := space.grid(cfg.Steps, maxGrid)
+		if pts == nil {
+			return nil, fmt.Errorf("optimize grid: more than %d configurations", maxGrid)
+		}
+		return &gridSearch{points: pts}, nil
+	case SearchBayes:
+		return &bayesSearch{cfg: cfg, space: space, rng: rng}, nil
+	}
+	return &geneticSearch{cfg: cfg, space: space, rng: rng}, nil
+}
+
+// Optimize searches cfg's strategies, grid and ranges for the
+// configuration of p with the best score over p's whole window, by
+// cfg.Method. Each round's configurations run together on the worker
+// pool, and none runs twice. The search stops when the method has
+// nothing more to propose, after cfg.Generations rounds, or once
+// cfg.Patience rounds in a row found nothing better. Random draws come
+// from a source seeded with p's Seed, so a search repeats. The returned
+// Result is the best configuration's run, under p's name, with the
+// search's rounds in Generations.
+func Optimize(ctx context.Context, store Store, p *Portfolio, cfg *OptimizeConfig) (Result, error) {
+	c := *cfg
+	if err := c.optimizeDefaults(); err != nil {
+		return Result{}, err
+	}
+	field, minimize, _ := objectiveField(c.Objective)
+	if len(p.Tickers) == 0 {
+		return Result{}, fmt.Errorf("optimize %s: no tickers", p.Pname)
+	}
+	space := newSearchSpace(&c, p.StrategySpec, p.StrategyParams)
+	search, err := newSearcher(&c, space, rand.New(rand.NewSource(p.Seed)))
+	if err != nil {
+		return Result{}, err
+	}
+	hist := store.QueryAssetsForTickers(ctx, allTickers([]*Portfolio{p}), p.StartTime, p.EndTime)
+	var rf map[int64]float64
+	if p.RiskFree == nil {
+		rf = DBRiskFree{store}.RiskFreeRates(ctx, riskFreeStart(p.StartTime), p.EndTime)
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	tmpl := *p
+	tmpl.store = store
+
+	score := func(r Result) float64 {
+		v, _ := resultValue(r, field)
+		s := v.(float64)
+		if minimize {
+			s = -s
+		}
+		s -= c.DrawdownPenalty * r.Metrics.MaxDrawdown
+		if math.IsNaN(s) {
+			return math.Inf(-1)
+		}
+		return s
+	}
+	seen := make(map[string]float64)
+	var returns [][]float64 // every configuration's, for pbo
+	var best Result
+	var bestPoint point
+	bestScore := math.Inf(-1)
+	// evaluate runs the points not run before, together, and scores
+	// every one.
+	evaluate := func(pts []point) ([]float64, int, error) {
+		var fresh []point
+		var runs []*Portfolio
+		queued := make(map[string]bool)
+		for _, pt := range pts {
+			k := space.key(pt)
+			if _, ok := seen[k]; ok || queued[k] {
+				continue
+			}
+			queued[k] = true
+			cand := space.candidate(pt)
+			v, err := tmpl.variant(cand, p.StartTime, p.EndTime, p.InitialBuyingPower)
+			if err != nil {
+				return nil, 0, fmt.Errorf("optimize %s: candidate %s: %w", p.Pname, cand.spec, err)
+			}
+			fresh = append(fresh, pt)
+			runs = append(runs, v)
+		}
+		results := runPortfolios(ctx, runs, hist, rf, nil, nil)
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		for i, r := range results {
+			returns = append(returns, r.Returns)
+			s := score(r)
+			seen[space.key(fresh[i])] = s
+			if s > bestScore || bestPoint == nil {
+				best, bestScore, bestPoint = r, s, fresh[i]
+			}
+		}
+		scores := make([]float64, len(pts))
+		for i, pt := range pts {
+			scores[i] = seen[space.key(pt)]
+		}
+		return scores, len(fresh), nil
+	}
+
+	var generations []OptimizeGeneration
+	stale := 0
+	for gen := 1; gen <= c.Generations; gen++ {
+		pts := search.propose()
+		if len(pts) == 0 {
+			break
+		}
+		prevBest := bestScore
+		scores, evaluated, err := evaluate(pts)
+		if err != nil {
+			return Result{}, err
+		}
+		search.observe(pts, scores)
+		if bestPoint == nil {
+			return Result{}, fmt.Errorf("optimize %s: no configuration ran", p.Pname)
+		}
+		mean, n := 0.0, 0
+		for _, s := range scores {
+			if !math.IsInf(s, -1) {
+				mean += s
+				n++
+			}
+		}
+		if n > 0 {
+			mean /= float64(n)
+		}
+		cand := space.candidate(bestPoint)
+		g := OptimizeGeneration{
+			Generation: gen, Best: bestScore, Mean: mean,
+			Strategy: cand.spec, Params: cand.params, Evaluated: evaluated,
+		}
+		generations = append(generations, g)
+		runLogger.Info("optimize generation",
+			"portfolio", p.Pname, "method", c.Method, "generation", gen, "objective", field,
+			"best", g.Best, "mean", g.Mean, "strategy", g.Strategy, "params", g.Params, "evaluated", evaluated)
+		if bestScore > prevBest {
+			stale = 0
+		} else if stale++; stale >= c.Patience {
+			runLogger.Info("optimize stopped early", "portfolio", p.Pname, "generation", gen, "patience", c.Patience)
+			break
+		}
+	}
+	best.PortfolioName = p.Pname
+	best.Generations = generations
+	// Runs cut short, by a drawdown halt or a lookahead, don't cover the
+	// same bars as the rest and are left out.
+	var full [][]float64
+	for _, rs := range returns {
+		if len(rs) == len(best.Returns) {
+			full = append(full, rs)
+		}
+	}
+	if v, ok := pbo(full, c.PBOBlocks); ok {
+		best.Metrics.PBO, best.Metrics.PBOConfigs = v, len(full)
+	}
+	runLogger.Info("optimize finished",
+		"portfolio", p.Pname, "method", c.Method, "objective", field, "best", bestScore,
+		"strategy", best.Strategy, "params", best.Params, "configurations", len(returns),
+		"pbo", best.Metrics.PBO, "pbo_configurations", best.Metrics.PBOConfigs)
+	return best, nil
+}
+
+// RunOptimize runs Optimize for each portfolio in turn, writing the
+// best configurations' results through output as Run does.
+func RunOptimize(
+	ctx context.Context,
+	store Store,
+	portfolios []*Portfolio,
+	cfg *OptimizeConfig,
+	output *OutputConfig,
+) ([]Result, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("optimize: no [Optimize] config")
+	}
+	return runEach(ctx, store, portfolios, output, func(ctx context.Context, store Store, p *Portfolio) (Result, error) {
+		return Optimize(ctx, store, p, cfg)
+	})
+}
+
+// objectiveField parses an objective such as "SharpeRatio" or
+// "-MaxDrawdown" into a numeric result field and whether lower is
+// better; "" is "SharpeRatio".
+func objectiveField(objective string) (field string, minimize bool, err error) {
+	field = objective
+	if field == "" {
+		field = "SharpeRatio"
+	}
+	field, minimize = strings.CutPrefix(field, "-")
+	if v, ok := resultValue(Result{}, field); !ok {
+		return "", false, fmt.Errorf("objective %q: unknown field", objective)
+	} else if _, numeric := v.(float64); !numeric {
+		return "", false, fmt.Errorf("objective %q: not a number", objective)
+	}
+	return field, minimize, nil
+}
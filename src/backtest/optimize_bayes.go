@@ -0,0 +1,220 @@
+package backtest
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// bayesCandidates is how many random points bayesSearch scores its
+// acquisition on to pick each proposal, half of them near the best so
+// far.
+const bayesCandidates = 2000
+
+// bayesSearch is SearchBayes: the first round runs Population random
+// points, and each later one the Batch points with the highest expected
+// improvement on the best score, under a Gaussian process fitted to
+// every score so far. The process has a squared-exponential kernel over
+// the points scaled to the unit cube, with a length scale of a quarter
+// of the cube's diagonal, and is fitted to the scores standardized. The
+// points of a batch are picked one at a time, each added to the fit at
+// its predicted score so the next goes elsewhere.
+type bayesSearch struct {
+	cfg   *OptimizeConfig
+	space searchSpace
+	rng   *rand.Rand
+	xs    [][]float64 // scaled points run, scored
+	ys    []float64
+	ran   map[string]bool
+}
+
+func (b *bayesSearch) propose() []point {
+	if b.ran == nil {
+		b.ran = make(map[string]bool)
+		pts := make([]point, 0, b.cfg.Population)
+		for len(pts) < b.cfg.Population {
+			pts = append(pts, b.space.random(b.rng))
+		}
+		return pts
+	}
+	if len(b.ys) < 2 {
+		return nil // nothing to fit
+	}
+	xs := append([][]float64(nil), b.xs...)
+	ys := append([]float64(nil), b.ys...)
+	var pts []point
+	for len(pts) < b.cfg.Batch {
+		gp, ok := fitGP(xs, ys, b.lengthScale())
+		if !ok {
+			break
+		}
+		best := math.Inf(-1)
+		var bestX point
+		for i, y := range ys {
+			if y > best {
+				best, bestX = y, b.unscale(xs[i])
+			}
+		}
+		var pick point
+		pickEI := -1.0
+		for i := 0; i < bayesCandidates; i++ {
+			pt := b.space.random(b.rng)
+			if i%2 == 1 {
+				pt = b.near(bestX)
+			}
+			k := b.space.key(pt)
+			if b.ran[k] {
+				continue
+			}
+			mu, sd := gp.predict(b.scale(pt))
+			if ei := expectedImprovement(mu, sd, best); ei > pickEI {
+				pick, pickEI = pt, ei
+			}
+		}
+		if pick == nil {
+			break // every point tried has run
+		}
+		b.ran[b.space.key(pick)] = true
+		pts = append(pts, pick)
+		mu, _ := gp.predict(b.scale(pick))
+		xs, ys = append(xs, b.scale(pick)), append(ys, mu)
+	}
+	return pts
+}
+
+func (b *bayesSearch) observe(pts []point, scores []float64) {
+	for i, pt := range pts {
+		b.ran[b.space.key(pt)] = true
+		if math.IsInf(scores[i], 0) || math.IsNaN(scores[i]) {
+			continue
+		}
+		b.xs, b.ys = append(b.xs, b.scale(pt)), append(b.ys, scores[i])
+	}
+}
+
+// near is a point a normal step of a tenth of each range's width, or a
+// random other value of one choice in ten, from pt.
+func (b *bayesSearch) near(pt point) point {
+	out := append(point(nil), pt...)
+	for i, d := range b.space.dims {
+		if d.values != nil {
+			if b.rng.Intn(10) == 0 {
+				out[i] = float64(b.rng.Intn(len(d.values)))
+			}
+			continue
+		}
+		out[i] = d.snap(out[i] + b.rng.NormFloat64()*(d.max-d.min)/10)
+	}
+	return out
+}
+
+func (b *bayesSearch) scale(pt point) []float64 {
+	out := make([]float64, len(pt))
+	for i, d := range b.space.dims {
+		out[i] = d.unit(pt[i])
+	}
+	return out
+}
+
+func (b *bayesSearch) unscale(x []float64) point {
+	pt := make(point, len(x))
+	for i, d := range b.space.dims {
+		lo, hi := d.min, d.max
+		if d.values != nil {
+			lo, hi = 0, float64(len(d.values)-1)
+		}
+		pt[i] = d.snap(lo + x[i]*(hi-lo))
+	}
+	return pt
+}
+
+func (b *bayesSearch) lengthScale() float64 {
+	return math.Sqrt(float64(len(b.space.dims))) / 4
+}
+
+// gaussianProcess is a fitted Gaussian process regression.
+type gaussianProcess struct {
+	xs        [][]float64
+	chol      mat.Cholesky
+	alpha     *mat.VecDense
+	scale     float64 // length scale
+	mean, std float64 // of the scores it was fitted to
+}
+
+// gpNoise is added to the kernel's diagonal, for the noise in a score
+// and to keep the matrix positive definite.
+const gpNoise = 1e-4
+
+// fitGP fits a Gaussian process to scores ys at points xs; false when
+// the kernel matrix can't be factored.
+func fitGP(xs [][]float64, ys []float64, scale float64) (*gaussianProcess, bool) {
+	n := len(xs)
+	gp := &gaussianProcess{xs: xs, scale: scale}
+	gp.mean, gp.std = stat.MeanStdDev(ys, nil)
+	if gp.std == 0 || math.IsNaN(gp.std) {
+		gp.std = 1
+	}
+	k := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			v := gp.kernel(xs[i], xs[j])
+			if i == j {
+				v += gpNoise
+			}
+			k.SetSym(i, j, v)
+		}
+	}
+	if !gp.chol.Factorize(k) {
+		return nil, false
+	}
+	y := mat.NewVecDense(n, nil)
+	for i, v := range ys {
+		y.SetVec(i, (v-gp.mean)/gp.std)
+	}
+	gp.alpha = mat.NewVecDense(n, nil)
+	if err := gp.chol.SolveVecTo(gp.alpha, y); err != nil {
+		return nil, false
+	}
+	return gp, true
+}
+
+func (gp *gaussianProcess) kernel(a, b []float64) float64 {
+	d := 0.0
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Exp(-d / (2 * gp.scale * gp.scale))
+}
+
+// predict is the process's mean and standard deviation at x, in the
+// scores' units.
+func (gp *gaussianProcess) predict(x []float64) (mu, sd float64) {
+	n := len(gp.xs)
+	k := mat.NewVecDense(n, nil)
+	for i, xi := range gp.xs {
+		k.SetVec(i, gp.kernel(x, xi))
+	}
+	mu = mat.Dot(k, gp.alpha)
+	v := mat.NewVecDense(n, nil)
+	if err := gp.chol.SolveVecTo(v, k); err != nil {
+		return gp.mean + mu*gp.std, 0
+	}
+	variance := 1 - mat.Dot(k, v)
+	if variance < 0 {
+		variance = 0
+	}
+	return gp.mean + mu*gp.std, math.Sqrt(variance) * gp.std
+}
+
+// expectedImprovement is how far above best a score with mean mu and
+// standard deviation sd is expected to land.
+func expectedImprovement(mu, sd, best float64) float64 {
+	if sd == 0 {
+		return math.Max(0, mu-best)
+	}
+	z := (mu - best) / sd
+	return (mu-best)*distuv.UnitNormal.CDF(z) + sd*distuv.UnitNormal.Prob(z)
+}
@@ -0,0 +1,84 @@
+package backtest
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// geneticSearch is SearchGenetic. The first generation is drawn at
+// random; after that the Elite best carry over and the rest are
+// children of parents picked by two-way tournaments.
+type geneticSearch struct {
+	cfg    *OptimizeConfig
+	space  searchSpace
+	rng    *rand.Rand
+	ranked []scored // the last generation, best first
+}
+
+// scored is a point with the score of its run.
+type scored struct {
+	pt    point
+	score float64
+}
+
+func (g *geneticSearch) propose() []point {
+	next := make([]point, 0, g.cfg.Population)
+	if g.ranked == nil {
+		for len(next) < g.cfg.Population {
+			next = append(next, g.space.random(g.rng))
+		}
+		return next
+	}
+	for i := 0; i < g.cfg.Elite && i < len(g.ranked); i++ {
+		next = append(next, g.ranked[i].pt)
+	}
+	for len(next) < g.cfg.Population {
+		next = append(next, g.child(g.tournament(), g.tournament()))
+	}
+	return next
+}
+
+func (g *geneticSearch) observe(pts []point, scores []float64) {
+	g.ranked = make([]scored, len(pts))
+	for i, pt := range pts {
+		g.ranked[i] = scored{pt, scores[i]}
+	}
+	sort.SliceStable(g.ranked, func(i, j int) bool { return g.ranked[i].score > g.ranked[j].score })
+}
+
+func (g *geneticSearch) tournament() point {
+	a, b := g.ranked[g.rng.Intn(len(g.ranked))], g.ranked[g.rng.Intn(len(g.ranked))]
+	if b.score > a.score {
+		return b.pt
+	}
+	return a.pt
+}
+
+// child breeds a point from a and b: a uniform crossover with
+// probability CrossoverRate, else a copy of a, then each gene mutated
+// with probability MutationRate. A choice mutates to another of its
+// values, and a range by a normal step of a tenth of its width.
+func (g *geneticSearch) child(a, b point) point {
+	c := append(point(nil), a...)
+	if g.rng.Float64() < g.cfg.CrossoverRate {
+		for i := range c {
+			if g.rng.Intn(2) == 1 {
+				c[i] = b[i]
+			}
+		}
+	}
+	for i, d := range g.space.dims {
+		if g.rng.Float64() >= g.cfg.MutationRate {
+			continue
+		}
+		if n := len(d.values); d.values != nil {
+			if n > 1 {
+				// Any other value, so a mutation always changes something.
+				c[i] = float64((int(c[i]) + 1 + g.rng.Intn(n-1)) % n)
+			}
+			continue
+		}
+		c[i] = d.snap(c[i] + g.rng.NormFloat64()*(d.max-d.min)/10)
+	}
+	return c
+}
@@ -0,0 +1,181 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A rising ticker, and a Lua strategy that buys params.qty shares of it
+// once: the more shares the better, whatever the unused params.x.
+func TestOptimize(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 30; i++ {
+		price := 10 + float64(i)
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: day(i), Open: price, High: price, Low: price, Close: price, Volume: 100,
+		})
+		store.rates[day(i).Unix()] = 0
+	}
+	script := filepath.Join(t.TempDir(), "qty.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  if position("A") == nil then
+    buy("A", params.qty, close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := InitializePortfolio(1000, day(0), day(29), "opt", []string{"A"}, "lua:"+script, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var qty, x []any
+	for i := 1; i <= 10; i++ {
+		qty, x = append(qty, int64(i)), append(x, int64(i))
+	}
+	cfg := &OptimizeConfig{
+		Objective: "AnnualReturn", Population: 10, Generations: 30, Patience: 10,
+		Grid: map[string][]any{"qty": qty, "x": x},
+	}
+	r, err := Optimize(context.Background(), store, p, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PortfolioName != "opt" || r.Params["qty"] != int64(10) {
+		t.Fatalf("best = %s with %v, want opt with qty 10", r.PortfolioName, r.Params)
+	}
+	evaluated := 0
+	for i, g := range r.Generations {
+		evaluated += g.Evaluated
+		if i > 0 && g.Best < r.Generations[i-1].Best {
+			t.Errorf("generation %d best %v fell from %v", g.Generation, g.Best, r.Generations[i-1].Best)
+		}
+	}
+	if n := len(r.Generations); n < 2 || n > cfg.Generations || evaluated > 100 {
+		t.Errorf("%d generations ran %d configurations; want a few, none twice", n, evaluated)
+	}
+	if last := r.Generations[len(r.Generations)-1]; last.Best != r.Metrics.AnnualReturn || last.Params["qty"] != int64(10) {
+		t.Errorf("last generation = %+v, want the returned run", last)
+	}
+	again, err := Optimize(context.Background(), store, p, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Generations, r.Generations) {
+		t.Error("the same seed bred different generations")
+	}
+
+	// A space no bigger than the population runs whole, once; the
+	// drawdown penalty makes no difference without a drawdown.
+	small, err := Optimize(context.Background(), store, p, &OptimizeConfig{
+		Objective: "AnnualReturn", DrawdownPenalty: 1, Grid: map[string][]any{"qty": {int64(1), int64(2)}},
+		PBOBlocks: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(small.Generations) != 1 || small.Generations[0].Evaluated != 2 || small.Params["qty"] != int64(2) {
+		t.Errorf("small space = %+v, want one generation of both", small.Generations)
+	}
+	if m := small.Metrics; m.PBOConfigs != 2 || m.PBO < 0 || m.PBO > 1 {
+		t.Errorf("pbo = %v over %d configurations, want a probability over 2", m.PBO, m.PBOConfigs)
+	}
+	// 30 bars are too few for the default 16 blocks.
+	if r.Metrics.PBOConfigs != 0 {
+		t.Errorf("pbo estimated over %d configurations from 30 bars", r.Metrics.PBOConfigs)
+	}
+
+	for _, bad := range []OptimizeConfig{
+		{Population: 1},
+		{Elite: 20},
+		{MutationRate: 2},
+		{Objective: "Strategy"},
+		{Grid: map[string][]any{"qty": {}}},
+		{PBOBlocks: 3},
+		{PBOBlocks: 22},
+	} {
+		if _, err := Optimize(context.Background(), store, p, &bad); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
+
+// A ticker that peaks on bar 14, and a strategy that holds it for
+// params.hold bars: a Bayesian search should find the peak in fewer
+// runs than there are holds to try.
+func TestOptimize_Bayes(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 30; i++ {
+		price := 10 + float64(i)
+		if i > 14 {
+			price = 24 - float64(i-14)
+		}
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: day(i), Open: price, High: price, Low: price, Close: price, Volume: 100,
+		})
+		store.rates[day(i).Unix()] = 0
+	}
+	script := filepath.Join(t.TempDir(), "hold.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  if day == 0 then
+    buy("A", params.qty or 10, close_at("A", day), day)
+  elseif day == params.hold then
+    sell_all("A", close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := InitializePortfolio(1000, day(0), day(29), "bayes", []string{"A"}, "lua:"+script, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &OptimizeConfig{
+		Method: SearchBayes, Objective: "AnnualReturn", Population: 5, Generations: 15, Patience: 15,
+		Ranges: map[string]ParamRange{"hold": {Min: 1, Max: 28, Int: true}},
+	}
+	r, err := Optimize(context.Background(), store, p, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	for _, g := range r.Generations {
+		runs += g.Evaluated
+	}
+	if r.Params["hold"] != int64(14) || runs >= 28 {
+		t.Errorf("best hold = %v after %d runs, want 14 in fewer than 28", r.Params["hold"], runs)
+	}
+
+	// A continuous range passes floats; the grid cuts it into steps.
+	grid, err := Optimize(context.Background(), store, p, &OptimizeConfig{
+		Method: SearchGrid, Objective: "AnnualReturn", Steps: 3,
+		Grid:   map[string][]any{"hold": {int64(14)}},
+		Ranges: map[string]ParamRange{"qty": {Min: 1, Max: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grid.Generations) != 1 || grid.Generations[0].Evaluated != 3 || grid.Params["qty"] != 10.0 {
+		t.Errorf("grid = %+v, want one round of qty 1, 5.5 and 10, best 10", grid.Generations)
+	}
+
+	for _, bad := range []OptimizeConfig{
+		{Method: "annealing"},
+		{Ranges: map[string]ParamRange{"hold": {Min: 2, Max: 1}}},
+		{Ranges: map[string]ParamRange{"hold": {Min: 1.2, Max: 1.8, Int: true}}},
+		{Grid: map[string][]any{"hold": {1}}, Ranges: map[string]ParamRange{"hold": {Max: 1}}},
+	} {
+		if _, err := Optimize(context.Background(), store, p, &bad); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package backtest
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Option configures a Portfolio built by NewPortfolio. New settings are
+// added as another With* function rather than another positional
+// parameter, so existing callers keep compiling.
+type Option func(*Portfolio) error
+
+// NewPortfolio builds a portfolio named name that starts with buyingPower
+// in cash and trades tickers with the strategy strategySpec (see
+// NewStrategy). Options are applied in order.
+func NewPortfolio(
+	name string,
+	buyingPower float64,
+	tickers []string,
+	strategySpec string,
+	opts ...Option,
+) (*Portfolio, error) {
+	p := &Portfolio{
+		Pname:              name,
+		BuyingPower:        buyingPower,
+		InitialBuyingPower: buyingPower,
+		Positions:          make(map[string]*Position),
+		Tickers:            tickers,
+		StrategySpec:       strategySpec,
+		Fill:               FillClose,
+		Calendar:           CalendarEquities,
+		Timeframe:          TimeframeDaily,
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, fmt.Errorf("portfolio %q: %w", name, err)
+		}
+	}
+	strat, err := p.newStrategy()
+	if err != nil {
+		return nil, fmt.Errorf("portfolio %q: %w", name, err)
+	}
+	p.Strategy = strat
+	// Strategies that know their own universe (e.g. a replayed trade
+	// list) supply it when the config omits Tickers.
+	if ts, ok := strat.(interface{ Tickers() []string }); ok && len(p.Tickers) == 0 {
+		p.Tickers = ts.Tickers()
+	}
+	days := int(p.EndTime.Sub(p.StartTime).Hours() / 24)
+	if days < 0 {
+		days = 0
+	}
+	p.DailyReturns = make([]DailyReturn, 0, days)
+	p.PortfolioCloseValues = make([]float64, 0, days)
+	return p, nil
+}
+
+// WithWindow limits the backtest to bars dated start through end. A zero
+// time leaves that side open.
+func WithWindow(start, end time.Time) Option {
+	return func(p *Portfolio) error {
+		p.StartTime, p.EndTime = start, end
+		return nil
+	}
+}
+
+// WithParams passes typed parameters to the strategy (see NewStrategy).
+func WithParams(params map[string]any) Option {
+	return func(p *Portfolio) error {
+		p.StrategyParams = params
+		return nil
+	}
+}
+
+// WithFill selects the fill model; window is the bar count for FillVWAP.
+func WithFill(model FillModel, window int) Option {
+	return func(p *Portfolio) error {
+		if _, err := ParseFillModel(string(model)); err != nil {
+			return err
+		}
+		p.Fill, p.FillWindow = model, window
+		return nil
+	}
+}
+
+// WithAccounting selects float or exact-cents cash tracking.
+func WithAccounting(a Accounting) Option {
+	return func(p *Portfolio) error {
+		if _, err := ParseAccounting(string(a)); err != nil {
+			return err
+		}
+		p.SetAccounting(a)
+		return nil
+	}
+}
+
+// WithLotMethod selects average-cost, FIFO or LIFO lot accounting.
+func WithLotMethod(m LotMethod) Option {
+	return func(p *Portfolio) error {
+		if _, err := ParseLotMethod(string(m)); err != nil {
+			return err
+		}
+		p.LotMethod = m
+		return nil
+	}
+}
+
+// WithAuditLookahead enables the lookahead audit (see AuditLookahead).
+func WithAuditLookahead() Option {
+	return func(p *Portfolio) error {
+		p.AuditLookahead = true
+		return nil
+	}
+}
+
+// WithShorting lets the portfolio sell short (see AllowShort).
+func WithShorting() Option {
+	return func(p *Portfolio) error {
+		p.AllowShort = true
+		return nil
+	}
+}
+
+// WithCommission charges a flat fee, in dollars, on every fill.
+func WithCommission(perTrade float64) Option {
+	return func(p *Portfolio) error {
+		if !(perTrade >= 0) || math.IsInf(perTrade, 1) {
+			return fmt.Errorf("commission %v: must be a finite amount >= 0", perTrade)
+		}
+		p.Commission = perTrade
+		return nil
+	}
+}
+
+// WithSlippage moves every fill bps basis points against the trade: buys
+// pay more, sells receive less.
+func WithSlippage(bps float64) Option {
+	return func(p *Portfolio) error {
+		if !(bps >= 0 && bps < 10_000) {
+			return fmt.Errorf("slippage %v bps: must be in [0, 10000)", bps)
+		}
+		p.SlippageBps = bps
+		return nil
+	}
+}
+
+// WithCalendar sets the trading calendar metrics annualize by.
+func WithCalendar(c Calendar) Option {
+	return func(p *Portfolio) error {
+		if _, err := ParseCalendar(string(c)); err != nil {
+			return err
+		}
+		p.Calendar = c
+		return nil
+	}
+}
+
+// WithTimeframe simulates on weekly or monthly bars resampled from the
+// daily data (see Timeframe).
+func WithTimeframe(tf Timeframe) Option {
+	return func(p *Portfolio) error {
+		if _, err := ParseTimeframe(string(tf)); err != nil {
+			return err
+		}
+		p.Timeframe = tf
+		return nil
+	}
+}
+
+// WithBenchmark compares the portfolio against buying and holding ticker
+// over the same bars, reported as Metrics.BenchmarkReturn.
+func WithBenchmark(ticker string) Option {
+	return func(p *Portfolio) error {
+		p.Benchmark = ticker
+		return nil
+	}
+}
+
+// WithVaRLevels sets the confidence levels, each in (0, 1), that VaR and
+// CVaR are reported at, e.g. 0.95 and 0.99.
+func WithVaRLevels(levels ...float64) Option {
+	return func(p *Portfolio) error {
+		for _, c := range levels {
+			if !(c > 0 && c < 1) {
+				return fmt.Errorf("VaR confidence %v: must be in (0, 1)", c)
+			}
+		}
+		p.VaRLevels = levels
+		return nil
+	}
+}
+
+// WithSeed seeds the portfolio's random source (see Portfolio.Rand), so
+// strategies that draw random numbers repeat exactly.
+func WithSeed(seed int64) Option {
+	return func(p *Portfolio) error {
+		p.Seed = seed
+		return nil
+	}
+}
+
+// WithLogger sends the portfolio's ledger records to l instead of
+// TransactionLogger.
+func WithLogger(l *slog.Logger) Option {
+	return func(p *Portfolio) error {
+		p.Logger = l
+		return nil
+	}
+}
@@ -0,0 +1,224 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"math"
+	"my-backtester/src/data"
+	"my-backtester/src/metrics"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewPortfolio_Options(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	var buf bytes.Buffer
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy",
+		WithWindow(start, end),
+		WithFill(FillVWAP, 3),
+		WithAccounting(AccountingCents),
+		WithCommission(1),
+		WithSlippage(10),
+		WithCalendar(CalendarCrypto),
+		WithBenchmark("SPY"),
+		WithSeed(7),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.StartTime != start || p.EndTime != end || p.Fill != FillVWAP || p.FillWindow != 3 ||
+		p.Accounting != AccountingCents || p.Commission != 1 || p.SlippageBps != 10 ||
+		p.Calendar != CalendarCrypto || p.Benchmark != "SPY" || p.Seed != 7 {
+		t.Errorf("options not applied: %+v", p)
+	}
+	c, err := p.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Commission != 1 || c.SlippageBps != 10 || c.Calendar != CalendarCrypto ||
+		c.Benchmark != "SPY" || c.Seed != 7 || c.Logger != p.Logger {
+		t.Errorf("Clone dropped options: %+v", c)
+	}
+	if err := c.Buy("A", 1, 100, start); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "msg=BUY portfolio=p ticker=A") {
+		t.Errorf("WithLogger did not receive the trade: %q", buf.String())
+	}
+
+	for _, opt := range []Option{
+		WithCommission(-1), WithSlippage(10_000), WithCalendar("lunar"),
+		WithFill("midpoint", 0), WithAccounting("bitcoin"),
+	} {
+		if _, err := NewPortfolio("bad", 1000, []string{"A"}, "greedy", opt); err == nil {
+			t.Error("invalid option accepted")
+		}
+	}
+}
+
+func TestCosts_AppliedToFills(t *testing.T) {
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy",
+		WithCommission(2), WithSlippage(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := p.Buy("A", 5, 100, day); err != nil {
+		t.Fatal(err)
+	}
+	// 5 shares at 101 (+1%) plus the $2 fee.
+	if want := 1000 - 5*101.0 - 2; math.Abs(p.BuyingPower-want) > 1e-9 {
+		t.Errorf("cash after buy = %v, want %v", p.BuyingPower, want)
+	}
+	if err := p.Sell("A", 5, 100, day); err != nil {
+		t.Fatal(err)
+	}
+	// Sold at 99 (-1%), less another $2.
+	if want := 1000 - 5*101.0 - 2 + 5*99.0 - 2; math.Abs(p.BuyingPower-want) > 1e-9 {
+		t.Errorf("cash after sell = %v, want %v", p.BuyingPower, want)
+	}
+	if tr := p.Trades[0]; tr.Price != 101 || tr.Fee != 2 {
+		t.Errorf("buy trade = %+v", tr)
+	}
+	if tr := p.Trades[1]; tr.Price != 99 || tr.Fee != 2 {
+		t.Errorf("sell trade = %+v", tr)
+	}
+
+	// Sizing leaves room for costs, so a greedy buy isn't rejected.
+	amount := p.maxBuy("A", 100, "greedy")
+	if err := p.Buy("A", amount, 100, day); err != nil {
+		t.Errorf("maxBuy %v shares rejected: %v", amount, err)
+	}
+}
+
+func TestCalendar_Annualization(t *testing.T) {
+	rets := make([]float64, 365)
+	for i := range rets {
+		rets[i] = 0.001
+	}
+	year := math.Pow(1.001, 365) - 1
+	if got := metrics.AnnualReturn(rets, CalendarCrypto.PeriodsPerYear()); math.Abs(got-year*100) > 1e-9 {
+		t.Errorf("crypto annual return = %v, want %v", got, year*100)
+	}
+	// Read as 252-day years, the same bars span more than a year.
+	if got := GetAnnualReturn(rets); got >= year*100 {
+		t.Errorf("equities annual return = %v, want less than %v", got, year*100)
+	}
+}
+
+func TestRun_BenchmarkReturn(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 5; i++ {
+		store.bars["A"] = append(store.bars["A"], data.AssetData{Date: day(i), Close: 10})
+		store.bars["SPY"] = append(store.bars["SPY"], data.AssetData{Date: day(i), Close: 100 + float64(i)})
+	}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy",
+		WithWindow(day(1), day(3)), WithBenchmark("SPY"), WithCalendar(CalendarCrypto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SPY over the same three bars: 101 -> 103.
+	want := (math.Pow(103.0/101.0, 365.0/2) - 1) * 100
+	if got := results[0].Metrics.BenchmarkReturn; math.Abs(got-want) > 1e-6 {
+		t.Errorf("BenchmarkReturn = %v, want %v", got, want)
+	}
+}
+
+func TestLuaRandom_Seeded(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "coin.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  if math.random(2) == 1 then
+    buy("A", 1, close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hist := make(map[string][]data.AssetData)
+	for i := 0; i < 64; i++ {
+		hist["A"] = append(hist["A"], data.AssetData{
+			Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i), Close: 10,
+		})
+	}
+	days := func(seed int64) []time.Time {
+		p, err := NewPortfolio("p", 1e6, []string{"A"}, "lua:"+script, WithSeed(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		runOne(context.Background(), p, hist, nil)
+		var out []time.Time
+		for _, tr := range p.Trades {
+			out = append(out, tr.Date)
+		}
+		return out
+	}
+	a, b := days(42), days(42)
+	if len(a) == 0 || len(a) == 64 {
+		t.Fatalf("coin flips bought on %d of 64 days", len(a))
+	}
+	if len(a) != len(b) {
+		t.Fatalf("same seed bought %d then %d times", len(a), len(b))
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			t.Fatalf("same seed diverged at trade %d", i)
+		}
+	}
+	if c := days(43); len(c) == len(a) && c[0].Equal(a[0]) && c[len(c)-1].Equal(a[len(a)-1]) {
+		t.Error("different seeds drew the same flips")
+	}
+}
+
+// A run-wide Seed reaches every portfolio without one, offset by its
+// place in the config, and survives the manifest's config round trip.
+func TestConfig_ApplySeed(t *testing.T) {
+	cfg, err := ParseConfig(`
+Seed = 100
+
+[[portfolio]]
+Name = "a"
+
+[[portfolio]]
+Name = "b"
+Seed = 7
+
+[[portfolio]]
+Name = "c"
+`, "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ApplySeed()
+	var seeds []int64
+	for _, pc := range cfg.Portfolios {
+		seeds = append(seeds, pc.Seed)
+	}
+	if !reflect.DeepEqual(seeds, []int64{100, 7, 102}) {
+		t.Errorf("seeds = %v, want [100 7 102]", seeds)
+	}
+	text, err := configTOML(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseConfig(text, "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.ApplySeed()
+	if again.Seed != 100 || again.Portfolios[2].Seed != 102 {
+		t.Errorf("round trip = %d, %+v", again.Seed, again.Portfolios)
+	}
+}
@@ -0,0 +1,297 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+	"time"
+)
+
+// Order rejection reasons. Buy, Sell and Order wrap them in an
+// *OrderError, so callers test the reason with errors.Is.
+var (
+	ErrInvalidTicker      = errors.New("ticker is empty")
+	ErrInvalidAmount      = errors.New("amount must be a positive, finite number")
+	ErrInvalidPrice       = errors.New("price must be a positive, finite number")
+	ErrInvalidSide        = errors.New(`side must be "BUY" or "SELL"`)
+	ErrInsufficientFunds  = errors.New("insufficient buying power")
+	ErrInsufficientShares = errors.New("insufficient shares")
+	ErrInvalidOrderType   = errors.New("order type must be market, limit, stop, stop_limit or trailing_stop")
+	ErrInvalidTrail       = errors.New("trail must be a fraction in (0, 1)")
+	ErrExecution          = errors.New("executor failed the order")
+)
+
+// OrderError reports an order the Portfolio refused. The portfolio is
+// left unchanged.
+type OrderError struct {
+	Side   string
+	Ticker string
+	Amount float64
+	Price  float64
+	Err    error
+}
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("%s %g %s @ %g: %v", e.Side, e.Amount, e.Ticker, e.Price, e.Err)
+}
+
+func (e *OrderError) Unwrap() error { return e.Err }
+
+// validateOrder rejects inputs no order can legitimately carry: a
+// negative buy would raise cash, a NaN price would poison it, and a zero
+// amount is almost always a sizing bug (e.g. truncating cash/price when
+// the price exceeds cash).
+func validateOrder(side, ticker string, amount, price float64) error {
+	var err error
+	switch {
+	case side != "BUY" && side != "SELL":
+		err = ErrInvalidSide
+	case ticker == "":
+		err = ErrInvalidTicker
+	case !(amount > 0) || math.IsInf(amount, 0):
+		err = ErrInvalidAmount
+	case !(price > 0) || math.IsInf(price, 0):
+		err = ErrInvalidPrice
+	}
+	if err != nil {
+		return &OrderError{Side: side, Ticker: ticker, Amount: amount, Price: price, Err: err}
+	}
+	return nil
+}
+
+// OrderType selects when a submitted Order fills.
+type OrderType string
+
+const (
+	OrderMarket       OrderType = "market"        // the next bar's open
+	OrderLimit        OrderType = "limit"         // Limit or better
+	OrderStop         OrderType = "stop"          // once the price reaches Stop
+	OrderStopLimit    OrderType = "stop_limit"    // a limit order once Stop is reached
+	OrderTrailingStop OrderType = "trailing_stop" // a stop Trail behind the best price since submission
+)
+
+// Order is a resting order a strategy submits with Portfolio.Submit.
+// Unlike Portfolio.Order, which executes under the FillModel on the bar
+// it is placed, a submitted order is first evaluated against the next
+// bar and fills only if that bar's Open..High..Low range reaches its
+// price: buy limits fill at min(Open, Limit) once Low <= Limit, buy
+// stops at max(Open, Stop) once High >= Stop, and sells mirror them, so
+// gaps through the price fill at the open. Costs apply as for any fill.
+type Order struct {
+	Ticker string
+	Side   string // "BUY" or "SELL"
+	Amount float64
+	Type   OrderType // "" is OrderMarket
+	Limit  float64   // limit and stop_limit
+	Stop   float64   // stop and stop_limit
+	// Trail is a trailing stop's distance from the highest High (sells)
+	// or lowest Low (buys) since submission, as a fraction, e.g. 0.05.
+	Trail float64
+	// Expire cancels the order if it has not filled within that many
+	// bars; 0 keeps it until it fills or is cancelled.
+	Expire int
+}
+
+// restingOrder is a submitted Order waiting for its price.
+type restingOrder struct {
+	Order
+	id        int
+	placed    int     // bar it was submitted on
+	mark      float64 // best price since submission, for trailing stops
+	triggered bool    // a stop_limit whose stop has been reached
+}
+
+// Submit validates o and rests it until a later bar reaches its price
+// (see Order), returning an id for Cancel. Placing it from day's Step
+// makes day+1 the first bar it can fill on.
+func (p *Portfolio) Submit(o Order, hist map[string][]data.AssetData, day int) (int, error) {
+	if o.Type == "" {
+		o.Type = OrderMarket
+	}
+	if err := validateOrder(o.Side, o.Ticker, o.Amount, 1); err != nil {
+		return 0, err
+	}
+	reject := func(price float64, err error) (int, error) {
+		return 0, &OrderError{Side: o.Side, Ticker: o.Ticker, Amount: o.Amount, Price: price, Err: err}
+	}
+	positive := func(v float64) bool { return v > 0 && !math.IsInf(v, 0) }
+	switch o.Type {
+	case OrderMarket:
+	case OrderLimit:
+		if !positive(o.Limit) {
+			return reject(o.Limit, ErrInvalidPrice)
+		}
+	case OrderStop:
+		if !positive(o.Stop) {
+			return reject(o.Stop, ErrInvalidPrice)
+		}
+	case OrderStopLimit:
+		if !positive(o.Stop) || !positive(o.Limit) {
+			return reject(o.Limit, ErrInvalidPrice)
+		}
+	case OrderTrailingStop:
+		if !(o.Trail > 0 && o.Trail < 1) {
+			return reject(o.Trail, ErrInvalidTrail)
+		}
+	default:
+		return reject(0, ErrInvalidOrderType)
+	}
+	if err := p.checkLookahead(o.Ticker, day); err != nil {
+		return 0, err
+	}
+	r := &restingOrder{Order: o, placed: day}
+	if o.Type == OrderTrailingStop {
+		series := hist[o.Ticker]
+		if day < 0 || day >= len(series) {
+			return reject(0, ErrInvalidPrice)
+		}
+		r.mark = series[day].Close
+	}
+	p.nextOrderID++
+	r.id = p.nextOrderID
+	p.resting = append(p.resting, r)
+	return r.id, nil
+}
+
+// Cancel withdraws a submitted order, reporting whether it was still
+// resting.
+func (p *Portfolio) Cancel(id int) bool {
+	for i, r := range p.resting {
+		if r.id == id {
+			p.resting = append(p.resting[:i], p.resting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// OpenOrders returns the submitted orders that have not yet filled,
+// expired or been cancelled, in submission order.
+func (p *Portfolio) OpenOrders() []Order {
+	out := make([]Order, len(p.resting))
+	for i, r := range p.resting {
+		out[i] = r.Order
+	}
+	return out
+}
+
+// FillResting evaluates submitted orders against bar day. The runner
+// calls it, after FillPending, before stepping the strategy on day. A
+// buy the portfolio can no longer afford is cut to the whole shares it
+// can, and a sell to the shares still held unless the portfolio may
+// sell short.
+func (p *Portfolio) FillResting(hist map[string][]data.AssetData, day int) {
+	if len(p.resting) == 0 {
+		return
+	}
+	orders := p.resting
+	p.resting = nil
+	var kept []*restingOrder
+	for _, r := range orders {
+		series := hist[r.Ticker]
+		if day <= r.placed || day >= len(series) {
+			kept = append(kept, r)
+			continue
+		}
+		bar := series[day]
+		price, ok := r.fillAt(bar)
+		if !ok {
+			if r.Expire > 0 && day-r.placed >= r.Expire {
+				p.txLog().Debug("EXPIRED", "portfolio", p.Pname, "type", r.Type, "side", r.Side, "ticker", r.Ticker, "amount", r.Amount)
+				continue
+			}
+			kept = append(kept, r)
+			continue
+		}
+		if err := p.fillResting(r, price, bar.Date); err != nil {
+			logger.Warn("order failed", "portfolio", p.Pname, "type", r.Type, "day", day, "err", err)
+		}
+	}
+	// Orders submitted by fill handlers rest behind the older ones.
+	p.resting = append(kept, p.resting...)
+}
+
+func (p *Portfolio) fillResting(r *restingOrder, price float64, date time.Time) error {
+	amount := r.Amount
+	if r.Side == "SELL" && !p.AllowShort {
+		pos, _ := p.FindPosition(r.Ticker)
+		if pos == nil {
+			return &OrderError{"SELL", r.Ticker, amount, price, ErrInsufficientShares}
+		}
+		amount = math.Min(amount, pos.Amount)
+	}
+	if r.Side == "SELL" {
+		return p.Sell(r.Ticker, amount, price, date)
+	}
+	if fill, fee := p.execute("BUY", r.Ticker, amount, price); !p.canAfford(amount*fill + fee) {
+		amount = p.maxBuy(r.Ticker, price, "greedy")
+	}
+	return p.Buy(r.Ticker, amount, price, date)
+}
+
+// fillAt reports the price r fills at on bar, if it does. Trailing
+// stops ratchet their mark after a bar that leaves them resting, and a
+// stop_limit whose stop is reached past its limit rests as a limit from
+// the next bar on.
+func (r *restingOrder) fillAt(bar data.AssetData) (float64, bool) {
+	buy := r.Side == "BUY"
+	switch r.Type {
+	case OrderMarket:
+		return bar.Open, true
+	case OrderLimit:
+		return limitFill(buy, r.Limit, bar)
+	case OrderStop:
+		return stopFill(buy, r.Stop, bar)
+	case OrderStopLimit:
+		if r.triggered {
+			return limitFill(buy, r.Limit, bar)
+		}
+		price, ok := stopFill(buy, r.Stop, bar)
+		if !ok {
+			return 0, false
+		}
+		r.triggered = true
+		if (buy && price <= r.Limit) || (!buy && price >= r.Limit) {
+			return price, true
+		}
+	case OrderTrailingStop:
+		stop := r.mark * (1 - r.Trail)
+		if buy {
+			stop = r.mark * (1 + r.Trail)
+		}
+		if price, ok := stopFill(buy, stop, bar); ok {
+			return price, true
+		}
+		if buy {
+			r.mark = math.Min(r.mark, bar.Low)
+		} else {
+			r.mark = math.Max(r.mark, bar.High)
+		}
+	}
+	return 0, false
+}
+
+// limitFill fills a limit order at its price or better once bar trades
+// through it.
+func limitFill(buy bool, limit float64, bar data.AssetData) (float64, bool) {
+	if buy && bar.Low <= limit {
+		return math.Min(bar.Open, limit), true
+	}
+	if !buy && bar.High >= limit {
+		return math.Max(bar.Open, limit), true
+	}
+	return 0, false
+}
+
+// stopFill fills a stop order at its price, or at the open if bar gapped
+// through it.
+func stopFill(buy bool, stop float64, bar data.AssetData) (float64, bool) {
+	if buy && bar.High >= stop {
+		return math.Max(bar.Open, stop), true
+	}
+	if !buy && bar.Low <= stop {
+		return math.Min(bar.Open, stop), true
+	}
+	return 0, false
+}
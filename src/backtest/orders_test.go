@@ -0,0 +1,227 @@
+package backtest
+
+import (
+	"errors"
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestBuySell_RejectPathologicalInputs(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	nan, inf := math.NaN(), math.Inf(1)
+	cases := []struct {
+		name   string
+		side   string
+		ticker string
+		amount float64
+		price  float64
+		want   error
+	}{
+		{"negative buy", "BUY", "AAA", -10, 100, ErrInvalidAmount},
+		{"zero buy", "BUY", "AAA", 0, 100, ErrInvalidAmount},
+		{"NaN amount", "BUY", "AAA", nan, 100, ErrInvalidAmount},
+		{"infinite amount", "BUY", "AAA", inf, 100, ErrInvalidAmount},
+		{"zero price", "BUY", "AAA", 1, 0, ErrInvalidPrice},
+		{"negative price", "BUY", "AAA", 1, -5, ErrInvalidPrice},
+		{"NaN price", "BUY", "AAA", 1, nan, ErrInvalidPrice},
+		{"infinite price", "BUY", "AAA", 1, inf, ErrInvalidPrice},
+		{"empty ticker", "BUY", "", 1, 100, ErrInvalidTicker},
+		{"unaffordable", "BUY", "AAA", 1000, 100, ErrInsufficientFunds},
+		{"negative sell", "SELL", "AAA", -5, 100, ErrInvalidAmount},
+		{"NaN sell price", "SELL", "AAA", 5, nan, ErrInvalidPrice},
+		{"oversell", "SELL", "AAA", 11, 100, ErrInsufficientShares},
+		{"sell unheld", "SELL", "BBB", 1, 100, ErrInsufficientShares},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newPropPortfolio(10_000, AccountingFloat)
+			if err := p.Buy("AAA", 10, 100, day); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			if c.side == "BUY" {
+				err = p.Buy(c.ticker, c.amount, c.price, day)
+			} else {
+				err = p.Sell(c.ticker, c.amount, c.price, day)
+			}
+			if !errors.Is(err, c.want) {
+				t.Fatalf("err = %v, want %v", err, c.want)
+			}
+			var oe *OrderError
+			if !errors.As(err, &oe) || oe.Side != c.side {
+				t.Errorf("err = %#v, want an *OrderError for %s", err, c.side)
+			}
+			if p.BuyingPower != 9_000 || len(p.Trades) != 1 || p.Positions["AAA"].Amount != 10 {
+				t.Errorf("rejected order changed the portfolio: cash %.2f, %d trades, %+v",
+					p.BuyingPower, len(p.Trades), p.Positions["AAA"])
+			}
+		})
+	}
+}
+
+// A sell through a long position closes it and shorts the rest, and a
+// buy through the short covers it and goes long again.
+func TestSell_Short(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	p := newPropPortfolio(10_000, AccountingFloat)
+	if err := p.Buy("AAA", 10, 100, day); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Sell("AAA", 15, 110, day); !errors.Is(err, ErrInsufficientShares) {
+		t.Fatalf("short without AllowShort: err = %v", err)
+	}
+	p.AllowShort = true
+	if err := p.Sell("AAA", 15, 110, day); err != nil {
+		t.Fatal(err)
+	}
+	pos := p.Positions["AAA"]
+	if pos.Amount != -5 || pos.AveragePrice != 110 || p.BuyingPower != 10_650 {
+		t.Fatalf("after selling through: %+v, cash %.2f", pos, p.BuyingPower)
+	}
+	hist := map[string][]data.AssetData{"AAA": {{Date: day, Close: 120}}}
+	if v := p.GetPortfolioValue([]string{"AAA"}, hist, 0); v != 10_050 {
+		t.Errorf("value with the short at 120 = %.2f, want 10050", v)
+	}
+	if err := p.Buy("AAA", 8, 100, day); err != nil {
+		t.Fatal(err)
+	}
+	if pos := p.Positions["AAA"]; pos.Amount != 3 || pos.AveragePrice != 100 || p.BuyingPower != 9_850 {
+		t.Fatalf("after covering through: %+v, cash %.2f", pos, p.BuyingPower)
+	}
+	want := []ClosedTrade{
+		{Ticker: "AAA", Entry: day, Exit: day, Amount: 10, EntryPrice: 100, ExitPrice: 110, PnL: 100, Return: 0.1},
+		{Ticker: "AAA", Entry: day, Exit: day, Amount: 5, EntryPrice: 110, ExitPrice: 100, PnL: 50, Short: true, Return: 50.0 / 550},
+	}
+	if len(p.ClosedTrades) != len(want) || p.ClosedTrades[0] != want[0] || p.ClosedTrades[1] != want[1] {
+		t.Errorf("closed trades = %+v, want %+v", p.ClosedTrades, want)
+	}
+}
+
+func TestGreedyBuy_NeverNegative(t *testing.T) {
+	for _, c := range []struct{ cash, price float64 }{
+		{1000, 0}, {1000, -1}, {1000, math.NaN()}, {-1000, 10},
+		{math.Inf(1), 10}, {1000, 1e-300},
+	} {
+		if n := greedyBuy(c.cash, c.price); n != 0 {
+			t.Errorf("greedyBuy(%g, %g) = %d, want 0", c.cash, c.price, n)
+		}
+	}
+	if n := greedyBuy(1000, 300); n != 3 {
+		t.Errorf("greedyBuy(1000, 300) = %d, want 3", n)
+	}
+}
+
+func TestOrder_RejectsInvalidSideAndQueuedAmount(t *testing.T) {
+	p := newPropPortfolio(10_000, AccountingFloat)
+	hist := fillTestHist()
+	if err := p.Order("A", "HOLD", 1, hist, 0); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("side HOLD: err = %v, want ErrInvalidSide", err)
+	}
+	p.Fill = FillNextOpen
+	if err := p.Order("A", "BUY", -3, hist, 0); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("queued negative buy: err = %v, want ErrInvalidAmount", err)
+	}
+	if len(p.pending) != 0 {
+		t.Errorf("invalid order was queued: %+v", p.pending)
+	}
+}
+
+// restingHist is one ticker's OHLC path: flat, a rally to 14, a drop
+// that gaps open below 10, then a recovery.
+func restingHist() map[string][]data.AssetData {
+	d := func(i int) time.Time { return time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	bars := []struct{ o, h, l, c float64 }{
+		{10, 10, 10, 10},
+		{10, 12, 10, 12},
+		{12, 14, 11, 13},
+		{9, 10, 8, 9},
+		{9, 11, 9, 11},
+	}
+	var series []data.AssetData
+	for i, b := range bars {
+		series = append(series, data.AssetData{Date: d(i), Open: b.o, High: b.h, Low: b.l, Close: b.c})
+	}
+	return map[string][]data.AssetData{"A": series}
+}
+
+func TestSubmit_OrderTypes(t *testing.T) {
+	hist := restingHist()
+	for _, c := range []struct {
+		name  string
+		held  float64 // shares held before the order
+		order Order
+		day   int     // bar it fills on; -1 for never
+		price float64 // fill price
+	}{
+		{"market", 0, Order{Side: "BUY"}, 1, 10},
+		{"buy limit", 0, Order{Side: "BUY", Type: OrderLimit, Limit: 11.5}, 1, 10},
+		{"buy limit gaps", 0, Order{Side: "BUY", Type: OrderLimit, Limit: 9.5}, 3, 9},
+		{"sell limit", 10, Order{Side: "SELL", Type: OrderLimit, Limit: 12}, 1, 12},
+		{"buy stop", 0, Order{Side: "BUY", Type: OrderStop, Stop: 13}, 2, 13},
+		{"sell stop gaps", 10, Order{Side: "SELL", Type: OrderStop, Stop: 9.5}, 3, 9},
+		{"stop limit", 0, Order{Side: "BUY", Type: OrderStopLimit, Stop: 11, Limit: 11.5}, 1, 11},
+		// Triggered by day 3's gap below its limit; rests as a limit.
+		{"stop limit past limit", 10, Order{Side: "SELL", Type: OrderStopLimit, Stop: 9.5, Limit: 9.5}, 4, 9.5},
+		// Ratchets to day 2's high of 14; 10% below is 12.6, gapped on day 3.
+		{"trailing stop", 10, Order{Side: "SELL", Type: OrderTrailingStop, Trail: 0.1}, 3, 9},
+		{"expired", 0, Order{Side: "BUY", Type: OrderLimit, Limit: 9.5, Expire: 2}, -1, 0},
+	} {
+		p := newPropPortfolio(1000, AccountingFloat)
+		if c.held > 0 {
+			if err := p.Buy("A", c.held, 10, hist["A"][0].Date); err != nil {
+				t.Fatal(err)
+			}
+		}
+		trades := len(p.Trades)
+		o := c.order
+		o.Ticker, o.Amount = "A", 10
+		if _, err := p.Submit(o, hist, 0); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for day := 0; day < len(hist["A"]); day++ {
+			p.FillResting(hist, day)
+		}
+		if c.day < 0 {
+			if len(p.Trades) != trades || len(p.OpenOrders()) != 0 {
+				t.Errorf("%s: trades = %+v, open = %+v", c.name, p.Trades[trades:], p.OpenOrders())
+			}
+			continue
+		}
+		if len(p.Trades) != trades+1 {
+			t.Fatalf("%s: trades = %+v", c.name, p.Trades[trades:])
+		}
+		got := p.Trades[trades]
+		if !got.Date.Equal(hist["A"][c.day].Date) || got.Price != c.price || got.Amount != 10 {
+			t.Errorf("%s: fill = %+v, want day %d @ %v", c.name, got, c.day, c.price)
+		}
+	}
+}
+
+func TestSubmit_RejectAndCancel(t *testing.T) {
+	hist := restingHist()
+	p := newPropPortfolio(1000, AccountingFloat)
+	for _, c := range []struct {
+		order Order
+		want  error
+	}{
+		{Order{Ticker: "A", Side: "BUY", Amount: 1, Type: "iceberg"}, ErrInvalidOrderType},
+		{Order{Ticker: "A", Side: "BUY", Amount: 1, Type: OrderLimit}, ErrInvalidPrice},
+		{Order{Ticker: "A", Side: "BUY", Amount: 1, Type: OrderStopLimit, Stop: 10}, ErrInvalidPrice},
+		{Order{Ticker: "A", Side: "SELL", Amount: 1, Type: OrderTrailingStop, Trail: 1}, ErrInvalidTrail},
+		{Order{Ticker: "A", Side: "HOLD", Amount: 1}, ErrInvalidSide},
+	} {
+		if _, err := p.Submit(c.order, hist, 0); !errors.Is(err, c.want) {
+			t.Errorf("%+v: err = %v, want %v", c.order, err, c.want)
+		}
+	}
+
+	id, err := p.Submit(Order{Ticker: "A", Side: "BUY", Amount: 1, Type: OrderLimit, Limit: 5}, hist, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Cancel(id) || p.Cancel(id) || len(p.OpenOrders()) != 0 {
+		t.Errorf("cancel: open orders = %+v", p.OpenOrders())
+	}
+}
@@ -0,0 +1,320 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is how often paper trading polls for quotes when
+// PaperConfig.PollInterval is unset.
+const DefaultPollInterval = time.Minute
+
+// FeedTrader runs a backtest-configured strategy forward over a Feed.
+// Each event is appended to the history the strategy sees and published
+// to the portfolio's Engine as a BarEvent, exactly as runOne does, so
+// strategies need no changes to be replayed or forward-tested. Quote
+// events build one bar per session instead (see OnEvent), so a bar is
+// still a day however often the feed polls. The Portfolio is simulated;
+// orders only leave the process if its Executor is set.
+type FeedTrader struct {
+	p    *Portfolio
+	feed Feed
+	rf   map[int64]float64
+
+	hist   map[string][]data.AssetData
+	engine *Engine
+	// open is set while the last bar of hist is a session still being
+	// quoted, which hasn't been stepped yet.
+	open bool
+}
+
+// NewFeedTrader subscribes feed to p's tickers. warmup is preloaded
+// history (e.g. from DuckDB) that indicators can look back over; it is
+// aligned to the dates every ticker has a bar on (see alignWindow) so
+// day indices stay aligned across tickers, and the strategy is not
+// stepped over it.
+func NewFeedTrader(
+	p *Portfolio,
+	feed Feed,
+	warmup map[string][]data.AssetData,
+	riskFreeRates map[int64]float64,
+) (*FeedTrader, error) {
+	if len(p.Tickers) == 0 {
+		return nil, fmt.Errorf("portfolio %q has no tickers", p.Pname)
+	}
+	if err := feed.Subscribe(p.Tickers); err != nil {
+		return nil, fmt.Errorf("portfolio %q: %w", p.Pname, err)
+	}
+	// Copied, as alignWindow may return warmup's own series and every
+	// trader appends to its history.
+	aligned := alignWindow(p.Tickers, warmup, time.Time{}, time.Time{}, p.Calendar.sessionFilter())
+	hist := make(map[string][]data.AssetData, len(p.Tickers))
+	for _, t := range p.Tickers {
+		hist[t] = append([]data.AssetData(nil), aligned[t]...)
+	}
+	if riskFreeRates == nil {
+		riskFreeRates = map[int64]float64{}
+	}
+	return &FeedTrader{
+		p:    p,
+		feed: feed,
+		rf:   riskFreeRates,
+		hist: hist,
+		engine: newEngine(p, func(hist map[string][]data.AssetData, day int) {
+			p.Strategy.Step(p, hist, day)
+		}),
+	}, nil
+}
+
+// Run consumes the feed until it is exhausted or ctx is done, then
+// computes metrics over the bars it delivered and returns the Result.
+func (ft *FeedTrader) Run(ctx context.Context) Result {
+	for {
+		ev, ok := ft.feed.Next(ctx)
+		if !ok {
+			return ft.finish()
+		}
+		ft.OnEvent(ctx, ev)
+	}
+}
+
+// OnEvent appends the event's bars and steps the strategy on them.
+// Events missing a subscribed ticker are dropped so day indices stay
+// aligned. A Quote event in the same session (UTC date) as the open bar
+// is folded into it instead; the open bar is stepped, as finished, when
+// the first quote of a later session arrives.
+func (ft *FeedTrader) OnEvent(ctx context.Context, ev FeedEvent) {
+	tickers := ft.p.Tickers
+	for _, t := range tickers {
+		if _, ok := ev.Bars[t]; !ok {
+			return
+		}
+	}
+	if ft.open && ev.Quote && sameSession(ft.hist[tickers[0]][len(ft.hist[tickers[0]])-1].Date, ev.Date) {
+		for _, t := range tickers {
+			series := ft.hist[t]
+			n := len(series)
+			ft.hist[t] = data.AppendBar(series[:n-1], foldQuote(series[n-1], ev.Bars[t]))
+		}
+		return
+	}
+	if ft.open {
+		ft.step(ctx)
+	}
+	for _, t := range tickers {
+		ft.hist[t] = data.AppendBar(ft.hist[t], ev.Bars[t])
+	}
+	if ev.Quote {
+		ft.open = true
+		return
+	}
+	ft.step(ctx)
+}
+
+// step publishes the last bar of the history to the engine.
+func (ft *FeedTrader) step(ctx context.Context) {
+	lead := ft.hist[ft.p.Tickers[0]]
+	day := len(lead) - 1
+	date := lead[day].Date
+	if ft.p.EffectiveStart.IsZero() {
+		ft.p.EffectiveStart = date
+	}
+	ft.p.EffectiveEnd = date
+	ft.p.bar, ft.p.barDate = day, date
+	ft.open = false
+	ft.engine.Bar(ctx, ft.hist, day, date)
+}
+
+// sameSession reports whether a and b fall on the same UTC date, which
+// holds a whole US equities or crypto session.
+func sameSession(a, b time.Time) bool {
+	ay, am, ad := a.UTC().Date()
+	by, bm, bd := b.UTC().Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// foldQuote is bar, a session's bar so far, updated by a later quote of
+// the session: its high and low widen to the quote's, and its close,
+// volume (a session total) and date are the quote's.
+func foldQuote(bar, q data.AssetData) data.AssetData {
+	if bar.Open == 0 {
+		bar.Open = q.Open
+	}
+	bar.High = math.Max(bar.High, q.High)
+	if q.Low > 0 && (bar.Low == 0 || q.Low < bar.Low) {
+		bar.Low = q.Low
+	}
+	bar.Close, bar.Volume, bar.Date = q.Close, q.Volume, q.Date
+	return bar
+}
+
+// finish computes the Result. A session still open when the feed ends
+// hasn't finished, so it is dropped rather than stepped.
+func (ft *FeedTrader) finish() Result {
+	if ft.open {
+		for _, t := range ft.p.Tickers {
+			ft.hist[t] = ft.hist[t][:len(ft.hist[t])-1]
+		}
+		ft.open = false
+	}
+	ft.p.GetBacktestingData(ft.rf, ft.hist, len(ft.hist[ft.p.Tickers[0]]))
+	if c, ok := ft.p.Strategy.(interface{ Close() }); ok {
+		c.Close()
+	}
+	return newResult(ft.p)
+}
+
+// RunPaper paper-trades every portfolio concurrently against live quotes
+// until ctx is done, then writes the Results through the configured
+// Reporter exactly as Run does. With source "replay" the portfolios'
+// StartDate..EndDate history is replayed instead, one bar per ReplayDelay,
+// ending early if ctx is done. Each trade is mirrored to the configured
+// broker and posted to webhook, when set; one the broker fails is refused,
+// and counted in the Result's ExecutionFailures. Warm-up history and
+// risk-free rates are read from store.
+func RunPaper(
+	ctx context.Context,
+	store Store,
+	portfolios []*Portfolio,
+	cfg *PaperConfig,
+	output *OutputConfig,
+	webhook *WebhookConfig,
+) ([]Result, error) {
+	if cfg == nil {
+		cfg = &PaperConfig{}
+	}
+	reporter, err := NewReporter(output)
+	if err != nil {
+		return nil, fmt.Errorf("output config: %w", err)
+	}
+	replay := cfg.Source == "replay"
+	var quotes data.QuoteSource
+	if !replay {
+		if quotes, err = data.NewQuoteSource(cfg.Source); err != nil {
+			return nil, fmt.Errorf("paper config: %w", err)
+		}
+	}
+	var replayDelay time.Duration
+	if cfg.ReplayDelay != "" {
+		if replayDelay, err = time.ParseDuration(cfg.ReplayDelay); err != nil {
+			return nil, fmt.Errorf("paper replay_delay %q: %w", cfg.ReplayDelay, err)
+		}
+	}
+	interval := DefaultPollInterval
+	if cfg.PollInterval != "" {
+		interval, err = time.ParseDuration(cfg.PollInterval)
+		if err != nil {
+			return nil, fmt.Errorf("paper poll_interval %q: %w", cfg.PollInterval, err)
+		}
+	}
+
+	executor, err := NewExecutor(cfg.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("paper broker: %w", err)
+	}
+
+	wall := RealClock{}
+	now := wall.Now()
+	warmStart := now.AddDate(0, 0, -cfg.WarmupDays)
+	tickers := allTickers(portfolios)
+	warmup := map[string][]data.AssetData{}
+	var replayHist map[string][]data.AssetData
+	rfStart, rfEnd := riskFreeStart(warmStart), now
+	if replay {
+		start, end := dateRange(portfolios)
+		replayHist = store.QueryAssetsForTickers(ctx, tickers, start, end)
+		rfStart, rfEnd = riskFreeStart(start), end
+	} else if cfg.WarmupDays > 0 {
+		warmup = store.QueryAssetsForTickers(ctx, tickers, warmStart, now)
+	}
+	var riskFreeRates map[int64]float64
+	if usesStoreRiskFree(portfolios) {
+		riskFreeRates = DBRiskFree{store}.RiskFreeRates(ctx, rfStart, rfEnd)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	traders := make([]*FeedTrader, 0, len(portfolios))
+	for _, p := range portfolios {
+		if len(p.Sleeves) > 0 {
+			return nil, fmt.Errorf("paper %s: a portfolio with sleeves can only be backtested", p.Pname)
+		}
+		clone, err := p.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("clone portfolio %s: %w", p.Pname, err)
+		}
+		clone.store = store
+		clone.loadMetadata(ctx)
+		var execs Executors
+		if executor != nil {
+			execs = append(execs, executor)
+		}
+		if webhook != nil && webhook.URL != "" {
+			w, err := NewWebhook(webhook, "paper", clone.Pname, clone.Strategy.Name())
+			if err != nil {
+				return nil, err
+			}
+			execs = append(execs, notifier{w})
+		}
+		if len(execs) > 0 {
+			clone.Executor = execs
+		}
+		var feed Feed
+		if replay {
+			feed = NewReplayFeed(
+				alignWindow(clone.Tickers, replayHist, clone.StartTime, clone.EndTime, clone.Calendar.sessionFilter()),
+				replayDelay,
+			)
+		} else {
+			feed = NewLiveFeed(quotes, interval)
+			if clone.Clock == nil {
+				clone.Clock = wall
+			}
+		}
+		rf := riskFreeRates
+		if clone.RiskFree != nil {
+			rf = clone.RiskFree.RiskFreeRates(ctx, rfStart, rfEnd)
+		}
+		ft, err := NewFeedTrader(clone, feed, warmup, rf)
+		if err != nil {
+			return nil, err
+		}
+		traders = append(traders, ft)
+	}
+
+	results := make([]Result, len(traders))
+	var wg sync.WaitGroup
+	for i, ft := range traders {
+		wg.Add(1)
+		go func(i int, ft *FeedTrader) {
+			defer wg.Done()
+			results[i] = ft.Run(ctx)
+		}(i, ft)
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		if werr := reporter.Write(res); werr != nil {
+			runLogger.Error("write result", "err", werr)
+		}
+	}
+	if err := reporter.Close(); err != nil {
+		return results, fmt.Errorf("close output: %w", err)
+	}
+	if output != nil && output.Dir != "" {
+		if err := WriteArtifacts(output.Dir, results); err != nil {
+			return results, err
+		}
+	}
+	if output != nil && output.Database {
+		if err := SaveResults(context.WithoutCancel(ctx), store, results); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
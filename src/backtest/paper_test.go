@@ -0,0 +1,112 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// scriptedQuotes serves a fixed sequence of closes per ticker, one per
+// Quote call, at hours after midnight on 1 January 2024. Repeating a
+// time simulates a stale (market-closed) quote.
+type scriptedQuotes struct {
+	closes map[string][]float64
+	hours  []int
+	calls  map[string]int
+}
+
+func (q *scriptedQuotes) Quote(ctx context.Context, ticker string) (data.AssetData, error) {
+	i := q.calls[ticker]
+	q.calls[ticker]++
+	c := q.closes[ticker][i]
+	return data.AssetData{
+		Date: time.Date(2024, 1, 1, q.hours[i], 0, 0, 0, time.UTC),
+		Open: c, High: c, Low: c, Close: c,
+	}, nil
+}
+
+// Quotes within a session fold into that session's bar, which is
+// stepped once, when the next session's first quote arrives; a session
+// still open at the end isn't reported.
+func TestFeedTrader_LiveStepsOnFreshQuotesOnly(t *testing.T) {
+	p, err := InitializePortfolio(
+		10_000, time.Time{}, time.Time{}, "paper", []string{"AAA"},
+		"greedy", nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotes := &scriptedQuotes{
+		closes: map[string][]float64{"AAA": {100, 100, 95, 105, 110, 121, 125}},
+		hours:  []int{14, 14, 15, 16, 24 + 14, 48 + 14, 48 + 15},
+		calls:  map[string]int{},
+	}
+	feed := NewLiveFeed(quotes, time.Hour)
+	ft, err := NewFeedTrader(p, feed, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if ev, ok := feed.Poll(context.Background()); ok {
+			ft.OnEvent(context.Background(), ev)
+		}
+	}
+
+	bars := ft.hist["AAA"]
+	if len(bars) != 3 {
+		t.Fatalf("history holds %d bars, want one a session", len(bars))
+	}
+	if b := bars[0]; b.Open != 100 || b.High != 105 || b.Low != 95 || b.Close != 105 {
+		t.Errorf("first session = %+v, want its quotes folded into 100/105/95/105", b)
+	}
+	if b := bars[2]; b.Close != 125 || b.Low != 121 {
+		t.Errorf("open session = %+v, want 121 then 125", b)
+	}
+	if got := len(p.Trades); got != 1 || p.Trades[0].Side != "BUY" || p.Trades[0].Price != 105 {
+		t.Fatalf("trades = %+v, want a single BUY at the first session's close", p.Trades)
+	}
+	if got := len(p.DailyReturns); got != 1 {
+		t.Fatalf("recorded %d daily returns, want 1 (the open session isn't stepped)", got)
+	}
+	if r := p.DailyReturns[0].Return; r < 0.047 || r > 0.048 {
+		t.Errorf("first live return = %.4f, want ~0.0476", r)
+	}
+	if r := bars[1].Return; r < 0.047 || r > 0.048 {
+		t.Errorf("appended bar return = %.4f, want ~0.0476", r)
+	}
+
+	ft.finish()
+	if got := len(ft.hist["AAA"]); got != 2 || len(p.DailyReturns) != 1 {
+		t.Errorf("finished with %d bars and %d returns, want the two closed sessions", got, len(p.DailyReturns))
+	}
+}
+
+// Warm-up history is kept on the dates every ticker has, so day i is the
+// same date for each.
+func TestNewFeedTrader_AlignsWarmupByDate(t *testing.T) {
+	p, err := NewPortfolio("paper", 10_000, []string{"A", "B"}, "greedy", WithCalendar(CalendarCrypto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(i int) data.AssetData {
+		return data.AssetData{Date: time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC), Close: float64(10 + i)}
+	}
+	warmup := map[string][]data.AssetData{
+		"A": {day(1), day(2), day(3), day(4), day(5)},
+		"B": {day(0), day(1), day(2), day(3), day(5)},
+	}
+	ft, err := NewFeedTrader(p, NewReplayFeed(warmup, 0), warmup, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tk := range []string{"A", "B"} {
+		bars := ft.hist[tk]
+		if len(bars) != 4 || !bars[3].Date.Equal(day(5).Date) || !bars[0].Date.Equal(day(1).Date) {
+			t.Errorf("%s warm-up = %v, want days 1, 2, 3 and 5", tk, bars)
+		}
+	}
+	if warmup["A"][0].Close != 11 || len(warmup["A"]) != 5 {
+		t.Error("warm-up input was modified")
+	}
+}
@@ -0,0 +1,97 @@
+package backtest
+
+import (
+	"math"
+	"math/bits"
+)
+
+// DefaultPBOBlocks is how many blocks pbo cuts the configurations'
+// returns into when an OptimizeConfig sets no PBOBlocks.
+const DefaultPBOBlocks = 16
+
+// maxPBOBlocks bounds PBOBlocks: the blocks' halves number
+// C(blocks, blocks/2), 184756 at 20.
+const maxPBOBlocks = 20
+
+// pbo estimates the probability of backtest overfitting of picking the
+// best of several configurations by combinatorially symmetric
+// cross-validation (Bailey, Borwein, López de Prado and Zhu, 2015).
+// returns holds each configuration's returns over the same bars, which
+// are cut into blocks contiguous blocks. For every way of choosing half
+// the blocks as in-sample, the configuration with the best Sharpe ratio
+// over them is ranked by its Sharpe ratio over the other half, and the
+// estimate is the share of splits in which it ranks no better than the
+// median. It reports false with fewer than two configurations or two
+// bars per block.
+func pbo(returns [][]float64, blocks int) (float64, bool) {
+	n := len(returns)
+	if n < 2 || len(returns[0]) < 2*blocks {
+		return 0, false
+	}
+	t := len(returns[0])
+	// sums[c][b] and squares[c][b] total configuration c's returns, and
+	// their squares, over block b of size[b] bars.
+	sums := make([][]float64, n)
+	squares := make([][]float64, n)
+	size := make([]int, blocks)
+	for b := range size {
+		size[b] = (b+1)*t/blocks - b*t/blocks
+	}
+	for c, rs := range returns {
+		sums[c], squares[c] = make([]float64, blocks), make([]float64, blocks)
+		for b := 0; b < blocks; b++ {
+			for _, r := range rs[b*t/blocks : (b+1)*t/blocks] {
+				sums[c][b] += r
+				squares[c][b] += r * r
+			}
+		}
+	}
+	sharpe := func(c int, mask uint32) float64 {
+		sum, sq, k := 0.0, 0.0, 0
+		for b := 0; b < blocks; b++ {
+			if mask&(1<<b) != 0 {
+				sum, sq, k = sum+sums[c][b], sq+squares[c][b], k+size[b]
+			}
+		}
+		mean := sum / float64(k)
+		variance := sq/float64(k) - mean*mean
+		if variance <= 0 {
+			return 0
+		}
+		return mean / math.Sqrt(variance)
+	}
+
+	all := uint32(1)<<blocks - 1
+	splits, overfit := 0, 0
+	for mask := uint32(1); mask < all; mask++ {
+		if bits.OnesCount32(mask) != blocks/2 {
+			continue
+		}
+		best, bestSharpe := 0, math.Inf(-1)
+		for c := 0; c < n; c++ {
+			if s := sharpe(c, mask); s > bestSharpe {
+				best, bestSharpe = c, s
+			}
+		}
+		// The pick's rank out of sample, 1 the worst, ties sharing the
+		// mean of their ranks; at or below the median is overfit.
+		out := sharpe(best, all&^mask)
+		rank := 1.0
+		for c := 0; c < n; c++ {
+			if c == best {
+				continue
+			}
+			switch s := sharpe(c, all&^mask); {
+			case s < out:
+				rank++
+			case s == out:
+				rank += 0.5
+			}
+		}
+		splits++
+		if rank/float64(n+1) <= 0.5 {
+			overfit++
+		}
+	}
+	return float64(overfit) / float64(splits), true
+}
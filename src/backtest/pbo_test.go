@@ -0,0 +1,59 @@
+package backtest
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPBO(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := func(n, bars int) [][]float64 {
+		out := make([][]float64, n)
+		for c := range out {
+			out[c] = make([]float64, bars)
+			for i := range out[c] {
+				out[c][i] = rng.NormFloat64() * 0.01
+			}
+		}
+		return out
+	}
+
+	// The best of pure noise is as likely as not to lose to the median
+	// out of sample.
+	if v, ok := pbo(noise(20, 400), 10); !ok || v < 0.25 || v > 0.75 {
+		t.Errorf("noise: pbo = %v, %v; want about 0.5", v, ok)
+	}
+
+	// A real edge is picked in sample and keeps it out of sample.
+	edge := noise(20, 400)
+	for i := range edge[0] {
+		edge[0][i] += 0.005
+	}
+	if v, ok := pbo(edge, 10); !ok || v > 0.05 {
+		t.Errorf("edge: pbo = %v, %v; want about 0", v, ok)
+	}
+
+	// Each configuration did well in one block and badly elsewhere: the
+	// best in sample is one whose block was in sample, and out of sample
+	// it loses to those whose block wasn't.
+	fitted := noise(10, 400)
+	for c := range fitted {
+		for i := range fitted[c] {
+			if i/40 == c {
+				fitted[c][i] += 0.01
+			} else {
+				fitted[c][i] -= 0.001
+			}
+		}
+	}
+	if v, ok := pbo(fitted, 10); !ok || v < 0.8 {
+		t.Errorf("fitted: pbo = %v, %v; want near 1", v, ok)
+	}
+
+	if _, ok := pbo(noise(1, 400), 10); ok {
+		t.Error("one configuration: want no estimate")
+	}
+	if _, ok := pbo(noise(5, 15), 10); ok {
+		t.Error("15 bars in 10 blocks: want no estimate")
+	}
+}
@@ -1,22 +1,68 @@
 package backtest
 
 import (
-	"io"
-	"log"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
 	"my-backtester/src/data"
+	"my-backtester/src/logging"
+	"sort"
 	"time"
 )
 
-// TransactionLogger receives BUY/SELL records. Defaults to a discard sink
-// so callers that don't enable transaction logging (e.g. the UI) don't
-// nil-panic; the CLI's --debug flag replaces it with a file-backed logger.
-var TransactionLogger = log.New(io.Discard, "", 0)
+// The package logs through these (see package logging for where their
+// records go): logger for a portfolio's simulation, such as orders that
+// couldn't be placed; runLogger for a run as a whole, such as data
+// loading and output files.
+var (
+	logger    = logging.For("backtest")
+	runLogger = logging.For("runner")
+)
+
+// TransactionLogger receives the ledger records (BUY, SELL, DIVIDEND,
+// ...) of portfolios without their own Logger, at debug level, so they
+// are only written with the level at debug.
+var TransactionLogger = logging.For("transactions")
 
 type DailyReturn struct {
 	Date   time.Time
 	Return float64
 }
 
+// Trade is one executed fill on the Portfolio's ledger. Backtests and
+// paper trading record the same entries, so their trade logs compare
+// line for line.
+type Trade struct {
+	Date   time.Time
+	Ticker string
+	Side   string // "BUY" or "SELL"
+	Amount float64
+	Price  float64 // after slippage
+	Fee    float64 // commission charged on the fill
+	Note   string  // why the run, not the strategy, traded, e.g. "delisted"
+}
+
+// ClosedTrade is a round trip: the part of a position one Sell closed,
+// or one Buy covered when Short. Entry and EntryPrice are the opening
+// fill and the position's average cost, or under FIFO or LIFO lot
+// accounting the closed lot's (see LotMethod). PnL is net of the closed
+// shares' share of the opening and closing fills' fees.
+type ClosedTrade struct {
+	Ticker     string
+	Entry      time.Time // when the position, or the lot, was opened
+	Exit       time.Time
+	Amount     float64
+	EntryPrice float64
+	ExitPrice  float64
+	PnL        float64
+	Short      bool
+	// HoldingDays is the calendar days from Entry to Exit, and Return
+	// PnL as a fraction of EntryPrice × Amount.
+	HoldingDays float64
+	Return      float64
+}
+
 type Portfolio struct {
 	Pname                string // Portfolio name for tracking purposes
 	BuyingPower          float64
@@ -25,12 +71,163 @@ type Portfolio struct {
 	DailyReturns         []DailyReturn
 	PortfolioCloseValues []float64
 	Metrics              Metrics
+	Trades               []Trade
+	ClosedTrades         []ClosedTrade
 	Tickers              []string
 	StrategySpec         string
 	StrategyParams       map[string]any
 	Strategy             Strategy
 	StartTime            time.Time
 	EndTime              time.Time
+	// EffectiveStart and EffectiveEnd are the first and last bars the
+	// simulation actually stepped over (see alignWindow).
+	EffectiveStart time.Time
+	EffectiveEnd   time.Time
+	// Executor, when set, receives every trade the simulated portfolio
+	// accepts so it can be mirrored to a broker. Nil in backtests. A
+	// trade it fails is refused with ErrExecution and counted in
+	// ExecutionFailures.
+	Executor          Executor
+	ExecutionFailures int
+	// Fill and FillWindow select how Order prices fills (see FillModel).
+	Fill       FillModel
+	FillWindow int
+	// Accounting selects float or exact-cents cash tracking; set it with
+	// SetAccounting so the cents balance is seeded.
+	Accounting Accounting
+	// LotMethod selects the shares each closing fill takes from a
+	// position, and so its ClosedTrades (see LotMethod).
+	LotMethod LotMethod
+	// AuditLookahead makes runOne hand the strategy only the bars up to
+	// the current one and stop at the first read past it, or order
+	// filling at the current bar's own prices, recorded in Lookahead.
+	AuditLookahead bool
+	Lookahead      *LookaheadError
+	// AllowShort lets Sell go past the shares held into a short position,
+	// held as a negative Amount; a Buy covers it. A short's proceeds are
+	// held as its collateral: opening or adding to one, and any buy while
+	// short, must keep gross exposure within Margin's Leverage (1 without
+	// Margin) times equity.
+	AllowShort bool
+	// Commission is a flat fee per fill and SlippageBps moves each fill
+	// price against the trade (see WithCommission, WithSlippage). Costs
+	// are further models applied after them (see CostModel, WithCosts).
+	Commission  float64
+	SlippageBps float64
+	Costs       Costs
+	// Calendar sets the annualization of metrics; Benchmark, when set,
+	// is a ticker whose buy-and-hold return is reported alongside.
+	// Timeframe is the bar length simulated, daily unless it resamples
+	// to weeks or months; Interval, when set, simulates intraday bars of
+	// that length instead of daily ones (see WithInterval).
+	Calendar  Calendar
+	Timeframe Timeframe
+	Interval  time.Duration
+	Benchmark string
+	// DataGaps is how dates some tickers have no bar on are simulated,
+	// and DelistingHaircut the fraction of a delisted position's last
+	// close it is liquidated without (see WithDataGaps).
+	DataGaps         DataGaps
+	DelistingHaircut float64
+	// VaRLevels are the confidence levels Metrics.VaR is reported at;
+	// DefaultVaRLevels when empty.
+	VaRLevels []float64
+	// RollingWindows are the windows, in bars, of Rolling;
+	// DefaultRollingWindows when empty. Rolling is filled with the other
+	// metrics.
+	RollingWindows []int
+	Rolling        []Rolling
+	// Contributions, when set, schedules outside cash flows, which are
+	// recorded in CashFlows (see WithContributions).
+	Contributions *ContributionSchedule
+	CashFlows     []CashFlow
+	// Risk, when set, has the engine enforce stop-losses, take-profits
+	// and a drawdown kill switch (see WithRisk); Halted is the date the
+	// kill switch tripped, zero if it hasn't.
+	Risk   *RiskConfig
+	Halted time.Time
+	// Margin, when set, lets buys borrow up to a leverage limit and
+	// charges interest on the loan (see WithMargin).
+	Margin *MarginConfig
+	// Tax, when set, charges capital-gains tax on realized gains (see
+	// WithTax).
+	Tax *TaxConfig
+	// Universe, when set, selects the portfolio's Tickers from the
+	// database at the start of each run (see WithUniverse).
+	Universe *UniverseConfig
+	// SettlementDays, when positive, holds sale proceeds back from buys
+	// for that many business days (see WithSettlement).
+	SettlementDays int
+	// Prices says whether dividends and splits are paid and applied as
+	// they happen or already in the prices (see PriceMode).
+	Prices PriceMode
+	// MonteCarloConfig, when set, has the finished run resampled into
+	// MonteCarlo (see WithMonteCarlo).
+	MonteCarloConfig *MonteCarloConfig
+	MonteCarlo       *MonteCarlo
+	// SignificanceConfig, when set, adds bootstrap confidence intervals
+	// to the run's Metrics (see WithSignificance).
+	SignificanceConfig *SignificanceConfig
+	// RandomEntry, when set, measures the run's Sharpe ratio against runs
+	// with random entries (see WithRandomEntry).
+	RandomEntry *RandomEntryConfig
+	// RiskFree and BenchmarkSource replace the Store as the source of
+	// risk-free rates and benchmark bars when set.
+	RiskFree        RiskFreeProvider
+	BenchmarkSource BenchmarkProvider
+	// Seed seeds Rand. Logger, when set, replaces TransactionLogger.
+	Seed   int64
+	Logger *slog.Logger
+	// Clock is the time source (see Clock); runs install a SimClock or
+	// RealClock when it is nil.
+	Clock Clock
+	// Router executes the orders placed during a run; nil is SimRouter.
+	Router OrderRouter
+	// Sleeves, when set, split the capital among several strategies in
+	// place of StrategySpec (see WithSleeves).
+	Sleeves []Sleeve
+
+	// trials is how many portfolios the run this one is in started with,
+	// the default Metrics.Trials.
+	trials int
+
+	pending     []pendingOrder
+	resting     []*restingOrder // submitted with Submit
+	nextOrderID int
+	cashCents   int64     // authoritative cash under AccountingCents
+	flow        float64   // outside cash added on the bar being processed
+	riskPeak    float64   // peak value the drawdown kill switch measures from
+	marginRates []float64 // risk-free rate of each bar, for margin interest
+	taxes       taxState
+	unsettled   []unsettledCash // sale proceeds not yet settled, by settlement date
+	refused     int             // buys refused for want of settled cash
+	exposures   exposureStats
+	actions     map[string][]data.CorporateAction // by ticker, set by Run
+	nextAction  map[string]int                    // each ticker's first unapplied action
+	bar         int                               // index of the bar being processed
+	barDate     time.Time                         // and its date, for LookaheadError
+	deciding    bool                              // an audited strategy is stepping
+	routing     bool                              // the OrderRouter is executing an order
+	opening     bool                              // FillPending is filling orders at the open
+	store       Store                             // set by Run and RunPaper; nil leaves macro() empty
+	benchBars   []data.AssetData                  // the Benchmark's bars over the simulated window, set by Run
+	sim         *timeframeViews                   // the simulated bars, for StrategyContext.Timeframe
+	raw         map[string][]data.AssetData       // the simulated bars unadjusted, for StrategyContext.Raw
+	adjusted    map[string]adjustedView           // StrategyContext.Adjusted's series, by ticker
+	members     []universeMembers                 // each selection of the Universe, set by Run
+	spells      []data.IndexMembership            // the Universe's index membership, set by Run
+	delisted    map[string]delisting              // by ticker, set by runOne under GapsForwardFill
+	note        string                            // Note for the trades being recorded
+	rng         *rand.Rand
+	hooks       []EngineHook
+	engine      *Engine                          // the engine stepping the portfolio, if any
+	sizers      map[string]Sizer                 // parsed sizer specs, by spec
+	sleeveParts []SleeveStats                    // each sleeve's part, set by runSleeves
+	sleeves     []*Portfolio                     // the sleeves to run, set by Clone
+	books       map[bookKey]float64              // the sleeves' or stitched runs' P&L
+	dividends   map[bookKey]float64              // paid or, short, owed, for Attribution
+	metadata    map[string]data.SecurityMetadata // by ticker, set by Run
+	sectors     map[string][]float64             // net value by sector, 1:1 with PortfolioCloseValues
 }
 
 func InitializePortfolio(
@@ -41,38 +238,22 @@ func InitializePortfolio(
 	strategySpec string,
 	strategyParams map[string]any,
 ) (*Portfolio, error) {
-	strat, err := NewStrategy(strategySpec, strategyParams)
-	if err != nil {
-		return nil, err
-	}
-	days := int(endTime.Sub(startTime).Hours() / 24)
-
-	return &Portfolio{
-		Pname:                pname,
-		BuyingPower:          buyingPower,
-		InitialBuyingPower:   buyingPower,
-		Positions:            make(map[string]*Position),
-		DailyReturns:         make([]DailyReturn, 0, days),
-		PortfolioCloseValues: make([]float64, 0, days),
-		StartTime:            startTime,
-		EndTime:              endTime,
-		Tickers:              tickers,
-		StrategySpec:         strategySpec,
-		StrategyParams:       strategyParams,
-		Strategy:             strat,
-	}, nil
+	return NewPortfolio(
+		pname, buyingPower, tickers, strategySpec,
+		WithWindow(startTime, endTime), WithParams(strategyParams),
+	)
 }
 
 // Clone returns a fresh portfolio with reset state and a new Strategy
 // instance built from StrategySpec. Used by the runner so each simulation
 // pass gets independent state and workers don't race on shared portfolios.
 func (p *Portfolio) Clone() (*Portfolio, error) {
-	strat, err := NewStrategy(p.StrategySpec, p.StrategyParams)
+	strat, err := p.newStrategy()
 	if err != nil {
 		return nil, err
 	}
 	days := cap(p.DailyReturns)
-	return &Portfolio{
+	c := &Portfolio{
 		Pname:                p.Pname,
 		BuyingPower:          p.InitialBuyingPower,
 		InitialBuyingPower:   p.InitialBuyingPower,
@@ -85,13 +266,69 @@ func (p *Portfolio) Clone() (*Portfolio, error) {
 		StrategySpec:         p.StrategySpec,
 		StrategyParams:       p.StrategyParams,
 		Strategy:             strat,
-	}, nil
+		Fill:                 p.Fill,
+		FillWindow:           p.FillWindow,
+		LotMethod:            p.LotMethod,
+		AuditLookahead:       p.AuditLookahead,
+		AllowShort:           p.AllowShort,
+		Commission:           p.Commission,
+		SlippageBps:          p.SlippageBps,
+		Costs:                p.Costs,
+		Calendar:             p.Calendar,
+		Timeframe:            p.Timeframe,
+		DataGaps:             p.DataGaps,
+		DelistingHaircut:     p.DelistingHaircut,
+		Interval:             p.Interval,
+		VaRLevels:            p.VaRLevels,
+		RollingWindows:       p.RollingWindows,
+		MonteCarloConfig:     p.MonteCarloConfig,
+		SignificanceConfig:   p.SignificanceConfig,
+		RandomEntry:          p.RandomEntry,
+		trials:               p.trials,
+		Contributions:        p.Contributions,
+		Risk:                 p.Risk,
+		Margin:               p.Margin,
+		Tax:                  p.Tax,
+		Universe:             p.Universe,
+		members:              p.members,
+		spells:               p.spells,
+		SettlementDays:       p.SettlementDays,
+		Prices:               p.Prices,
+		Benchmark:            p.Benchmark,
+		RiskFree:             p.RiskFree,
+		BenchmarkSource:      p.BenchmarkSource,
+		Seed:                 p.Seed,
+		Logger:               p.Logger,
+		Router:               p.Router,
+		Sleeves:              p.Sleeves,
+		hooks:                p.hooks,
+	}
+	// A SimClock tracks one run's bars, so each clone starts its own.
+	if _, sim := p.Clock.(*SimClock); !sim {
+		c.Clock = p.Clock
+	}
+	c.SetAccounting(p.Accounting)
+	if len(c.Sleeves) > 0 {
+		if c.sleeves, err = c.newSleeves(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
 }
 
+// Position is a holding in one ticker; Amount is negative when short.
 type Position struct {
 	Amount       float64
 	AveragePrice float64
 	CurrentPrice float64
+	// Opened is the date of the buy that opened the position, and Fees
+	// the buy fees not yet charged to a ClosedTrade.
+	Opened time.Time
+	Fees   float64
+	// Lots are the open lots, oldest first, under FIFO or LIFO lot
+	// accounting; nil under LotAverage. Opened, AveragePrice and Fees
+	// then follow them as lots close.
+	Lots []Lot
 }
 
 func (p *Portfolio) FindPosition(ticker string) (*Position, bool) {
@@ -99,88 +336,229 @@ func (p *Portfolio) FindPosition(ticker string) (*Position, bool) {
 	return pos, ok
 }
 
+// PrintMetrics logs p's positions, one record each, and its metrics.
 func (p *Portfolio) PrintMetrics() {
-	log.Printf("Buying Power: %.2f\n", p.BuyingPower)
-	if len(p.Positions) == 0 {
-		log.Println("No positions")
-	}
-	for key, pos := range p.Positions {
-		log.Printf(
-			"Ticker: %s, Amount: %.2f, Average Price: %.2f, CurrentPrice: %.2f\n",
-			key, pos.Amount, pos.AveragePrice, pos.CurrentPrice,
-		)
-		currentValue := pos.Amount*pos.CurrentPrice + p.BuyingPower
-		log.Println("Amount now is", currentValue)
-	}
-	log.Println("=============================================")
-	log.Printf("Annual Metrics: \n")
-	log.Printf("Sharpe Ratio: %.2f\n", p.Metrics.SharpeRatio)
-	log.Printf("Sortino Ratio: %.2f\n", p.Metrics.SortinoRatio)
-	log.Printf("MaxDrawdown: %.2f\n", p.Metrics.MaxDrawdown)
-	log.Printf("Annual Return: %.2f\n", p.Metrics.AnnualReturn)
-	log.Printf("Standard Deviation: %.4f\n", p.Metrics.StandardDev)
-	log.Println("=============================================")
+	keys := make([]string, 0, len(p.Positions))
+	for key := range p.Positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		pos := p.Positions[key]
+		logger.Info("position", "portfolio", p.Pname, "ticker", key, "amount", pos.Amount,
+			"average_price", pos.AveragePrice, "current_price", pos.CurrentPrice)
+	}
+	m := p.Metrics
+	attrs := []any{
+		"portfolio", p.Pname, "buying_power", p.BuyingPower, "positions", len(p.Positions),
+		"sharpe", m.SharpeRatio, "sortino", m.SortinoRatio, "max_drawdown", m.MaxDrawdown,
+		"annual_return", m.AnnualReturn, "standard_dev", m.StandardDev, "calmar", m.CalmarRatio,
+		"max_drawdown_days", m.MaxDrawdownDays, "recovery_days", m.RecoveryDays,
+		"closed_trades", m.ClosedTrades, "win_rate", m.WinRate, "profit_factor", m.ProfitFactor,
+		"expectancy", m.Expectancy, "gross_exposure", m.AvgGrossExposure,
+		"net_exposure", m.AvgNetExposure, "max_leverage", m.MaxLeverage,
+	}
+	if len(p.CashFlows) > 0 {
+		attrs = append(attrs, "irr", m.IRR, "net_contributions", m.NetContributions)
+	}
+	if p.Margin != nil {
+		attrs = append(attrs, "margin_interest", m.MarginInterest)
+	}
+	if p.Tax != nil {
+		attrs = append(attrs, "taxes_paid", m.TaxesPaid, "deferred_tax", m.DeferredTax, "after_tax_return", m.AfterTaxReturn)
+	}
+	if !p.Halted.IsZero() {
+		attrs = append(attrs, "halted", formatDate(p.Halted))
+	}
+	if p.ExecutionFailures > 0 {
+		attrs = append(attrs, "execution_failures", p.ExecutionFailures)
+	}
+	logger.Info("metrics", attrs...)
 }
 
+// Buy adds amount shares of ticker at initialPrice, covering any short
+// position first. It returns an *OrderError, leaving the portfolio
+// unchanged, if the order is invalid (see validateOrder), cash doesn't
+// cover it, the portfolio is short and it would take gross exposure past
+// its leverage (see AllowShort), it would break a sector limit (see
+// RiskConfig), it would grow a position in a ticker outside the
+// universe on time (see ErrNotInUniverse) or the Executor fails it (see
+// ErrExecution).
 func (p *Portfolio) Buy(
 	ticker string,
 	amount float64,
 	initialPrice float64,
 	time time.Time,
-) {
-	if p.BuyingPower < amount*initialPrice {
-		return
+) error {
+	if err := validateOrder("BUY", ticker, amount, initialPrice); err != nil {
+		return err
 	}
-	if amount == 0.0 {
-		return
+	quoted := initialPrice
+	initialPrice, fee := p.execute("BUY", ticker, amount, quoted)
+	if cost := amount*initialPrice + fee; !p.canAfford(cost) {
+		return &OrderError{"BUY", ticker, amount, quoted, p.fundsShort(cost)}
 	}
-	pos, ok := p.FindPosition(ticker)
-	if !ok {
-		// Position does not exist, create a new one
-		p.Positions[ticker] = &Position{
-			Amount:       amount,
-			AveragePrice: initialPrice,
+	if !p.withinLeverage(ticker, amount, initialPrice, fee) {
+		return &OrderError{"BUY", ticker, amount, quoted, ErrInsufficientFunds}
+	}
+	if err := p.checkSectorLimit(ticker, amount, initialPrice); err != nil {
+		return &OrderError{"BUY", ticker, amount, quoted, err}
+	}
+	if err := p.checkUniverse(ticker, amount, time); err != nil {
+		return &OrderError{"BUY", ticker, amount, quoted, err}
+	}
+	if err := p.record(Trade{
+		Date: time, Ticker: ticker, Side: "BUY",
+		Amount: amount, Price: initialPrice, Fee: fee,
+	}); err != nil {
+		return &OrderError{"BUY", ticker, amount, quoted, err}
+	}
+	p.txLog().Debug("BUY", "portfolio", p.Pname, "ticker", ticker,
+		"amount", amount, "price", initialPrice, "date", formatDate(time))
+	p.fill(ticker, amount, initialPrice, fee, time)
+	p.adjustCash(-amount*initialPrice - fee)
+	return nil
+}
+
+// record forwards t to the Executor, if any, and appends it to the
+// ledger once the Executor has it. A trade the Executor fails isn't
+// booked, so the simulation doesn't drift from the account: record
+// counts it in ExecutionFailures and returns ErrExecution.
+func (p *Portfolio) record(t Trade) error {
+	if t.Note == "" {
+		t.Note = p.note
+	}
+	if p.Executor != nil {
+		if err := p.Executor.Execute(p.context(), t); err != nil {
+			p.ExecutionFailures++
+			logger.Error("execute trade", "portfolio", p.Pname, "side", t.Side, "ticker", t.Ticker,
+				"amount", t.Amount, "price", t.Price, "err", err)
+			return fmt.Errorf("%w: %w", ErrExecution, err)
 		}
-	} else {
-		// Position exists, update it
-		pos.AveragePrice = (pos.AveragePrice*pos.Amount +
-			initialPrice*amount) / (pos.Amount + amount)
-		pos.Amount += amount
-	}
-	TransactionLogger.Printf(
-		"BUY: %s, Amount: %.2f, Price: %.2f, Date: %s\n",
-		ticker, amount, initialPrice, time,
-	)
-	p.BuyingPower -= amount * initialPrice
+	}
+	p.Trades = append(p.Trades, t)
+	if p.engine != nil {
+		p.engine.Publish(FillEvent{Day: p.bar, Trade: t})
+	}
+	return nil
 }
 
 func (p *Portfolio) Deposit(cash float64) {
-	p.BuyingPower += cash
+	p.adjustCash(cash)
 }
 
 func (p *Portfolio) Withdraw(cash float64) {
-	p.BuyingPower -= cash
+	p.adjustCash(-cash)
 }
 
+// Sell removes stockAmount shares of ticker at currentPrice. It returns
+// an *OrderError, leaving the portfolio unchanged, if the order is
+// invalid, would break a sector limit or, unless AllowShort is set,
+// sells more shares than are held. A sale into a short is refused with
+// ErrInsufficientFunds if the portfolio can't back it (see AllowShort),
+// with ErrNotInUniverse if the ticker isn't a member on time, or with
+// ErrExecution if the Executor fails it.
 func (p *Portfolio) Sell(
 	ticker string,
 	stockAmount float64,
 	currentPrice float64,
 	time time.Time,
-) {
+) error {
+	if err := validateOrder("SELL", ticker, stockAmount, currentPrice); err != nil {
+		return err
+	}
 	pos, ok := p.FindPosition(ticker)
-	if !ok || pos.Amount < stockAmount || pos.Amount <= 0 {
-		return
+	if !p.AllowShort && (!ok || pos.Amount < stockAmount) {
+		return &OrderError{"SELL", ticker, stockAmount, currentPrice, ErrInsufficientShares}
 	}
-	TransactionLogger.Printf(
-		"SELL: %s, Amount: %.2f, Price: %.2f, Date: %s\n",
-		ticker, stockAmount, currentPrice, time,
-	)
-	pos.Amount -= stockAmount
-	if pos.Amount == 0 {
-		delete(p.Positions, ticker)
+	if err := p.checkSectorLimit(ticker, -stockAmount, currentPrice); err != nil {
+		return &OrderError{"SELL", ticker, stockAmount, currentPrice, err}
+	}
+	if err := p.checkUniverse(ticker, -stockAmount, time); err != nil {
+		return &OrderError{"SELL", ticker, stockAmount, currentPrice, err}
+	}
+	quoted := currentPrice
+	currentPrice, fee := p.execute("SELL", ticker, stockAmount, currentPrice)
+	if !p.withinLeverage(ticker, -stockAmount, currentPrice, fee) {
+		return &OrderError{"SELL", ticker, stockAmount, quoted, ErrInsufficientFunds}
 	}
-	p.Deposit(stockAmount * currentPrice)
+	if err := p.record(Trade{
+		Date: time, Ticker: ticker, Side: "SELL",
+		Amount: stockAmount, Price: currentPrice, Fee: fee,
+	}); err != nil {
+		return &OrderError{"SELL", ticker, stockAmount, quoted, err}
+	}
+	p.txLog().Debug("SELL", "portfolio", p.Pname, "ticker", ticker,
+		"amount", stockAmount, "price", currentPrice, "date", formatDate(time))
+	p.fill(ticker, -stockAmount, currentPrice, fee, time)
+	p.Deposit(stockAmount*currentPrice - fee)
+	p.holdProceeds(stockAmount*currentPrice-fee, time)
+	return nil
+}
+
+// fill moves ticker's position by shares (negative for a sale) at price
+// with fee. Shares that reduce an open position close that part of it
+// into ClosedTrades (see closeLots); any left over open or add to a
+// position on their own side, long or short. Fees are split between the
+// two by shares.
+func (p *Portfolio) fill(ticker string, shares, price, fee float64, date time.Time) {
+	pos, ok := p.FindPosition(ticker)
+	if ok && pos.Amount*shares < 0 {
+		closing, closeFee := math.Abs(shares), fee
+		if held := math.Abs(pos.Amount); held < closing {
+			closing, closeFee = held, fee*held/closing
+		}
+		short := pos.Amount < 0
+		p.closeLots(ticker, pos, closing, price, closeFee, date)
+		p.accrueTax(date)
+		if short {
+			pos.Amount += closing
+			shares -= closing
+		} else {
+			pos.Amount -= closing
+			shares += closing
+		}
+		fee -= closeFee
+		if pos.Amount == 0 {
+			delete(p.Positions, ticker)
+			ok = false
+		}
+		if shares == 0 {
+			return
+		}
+	}
+	if !ok {
+		pos = &Position{
+			Amount:       shares,
+			AveragePrice: price,
+			Opened:       date,
+			Fees:         fee,
+		}
+		p.Positions[ticker] = pos
+	} else {
+		pos.AveragePrice = (pos.AveragePrice*pos.Amount + price*shares) / (pos.Amount + shares)
+		pos.Amount += shares
+		pos.Fees += fee
+	}
+	if p.tracksLots() {
+		pos.Lots = append(pos.Lots, Lot{Opened: date, Amount: math.Abs(shares), Price: price, Fees: fee})
+	}
+}
+
+func (p *Portfolio) txLog() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return TransactionLogger
+}
+
+// Rand is the portfolio's random source, seeded from Seed on first use
+// so a strategy's draws repeat exactly from run to run.
+func (p *Portfolio) Rand() *rand.Rand {
+	if p.rng == nil {
+		p.rng = rand.New(rand.NewSource(p.Seed))
+	}
+	return p.rng
 }
 
 func (p *Portfolio) GetPortfolioValue(
@@ -194,7 +572,7 @@ func (p *Portfolio) GetPortfolioValue(
 		if day >= len(tickerData) {
 			continue
 		}
-		if position, ok := p.Positions[ticker]; ok && position.Amount > 0 {
+		if position, ok := p.Positions[ticker]; ok && position.Amount != 0 {
 			value += position.Amount * tickerData[day].Close
 		}
 	}
@@ -214,14 +592,16 @@ func (p *Portfolio) AdjustPortfolioParameters(
 	if startingValue > 0.0 {
 		dailyChange = (endingValue - startingValue) / startingValue
 	}
-	TransactionLogger.Printf("dailyChange: %.4f\n", dailyChange*100)
 	date := currentDayData[tickers[0]][day].Date
+	if tx := p.txLog(); tx.Enabled(p.context(), slog.LevelDebug) {
+		tx.Debug("daily change", "portfolio", p.Pname, "pct", dailyChange*100, "date", formatDate(date))
+	}
 	p.DailyReturns = append(p.DailyReturns,
 		DailyReturn{Date: date, Return: dailyChange})
 	p.PortfolioCloseValues = append(p.PortfolioCloseValues, endingValue)
 
 	for _, ticker := range tickers {
-		if pos, ok := p.Positions[ticker]; ok && pos.Amount > 0 {
+		if pos, ok := p.Positions[ticker]; ok && pos.Amount != 0 {
 			tickerData := currentDayData[ticker]
 			if day < len(tickerData) {
 				pos.CurrentPrice = tickerData[day].Close
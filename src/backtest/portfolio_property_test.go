@@ -0,0 +1,234 @@
+package backtest
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// op is one randomly generated order against a Portfolio.
+type op struct {
+	Ticker string
+	Buy    bool
+	Amount float64 // whole shares, > 0
+	Price  float64 // > 0
+}
+
+// opSeq is a random order sequence; it implements quick.Generator so
+// testing/quick produces valid-looking orders rather than arbitrary
+// floats.
+type opSeq []op
+
+func (opSeq) Generate(r *rand.Rand, size int) reflect.Value {
+	tickers := []string{"A", "B", "C"}
+	ops := make(opSeq, r.Intn(size*4+1))
+	for i := range ops {
+		ops[i] = op{
+			Ticker: tickers[r.Intn(len(tickers))],
+			Buy:    r.Intn(3) > 0,
+			Amount: float64(1 + r.Intn(50)),
+			Price:  0.01 + r.Float64()*500,
+		}
+	}
+	return reflect.ValueOf(ops)
+}
+
+var propDate = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+func newPropPortfolio(cash float64, a Accounting) *Portfolio {
+	p := &Portfolio{
+		BuyingPower: cash, InitialBuyingPower: cash,
+		Positions: map[string]*Position{},
+	}
+	p.SetAccounting(a)
+	return p
+}
+
+func (o op) apply(p *Portfolio) {
+	if o.Buy {
+		p.Buy(o.Ticker, o.Amount, o.Price, propDate)
+	} else {
+		p.Sell(o.Ticker, o.Amount, o.Price, propDate)
+	}
+}
+
+var propConfig = &quick.Config{MaxCount: 300}
+
+func TestProperty_CashNeverNegative(t *testing.T) {
+	for _, a := range []Accounting{AccountingFloat, AccountingCents} {
+		f := func(ops opSeq) bool {
+			p := newPropPortfolio(10_000, a)
+			for _, o := range ops {
+				o.apply(p)
+				if p.BuyingPower < 0 {
+					return false
+				}
+				for _, pos := range p.Positions {
+					if pos.Amount <= 0 {
+						return false
+					}
+				}
+			}
+			return true
+		}
+		if err := quick.Check(f, propConfig); err != nil {
+			t.Errorf("%s: %v", a, err)
+		}
+	}
+}
+
+func TestProperty_OversellRejected(t *testing.T) {
+	f := func(ops opSeq, extra uint8) bool {
+		p := newPropPortfolio(10_000, AccountingFloat)
+		for _, o := range ops {
+			o.apply(p)
+		}
+		for tk, pos := range p.Positions {
+			cash, held, n := p.BuyingPower, pos.Amount, len(p.Trades)
+			p.Sell(tk, held+1+float64(extra), 10, propDate)
+			if p.BuyingPower != cash || pos.Amount != held || len(p.Trades) != n {
+				return false
+			}
+		}
+		cash, n := p.BuyingPower, len(p.Trades)
+		p.Sell("NEVER_HELD", 1, 10, propDate)
+		return p.BuyingPower == cash && len(p.Trades) == n
+	}
+	if err := quick.Check(f, propConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+// Buying any sequence and then selling everything back at the same
+// prices returns the starting cash: exactly under cents accounting and
+// to within float rounding otherwise, when there are no costs.
+func TestProperty_RoundTripRestoresCash(t *testing.T) {
+	for _, a := range []Accounting{AccountingFloat, AccountingCents} {
+		f := func(ops opSeq) bool {
+			p := newPropPortfolio(1_000_000, a)
+			for _, o := range ops {
+				p.Buy(o.Ticker, o.Amount, o.Price, propDate)
+				p.Sell(o.Ticker, o.Amount, o.Price, propDate)
+			}
+			if len(p.Positions) != 0 {
+				return false
+			}
+			if a == AccountingCents {
+				return p.cashCents == toCents(1_000_000)
+			}
+			return math.Abs(p.BuyingPower-1_000_000) < 1e-6
+		}
+		if err := quick.Check(f, propConfig); err != nil {
+			t.Errorf("%s: %v", a, err)
+		}
+	}
+}
+
+// With costs, the same round trips leave the starting cash minus the
+// fees recorded on the trades and, under slippage, the spread between
+// the recorded buy and sell prices: cents accounting rounds each fill to
+// the cent, float accounting is exact to within rounding.
+func TestProperty_RoundTripCostsCash(t *testing.T) {
+	for _, costs := range []struct {
+		name       string
+		commission float64
+		slippage   float64
+		models     Costs
+	}{
+		{"commission", 1.5, 0, nil},
+		{"per share", 0, 0, Costs{PerShareFee{PerShare: 0.005, Min: 1}}},
+		{"commission and slippage", 1, 10, Costs{PerShareFee{PerShare: 0.01}}},
+	} {
+		for _, a := range []Accounting{AccountingFloat, AccountingCents} {
+			f := func(ops opSeq) bool {
+				p := newPropPortfolio(1_000_000, a)
+				p.Commission, p.SlippageBps, p.Costs = costs.commission, costs.slippage, costs.models
+				for _, o := range ops {
+					p.Buy(o.Ticker, o.Amount, o.Price, propDate)
+					p.Sell(o.Ticker, o.Amount, o.Price, propDate)
+				}
+				if len(p.Positions) != 0 || len(p.Trades) != 2*len(ops) {
+					return false
+				}
+				fees, spread := 0.0, 0.0
+				for _, tr := range p.Trades {
+					fees += tr.Fee
+					if tr.Side == "BUY" {
+						spread += tr.Amount * tr.Price
+					} else {
+						spread -= tr.Amount * tr.Price
+					}
+				}
+				if costs.slippage == 0 && spread != 0 {
+					return false
+				}
+				want := 1_000_000 - fees - spread
+				tolerance := 1e-6
+				if a == AccountingCents {
+					tolerance = 0.005 * float64(len(p.Trades))
+				}
+				return math.Abs(p.BuyingPower-want) <= tolerance
+			}
+			if err := quick.Check(f, propConfig); err != nil {
+				t.Errorf("%s, %s: %v", costs.name, a, err)
+			}
+		}
+	}
+}
+
+// AveragePrice always equals the cost basis per held share (buys add
+// cost, sells release it at the average) and stays within the range of
+// buy prices since the position was opened, under any interleaving of
+// buys and partial sells.
+func TestProperty_AveragePriceStable(t *testing.T) {
+	f := func(ops opSeq) bool {
+		p := newPropPortfolio(math.Inf(1), AccountingFloat)
+		type basis struct{ cost, shares, lo, hi float64 }
+		track := map[string]*basis{}
+		for _, o := range ops {
+			if o.Buy {
+				p.Buy(o.Ticker, o.Amount, o.Price, propDate)
+				b := track[o.Ticker]
+				if b == nil {
+					b = &basis{lo: o.Price, hi: o.Price}
+					track[o.Ticker] = b
+				}
+				b.cost += o.Amount * o.Price
+				b.shares += o.Amount
+				b.lo, b.hi = math.Min(b.lo, o.Price), math.Max(b.hi, o.Price)
+				continue
+			}
+			pos, ok := p.FindPosition(o.Ticker)
+			if !ok || pos.Amount < o.Amount {
+				continue
+			}
+			p.Sell(o.Ticker, o.Amount, o.Price, propDate)
+			if _, still := p.Positions[o.Ticker]; !still {
+				delete(track, o.Ticker)
+				continue
+			}
+			// Selling releases shares at the average cost, leaving the
+			// average of what remains unchanged.
+			b := track[o.Ticker]
+			b.cost -= o.Amount * b.cost / b.shares
+			b.shares -= o.Amount
+		}
+		for tk, pos := range p.Positions {
+			b := track[tk]
+			want := b.cost / b.shares
+			if math.Abs(pos.AveragePrice-want) > 1e-9*want {
+				return false
+			}
+			if pos.AveragePrice < b.lo*(1-1e-12) || pos.AveragePrice > b.hi*(1+1e-12) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, propConfig); err != nil {
+		t.Error(err)
+	}
+}
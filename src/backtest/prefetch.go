@@ -0,0 +1,148 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"sync/atomic"
+	"time"
+)
+
+// prefetchStore is a read-only cache in front of a Store: the bars of
+// every ticker a batch of portfolios reads, over their combined window,
+// and the risk-free rates for it, queried once and shared. Queries the
+// cache covers are answered from it, each with its own copy of the bars
+// and returns recomputed from the window's first bar, exactly as the
+// Store would answer them; anything else goes to the Store.
+type prefetchStore struct {
+	Store
+	bars       map[string][]data.AssetData
+	rates      map[int64]float64 // nil when no portfolio reads the Store's
+	start, end time.Time
+
+	// fetch is how long the prefetch took; queries and wait count the
+	// queries answered since, cached or not, and the time spent in them.
+	fetch   time.Duration
+	queries atomic.Int64
+	wait    atomic.Int64
+}
+
+// queryStats is a count of queries and the time spent answering them.
+type queryStats struct {
+	queries int64
+	elapsed time.Duration
+}
+
+func (ps *prefetchStore) stats() queryStats {
+	return queryStats{ps.queries.Load(), time.Duration(ps.wait.Load())}
+}
+
+// record counts a query that began at began.
+func (ps *prefetchStore) record(began time.Time) {
+	ps.queries.Add(1)
+	ps.wait.Add(int64(time.Since(began)))
+}
+
+// logJob logs, at debug level, the queries p's job made since before and
+// how long they took.
+func (ps *prefetchStore) logJob(p *Portfolio, before queryStats) {
+	after := ps.stats()
+	runLogger.Debug("job queries", "portfolio", p.Pname,
+		"queries", after.queries-before.queries, "elapsed", after.elapsed-before.elapsed)
+}
+
+// logSummary logs the prefetch's time next to the time the jobs spent in
+// their queries, so the two can be compared with a run without it.
+func (ps *prefetchStore) logSummary(jobs int) {
+	s := ps.stats()
+	runLogger.Info("prefetch served", "jobs", jobs, "queries", s.queries,
+		"elapsed", s.elapsed, "prefetch", ps.fetch, "total", ps.fetch+s.elapsed)
+}
+
+// prefetch loads what portfolios will query from store.
+func prefetch(ctx context.Context, store Store, portfolios []*Portfolio) *prefetchStore {
+	ps := &prefetchStore{Store: store}
+	if len(portfolios) == 0 {
+		return ps
+	}
+	began := time.Now()
+	ps.start, ps.end = dateRange(portfolios)
+	tickers := allTickers(portfolios)
+	ps.bars = store.QueryAssetsForTickers(ctx, tickers, ps.start, ps.end)
+	if usesStoreRiskFree(portfolios) {
+		ps.rates = store.GetRiskFreeRates(ctx, riskFreeStart(ps.start), ps.end)
+	}
+	ps.fetch = time.Since(began)
+	runLogger.Info("prefetched", "tickers", len(tickers), "portfolios", len(portfolios), "elapsed", ps.fetch)
+	return ps
+}
+
+// covers reports whether start..end lies within from..to, where a zero
+// bound is unbounded.
+func covers(from, to, start, end time.Time) bool {
+	if !from.IsZero() && (start.IsZero() || start.Before(from)) {
+		return false
+	}
+	return to.IsZero() || !end.IsZero() && !end.After(to)
+}
+
+func (ps *prefetchStore) QueryAssetsForTickers(
+	ctx context.Context, tickers []string, start, end time.Time,
+) map[string][]data.AssetData {
+	defer ps.record(time.Now())
+	if ps.bars == nil || !covers(ps.start, ps.end, start, end) {
+		return ps.Store.QueryAssetsForTickers(ctx, tickers, start, end)
+	}
+	out := make(map[string][]data.AssetData, len(tickers))
+	var missing []string
+	for _, t := range tickers {
+		series, ok := ps.bars[t]
+		if !ok {
+			missing = append(missing, t)
+			continue
+		}
+		if clipped := clipSeries(series, start, end); len(clipped) > 0 {
+			out[t] = append([]data.AssetData(nil), clipped...)
+			data.FillReturns(out[t])
+		}
+	}
+	if len(missing) > 0 {
+		for t, series := range ps.Store.QueryAssetsForTickers(ctx, missing, start, end) {
+			out[t] = series
+		}
+	}
+	return out
+}
+
+func (ps *prefetchStore) GetRiskFreeRates(ctx context.Context, start, end time.Time) map[int64]float64 {
+	defer ps.record(time.Now())
+	if ps.rates == nil || !covers(riskFreeStart(ps.start), ps.end, start, end) {
+		return ps.Store.GetRiskFreeRates(ctx, start, end)
+	}
+	out := make(map[int64]float64)
+	for day, r := range ps.rates {
+		if d := time.Unix(day, 0); (start.IsZero() || !d.Before(start)) && (end.IsZero() || !d.After(end)) {
+			out[day] = r
+		}
+	}
+	return out
+}
+
+// QueryActions passes through to the Store, so prefetching doesn't hide
+// its corporate actions.
+func (ps *prefetchStore) QueryActions(
+	ctx context.Context, tickers []string, start, end time.Time,
+) map[string][]data.CorporateAction {
+	if as, ok := ps.Store.(ActionStore); ok {
+		return as.QueryActions(ctx, tickers, start, end)
+	}
+	return nil
+}
+
+// SymbolChanges passes through to the Store, so prefetching doesn't
+// hide its renames.
+func (ps *prefetchStore) SymbolChanges(ctx context.Context) ([]data.SymbolChange, error) {
+	if ss, ok := ps.Store.(SymbolStore); ok {
+		return ss.SymbolChanges(ctx)
+	}
+	return nil, nil
+}
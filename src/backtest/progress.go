@@ -0,0 +1,112 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressInterval, when positive, makes Run and RunStream print their
+// progress to ProgressOutput that often, and once more when they finish.
+// The CLI sets it with -progress.
+var (
+	ProgressInterval time.Duration
+	ProgressOutput   io.Writer = os.Stderr
+)
+
+// Progress counts the portfolios a run has finished out of its total.
+// It is safe for concurrent use; a nil *Progress counts nothing.
+type Progress struct {
+	total   int
+	done    atomic.Int64
+	started time.Time
+}
+
+// ProgressSnapshot is a run's progress at one moment.
+type ProgressSnapshot struct {
+	Done    int           `json:"done"`
+	Total   int           `json:"total"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+	// PerSecond is the portfolios finished per second so far, and ETA
+	// the time left at that rate; 0 until the first finishes.
+	PerSecond float64       `json:"per_second"`
+	ETA       time.Duration `json:"eta_ns"`
+}
+
+func (s ProgressSnapshot) String() string {
+	pct := 100.0
+	if s.Total > 0 {
+		pct = 100 * float64(s.Done) / float64(s.Total)
+	}
+	eta := "-"
+	if s.PerSecond > 0 {
+		eta = s.ETA.Round(time.Second).String()
+	}
+	return fmt.Sprintf("%d/%d portfolios (%.1f%%), %.1f/s, elapsed %s, ETA %s",
+		s.Done, s.Total, pct, s.PerSecond, s.Elapsed.Round(time.Second), eta)
+}
+
+// current is the progress of the run in flight, for CurrentProgress.
+var current atomic.Pointer[Progress]
+
+// CurrentProgress reports the progress of the Run or RunStream in
+// flight, if any. With several runs at once it is the latest started.
+// The CLI serves it as the "progress" expvar on its -debug pprof port.
+func CurrentProgress() (ProgressSnapshot, bool) {
+	p := current.Load()
+	if p == nil {
+		return ProgressSnapshot{}, false
+	}
+	return p.Snapshot(), true
+}
+
+// startProgress begins tracking a run of total portfolios, printing it
+// every ProgressInterval. The returned stop prints the final count and
+// ends the tracking.
+func startProgress(total int) (p *Progress, stop func()) {
+	p = &Progress{total: total, started: time.Now()}
+	current.Store(p)
+	quit, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		if ProgressInterval <= 0 {
+			<-quit
+			return
+		}
+		ticker := time.NewTicker(ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(ProgressOutput, "progress: %v\n", p.Snapshot())
+			case <-quit:
+				fmt.Fprintf(ProgressOutput, "progress: %v\n", p.Snapshot())
+				return
+			}
+		}
+	}()
+	return p, func() {
+		close(quit)
+		<-exited
+		current.CompareAndSwap(p, nil)
+	}
+}
+
+// Add counts one more finished portfolio.
+func (p *Progress) Add() {
+	if p != nil {
+		p.done.Add(1)
+	}
+}
+
+// Snapshot returns the progress so far.
+func (p *Progress) Snapshot() ProgressSnapshot {
+	s := ProgressSnapshot{Done: int(p.done.Load()), Total: p.total, Elapsed: time.Since(p.started)}
+	if s.Done > 0 && s.Elapsed > 0 {
+		s.PerSecond = float64(s.Done) / s.Elapsed.Seconds()
+		s.ETA = time.Duration(float64(s.Total-s.Done) / s.PerSecond * float64(time.Second))
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RiskFreeProvider supplies the daily risk-free rates (decimals, keyed by
+// Unix seconds) that Sharpe and Sortino are measured against. Days
+// without a rate are forward-filled (see alignRiskFree); no rates at all
+// leaves both ratios 0.
+type RiskFreeProvider interface {
+	RiskFreeRates(ctx context.Context, start, end time.Time) map[int64]float64
+}
+
+// BenchmarkProvider supplies the date-ordered bars of a benchmark for
+// Metrics.BenchmarkReturn.
+type BenchmarkProvider interface {
+	BenchmarkBars(ctx context.Context, name string, start, end time.Time) []data.AssetData
+}
+
+// DBRiskFree reads rates from the "3MTreasuryYields" table through a
+// Store. It is what portfolios without a RiskFree provider use.
+type DBRiskFree struct{ Store Store }
+
+func (r DBRiskFree) RiskFreeRates(ctx context.Context, start, end time.Time) map[int64]float64 {
+	return r.Store.GetRiskFreeRates(ctx, start, end)
+}
+
+// ConstantRiskFree is the same daily rate on every calendar day. With
+// an unbounded (zero) start it publishes only end's rate, which
+// alignRiskFree applies to every earlier day as well.
+type ConstantRiskFree float64
+
+func (r ConstantRiskFree) RiskFreeRates(_ context.Context, start, end time.Time) map[int64]float64 {
+	if start.IsZero() {
+		start = end
+	}
+	rates := make(map[int64]float64)
+	for d := start.UTC().Truncate(24 * time.Hour); !d.After(end); d = d.AddDate(0, 0, 1) {
+		rates[d.Unix()] = float64(r)
+	}
+	return rates
+}
+
+// ZeroRiskFree measures Sharpe and Sortino against a zero rate, i.e. on
+// raw returns.
+type ZeroRiskFree struct{}
+
+func (ZeroRiskFree) RiskFreeRates(ctx context.Context, start, end time.Time) map[int64]float64 {
+	return ConstantRiskFree(0).RiskFreeRates(ctx, start, end)
+}
+
+// DBBenchmark reads a benchmark ticker's bars through a Store.
+type DBBenchmark struct{ Store Store }
+
+func (b DBBenchmark) BenchmarkBars(ctx context.Context, name string, start, end time.Time) []data.AssetData {
+	return b.Store.QueryAssetsForTickers(ctx, []string{name}, start, end)[name]
+}
+
+// ProviderStore runs against dp: its bars and risk-free rates stand in
+// for the database's. A dp that is already a Store (*data.Store is) is
+// returned as is; otherwise macro series are read from dp if it has a
+// QueryMacro method like Store's, and are empty if not.
+func ProviderStore(dp data.DataProvider) Store {
+	if s, ok := dp.(Store); ok {
+		return s
+	}
+	return providerStore{dp}
+}
+
+type providerStore struct{ data.DataProvider }
+
+func (s providerStore) QueryAssetsForTickers(ctx context.Context, tickers []string, start, end time.Time) map[string][]data.AssetData {
+	return s.GetBars(ctx, tickers, start, end)
+}
+
+func (s providerStore) GetRiskFreeRates(ctx context.Context, start, end time.Time) map[int64]float64 {
+	return s.GetRiskFree(ctx, start, end)
+}
+
+func (s providerStore) QueryMacro(ctx context.Context, series string, end time.Time) []data.MacroPoint {
+	if m, ok := s.DataProvider.(interface {
+		QueryMacro(context.Context, string, time.Time) []data.MacroPoint
+	}); ok {
+		return m.QueryMacro(ctx, series, end)
+	}
+	return nil
+}
+
+// ParseRiskFree maps a config value to a RiskFreeProvider: "" or "db"
+// for the database table (returned as nil, so Run supplies its Store),
+// "zero", or a number for a constant daily rate.
+func ParseRiskFree(s string) (RiskFreeProvider, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "db":
+		return nil, nil
+	case "zero":
+		return ZeroRiskFree{}, nil
+	}
+	rate, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil, fmt.Errorf("risk-free %q: must be db, zero, or a daily rate", s)
+	}
+	return ConstantRiskFree(rate), nil
+}
+
+// WithRiskFree measures the portfolio's Sharpe and Sortino against rf
+// instead of the database's Treasury yields.
+func WithRiskFree(rf RiskFreeProvider) Option {
+	return func(p *Portfolio) error {
+		p.RiskFree = rf
+		return nil
+	}
+}
+
+// WithBenchmarkSource reads the Benchmark's bars from b instead of the
+// Store the portfolio runs against.
+func WithBenchmarkSource(b BenchmarkProvider) Option {
+	return func(p *Portfolio) error {
+		p.BenchmarkSource = b
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"my-backtester/src/metrics"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConstantRiskFree_EveryDay(t *testing.T) {
+	start := time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+	rates := ConstantRiskFree(0.0001).RiskFreeRates(context.Background(), start, start.AddDate(0, 0, 9))
+	if len(rates) != 10 {
+		t.Fatalf("got %d days, want 10", len(rates))
+	}
+	days := []int64{start.Unix(), start.AddDate(0, 0, 9).Unix()}
+	got, filled := metrics.AlignRiskFree(rates, days)
+	if filled != 0 || got[0] != 0.0001 || got[1] != 0.0001 {
+		t.Errorf("aligned %v with %d filled", got, filled)
+	}
+}
+
+func TestParseRiskFree(t *testing.T) {
+	for in, want := range map[string]RiskFreeProvider{
+		"":       nil,
+		"db":     nil,
+		"Zero":   ZeroRiskFree{},
+		"0.0002": ConstantRiskFree(0.0002),
+	} {
+		got, err := ParseRiskFree(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRiskFree(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseRiskFree("treasury"); err == nil {
+		t.Error("expected error for unknown risk-free source")
+	}
+}
+
+type fixedBenchmark map[string][]data.AssetData
+
+func (b fixedBenchmark) BenchmarkBars(_ context.Context, name string, start, end time.Time) []data.AssetData {
+	return clipSeries(b[name], start, end)
+}
+
+// With its own providers a portfolio needs no risk-free table, and its
+// benchmark is not read from the Store.
+func TestRun_Providers(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}}
+	bench := fixedBenchmark{}
+	for i := 0; i < 10; i++ {
+		c := 100 * (1 + 0.01*float64(i%3))
+		store.bars["A"] = append(store.bars["A"], data.AssetData{Date: day(i), Open: c, High: c, Low: c, Close: c})
+		bench["IDX"] = append(bench["IDX"], data.AssetData{Date: day(i), Close: 100 + float64(i)})
+	}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "buyAndHold:equalWeights",
+		WithWindow(day(0), day(9)),
+		WithBenchmark("IDX"),
+		WithRiskFree(ZeroRiskFree{}),
+		WithBenchmarkSource(bench),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.rateReads != 0 {
+		t.Errorf("read the Store's risk-free rates %d times", store.rateReads)
+	}
+	if !reflect.DeepEqual(store.queried, []string{"A"}) {
+		t.Errorf("queried %v, want [A]", store.queried)
+	}
+	m := results[0].Metrics
+	if m.SharpeRatio == 0 || m.Observations == 0 || m.RiskFreeFilled != 0 {
+		t.Errorf("metrics = %+v, want Sharpe over unfilled zero rates", m)
+	}
+	if m.BenchmarkReturn <= 0 {
+		t.Errorf("BenchmarkReturn = %v, want the provider's rising series", m.BenchmarkReturn)
+	}
+}
+
+// A directory of CSV files runs like the database: bars are windowed
+// per ticker and risk_free.csv feeds Sharpe.
+func TestRun_CSVProvider(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("A.csv", "date,open,high,low,close,volume\n"+
+		"2023-12-29,5,5,5,5,100\n2024-01-02,10,10,10,10,100\n2024-01-03,11,11,11,11,100\n2024-01-04,12,12,12,12,100\n")
+	write("B.csv", "date,open,high,low,close,volume\n2024-01-02,1,1,1,1,1\n")
+	write(data.RiskFreeFile, "date,rate\n2024-01-02,0.0001\n2024-01-03,0.0001\n2024-01-04,0.0001\n")
+
+	store, release, err := (&DatabaseConfig{Provider: "csv"}).OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	p, err := NewPortfolio("csv", 1000, []string{"A"}, "greedy",
+		WithWindow(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if len(r.Trades) != 1 || r.Trades[0].Price != 10 || r.Trades[0].Amount != 100 {
+		t.Errorf("trades = %+v, want 100 shares at the 2024-01-02 close", r.Trades)
+	}
+	if r.Metrics.SharpeRatio == 0 || r.Metrics.RiskFreeFilled != 0 {
+		t.Errorf("metrics = %+v, want Sharpe over the file's rates", r.Metrics)
+	}
+	tickers, err := data.NewCSVDir(dir, data.ImportOptions{}).GetTickers(context.Background())
+	if err != nil || !reflect.DeepEqual(tickers, []string{"A", "B"}) {
+		t.Errorf("GetTickers = %v, %v; want [A B]", tickers, err)
+	}
+
+	if _, _, err := (&DatabaseConfig{Provider: "postgres"}).OpenStore(dir); err == nil {
+		t.Error("unknown provider: expected error")
+	}
+}
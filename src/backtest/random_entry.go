@@ -0,0 +1,106 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"my-backtester/src/data"
+	"sort"
+	"strconv"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// DefaultRandomEntryRuns is how many random-entry runs a portfolio's
+// result is measured against when its config sets no Runs.
+const DefaultRandomEntryRuns = 100
+
+// DefaultRandomEntryPercentile is the percentile of the random-entry
+// runs' Sharpe ratios reported when a config sets none.
+const DefaultRandomEntryPercentile = 95
+
+// RandomEntryConfig asks for a portfolio's Sharpe ratio to be measured
+// against runs of the RandomEntry strategy on the same tickers (see
+// WithRandomEntry). All fields are optional.
+type RandomEntryConfig struct {
+	Runs int `toml:"runs"` // default DefaultRandomEntryRuns
+	// Percentile of the runs' Sharpe ratios reported as
+	// RandomEntrySharpe; default DefaultRandomEntryPercentile.
+	Percentile float64 `toml:"percentile"`
+	Hold       int     `toml:"hold"` // RandomEntry's mean bars held; default 20
+}
+
+// WithRandomEntry runs cfg.Runs copies of the portfolio with random
+// entries and exits once it finishes, each seeded one further after its
+// Seed, and reports where its Sharpe ratio falls among theirs as
+// Metrics.RandomEntrySharpe and RandomEntryRank: a strategy whose timing
+// is worth anything should beat random timing on the same tickers.
+func WithRandomEntry(cfg RandomEntryConfig) Option {
+	return func(p *Portfolio) error {
+		if cfg.Runs < 0 {
+			return fmt.Errorf("random entry runs %d: must not be negative", cfg.Runs)
+		}
+		if cfg.Percentile != 0 && !(cfg.Percentile > 0 && cfg.Percentile <= 100) {
+			return fmt.Errorf("random entry percentile %v: must be in (0, 100]", cfg.Percentile)
+		}
+		if cfg.Hold < 0 {
+			return fmt.Errorf("random entry hold %d: must not be negative", cfg.Hold)
+		}
+		p.RandomEntry = &cfg
+		return nil
+	}
+}
+
+// compareRandomEntry runs p's random-entry copies over the bars p ran
+// on and sets its Metrics' RandomEntrySharpe and RandomEntryRank. It
+// reports false if ctx was done before they finished.
+func (p *Portfolio) compareRandomEntry(
+	ctx context.Context,
+	hist map[string][]data.AssetData,
+	riskFreeRates map[int64]float64,
+) bool {
+	cfg := p.RandomEntry
+	if cfg == nil || len(p.DailyReturns) == 0 {
+		return true
+	}
+	runs := cfg.Runs
+	if runs == 0 {
+		runs = DefaultRandomEntryRuns
+	}
+	pc := cfg.Percentile
+	if pc == 0 {
+		pc = DefaultRandomEntryPercentile
+	}
+	spec := "random-entry"
+	if cfg.Hold > 0 {
+		spec += ":" + strconv.Itoa(cfg.Hold)
+	}
+	sharpes := make([]float64, 0, runs)
+	beaten := 0
+	for i := 1; i <= runs; i++ {
+		c, err := p.Clone()
+		if err != nil {
+			return true
+		}
+		c.StrategySpec, c.StrategyParams, c.Sleeves = spec, nil, nil
+		if c.Strategy, err = NewStrategy(spec, nil); err != nil {
+			return true
+		}
+		c.Seed = p.Seed + int64(i)
+		c.RandomEntry, c.MonteCarloConfig, c.SignificanceConfig = nil, nil, nil
+		c.Logger = slog.New(slog.DiscardHandler)
+		c.store, c.actions, c.trials = p.store, p.actions, p.trials
+		if !runOne(ctx, c, hist, riskFreeRates) {
+			return false
+		}
+		sharpes = append(sharpes, c.Metrics.SharpeRatio)
+		if p.Metrics.SharpeRatio > c.Metrics.SharpeRatio {
+			beaten++
+		}
+	}
+	sort.Float64s(sharpes)
+	p.Metrics.RandomEntryRuns = runs
+	p.Metrics.RandomEntrySharpe = stat.Quantile(pc/100, stat.Empirical, sharpes, nil)
+	p.Metrics.RandomEntryRank = 100 * float64(beaten) / float64(runs)
+	return true
+}
@@ -0,0 +1,61 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestRandomEntry(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i) }
+	bars := map[string][]data.AssetData{}
+	rates := map[int64]float64{}
+	for i := 0; i < 200; i++ {
+		rates[day(i).Unix()] = 0
+		c := 10 + float64(i)/20 + math.Sin(float64(i)/3)
+		bars["A"] = append(bars["A"], data.AssetData{Date: day(i), Open: c, High: c, Low: c, Close: c, Volume: 100})
+	}
+	store := &fakeStore{bars: bars, rates: rates}
+	run := func(spec string, cfg RandomEntryConfig) Result {
+		p, err := NewPortfolio("p", 1000, []string{"A"}, spec, WithWindow(day(0), day(199)), WithRandomEntry(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("run: %v, %d results", err, len(results))
+		}
+		return results[0]
+	}
+
+	r := run("greedy", RandomEntryConfig{Runs: 20, Hold: 10})
+	m := r.Metrics
+	if m.RandomEntryRuns != 20 || m.RandomEntrySharpe == 0 || m.RandomEntryRank < 0 || m.RandomEntryRank > 100 {
+		t.Fatalf("random entry = %d runs, Sharpe %v, rank %v", m.RandomEntryRuns, m.RandomEntrySharpe, m.RandomEntryRank)
+	}
+	if again := run("greedy", RandomEntryConfig{Runs: 20, Hold: 10}).Metrics; again.RandomEntrySharpe != m.RandomEntrySharpe ||
+		again.RandomEntryRank != m.RandomEntryRank {
+		t.Errorf("the same seeds drew Sharpe %v and rank %v, then %v and %v",
+			m.RandomEntrySharpe, m.RandomEntryRank, again.RandomEntrySharpe, again.RandomEntryRank)
+	}
+	// Random entries on one ticker are in and out of it: some runs trade
+	// many times and none holds it throughout as greedy does.
+	entry := run("random-entry:10", RandomEntryConfig{Runs: 1})
+	if len(entry.Trades) < 4 || entry.Strategy != "random-entry:10" {
+		t.Errorf("random-entry:10 made %d trades as %s; want it in and out", len(entry.Trades), entry.Strategy)
+	}
+	if entry.Metrics.RandomEntryRuns != 1 {
+		t.Errorf("random entry runs = %d, want 1", entry.Metrics.RandomEntryRuns)
+	}
+
+	for _, bad := range []RandomEntryConfig{{Runs: -1}, {Percentile: 101}, {Hold: -1}} {
+		if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithRandomEntry(bad)); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+	if _, err := NewStrategy("random-entry:0", nil); err == nil {
+		t.Error("random-entry:0: expected error")
+	}
+}
@@ -0,0 +1,241 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Kind groups registered components by the role they play in a run.
+type Kind string
+
+const (
+	KindStrategy   Kind = "strategies"
+	KindSizer      Kind = "sizers"
+	KindCommission Kind = "commissions"
+	KindIndicator  Kind = "indicators"
+)
+
+// Kinds lists every Kind in the order `backtester list` prints them.
+var Kinds = []Kind{KindStrategy, KindSizer, KindCommission, KindIndicator}
+
+// Param describes one parameter a component accepts, either as a field
+// of its spec string or as a config key.
+type Param struct {
+	Name    string
+	Type    string // "int", "float", "string", "path", "table" or "sizer"
+	Default string // empty when the parameter is required
+	Doc     string
+}
+
+// Component is the metadata a strategy, sizer, commission model or
+// indicator registers under its name. Usage is the form it takes in a
+// config, e.g. "smaCross:<short>:<long>:<sizer>".
+type Component struct {
+	Kind   Kind
+	Name   string
+	Usage  string
+	Doc    string
+	Params []Param
+}
+
+// StrategyFactory builds a strategy from the part of its spec after the
+// first ':' ("" when there is none) and the portfolio's Params.
+type StrategyFactory func(arg string, params map[string]any) (Strategy, error)
+
+var registry = struct {
+	sync.RWMutex
+	components map[Kind]map[string]Component
+	strategies map[string]StrategyFactory
+	sizers     map[string]SizerFactory
+}{
+	components: make(map[Kind]map[string]Component),
+	strategies: make(map[string]StrategyFactory),
+	sizers:     make(map[string]SizerFactory),
+}
+
+// Register adds c to the registry. Like database/sql.Register it is meant
+// to be called from init, and panics if the name is already taken.
+func Register(c Component) {
+	registry.Lock()
+	defer registry.Unlock()
+	byName := registry.components[c.Kind]
+	if byName == nil {
+		byName = make(map[string]Component)
+		registry.components[c.Kind] = byName
+	}
+	if _, dup := byName[c.Name]; dup {
+		panic(fmt.Sprintf("backtest: %s %q registered twice", c.Kind, c.Name))
+	}
+	byName[c.Name] = c
+}
+
+// RegisterStrategy registers a strategy component and the factory
+// NewStrategy calls for specs starting with its name.
+func RegisterStrategy(c Component, f StrategyFactory) {
+	c.Kind = KindStrategy
+	Register(c)
+	registry.Lock()
+	defer registry.Unlock()
+	registry.strategies[c.Name] = f
+}
+
+// Lookup returns the component of kind registered as name.
+func Lookup(kind Kind, name string) (Component, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.components[kind][name]
+	return c, ok
+}
+
+// Components returns every component of kind, sorted by name.
+func Components(kind Kind) []Component {
+	registry.RLock()
+	defer registry.RUnlock()
+	out := make([]Component, 0, len(registry.components[kind]))
+	for _, c := range registry.components[kind] {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ParseKind maps a `backtester list` argument to a Kind; the singular
+// form is accepted too.
+func ParseKind(s string) (Kind, error) {
+	for _, k := range Kinds {
+		if s == string(k) || s+"s" == string(k) || s == strings.TrimSuffix(string(k), "ies")+"y" {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown component kind %q: must be one of %s", s, joinKinds())
+}
+
+func joinKinds() string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, ", ")
+}
+
+// WriteComponents prints the components of kind with their parameters,
+// or of every kind when kind is empty.
+func WriteComponents(w io.Writer, kind Kind) error {
+	kinds := Kinds
+	if kind != "" {
+		kinds = []Kind{kind}
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, k := range kinds {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s:\n", k)
+		for _, c := range Components(k) {
+			fmt.Fprintf(tw, "  %s\t%s\n", c.Usage, c.Doc)
+			for _, p := range c.Params {
+				def := ""
+				if p.Default != "" {
+					def = " (default " + p.Default + ")"
+				}
+				fmt.Fprintf(tw, "    %s %s\t%s%s\n", p.Name, p.Type, p.Doc, def)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// checkSizer reports whether spec is a valid sizer spec, so a misspelt
+// buy type fails at load time instead of silently buying nothing.
+func checkSizer(spec string) error {
+	_, err := NewSizer(spec)
+	return err
+}
+
+func componentNames(kind Kind) string {
+	cs := Components(kind)
+	names := make([]string, len(cs))
+	for i, c := range cs {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	for _, c := range []Component{
+		{
+			Kind: KindCommission, Name: "flat", Usage: "Commission = <dollars>",
+			Doc:    "flat fee charged on every fill",
+			Params: []Param{{Name: "Commission", Type: "float", Default: "0", Doc: "fee per fill, in dollars"}},
+		},
+		{
+			Kind: KindCommission, Name: "perShare", Usage: "CommissionPerShare = <dollars>",
+			Doc: "fee per share traded, with a minimum per fill",
+			Params: []Param{
+				{Name: "CommissionPerShare", Type: "float", Default: "0", Doc: "fee per share, in dollars"},
+				{Name: "CommissionMin", Type: "float", Default: "0", Doc: "minimum fee per fill"},
+			},
+		},
+		{
+			Kind: KindCommission, Name: "slippage", Usage: "SlippageBps = <bps>",
+			Doc:    "moves each fill price against the trade",
+			Params: []Param{{Name: "SlippageBps", Type: "float", Default: "0", Doc: "basis points per fill"}},
+		},
+		{
+			Kind: KindCommission, Name: "volumeSlippage", Usage: "VolumeImpact = <fraction>",
+			Doc:    "moves each fill by its share of the bar's volume",
+			Params: []Param{{Name: "VolumeImpact", Type: "float", Default: "0", Doc: "price move when taking the whole bar"}},
+		},
+		{
+			Kind: KindIndicator, Name: "sma", Usage: "sma(ticker, day, period)",
+			Doc:    "mean Close over [day-period, day); Go: SMA",
+			Params: []Param{{Name: "period", Type: "int", Doc: "bars averaged"}},
+		},
+		{
+			Kind: KindIndicator, Name: "rsi", Usage: "rsi(ticker, day, period)",
+			Doc:    "Wilder's relative strength index of Close changes; Go: indicators.RSI",
+			Params: []Param{{Name: "period", Type: "int", Doc: "smoothing bars"}},
+		},
+		{
+			Kind: KindIndicator, Name: "ema", Usage: "ema(ticker, day, period)",
+			Doc:    "exponential moving average of Close; Go: indicators.EMA",
+			Params: []Param{{Name: "period", Type: "int", Doc: "bars; smoothing 2/(period+1)"}},
+		},
+		{
+			Kind: KindIndicator, Name: "macd", Usage: "macd(ticker, day, fast, slow, signal)",
+			Doc: "MACD line, signal line and histogram of Close; Go: indicators.MACD",
+			Params: []Param{
+				{Name: "fast", Type: "int", Doc: "fast EMA period, usually 12"},
+				{Name: "slow", Type: "int", Doc: "slow EMA period, usually 26"},
+				{Name: "signal", Type: "int", Doc: "signal EMA period, usually 9"},
+			},
+		},
+		{
+			Kind: KindIndicator, Name: "bbands", Usage: "bbands(ticker, day, period, k)",
+			Doc: "middle, upper and lower Bollinger Bands of Close; Go: indicators.Bollinger",
+			Params: []Param{
+				{Name: "period", Type: "int", Doc: "SMA period, usually 20"},
+				{Name: "k", Type: "float", Doc: "band width in standard deviations, usually 2"},
+			},
+		},
+		{
+			Kind: KindIndicator, Name: "atr", Usage: "atr(ticker, day, period)",
+			Doc:    "Wilder's average true range; Go: indicators.ATR",
+			Params: []Param{{Name: "period", Type: "int", Doc: "smoothing bars, usually 14"}},
+		},
+		{
+			Kind: KindIndicator, Name: "stoch", Usage: "stoch(ticker, day, k, d)",
+			Doc: "stochastic oscillator %K and %D; Go: indicators.Stochastic",
+			Params: []Param{
+				{Name: "k", Type: "int", Doc: "high-low lookback, usually 14"},
+				{Name: "d", Type: "int", Doc: "%D smoothing, usually 3"},
+			},
+		},
+	} {
+		Register(c)
+	}
+}
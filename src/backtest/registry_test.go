@@ -0,0 +1,78 @@
+package backtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewStrategy_Registry(t *testing.T) {
+	for _, c := range []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "greedy", want: "buyAndHold:greedy"},
+		{spec: "buyAndHold", want: "buyAndHold:greedy"},
+		{spec: "buyAndHold:equalWeights", want: "buyAndHold:equalWeights"},
+		{spec: "smaCross:5:20:greedy", want: "smaCross:5:20:greedy"},
+		{spec: "buyAndHold:greedyy", wantErr: true},
+		{spec: "smaCross:5:20:eqWeights", wantErr: true},
+		{spec: "smaCross:0:20:greedy", wantErr: true},
+		{spec: "smaCross:5:20", wantErr: true},
+		{spec: "trades:", wantErr: true},
+		{spec: "momentum:10", wantErr: true},
+	} {
+		s, err := NewStrategy(c.spec, nil)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error", c.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.spec, err)
+		} else if s.Name() != c.want {
+			t.Errorf("%q: Name() = %q, want %q", c.spec, s.Name(), c.want)
+		}
+	}
+
+	_, err := NewStrategy("astrology:10", nil)
+	if err == nil || !strings.Contains(err.Error(), "smaCross") {
+		t.Errorf("unknown spec error should list the registered strategies: %v", err)
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering greedy twice")
+		}
+	}()
+	Register(Component{Kind: KindSizer, Name: "greedy"})
+}
+
+func TestWriteComponents(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteComponents(&buf, KindStrategy); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"strategies:", "smaCross:<short>:<long>:<sizer>", "lua:<path>", "(default greedy)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "sizers:") {
+		t.Errorf("listing strategies printed other kinds:\n%s", out)
+	}
+
+	for _, s := range []string{"strategy", "strategies", "sizer", "indicators", "commission"} {
+		if _, err := ParseKind(s); err != nil {
+			t.Errorf("ParseKind(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseKind("brokers"); err == nil {
+		t.Error("expected error for an unknown kind")
+	}
+}
@@ -0,0 +1,290 @@
+package backtest
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WriteHTMLReport writes results as one self-contained HTML page: for
+// each portfolio its equity curve against its benchmark, its drawdown,
+// its sector exposures, a heatmap of monthly returns, its metrics, its
+// return's attribution and its trades. Charts are inline SVG, so the
+// page needs no scripts or network access to view.
+func WriteHTMLReport(w io.Writer, results []Result) error {
+	page := htmlReport{Version: Version}
+	for _, r := range results {
+		page.Portfolios = append(page.Portfolios, newHTMLPortfolio(r))
+	}
+	if err := htmlReportTemplate.Execute(w, page); err != nil {
+		return fmt.Errorf("html report: %w", err)
+	}
+	return nil
+}
+
+type htmlReport struct {
+	Version    string
+	Portfolios []htmlPortfolio
+}
+
+type htmlPortfolio struct {
+	Result
+	Equity, Drawdown svgChart
+	Months           []monthRow
+	Metrics          []htmlMetric
+	Parts            []htmlPart // the Attribution's rows
+	Sectors          svgChart   // the sector exposures, if any
+}
+
+type htmlMetric struct{ Name, Value string }
+
+type htmlPart struct {
+	By string
+	AttributionRow
+}
+
+// svgChart is a line chart scaled into a chartWidth × chartHeight box.
+type svgChart struct {
+	Lines      []svgLine
+	Top, Low   string // the y axis' labels
+	First, End string // the x axis' labels
+}
+
+type svgLine struct{ Class, Label, Points string }
+
+type monthRow struct {
+	Year   string
+	Months [12]heatCell
+	Total  heatCell
+}
+
+// heatCell is a return and its heatmap colour; empty with no return.
+type heatCell struct{ Text, Color string }
+
+const chartWidth, chartHeight = 800, 220
+
+// sectorColors is how many .s<n> colours the sector lines cycle through.
+const sectorColors = 6
+
+func newHTMLPortfolio(r Result) htmlPortfolio {
+	hp := htmlPortfolio{Result: r, Months: monthlyReturns(r.Dates, r.Returns)}
+	lines := []svgLine{{Class: "equity", Label: r.PortfolioName}}
+	series := [][]float64{r.EquityCurve}
+	if len(r.BenchmarkCurve) > 0 {
+		lines = append(lines, svgLine{Class: "benchmark", Label: "Benchmark"})
+		series = append(series, r.BenchmarkCurve)
+	}
+	hp.Equity = lineChart(r.Dates, "%.0f", lines, series)
+	hp.Drawdown = lineChart(r.Dates, "%.1f%%",
+		[]svgLine{{Class: "drawdown", Label: "Drawdown"}}, [][]float64{drawdownSeries(r.EquityCurve)})
+	for _, name := range resultFields[2:] {
+		if v, ok := resultValue(r, name); ok {
+			hp.Metrics = append(hp.Metrics, htmlMetric{name, formatValue(v)})
+		}
+	}
+	if len(r.Sectors) > 0 {
+		lines := make([]svgLine, len(r.Sectors))
+		series := make([][]float64, len(r.Sectors))
+		for i, s := range r.Sectors {
+			lines[i] = svgLine{Class: fmt.Sprintf("sector s%d", i%sectorColors), Label: s.Sector}
+			series[i] = s.Exposure
+		}
+		hp.Sectors = lineChart(r.Dates, "%.0f%%", lines, series)
+	}
+	if a := r.Attribution; a != nil {
+		for _, by := range []struct {
+			name string
+			rows []AttributionRow
+		}{{"Ticker", a.Tickers}, {"Strategy", a.Strategies}, {"Book", a.Books}, {"", []AttributionRow{a.Other, a.Total}}} {
+			for _, row := range by.rows {
+				hp.Parts = append(hp.Parts, htmlPart{by.name, row})
+			}
+		}
+	}
+	return hp
+}
+
+// lineChart draws each of series as the matching line, scaled to one
+// shared y range labelled with yFormat, over dates.
+func lineChart(dates []string, yFormat string, lines []svgLine, series [][]float64) svgChart {
+	var c svgChart
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, v := range s {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return c
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+	for i, line := range lines {
+		var pts strings.Builder
+		for j, v := range series[i] {
+			x := 0.0
+			if len(series[i]) > 1 {
+				x = float64(j) / float64(len(series[i])-1) * chartWidth
+			}
+			y := (hi - v) / (hi - lo) * chartHeight
+			fmt.Fprintf(&pts, "%.1f,%.1f ", x, y)
+		}
+		line.Points = strings.TrimSpace(pts.String())
+		c.Lines = append(c.Lines, line)
+	}
+	c.Top, c.Low = fmt.Sprintf(yFormat, hi), fmt.Sprintf(yFormat, lo)
+	if len(dates) > 0 {
+		c.First, c.End = dates[0], dates[len(dates)-1]
+	}
+	return c
+}
+
+// drawdownSeries is each value's percent below the highest before it.
+func drawdownSeries(values []float64) []float64 {
+	dd := make([]float64, len(values))
+	peak := math.Inf(-1)
+	for i, v := range values {
+		peak = math.Max(peak, v)
+		if peak > 0 {
+			dd[i] = (v/peak - 1) * 100
+		}
+	}
+	return dd
+}
+
+// monthlyReturns compounds daily returns, 1:1 with dates (YYYY-MM-DD),
+// into each calendar month's and year's return.
+func monthlyReturns(dates []string, returns []float64) []monthRow {
+	var rows []monthRow
+	var year, month float64 = 1, 1
+	cur := -1
+	flush := func() {
+		if cur >= 0 {
+			rows[len(rows)-1].Months[cur] = newHeatCell(month - 1)
+			rows[len(rows)-1].Total = newHeatCell(year - 1)
+		}
+	}
+	for i, d := range dates {
+		if i >= len(returns) || len(d) < 7 {
+			break
+		}
+		m, _ := strconv.Atoi(d[5:7])
+		if len(rows) == 0 || rows[len(rows)-1].Year != d[:4] {
+			flush()
+			rows = append(rows, monthRow{Year: d[:4]})
+			year, month, cur = 1, 1, m-1
+		} else if m-1 != cur {
+			flush()
+			month, cur = 1, m-1
+		}
+		month *= 1 + returns[i]
+		year *= 1 + returns[i]
+	}
+	flush()
+	return rows
+}
+
+// newHeatCell formats a return in percent, shaded from white to green
+// or red at ±10%.
+func newHeatCell(ret float64) heatCell {
+	shade := math.Min(math.Abs(ret)/0.10, 1)
+	to := [3]float64{46, 160, 67}
+	if ret < 0 {
+		to = [3]float64{207, 34, 46}
+	}
+	mix := func(c float64) int { return int(math.Round(255 + (c-255)*shade)) }
+	return heatCell{
+		Text:  fmt.Sprintf("%.1f", ret*100),
+		Color: fmt.Sprintf("#%02x%02x%02x", mix(to[0]), mix(to[1]), mix(to[2])),
+	}
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Backtest report</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em auto; max-width: 900px; color: #222; }
+section { margin-bottom: 3em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 2px 8px; text-align: right; border-bottom: 1px solid #eee; }
+th:first-child, td:first-child { text-align: left; }
+svg { background: #fafafa; overflow: visible; }
+svg text { font-size: 11px; fill: #666; }
+polyline { fill: none; stroke-width: 1.5; }
+.equity { stroke: #1f6feb; color: #1f6feb; }
+.benchmark { stroke: #999; stroke-dasharray: 4 3; color: #999; }
+.drawdown { stroke: #cf222e; color: #cf222e; }
+.s0 { stroke: #1f6feb; color: #1f6feb; }
+.s1 { stroke: #e36209; color: #e36209; }
+.s2 { stroke: #2da44e; color: #2da44e; }
+.s3 { stroke: #8250df; color: #8250df; }
+.s4 { stroke: #bf8700; color: #bf8700; }
+.s5 { stroke: #57606a; color: #57606a; }
+.legend span { margin-right: 1em; }
+.heat td { min-width: 3em; }
+.trades { max-height: 400px; overflow-y: auto; display: block; }
+</style>
+</head>
+<body>
+<h1>Backtest report</h1>
+<p>Generated by backtester {{.Version}}.</p>
+{{range .Portfolios}}
+<section>
+<h2>{{.PortfolioName}}</h2>
+<p>{{.Strategy}} on {{range $i, $t := .Tickers}}{{if $i}}, {{end}}{{$t}}{{end}}, {{.EffectiveStart}} to {{.EffectiveEnd}}.</p>
+
+<h3>Equity</h3>
+{{template "chart" .Equity}}
+<h3>Drawdown</h3>
+{{template "chart" .Drawdown}}
+{{if .Sectors.Lines}}
+<h3>Sector exposure</h3>
+{{template "chart" .Sectors}}
+<table>
+<tr><th>Sector</th><th>Average %</th><th>Max %</th></tr>
+{{range .Result.Sectors}}<tr><td>{{.Sector}}</td><td>{{printf "%.1f" .Average}}</td><td>{{printf "%.1f" .Max}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h3>Monthly returns (%)</h3>
+<table class="heat">
+<tr><th>Year</th><th>Jan</th><th>Feb</th><th>Mar</th><th>Apr</th><th>May</th><th>Jun</th><th>Jul</th><th>Aug</th><th>Sep</th><th>Oct</th><th>Nov</th><th>Dec</th><th>Year</th></tr>
+{{range .Months}}<tr><td>{{.Year}}</td>{{range .Months}}<td{{if .Color}} style="background: {{.Color}}"{{end}}>{{.Text}}</td>{{end}}<td style="background: {{.Total.Color}}">{{.Total.Text}}</td></tr>
+{{end}}</table>
+
+<h3>Metrics</h3>
+<table>
+{{range .Metrics}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Parts}}
+<h3>Attribution</h3>
+<table>
+<tr><th>By</th><th>Name</th><th>P&amp;L</th><th>Contribution (pts)</th></tr>
+{{range .Parts}}<tr><td>{{.By}}</td><td>{{.Name}}</td><td>{{printf "%.2f" .PnL}}</td><td>{{printf "%.2f" .Contribution}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h3>Trades ({{len .Trades}})</h3>
+<table class="trades">
+<tr><th>Date</th><th>Ticker</th><th>Side</th><th>Amount</th><th>Price</th><th>Fee</th></tr>
+{{range .Trades}}<tr><td>{{.Date.Format "2006-01-02"}}</td><td>{{.Ticker}}</td><td>{{.Side}}</td><td>{{printf "%.4g" .Amount}}</td><td>{{printf "%.2f" .Price}}</td><td>{{printf "%.2f" .Fee}}</td></tr>
+{{end}}</table>
+</section>
+{{end}}
+</body>
+</html>
+{{define "chart"}}{{if .Lines}}<div class="legend">{{range .Lines}}<span class="{{.Class}}">&#9473; {{.Label}}</span>{{end}}</div>
+<svg viewBox="-60 -10 880 250" width="100%">
+<text x="-8" y="4" text-anchor="end">{{.Top}}</text>
+<text x="-8" y="220" text-anchor="end">{{.Low}}</text>
+<text x="0" y="236">{{.First}}</text>
+<text x="800" y="236" text-anchor="end">{{.End}}</text>
+{{range .Lines}}<polyline class="{{.Class}}" points="{{.Points}}"/>
+{{end}}</svg>{{else}}<p>No data.</p>{{end}}{{end}}
+`))
@@ -0,0 +1,78 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"my-backtester/src/data"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	r := Result{
+		PortfolioName:  "<p1>",
+		Strategy:       "buyAndHold",
+		Tickers:        []string{"A", "B"},
+		EquityCurve:    []float64{1000, 1100, 990, 1045},
+		BenchmarkCurve: []float64{1000, 1010, 1020, 1030},
+		Dates:          []string{"2024-01-30", "2024-01-31", "2024-02-01", "2024-03-01"},
+		Returns:        []float64{0, 0.1, -0.1, 0.0555},
+		Trades:         []Trade{{Date: time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC), Ticker: "A", Side: "BUY", Amount: 10, Price: 100}},
+	}
+	var b strings.Builder
+	if err := WriteHTMLReport(&b, []Result{r}); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	for _, want := range []string{
+		"<h2>&lt;p1&gt;</h2>",
+		`<polyline class="equity" points="0.0,200.0 266.7,0.0 533.3,220.0 800.0,110.0"/>`,
+		`<polyline class="benchmark"`,
+		`<polyline class="drawdown"`,
+		"<td>2024-01-30</td><td>A</td><td>BUY</td>",
+		"<td>SharpeRatio</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report lacks %s", want)
+		}
+	}
+
+	rows := monthlyReturns(r.Dates, r.Returns)
+	if len(rows) != 1 || rows[0].Months[0].Text != "10.0" || rows[0].Months[1].Text != "-10.0" ||
+		rows[0].Months[2].Text != "5.6" || rows[0].Months[3] != (heatCell{}) || rows[0].Total.Text != "4.5" {
+		t.Errorf("monthly returns = %+v", rows)
+	}
+	if c := newHeatCell(0.2); c.Color != "#2ea043" {
+		t.Errorf("+20%% colour = %s", c.Color)
+	}
+	if got := drawdownSeries(r.EquityCurve); math.Abs(got[2]+10) > 1e-9 || got[1] != 0 {
+		t.Errorf("drawdown = %v", got)
+	}
+}
+
+func TestBenchmarkCurve(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}}
+	for i := 0; i < 5; i++ {
+		store.bars["A"] = append(store.bars["A"], data.AssetData{Date: day(i), Close: 10})
+		if i != 3 {
+			store.bars["SPY"] = append(store.bars["SPY"], data.AssetData{Date: day(i), Close: 100 + 10*float64(i)})
+		}
+	}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "buyAndHold", WithWindow(day(0), day(4)), WithBenchmark("SPY"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The curve starts from SPY's close on the first date, 110, and its
+	// missing 4th bar carries the 3rd's close.
+	want := []float64{1000, 1000 * 120.0 / 110, 1000 * 120.0 / 110, 1000 * 140.0 / 110}
+	if got := results[0].BenchmarkCurve; !reflect.DeepEqual(got, want) {
+		t.Errorf("benchmark curve = %v, want %v", got, want)
+	}
+}
@@ -13,15 +13,23 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// DefaultBatchSize is how many qualifying results the Reporter holds in
+// memory before writing them out when OutputConfig.BatchSize is unset.
+const DefaultBatchSize = 64
+
 // Reporter writes backtest Results to a file according to OutputConfig.
-// Use NewReporter to construct one; Write is safe to call from a single
-// goroutine. Close flushes buffered writers (CSV) and closes the file.
+// Use NewReporter to construct one; Write and Flush are safe to call from
+// a single goroutine. Results are batched in memory and the file is only
+// opened on the first flush, so a run that produces nothing does no I/O
+// until Close. Close writes any remaining results and closes the file.
 type Reporter struct {
+	path    string
 	format  string
 	file    *os.File
-	out     *bufio.Writer // used for txt/json; nil for csv (csv has its own buffering)
+	out     *bufio.Writer // used for txt/json/ndjson; nil for csv (csv has its own buffering)
 	csv     *csv.Writer
 	filter  ast.Expr
 	fields  []string
@@ -31,6 +39,11 @@ type Reporter struct {
 	sortAsc bool
 	limit   int
 	buf     []Result
+
+	batchSize     int
+	flushInterval time.Duration
+	fsync         bool
+	pending       []Result
 }
 
 // resultFields lists every value addressable from a filter expression or
@@ -45,6 +58,45 @@ var resultFields = []string{
 	"StandardDev",
 	"AvgCorrelation",
 	"CointegratedPairs",
+	"BenchmarkReturn",
+	"CalmarRatio",
+	"IRR",
+	"NetContributions",
+	"AvgGrossExposure",
+	"AvgNetExposure",
+	"MaxLeverage",
+	"MarginInterest",
+	"MaxSectorExposure",
+	"TaxesPaid",
+	"DeferredTax",
+	"AfterTaxReturn",
+	"UnsettledRejects",
+	"TStat",
+	"PValue",
+	"Trials",
+	"DeflatedSharpe",
+	"SharpeLow",
+	"SharpeHigh",
+	"AnnualReturnLow",
+	"AnnualReturnHigh",
+	"RandomEntrySharpe",
+	"RandomEntryRank",
+	"FoldScore",
+	"FoldScoreStdDev",
+	"FoldInSampleScore",
+	"PBO",
+	"ClosedTrades",
+	"WinRate",
+	"AvgWin",
+	"AvgLoss",
+	"ProfitFactor",
+	"Expectancy",
+	"AvgHoldingDays",
+	"VaR",
+	"ParametricVaR",
+	"CVaR",
+	"MaxDrawdownDays",
+	"RecoveryDays",
 }
 
 func resultValue(r Result, name string) (any, bool) {
@@ -53,6 +105,10 @@ func resultValue(r Result, name string) (any, bool) {
 		return r.PortfolioName, true
 	case "Strategy":
 		return r.Strategy, true
+	case "EffectiveStart":
+		return r.EffectiveStart, true
+	case "EffectiveEnd":
+		return r.EffectiveEnd, true
 	case "SharpeRatio":
 		return r.Metrics.SharpeRatio, true
 	case "SortinoRatio":
@@ -67,6 +123,89 @@ func resultValue(r Result, name string) (any, bool) {
 		return r.Metrics.AvgCorrelation, true
 	case "CointegratedPairs":
 		return float64(r.Metrics.CointegratedPairs), true
+	case "Observations":
+		return float64(r.Metrics.Observations), true
+	case "RiskFreeFilled":
+		return float64(r.Metrics.RiskFreeFilled), true
+	case "BenchmarkReturn":
+		return r.Metrics.BenchmarkReturn, true
+	case "CalmarRatio":
+		return r.Metrics.CalmarRatio, true
+	case "IRR":
+		return r.Metrics.IRR, true
+	case "NetContributions":
+		return r.Metrics.NetContributions, true
+	case "AvgGrossExposure":
+		return r.Metrics.AvgGrossExposure, true
+	case "AvgNetExposure":
+		return r.Metrics.AvgNetExposure, true
+	case "MaxLeverage":
+		return r.Metrics.MaxLeverage, true
+	case "MarginInterest":
+		return r.Metrics.MarginInterest, true
+	case "MaxSectorExposure":
+		return r.Metrics.MaxSectorExposure, true
+	case "TaxesPaid":
+		return r.Metrics.TaxesPaid, true
+	case "DeferredTax":
+		return r.Metrics.DeferredTax, true
+	case "AfterTaxReturn":
+		return r.Metrics.AfterTaxReturn, true
+	case "UnsettledRejects":
+		return float64(r.Metrics.UnsettledRejects), true
+	case "TStat":
+		return r.Metrics.TStat, true
+	case "PValue":
+		return r.Metrics.PValue, true
+	case "Trials":
+		return float64(r.Metrics.Trials), true
+	case "DeflatedSharpe":
+		return r.Metrics.DeflatedSharpe, true
+	case "SharpeLow":
+		return r.Metrics.SharpeLow, true
+	case "SharpeHigh":
+		return r.Metrics.SharpeHigh, true
+	case "AnnualReturnLow":
+		return r.Metrics.AnnualReturnLow, true
+	case "AnnualReturnHigh":
+		return r.Metrics.AnnualReturnHigh, true
+	case "RandomEntrySharpe":
+		return r.Metrics.RandomEntrySharpe, true
+	case "RandomEntryRank":
+		return r.Metrics.RandomEntryRank, true
+	case "FoldScore":
+		return r.Metrics.FoldScore, true
+	case "FoldScoreStdDev":
+		return r.Metrics.FoldScoreStdDev, true
+	case "FoldInSampleScore":
+		return r.Metrics.FoldInSampleScore, true
+	case "PBO":
+		return r.Metrics.PBO, true
+	case "ClosedTrades":
+		return float64(r.Metrics.ClosedTrades), true
+	case "WinRate":
+		return r.Metrics.WinRate, true
+	case "AvgWin":
+		return r.Metrics.AvgWin, true
+	case "AvgLoss":
+		return r.Metrics.AvgLoss, true
+	case "ProfitFactor":
+		return r.Metrics.ProfitFactor, true
+	case "Expectancy":
+		return r.Metrics.Expectancy, true
+	case "AvgHoldingDays":
+		return r.Metrics.AvgHoldingDays, true
+	// The VaR fields are at the first confidence level.
+	case "VaR":
+		return r.Metrics.primaryVaR().Historical, true
+	case "ParametricVaR":
+		return r.Metrics.primaryVaR().Parametric, true
+	case "CVaR":
+		return r.Metrics.primaryVaR().CVaR, true
+	case "MaxDrawdownDays":
+		return float64(r.Metrics.MaxDrawdownDays), true
+	case "RecoveryDays":
+		return float64(r.Metrics.RecoveryDays), true
 	}
 	return nil, false
 }
@@ -81,9 +220,9 @@ func NewReporter(cfg *OutputConfig) (*Reporter, error) {
 		format = "txt"
 	}
 	switch format {
-	case "txt", "csv", "json":
+	case "txt", "csv", "json", "ndjson":
 	default:
-		return nil, fmt.Errorf("output format %q: must be txt, csv, or json", cfg.Format)
+		return nil, fmt.Errorf("output format %q: must be txt, csv, json, or ndjson", cfg.Format)
 	}
 
 	fields := cfg.Fields
@@ -132,34 +271,74 @@ func NewReporter(cfg *OutputConfig) (*Reporter, error) {
 		return nil, fmt.Errorf("output limit %d: must be >= 0", cfg.Limit)
 	}
 
+	if cfg.BatchSize < 0 {
+		return nil, fmt.Errorf("output batch_size %d: must be >= 0", cfg.BatchSize)
+	}
+	batchSize := cfg.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var flushInterval time.Duration
+	if strings.TrimSpace(cfg.FlushInterval) != "" {
+		d, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("output flush_interval %q: %w", cfg.FlushInterval, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("output flush_interval %q: must be positive", cfg.FlushInterval)
+		}
+		flushInterval = d
+	}
+
+	return &Reporter{
+		path:          cfg.Path,
+		format:        format,
+		fields:        fields,
+		filter:        filter,
+		sortBy:        sortBy,
+		sortAsc:       sortAsc,
+		limit:         cfg.Limit,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		fsync:         cfg.Fsync,
+	}, nil
+}
+
+// FlushInterval is the configured period between timed flushes, or 0 if
+// only size-based and shutdown flushes are enabled.
+func (r *Reporter) FlushInterval() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.flushInterval
+}
+
+// open creates (truncating) the output file on first use.
+func (r *Reporter) open() error {
+	if r.file != nil {
+		return nil
+	}
 	file, err := os.OpenFile(
-		cfg.Path,
+		r.path,
 		os.O_TRUNC|os.O_CREATE|os.O_WRONLY,
 		0644,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("open output %q: %w", cfg.Path, err)
+		return fmt.Errorf("open output %q: %w", r.path, err)
 	}
-
-	r := &Reporter{
-		format:  format,
-		file:    file,
-		fields:  fields,
-		filter:  filter,
-		sortBy:  sortBy,
-		sortAsc: sortAsc,
-		limit:   cfg.Limit,
-	}
-	if format == "csv" {
+	r.file = file
+	if r.format == "csv" {
 		r.csv = csv.NewWriter(file)
 	} else {
 		r.out = bufio.NewWriter(file)
 	}
-	return r, nil
+	return nil
 }
 
-// Write emits one Result if it passes the filter (or if no filter is set).
-// When sort_by is configured, results are buffered and emitted in Close.
+// Write queues one Result if it passes the filter (or if no filter is set).
+// Queued results are written once the batch fills; when sort_by is
+// configured, results are held until Close so they can be ordered.
 func (r *Reporter) Write(res Result) error {
 	if r == nil {
 		return nil
@@ -179,10 +358,57 @@ func (r *Reporter) Write(res Result) error {
 		return nil
 	}
 
-	if r.limit > 0 && r.wrote >= r.limit {
+	if r.limit > 0 && r.wrote+len(r.pending) >= r.limit {
+		return nil
+	}
+	r.pending = append(r.pending, res)
+	if len(r.pending) >= r.batchSize {
+		return r.Flush()
+	}
+	return nil
+}
+
+// Flush writes every queued result to the file, flushes the buffered
+// writer, and fsyncs when configured. Sorted output is unaffected; it is
+// only written by Close.
+func (r *Reporter) Flush() error {
+	if r == nil || len(r.pending) == 0 {
 		return nil
 	}
-	return r.emit(res)
+	if err := r.open(); err != nil {
+		return err
+	}
+	var emitErr error
+	for _, res := range r.pending {
+		if err := r.emit(res); err != nil && emitErr == nil {
+			emitErr = err
+		}
+	}
+	r.pending = r.pending[:0]
+	if err := r.flushWriters(); err != nil {
+		return err
+	}
+	return emitErr
+}
+
+// flushWriters pushes buffered bytes to the file and, if fsync is set,
+// to stable storage.
+func (r *Reporter) flushWriters() error {
+	if r.csv != nil {
+		r.csv.Flush()
+		if err := r.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if r.out != nil {
+		if err := r.out.Flush(); err != nil {
+			return err
+		}
+	}
+	if r.fsync {
+		return r.file.Sync()
+	}
+	return nil
 }
 
 func (r *Reporter) emit(res Result) error {
@@ -194,6 +420,8 @@ func (r *Reporter) emit(res Result) error {
 		return r.writeCSV(res)
 	case "json":
 		return r.writeJSON(res)
+	case "ndjson":
+		return r.writeNDJSON(res)
 	}
 	return nil
 }
@@ -265,11 +493,28 @@ func (r *Reporter) writeJSON(res Result) error {
 	return err
 }
 
+// writeNDJSON writes res in full, ignoring fields, as one line of
+// WriteResultsNDJSON's output.
+func (r *Reporter) writeNDJSON(res Result) error {
+	b, err := json.Marshal(NewResultJSON(res))
+	if err != nil {
+		return err
+	}
+	_, err = r.out.Write(append(b, '\n'))
+	return err
+}
+
+// Close writes any queued or sorted results and closes the file. The file
+// is created even when no result qualified, so stale output from a
+// previous run never survives.
 func (r *Reporter) Close() error {
 	if r == nil {
 		return nil
 	}
-	var emitErr error
+	emitErr := r.Flush()
+	if err := r.open(); err != nil {
+		return err
+	}
 	if r.sortBy != "" {
 		sort.SliceStable(r.buf, func(i, j int) bool {
 			return r.lessByField(r.buf[i], r.buf[j])
@@ -284,18 +529,9 @@ func (r *Reporter) Close() error {
 			}
 		}
 	}
-	if r.csv != nil {
-		r.csv.Flush()
-		if err := r.csv.Error(); err != nil {
-			r.file.Close()
-			return err
-		}
-	}
-	if r.out != nil {
-		if err := r.out.Flush(); err != nil {
-			r.file.Close()
-			return err
-		}
+	if err := r.flushWriters(); err != nil {
+		r.file.Close()
+		return err
 	}
 	if err := r.file.Close(); err != nil {
 		return err
@@ -0,0 +1,78 @@
+package backtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReporter_BatchesUntilFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	r, err := NewReporter(&OutputConfig{
+		Path:      path,
+		Format:    "csv",
+		Fields:    []string{"PortfolioName", "SharpeRatio"},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewReporter: %v", err)
+	}
+
+	if err := r.Write(Result{PortfolioName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("output opened before the first batch filled (stat err=%v)", err)
+	}
+
+	if err := r.Write(Result{PortfolioName: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("batch of 2 should have been flushed: %v", err)
+	}
+	if lines := strings.Count(string(got), "\n"); lines != 3 {
+		t.Errorf("after first batch: %d lines, want header + 2\n%s", lines, got)
+	}
+
+	if err := r.Write(Result{PortfolioName: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, _ = os.ReadFile(path)
+	if lines := strings.Count(string(got), "\n"); lines != 4 {
+		t.Errorf("after Close: %d lines, want header + 3\n%s", lines, got)
+	}
+}
+
+func TestReporter_CloseTruncatesWithNoResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.txt")
+	if err := os.WriteFile(path, []byte("stale\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReporter(&OutputConfig{Path: path, Fsync: true})
+	if err != nil {
+		t.Fatalf("NewReporter: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	if len(got) != 0 {
+		t.Errorf("stale output survived an empty run: %q", got)
+	}
+}
+
+func TestReporter_BadFlushInterval(t *testing.T) {
+	for _, iv := range []string{"soon", "-1s", "0s"} {
+		if _, err := NewReporter(&OutputConfig{
+			Path: "unused", FlushInterval: iv,
+		}); err == nil {
+			t.Errorf("flush_interval %q: expected error", iv)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package backtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"my-backtester/src/data"
+	"strings"
+	"time"
+)
+
+// ResultInserter is a Store that can also record results; *data.Store
+// is one.
+type ResultInserter interface {
+	InsertResults(ctx context.Context, rows []data.ResultRow) error
+}
+
+// SaveResults appends results to store's results table, stamped with the
+// current time so one run's rows can be told from another's.
+func SaveResults(ctx context.Context, store Store, results []Result) error {
+	ins, ok := store.(ResultInserter)
+	if !ok {
+		return fmt.Errorf("save results: %T cannot store results", store)
+	}
+	rows, err := ResultRows(time.Now().UTC(), results)
+	if err != nil {
+		return fmt.Errorf("save results: %w", err)
+	}
+	if err := ins.InsertResults(ctx, rows); err != nil {
+		return fmt.Errorf("save results: %w", err)
+	}
+	return nil
+}
+
+// ResultRows converts results into results-table rows stamped runAt,
+// numbered in order and carrying their equity curves.
+func ResultRows(runAt time.Time, results []Result) ([]data.ResultRow, error) {
+	rows := make([]data.ResultRow, 0, len(results))
+	for i, r := range results {
+		params, hash, err := hashParams(r.Params)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.PortfolioName, err)
+		}
+		m := r.Metrics
+		v := m.primaryVaR()
+		equity := make([]data.EquityPoint, 0, len(r.Dates))
+		for j, d := range r.Dates {
+			e := data.EquityPoint{Date: parseDay(d), Value: r.EquityCurve[j]}
+			if j < len(r.Returns) {
+				e.Return = r.Returns[j]
+			}
+			equity = append(equity, e)
+		}
+		rows = append(rows, data.ResultRow{
+			RunAt:             runAt,
+			Seq:               i,
+			Portfolio:         r.PortfolioName,
+			Strategy:          r.Strategy,
+			Tickers:           strings.Join(r.Tickers, ","),
+			ParamsHash:        hash,
+			Params:            params,
+			Seed:              r.Seed,
+			StartDate:         parseDay(r.Start),
+			EndDate:           parseDay(r.End),
+			EffectiveStart:    parseDay(r.EffectiveStart),
+			EffectiveEnd:      parseDay(r.EffectiveEnd),
+			SharpeRatio:       m.SharpeRatio,
+			SortinoRatio:      m.SortinoRatio,
+			MaxDrawdown:       m.MaxDrawdown,
+			AnnualReturn:      m.AnnualReturn,
+			StandardDev:       m.StandardDev,
+			AvgCorrelation:    m.AvgCorrelation,
+			CointegratedPairs: m.CointegratedPairs,
+			Observations:      m.Observations,
+			RiskFreeFilled:    m.RiskFreeFilled,
+			BenchmarkReturn:   m.BenchmarkReturn,
+			CalmarRatio:       m.CalmarRatio,
+			ClosedTrades:      m.ClosedTrades,
+			WinRate:           m.WinRate,
+			AvgWin:            m.AvgWin,
+			AvgLoss:           m.AvgLoss,
+			ProfitFactor:      m.ProfitFactor,
+			Expectancy:        m.Expectancy,
+			AvgHoldingDays:    m.AvgHoldingDays,
+			VaRConfidence:     v.Confidence,
+			VaR:               v.Historical,
+			ParametricVaR:     v.Parametric,
+			CVaR:              v.CVaR,
+			MaxDrawdownDays:   m.MaxDrawdownDays,
+			RecoveryDays:      m.RecoveryDays,
+			Equity:            equity,
+		})
+	}
+	return rows, nil
+}
+
+// RowResult is the Result a results-table row records, with row.Equity
+// as its equity curve: what the results dashboard and an HTML report
+// need of a past run. Trades, rolling metrics and the other series
+// aren't stored, so they are empty.
+func RowResult(row data.ResultRow) Result {
+	r := Result{
+		PortfolioName:  row.Portfolio,
+		Strategy:       row.Strategy,
+		Seed:           row.Seed,
+		Start:          formatDate(row.StartDate),
+		End:            formatDate(row.EndDate),
+		EffectiveStart: formatDate(row.EffectiveStart),
+		EffectiveEnd:   formatDate(row.EffectiveEnd),
+		Metrics: Metrics{
+			SharpeRatio:       row.SharpeRatio,
+			SortinoRatio:      row.SortinoRatio,
+			MaxDrawdown:       row.MaxDrawdown,
+			AnnualReturn:      row.AnnualReturn,
+			StandardDev:       row.StandardDev,
+			AvgCorrelation:    row.AvgCorrelation,
+			CointegratedPairs: row.CointegratedPairs,
+			Observations:      row.Observations,
+			RiskFreeFilled:    row.RiskFreeFilled,
+			BenchmarkReturn:   row.BenchmarkReturn,
+			CalmarRatio:       row.CalmarRatio,
+			ClosedTrades:      row.ClosedTrades,
+			WinRate:           row.WinRate,
+			AvgWin:            row.AvgWin,
+			AvgLoss:           row.AvgLoss,
+			ProfitFactor:      row.ProfitFactor,
+			Expectancy:        row.Expectancy,
+			AvgHoldingDays:    row.AvgHoldingDays,
+			MaxDrawdownDays:   row.MaxDrawdownDays,
+			RecoveryDays:      row.RecoveryDays,
+		},
+	}
+	if row.Tickers != "" {
+		r.Tickers = strings.Split(row.Tickers, ",")
+	}
+	if row.Params != "" {
+		json.Unmarshal([]byte(row.Params), &r.Params)
+	}
+	if row.VaRConfidence > 0 {
+		r.Metrics.VaR = []VaR{{Confidence: row.VaRConfidence, Historical: row.VaR, Parametric: row.ParametricVaR, CVaR: row.CVaR}}
+	}
+	for _, e := range row.Equity {
+		r.Dates = append(r.Dates, formatDate(e.Date))
+		r.EquityCurve = append(r.EquityCurve, e.Value)
+		r.Returns = append(r.Returns, e.Return)
+	}
+	return r
+}
+
+// hashParams returns params as JSON, whose keys encoding/json sorts, and
+// the first 16 hex digits of its SHA-256, so runs with equal parameters
+// share a hash. No parameters hash to "".
+func hashParams(params map[string]any) (string, string, error) {
+	if len(params) == 0 {
+		return "", "", nil
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return "", "", fmt.Errorf("params: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return string(b), hex.EncodeToString(sum[:8]), nil
+}
+
+// parseDay parses a Result date, YYYY-MM-DD or an intraday bar's
+// time (see formatDate), mapping "" (and anything malformed) to the
+// zero time.
+func parseDay(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t, _ = time.Parse(dateTimeLayout, s)
+	}
+	return t
+}
@@ -0,0 +1,57 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+type resultsStore struct {
+	*fakeStore
+	rows []data.ResultRow
+}
+
+func (s *resultsStore) InsertResults(_ context.Context, rows []data.ResultRow) error {
+	s.rows = append(s.rows, rows...)
+	return nil
+}
+
+func TestRun_SavesResults(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &resultsStore{fakeStore: &fakeStore{
+		bars: map[string][]data.AssetData{"A": {
+			{Date: day(0), Open: 10, High: 10, Low: 10, Close: 10},
+			{Date: day(1), Open: 11, High: 11, Low: 11, Close: 11},
+		}},
+		rates: map[int64]float64{},
+	}}
+	var portfolios []*Portfolio
+	for _, fast := range []int{2, 2, 3} {
+		p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy",
+			WithWindow(day(0), day(1)), WithParams(map[string]any{"fast": fast}), WithSeed(9))
+		if err != nil {
+			t.Fatal(err)
+		}
+		portfolios = append(portfolios, p)
+	}
+	if _, err := Run(context.Background(), store, portfolios, &OutputConfig{Database: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.rows) != 3 {
+		t.Fatalf("rows = %+v", store.rows)
+	}
+	r := store.rows[0]
+	if r.Tickers != "A" || r.Seed != 9 || r.Params != `{"fast":2}` || !r.StartDate.Equal(day(0)) ||
+		!r.EffectiveEnd.Equal(day(1)) || r.RunAt.IsZero() || r.RunAt != store.rows[2].RunAt {
+		t.Errorf("row = %+v", r)
+	}
+	if r.ParamsHash == "" || r.ParamsHash != store.rows[1].ParamsHash || r.ParamsHash == store.rows[2].ParamsHash {
+		t.Errorf("hashes = %q %q %q, want equal params to share one",
+			r.ParamsHash, store.rows[1].ParamsHash, store.rows[2].ParamsHash)
+	}
+
+	if err := SaveResults(context.Background(), store.fakeStore, nil); err == nil {
+		t.Error("expected error for a store without a results table")
+	}
+}
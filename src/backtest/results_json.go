@@ -0,0 +1,504 @@
+package backtest
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ResultsSchemaVersion is bumped whenever ResultsDocument changes
+// incompatibly, so scripted consumers (e.g. the Python helper) can check
+// what they are reading. ReadResultsJSON migrates older documents.
+const ResultsSchemaVersion = 1
+
+// ResultsDocument is the stable JSON shape written by `-json`: one object
+// holding every Result in run order. Keys are snake_case and never depend
+// on the [Output] fields list.
+type ResultsDocument struct {
+	SchemaVersion int          `json:"schema_version"`
+	Results       []ResultJSON `json:"results"`
+}
+
+// ResultJSON is one Result: a ResultsDocument entry, or one line of
+// WriteResultsNDJSON's output.
+type ResultJSON struct {
+	Portfolio string         `json:"portfolio"`
+	Strategy  string         `json:"strategy"`
+	Params    map[string]any `json:"params,omitempty"`
+	Seed      int64          `json:"seed,omitempty"`
+	FillModel string         `json:"fill_model"`
+	// Start and End are the configured window (YYYY-MM-DD), omitted
+	// when unbounded.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// EffectiveStart and EffectiveEnd are the first and last bars
+	// simulated (YYYY-MM-DD); "" if the portfolio had no data.
+	EffectiveStart string      `json:"effective_start"`
+	EffectiveEnd   string      `json:"effective_end"`
+	Metrics        MetricsJSON `json:"metrics"`
+	Dates          []string    `json:"dates"`        // YYYY-MM-DD
+	EquityCurve    []float64   `json:"equity_curve"` // 1:1 with dates
+	Trades         []TradeJSON `json:"trades"`
+	// Rolling holds one set of rolling series per window; entry i of
+	// each covers the window ending at dates[i+window-1].
+	Rolling []RollingJSON `json:"rolling"`
+	// MonteCarlo holds the percentile bands of a Monte Carlo analysis.
+	MonteCarlo *MonteCarloJSON `json:"monte_carlo,omitempty"`
+	// WalkForward lists a walk-forward run's steps.
+	WalkForward []WalkForwardJSON `json:"walk_forward,omitempty"`
+	// Optimize lists a genetic search's generations.
+	Optimize []OptimizeJSON `json:"optimize,omitempty"`
+	// Folds lists a cross-validation run's folds.
+	Folds []CrossValidationJSON `json:"cross_validation,omitempty"`
+	// Pairs lists a pairs-trading run's pairs.
+	Pairs []PairJSON `json:"pairs,omitempty"`
+	// Sleeves attributes a portfolio with sleeves' return among them.
+	Sleeves []SleeveJSON `json:"sleeves,omitempty"`
+	// Attribution decomposes the return by ticker, strategy and book.
+	Attribution *AttributionJSON `json:"attribution,omitempty"`
+	// Sectors holds the sector exposures over the run.
+	Sectors []SectorExposureJSON `json:"sectors,omitempty"`
+	// Lookahead is set when the lookahead audit caught a violation.
+	Lookahead string `json:"lookahead,omitempty"`
+	// Halted is the date the drawdown kill switch tripped.
+	Halted string `json:"halted,omitempty"`
+	// ExecutionFailures counts the trades the paper broker failed.
+	ExecutionFailures int `json:"execution_failures,omitempty"`
+}
+
+type WalkForwardJSON struct {
+	InStart  string         `json:"in_start"`
+	InEnd    string         `json:"in_end"`
+	OutStart string         `json:"out_start"`
+	OutEnd   string         `json:"out_end"`
+	Strategy string         `json:"strategy"`
+	Params   map[string]any `json:"params,omitempty"`
+	Score    float64        `json:"score"` // in-sample objective
+	Metrics  MetricsJSON    `json:"metrics"`
+}
+
+type CrossValidationJSON struct {
+	Start         string         `json:"start"`
+	End           string         `json:"end"`
+	Strategy      string         `json:"strategy"`
+	Params        map[string]any `json:"params,omitempty"`
+	InSampleScore float64        `json:"in_sample_score"` // mean over the other blocks
+	Score         float64        `json:"score"`           // on the held-out block
+	Metrics       MetricsJSON    `json:"metrics"`
+}
+
+type OptimizeJSON struct {
+	Generation int            `json:"generation"`
+	Best       float64        `json:"best"` // score of the best configuration so far
+	Mean       float64        `json:"mean"`
+	Strategy   string         `json:"strategy"`
+	Params     map[string]any `json:"params,omitempty"`
+	Evaluated  int            `json:"evaluated"` // configurations first run this generation
+}
+
+type PairJSON struct {
+	Pair         string  `json:"pair"` // "a/b"
+	RoundTrips   int     `json:"round_trips"`
+	Wins         int     `json:"wins"`
+	PnL          float64 `json:"pnl"`
+	Correlation  float64 `json:"correlation"`
+	HedgeRatio   float64 `json:"hedge_ratio"`
+	Cointegrated bool    `json:"cointegrated"`
+}
+
+type SleeveJSON struct {
+	Strategy     string         `json:"strategy"`
+	Params       map[string]any `json:"params,omitempty"`
+	Weight       float64        `json:"weight"`
+	Contribution float64        `json:"contribution"` // percentage points of the portfolio's return
+	Metrics      MetricsJSON    `json:"metrics"`
+}
+
+// AttributionJSON is an Attribution; contributions are in percentage
+// points of the initial capital.
+type AttributionJSON struct {
+	Tickers    []AttributionRowJSON `json:"tickers"`
+	Strategies []AttributionRowJSON `json:"strategies"`
+	Books      []AttributionRowJSON `json:"books"`
+	Other      AttributionRowJSON   `json:"other"`
+	Total      AttributionRowJSON   `json:"total"`
+}
+
+type AttributionRowJSON struct {
+	Name         string  `json:"name"`
+	PnL          float64 `json:"pnl"`
+	Contribution float64 `json:"contribution"`
+}
+
+type SectorExposureJSON struct {
+	Sector   string    `json:"sector"`
+	Exposure []float64 `json:"exposure"` // percent of value, 1:1 with dates
+	Average  float64   `json:"average"`
+	Max      float64   `json:"max"`
+}
+
+type MonteCarloJSON struct {
+	Method          string               `json:"method"` // "returns" or "trades"
+	Runs            int                  `json:"runs"`
+	LossProbability float64              `json:"loss_probability"`
+	Bands           []MonteCarloBandJSON `json:"bands"`
+}
+
+type MonteCarloBandJSON struct {
+	Percentile   float64 `json:"percentile"`
+	AnnualReturn float64 `json:"annual_return"`
+	MaxDrawdown  float64 `json:"max_drawdown"`
+	SharpeRatio  float64 `json:"sharpe_ratio"`
+}
+
+type RollingJSON struct {
+	Window     int       `json:"window"`
+	Sharpe     []float64 `json:"sharpe"`
+	Volatility []float64 `json:"volatility"`
+	Drawdown   []float64 `json:"drawdown"`
+}
+
+type MetricsJSON struct {
+	SharpeRatio       float64   `json:"sharpe_ratio"`
+	SortinoRatio      float64   `json:"sortino_ratio"`
+	MaxDrawdown       float64   `json:"max_drawdown"`
+	AnnualReturn      float64   `json:"annual_return"`
+	StandardDev       float64   `json:"standard_dev"`
+	AvgCorrelation    float64   `json:"avg_correlation"`
+	CointegratedPairs int       `json:"cointegrated_pairs"`
+	Observations      int       `json:"observations"`
+	RiskFreeFilled    int       `json:"risk_free_filled"`
+	BenchmarkReturn   float64   `json:"benchmark_return"`
+	CalmarRatio       float64   `json:"calmar_ratio"`
+	IRR               float64   `json:"irr"`
+	NetContributions  float64   `json:"net_contributions"`
+	AvgGrossExposure  float64   `json:"avg_gross_exposure"`
+	AvgNetExposure    float64   `json:"avg_net_exposure"`
+	MaxLeverage       float64   `json:"max_leverage"`
+	MarginInterest    float64   `json:"margin_interest"`
+	MaxSectorExposure float64   `json:"max_sector_exposure"`
+	TaxesPaid         float64   `json:"taxes_paid"`
+	DeferredTax       float64   `json:"deferred_tax"`
+	AfterTaxReturn    float64   `json:"after_tax_return"`
+	UnsettledRejects  int       `json:"unsettled_rejects"`
+	TStat             float64   `json:"t_stat"`
+	PValue            float64   `json:"p_value"`
+	Trials            int       `json:"trials"`
+	DeflatedSharpe    float64   `json:"deflated_sharpe"`
+	Confidence        float64   `json:"confidence,omitempty"`
+	SharpeLow         float64   `json:"sharpe_low,omitempty"`
+	SharpeHigh        float64   `json:"sharpe_high,omitempty"`
+	AnnualReturnLow   float64   `json:"annual_return_low,omitempty"`
+	AnnualReturnHigh  float64   `json:"annual_return_high,omitempty"`
+	RandomEntryRuns   int       `json:"random_entry_runs,omitempty"`
+	RandomEntrySharpe float64   `json:"random_entry_sharpe,omitempty"`
+	RandomEntryRank   float64   `json:"random_entry_rank,omitempty"`
+	Folds             int       `json:"folds,omitempty"`
+	FoldScore         float64   `json:"fold_score,omitempty"`
+	FoldScoreStdDev   float64   `json:"fold_score_stddev,omitempty"`
+	FoldInSampleScore float64   `json:"fold_in_sample_score,omitempty"`
+	PBO               float64   `json:"pbo,omitempty"`
+	PBOConfigs        int       `json:"pbo_configs,omitempty"`
+	ClosedTrades      int       `json:"closed_trades"`
+	WinRate           float64   `json:"win_rate"`
+	AvgWin            float64   `json:"avg_win"`
+	AvgLoss           float64   `json:"avg_loss"`
+	ProfitFactor      float64   `json:"profit_factor"`
+	Expectancy        float64   `json:"expectancy"`
+	AvgHoldingDays    float64   `json:"avg_holding_days"`
+	VaR               []VaRJSON `json:"var"`
+	MaxDrawdownDays   int       `json:"max_drawdown_days"`
+	RecoveryDays      int       `json:"recovery_days"`
+	// Drawdowns are the deepest drawdowns, deepest first.
+	Drawdowns []DrawdownJSON `json:"drawdowns"`
+}
+
+type DrawdownJSON struct {
+	Peak         string  `json:"peak"` // YYYY-MM-DD
+	Trough       string  `json:"trough"`
+	Recovery     string  `json:"recovery"` // "" while unrecovered
+	Depth        float64 `json:"depth"`    // percent
+	Days         int     `json:"days"`     // peak to trough
+	RecoveryDays int     `json:"recovery_days"`
+}
+
+type VaRJSON struct {
+	Confidence float64 `json:"confidence"`
+	Historical float64 `json:"historical"`
+	Parametric float64 `json:"parametric"`
+	CVaR       float64 `json:"cvar"`
+}
+
+type TradeJSON struct {
+	Date   string  `json:"date"`
+	Ticker string  `json:"ticker"`
+	Side   string  `json:"side"` // "BUY" or "SELL"
+	Amount float64 `json:"amount"`
+	Price  float64 `json:"price"`
+	Fee    float64 `json:"fee"`
+	Note   string  `json:"note,omitempty"` // e.g. "delisted"
+}
+
+// NewResultsDocument converts Results into their wire form.
+func NewResultsDocument(results []Result) ResultsDocument {
+	doc := ResultsDocument{
+		SchemaVersion: ResultsSchemaVersion,
+		Results:       make([]ResultJSON, 0, len(results)),
+	}
+	for _, r := range results {
+		doc.Results = append(doc.Results, NewResultJSON(r))
+	}
+	return doc
+}
+
+// NewResultJSON converts one Result into its wire form.
+func NewResultJSON(r Result) ResultJSON {
+	trades := make([]TradeJSON, 0, len(r.Trades))
+	for _, t := range r.Trades {
+		trades = append(trades, TradeJSON{
+			Date:   formatDate(t.Date),
+			Ticker: t.Ticker,
+			Side:   t.Side,
+			Amount: t.Amount,
+			Price:  t.Price,
+			Fee:    t.Fee,
+			Note:   t.Note,
+		})
+	}
+	return ResultJSON{
+		Portfolio:      r.PortfolioName,
+		Strategy:       r.Strategy,
+		Params:         r.Params,
+		Seed:           r.Seed,
+		FillModel:      string(r.FillModel),
+		Start:          r.Start,
+		End:            r.End,
+		EffectiveStart: r.EffectiveStart,
+		EffectiveEnd:   r.EffectiveEnd,
+		Lookahead:      r.Lookahead,
+		Halted:         r.Halted,
+		Metrics:        metricsJSON(r.Metrics),
+		Dates:          nonNil(r.Dates),
+		EquityCurve:    nonNil(r.EquityCurve),
+		Trades:         trades,
+		Rolling:        rollingJSON(r.Rolling),
+		MonteCarlo:     monteCarloJSON(r.MonteCarlo),
+		WalkForward:    walkForwardJSON(r.Windows),
+		Optimize:       optimizeJSON(r.Generations),
+		Folds:          crossValidationJSON(r.Folds),
+		Pairs:          pairsJSON(r.Pairs),
+		Sleeves:        sleevesJSON(r.Sleeves),
+		Attribution:    attributionJSON(r.Attribution),
+		Sectors:        sectorsJSON(r.Sectors),
+
+		ExecutionFailures: r.ExecutionFailures,
+	}
+}
+
+// metricsJSON converts Metrics into their wire form.
+func metricsJSON(m Metrics) MetricsJSON {
+	return MetricsJSON{
+		SharpeRatio:       m.SharpeRatio,
+		SortinoRatio:      m.SortinoRatio,
+		MaxDrawdown:       m.MaxDrawdown,
+		AnnualReturn:      m.AnnualReturn,
+		StandardDev:       m.StandardDev,
+		AvgCorrelation:    m.AvgCorrelation,
+		CointegratedPairs: m.CointegratedPairs,
+		Observations:      m.Observations,
+		RiskFreeFilled:    m.RiskFreeFilled,
+		BenchmarkReturn:   m.BenchmarkReturn,
+		CalmarRatio:       m.CalmarRatio,
+		IRR:               m.IRR,
+		NetContributions:  m.NetContributions,
+		AvgGrossExposure:  m.AvgGrossExposure,
+		AvgNetExposure:    m.AvgNetExposure,
+		MaxLeverage:       m.MaxLeverage,
+		MarginInterest:    m.MarginInterest,
+		MaxSectorExposure: m.MaxSectorExposure,
+		TaxesPaid:         m.TaxesPaid,
+		DeferredTax:       m.DeferredTax,
+		AfterTaxReturn:    m.AfterTaxReturn,
+		UnsettledRejects:  m.UnsettledRejects,
+		TStat:             m.TStat,
+		PValue:            m.PValue,
+		Trials:            m.Trials,
+		DeflatedSharpe:    m.DeflatedSharpe,
+		Confidence:        m.Confidence,
+		SharpeLow:         m.SharpeLow,
+		SharpeHigh:        m.SharpeHigh,
+		AnnualReturnLow:   m.AnnualReturnLow,
+		AnnualReturnHigh:  m.AnnualReturnHigh,
+		RandomEntryRuns:   m.RandomEntryRuns,
+		RandomEntrySharpe: m.RandomEntrySharpe,
+		RandomEntryRank:   m.RandomEntryRank,
+		Folds:             m.Folds,
+		FoldScore:         m.FoldScore,
+		FoldScoreStdDev:   m.FoldScoreStdDev,
+		FoldInSampleScore: m.FoldInSampleScore,
+		PBO:               m.PBO,
+		PBOConfigs:        m.PBOConfigs,
+		ClosedTrades:      m.ClosedTrades,
+		WinRate:           m.WinRate,
+		AvgWin:            m.AvgWin,
+		AvgLoss:           m.AvgLoss,
+		ProfitFactor:      m.ProfitFactor,
+		Expectancy:        m.Expectancy,
+		AvgHoldingDays:    m.AvgHoldingDays,
+		VaR:               varJSON(m.VaR),
+		MaxDrawdownDays:   m.MaxDrawdownDays,
+		RecoveryDays:      m.RecoveryDays,
+		Drawdowns:         drawdownsJSON(m.Drawdowns),
+	}
+}
+
+func pairsJSON(ps []PairStats) []PairJSON {
+	var out []PairJSON
+	for _, p := range ps {
+		out = append(out, PairJSON(p))
+	}
+	return out
+}
+
+func sleevesJSON(ss []SleeveStats) []SleeveJSON {
+	var out []SleeveJSON
+	for _, s := range ss {
+		out = append(out, SleeveJSON{
+			Strategy: s.Strategy, Params: s.Params, Weight: s.Weight,
+			Contribution: s.Contribution, Metrics: metricsJSON(s.Metrics),
+		})
+	}
+	return out
+}
+
+func sectorsJSON(es []SectorExposure) []SectorExposureJSON {
+	var out []SectorExposureJSON
+	for _, e := range es {
+		out = append(out, SectorExposureJSON(e))
+	}
+	return out
+}
+
+func attributionJSON(a *Attribution) *AttributionJSON {
+	if a == nil {
+		return nil
+	}
+	rows := func(rs []AttributionRow) []AttributionRowJSON {
+		out := make([]AttributionRowJSON, len(rs))
+		for i, r := range rs {
+			out[i] = AttributionRowJSON(r)
+		}
+		return out
+	}
+	return &AttributionJSON{
+		Tickers: rows(a.Tickers), Strategies: rows(a.Strategies), Books: rows(a.Books),
+		Other: AttributionRowJSON(a.Other), Total: AttributionRowJSON(a.Total),
+	}
+}
+
+func monteCarloJSON(mc *MonteCarlo) *MonteCarloJSON {
+	if mc == nil {
+		return nil
+	}
+	out := &MonteCarloJSON{Method: mc.Method, Runs: mc.Runs, LossProbability: mc.LossProbability}
+	for _, b := range mc.Bands {
+		out.Bands = append(out.Bands, MonteCarloBandJSON(b))
+	}
+	return out
+}
+
+func rollingJSON(rs []Rolling) []RollingJSON {
+	out := make([]RollingJSON, 0, len(rs))
+	for _, r := range rs {
+		out = append(out, RollingJSON{
+			Window:     r.Window,
+			Sharpe:     nonNil(r.Sharpe),
+			Volatility: nonNil(r.Volatility),
+			Drawdown:   nonNil(r.Drawdown),
+		})
+	}
+	return out
+}
+
+func walkForwardJSON(ws []WalkForwardWindow) []WalkForwardJSON {
+	var out []WalkForwardJSON
+	for _, w := range ws {
+		out = append(out, WalkForwardJSON{
+			InStart: w.InStart, InEnd: w.InEnd,
+			OutStart: w.OutStart, OutEnd: w.OutEnd,
+			Strategy: w.Strategy, Params: w.Params,
+			Score: w.Score, Metrics: metricsJSON(w.Metrics),
+		})
+	}
+	return out
+}
+
+func crossValidationJSON(fs []CrossValidationFold) []CrossValidationJSON {
+	var out []CrossValidationJSON
+	for _, f := range fs {
+		out = append(out, CrossValidationJSON{
+			Start: f.Start, End: f.End,
+			Strategy: f.Strategy, Params: f.Params,
+			InSampleScore: f.InSampleScore, Score: f.Score, Metrics: metricsJSON(f.Metrics),
+		})
+	}
+	return out
+}
+
+func optimizeJSON(gs []OptimizeGeneration) []OptimizeJSON {
+	var out []OptimizeJSON
+	for _, g := range gs {
+		out = append(out, OptimizeJSON(g))
+	}
+	return out
+}
+
+// WriteResultsJSON writes results as an indented ResultsDocument.
+func WriteResultsJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewResultsDocument(results))
+}
+
+// WriteResultsNDJSON writes results as newline-delimited JSON: one
+// compact ResultJSON per line, in the current ResultsSchemaVersion, so
+// consumers can stream a large run line by line.
+func WriteResultsNDJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		if err := enc.Encode(NewResultJSON(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func varJSON(levels []VaR) []VaRJSON {
+	out := make([]VaRJSON, 0, len(levels))
+	for _, v := range levels {
+		out = append(out, VaRJSON(v))
+	}
+	return out
+}
+
+func drawdownsJSON(dd []Drawdown) []DrawdownJSON {
+	out := make([]DrawdownJSON, 0, len(dd))
+	for _, d := range dd {
+		out = append(out, DrawdownJSON{
+			Peak:         formatDate(d.Peak),
+			Trough:       formatDate(d.Trough),
+			Recovery:     formatDate(d.Recovery),
+			Depth:        d.Depth,
+			Days:         d.Days(),
+			RecoveryDays: d.RecoveryDays(),
+		})
+	}
+	return out
+}
+
+// nonNil keeps empty series as [] rather than null in the JSON.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
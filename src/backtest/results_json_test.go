@@ -0,0 +1,161 @@
+package backtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseConfig_JSONAndYAMLMatchTOML(t *testing.T) {
+	tomlText := `
+[[portfolio]]
+Name = "A"
+BuyingPower = 1000.0
+StartDate = "2020-01-01"
+EndDate = "2021-01-01"
+Tickers = ["AAPL", "MSFT"]
+Strategy = "lua:x.lua"
+Params = { fast = 10, band = 0.5 }
+
+[Output]
+path = "out.csv"
+`
+	jsonText := `{
+  "portfolio": [{
+    "Name": "A", "BuyingPower": 1000, "StartDate": "2020-01-01",
+    "EndDate": "2021-01-01", "Tickers": ["AAPL", "MSFT"],
+    "Strategy": "lua:x.lua", "Params": {"fast": 10, "band": 0.5}
+  }],
+  "Output": {"path": "out.csv"}
+}`
+	want, err := ParseConfig(tomlText, "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseConfig(jsonText, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := json.Marshal(want)
+	b, _ := json.Marshal(got)
+	if !bytes.Equal(a, b) {
+		t.Errorf("json config = %s\nwant %s", b, a)
+	}
+	if _, ok := got.Portfolios[0].Params["fast"].(int64); !ok {
+		t.Errorf("fast = %T, want int64", got.Portfolios[0].Params["fast"])
+	}
+
+	// Unquoted YAML dates stay strings.
+	yamlText := `
+portfolio:
+  - Name: A
+    BuyingPower: 1000
+    StartDate: 2020-01-01
+    EndDate: "2021-01-01"
+    Tickers: [AAPL, MSFT]
+    Strategy: lua:x.lua
+    Params: {fast: 10, band: 0.5}
+Output:
+  path: out.csv
+`
+	path := filepath.Join(t.TempDir(), "backtest.yaml")
+	if err := os.WriteFile(path, []byte(yamlText), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := json.Marshal(got); !bytes.Equal(a, c) {
+		t.Errorf("yaml config = %s\nwant %s", c, a)
+	}
+}
+
+func TestWriteResultsJSON_Schema(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteResultsJSON(&buf, []Result{{
+		PortfolioName: "A",
+		Strategy:      "buyAndHold",
+		Metrics:       Metrics{SharpeRatio: 1.5},
+		Trades: []Trade{{
+			Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Ticker: "AAPL",
+			Side: "BUY", Amount: 1, Price: 10,
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["schema_version"] != float64(ResultsSchemaVersion) {
+		t.Errorf("schema_version = %v", doc["schema_version"])
+	}
+	r := doc["results"].([]any)[0].(map[string]any)
+	if r["metrics"].(map[string]any)["sharpe_ratio"] != 1.5 {
+		t.Errorf("metrics = %v", r["metrics"])
+	}
+	if d, ok := r["dates"].([]any); !ok || len(d) != 0 {
+		t.Errorf("dates = %v, want []", r["dates"])
+	}
+	if tr := r["trades"].([]any)[0].(map[string]any); tr["date"] != "2024-01-02" {
+		t.Errorf("trade = %v", tr)
+	}
+}
+
+// NDJSON lines carry the run metadata, and the reporter's ndjson format
+// writes the same lines for the results that pass its filter.
+func TestWriteResultsNDJSON(t *testing.T) {
+	results := []Result{
+		{
+			PortfolioName: "A", Strategy: "smaCross:10:30", Seed: 7,
+			Params: map[string]any{"fast": int64(10)},
+			Start:  "2020-01-01", End: "2021-01-01",
+			Metrics: Metrics{SharpeRatio: 1.5},
+		},
+		{PortfolioName: "B", Strategy: "buyAndHold"},
+	}
+	var buf bytes.Buffer
+	if err := WriteResultsNDJSON(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines:\n%s", len(lines), buf.Bytes())
+	}
+	var a, b ResultJSON
+	if err := json.Unmarshal(lines[0], &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Seed != 7 || a.Start != "2020-01-01" || a.End != "2021-01-01" ||
+		a.Params["fast"] != float64(10) || a.Metrics.SharpeRatio != 1.5 {
+		t.Errorf("line 1 = %s", lines[0])
+	}
+	if err := json.Unmarshal(lines[1], &b); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(lines[1], []byte(`"seed"`)) || bytes.Contains(lines[1], []byte(`"params"`)) {
+		t.Errorf("unset metadata should be omitted: %s", lines[1])
+	}
+
+	path := filepath.Join(t.TempDir(), "results.ndjson")
+	r, err := NewReporter(&OutputConfig{Path: path, Format: "ndjson", Filter: "SharpeRatio > 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if err := r.Write(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if want := append(lines[0], '\n'); !bytes.Equal(got, want) {
+		t.Errorf("reporter wrote\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+	"sort"
+	"time"
+)
+
+// RiskConfig sets portfolio-level risk rules the engine enforces on
+// every bar, whatever the strategy does. Each is off when 0.
+//
+// StopLoss and TakeProfit close a position once the bar trades that
+// fraction against or in favour of its average entry price: a long
+// bought at 100 with StopLoss 0.05 is sold at 95, or at the open if the
+// bar gaps below it. When one bar reaches both, the stop is assumed to
+// come first. MaxDrawdown is a kill switch: once the portfolio's value at
+// a close is that fraction below its peak, every position is closed at
+// that close, open orders are cancelled and the strategy is not stepped
+// again for the rest of the run.
+//
+// MaxSectorExposure caps the gross value of the positions in any one
+// sector at that fraction of equity, and SectorLimits caps the sectors
+// it names, matched case-insensitively, in its place: a buy or sale that
+// would take its ticker's sector past its cap, marked as the margin
+// check marks positions, is refused with ErrSectorLimit, unless it
+// leaves the sector no more exposed. Sectors come from the store's
+// security metadata (see MetadataStore); tickers without one aren't
+// limited. Unlike the rules above, a sector_limits entry of 0 is on: it
+// keeps the portfolio out of that sector.
+type RiskConfig struct {
+	StopLoss          float64            `toml:"stop_loss"`
+	TakeProfit        float64            `toml:"take_profit"`
+	MaxDrawdown       float64            `toml:"max_drawdown"`
+	MaxSectorExposure float64            `toml:"max_sector_exposure"`
+	SectorLimits      map[string]float64 `toml:"sector_limits"`
+}
+
+// WithRisk enforces r's rules (see RiskConfig).
+func WithRisk(r RiskConfig) Option {
+	return func(p *Portfolio) error {
+		if !(r.StopLoss >= 0 && r.StopLoss < 1) {
+			return fmt.Errorf("risk stop_loss %v: must be a fraction in [0, 1)", r.StopLoss)
+		}
+		if !(r.TakeProfit >= 0) || math.IsInf(r.TakeProfit, 1) {
+			return fmt.Errorf("risk take_profit %v: must be a non-negative fraction", r.TakeProfit)
+		}
+		if !(r.MaxDrawdown >= 0 && r.MaxDrawdown < 1) {
+			return fmt.Errorf("risk max_drawdown %v: must be a fraction in [0, 1)", r.MaxDrawdown)
+		}
+		if !(r.MaxSectorExposure >= 0) || math.IsInf(r.MaxSectorExposure, 1) {
+			return fmt.Errorf("risk max_sector_exposure %v: must be a non-negative fraction", r.MaxSectorExposure)
+		}
+		for s, limit := range r.SectorLimits {
+			if !(limit >= 0) || math.IsInf(limit, 1) {
+				return fmt.Errorf("risk sector_limits %q %v: must be a non-negative fraction", s, limit)
+			}
+		}
+		p.Risk = &r
+		return nil
+	}
+}
+
+// stopPositions closes every position whose stop-loss or take-profit
+// level bar day trades through. The engine calls it before orders fill
+// and the strategy steps, so a position is only stopped on bars after
+// the one it was opened on.
+func (p *Portfolio) stopPositions(hist map[string][]data.AssetData, day int) {
+	r := p.Risk
+	if r == nil || (r.StopLoss == 0 && r.TakeProfit == 0) {
+		return
+	}
+	tickers := make([]string, 0, len(p.Positions))
+	for t, pos := range p.Positions {
+		if pos.Amount != 0 {
+			tickers = append(tickers, t)
+		}
+	}
+	sort.Strings(tickers)
+	for _, t := range tickers {
+		series := hist[t]
+		if day >= len(series) {
+			continue
+		}
+		pos, bar := p.Positions[t], series[day]
+		long := pos.Amount > 0
+		price, ok, why := 0.0, false, ""
+		if r.StopLoss > 0 {
+			stop := pos.AveragePrice * (1 - r.StopLoss)
+			if !long {
+				stop = pos.AveragePrice * (1 + r.StopLoss)
+			}
+			price, ok = stopFill(!long, stop, bar)
+			why = "STOP LOSS"
+		}
+		if !ok && r.TakeProfit > 0 {
+			target := pos.AveragePrice * (1 + r.TakeProfit)
+			if !long {
+				target = pos.AveragePrice * math.Max(1-r.TakeProfit, 0)
+			}
+			price, ok = limitFill(!long, target, bar)
+			why = "TAKE PROFIT"
+		}
+		if !ok {
+			continue
+		}
+		p.txLog().Debug(why, "portfolio", p.Pname, "ticker", t, "price", price, "date", formatDate(bar.Date))
+		p.flatten(t, price, bar.Date)
+	}
+}
+
+// checkDrawdown trips the MaxDrawdown kill switch if the portfolio's
+// value at bar day's close is too far below its peak. Cash flowing in or
+// out moves the peak with it, so a withdrawal is not a drawdown.
+func (p *Portfolio) checkDrawdown(hist map[string][]data.AssetData, day int) {
+	r := p.Risk
+	if r == nil || r.MaxDrawdown == 0 || !p.Halted.IsZero() {
+		return
+	}
+	value := p.GetPortfolioValue(p.Tickers, hist, day)
+	p.riskPeak = math.Max(p.riskPeak+p.flow, value)
+	if p.riskPeak <= 0 || 1-value/p.riskPeak < r.MaxDrawdown {
+		return
+	}
+	date := hist[p.Tickers[0]][day].Date
+	p.txLog().Debug("HALT", "portfolio", p.Pname, "drawdown_pct", (1-value/p.riskPeak)*100,
+		"peak", p.riskPeak, "date", formatDate(date))
+	p.pending, p.resting = nil, nil
+	for _, t := range p.Tickers {
+		if pos, ok := p.Positions[t]; ok && pos.Amount != 0 && day < len(hist[t]) {
+			p.flatten(t, hist[t][day].Close, date)
+		}
+	}
+	p.Halted = date
+}
+
+// flatten closes ticker's position at price.
+func (p *Portfolio) flatten(ticker string, price float64, date time.Time) {
+	pos := p.Positions[ticker]
+	var err error
+	if pos.Amount > 0 {
+		err = p.Sell(ticker, pos.Amount, price, date)
+	} else {
+		err = p.Buy(ticker, -pos.Amount, price, date)
+	}
+	if err != nil {
+		logger.Warn("closing position failed", "portfolio", p.Pname, "ticker", ticker, "date", formatDate(date), "err", err)
+	}
+}
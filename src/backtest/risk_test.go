@@ -0,0 +1,100 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func riskRun(t *testing.T, bars []data.AssetData, spec string, opts ...Option) Result {
+	t.Helper()
+	store := &fakeStore{bars: map[string][]data.AssetData{"A": bars}, rates: map[int64]float64{}}
+	opts = append(opts, WithWindow(bars[0].Date, bars[len(bars)-1].Date))
+	p, err := NewPortfolio("risk", 1000, []string{"A"}, spec, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results[0]
+}
+
+func TestRisk_StopsAndTargets(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	flat := data.AssetData{Date: day(0), Open: 10, High: 10, Low: 10, Close: 10, Volume: 100}
+	bar := func(i int, o, h, l, c float64) data.AssetData {
+		return data.AssetData{Date: day(i), Open: o, High: h, Low: l, Close: c, Volume: 100}
+	}
+	for _, c := range []struct {
+		name string
+		bar  data.AssetData
+		risk RiskConfig
+		exit float64 // 0 when the position should be held
+	}{
+		{"stop", bar(2, 9.8, 9.9, 9.4, 9.6), RiskConfig{StopLoss: 0.05}, 9.5},
+		{"stop gapped through", bar(2, 9, 9.2, 8.5, 9), RiskConfig{StopLoss: 0.05}, 9},
+		{"target", bar(2, 10.5, 11.5, 10.4, 11), RiskConfig{TakeProfit: 0.1}, 11},
+		{"target gapped through", bar(2, 12, 12.5, 11.5, 12), RiskConfig{TakeProfit: 0.1}, 12},
+		{"both: stop first", bar(2, 10, 11.5, 9.4, 10), RiskConfig{StopLoss: 0.05, TakeProfit: 0.1}, 9.5},
+		{"inside both", bar(2, 10, 10.9, 9.6, 10), RiskConfig{StopLoss: 0.05, TakeProfit: 0.1}, 0},
+	} {
+		day1 := flat
+		day1.Date = day(1)
+		r := riskRun(t, []data.AssetData{flat, day1, c.bar}, "greedy", WithRisk(c.risk))
+		if r.Trades[0].Side != "BUY" || r.Trades[0].Amount != 100 {
+			t.Fatalf("%s: trades = %+v", c.name, r.Trades)
+		}
+		if c.exit == 0 {
+			if len(r.Trades) != 1 {
+				t.Errorf("%s: trades = %+v, want the position held", c.name, r.Trades)
+			}
+			continue
+		}
+		want := Trade{Date: day(2), Ticker: "A", Side: "SELL", Amount: 100, Price: c.exit}
+		if len(r.Trades) != 2 || r.Trades[1] != want {
+			t.Errorf("%s: trades = %+v, want a %+v", c.name, r.Trades, want)
+		}
+	}
+}
+
+// A 30% fall trips a 20% kill switch: the position is sold at that
+// close, and dca leaves the next month's deposit in cash.
+func TestRisk_KillSwitch(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	var bars []data.AssetData
+	for i := 0; i < 60; i++ {
+		price := 10.0
+		if i >= 10 {
+			price = 7
+		}
+		bars = append(bars, data.AssetData{Date: day(i), Open: price, High: price, Low: price, Close: price, Volume: 100})
+	}
+	r := riskRun(t, bars, "dca:greedy",
+		WithRisk(RiskConfig{MaxDrawdown: 0.2}), WithContributions(ContributionSchedule{Amount: 500}))
+	want := []Trade{
+		{Date: day(0), Ticker: "A", Side: "BUY", Amount: 100, Price: 10},
+		{Date: day(10), Ticker: "A", Side: "SELL", Amount: 100, Price: 7},
+	}
+	if len(r.Trades) != len(want) || r.Trades[0] != want[0] || r.Trades[1] != want[1] {
+		t.Fatalf("trades = %+v, want %+v", r.Trades, want)
+	}
+	if r.Halted != "2024-01-11" {
+		t.Errorf("Halted = %q, want 2024-01-11", r.Halted)
+	}
+	if final := r.EquityCurve[len(r.EquityCurve)-1]; final != 1200 {
+		t.Errorf("final value = %v, want 700 from the sale plus the 500 deposit", final)
+	}
+
+	for _, bad := range []RiskConfig{
+		{StopLoss: 1}, {TakeProfit: -0.1}, {MaxDrawdown: -0.5},
+		{MaxSectorExposure: -0.1}, {SectorLimits: map[string]float64{"Energy": math.Inf(1)}},
+	} {
+		if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithRisk(bad)); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/metrics"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// DefaultRollingWindows are the windows, in bars, rolling metrics are
+// computed over when a portfolio sets none: about a quarter and a year
+// of equities trading days.
+var DefaultRollingWindows = []int{63, 252}
+
+// Rolling is a run's metrics over a window sliding one bar at a time,
+// to show when a strategy's edge comes and goes. Entry i covers the
+// Window bars ending at the run's date i+Window-1, so each series is
+// Window-1 shorter than the run, and empty if the run is shorter than
+// Window.
+type Rolling struct {
+	Window     int
+	Sharpe     []float64 // annualized, of excess returns; 0 without risk-free rates
+	Volatility []float64 // annualized standard deviation of returns
+	Drawdown   []float64 // max drawdown inside the window, in percent
+}
+
+// WithRollingWindows sets the windows, in bars, that rolling metrics are
+// computed over. Each must be at least 2.
+func WithRollingWindows(windows ...int) Option {
+	return func(p *Portfolio) error {
+		for _, w := range windows {
+			if w < 2 {
+				return fmt.Errorf("rolling window %d: must be at least 2 bars", w)
+			}
+		}
+		p.RollingWindows = windows
+		return nil
+	}
+}
+
+// rolling computes the Rolling series for window from the daily returns,
+// their excess over the risk-free rate (nil when there are no rates) and
+// the close values, all 1:1.
+func rolling(window int, returns, excess, values []float64, periodsPerYear float64) Rolling {
+	r := Rolling{Window: window}
+	for end := window; end <= len(returns); end++ {
+		start := end - window
+		sharpe := 0.0
+		if excess != nil {
+			sharpe = metrics.Sharpe(excess[start:end], periodsPerYear)
+		}
+		r.Sharpe = append(r.Sharpe, sharpe)
+		r.Volatility = append(r.Volatility,
+			stat.StdDev(returns[start:end], nil)*math.Sqrt(periodsPerYear))
+		r.Drawdown = append(r.Drawdown, GetMaxDrawdown(values[start:end]))
+	}
+	return r
+}
@@ -0,0 +1,58 @@
+package backtest
+
+import (
+	"bytes"
+	"math"
+	"my-backtester/src/data"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRolling(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	values := []float64{100, 110, 99, 108.9, 119.79}
+	returns := []float64{0, 0.1, -0.1, 0.1, 0.1}
+	p, err := NewPortfolio("p", 100, []string{"A"}, "greedy", WithRollingWindows(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.PortfolioCloseValues = values
+	rf := make(map[int64]float64)
+	for i, r := range returns {
+		p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: day(i), Return: r})
+		rf[day(i).Unix()] = 0
+	}
+	p.GetBacktestingData(rf, map[string][]data.AssetData{}, 0)
+
+	if len(p.Rolling) != 1 || len(p.Rolling[0].Sharpe) != 3 {
+		t.Fatalf("rolling = %+v, want one window of 3 entries", p.Rolling)
+	}
+	ro := p.Rolling[0]
+	// The second window is 0.1, -0.1, 0.1 over 110, 99, 108.9.
+	mean, sd := 0.1/3, math.Sqrt((2*math.Pow(0.1-0.1/3, 2)+math.Pow(-0.1-0.1/3, 2))/2)
+	if want := mean / sd * math.Sqrt(252); !closeTo(ro.Sharpe[1], want) {
+		t.Errorf("sharpe[1] = %v, want %v", ro.Sharpe[1], want)
+	}
+	if want := sd * math.Sqrt(252); !closeTo(ro.Volatility[1], want) {
+		t.Errorf("volatility[1] = %v, want %v", ro.Volatility[1], want)
+	}
+	if !closeTo(ro.Drawdown[1], 10) || ro.Drawdown[2] != 0 {
+		t.Errorf("drawdown = %v, want [_, 10, 0]", ro.Drawdown)
+	}
+
+	r := newResult(p)
+	var buf bytes.Buffer
+	if err := WriteRollingCSV(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "date,sharpe_3,volatility_3,drawdown_3" || lines[2] != "2024-01-02,,," ||
+		!strings.HasPrefix(lines[3], "2024-01-03,") || len(lines) != 6 {
+		t.Errorf("csv =\n%s", buf.String())
+	}
+
+	if _, err := NewPortfolio("p", 100, []string{"A"}, "greedy", WithRollingWindows(1)); err == nil {
+		t.Error("expected error for a one-bar window")
+	}
+}
@@ -1,28 +1,174 @@
 package backtest
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"my-backtester/src/data"
+	"my-backtester/src/metrics"
+	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/BurntSushi/toml"
 )
 
+// Store is the market data a run reads. *data.Store implements it over
+// DuckDB; tests substitute in-memory fakes. Queries honour ctx and
+// return what they read before it was done.
+type Store interface {
+	QueryAssetsForTickers(ctx context.Context, tickers []string, start, end time.Time) map[string][]data.AssetData
+	GetRiskFreeRates(ctx context.Context, start, end time.Time) map[int64]float64
+	QueryMacro(ctx context.Context, series string, end time.Time) []data.MacroPoint
+}
+
 // Result holds the result of a backtest.
 type Result struct {
 	PortfolioName string
 	Strategy      string
-	Metrics       Metrics
+	Tickers       []string
+	// Params are the strategy's typed parameters and Seed its random
+	// seed, as configured.
+	Params  map[string]any
+	Seed    int64
+	Metrics Metrics
+	// Start and End (YYYY-MM-DD) are the configured window; "" when
+	// unbounded.
+	Start string
+	End   string
 	// EquityCurve is the portfolio's daily total value, and Dates are the
 	// matching trading days (YYYY-MM-DD) in the same order. Both come from
 	// the per-day record kept during the simulation and are 1:1 in length,
 	// so the frontend can plot value-over-time directly.
 	EquityCurve []float64
 	Dates       []string
+	// Returns are the daily returns behind EquityCurve, 1:1 with Dates.
+	Returns []float64
+	// BenchmarkCurve is the value of buying and holding the Benchmark
+	// with EquityCurve's first value, 1:1 with Dates; nil without a
+	// benchmark.
+	BenchmarkCurve []float64
+	// Rolling holds the rolling metrics series, one per window.
+	Rolling []Rolling
+	// MonteCarlo is the resampling analysis, when the portfolio asked
+	// for one (see WithMonteCarlo).
+	MonteCarlo *MonteCarlo
+	// Windows are the steps of a walk-forward run (see WalkForward);
+	// nil otherwise.
+	Windows []WalkForwardWindow
+	// Generations are the steps of a genetic search (see Optimize); nil
+	// otherwise.
+	Generations []OptimizeGeneration
+	// Folds are the folds of a cross-validation run (see CrossValidate);
+	// nil otherwise.
+	Folds []CrossValidationFold
+	// Pairs are the per-pair results of a pairs-trading run (see
+	// PairsTrading); nil otherwise.
+	Pairs []PairStats
+	// Sleeves attributes the return of a portfolio with sleeves among
+	// them (see WithSleeves); nil otherwise.
+	Sleeves []SleeveStats
+	// Attribution decomposes the return by ticker, strategy and book.
+	Attribution *Attribution
+	// Sectors are the portfolio's sector exposures over the run, when
+	// its store has the tickers' sectors (see MetadataStore).
+	Sectors []SectorExposure
+	// Trades is the portfolio's full trade ledger in execution order,
+	// and ClosedTrades its round trips as the LotMethod matched them.
+	Trades       []Trade
+	ClosedTrades []ClosedTrade
+	// FillModel is the fill-price model the run used.
+	FillModel FillModel
+	// EffectiveStart and EffectiveEnd (YYYY-MM-DD) are the first and last
+	// bars actually simulated. They fall inside the configured window and
+	// narrow it when a ticker's data starts late or ends early; metrics
+	// are annualized over the bars in between, not the configured span.
+	EffectiveStart string
+	EffectiveEnd   string
+	// Lookahead describes the first read past the current bar caught by
+	// the lookahead audit; empty when clean or not audited.
+	Lookahead string
+	// Halted (YYYY-MM-DD) is the date the drawdown kill switch stopped
+	// trading (see RiskConfig); empty if it never tripped.
+	Halted string
+	// ExecutionFailures counts the trades the Executor failed, which
+	// were refused rather than booked (see ErrExecution).
+	ExecutionFailures int
+}
+
+// newResult snapshots a finished simulation into a Result.
+func newResult(p *Portfolio) Result {
+	// DailyReturns and PortfolioCloseValues are appended together each
+	// day, so they share length and ordering.
+	dates := make([]string, len(p.DailyReturns))
+	returns := make([]float64, len(p.DailyReturns))
+	for i, dr := range p.DailyReturns {
+		dates[i] = formatDate(dr.Date)
+		returns[i] = dr.Return
+	}
+	lookahead := ""
+	if p.Lookahead != nil {
+		lookahead = p.Lookahead.Error()
+	}
+	var pairs []PairStats
+	if pr, ok := p.Strategy.(interface{ PairStats(*Portfolio) []PairStats }); ok {
+		pairs = pr.PairStats(p)
+	}
+	return Result{
+		PortfolioName:  p.Pname,
+		Strategy:       p.Strategy.Name(),
+		Tickers:        p.Tickers,
+		Params:         p.StrategyParams,
+		Seed:           p.Seed,
+		Metrics:        p.Metrics,
+		Start:          formatDate(p.StartTime),
+		End:            formatDate(p.EndTime),
+		EquityCurve:    p.PortfolioCloseValues,
+		Dates:          dates,
+		Returns:        returns,
+		BenchmarkCurve: benchmarkCurve(p.benchBars, dates, p.PortfolioCloseValues),
+		Rolling:        p.Rolling,
+		MonteCarlo:     p.MonteCarlo,
+		Pairs:          pairs,
+		Sleeves:        p.sleeveParts,
+		Attribution:    p.attribution(),
+		Sectors:        p.sectorExposures(),
+		Trades:         p.Trades,
+		ClosedTrades:   p.ClosedTrades,
+		FillModel:      p.Fill,
+		EffectiveStart: formatDate(p.EffectiveStart),
+		EffectiveEnd:   formatDate(p.EffectiveEnd),
+		Lookahead:      lookahead,
+		Halted:         formatDate(p.Halted),
+
+		ExecutionFailures: p.ExecutionFailures,
+	}
+}
+
+// formatDate formats t as YYYY-MM-DD, or "" for the zero time.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 {
+		return t.Format(dateTimeLayout)
+	}
+	return t.Format("2006-01-02")
+}
+
+// dateTimeLayout is how formatDate writes an intraday bar's time.
+const dateTimeLayout = "2006-01-02 15:04:05"
+
+// riskFreeLookbackDays is how far before a window risk-free rates are
+// loaded, so a window opening on a day with no published rate (e.g. a
+// bond-market holiday) can forward-fill from the last one.
+const riskFreeLookbackDays = 14
+
+func riskFreeStart(start time.Time) time.Time {
+	if start.IsZero() {
+		return start // unbounded stays unbounded
+	}
+	return start.AddDate(0, 0, -riskFreeLookbackDays)
 }
 
 // dateRange returns the earliest StartTime and the latest EndTime across
@@ -42,66 +188,270 @@ func dateRange(portfolios []*Portfolio) (time.Time, time.Time) {
 	return minDate, maxDate
 }
 
-// runOne executes one full simulation pass over a single-strategy portfolio.
-// The day loop lives here; the strategy decides what to do on each day.
+// allTickers returns the sorted union of every portfolio's tickers and
+// the benchmarks read from the Store.
+func allTickers(portfolios []*Portfolio) []string {
+	seen := make(map[string]bool)
+	var tickers []string
+	add := func(t string) {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			tickers = append(tickers, t)
+		}
+	}
+	for _, p := range portfolios {
+		for _, t := range p.Tickers {
+			add(t)
+		}
+		if p.BenchmarkSource == nil {
+			add(p.Benchmark)
+		}
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+// usesStoreRiskFree reports whether any portfolio reads risk-free rates
+// from the Store.
+func usesStoreRiskFree(portfolios []*Portfolio) bool {
+	for _, p := range portfolios {
+		if p.RiskFree == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// indexedResult carries a worker's Result with its portfolio's index.
+// ok is false when the run was cancelled before the portfolio finished.
+type indexedResult struct {
+	index  int
+	result Result
+	ok     bool
+}
+
+// runOne executes one full simulation pass over a single-strategy
+// portfolio, publishing one BarEvent per day to the portfolio's Engine.
+// The pass covers only the dates inside the portfolio's window that all of
+// its tickers have data for (see alignWindow), or under GapsForwardFill
+// the dates any of them has (see alignUnion). Under AuditLookahead it
+// stops at the first lookahead and metrics cover the bars before it.
+// riskFreeRates and hist's benchmark series are used unless p has its own
+// providers. It reports false, leaving p unfinished, if ctx is done first.
 func runOne(
+	ctx context.Context,
 	p *Portfolio,
 	hist map[string][]data.AssetData,
 	riskFreeRates map[int64]float64,
-) {
+) bool {
 	if len(p.Tickers) == 0 {
-		return
+		return true
+	}
+	p.loadMetadata(ctx)
+	if len(p.Sleeves) > 0 {
+		return p.runSleeves(ctx, hist, riskFreeRates)
 	}
+	if p.Interval > 0 {
+		hist = p.loadIntraday(ctx)
+	}
+	full := hist
+	align := alignWindow
+	if p.gaps() == GapsForwardFill {
+		align = alignUnion
+	}
+	hist = align(p.Tickers, hist, p.StartTime, p.windowEnd(), p.Calendar.sessionFilter())
 	dataLen := len(hist[p.Tickers[0]])
 	if dataLen == 0 {
-		return
+		return true
+	}
+	p.EffectiveStart = hist[p.Tickers[0]][0].Date
+	p.EffectiveEnd = hist[p.Tickers[0]][dataLen-1].Date
+	p.loadActions(ctx)
+	raw := hist
+	if p.Prices == PricesAdjusted {
+		hist = adjustHist(hist, p.Tickers, p.actions)
+		raw = p.resample(raw)
+	}
+	hist = p.resample(hist)
+	if p.Prices != PricesAdjusted {
+		raw = hist
+	}
+	dataLen = len(hist[p.Tickers[0]])
+	if p.gaps() == GapsForwardFill {
+		if p.Prices == PricesAdjusted {
+			p.delist(full, raw, p.StartTime, p.windowEnd())
+		}
+		p.delist(full, hist, p.StartTime, p.windowEnd())
 	}
+	p.raw, p.adjusted = raw, nil
+	p.sim = newTimeframeViews(hist)
 
-	p.Strategy.Step(p, hist, 0)
-	prev := p.GetPortfolioValue(p.Tickers, hist, 0)
-	for day := 1; day < dataLen; day++ {
-		p.Strategy.Step(p, hist, day)
-		curr := p.GetPortfolioValue(p.Tickers, hist, day)
-		p.AdjustPortfolioParameters(p.Tickers, hist, day, prev, curr)
-		prev = curr
+	lead := hist[p.Tickers[0]]
+	step := func(hist map[string][]data.AssetData, day int) { p.Strategy.Step(p, hist, day) }
+	if p.AuditLookahead {
+		view := newAuditView(hist)
+		step = func(_ map[string][]data.AssetData, day int) { p.stepAudited(view, day) }
+	}
+
+	if p.RiskFree != nil {
+		riskFreeRates = p.RiskFree.RiskFreeRates(ctx, riskFreeStart(p.EffectiveStart), p.EffectiveEnd)
+	}
+	if p.Margin != nil {
+		days := make([]int64, dataLen)
+		for i, b := range lead {
+			days[i] = b.Date.Unix()
+		}
+		p.marginRates, _ = metrics.AlignRiskFree(p.barRates(riskFreeRates), days)
+	}
+
+	if !newEngine(p, step).Run(ctx, hist) {
+		return false
+	}
+	if p.Lookahead != nil {
+		logger.Warn("lookahead", "portfolio", p.Pname, "err", p.Lookahead)
 	}
 	p.GetBacktestingData(riskFreeRates, hist, dataLen)
+	if p.Benchmark != "" {
+		var bench []data.AssetData
+		if p.BenchmarkSource != nil {
+			// A provider's bars needn't carry their returns; fill a copy.
+			bench = append([]data.AssetData(nil), p.BenchmarkSource.BenchmarkBars(ctx, p.Benchmark, p.EffectiveStart, p.EffectiveEnd)...)
+			data.FillReturns(bench)
+		} else {
+			bench = clipSeries(full[p.Benchmark], p.EffectiveStart, p.EffectiveEnd)
+		}
+		if every := p.Timeframe.period(); every != "" {
+			bench = data.Resample(bench, every)
+		}
+		p.Metrics.BenchmarkReturn = benchmarkReturn(bench, p.periodsPerYear())
+		p.benchBars = bench
+	}
 	if c, ok := p.Strategy.(interface{ Close() }); ok {
 		c.Close()
 	}
+	return true
 }
 
-// Run executes every portfolio concurrently and always returns the
-// collected results. If output is non-nil, results are also written to a
-// file via the configured Reporter.
-func Run(portfolios []*Portfolio, output *OutputConfig) ([]Result, error) {
+// Run executes every portfolio concurrently against store and always
+// returns the collected results, in portfolio order. If output is non-nil,
+// results are also written to a file via the configured Reporter, each
+// one's equity curve and trades to output.Dir (see WriteArtifacts), and,
+// with output.Database, a row per result to the store's results table (see
+// SaveResults). Once ctx is done workers stop at the next bar; Run then
+// returns the results of the portfolios that finished, with ctx's error.
+// With a CheckedStore, failed queries are retried, portfolios needing bars
+// that still couldn't be read are skipped, and the data errors are
+// summarized in the log at the end.
+func Run(ctx context.Context, store Store, portfolios []*Portfolio, output *OutputConfig) ([]Result, error) {
 	reporter, err := NewReporter(output)
 	if err != nil {
 		return nil, fmt.Errorf("output config: %w", err)
 	}
 
+	portfolios, skipped := selectUniverses(ctx, store, portfolios)
+	if len(portfolios) == 0 {
+		return nil, ctx.Err()
+	}
 	startTime, endTime := dateRange(portfolios)
-	riskFreeRates := data.GetRiskFreeRates(startTime, endTime)
+	// Rates for portfolios without their own RiskFree provider, read
+	// once for all of them.
+	var riskFreeRates map[int64]float64
+	var dataErrs []DataError
+	if usesStoreRiskFree(portfolios) {
+		riskFreeRates, dataErrs = loadRiskFree(ctx, store, riskFreeStart(startTime), endTime)
+	}
 
-	allTickersMap := make(map[string]bool)
-	for _, p := range portfolios {
-		for _, ticker := range p.Tickers {
-			allTickersMap[ticker] = true
+	// Renamed tickers need every symbol they went by, and spin-offs the
+	// bars of the ticker spun off.
+	tickers := allTickers(portfolios)
+	symbols := loadSymbols(ctx, store, tickers)
+	load := withAliases(tickers, symbols)
+	var actions map[string][]data.CorporateAction
+	if as, ok := store.(ActionStore); ok {
+		actions = as.QueryActions(ctx, load, startTime, endTime)
+		load = withSpinOffs(load, actions)
+	}
+	historicalData, barErrs := loadBars(ctx, store, load, startTime, endTime)
+	dataErrs = append(dataErrs, barErrs...)
+	failed := make(map[string]bool, len(barErrs))
+	for _, e := range barErrs {
+		failed[e.Ticker] = true
+	}
+	for t, spans := range symbols {
+		for _, s := range spans {
+			if failed[s.Ticker] {
+				failed[t] = true
+			}
 		}
 	}
-	allTickers := make([]string, 0, len(allTickersMap))
-	for ticker := range allTickersMap {
-		allTickers = append(allTickers, ticker)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	historicalData, actions = stitchSymbols(historicalData, actions, symbols)
+	metadata := loadMetadata(ctx, store)
+	actions = valueSpinOffs(actions, historicalData)
 
-	historicalData := data.QueryAssetsForTickers(
-		allTickers, startTime, endTime,
-	)
+	// Clone up front so each job has a fixed index; results are
+	// collected and written in portfolio order whichever worker finishes
+	// first.
+	clones := make([]*Portfolio, 0, len(portfolios))
+	for _, p := range portfolios {
+		if needsFailed(p, failed) {
+			skipped = append(skipped, p.Pname)
+			continue
+		}
+		if gap := p.checkGaps(historicalData); gap != nil {
+			dataErrs = append(dataErrs, *gap)
+			skipped = append(skipped, p.Pname)
+			continue
+		}
+		clone, err := p.Clone()
+		if err != nil {
+			runLogger.Error("clone portfolio", "portfolio", p.Pname, "err", err)
+			continue
+		}
+		clone.store = store
+		clone.actions = actions
+		clone.metadata = metadata
+		clone.trials = len(portfolios)
+		clones = append(clones, clone)
+	}
+	prog, stop := startProgress(len(clones))
+	results := runPortfolios(ctx, clones, historicalData, riskFreeRates, reporter, prog)
+	stop()
+	logDataErrors(dataErrs, skipped)
+	if output != nil && output.Dir != "" {
+		if err := WriteArtifacts(output.Dir, results); err != nil {
+			runLogger.Error("write artifacts", "err", err)
+		}
+	}
+	if output != nil && output.Database {
+		// Results that finished before a cancel are still worth keeping.
+		if err := SaveResults(context.WithoutCancel(ctx), store, results); err != nil {
+			runLogger.Error("save results", "err", err)
+		}
+	}
+	return results, ctx.Err()
+}
 
+// runPortfolios simulates each portfolio on a worker pool and returns
+// the Results in portfolio order, writing each to reporter (if non-nil)
+// as soon as every earlier portfolio's Result has been written. The
+// reporter is closed before returning. Portfolios not finished when ctx
+// is done are left out. Each finished portfolio is counted in prog,
+// which may be nil.
+func runPortfolios(
+	ctx context.Context,
+	clones []*Portfolio,
+	historicalData map[string][]data.AssetData,
+	riskFreeRates map[int64]float64,
+	reporter *Reporter,
+	prog *Progress,
+) []Result {
 	numWorkers := runtime.NumCPU()
-	totalJobs := len(portfolios)
-	jobs := make(chan *Portfolio, totalJobs)
-	results := make(chan Result, totalJobs)
+	totalJobs := len(clones)
+	jobs := make(chan int, totalJobs)
+	results := make(chan indexedResult, totalJobs)
 
 	var wg sync.WaitGroup
 
@@ -109,49 +459,71 @@ func Run(portfolios []*Portfolio, output *OutputConfig) ([]Result, error) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for p := range jobs {
-				runOne(p, historicalData, riskFreeRates)
-				// DailyReturns and PortfolioCloseValues are appended together
-				// each day, so they share length and ordering.
-				dates := make([]string, len(p.DailyReturns))
-				for i, dr := range p.DailyReturns {
-					dates[i] = dr.Date.Format("2006-01-02")
-				}
-				results <- Result{
-					PortfolioName: p.Pname,
-					Strategy:      p.Strategy.Name(),
-					Metrics:       p.Metrics,
-					EquityCurve:   p.PortfolioCloseValues,
-					Dates:         dates,
+			for i := range jobs {
+				p := clones[i]
+				if !runOne(ctx, p, historicalData, riskFreeRates) ||
+					!p.compareRandomEntry(ctx, historicalData, riskFreeRates) {
+					results <- indexedResult{index: i}
+					continue
 				}
+				results <- indexedResult{i, newResult(p), true}
+				prog.Add()
 			}
 		}()
 	}
-
-	go func() {
-		defer close(jobs)
-		for _, p := range portfolios {
-			clone, err := p.Clone()
-			if err != nil {
-				log.Printf("clone portfolio %s: %v", p.Pname, err)
-				continue
-			}
-			jobs <- clone
-		}
-	}()
+	for i := range clones {
+		jobs <- i
+	}
+	close(jobs)
 
 	collected := make([]Result, 0, totalJobs)
+	// done buffers results that finished ahead of an earlier portfolio;
+	// next is the index of the portfolio to write next.
+	done := make(map[int]indexedResult)
+	next := 0
 	writerDone := make(chan struct{})
 	go func() {
 		defer close(writerDone)
 		if reporter != nil {
-			defer reporter.Close()
+			defer func() {
+				if cerr := reporter.Close(); cerr != nil {
+					runLogger.Error("close output", "err", cerr)
+				}
+			}()
 		}
-		for result := range results {
-			collected = append(collected, result)
-			if reporter != nil {
-				if werr := reporter.Write(result); werr != nil {
-					log.Printf("Failed to write result: %v", werr)
+		var tick <-chan time.Time
+		if d := reporter.FlushInterval(); d > 0 {
+			ticker := time.NewTicker(d)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case ir, ok := <-results:
+				if !ok {
+					return
+				}
+				done[ir.index] = ir
+				for {
+					ir, ok := done[next]
+					if !ok {
+						break
+					}
+					delete(done, next)
+					next++
+					if !ir.ok {
+						continue
+					}
+					collected = append(collected, ir.result)
+					if reporter != nil {
+						if werr := reporter.Write(ir.result); werr != nil {
+							runLogger.Error("write result", "err", werr)
+						}
+					}
+				}
+			case <-tick:
+				if ferr := reporter.Flush(); ferr != nil {
+					runLogger.Error("flush results", "err", ferr)
 				}
 			}
 		}
@@ -161,24 +533,37 @@ func Run(portfolios []*Portfolio, output *OutputConfig) ([]Result, error) {
 	close(results)
 	<-writerDone
 
-	return collected, nil
+	return collected
 }
 
-// RunFromConfigText decodes a TOML config from cfgText, initializes the DB
-// at dbPath, and runs every configured portfolio. Portfolios that omit
-// Strategy fall back to "lua:<defaultLuaPath>" so the UI's open Lua script
-// acts as the default strategy. Designed as the entry point for callers
-// (e.g. the UI) that hold the config as in-memory text.
-func RunFromConfigText(cfgText, dbPath, defaultLuaPath string) ([]Result, error) {
-	if _, err := data.InitDB(dbPath); err != nil {
+// RunFromConfigText decodes a TOML (or JSON) config from cfgText,
+// initializes the DB at dbPath, and runs every configured portfolio.
+// Portfolios that omit Strategy fall back to "lua:<defaultLuaPath>" so the
+// UI's open Lua script acts as the default strategy. Designed as the entry
+// point for callers (e.g. the UI) that hold the config as in-memory text.
+// Like the CLI, it writes a manifest beside any [Output] file.
+// BACKTESTER_* environment overrides apply, except that dbPath always
+// wins. ctx cancels the run as it does for Run.
+func RunFromConfigText(ctx context.Context, cfgText, dbPath, defaultLuaPath string) ([]Result, error) {
+	cfg, err := ParseConfig(cfgText, "")
+	if err != nil {
+		return nil, err
+	}
+	cfg.ApplyEnv(os.Getenv)
+	cfg.ApplySeed()
+	store, closeStore, err := cfg.Database.OpenStore(dbPath)
+	if err != nil {
 		return nil, fmt.Errorf("open db %q: %w", dbPath, err)
 	}
-	var cfg Config
-	if _, err := toml.Decode(cfgText, &cfg); err != nil {
-		return nil, fmt.Errorf("parse toml: %w", err)
+	defer closeStore()
+	if cfg.Database != nil && cfg.Database.CacheMB > 0 {
+		SharedBarCache.SetBudget(int64(cfg.Database.CacheMB) << 20)
+		store = SharedBarCache.Wrap(dbPath, store)
 	}
 	portfolios := make([]*Portfolio, 0, len(cfg.Portfolios))
-	for _, pc := range cfg.Portfolios {
+	for i := range cfg.Portfolios {
+		// Filled in place so the manifest records the script that ran.
+		pc := &cfg.Portfolios[i]
 		if strings.TrimSpace(pc.Strategy) == "" {
 			if defaultLuaPath == "" {
 				return nil, fmt.Errorf(
@@ -197,5 +582,21 @@ func RunFromConfigText(cfgText, dbPath, defaultLuaPath string) ([]Result, error)
 	if len(portfolios) == 0 {
 		return nil, fmt.Errorf("config defines no portfolios")
 	}
-	return Run(portfolios, cfg.Output)
+	results, err := Run(ctx, store, portfolios, cfg.Output)
+	if err != nil {
+		return nil, err
+	}
+	if path := ManifestPath(cfg); path != "" {
+		m, err := NewManifest(ctx, store, cfg, portfolios, results)
+		if err == nil {
+			err = WriteManifest(path, m)
+		}
+		if err != nil {
+			runLogger.Error("manifest", "err", err)
+		}
+	}
+	if err := PostRunSignals(ctx, cfg.Webhook, results); err != nil {
+		runLogger.Error("webhook", "err", err)
+	}
+	return results, nil
 }
@@ -0,0 +1,219 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"my-backtester/src/metrics"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeStore serves fixed in-memory series in place of DuckDB.
+type fakeStore struct {
+	bars      map[string][]data.AssetData
+	rates     map[int64]float64
+	macro     map[string][]data.MacroPoint
+	actions   map[string][]data.CorporateAction
+	symbols   []data.SymbolChange
+	queried   []string
+	rateReads int
+}
+
+func (f *fakeStore) QueryAssetsForTickers(
+	ctx context.Context, tickers []string, start, end time.Time,
+) map[string][]data.AssetData {
+	f.queried = append(f.queried, tickers...)
+	out := make(map[string][]data.AssetData)
+	for _, t := range tickers {
+		for _, b := range f.bars[t] {
+			if !b.Date.Before(start) && !b.Date.After(end) {
+				out[t] = append(out[t], b)
+			}
+		}
+		data.FillReturns(out[t])
+	}
+	return out
+}
+
+func (f *fakeStore) GetRiskFreeRates(ctx context.Context, start, end time.Time) map[int64]float64 {
+	f.rateReads++
+	return f.rates
+}
+
+func (f *fakeStore) QueryActions(
+	ctx context.Context, tickers []string, start, end time.Time,
+) map[string][]data.CorporateAction {
+	return f.actions
+}
+
+func (f *fakeStore) SymbolChanges(ctx context.Context) ([]data.SymbolChange, error) {
+	return f.symbols, nil
+}
+
+func (f *fakeStore) QueryMacro(ctx context.Context, series string, end time.Time) []data.MacroPoint {
+	var out []data.MacroPoint
+	for _, pt := range f.macro[series] {
+		if !pt.Date.After(end) {
+			out = append(out, pt)
+		}
+	}
+	return out
+}
+
+// Run reads everything through the Store it is given, including the Lua
+// macro() helper, so no database is needed.
+func TestRun_FakeStore(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{
+		bars:  map[string][]data.AssetData{},
+		rates: map[int64]float64{},
+		macro: map[string][]data.MacroPoint{
+			// The calm reading for day 2 isn't out until day 9.
+			"VIX": {{Date: day(0), Value: 30}, {Date: day(2), Value: 10, Published: day(9)}, {Date: day(3), Value: 15}},
+		},
+	}
+	for i := 0; i < 8; i++ {
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: day(i), Open: 10, High: 10, Low: 10, Close: 10 + float64(i), Volume: 100,
+		})
+		store.rates[day(i).Unix()] = 0
+	}
+	script := filepath.Join(t.TempDir(), "calm.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  local vix = macro("VIX", day)
+  if vix ~= nil and vix < 20 and position("A") == nil then
+    buy("A", 1, close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := InitializePortfolio(
+		1000, day(1), day(6), "calm", []string{"A"}, "lua:"+script, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.queried, []string{"A"}) {
+		t.Errorf("queried %v, want [A]", store.queried)
+	}
+	r := results[0]
+	if r.EffectiveStart != "2024-01-02" || r.EffectiveEnd != "2024-01-07" {
+		t.Errorf("window = %s..%s", r.EffectiveStart, r.EffectiveEnd)
+	}
+	if len(r.Trades) != 1 || !r.Trades[0].Date.Equal(day(3)) {
+		t.Fatalf("trades = %+v, want one buy once VIX drops on %s", r.Trades, day(3))
+	}
+}
+
+// Cancelling mid-run stops the simulation at the next bar, and the
+// unfinished portfolio is left out of the results rather than reported
+// with metrics for part of its window.
+func TestRunPortfolios_Cancel(t *testing.T) {
+	tickers, hist := generateBenchData()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bars := 0
+	done, err := InitializePortfolio(
+		100_000, time.Time{}, time.Time{}, "done", tickers, "smaCross:3:20:equalWeights", nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut, err := NewPortfolio("cut", 100_000, tickers, "smaCross:3:20:equalWeights",
+		WithEngineHook(func(e *Engine) {
+			e.OnBar(func(b BarEvent) {
+				if bars++; bars == 5 {
+					cancel()
+				}
+			})
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !runOne(ctx, done, hist, nil) {
+		t.Fatal("runOne cancelled before ctx was")
+	}
+	if runOne(ctx, cut, hist, nil) {
+		t.Error("runOne should report a cancelled run")
+	}
+	if bars != 5 {
+		t.Errorf("ran %d bars after cancel at bar 5", bars)
+	}
+	if results := runPortfolios(ctx, []*Portfolio{cut}, hist, nil, nil, nil); len(results) != 0 {
+		t.Errorf("results = %d, want none once cancelled", len(results))
+	}
+}
+
+// Results must come back in portfolio order with bit-identical metrics
+// however the worker pool schedules them.
+func TestRunPortfolios_DeterministicOrder(t *testing.T) {
+	tickers, hist := generateBenchData()
+	rf := make(map[int64]float64)
+	for _, bar := range hist[tickers[0]] {
+		rf[bar.Date.Unix()] = 0.0001
+	}
+	run := func() []Result {
+		var clones []*Portfolio
+		for i := 0; i < 16; i++ {
+			spec := fmt.Sprintf("smaCross:%d:%d:equalWeights", 3+i%5, 20+i)
+			p, err := InitializePortfolio(
+				100_000, time.Time{}, time.Time{}, fmt.Sprintf("p%02d", i),
+				tickers, spec, nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clones = append(clones, p)
+		}
+		return runPortfolios(context.Background(), clones, hist, rf, nil, nil)
+	}
+
+	first := run()
+	for i, r := range first {
+		if want := fmt.Sprintf("p%02d", i); r.PortfolioName != want {
+			t.Fatalf("result %d is %s, want %s", i, r.PortfolioName, want)
+		}
+	}
+	for n := 0; n < 3; n++ {
+		if again := run(); !reflect.DeepEqual(first, again) {
+			t.Fatalf("run %d differs from the first", n+2)
+		}
+	}
+}
+
+func TestExcessReturnsByDate_Ordered(t *testing.T) {
+	dailyAvg := map[int64]float64{}
+	rf := map[int64]float64{}
+	for d := int64(0); d < 500; d++ {
+		dailyAvg[d*86400] = float64(d%7) * 1e-3
+		if d%10 != 0 {
+			rf[d*86400] = 1e-4
+		}
+	}
+	got, filled := metrics.ExcessReturns(rf, dailyAvg)
+	if len(got) != 500 || filled != 50 {
+		t.Fatalf("got %d excess returns with %d filled, want 500 with 50", len(got), filled)
+	}
+	for d := range got {
+		if want := float64(d%7)*1e-3 - 1e-4; got[d] != want {
+			t.Fatalf("excess[%d] = %g, want %g", d, got[d], want)
+		}
+	}
+	sharpe := GetSharpeRatio(rf, dailyAvg)
+	for n := 0; n < 20; n++ {
+		if s := GetSharpeRatio(rf, dailyAvg); s != sharpe {
+			t.Fatalf("Sharpe varies between calls: %v vs %v", s, sharpe)
+		}
+	}
+}
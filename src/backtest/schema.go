@@ -0,0 +1,405 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigSchemaVersion is bumped whenever Config changes incompatibly.
+// Configs carry it as SchemaVersion; ParseConfig upgrades older ones.
+const ConfigSchemaVersion = 1
+
+// Migrations upgrade a raw document one version at a time: step v turns
+// a version-v document into a version v+1 one, so a file of any earlier
+// version loads after running every step from its own. Renamed or
+// reshaped fields get a step here when their version is bumped.
+var (
+	configMigrations = map[int]func(doc map[string]any) error{
+		// Version 0 is every config written before SchemaVersion existed;
+		// its keys are exactly version 1's.
+		0: func(map[string]any) error { return nil },
+	}
+	resultsMigrations = map[int]func(doc map[string]any) error{}
+)
+
+// migrate upgrades doc, whose version is stored under key (absent means
+// 0), to current.
+func migrate(
+	doc map[string]any,
+	key string,
+	current int,
+	steps map[int]func(map[string]any) error,
+) error {
+	v, err := schemaVersion(doc[key])
+	if err != nil {
+		return err
+	}
+	if v > current {
+		return fmt.Errorf("%s %d is newer than this build supports (%d)", key, v, current)
+	}
+	for ; v < current; v++ {
+		step, ok := steps[v]
+		if !ok {
+			return fmt.Errorf("%s %d is no longer supported", key, v)
+		}
+		if err := step(doc); err != nil {
+			return fmt.Errorf("migrate %s %d: %w", key, v, err)
+		}
+	}
+	doc[key] = current
+	return nil
+}
+
+// schemaVersion reads a version number as decoded from TOML (int64) or
+// JSON (float64 or json.Number).
+func schemaVersion(v any) (int, error) {
+	switch x := v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return int(x), nil
+	case float64:
+		if x == float64(int(x)) {
+			return int(x), nil
+		}
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return int(i), nil
+		}
+	}
+	return 0, fmt.Errorf("schema version %v: not an integer", v)
+}
+
+// ReadResultsJSON decodes a ResultsDocument written by WriteResultsJSON
+// under this or any earlier schema version, migrating it to the current
+// one.
+func ReadResultsJSON(r io.Reader) (ResultsDocument, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return ResultsDocument{}, fmt.Errorf("results: %w", err)
+	}
+	if err := migrate(doc, "schema_version", ResultsSchemaVersion, resultsMigrations); err != nil {
+		return ResultsDocument{}, fmt.Errorf("results: %w", err)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return ResultsDocument{}, err
+	}
+	var out ResultsDocument
+	if err := json.Unmarshal(b, &out); err != nil {
+		return ResultsDocument{}, fmt.Errorf("results: %w", err)
+	}
+	return out, nil
+}
+
+// ToResults converts the document back into Results, e.g. to compare or
+// report on a stored run.
+func (d ResultsDocument) ToResults() ([]Result, error) {
+	results := make([]Result, 0, len(d.Results))
+	for _, r := range d.Results {
+		trades := make([]Trade, 0, len(r.Trades))
+		for _, t := range r.Trades {
+			date, err := time.Parse("2006-01-02", t.Date)
+			if err != nil {
+				date, err = time.Parse(dateTimeLayout, t.Date)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("result %s: trade date: %w", r.Portfolio, err)
+			}
+			trades = append(trades, Trade{
+				Date:   date,
+				Ticker: t.Ticker,
+				Side:   t.Side,
+				Amount: t.Amount,
+				Price:  t.Price,
+				Fee:    t.Fee,
+				Note:   t.Note,
+			})
+		}
+		results = append(results, Result{
+			PortfolioName:  r.Portfolio,
+			Strategy:       r.Strategy,
+			Params:         r.Params,
+			Seed:           r.Seed,
+			Start:          r.Start,
+			End:            r.End,
+			Metrics:        metricsFromJSON(r.Metrics),
+			EquityCurve:    r.EquityCurve,
+			Dates:          r.Dates,
+			Trades:         trades,
+			Rolling:        rollingSeries(r.Rolling),
+			MonteCarlo:     monteCarloBands(r.MonteCarlo),
+			Windows:        walkForwardWindows(r.WalkForward),
+			Generations:    optimizeGenerations(r.Optimize),
+			Folds:          crossValidationFolds(r.Folds),
+			Pairs:          pairStats(r.Pairs),
+			Sleeves:        sleeveStats(r.Sleeves),
+			Attribution:    attribution(r.Attribution),
+			Sectors:        sectorExposures(r.Sectors),
+			FillModel:      FillModel(r.FillModel),
+			EffectiveStart: r.EffectiveStart,
+			EffectiveEnd:   r.EffectiveEnd,
+			Lookahead:      r.Lookahead,
+			Halted:         r.Halted,
+
+			ExecutionFailures: r.ExecutionFailures,
+		})
+	}
+	return results, nil
+}
+
+func varLevels(levels []VaRJSON) []VaR {
+	var out []VaR
+	for _, v := range levels {
+		out = append(out, VaR(v))
+	}
+	return out
+}
+
+// metricsFromJSON converts wire-form metrics back.
+func metricsFromJSON(m MetricsJSON) Metrics {
+	return Metrics{
+		SharpeRatio:       m.SharpeRatio,
+		SortinoRatio:      m.SortinoRatio,
+		MaxDrawdown:       m.MaxDrawdown,
+		AnnualReturn:      m.AnnualReturn,
+		StandardDev:       m.StandardDev,
+		AvgCorrelation:    m.AvgCorrelation,
+		CointegratedPairs: m.CointegratedPairs,
+		Observations:      m.Observations,
+		RiskFreeFilled:    m.RiskFreeFilled,
+		BenchmarkReturn:   m.BenchmarkReturn,
+		CalmarRatio:       m.CalmarRatio,
+		IRR:               m.IRR,
+		NetContributions:  m.NetContributions,
+		AvgGrossExposure:  m.AvgGrossExposure,
+		AvgNetExposure:    m.AvgNetExposure,
+		MaxLeverage:       m.MaxLeverage,
+		MarginInterest:    m.MarginInterest,
+		MaxSectorExposure: m.MaxSectorExposure,
+		TaxesPaid:         m.TaxesPaid,
+		DeferredTax:       m.DeferredTax,
+		AfterTaxReturn:    m.AfterTaxReturn,
+		UnsettledRejects:  m.UnsettledRejects,
+		TStat:             m.TStat,
+		PValue:            m.PValue,
+		Trials:            m.Trials,
+		DeflatedSharpe:    m.DeflatedSharpe,
+		Confidence:        m.Confidence,
+		SharpeLow:         m.SharpeLow,
+		SharpeHigh:        m.SharpeHigh,
+		AnnualReturnLow:   m.AnnualReturnLow,
+		AnnualReturnHigh:  m.AnnualReturnHigh,
+		RandomEntryRuns:   m.RandomEntryRuns,
+		RandomEntrySharpe: m.RandomEntrySharpe,
+		RandomEntryRank:   m.RandomEntryRank,
+		Folds:             m.Folds,
+		FoldScore:         m.FoldScore,
+		FoldScoreStdDev:   m.FoldScoreStdDev,
+		FoldInSampleScore: m.FoldInSampleScore,
+		PBO:               m.PBO,
+		PBOConfigs:        m.PBOConfigs,
+		ClosedTrades:      m.ClosedTrades,
+		WinRate:           m.WinRate,
+		AvgWin:            m.AvgWin,
+		AvgLoss:           m.AvgLoss,
+		ProfitFactor:      m.ProfitFactor,
+		Expectancy:        m.Expectancy,
+		AvgHoldingDays:    m.AvgHoldingDays,
+		VaR:               varLevels(m.VaR),
+		MaxDrawdownDays:   m.MaxDrawdownDays,
+		RecoveryDays:      m.RecoveryDays,
+		Drawdowns:         drawdownList(m.Drawdowns),
+	}
+}
+
+func walkForwardWindows(ws []WalkForwardJSON) []WalkForwardWindow {
+	var out []WalkForwardWindow
+	for _, w := range ws {
+		out = append(out, WalkForwardWindow{
+			InStart: w.InStart, InEnd: w.InEnd,
+			OutStart: w.OutStart, OutEnd: w.OutEnd,
+			Strategy: w.Strategy, Params: w.Params,
+			Score: w.Score, Metrics: metricsFromJSON(w.Metrics),
+		})
+	}
+	return out
+}
+
+func crossValidationFolds(fs []CrossValidationJSON) []CrossValidationFold {
+	var out []CrossValidationFold
+	for _, f := range fs {
+		out = append(out, CrossValidationFold{
+			Start: f.Start, End: f.End,
+			Strategy: f.Strategy, Params: f.Params,
+			InSampleScore: f.InSampleScore, Score: f.Score, Metrics: metricsFromJSON(f.Metrics),
+		})
+	}
+	return out
+}
+
+func optimizeGenerations(gs []OptimizeJSON) []OptimizeGeneration {
+	var out []OptimizeGeneration
+	for _, g := range gs {
+		out = append(out, OptimizeGeneration(g))
+	}
+	return out
+}
+
+func sleeveStats(ss []SleeveJSON) []SleeveStats {
+	var out []SleeveStats
+	for _, s := range ss {
+		out = append(out, SleeveStats{
+			Strategy: s.Strategy, Params: s.Params, Weight: s.Weight,
+			Contribution: s.Contribution, Metrics: metricsFromJSON(s.Metrics),
+		})
+	}
+	return out
+}
+
+func sectorExposures(es []SectorExposureJSON) []SectorExposure {
+	var out []SectorExposure
+	for _, e := range es {
+		out = append(out, SectorExposure(e))
+	}
+	return out
+}
+
+func attribution(a *AttributionJSON) *Attribution {
+	if a == nil {
+		return nil
+	}
+	rows := func(rs []AttributionRowJSON) []AttributionRow {
+		out := make([]AttributionRow, len(rs))
+		for i, r := range rs {
+			out[i] = AttributionRow(r)
+		}
+		return out
+	}
+	return &Attribution{
+		Tickers: rows(a.Tickers), Strategies: rows(a.Strategies), Books: rows(a.Books),
+		Other: AttributionRow(a.Other), Total: AttributionRow(a.Total),
+	}
+}
+
+func pairStats(ps []PairJSON) []PairStats {
+	var out []PairStats
+	for _, p := range ps {
+		out = append(out, PairStats(p))
+	}
+	return out
+}
+
+func monteCarloBands(mc *MonteCarloJSON) *MonteCarlo {
+	if mc == nil {
+		return nil
+	}
+	out := &MonteCarlo{Method: mc.Method, Runs: mc.Runs, LossProbability: mc.LossProbability}
+	for _, b := range mc.Bands {
+		out.Bands = append(out.Bands, MonteCarloBand(b))
+	}
+	return out
+}
+
+func rollingSeries(rs []RollingJSON) []Rolling {
+	var out []Rolling
+	for _, r := range rs {
+		out = append(out, Rolling(r))
+	}
+	return out
+}
+
+// drawdownList parses drawdowns back; their day counts follow from the
+// dates.
+func drawdownList(dd []DrawdownJSON) []Drawdown {
+	var out []Drawdown
+	for _, d := range dd {
+		out = append(out, Drawdown{
+			Peak:     parseDay(d.Peak),
+			Trough:   parseDay(d.Trough),
+			Recovery: parseDay(d.Recovery),
+			Depth:    d.Depth,
+		})
+	}
+	return out
+}
+
+// JSONSchema describes the config file ("config") or the -json results
+// document ("results") as a JSON Schema. It is generated from the Go
+// structs, so it cannot drift from what the engine reads and writes.
+func JSONSchema(kind string) ([]byte, error) {
+	var s map[string]any
+	switch kind {
+	case "config":
+		s = schemaOf(reflect.TypeOf(Config{}), "toml")
+		s["title"] = "Backtester config"
+		s["properties"].(map[string]any)["SchemaVersion"] = map[string]any{
+			"type": "integer", "minimum": 0, "maximum": ConfigSchemaVersion,
+		}
+	case "results":
+		s = schemaOf(reflect.TypeOf(ResultsDocument{}), "json")
+		s["title"] = "Backtester results"
+		s["properties"].(map[string]any)["schema_version"] = map[string]any{
+			"const": ResultsSchemaVersion,
+		}
+	default:
+		return nil, fmt.Errorf("schema %q: must be config or results", kind)
+	}
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// schemaOf maps a Go type to a JSON Schema, naming struct fields by
+// their tag key. JSON fields without omitempty are always written, so
+// they are listed as required; config keys never are.
+func schemaOf(t reflect.Type, tag string) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), tag)
+	case reflect.Struct:
+		props := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = schemaOf(f.Type, tag)
+			if tag == "json" && !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		s := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), tag)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), tag)}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{} // any value, e.g. Lua Params
+}
@@ -0,0 +1,153 @@
+package backtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig_SchemaVersion(t *testing.T) {
+	const body = `
+[[portfolio]]
+Name = "A"
+Tickers = ["AAPL"]
+Params = { fast = 10 }
+`
+	for _, c := range []struct {
+		header  string
+		wantErr bool
+	}{
+		{"", false}, // written before versioning
+		{"SchemaVersion = 1", false},
+		{"SchemaVersion = 99", true},
+		{`SchemaVersion = "one"`, true},
+	} {
+		cfg, err := ParseConfig(c.header+"\n"+body, "toml")
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error", c.header)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", c.header, err)
+		}
+		if cfg.SchemaVersion != ConfigSchemaVersion {
+			t.Errorf("%q: SchemaVersion = %d", c.header, cfg.SchemaVersion)
+		}
+		pc := cfg.Portfolios[0]
+		if pc.Name != "A" || pc.Params["fast"] != int64(10) {
+			t.Errorf("%q: portfolio = %+v", c.header, pc)
+		}
+	}
+}
+
+// Steps run in order from the document's version, each seeing the
+// previous one's output.
+func TestMigrate_Steps(t *testing.T) {
+	steps := map[int]func(map[string]any) error{
+		1: func(doc map[string]any) error {
+			doc["b"] = doc["a"]
+			delete(doc, "a")
+			return nil
+		},
+		2: func(doc map[string]any) error {
+			doc["c"] = doc["b"].(string) + "!"
+			delete(doc, "b")
+			return nil
+		},
+	}
+	doc := map[string]any{"v": int64(1), "a": "x"}
+	if err := migrate(doc, "v", 3, steps); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"v": 3, "c": "x!"}; !reflect.DeepEqual(doc, want) {
+		t.Errorf("doc = %v, want %v", doc, want)
+	}
+	if err := migrate(map[string]any{}, "v", 3, steps); err == nil {
+		t.Error("expected error without a step from version 0")
+	}
+}
+
+func TestReadResultsJSON_RoundTrip(t *testing.T) {
+	want := []Result{{
+		PortfolioName: "p",
+		Strategy:      "smaCross",
+		Metrics:       Metrics{SharpeRatio: 1.25, Observations: 2, BenchmarkReturn: 3},
+		EquityCurve:   []float64{100, 101.5},
+		Dates:         []string{"2024-01-02", "2024-01-03"},
+		Trades: []Trade{{
+			Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Ticker: "A",
+			Side: "BUY", Amount: 2, Price: 50, Fee: 1,
+		}},
+		FillModel:      FillClose,
+		EffectiveStart: "2024-01-02",
+		EffectiveEnd:   "2024-01-03",
+	}}
+	var buf bytes.Buffer
+	if err := WriteResultsJSON(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadResultsJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := doc.ToResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	if _, err := ReadResultsJSON(strings.NewReader(`{"schema_version": 99, "results": []}`)); err == nil {
+		t.Error("expected error for a newer schema_version")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	var results map[string]any
+	b, err := JSONSchema("results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &results); err != nil {
+		t.Fatal(err)
+	}
+	props := results["properties"].(map[string]any)
+	if v := props["schema_version"].(map[string]any)["const"]; v != float64(ResultsSchemaVersion) {
+		t.Errorf("schema_version const = %v", v)
+	}
+	result := props["results"].(map[string]any)["items"].(map[string]any)
+	required := result["required"].([]any)
+	if !containsAny(required, "portfolio") || containsAny(required, "lookahead") {
+		t.Errorf("result required = %v", required)
+	}
+
+	var config map[string]any
+	if b, err = JSONSchema("config"); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		t.Fatal(err)
+	}
+	portfolio := config["properties"].(map[string]any)["portfolio"].(map[string]any)["items"].(map[string]any)
+	if _, ok := portfolio["properties"].(map[string]any)["StartDate"]; !ok {
+		t.Errorf("portfolio properties missing StartDate: %v", portfolio["properties"])
+	}
+	if _, err := JSONSchema("trades"); err == nil {
+		t.Error("expected error for unknown schema")
+	}
+}
+
+func containsAny(xs []any, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package backtest
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Secret holds a credential (API key, token, webhook URL) read from the
+// config file or environment. It formats as "[redacted]" under every fmt
+// verb and marshals redacted, so logging a config or executor never
+// leaks it; use string(s) only where the raw value goes on the wire.
+type Secret string
+
+const redacted = "[redacted]"
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string { return `"` + s.String() + `"` }
+
+func (s Secret) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// redactURLError strips the path and query from the URL that net/http
+// embeds in request errors, since webhook URLs often carry their token
+// in the path.
+func redactURLError(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
+	}
+	u, perr := url.Parse(ue.URL)
+	if perr != nil {
+		return &url.Error{Op: ue.Op, URL: redacted, Err: ue.Err}
+	}
+	return &url.Error{Op: ue.Op, URL: u.Scheme + "://" + u.Host + "/" + redacted, Err: ue.Err}
+}
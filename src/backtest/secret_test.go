@@ -0,0 +1,52 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSecret_NeverFormatsRawValue(t *testing.T) {
+	cfg := &BrokerConfig{
+		Name:   "alpaca",
+		Alpaca: &AlpacaConfig{KeyID: "AKID123", SecretKey: "s3cr3t"},
+	}
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+		out := fmt.Sprintf(verb, *cfg.Alpaca)
+		if strings.Contains(out, "AKID123") || strings.Contains(out, "s3cr3t") {
+			t.Errorf("%s leaked secret: %s", verb, out)
+		}
+	}
+	if Secret("").String() != "" {
+		t.Error("empty secret should format empty")
+	}
+}
+
+func TestApplyEnv_OverridesConfig(t *testing.T) {
+	cfg := &Config{Output: &OutputConfig{Path: "file.csv", Format: "csv"}}
+	env := map[string]string{
+		EnvDBPath:     "/data/prices.db",
+		EnvOutputPath: "/out/results.csv",
+		EnvWebhookURL: "https://hooks.example.com/T0KEN",
+	}
+	cfg.ApplyEnv(func(k string) string { return env[k] })
+	if cfg.Database.Path != "/data/prices.db" {
+		t.Errorf("db path = %q", cfg.Database.Path)
+	}
+	if cfg.Output.Path != "/out/results.csv" || cfg.Output.Format != "csv" {
+		t.Errorf("output = %+v", *cfg.Output)
+	}
+	if string(cfg.Webhook.URL) != env[EnvWebhookURL] {
+		t.Errorf("webhook url not applied")
+	}
+}
+
+func TestRedactURLError(t *testing.T) {
+	err := &url.Error{Op: "Post", URL: "https://hooks.example.com/T0KEN?x=1", Err: errors.New("refused")}
+	got := redactURLError(err).Error()
+	if strings.Contains(got, "T0KEN") || !strings.Contains(got, "hooks.example.com") {
+		t.Errorf("redacted error = %q", got)
+	}
+}
@@ -0,0 +1,225 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"my-backtester/src/data"
+	"sort"
+	"strings"
+)
+
+// ErrSectorLimit refuses an order that would take its ticker's sector
+// past the portfolio's limit (see RiskConfig).
+var ErrSectorLimit = errors.New("sector exposure limit")
+
+// MetadataStore is a Store that also has the securities' sector,
+// industry and market cap; data.Store is one. Runs against one report
+// their sector exposures (see Result.Sectors) and can limit them (see
+// RiskConfig).
+type MetadataStore interface {
+	Metadata(ctx context.Context) (map[string]data.SecurityMetadata, error)
+}
+
+// SectorExposure is a portfolio's exposure to one sector over its run.
+type SectorExposure struct {
+	Sector string
+	// Exposure is the net value of the positions in the sector's
+	// tickers, shorts negative, as a percentage of the portfolio's value
+	// at each close, 1:1 with Result.Dates.
+	Exposure []float64
+	// Average is Exposure's mean and Max its largest absolute value.
+	Average float64
+	Max     float64
+}
+
+// loadMetadata reads the securities' metadata from store, if it is a
+// MetadataStore.
+func loadMetadata(ctx context.Context, store Store) map[string]data.SecurityMetadata {
+	ms, ok := store.(MetadataStore)
+	if !ok {
+		return nil
+	}
+	meta, err := ms.Metadata(ctx)
+	if err != nil {
+		runLogger.Error("load security metadata", "err", err)
+		return nil
+	}
+	return meta
+}
+
+// loadMetadata reads the securities' metadata from p's store, unless Run
+// already has.
+func (p *Portfolio) loadMetadata(ctx context.Context) {
+	if p.metadata == nil {
+		p.metadata = loadMetadata(ctx, p.store)
+	}
+}
+
+// Metadata is ticker's sector, industry and market cap from the store
+// (see MetadataStore), and whether it has any. They are as loaded, not
+// as of the current bar: a market cap is today's.
+func (c StrategyContext) Metadata(ticker string) (data.SecurityMetadata, bool) {
+	m, ok := c.p.metadata[ticker]
+	return m, ok
+}
+
+// SectorExposure is the gross value of the portfolio's positions in
+// sector's tickers, as a fraction of its equity, marked as the margin
+// check marks them. RiskConfig's sector limits are on this figure.
+func (c StrategyContext) SectorExposure(sector string) float64 {
+	return c.p.sectorExposure(sector)
+}
+
+func (p *Portfolio) sectorExposure(sector string) float64 {
+	equity, _, _ := p.exposure()
+	if !(equity > 0) {
+		return 0
+	}
+	return p.sectorGross(sector, "", 0, 0) / equity
+}
+
+// sectorGross is the gross value of p's positions in sector's tickers,
+// with ticker's moved by shares at price.
+func (p *Portfolio) sectorGross(sector, ticker string, shares, price float64) float64 {
+	gross := 0.0
+	if ticker != "" {
+		if pos, ok := p.Positions[ticker]; !ok || pos.Amount == 0 {
+			gross += math.Abs(shares * price)
+		}
+	}
+	for _, t := range p.Tickers {
+		pos, ok := p.Positions[t]
+		if !ok || pos.Amount == 0 || !strings.EqualFold(p.metadata[t].Sector, sector) {
+			continue
+		}
+		mark := p.mark(t, pos)
+		if t == ticker {
+			gross += math.Abs((pos.Amount + shares) * mark)
+		} else {
+			gross += math.Abs(pos.Amount * mark)
+		}
+	}
+	return gross
+}
+
+// sectorLimit is the largest fraction of equity r lets sector's
+// positions reach, if it limits sector.
+func (r *RiskConfig) sectorLimit(sector string) (float64, bool) {
+	for s, limit := range r.SectorLimits {
+		if strings.EqualFold(s, sector) {
+			return limit, true
+		}
+	}
+	return r.MaxSectorExposure, r.MaxSectorExposure > 0
+}
+
+// checkSectorLimit returns ErrSectorLimit if moving ticker's position by
+// shares at price would take its sector past p's limit for it. An order
+// that leaves the sector no more exposed always passes.
+func (p *Portfolio) checkSectorLimit(ticker string, shares, price float64) error {
+	r := p.Risk
+	if r == nil || (r.MaxSectorExposure == 0 && len(r.SectorLimits) == 0) {
+		return nil
+	}
+	sector := p.metadata[ticker].Sector
+	if sector == "" {
+		return nil
+	}
+	limit, ok := r.sectorLimit(sector)
+	if !ok {
+		return nil
+	}
+	after := p.sectorGross(sector, ticker, shares, price)
+	if after <= p.sectorGross(sector, "", 0, 0) {
+		return nil
+	}
+	if equity, _, _ := p.exposure(); after > limit*equity {
+		return ErrSectorLimit
+	}
+	return nil
+}
+
+// recordSectors adds the net value of p's positions in each of its
+// tickers' sectors at bar day's close to p.sectors, alongside the
+// close value just recorded.
+func (p *Portfolio) recordSectors(hist map[string][]data.AssetData, day int) {
+	if len(p.metadata) == 0 {
+		return
+	}
+	for _, t := range p.Tickers {
+		sector := p.metadata[t].Sector
+		if sector == "" {
+			continue
+		}
+		if p.sectors == nil {
+			p.sectors = make(map[string][]float64)
+		}
+		values := p.sectors[sector]
+		// The first of the sector's tickers opens its entry for the bar.
+		if len(values) < len(p.PortfolioCloseValues) {
+			values = append(values, make([]float64, len(p.PortfolioCloseValues)-len(values))...)
+		}
+		if pos, ok := p.Positions[t]; ok && pos.Amount != 0 && day < len(hist[t]) {
+			values[len(values)-1] += pos.Amount * hist[t][day].Close
+		}
+		p.sectors[sector] = values
+	}
+}
+
+// addSectors adds values, the sector values of a run whose bar i is p's
+// bar from+i, to p's. A run shorter than p holds its last values.
+func (p *Portfolio) addSectors(values map[string][]float64, from int) {
+	if p.sectors == nil && len(values) > 0 {
+		p.sectors = make(map[string][]float64)
+	}
+	n := len(p.PortfolioCloseValues)
+	for sector, vs := range values {
+		if len(vs) == 0 {
+			continue
+		}
+		dst := p.sectors[sector]
+		if len(dst) < n {
+			dst = append(dst, make([]float64, n-len(dst))...)
+		}
+		for i := from; i < n; i++ {
+			dst[i] += vs[min(i-from, len(vs)-1)]
+		}
+		p.sectors[sector] = dst
+	}
+}
+
+// sectorExposures are p's SectorExposures, by sector name; nil without
+// sectors.
+func (p *Portfolio) sectorExposures() []SectorExposure {
+	sectors := make([]string, 0, len(p.sectors))
+	for s := range p.sectors {
+		sectors = append(sectors, s)
+	}
+	sort.Strings(sectors)
+	var out []SectorExposure
+	for _, s := range sectors {
+		values := p.sectors[s]
+		e := SectorExposure{Sector: s, Exposure: make([]float64, len(p.PortfolioCloseValues))}
+		for i, v := range p.PortfolioCloseValues {
+			if i < len(values) && v > 0 {
+				e.Exposure[i] = 100 * values[i] / v
+			}
+			e.Average += e.Exposure[i]
+			e.Max = math.Max(e.Max, math.Abs(e.Exposure[i]))
+		}
+		if n := len(e.Exposure); n > 0 {
+			e.Average /= float64(n)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// setSectorExposure reports the largest of p's sector exposures in m.
+func (p *Portfolio) setSectorExposure(m *Metrics) {
+	m.MaxSectorExposure = 0
+	for _, e := range p.sectorExposures() {
+		m.MaxSectorExposure = math.Max(m.MaxSectorExposure, e.Max)
+	}
+}
@@ -0,0 +1,123 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metaStore is a fakeStore with security metadata.
+type metaStore struct {
+	*fakeStore
+	meta map[string]data.SecurityMetadata
+}
+
+func (m metaStore) Metadata(context.Context) (map[string]data.SecurityMetadata, error) {
+	return m.meta, nil
+}
+
+// Two technology tickers and an energy one, flat at 10, under a 40% cap
+// on any sector: the second technology buy would take the sector to 60%
+// and is refused, and a smaller one that reaches the cap goes through.
+func TestSectorExposure(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i) }
+	store := metaStore{
+		fakeStore: &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}},
+		meta: map[string]data.SecurityMetadata{
+			"A": {Sector: "Technology", Industry: "Software", MarketCap: 2e12},
+			"B": {Sector: "Technology"},
+			"C": {Sector: "Energy"},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		for _, tk := range []string{"A", "B", "C"} {
+			store.bars[tk] = append(store.bars[tk], data.AssetData{Date: day(i), Open: 10, High: 10, Low: 10, Close: 10, Volume: 100})
+		}
+		store.rates[day(i).Unix()] = 0
+	}
+	script := filepath.Join(t.TempDir(), "sectors.lua")
+	if err := os.WriteFile(script, []byte(`
+n = 0
+function step(day)
+  n = n + 1
+  if n == 1 then
+    if metadata("A").industry ~= "Software" or metadata("D") ~= nil then error("metadata") end
+    buy("A", 30, 10, day)
+    refused = not buy("B", 30, 10, day)
+    buy("C", 45, 10, day)
+  elseif n == 2 then
+    if not refused or math.abs(sector_exposure("technology") - 0.3) > 1e-9 then error("exposure") end
+    buy("B", 10, 10, day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPortfolio("sectors", 1000, []string{"A", "B", "C"}, "lua:"+script, WithWindow(day(0), day(9)),
+		WithRisk(RiskConfig{MaxSectorExposure: 0.4, SectorLimits: map[string]float64{"energy": 0.5}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("run: %v, %d results", err, len(results))
+	}
+	r := results[0]
+	var got []string
+	for _, tr := range r.Trades {
+		got = append(got, tr.Ticker)
+	}
+	if strings.Join(got, ",") != "A,C,B" || r.Trades[2].Amount != 10 {
+		t.Fatalf("trades = %+v, want A, C and 10 of B", r.Trades)
+	}
+
+	if len(r.Sectors) != 2 || r.Sectors[0].Sector != "Energy" || r.Sectors[1].Sector != "Technology" {
+		t.Fatalf("sectors = %+v", r.Sectors)
+	}
+	energy, tech := r.Sectors[0], r.Sectors[1]
+	if n := len(tech.Exposure); n != len(r.Dates) || !closeTo(tech.Exposure[n-1], 40) || !closeTo(energy.Exposure[n-1], 45) {
+		t.Errorf("exposure = %v, %v; want 40%% and 45%% at the end", tech.Exposure, energy.Exposure)
+	}
+	if !closeTo(tech.Max, 40) || !closeTo(r.Metrics.MaxSectorExposure, 45) {
+		t.Errorf("max = %v, metric %v; want 40 and 45", tech.Max, r.Metrics.MaxSectorExposure)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSectorsCSV(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); lines[0] != "date,Energy,Technology" ||
+		lines[len(lines)-1] != r.Dates[len(r.Dates)-1]+",45,40" {
+		t.Errorf("csv:\n%s", buf.String())
+	}
+}
+
+func TestCheckSectorLimit(t *testing.T) {
+	p, err := NewPortfolio("p", 1000, []string{"A", "B"}, "buyAndHold",
+		WithShorting(), WithRisk(RiskConfig{SectorLimits: map[string]float64{"Energy": 0}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.metadata = map[string]data.SecurityMetadata{"A": {Sector: "Energy"}}
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := p.Buy("A", 1, 10, now); !errors.Is(err, ErrSectorLimit) {
+		t.Errorf("buy in a closed sector: err = %v", err)
+	}
+	if err := p.Sell("A", 1, 10, now); !errors.Is(err, ErrSectorLimit) {
+		t.Errorf("short in a closed sector: err = %v", err)
+	}
+	if err := p.Buy("B", 1, 10, now); err != nil {
+		t.Errorf("buy without a sector: %v", err)
+	}
+	// A position from before the limit can still be cut.
+	p.Positions["A"] = &Position{Amount: 5, AveragePrice: 10, CurrentPrice: 10}
+	if err := p.Sell("A", 2, 10, now); err != nil {
+		t.Errorf("trim: %v", err)
+	}
+}
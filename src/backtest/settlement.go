@@ -0,0 +1,75 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsettledFunds is the OrderError cause of a buy that only cash
+// still settling would have paid for (see WithSettlement).
+var ErrUnsettledFunds = errors.New("insufficient settled cash")
+
+// WithSettlement models a cash account's settlement: a sale's proceeds are
+// cash at once, and count in the portfolio's value, but can't pay for a
+// buy until days sessions of the portfolio's Calendar after the sale:
+// business days, less holidays under CalendarNYSE. 2 is US equities' T+2,
+// 1 today's T+1, and 0 turns settlement off. Margin accounts don't wait,
+// so a portfolio with WithMargin ignores it.
+func WithSettlement(days int) Option {
+	return func(p *Portfolio) error {
+		if days < 0 {
+			return fmt.Errorf("settlement days %d: must be non-negative", days)
+		}
+		p.SettlementDays = days
+		return nil
+	}
+}
+
+// unsettledCash is sale proceeds that settle on a date.
+type unsettledCash struct {
+	settles time.Time
+	amount  float64
+}
+
+// settling reports whether p holds proceeds back until they settle.
+func (p *Portfolio) settling() bool {
+	return p.SettlementDays > 0 && p.Margin == nil
+}
+
+// holdProceeds marks amount, raised by a sale on date, as unsettled.
+func (p *Portfolio) holdProceeds(amount float64, date time.Time) {
+	if !p.settling() || !(amount > 0) {
+		return
+	}
+	p.unsettled = append(p.unsettled, unsettledCash{p.Calendar.Exchange().AddSessions(date, p.SettlementDays), amount})
+}
+
+// settle releases the proceeds due to settle by date. The engine calls
+// it before anything trades on a bar.
+func (p *Portfolio) settle(date time.Time) {
+	i := 0
+	for i < len(p.unsettled) && !p.unsettled[i].settles.After(date) {
+		i++
+	}
+	p.unsettled = p.unsettled[i:]
+}
+
+// fundsShort is why a buy costing cost was refused: ErrUnsettledFunds,
+// counted, if the cash is there but not settled.
+func (p *Portfolio) fundsShort(cost float64) error {
+	if p.settling() && cost <= p.BuyingPower {
+		p.refused++
+		return ErrUnsettledFunds
+	}
+	return ErrInsufficientFunds
+}
+
+// unsettledTotal is the proceeds not yet settled.
+func (p *Portfolio) unsettledTotal() float64 {
+	total := 0.0
+	for _, u := range p.unsettled {
+		total += u.amount
+	}
+	return total
+}
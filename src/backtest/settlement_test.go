@@ -0,0 +1,57 @@
+package backtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Proceeds of a sale can't pay for a buy until two business days later.
+func TestSettlement(t *testing.T) {
+	date := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) } // the 1st is a Monday
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithSettlement(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Buy("A", 10, 100, date(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Sell("A", 10, 100, date(2)); err != nil {
+		t.Fatal(err)
+	}
+	if p.BuyingPower != 1000 || p.purchasingPower() != 0 {
+		t.Fatalf("cash %v, purchasing power %v", p.BuyingPower, p.purchasingPower())
+	}
+	p.settle(date(3))
+	if err := p.Buy("A", 1, 100, date(3)); !errors.Is(err, ErrUnsettledFunds) {
+		t.Fatalf("buy with unsettled cash: err = %v", err)
+	}
+	if err := p.Buy("A", 20, 100, date(3)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("buy past the cash: err = %v", err)
+	}
+	p.settle(date(4))
+	if err := p.Buy("A", 1, 100, date(4)); err != nil {
+		t.Fatalf("buy with settled cash: %v", err)
+	}
+	if p.refused != 1 {
+		t.Errorf("refused = %d, want 1", p.refused)
+	}
+
+	// Friday's sale settles on Tuesday.
+	if err := p.Sell("A", 1, 100, date(5)); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.unsettled[0].settles; !got.Equal(date(9)) {
+		t.Errorf("settles %v, want the 9th", got)
+	}
+
+	m, err := NewPortfolio("m", 1000, []string{"A"}, "greedy", WithSettlement(2), WithMargin(MarginConfig{Leverage: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Buy("A", 10, 100, date(1))
+	m.Sell("A", 10, 100, date(2))
+	if err := m.Buy("A", 10, 100, date(2)); err != nil {
+		t.Errorf("margin account waited for settlement: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// DefaultSignificanceResamples is how many bootstrap paths a
+// significance config draws when it sets no Resamples.
+const DefaultSignificanceResamples = 1000
+
+// DefaultSignificanceConfidence is the confidence level of the bootstrap
+// intervals when a significance config sets none.
+const DefaultSignificanceConfidence = 0.95
+
+// SignificanceConfig tunes the significance tests of a run (see
+// WithSignificance). All fields are optional.
+type SignificanceConfig struct {
+	// Resamples is how many bootstrap paths the confidence intervals of
+	// the Sharpe ratio and annual return are drawn from; default
+	// DefaultSignificanceResamples.
+	Resamples int `toml:"resamples"`
+	// Block is how many consecutive days a path draws at a time, as in
+	// MonteCarloConfig; default 1.
+	Block int `toml:"block"`
+	// Confidence is the intervals' level, in (0, 1); default
+	// DefaultSignificanceConfidence.
+	Confidence float64 `toml:"confidence"`
+	// Trials is how many configurations the run was picked from, for the
+	// deflated Sharpe ratio; default the number of portfolios in the run.
+	Trials int `toml:"trials"`
+}
+
+// WithSignificance adds bootstrap confidence intervals of the Sharpe
+// ratio and annual return to the run's Metrics, and sets the trials its
+// deflated Sharpe ratio is corrected for when cfg.Trials is set. The
+// paths are drawn from a source seeded with the portfolio's Seed, so
+// they repeat exactly.
+func WithSignificance(cfg SignificanceConfig) Option {
+	return func(p *Portfolio) error {
+		if cfg.Resamples < 0 {
+			return fmt.Errorf("significance resamples %d: must not be negative", cfg.Resamples)
+		}
+		if cfg.Block < 0 {
+			return fmt.Errorf("significance block %d: must not be negative", cfg.Block)
+		}
+		if cfg.Confidence != 0 && !(cfg.Confidence > 0 && cfg.Confidence < 1) {
+			return fmt.Errorf("significance confidence %v: must be in (0, 1)", cfg.Confidence)
+		}
+		if cfg.Trials < 0 {
+			return fmt.Errorf("significance trials %d: must not be negative", cfg.Trials)
+		}
+		p.SignificanceConfig = &cfg
+		return nil
+	}
+}
+
+// setSignificance fills m's significance tests from a run's daily excess
+// returns: the t-test and deflated Sharpe ratio always, and the
+// bootstrap intervals, drawn from returns, when p asked for them.
+func (p *Portfolio) setSignificance(m *Metrics, returns, excess []float64, periodsPerYear float64) {
+	m.Trials = p.trials
+	if p.SignificanceConfig != nil && p.SignificanceConfig.Trials > 0 {
+		m.Trials = p.SignificanceConfig.Trials
+	}
+	if m.Trials < 1 {
+		m.Trials = 1
+	}
+	m.TStat, m.PValue = meanTTest(excess)
+	m.DeflatedSharpe = deflatedSharpe(excess, m.Trials)
+
+	cfg := p.SignificanceConfig
+	if cfg == nil || len(excess) != len(returns) {
+		return
+	}
+	runs := cfg.Resamples
+	if runs == 0 {
+		runs = DefaultSignificanceResamples
+	}
+	level := cfg.Confidence
+	if level == 0 {
+		level = DefaultSignificanceConfidence
+	}
+	// Its own source, so the draws don't depend on the strategy's.
+	rng := rand.New(rand.NewSource(p.Seed))
+	sharpe := make([]float64, runs)
+	annual := make([]float64, runs)
+	for i := range sharpe {
+		_, annual[i], sharpe[i] = returnPath(rng, cfg.Block, returns, excess, periodsPerYear)
+	}
+	sort.Float64s(sharpe)
+	sort.Float64s(annual)
+	lo, hi := (1-level)/2, 1-(1-level)/2
+	m.Confidence = level
+	m.SharpeLow = stat.Quantile(lo, stat.Empirical, sharpe, nil)
+	m.SharpeHigh = stat.Quantile(hi, stat.Empirical, sharpe, nil)
+	m.AnnualReturnLow = stat.Quantile(lo, stat.Empirical, annual, nil)
+	m.AnnualReturnHigh = stat.Quantile(hi, stat.Empirical, annual, nil)
+}
+
+// meanTTest is the t statistic of excess's mean against zero and its
+// one-sided p-value: the chance of a mean at least this high from
+// returns whose true mean is zero. Both are 0 below three observations
+// or without variance.
+func meanTTest(excess []float64) (t, p float64) {
+	n := float64(len(excess))
+	if n < 3 {
+		return 0, 0
+	}
+	mean, sd := stat.MeanStdDev(excess, nil)
+	if sd == 0 || math.IsNaN(sd) {
+		return 0, 0
+	}
+	t = mean / (sd / math.Sqrt(n))
+	return t, distuv.StudentsT{Mu: 0, Sigma: 1, Nu: n - 1}.Survival(t)
+}
+
+// eulerGamma is the Euler–Mascheroni constant.
+const eulerGamma = 0.5772156649015329
+
+// deflatedSharpe is the probability that excess's true Sharpe ratio is
+// above the highest one trials strategies without any edge would be
+// expected to show by luck (Bailey and López de Prado, "The Deflated
+// Sharpe Ratio", 2014), allowing for the returns' skew and fat tails.
+// With one trial it is the probabilistic Sharpe ratio against zero. The
+// luck is that of a per-period Sharpe estimate from len(excess)
+// observations; 0 below three or without variance.
+func deflatedSharpe(excess []float64, trials int) float64 {
+	n := float64(len(excess))
+	if n < 3 {
+		return 0
+	}
+	mean, sd := stat.MeanStdDev(excess, nil)
+	if sd == 0 || math.IsNaN(sd) {
+		return 0
+	}
+	sr := mean / sd
+	norm := distuv.UnitNormal
+	expectedMax := 0.0
+	if trials > 1 {
+		k := float64(trials)
+		expectedMax = math.Sqrt(1/(n-1)) *
+			((1-eulerGamma)*norm.Quantile(1-1/k) + eulerGamma*norm.Quantile(1-1/(k*math.E)))
+	}
+	skew := stat.Skew(excess, nil)
+	exKurt := stat.ExKurtosis(excess, nil)
+	v := 1 - skew*sr + (exKurt+2)/4*sr*sr
+	if !(v > 0) {
+		return 0
+	}
+	return norm.CDF((sr - expectedMax) * math.Sqrt(n-1) / math.Sqrt(v))
+}
@@ -0,0 +1,82 @@
+package backtest
+
+import (
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestSignificance(t *testing.T) {
+	// mean 0.006, sample stddev sqrt(0.00172/4), as in
+	// TestMetrics_HandVerified.
+	returns := []float64{0.01, -0.02, 0.03, -0.01, 0.02}
+	tStat, p := meanTTest(returns)
+	if want := 0.006 / (math.Sqrt(0.00172/4) / math.Sqrt(5)); !closeTo(tStat, want) {
+		t.Errorf("t = %v, want %v", tStat, want)
+	}
+	if !(p > 0.25 && p < 0.5) {
+		t.Errorf("p = %v, want a weak one-sided p-value", p)
+	}
+	if _, p := meanTTest([]float64{-0.01, -0.02, -0.01, -0.03}); p < 0.5 {
+		t.Errorf("losing returns p = %v, want above 0.5", p)
+	}
+	if tStat, p := meanTTest([]float64{0.01, 0.01, 0.01}); tStat != 0 || p != 0 {
+		t.Errorf("flat returns = %v, %v; want 0, 0", tStat, p)
+	}
+
+	// The same Sharpe ratio is less convincing the more it was picked
+	// from.
+	day := func(i int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i) }
+	var long []float64
+	for i := 0; i < 500; i++ {
+		long = append(long, 0.002+0.01*math.Sin(float64(i)))
+	}
+	one, many := deflatedSharpe(long, 1), deflatedSharpe(long, 1000)
+	if !(one > 0.95 && many < one) {
+		t.Errorf("deflated Sharpe = %v with one trial, %v with 1000; want it high and then lower", one, many)
+	}
+
+	newRun := func(opts ...Option) *Portfolio {
+		p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", append(opts, WithSeed(7))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := 1000.0
+		rates := make(map[int64]float64, len(long))
+		for i, r := range long {
+			rates[day(i).Unix()] = 0
+			value *= 1 + r
+			p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: day(i), Return: r})
+			p.PortfolioCloseValues = append(p.PortfolioCloseValues, value)
+		}
+		p.GetBacktestingData(rates, map[string][]data.AssetData{}, 0)
+		return p
+	}
+	m := newRun().Metrics
+	if m.Trials != 1 || !closeTo(m.DeflatedSharpe, one) || m.SharpeLow != 0 || m.Confidence != 0 {
+		t.Errorf("without a config: trials %d, deflated Sharpe %v, Sharpe low %v; want 1, %v, 0",
+			m.Trials, m.DeflatedSharpe, m.SharpeLow, one)
+	}
+	m = newRun(WithSignificance(SignificanceConfig{Resamples: 200, Trials: 1000})).Metrics
+	if m.Trials != 1000 || !closeTo(m.DeflatedSharpe, many) {
+		t.Errorf("trials = %d, deflated Sharpe = %v; want 1000 and %v", m.Trials, m.DeflatedSharpe, many)
+	}
+	if m.Confidence != DefaultSignificanceConfidence ||
+		!(m.SharpeLow < m.SharpeRatio && m.SharpeRatio < m.SharpeHigh) ||
+		!(m.AnnualReturnLow < m.AnnualReturn && m.AnnualReturn < m.AnnualReturnHigh) {
+		t.Errorf("Sharpe %v in [%v, %v], annual return %v in [%v, %v] at %v; want each inside its interval",
+			m.SharpeRatio, m.SharpeLow, m.SharpeHigh, m.AnnualReturn, m.AnnualReturnLow, m.AnnualReturnHigh, m.Confidence)
+	}
+
+	for _, bad := range []SignificanceConfig{
+		{Resamples: -1},
+		{Block: -1},
+		{Confidence: 1},
+		{Trials: -1},
+	} {
+		if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithSignificance(bad)); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+	"my-backtester/src/indicators"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// Sizer decides how many shares a buy signal takes. Strategies name one
+// as the last field of their spec (e.g. "smaCross:10:50:fixedFraction:0.2"),
+// and Portfolio.maxBuy cuts what it asks for to the whole shares cash
+// affords, fees included.
+type Sizer interface {
+	Name() string
+	Size(req SizeRequest) float64
+}
+
+// SizeRequest is what a Sizer sizes a buy of Ticker from.
+type SizeRequest struct {
+	Ticker string
+	Price  float64 // the fill price, after slippage
+	Cash   float64 // buying power, margin included, left after the order's fee
+	// Equity is cash plus open positions at the current bar's close.
+	Equity  float64
+	Tickers []string // the portfolio's universe on the bar
+	// Bars are Ticker's bars up to and including the current one; nil
+	// outside a run.
+	Bars           []data.AssetData
+	Closed         []ClosedTrade
+	PeriodsPerYear float64
+}
+
+// SizerFactory builds a sizer from the part of its spec after the first
+// ':' ("" when there is none).
+type SizerFactory func(arg string) (Sizer, error)
+
+// RegisterSizer registers a sizer component and the factory NewSizer
+// calls for specs starting with its name.
+func RegisterSizer(c Component, f SizerFactory) {
+	c.Kind = KindSizer
+	Register(c)
+	registry.Lock()
+	defer registry.Unlock()
+	registry.sizers[c.Name] = f
+}
+
+// NewSizer builds a Sizer from a spec such as "greedy" or
+// "fixedFraction:0.1".
+func NewSizer(spec string) (Sizer, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	registry.RLock()
+	f, ok := registry.sizers[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sizer %q: must be one of %s", spec, componentNames(KindSizer))
+	}
+	return f(arg)
+}
+
+// sizer returns the Sizer for spec, parsed once per portfolio.
+func (p *Portfolio) sizer(spec string) (Sizer, error) {
+	if s, ok := p.sizers[spec]; ok {
+		return s, nil
+	}
+	s, err := NewSizer(spec)
+	if err != nil {
+		return nil, err
+	}
+	if p.sizers == nil {
+		p.sizers = make(map[string]Sizer)
+	}
+	p.sizers[spec] = s
+	return s, nil
+}
+
+// sizeRequest describes a buy of ticker at price with cash to spend,
+// from the bar the engine is on.
+func (p *Portfolio) sizeRequest(ticker string, price, cash float64) SizeRequest {
+	req := SizeRequest{
+		Ticker: ticker, Price: price, Cash: cash, Tickers: p.Tickers,
+		Closed: p.ClosedTrades, PeriodsPerYear: p.periodsPerYear(),
+	}
+	req.Equity, _, _ = p.exposure()
+	if p.engine != nil {
+		if series := p.engine.bar.Hist[ticker]; p.bar < len(series) {
+			req.Bars = series[:p.bar+1]
+		}
+		if members := p.Context(p.engine.bar.Hist, p.bar).Universe(); len(members) > 0 {
+			req.Tickers = members
+		}
+	}
+	return req
+}
+
+func init() {
+	RegisterSizer(Component{
+		Name: "greedy", Usage: "greedy",
+		Doc: "all available buying power into each ticker in order",
+	}, func(arg string) (Sizer, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("greedy takes no arguments: %q", "greedy:"+arg)
+		}
+		return greedySizer{}, nil
+	})
+	RegisterSizer(Component{
+		Name: "equalWeights", Usage: "equalWeights",
+		Doc: "buying power split evenly across the portfolio's tickers",
+	}, func(arg string) (Sizer, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("equalWeights takes no arguments: %q", "equalWeights:"+arg)
+		}
+		return equalWeightsSizer{}, nil
+	})
+	RegisterSizer(Component{
+		Name: "fixedFraction", Usage: "fixedFraction:<fraction>",
+		Doc: "a fixed fraction of the portfolio's equity per buy",
+		Params: []Param{
+			{Name: "fraction", Type: "float", Doc: "share of equity, in (0, 1]"},
+		},
+	}, func(arg string) (Sizer, error) {
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil || !(f > 0 && f <= 1) {
+			return nil, fmt.Errorf("fixedFraction must be in (0, 1]: %q", arg)
+		}
+		return FixedFraction{Fraction: f}, nil
+	})
+	RegisterSizer(Component{
+		Name: "fixedDollar", Usage: "fixedDollar:<dollars>",
+		Doc: "a fixed dollar amount per buy",
+		Params: []Param{
+			{Name: "dollars", Type: "float", Doc: "amount per buy"},
+		},
+	}, func(arg string) (Sizer, error) {
+		d, err := strconv.ParseFloat(arg, 64)
+		if err != nil || !(d > 0) || math.IsInf(d, 1) {
+			return nil, fmt.Errorf("fixedDollar must be a positive amount: %q", arg)
+		}
+		return FixedDollar{Dollars: d}, nil
+	})
+	RegisterSizer(Component{
+		Name: "volTarget", Usage: "volTarget:<vol>:<lookback>",
+		Doc: "sizes each position to an annualized volatility of <vol> of equity",
+		Params: []Param{
+			{Name: "vol", Type: "float", Doc: "target annualized volatility, e.g. 0.1 for 10%"},
+			{Name: "lookback", Type: "int", Doc: "daily returns the ticker's volatility is measured over"},
+		},
+	}, func(arg string) (Sizer, error) {
+		sub := strings.Split(arg, ":")
+		if len(sub) != 2 {
+			return nil, fmt.Errorf("volTarget spec needs vol:lookback: %q", "volTarget:"+arg)
+		}
+		vol, err := strconv.ParseFloat(sub[0], 64)
+		if err != nil || !(vol > 0) {
+			return nil, fmt.Errorf("volTarget vol must be positive: %q", sub[0])
+		}
+		lookback, err := strconv.Atoi(sub[1])
+		if err != nil || lookback < 2 {
+			return nil, fmt.Errorf("volTarget lookback must be at least 2: %q", sub[1])
+		}
+		return VolTarget{Vol: vol, Lookback: lookback}, nil
+	})
+	RegisterSizer(Component{
+		Name: "atr", Usage: "atr:<period>:<risk>",
+		Doc: "risks <risk> of equity per average true range of the ticker",
+		Params: []Param{
+			{Name: "period", Type: "int", Doc: "bars in Wilder's ATR, e.g. 14"},
+			{Name: "risk", Type: "float", Doc: "share of equity one ATR move may cost, e.g. 0.01"},
+		},
+	}, func(arg string) (Sizer, error) {
+		sub := strings.Split(arg, ":")
+		if len(sub) != 2 {
+			return nil, fmt.Errorf("atr spec needs period:risk: %q", "atr:"+arg)
+		}
+		period, err := strconv.Atoi(sub[0])
+		if err != nil || period < 1 {
+			return nil, fmt.Errorf("atr period must be positive: %q", sub[0])
+		}
+		risk, err := strconv.ParseFloat(sub[1], 64)
+		if err != nil || !(risk > 0 && risk <= 1) {
+			return nil, fmt.Errorf("atr risk must be in (0, 1]: %q", sub[1])
+		}
+		return &ATRRisk{Period: period, Risk: risk}, nil
+	})
+	RegisterSizer(Component{
+		Name: "kelly", Usage: "kelly:<scale>:<start>",
+		Doc: "the Kelly fraction of equity from the closed trades so far, scaled",
+		Params: []Param{
+			{Name: "scale", Type: "float", Doc: "multiple of the Kelly fraction, e.g. 0.5 for half Kelly"},
+			{Name: "start", Type: "float", Doc: "fraction of equity bet until there are enough closed trades"},
+		},
+	}, func(arg string) (Sizer, error) {
+		sub := strings.Split(arg, ":")
+		if len(sub) != 2 {
+			return nil, fmt.Errorf("kelly spec needs scale:start: %q", "kelly:"+arg)
+		}
+		scale, err := strconv.ParseFloat(sub[0], 64)
+		if err != nil || !(scale > 0 && scale <= 1) {
+			return nil, fmt.Errorf("kelly scale must be in (0, 1]: %q", sub[0])
+		}
+		start, err := strconv.ParseFloat(sub[1], 64)
+		if err != nil || !(start >= 0 && start <= 1) {
+			return nil, fmt.Errorf("kelly start must be in [0, 1]: %q", sub[1])
+		}
+		return Kelly{Scale: scale, Start: start}, nil
+	})
+}
+
+type greedySizer struct{}
+
+func (greedySizer) Name() string { return "greedy" }
+func (greedySizer) Size(req SizeRequest) float64 {
+	return float64(greedyBuy(req.Cash, req.Price))
+}
+
+type equalWeightsSizer struct{}
+
+func (equalWeightsSizer) Name() string { return "equalWeights" }
+func (equalWeightsSizer) Size(req SizeRequest) float64 {
+	return float64(greedyBuy(req.Cash/float64(len(req.Tickers)), req.Price))
+}
+
+// FixedFraction buys Fraction of the portfolio's equity.
+type FixedFraction struct{ Fraction float64 }
+
+func (s FixedFraction) Name() string { return fmt.Sprintf("fixedFraction:%g", s.Fraction) }
+func (s FixedFraction) Size(req SizeRequest) float64 {
+	return float64(greedyBuy(s.Fraction*req.Equity, req.Price))
+}
+
+// FixedDollar buys Dollars' worth.
+type FixedDollar struct{ Dollars float64 }
+
+func (s FixedDollar) Name() string { return fmt.Sprintf("fixedDollar:%g", s.Dollars) }
+func (s FixedDollar) Size(req SizeRequest) float64 {
+	return float64(greedyBuy(s.Dollars, req.Price))
+}
+
+// VolTarget sizes a position so that its annualized volatility, measured
+// over the ticker's last Lookback daily returns (the bars' Return), is
+// Vol of equity. It buys nothing until there are Lookback returns.
+type VolTarget struct {
+	Vol      float64
+	Lookback int
+}
+
+func (s VolTarget) Name() string { return fmt.Sprintf("volTarget:%g:%d", s.Vol, s.Lookback) }
+func (s VolTarget) Size(req SizeRequest) float64 {
+	if len(req.Bars) <= s.Lookback {
+		return 0
+	}
+	bars := req.Bars[len(req.Bars)-s.Lookback-1:]
+	returns := data.Returns(bars, len(bars))
+	vol := stat.StdDev(returns, nil) * math.Sqrt(req.PeriodsPerYear)
+	if !(vol > 0) {
+		return 0
+	}
+	return float64(greedyBuy(s.Vol*req.Equity/vol, req.Price))
+}
+
+// ATRRisk sizes a position so that a move of one average true range
+// costs Risk of equity. It buys nothing until the ATR is ready.
+type ATRRisk struct {
+	Period int
+	Risk   float64
+	ranges map[string]*atrRange
+}
+
+// atrRange is one ticker's ATR, fed each of its bars once.
+type atrRange struct {
+	atr  *indicators.ATR
+	fed  int       // bars fed so far
+	last time.Time // the date of the last bar fed
+}
+
+func (s *ATRRisk) Name() string { return fmt.Sprintf("atr:%d:%g", s.Period, s.Risk) }
+func (s *ATRRisk) Size(req SizeRequest) float64 {
+	if s.ranges == nil {
+		s.ranges = make(map[string]*atrRange)
+	}
+	r := s.ranges[req.Ticker]
+	// Bars that don't extend the ones already fed are another series;
+	// start over on them.
+	if r == nil || r.fed > len(req.Bars) || (r.fed > 0 && !req.Bars[r.fed-1].Date.Equal(r.last)) {
+		r = &atrRange{atr: indicators.NewATR(s.Period)}
+		s.ranges[req.Ticker] = r
+	}
+	// Each bar is added once, so sizing costs O(1) however often
+	// maxBuy asks on a bar.
+	for ; r.fed < len(req.Bars); r.fed++ {
+		b := req.Bars[r.fed]
+		r.atr.Update(b.High, b.Low, b.Close)
+		r.last = b.Date
+	}
+	if !r.atr.Ready() || !(r.atr.Value() > 0) {
+		return 0
+	}
+	return math.Floor(s.Risk * req.Equity / r.atr.Value())
+}
+
+// KellyMinTrades is how many closed trades Kelly waits for before it
+// trusts their win rate and payoff.
+const KellyMinTrades = 10
+
+// Kelly bets Scale times the Kelly fraction W - (1-W)/R of equity, where
+// W is the closed trades' win rate and R their average win over average
+// loss, and nothing when that is negative. Before KellyMinTrades closed
+// trades it bets Start.
+type Kelly struct {
+	Scale, Start float64
+}
+
+func (s Kelly) Name() string { return fmt.Sprintf("kelly:%g:%g", s.Scale, s.Start) }
+func (s Kelly) Size(req SizeRequest) float64 {
+	f := s.Start
+	if len(req.Closed) >= KellyMinTrades {
+		f = s.Scale * kellyFraction(req.Closed)
+	}
+	if !(f > 0) {
+		return 0
+	}
+	return float64(greedyBuy(math.Min(f, 1)*req.Equity, req.Price))
+}
+
+// kellyFraction is W - (1-W)/R over closed; 1 when nothing lost and 0
+// when nothing won.
+func kellyFraction(closed []ClosedTrade) float64 {
+	var wins, losses int
+	var won, lost float64
+	for _, c := range closed {
+		switch {
+		case c.PnL > 0:
+			wins++
+			won += c.PnL
+		case c.PnL < 0:
+			losses++
+			lost -= c.PnL
+		}
+	}
+	if wins == 0 {
+		return 0
+	}
+	if losses == 0 {
+		return 1
+	}
+	w := float64(wins) / float64(wins+losses)
+	r := (won / float64(wins)) / (lost / float64(losses))
+	return w - (1-w)/r
+}
+
+// maxBuy sizes a purchase of ticker at quoted price with the sizer spec
+// (see Sizer), cut to what cash, or margin, covers. Sizing starts from one
+// share's costs and shrinks until the order affords its own, which covers
+// fees and slippage that grow with the order. An unknown sizer buys
+// nothing.
+func (p *Portfolio) maxBuy(ticker string, quoted float64, spec string) float64 {
+	s, err := p.sizer(spec)
+	if err != nil {
+		logger.Warn("sizer", "portfolio", p.Pname, "err", err)
+		return 0
+	}
+	size := func(n float64) float64 {
+		price, fee := p.execute("BUY", ticker, n, quoted)
+		cash := p.purchasingPower() - fee
+		if limit, ok := p.shortLimit(); ok {
+			cash = math.Min(cash, limit-fee)
+		}
+		return math.Min(s.Size(p.sizeRequest(ticker, price, cash)), float64(greedyBuy(cash, price)))
+	}
+	n := size(1)
+	for n > 0 {
+		m := size(n)
+		if m >= n {
+			break
+		}
+		n = m
+	}
+	return n
+}
@@ -0,0 +1,142 @@
+package backtest
+
+import (
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestSizers(t *testing.T) {
+	bar := func(c float64) data.AssetData { return data.AssetData{High: c + 1, Low: c - 1, Close: c} }
+	// Returns alternate +10% and -10%: a sample deviation of 0.1155,
+	// which is 20% a year at 3 periods a year.
+	swings := []data.AssetData{bar(100), bar(110), bar(99), bar(108.9), bar(98.01)}
+	data.FillReturns(swings)
+	won := ClosedTrade{PnL: 20}
+	lost := ClosedTrade{PnL: -10}
+	var record []ClosedTrade
+	for i := 0; i < 6; i++ {
+		record = append(record, won)
+	}
+	for i := 0; i < 4; i++ {
+		record = append(record, lost)
+	}
+	losing := make([]ClosedTrade, 10)
+	for i := range losing {
+		losing[i] = lost
+	}
+
+	for _, c := range []struct {
+		spec string
+		req  SizeRequest
+		want float64
+	}{
+		{"greedy", SizeRequest{Price: 10, Cash: 1000}, 100},
+		{"equalWeights", SizeRequest{Price: 10, Cash: 1000, Tickers: []string{"A", "B"}}, 50},
+		{"fixedFraction:0.25", SizeRequest{Price: 10, Cash: 1000, Equity: 10000}, 250},
+		{"fixedDollar:1000", SizeRequest{Price: 30, Cash: 5000}, 33},
+		{"volTarget:0.1:4", SizeRequest{Price: 49, Equity: 10000, Bars: swings, PeriodsPerYear: 3}, 102},
+		{"volTarget:0.1:5", SizeRequest{Price: 49, Equity: 10000, Bars: swings, PeriodsPerYear: 3}, 0},
+		{"atr:2:0.01", SizeRequest{Price: 10, Equity: 10000, Bars: []data.AssetData{bar(10), bar(10)}}, 50},
+		{"atr:3:0.01", SizeRequest{Price: 10, Equity: 10000, Bars: []data.AssetData{bar(10), bar(10)}}, 0},
+		{"kelly:0.5:0.1", SizeRequest{Price: 10, Equity: 10000, Closed: record[:9]}, 100},
+		// W = 0.6 and R = 2, so Kelly is 0.4 and half of it 0.2.
+		{"kelly:0.5:0.1", SizeRequest{Price: 9, Equity: 10000, Closed: record}, 222},
+		{"kelly:1:0.1", SizeRequest{Price: 10, Equity: 10000, Closed: losing}, 0},
+	} {
+		s, err := NewSizer(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+		if s.Name() != c.spec {
+			t.Errorf("%s: Name() = %q", c.spec, s.Name())
+		}
+		if got := s.Size(c.req); got != c.want {
+			t.Errorf("%s: Size = %v, want %v", c.spec, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"", "greedy:1", "fixedFraction", "fixedFraction:0", "fixedFraction:1.5",
+		"fixedDollar:-5", "volTarget:0.1", "volTarget:0.1:1", "atr:0:0.01",
+		"atr:14:2", "kelly:0:0.1", "kelly:0.5", "martingale",
+	} {
+		if _, err := NewSizer(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+// ATRRisk feeds each bar once and matches an ATR over the whole series
+// however the bars arrive, starting over on a different series.
+func TestATRRisk_Incremental(t *testing.T) {
+	var bars []data.AssetData
+	for i := 0; i < 30; i++ {
+		c := 100 + float64(i%7)
+		bars = append(bars, data.AssetData{
+			Date: time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			High: c + float64(i%3), Low: c - 1, Close: c,
+		})
+	}
+	fresh := func(n int) float64 {
+		s, _ := NewSizer("atr:5:0.01")
+		return s.Size(SizeRequest{Ticker: "A", Price: 100, Equity: 10000, Bars: bars[:n]})
+	}
+	s, _ := NewSizer("atr:5:0.01")
+	for n := 1; n <= len(bars); n++ {
+		for i := 0; i < 3; i++ { // maxBuy asks several times a bar
+			if got, want := s.Size(SizeRequest{Ticker: "A", Price: 100, Equity: 10000, Bars: bars[:n]}), fresh(n); got != want {
+				t.Fatalf("bar %d: Size = %v, want %v", n, got, want)
+			}
+		}
+	}
+	if r := s.(*ATRRisk).ranges["A"]; r.fed != len(bars) {
+		t.Errorf("fed %d bars, want %d", r.fed, len(bars))
+	}
+	other := SizeRequest{Ticker: "A", Price: 100, Equity: 10000, Bars: bars[10:20]}
+	restarted, _ := NewSizer("atr:5:0.01")
+	if got, want := s.Size(other), restarted.Size(other); got != want {
+		t.Errorf("another series: Size = %v, want %v", got, want)
+	}
+}
+
+// maxBuy sizes from equity, positions included, but never spends more
+// than the cash on hand.
+func TestMaxBuy_Sizer(t *testing.T) {
+	p := newPropPortfolio(1000, AccountingFloat)
+	p.Tickers = []string{"A", "B"}
+	p.Positions["B"] = &Position{Amount: 100, AveragePrice: 40, CurrentPrice: 40}
+	if got := p.maxBuy("A", 10, "fixedFraction:0.1"); got != 50 {
+		t.Errorf("fixedFraction:0.1 of $5000 at $10 = %v, want 50", got)
+	}
+	if got := p.maxBuy("A", 10, "fixedFraction:0.5"); got != 100 {
+		t.Errorf("fixedFraction:0.5 with $1000 cash = %v, want 100", got)
+	}
+	if got := p.maxBuy("A", 10, "nonsense"); got != 0 {
+		t.Errorf("unknown sizer bought %v", got)
+	}
+}
+
+func TestSizerSpecs(t *testing.T) {
+	for spec, want := range map[string]string{
+		"smaCross:2:3:fixedFraction:0.5":   "smaCross:2:3:fixedFraction:0.5",
+		"bollinger:20:2:atr:14:0.01":       "bollinger:20:2:atr:14:0.01",
+		"zscore:20:2:0.5:volTarget:0.1:20": "zscore:20:2:0.5:volTarget:0.1:20",
+		"buyAndHold:kelly:0.5:0.1":         "buyAndHold:kelly:0.5:0.1",
+		"fixedDollar:500":                  "buyAndHold:fixedDollar:500",
+		"dca:fixedDollar:500":              "dca:fixedDollar:500",
+	} {
+		s, err := NewStrategy(spec, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if s.Name() != want {
+			t.Errorf("%s: Name() = %q, want %q", spec, s.Name(), want)
+		}
+	}
+	for _, bad := range []string{"smaCross:2:3:fixedFraction", "fixedDollar:x", "buyAndHold:atr:14"} {
+		if _, err := NewStrategy(bad, nil); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
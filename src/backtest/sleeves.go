@@ -0,0 +1,301 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sleeve is one of several strategies trading a share of a portfolio's
+// capital (see WithSleeves).
+type Sleeve struct {
+	Strategy string         `toml:"Strategy"`
+	Params   map[string]any `toml:"Params"`
+	// Weight is the sleeve's share of the capital, relative to the
+	// other sleeves' weights.
+	Weight float64 `toml:"Weight"`
+}
+
+// SleeveStats is one sleeve's part in a portfolio's run: its strategy,
+// its share of the capital, its Contribution to the portfolio's return
+// in percentage points, and the Metrics of its own capital.
+type SleeveStats struct {
+	Strategy     string
+	Params       map[string]any
+	Weight       float64 // normalized to sum to 1
+	Contribution float64
+	Metrics      Metrics
+}
+
+// WithSleeves splits the portfolio's capital among several strategies,
+// by weight, in place of its one strategy. Each sleeve trades the
+// portfolio's tickers over its window as an account of its own, with
+// its share of the buying power and of any contributions, under the
+// portfolio's fills, costs, risk rules and margin. The portfolio's
+// equity curve, trades and metrics are the sleeves' combined, and
+// Result.Sleeves attributes its return among them.
+func WithSleeves(sleeves ...Sleeve) Option {
+	return func(p *Portfolio) error {
+		if len(sleeves) < 2 {
+			return fmt.Errorf("sleeves: need at least 2, got %d", len(sleeves))
+		}
+		for i, s := range sleeves {
+			if !(s.Weight > 0) {
+				return fmt.Errorf("sleeve %d weight %v: must be positive", i+1, s.Weight)
+			}
+			if _, err := NewStrategy(s.Strategy, s.Params); err != nil {
+				return fmt.Errorf("sleeve %d: %w", i+1, err)
+			}
+		}
+		p.Sleeves = sleeves
+		return nil
+	}
+}
+
+// sleeveStrategy stands in for the strategy of a portfolio with
+// Sleeves, which runOne runs through runSleeves instead.
+type sleeveStrategy struct{ sleeves []Sleeve }
+
+// Name lists the sleeves' strategies and weights, e.g.
+// "sleeves(smaCross:20:50:equalWeights 60%, momentum 40%)".
+func (s *sleeveStrategy) Name() string {
+	total := 0.0
+	for _, sl := range s.sleeves {
+		total += sl.Weight
+	}
+	parts := make([]string, len(s.sleeves))
+	for i, sl := range s.sleeves {
+		parts[i] = sl.Strategy + " " + strconv.FormatFloat(100*sl.Weight/total, 'f', -1, 64) + "%"
+	}
+	return "sleeves(" + strings.Join(parts, ", ") + ")"
+}
+
+func (s *sleeveStrategy) Step(*Portfolio, map[string][]data.AssetData, int) {}
+
+// newStrategy builds p's strategy: from StrategySpec, or the stand-in
+// for its Sleeves.
+func (p *Portfolio) newStrategy() (Strategy, error) {
+	if len(p.Sleeves) > 0 {
+		if p.StrategySpec != "" {
+			return nil, fmt.Errorf("strategy %q: a portfolio with sleeves has none of its own", p.StrategySpec)
+		}
+		return &sleeveStrategy{p.Sleeves}, nil
+	}
+	return NewStrategy(p.StrategySpec, p.StrategyParams)
+}
+
+// addPosition adds pos, a sleeve's position in ticker, to p's. Holdings
+// on the same side pool: the amounts, fees and lots add up and the
+// average price is weighted by cost. A long in one sleeve and a short in
+// another net to the larger, its fees and lots scaled to what is left; a
+// position netting to nothing is dropped.
+func (p *Portfolio) addPosition(ticker string, pos *Position) {
+	if pos.Amount == 0 {
+		return
+	}
+	q, ok := p.Positions[ticker]
+	if !ok {
+		cp := *pos
+		cp.Lots = append([]Lot(nil), pos.Lots...)
+		p.Positions[ticker] = &cp
+		return
+	}
+	net := q.Amount + pos.Amount
+	if net == 0 {
+		delete(p.Positions, ticker)
+		return
+	}
+	if pos.CurrentPrice != 0 {
+		q.CurrentPrice = pos.CurrentPrice
+	}
+	if (q.Amount > 0) == (pos.Amount > 0) {
+		q.AveragePrice = (q.Amount*q.AveragePrice + pos.Amount*pos.AveragePrice) / net
+		q.Fees += pos.Fees
+		if pos.Opened.Before(q.Opened) {
+			q.Opened = pos.Opened
+		}
+		q.Lots = append(q.Lots, pos.Lots...)
+		sort.SliceStable(q.Lots, func(i, j int) bool { return q.Lots[i].Opened.Before(q.Lots[j].Opened) })
+		q.Amount = net
+		return
+	}
+	larger := q
+	if math.Abs(pos.Amount) > math.Abs(q.Amount) {
+		larger = pos
+	}
+	keep := math.Abs(net / larger.Amount)
+	lots := make([]Lot, len(larger.Lots))
+	for i, l := range larger.Lots {
+		l.Amount *= keep
+		l.Fees *= keep
+		lots[i] = l
+	}
+	*q = Position{
+		Amount: net, AveragePrice: larger.AveragePrice, CurrentPrice: q.CurrentPrice,
+		Opened: larger.Opened, Fees: larger.Fees * keep, Lots: lots,
+	}
+}
+
+// newSleeves builds a clone of p for each of its Sleeves, with the
+// sleeve's strategy and its share of the capital and contributions.
+// Clone calls it, so a sleeve that can't be built fails the portfolio's
+// clone rather than its run.
+func (p *Portfolio) newSleeves() ([]*Portfolio, error) {
+	total := 0.0
+	for _, s := range p.Sleeves {
+		total += s.Weight
+	}
+	runs := make([]*Portfolio, len(p.Sleeves))
+	for i, s := range p.Sleeves {
+		w := s.Weight / total
+		v := *p
+		v.Sleeves, v.StrategySpec, v.StrategyParams = nil, s.Strategy, s.Params
+		v.InitialBuyingPower = p.InitialBuyingPower * w
+		if p.Contributions != nil {
+			c := *p.Contributions
+			c.Amount *= w
+			v.Contributions = &c
+		}
+		c, err := v.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("sleeve %d (%s): %w", i+1, s.Strategy, err)
+		}
+		c.MonteCarloConfig, c.SignificanceConfig, c.RandomEntry = nil, nil, nil
+		runs[i] = c
+	}
+	return runs, nil
+}
+
+// runSleeves runs each of p's sleeves (see newSleeves) over hist, one
+// after another, and combines them into p as runOne would have left it.
+// It reports false if ctx was done first.
+func (p *Portfolio) runSleeves(
+	ctx context.Context,
+	hist map[string][]data.AssetData,
+	riskFreeRates map[int64]float64,
+) bool {
+	total := 0.0
+	for _, s := range p.Sleeves {
+		total += s.Weight
+	}
+	runs := p.sleeves
+	if runs == nil {
+		var err error
+		if runs, err = p.newSleeves(); err != nil {
+			// Clone builds the sleeves, so only a portfolio that was never
+			// cloned gets here.
+			logger.Error("sleeves", "portfolio", p.Pname, "err", err)
+			return false
+		}
+	}
+	p.sleeves = nil
+	for _, c := range runs {
+		c.store, c.actions, c.metadata, c.trials = p.store, p.actions, p.metadata, p.trials
+		if !runOne(ctx, c, hist, riskFreeRates) {
+			return false
+		}
+	}
+
+	// Each day's return is the sleeves' gain over the value they started
+	// it with, outside cash included, which each one's own return
+	// recovers from its close value.
+	lead := runs[0]
+	for _, r := range runs {
+		if len(r.DailyReturns) > len(lead.DailyReturns) {
+			lead = r
+		}
+	}
+	for t, dr := range lead.DailyReturns {
+		value, base := 0.0, 0.0
+		for _, r := range runs {
+			n := len(r.PortfolioCloseValues)
+			switch {
+			case t < n && r.DailyReturns[t].Return != -1:
+				v := r.PortfolioCloseValues[t]
+				value, base = value+v, base+v/(1+r.DailyReturns[t].Return)
+			case t < n:
+				value += r.PortfolioCloseValues[t]
+			case n > 0:
+				value, base = value+r.PortfolioCloseValues[n-1], base+r.PortfolioCloseValues[n-1]
+			}
+		}
+		ret := 0.0
+		if base > 0 {
+			ret = (value - base) / base
+		}
+		p.DailyReturns = append(p.DailyReturns, DailyReturn{Date: dr.Date, Return: ret})
+		p.PortfolioCloseValues = append(p.PortfolioCloseValues, value)
+	}
+
+	cash := 0.0
+	p.sleeveParts = make([]SleeveStats, len(runs))
+	p.books = make(map[bookKey]float64)
+	for i, r := range runs {
+		p.Trades = append(p.Trades, r.Trades...)
+		p.ClosedTrades = append(p.ClosedTrades, r.ClosedTrades...)
+		p.CashFlows = append(p.CashFlows, r.CashFlows...)
+		for k, v := range r.bookPnL() {
+			p.books[k] += v
+		}
+		p.addSectors(r.sectors, 0)
+		netFlow := 0.0
+		for _, f := range r.CashFlows {
+			netFlow += f.Amount
+		}
+		for _, t := range sortedKeys(r.Positions) {
+			p.addPosition(t, r.Positions[t])
+		}
+		cash += r.BuyingPower
+		if p.EffectiveStart.IsZero() || r.EffectiveStart.Before(p.EffectiveStart) {
+			p.EffectiveStart = r.EffectiveStart
+		}
+		if r.EffectiveEnd.After(p.EffectiveEnd) {
+			p.EffectiveEnd = r.EffectiveEnd
+		}
+		if p.Lookahead == nil {
+			p.Lookahead = r.Lookahead
+		}
+		if !r.Halted.IsZero() && (p.Halted.IsZero() || r.Halted.Before(p.Halted)) {
+			p.Halted = r.Halted
+		}
+		final := r.InitialBuyingPower
+		if n := len(r.PortfolioCloseValues); n > 0 {
+			final = r.PortfolioCloseValues[n-1]
+		}
+		s := p.Sleeves[i]
+		p.sleeveParts[i] = SleeveStats{
+			Strategy: s.Strategy, Params: s.Params, Weight: s.Weight / total,
+			Contribution: 100 * (final - r.InitialBuyingPower - netFlow) / p.InitialBuyingPower,
+			Metrics:      r.Metrics,
+		}
+		logger.Info("sleeve", "portfolio", p.Pname, "strategy", s.Strategy, "weight", s.Weight/total,
+			"contribution", p.sleeveParts[i].Contribution, "sharpe", r.Metrics.SharpeRatio)
+	}
+	p.adjustCash(cash - p.BuyingPower)
+	sort.SliceStable(p.Trades, func(i, j int) bool { return p.Trades[i].Date.Before(p.Trades[j].Date) })
+	sort.SliceStable(p.ClosedTrades, func(i, j int) bool { return p.ClosedTrades[i].Exit.Before(p.ClosedTrades[j].Exit) })
+	// The sleeves' deposits on a date are one deposit to the portfolio.
+	sort.SliceStable(p.CashFlows, func(i, j int) bool { return p.CashFlows[i].Date.Before(p.CashFlows[j].Date) })
+	flows := p.CashFlows[:0]
+	for _, f := range p.CashFlows {
+		if n := len(flows); n > 0 && flows[n-1].Date.Equal(f.Date) {
+			flows[n-1].Amount += f.Amount
+			continue
+		}
+		flows = append(flows, f)
+	}
+	p.CashFlows = flows
+
+	if p.RiskFree != nil {
+		riskFreeRates = p.RiskFree.RiskFreeRates(ctx, riskFreeStart(p.EffectiveStart), p.EffectiveEnd)
+	}
+	span := alignWindow(p.Tickers, hist, p.EffectiveStart, p.EffectiveEnd, p.Calendar.sessionFilter())
+	p.GetBacktestingData(riskFreeRates, span, len(span[p.Tickers[0]]))
+	p.Metrics.BenchmarkReturn = lead.Metrics.BenchmarkReturn
+	p.benchBars = lead.benchBars
+	return true
+}
@@ -0,0 +1,134 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Three quarters of the capital in a rising ticker and a quarter in a
+// flat one: the portfolio is the two sleeves added up, and the rising
+// one's sleeve contributes its whole return.
+func TestSleeves(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 30; i++ {
+		a := 10 + float64(i)
+		store.bars["A"] = append(store.bars["A"], data.AssetData{Date: day(i), Open: a, High: a, Low: a, Close: a, Volume: 100})
+		store.bars["B"] = append(store.bars["B"], data.AssetData{Date: day(i), Open: 10, High: 10, Low: 10, Close: 10, Volume: 100})
+		store.rates[day(i).Unix()] = 0
+	}
+	p, err := NewPortfolio("blend", 1000, []string{"A", "B"}, "", WithWindow(day(0), day(29)), WithSleeves(
+		Sleeve{Strategy: "rebalance:1000:A=100", Weight: 3},
+		Sleeve{Strategy: "rebalance:1000:B=100", Weight: 1},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("run: %v, %d results", err, len(results))
+	}
+	r := results[0]
+	if r.Strategy != "sleeves(rebalance:1000:A=100 75%, rebalance:1000:B=100 25%)" {
+		t.Errorf("strategy = %q", r.Strategy)
+	}
+	// 75 shares of A bought at 10 close at 39; B's 250 stays 250.
+	if n := len(r.EquityCurve); n != 29 || !closeTo(r.EquityCurve[n-1], 75*39+250) {
+		t.Fatalf("equity = %v, want 29 days ending at %v", r.EquityCurve, 75*39+250)
+	}
+	// Day 1's return is A's 10% on three quarters of the capital.
+	if !closeTo(r.Returns[0], 0.075) {
+		t.Errorf("first return = %v, want 0.075", r.Returns[0])
+	}
+	if len(r.Trades) != 2 || r.Trades[0].Ticker != "A" || r.Trades[1].Ticker != "B" {
+		t.Errorf("trades = %+v, want each sleeve's buy", r.Trades)
+	}
+	if len(r.Sleeves) != 2 {
+		t.Fatalf("sleeves = %+v", r.Sleeves)
+	}
+	a, b := r.Sleeves[0], r.Sleeves[1]
+	if a.Weight != 0.75 || !closeTo(a.Contribution, 217.5) || !closeTo(b.Contribution, 0) {
+		t.Errorf("attribution = %+v, %+v; want A's 217.5 points at 0.75 and none from B", a, b)
+	}
+	if at := r.Attribution; len(at.Strategies) != 2 || !closeTo(at.Strategies[0].Contribution, 217.5) ||
+		len(at.Tickers) != 2 || !closeTo(at.Tickers[0].Contribution, 217.5) {
+		t.Errorf("attribution = %+v; want A's 217.5 points by strategy and by ticker", at)
+	}
+	if b.Metrics.AnnualReturn != 0 || a.Metrics.SharpeRatio == 0 {
+		t.Errorf("sleeve metrics = %+v, %+v", a.Metrics, b.Metrics)
+	}
+
+	for _, tc := range []struct {
+		spec    string
+		sleeves []Sleeve
+	}{
+		{"", []Sleeve{{Strategy: "buyAndHold", Weight: 1}}},
+		{"", []Sleeve{{Strategy: "buyAndHold", Weight: 1}, {Strategy: "buyAndHold", Weight: 0}}},
+		{"", []Sleeve{{Strategy: "buyAndHold", Weight: 1}, {Strategy: "nope", Weight: 1}}},
+		{"buyAndHold", []Sleeve{{Strategy: "buyAndHold", Weight: 1}, {Strategy: "buyAndHold", Weight: 1}}},
+	} {
+		if _, err := NewPortfolio("p", 1000, []string{"A"}, tc.spec, WithSleeves(tc.sleeves...)); err == nil {
+			t.Errorf("%q with %+v: expected error", tc.spec, tc.sleeves)
+		}
+	}
+}
+
+// Sleeves holding the same ticker add up to one position at their
+// combined cost, with copies of their lots; opposite sides net.
+func TestAddPosition(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, i, 0, 0, 0, 0, time.UTC) }
+	p := newPropPortfolio(0, AccountingFloat)
+	first := &Position{Amount: 10, AveragePrice: 10, CurrentPrice: 12, Opened: day(5), Fees: 1,
+		Lots: []Lot{{Opened: day(5), Amount: 10, Price: 10, Fees: 1}}}
+	second := &Position{Amount: 30, AveragePrice: 14, CurrentPrice: 12, Opened: day(2), Fees: 3,
+		Lots: []Lot{{Opened: day(2), Amount: 30, Price: 14, Fees: 3}}}
+	p.addPosition("A", first)
+	p.addPosition("A", second)
+	got := p.Positions["A"]
+	if got.Amount != 40 || got.AveragePrice != 13 || got.Fees != 4 || !got.Opened.Equal(day(2)) {
+		t.Errorf("merged = %+v, want 40 at 13 with 4 in fees, opened on the 2nd", got)
+	}
+	if len(got.Lots) != 2 || !got.Lots[0].Opened.Equal(day(2)) {
+		t.Errorf("lots = %+v, want both, oldest first", got.Lots)
+	}
+	got.Lots[1].Amount = 0
+	if first.Lots[0].Amount != 10 || first.Amount != 10 {
+		t.Error("the merged position shares the sleeve's lots")
+	}
+
+	p.addPosition("A", &Position{Amount: -20, AveragePrice: 11, Fees: 2})
+	if got := p.Positions["A"]; got.Amount != 20 || got.AveragePrice != 13 || got.Fees != 2 {
+		t.Errorf("netted = %+v, want 20 left at 13 with half the fees", got)
+	}
+	p.addPosition("A", &Position{Amount: -20, AveragePrice: 11})
+	p.addPosition("B", &Position{})
+	if len(p.Positions) != 0 {
+		t.Errorf("positions = %v, want the flat ones dropped", p.Positions)
+	}
+}
+
+// A sleeve that can't be built fails the portfolio's Clone.
+func TestSleeves_CloneError(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "s.lua")
+	if err := os.WriteFile(script, []byte("function step(day) end"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "", WithSleeves(
+		Sleeve{Strategy: "buyAndHold:greedy", Weight: 1},
+		Sleeve{Strategy: "lua:" + script, Weight: 1},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(script); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Clone(); err == nil || !strings.Contains(err.Error(), "sleeve 2") {
+		t.Errorf("clone err = %v, want sleeve 2's", err)
+	}
+}
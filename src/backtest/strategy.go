@@ -3,6 +3,7 @@ package backtest
 import (
 	"fmt"
 	"my-backtester/src/data"
+	"my-backtester/src/indicators"
 	"strconv"
 	"strings"
 )
@@ -18,26 +19,61 @@ type Strategy interface {
 }
 
 // NewStrategy builds a Strategy from a spec string and optional typed
-// params. Formats:
-//   - "greedy" / "equalWeights"          -> BuyAndHold with that buy type
-//   - "buyAndHold:<buyType>"             -> BuyAndHold
-//   - "smaCross:<short>:<long>:<buyType>" -> SMACross
-//   - "lua:<path>"                       -> LuaStrategy (params from arg)
+// params. The spec's name, up to the first ':', selects a registered
+// strategy (see RegisterStrategy) and the rest is its argument; a sizer
+// spec on its own is BuyAndHold with that sizer. `backtester list
+// strategies` prints every registered spec format.
 func NewStrategy(spec string, params map[string]any) (Strategy, error) {
-	parts := strings.SplitN(spec, ":", 2)
-	switch parts[0] {
-	case "greedy", "equalWeights":
-		return &BuyAndHold{BuyType: parts[0]}, nil
-	case "buyAndHold":
-		if len(parts) < 2 {
-			return nil, fmt.Errorf("buyAndHold spec needs a buy type: %q", spec)
+	name, arg, _ := strings.Cut(spec, ":")
+	if _, ok := Lookup(KindSizer, name); ok {
+		if err := checkSizer(spec); err != nil {
+			return nil, err
 		}
-		return &BuyAndHold{BuyType: parts[1]}, nil
-	case "smaCross":
-		sub := strings.Split(parts[1], ":")
+		return &BuyAndHold{BuyType: spec}, nil
+	}
+	registry.RLock()
+	f, ok := registry.strategies[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf(
+			"unknown strategy spec %q: must start with one of %s, or be a sizer (%s)",
+			spec, componentNames(KindStrategy), componentNames(KindSizer),
+		)
+	}
+	return f(arg, params)
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "buyAndHold",
+		Usage: "buyAndHold[:<sizer>]",
+		Doc:   "buys every ticker on the first bar and holds",
+		Params: []Param{
+			{Name: "sizer", Type: "sizer", Default: "greedy", Doc: "how buying power is split"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		if arg == "" {
+			arg = "greedy"
+		}
+		if err := checkSizer(arg); err != nil {
+			return nil, fmt.Errorf("buyAndHold: %w", err)
+		}
+		return &BuyAndHold{BuyType: arg}, nil
+	})
+	RegisterStrategy(Component{
+		Name:  "smaCross",
+		Usage: "smaCross:<short>:<long>:<sizer>",
+		Doc:   "buys when the short SMA crosses above the long one, sells on the cross below",
+		Params: []Param{
+			{Name: "short", Type: "int", Doc: "fast SMA period, in bars"},
+			{Name: "long", Type: "int", Doc: "slow SMA period, in bars"},
+			{Name: "sizer", Type: "sizer", Doc: "how buying power is split"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		sub := strings.SplitN(arg, ":", 3)
 		if len(sub) < 3 {
 			return nil, fmt.Errorf(
-				"smaCross spec needs short:long:buyType: %q", spec,
+				"smaCross spec needs short:long:sizer: %q", "smaCross:"+arg,
 			)
 		}
 		short, err := strconv.Atoi(sub[0])
@@ -48,17 +84,21 @@ func NewStrategy(spec string, params map[string]any) (Strategy, error) {
 		if err != nil {
 			return nil, fmt.Errorf("smaCross long period: %w", err)
 		}
-		return &SMACross{Short: short, Long: long, BuyType: sub[2]}, nil
-	case "lua":
-		if len(parts) < 2 || parts[1] == "" {
-			return nil, fmt.Errorf("lua spec needs a script path: %q", spec)
+		if short <= 0 || long <= 0 {
+			return nil, fmt.Errorf("smaCross periods must be positive: %d, %d", short, long)
 		}
-		return NewLuaStrategy(parts[1], params)
-	}
-	return nil, fmt.Errorf("unknown strategy spec: %q", spec)
+		if err := checkSizer(sub[2]); err != nil {
+			return nil, fmt.Errorf("smaCross: %w", err)
+		}
+		return &SMACross{Short: short, Long: long, BuyType: sub[2]}, nil
+	})
 }
 
+// SMA is the mean Close of stocks, or 0 for an empty slice.
 func SMA(stocks []data.AssetData) float64 {
+	if len(stocks) == 0 {
+		return 0
+	}
 	var mean float64
 	for _, stock := range stocks {
 		mean += stock.Close
@@ -67,26 +107,33 @@ func SMA(stocks []data.AssetData) float64 {
 	return mean
 }
 
+// RSI is the relative strength index of closeValues, a series of
+// day-over-day Close changes, averaged over rsPeriod. With no down days
+// it is 100, and with no changes at all (or a non-positive period) it is
+// the neutral 50.
+//
+// It takes plain averages; indicators.RSI is Wilder's smoothed RSI,
+// which the Lua rsi() helper uses.
 func RSI(closeValues []float64, rsPeriod float64) float64 {
-	upDays := make([]float64, 0)
-	downDays := make([]float64, 0)
+	if rsPeriod <= 0 {
+		return 50
+	}
 	upDayRs, downDayRs := 0.0, 0.0
-
-	for i := range closeValues {
-		if closeValues[i] >= 0 {
-			upDays = append(upDays, closeValues[i])
+	for _, change := range closeValues {
+		if change >= 0 {
+			upDayRs += change
 		} else {
-			downDays = append(downDays, closeValues[i])
+			downDayRs -= change
 		}
 	}
-	for _, value := range upDays {
-		upDayRs += value
-	}
 	upDayRs /= rsPeriod
-	for _, value := range downDays {
-		downDayRs += value
-	}
 	downDayRs /= rsPeriod
+	if downDayRs == 0 {
+		if upDayRs == 0 {
+			return 50
+		}
+		return 100
+	}
 	rs := upDayRs / downDayRs
 
 	return 100 - (100 / (1 + rs))
@@ -104,25 +151,29 @@ func (s *BuyAndHold) Step(
 	if day != 0 {
 		return
 	}
-	for _, ticker := range p.Tickers {
-		td := hist[ticker]
-		if len(td) == 0 {
+	for _, ticker := range p.Context(hist, 0).Universe() {
+		price := p.FillPrice(ticker, hist, 0)
+		if price <= 0 {
 			continue
 		}
-		price := td[0].Close
-		amount := generalBuy(p.BuyingPower, price, s.BuyType, p.Tickers)
-		p.Buy(ticker, amount, price, td[0].Date)
+		amount := p.maxBuy(ticker, price, s.BuyType)
+		p.Order(ticker, "BUY", amount, hist, 0)
 	}
 }
 
 type SMACross struct {
 	Short, Long int
 	BuyType     string
-	prevShort   map[string]float64
-	prevLong    map[string]float64
-	sumShort    map[string]float64
-	sumLong     map[string]float64
-	havePrev    map[string]bool
+	averages    map[string]*smaPair
+}
+
+// smaPair is one ticker's short and long averages, fed every Close up to
+// the bar before the one being stepped.
+type smaPair struct {
+	short, long         *indicators.SMA
+	fed                 int // bars fed so far
+	prevShort, prevLong float64
+	havePrev            bool
 }
 
 func (s *SMACross) Name() string {
@@ -135,72 +186,49 @@ func (s *SMACross) Step(
 	if day < s.Long {
 		return
 	}
-	if s.prevShort == nil {
-		s.prevShort = make(map[string]float64, len(p.Tickers))
-		s.prevLong = make(map[string]float64, len(p.Tickers))
-		s.sumShort = make(map[string]float64, len(p.Tickers))
-		s.sumLong = make(map[string]float64, len(p.Tickers))
-		s.havePrev = make(map[string]bool, len(p.Tickers))
+	if s.averages == nil {
+		s.averages = make(map[string]*smaPair, len(p.Tickers))
 	}
-	for _, ticker := range p.Tickers {
+	for _, ticker := range p.Context(hist, day).tradable() {
 		td := hist[ticker]
 		if day >= len(td) {
 			continue
 		}
-		var sShort, sLong float64
-		if _, seeded := s.sumShort[ticker]; seeded {
-			sShort = s.sumShort[ticker] - td[day-s.Short-1].Close + td[day-1].Close
-			sLong = s.sumLong[ticker] - td[day-s.Long-1].Close + td[day-1].Close
-		} else {
-			for i := day - s.Short; i < day; i++ {
-				sShort += td[i].Close
-			}
-			for i := day - s.Long; i < day; i++ {
-				sLong += td[i].Close
-			}
+		a := s.averages[ticker]
+		if a == nil {
+			a = &smaPair{short: indicators.NewSMA(s.Short), long: indicators.NewSMA(s.Long)}
+			s.averages[ticker] = a
+		}
+		// Each Close is added once, so a step costs O(1) whatever the
+		// periods.
+		for ; a.fed < day; a.fed++ {
+			a.short.Update(td[a.fed].Close)
+			a.long.Update(td[a.fed].Close)
 		}
-		s.sumShort[ticker] = sShort
-		s.sumLong[ticker] = sLong
-		smaShort := sShort / float64(s.Short)
-		smaLong := sLong / float64(s.Long)
-
-		if s.havePrev[ticker] {
-			currentDayData := td[day]
-			avg := (currentDayData.Low +
-				currentDayData.High + currentDayData.Close) / 3.0
-			if smaShort > smaLong && s.prevShort[ticker] <= s.prevLong[ticker] {
-				amount := generalBuy(p.BuyingPower, avg, s.BuyType, p.Tickers)
-				p.Buy(ticker, amount, avg, currentDayData.Date)
-			} else if smaShort < smaLong && s.prevShort[ticker] >= s.prevLong[ticker] {
+		smaShort, smaLong := a.short.Value(), a.long.Value()
+
+		if a.havePrev {
+			if smaShort > smaLong && a.prevShort <= a.prevLong {
+				price := p.FillPrice(ticker, hist, day)
+				amount := p.maxBuy(ticker, price, s.BuyType)
+				p.Order(ticker, "BUY", amount, hist, day)
+			} else if smaShort < smaLong && a.prevShort >= a.prevLong {
 				if pos, _ := p.FindPosition(ticker); pos != nil {
-					p.Sell(ticker, pos.Amount, avg, currentDayData.Date)
+					p.Order(ticker, "SELL", pos.Amount, hist, day)
 				}
 			}
 		}
-		s.prevShort[ticker] = smaShort
-		s.prevLong[ticker] = smaLong
-		s.havePrev[ticker] = true
+		a.prevShort, a.prevLong, a.havePrev = smaShort, smaLong, true
 	}
 }
 
-func generalBuy(
-	buyingPower float64,
-	stockValue float64,
-	strategyType string,
-	tickers []string,
-) float64 {
-	amount := 0.0
-	switch strategyType {
-	case "greedy":
-		amount = float64(greedyBuy(buyingPower, stockValue))
-	case "equalWeights":
-		amount = float64(
-			greedyBuy(buyingPower/float64(len(tickers)), stockValue),
-		)
-	}
-	return amount
-}
-
+// greedyBuy returns the whole shares buyingPower affords at stockValue,
+// or 0 for a non-positive price or cash, or a ratio too large to be
+// exact — where int() of NaN or ±Inf would yield a huge negative count.
 func greedyBuy(buyingPower float64, stockValue float64) int {
-	return int(buyingPower / stockValue)
+	n := buyingPower / stockValue
+	if !(stockValue > 0) || !(n >= 0) || n > 1<<53 {
+		return 0
+	}
+	return int(n)
 }
@@ -0,0 +1,180 @@
+package backtest
+
+import (
+	"my-backtester/src/data"
+	"sort"
+	"time"
+)
+
+// StrategyContext is a strategy's view of the bar being processed: the
+// series it is simulated on, up to that bar, and the same tickers on
+// longer timeframes, so a strategy stepping on daily bars can, say, only
+// buy while the weekly trend is up. Longer bars are resampled from the
+// simulated ones (see data.Resample) and cut to the bars that had closed
+// by the current one: the week in progress isn't a weekly bar until its
+// last session. Get one in Step with p.Context(hist, day).
+type StrategyContext struct {
+	p    *Portfolio
+	hist map[string][]data.AssetData
+	day  int
+}
+
+// Context is the StrategyContext of bar day of hist, the arguments
+// Step was called with.
+func (p *Portfolio) Context(hist map[string][]data.AssetData, day int) StrategyContext {
+	return StrategyContext{p: p, hist: hist, day: day}
+}
+
+// Day is the index of the bar being processed.
+func (c StrategyContext) Day() int { return c.day }
+
+// Date is the bar being processed's date, or its time for intraday bars.
+func (c StrategyContext) Date() time.Time {
+	if len(c.p.Tickers) == 0 {
+		return time.Time{}
+	}
+	if lead := c.hist[c.p.Tickers[0]]; c.day < len(lead) {
+		return lead[c.day].Date
+	}
+	return time.Time{}
+}
+
+// Bars is ticker's simulated bars up to and including the current one.
+func (c StrategyContext) Bars(ticker string) []data.AssetData {
+	series := c.hist[ticker]
+	if n := c.day + 1; n < len(series) {
+		return series[:n:n]
+	}
+	return series
+}
+
+// Timeframe is ticker's bars on tf that had closed by the current bar,
+// oldest first, with returns between them: TimeframeDaily over 5-minute
+// bars, TimeframeWeekly or TimeframeMonthly over daily ones. tf the
+// portfolio's own bar length is Bars; one shorter than it, which can't
+// be recovered from the simulated bars, is nil.
+func (c StrategyContext) Timeframe(tf Timeframe, ticker string) []data.AssetData {
+	own, want := c.p.barRank(), tf.rank()
+	switch {
+	case want == own:
+		return c.Bars(ticker)
+	case want < own:
+		return nil
+	}
+	var series []data.AssetData
+	if c.p.sim != nil {
+		series = c.p.sim.get(tf, ticker)
+	} else {
+		series = data.Resample(c.hist[ticker], tf.viewPeriod())
+	}
+	now := c.Date()
+	n := sort.Search(len(series), func(i int) bool { return series[i].Date.After(now) })
+	return series[:n:n]
+}
+
+// Raw is ticker's bars up to and including the current one as stored,
+// without the adjustments PricesAdjusted trades on: the prices orders
+// actually filled at on each date. Under PricesRaw it is Bars.
+func (c StrategyContext) Raw(ticker string) []data.AssetData {
+	if c.p.raw == nil {
+		return c.Bars(ticker)
+	}
+	series := c.p.raw[ticker]
+	if n := c.day + 1; n < len(series) {
+		return series[:n:n]
+	}
+	return series
+}
+
+// Adjusted is Raw back-adjusted for ticker's splits and dividends (see
+// data.AdjustBars) as they were known at the current bar: later actions
+// don't reach back into it, so unlike PricesAdjusted's bars its past
+// prices only change on an ex-date.
+func (c StrategyContext) Adjusted(ticker string) []data.AssetData {
+	raw := c.Raw(ticker)
+	if len(raw) == 0 {
+		return raw
+	}
+	actions := c.p.actions[ticker]
+	now := raw[len(raw)-1].Date
+	k := sort.Search(len(actions), func(i int) bool { return actions[i].Date.After(now) })
+	if k == 0 {
+		return raw
+	}
+	// Adjusting the whole series for the actions so far leaves the bars
+	// up to now as adjusting just them would, so it is done once per
+	// action rather than per bar.
+	v, ok := c.p.adjusted[ticker]
+	if !ok || v.actions != k {
+		full := c.hist[ticker]
+		if c.p.raw != nil {
+			full = c.p.raw[ticker]
+		}
+		v = adjustedView{k, data.AdjustBars(full, actions[:k])}
+		if c.p.adjusted == nil {
+			c.p.adjusted = make(map[string]adjustedView)
+		}
+		c.p.adjusted[ticker] = v
+	}
+	n := len(raw)
+	return v.bars[:n:n]
+}
+
+// adjustedView is a ticker's bars adjusted for its first actions
+// actions.
+type adjustedView struct {
+	actions int
+	bars    []data.AssetData
+}
+
+// rank orders timeframes by bar length, intraday bars (0) first.
+func (tf Timeframe) rank() int {
+	switch tf {
+	case TimeframeWeekly:
+		return 2
+	case TimeframeMonthly:
+		return 3
+	}
+	return 1
+}
+
+// barRank is the rank of the bars p simulates.
+func (p *Portfolio) barRank() int {
+	if p.Interval > 0 && p.Timeframe == TimeframeDaily {
+		return 0
+	}
+	return p.Timeframe.rank()
+}
+
+// viewPeriod is the calendar.Period tf's bars span.
+func (tf Timeframe) viewPeriod() string {
+	if every := tf.period(); every != "" {
+		return every
+	}
+	return "day"
+}
+
+// timeframeViews caches the simulated series resampled to each
+// timeframe a strategy asks for, so a run resamples each ticker once.
+type timeframeViews struct {
+	hist  map[string][]data.AssetData
+	views map[Timeframe]map[string][]data.AssetData
+}
+
+func newTimeframeViews(hist map[string][]data.AssetData) *timeframeViews {
+	return &timeframeViews{hist: hist, views: make(map[Timeframe]map[string][]data.AssetData)}
+}
+
+func (v *timeframeViews) get(tf Timeframe, ticker string) []data.AssetData {
+	byTicker, ok := v.views[tf]
+	if !ok {
+		byTicker = make(map[string][]data.AssetData)
+		v.views[tf] = byTicker
+	}
+	series, ok := byTicker[ticker]
+	if !ok {
+		series = data.Resample(v.hist[ticker], tf.viewPeriod())
+		byTicker[ticker] = series
+	}
+	return series
+}
@@ -0,0 +1,77 @@
+package backtest
+
+import (
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestStrategyContext_Timeframe(t *testing.T) {
+	// Two weeks of daily bars from Monday 2024-01-01, closing at 1..10.
+	var bars []data.AssetData
+	for i := 0; i < 14; i++ {
+		d := time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		c := float64(len(bars) + 1)
+		bars = append(bars, data.AssetData{Date: d, Open: c, High: c, Low: c, Close: c})
+	}
+	hist := map[string][]data.AssetData{"A": bars}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sim := range []*timeframeViews{nil, newTimeframeViews(hist)} {
+		p.sim = sim
+		// Wednesday of the second week: only the first week has closed.
+		c := p.Context(hist, 7)
+		if got := c.Bars("A"); len(got) != 8 || cap(got) != 8 {
+			t.Errorf("Bars = %d bars, cap %d; want 8", len(got), cap(got))
+		}
+		weeks := c.Timeframe(TimeframeWeekly, "A")
+		if len(weeks) != 1 || weeks[0].Close != 5 || !weeks[0].Date.Equal(bars[4].Date) {
+			t.Errorf("weekly on %s = %+v, want the first week", c.Date().Format("2006-01-02"), weeks)
+		}
+		// Friday closes the second week.
+		if weeks := p.Context(hist, 9).Timeframe(TimeframeWeekly, "A"); len(weeks) != 2 || weeks[1].Return != 1 {
+			t.Errorf("weekly on Friday = %+v, want two weeks, the second up 100%%", weeks)
+		}
+		if got := c.Timeframe(TimeframeDaily, "A"); len(got) != 8 {
+			t.Errorf("daily view = %d bars, want the simulated ones", len(got))
+		}
+	}
+
+	p.Timeframe = TimeframeWeekly
+	if got := p.Context(hist, 1).Timeframe(TimeframeDaily, "A"); got != nil {
+		t.Errorf("daily view of weekly bars = %+v, want nil", got)
+	}
+}
+
+func TestStrategyContext_RawAndAdjusted(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC) }
+	raw := []data.AssetData{{Date: day(0), Close: 100}, {Date: day(1), Close: 100}, {Date: day(2), Close: 50}}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithPrices(PricesAdjusted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.actions = map[string][]data.CorporateAction{"A": {{Date: day(2), Split: 2}}}
+	p.raw = map[string][]data.AssetData{"A": raw}
+	hist := map[string][]data.AssetData{"A": data.AdjustBars(raw, p.actions["A"])}
+
+	// Before the split the adjusted series doesn't know of it.
+	before := p.Context(hist, 1)
+	if got := before.Adjusted("A"); len(got) != 2 || got[0].Close != 100 {
+		t.Errorf("adjusted before the split = %+v, want raw", got)
+	}
+	if got := before.Bars("A"); got[0].Close != 50 {
+		t.Errorf("simulated bars = %+v, want adjusted for the whole window", got)
+	}
+	after := p.Context(hist, 2)
+	if got := after.Adjusted("A"); len(got) != 3 || got[0].Close != 50 || got[2].Close != 50 {
+		t.Errorf("adjusted on the split = %+v, want every close 50", got)
+	}
+	if got := after.Raw("A"); len(got) != 3 || got[0].Close != 100 {
+		t.Errorf("raw = %+v, want as stored", got)
+	}
+}
@@ -0,0 +1,64 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+)
+
+// DCA (dollar-cost averaging) invests the starting cash on the first bar
+// and then every contribution on the bar it arrives, split across the
+// tickers by BuyType, and never sells. Pair it with a
+// ContributionSchedule; without one it is buy and hold.
+//
+// Spec format: "dca[:<sizer>]", e.g. "dca:equalWeights".
+type DCA struct {
+	BuyType string
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "dca",
+		Usage: "dca[:<sizer>]",
+		Doc:   "invests the starting cash and then each scheduled contribution as it arrives",
+		Params: []Param{
+			{Name: "sizer", Type: "sizer", Default: "equalWeights", Doc: "how each deposit is split"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		if arg == "" {
+			arg = "equalWeights"
+		}
+		if err := checkSizer(arg); err != nil {
+			return nil, fmt.Errorf("dca: %w", err)
+		}
+		return &DCA{BuyType: arg}, nil
+	})
+}
+
+func (s *DCA) Name() string { return "dca:" + s.BuyType }
+
+func (s *DCA) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if day > 0 && !(p.flow > 0) {
+		return
+	}
+	// Size every ticker's share from the cash before any of them buys.
+	tickers := p.Context(hist, day).Universe()
+	prices := make([]float64, len(tickers))
+	amounts := make([]float64, len(tickers))
+	for i, ticker := range tickers {
+		if prices[i] = p.FillPrice(ticker, hist, day); prices[i] > 0 {
+			amounts[i] = p.maxBuy(ticker, prices[i], s.BuyType)
+		}
+	}
+	for i, ticker := range tickers {
+		if amounts[i] <= 0 {
+			continue
+		}
+		amount := math.Min(amounts[i], p.maxBuy(ticker, prices[i], "greedy"))
+		if amount > 0 {
+			p.Order(ticker, "BUY", amount, hist, day)
+		}
+	}
+}
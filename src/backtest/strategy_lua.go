@@ -2,16 +2,19 @@ package backtest
 
 import (
 	"fmt"
-	"log"
+	"math/rand"
 	"my-backtester/src/data"
+	"my-backtester/src/indicators"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	lua "github.com/yuin/gopher-lua"
 )
 
 // LuaStrategy runs a user-supplied Lua script that handles control flow.
-// All numeric heavy-lifting (SMA, RSI, OHLCV lookups, order sizing) stays
+// All numeric heavy-lifting (indicators, OHLCV lookups, order sizing) stays
 // in Go and is exposed to the script as registered globals. The Lua state
 // is lazily constructed on the first Step call because Step is when the
 // strategy first sees the live portfolio and price history. Each Portfolio
@@ -42,6 +45,23 @@ func NewLuaStrategy(
 	return &LuaStrategy{Path: path, Params: params}, nil
 }
 
+func init() {
+	RegisterStrategy(Component{
+		Name:  "lua",
+		Usage: "lua:<path>",
+		Doc:   "runs a Lua script's step(day) on every bar",
+		Params: []Param{
+			{Name: "path", Type: "path", Doc: "script file"},
+			{Name: "Params", Type: "table", Doc: "any keys; the script's params global"},
+		},
+	}, func(arg string, params map[string]any) (Strategy, error) {
+		if arg == "" {
+			return nil, fmt.Errorf("lua spec needs a script path: %q", "lua:")
+		}
+		return NewLuaStrategy(arg, params)
+	})
+}
+
 func (s *LuaStrategy) Name() string { return "lua:" + s.Path }
 
 // Close releases the underlying lua.LState. Safe to call multiple times.
@@ -57,33 +77,60 @@ func (s *LuaStrategy) Step(
 ) {
 	if s.L == nil {
 		if err := s.init(p, hist); err != nil {
-			log.Printf("lua strategy %q init: %v", s.Path, err)
+			logger.Error("lua strategy init", "script", s.Path, "err", err)
 			return
 		}
 	}
+	if len(p.members) > 0 || p.spells != nil {
+		setTickers(s.L, p.Context(hist, day).tradable())
+	}
 	s.L.Push(s.stepFn)
 	s.L.Push(lua.LNumber(day))
 	if err := s.L.PCall(1, 0, nil); err != nil {
-		log.Printf("lua strategy %q step day=%d: %v", s.Path, day, err)
+		logger.Error("lua strategy step", "script", s.Path, "day", day, "err", err)
 	}
 }
 
+// setTickers sets the script's tickers global. Under a universe that
+// changes over the run (see UniverseConfig) Step sets it every bar to the
+// members, followed by any other tickers still held, so a script looping
+// over it only enters members but can still exit a ticker that left.
+func setTickers(L *lua.LState, tickers []string) {
+	tbl := L.CreateTable(len(tickers), 0)
+	for i, t := range tickers {
+		tbl.RawSetInt(i+1, lua.LString(t))
+	}
+	L.SetGlobal("tickers", tbl)
+}
+
 func (s *LuaStrategy) init(
 	p *Portfolio, hist map[string][]data.AssetData,
 ) error {
 	L := lua.NewState()
 
-	tickersTbl := L.CreateTable(len(p.Tickers), 0)
-	for i, t := range p.Tickers {
-		tickersTbl.RawSetInt(i+1, lua.LString(t))
-	}
-	L.SetGlobal("tickers", tickersTbl)
+	setTickers(L, p.Tickers)
 
 	L.SetGlobal("params", goToLua(L, s.Params))
 
-	registerIndicators(L, hist)
-	registerOHLCV(L, hist)
-	registerTrading(L, p, hist)
+	// Under the lookahead audit every helper that reads a bar checks it
+	// isn't past the one being processed and aborts the step if it is.
+	gate := func(L *lua.LState, ticker string, day int) {
+		if err := p.checkLookahead(ticker, day); err != nil {
+			L.RaiseError("%v", err)
+		}
+	}
+	registerIndicators(L, hist, gate)
+	registerOHLCV(L, hist, gate)
+	registerTrading(L, p, hist, gate)
+	registerMacro(L, p, hist, gate)
+	registerMetadata(L, p)
+	registerRandom(L, p)
+	// now() — the portfolio clock's time as Unix seconds: the bar's date
+	// in a backtest, wall-clock time when paper trading live.
+	L.SetGlobal("now", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(p.Now().Unix()))
+		return 1
+	}))
 
 	if err := L.DoFile(s.Path); err != nil {
 		L.Close()
@@ -127,9 +174,16 @@ func goToLua(L *lua.LState, v any) lua.LValue {
 		}
 		return t
 	case map[string]any:
+		// Insert in key order: a table's pairs() order follows insertion,
+		// so scripts iterating params behave the same on every run.
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
 		t := L.CreateTable(0, len(x))
-		for k, item := range x {
-			t.RawSetString(k, goToLua(L, item))
+		for _, k := range keys {
+			t.RawSetString(k, goToLua(L, x[k]))
 		}
 		return t
 	default:
@@ -137,67 +191,186 @@ func goToLua(L *lua.LState, v any) lua.LValue {
 	}
 }
 
+// luaGate checks a helper's bar read against the lookahead audit.
+type luaGate func(L *lua.LState, ticker string, day int)
+
 func registerIndicators(
-	L *lua.LState, hist map[string][]data.AssetData,
+	L *lua.LState, hist map[string][]data.AssetData, gate luaGate,
 ) {
-	// sma(ticker, day, period) — mean Close over [day-period, day).
+	// Indicators are computed incrementally by the indicators package,
+	// once per ticker and parameters, as scripts ask for later bars, so
+	// a call costs O(1) however long the period.
+	cache := make(map[string]*indicatorSeries)
+	series := func(
+		L *lua.LState, ticker string, day int, key string, start func() func(data.AssetData) []float64,
+	) []float64 {
+		gate(L, ticker, day)
+		bars := hist[ticker]
+		if day < 0 || day >= len(bars) {
+			return nil
+		}
+		key = ticker + "/" + key
+		s, ok := cache[key]
+		if !ok {
+			s = &indicatorSeries{update: start()}
+			cache[key] = s
+		}
+		for len(s.outs) <= day {
+			s.outs = append(s.outs, s.update(bars[len(s.outs)]))
+		}
+		return s.outs[day]
+	}
+	period := func(L *lua.LState, n int) int {
+		p := L.ToInt(n)
+		if p < 1 {
+			L.ArgError(n, "period must be at least 1")
+		}
+		return p
+	}
+	push := func(L *lua.LState, n int, out []float64) int {
+		if out == nil {
+			for i := 0; i < n; i++ {
+				L.Push(lua.LNil)
+			}
+			return n
+		}
+		for _, v := range out {
+			L.Push(lua.LNumber(v))
+		}
+		return n
+	}
+
+	// sma(ticker, day, period) — mean Close over [day-period, day), or 0
+	// without that much history.
 	L.SetGlobal("sma", L.NewFunction(func(L *lua.LState) int {
-		ticker := L.ToString(1)
-		day := L.ToInt(2)
-		period := L.ToInt(3)
-		series := hist[ticker]
-		if period <= 0 || day < period || day > len(series) {
+		ticker, day, n := L.ToString(1), L.ToInt(2), L.ToInt(3)
+		if n <= 0 {
 			L.Push(lua.LNumber(0))
 			return 1
 		}
-		sum := 0.0
-		for i := day - period; i < day; i++ {
-			sum += series[i].Close
+		out := series(L, ticker, day-1, fmt.Sprintf("sma/%d", n), func() func(data.AssetData) []float64 {
+			s := indicators.NewSMA(n)
+			return func(b data.AssetData) []float64 {
+				if v := s.Update(b.Close); s.Ready() {
+					return []float64{v}
+				}
+				return nil
+			}
+		})
+		if out == nil {
+			out = []float64{0}
 		}
-		L.Push(lua.LNumber(sum / float64(period)))
-		return 1
+		return push(L, 1, out)
 	}))
 
-	// rsi(ticker, day, period) — Wilder-lite RSI on Close changes over
-	// the trailing `period` days ending at `day`. Returns 50 if there is
-	// not enough history yet, 100 if there have been no losses.
+	// The rest read Closes (or bars) up to and including `day` and
+	// return nil until they have enough history.
+
+	// rsi(ticker, day, period) — Wilder's RSI of Close changes up to
+	// `day`. Returns 50 if there is not enough history yet or prices were
+	// flat, 100 if there have been no losses.
 	L.SetGlobal("rsi", L.NewFunction(func(L *lua.LState) int {
-		ticker := L.ToString(1)
-		day := L.ToInt(2)
-		period := L.ToInt(3)
-		series := hist[ticker]
-		if period <= 0 || day < period || day >= len(series) {
-			L.Push(lua.LNumber(50))
-			return 1
+		ticker, day, n := L.ToString(1), L.ToInt(2), period(L, 3)
+		out := series(L, ticker, day, fmt.Sprintf("rsi/%d", n), func() func(data.AssetData) []float64 {
+			r := indicators.NewRSI(n)
+			return func(b data.AssetData) []float64 { return []float64{r.Update(b.Close)} }
+		})
+		if out == nil {
+			out = []float64{50}
 		}
-		gain, loss := 0.0, 0.0
-		for i := day - period + 1; i <= day; i++ {
-			change := series[i].Close - series[i-1].Close
-			if change >= 0 {
-				gain += change
-			} else {
-				loss -= change
+		return push(L, 1, out)
+	}))
+
+	// ema(ticker, day, period) — exponential moving average of Close.
+	L.SetGlobal("ema", L.NewFunction(func(L *lua.LState) int {
+		ticker, day, n := L.ToString(1), L.ToInt(2), period(L, 3)
+		return push(L, 1, series(L, ticker, day, fmt.Sprintf("ema/%d", n), func() func(data.AssetData) []float64 {
+			e := indicators.NewEMA(n)
+			return func(b data.AssetData) []float64 {
+				if v := e.Update(b.Close); e.Ready() {
+					return []float64{v}
+				}
+				return nil
 			}
-		}
-		gain /= float64(period)
-		loss /= float64(period)
-		if loss == 0 {
-			L.Push(lua.LNumber(100))
-			return 1
-		}
-		rs := gain / loss
-		L.Push(lua.LNumber(100 - (100 / (1 + rs))))
-		return 1
+		}))
+	}))
+
+	// macd(ticker, day, fast, slow, signal) — MACD line, signal line and
+	// histogram of Close.
+	L.SetGlobal("macd", L.NewFunction(func(L *lua.LState) int {
+		ticker, day := L.ToString(1), L.ToInt(2)
+		fast, slow, signal := period(L, 3), period(L, 4), period(L, 5)
+		key := fmt.Sprintf("macd/%d/%d/%d", fast, slow, signal)
+		return push(L, 3, series(L, ticker, day, key, func() func(data.AssetData) []float64 {
+			m := indicators.NewMACD(fast, slow, signal)
+			return func(b data.AssetData) []float64 {
+				if line, sig, h := m.Update(b.Close); m.Ready() {
+					return []float64{line, sig, h}
+				}
+				return nil
+			}
+		}))
+	}))
+
+	// bbands(ticker, day, period, k) — middle, upper and lower Bollinger
+	// Bands of Close, k standard deviations wide.
+	L.SetGlobal("bbands", L.NewFunction(func(L *lua.LState) int {
+		ticker, day, n, k := L.ToString(1), L.ToInt(2), period(L, 3), float64(L.ToNumber(4))
+		return push(L, 3, series(L, ticker, day, fmt.Sprintf("bbands/%d/%g", n, k), func() func(data.AssetData) []float64 {
+			bb := indicators.NewBollinger(n, k)
+			return func(b data.AssetData) []float64 {
+				if mid, up, lo := bb.Update(b.Close); bb.Ready() {
+					return []float64{mid, up, lo}
+				}
+				return nil
+			}
+		}))
+	}))
+
+	// atr(ticker, day, period) — Wilder's average true range.
+	L.SetGlobal("atr", L.NewFunction(func(L *lua.LState) int {
+		ticker, day, n := L.ToString(1), L.ToInt(2), period(L, 3)
+		return push(L, 1, series(L, ticker, day, fmt.Sprintf("atr/%d", n), func() func(data.AssetData) []float64 {
+			a := indicators.NewATR(n)
+			return func(b data.AssetData) []float64 {
+				if v := a.Update(b.High, b.Low, b.Close); a.Ready() {
+					return []float64{v}
+				}
+				return nil
+			}
+		}))
+	}))
+
+	// stoch(ticker, day, k, d) — stochastic oscillator %K and %D.
+	L.SetGlobal("stoch", L.NewFunction(func(L *lua.LState) int {
+		ticker, day, k, d := L.ToString(1), L.ToInt(2), period(L, 3), period(L, 4)
+		return push(L, 2, series(L, ticker, day, fmt.Sprintf("stoch/%d/%d", k, d), func() func(data.AssetData) []float64 {
+			st := indicators.NewStochastic(k, d)
+			return func(b data.AssetData) []float64 {
+				if pk, pd := st.Update(b.High, b.Low, b.Close); st.Ready() {
+					return []float64{pk, pd}
+				}
+				return nil
+			}
+		}))
 	}))
 }
 
+// indicatorSeries memoizes one indicator over a ticker's bars: outs[i]
+// holds its values as of bar i, nil before it is ready.
+type indicatorSeries struct {
+	update func(data.AssetData) []float64
+	outs   [][]float64
+}
+
 func registerOHLCV(
-	L *lua.LState, hist map[string][]data.AssetData,
+	L *lua.LState, hist map[string][]data.AssetData, gate luaGate,
 ) {
 	field := func(pick func(data.AssetData) float64) lua.LGFunction {
 		return func(L *lua.LState) int {
 			ticker := L.ToString(1)
 			day := L.ToInt(2)
+			gate(L, ticker, day)
 			series, ok := hist[ticker]
 			if !ok || day < 0 || day >= len(series) {
 				L.Push(lua.LNumber(0))
@@ -225,10 +398,17 @@ func registerOHLCV(
 	L.SetGlobal("price", L.NewFunction(
 		field(func(a data.AssetData) float64 { return a.Close }),
 	))
+	L.SetGlobal("return_at", L.NewFunction(
+		field(func(a data.AssetData) float64 { return a.Return }),
+	))
+	L.SetGlobal("log_return_at", L.NewFunction(
+		field(func(a data.AssetData) float64 { return a.LogReturn }),
+	))
 
 	L.SetGlobal("date_at", L.NewFunction(func(L *lua.LState) int {
 		ticker := L.ToString(1)
 		day := L.ToInt(2)
+		gate(L, ticker, day)
 		series, ok := hist[ticker]
 		if !ok || day < 0 || day >= len(series) {
 			L.Push(lua.LString(""))
@@ -240,12 +420,13 @@ func registerOHLCV(
 }
 
 func registerTrading(
-	L *lua.LState, p *Portfolio, hist map[string][]data.AssetData,
+	L *lua.LState, p *Portfolio, hist map[string][]data.AssetData, gate luaGate,
 ) {
 	dateOf := func(ticker string, day int) time.Time {
 		if day < 0 {
 			return time.Time{}
 		}
+		gate(L, ticker, day)
 		series, ok := hist[ticker]
 		if !ok || day >= len(series) {
 			return time.Time{}
@@ -273,48 +454,234 @@ func registerTrading(
 		return 1
 	}))
 
-	// buy_max(ticker, price, [buyType="equalWeights"], [day=-1])
-	// Sizes the order with generalBuy and submits it. Returns the share
-	// count it actually placed (caller can ignore).
+	// orderResult pushes the Lua-style result of an order: true, or
+	// false and the rejection reason.
+	orderResult := func(L *lua.LState, err error) int {
+		if err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LTrue)
+		return 1
+	}
+
+	// buy_max(ticker, price, [sizer="equalWeights"], [day=-1])
+	// Sizes the order with the sizer spec (see Sizer) and submits it.
+	// Returns the share count it actually placed (0 if rejected, plus the
+	// reason).
 	L.SetGlobal("buy_max", L.NewFunction(func(L *lua.LState) int {
 		ticker := L.ToString(1)
 		price := float64(L.ToNumber(2))
 		buyType := L.OptString(3, "equalWeights")
 		day := L.OptInt(4, -1)
-		amount := generalBuy(p.BuyingPower, price, buyType, p.Tickers)
-		p.Buy(ticker, amount, price, dateOf(ticker, day))
+		amount := p.maxBuy(ticker, price, buyType)
+		if err := p.Buy(ticker, amount, price, dateOf(ticker, day)); err != nil {
+			L.Push(lua.LNumber(0))
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
 		L.Push(lua.LNumber(amount))
 		return 1
 	}))
 
-	// buy(ticker, amount, price, [day=-1]) — caller-sized buy.
+	// buy(ticker, amount, price, [day=-1]) — caller-sized buy. Returns
+	// true, or false and the reason it was rejected.
 	L.SetGlobal("buy", L.NewFunction(func(L *lua.LState) int {
 		ticker := L.ToString(1)
 		amount := float64(L.ToNumber(2))
 		price := float64(L.ToNumber(3))
 		day := L.OptInt(4, -1)
-		p.Buy(ticker, amount, price, dateOf(ticker, day))
-		return 0
+		return orderResult(L, p.Buy(ticker, amount, price, dateOf(ticker, day)))
 	}))
 
-	// sell(ticker, amount, price, [day=-1]) — caller-sized sell.
+	// sell(ticker, amount, price, [day=-1]) — caller-sized sell. Returns
+	// like buy.
 	L.SetGlobal("sell", L.NewFunction(func(L *lua.LState) int {
 		ticker := L.ToString(1)
 		amount := float64(L.ToNumber(2))
 		price := float64(L.ToNumber(3))
 		day := L.OptInt(4, -1)
-		p.Sell(ticker, amount, price, dateOf(ticker, day))
-		return 0
+		return orderResult(L, p.Sell(ticker, amount, price, dateOf(ticker, day)))
+	}))
+
+	// fill_price(ticker, day) — the price an order placed on day fills at
+	// under the portfolio's FillPrice model (the close under next_open).
+	L.SetGlobal("fill_price", L.NewFunction(func(L *lua.LState) int {
+		ticker, day := L.ToString(1), L.ToInt(2)
+		gate(L, ticker, day)
+		L.Push(lua.LNumber(p.FillPrice(ticker, hist, day)))
+		return 1
+	}))
+
+	// order(ticker, side, amount, day) — places a "BUY" or "SELL" that
+	// fills per the portfolio's FillPrice model rather than at a price the
+	// script picks. Returns like buy.
+	L.SetGlobal("order", L.NewFunction(func(L *lua.LState) int {
+		ticker := L.ToString(1)
+		side := strings.ToUpper(L.ToString(2))
+		amount := float64(L.ToNumber(3))
+		day := L.ToInt(4)
+		if side != "BUY" && side != "SELL" {
+			L.ArgError(2, "side must be BUY or SELL")
+			return 0
+		}
+		return orderResult(L, p.Order(ticker, side, amount, hist, day))
 	}))
 
-	// sell_all(ticker, price, [day=-1]) — closes the entire position.
+	// submit(ticker, side, amount, day, {type=, limit=, stop=, trail=,
+	// expire=}) — rests a limit, stop, stop_limit, trailing_stop or
+	// market order from the next bar on (see Order). Returns the order's
+	// id for cancel, or nil and the rejection reason.
+	L.SetGlobal("submit", L.NewFunction(func(L *lua.LState) int {
+		o := Order{
+			Ticker: L.ToString(1),
+			Side:   strings.ToUpper(L.ToString(2)),
+			Amount: float64(L.ToNumber(3)),
+		}
+		day := L.ToInt(4)
+		if opts := L.OptTable(5, nil); opts != nil {
+			o.Type = OrderType(lua.LVAsString(opts.RawGetString("type")))
+			o.Limit = float64(lua.LVAsNumber(opts.RawGetString("limit")))
+			o.Stop = float64(lua.LVAsNumber(opts.RawGetString("stop")))
+			o.Trail = float64(lua.LVAsNumber(opts.RawGetString("trail")))
+			o.Expire = int(lua.LVAsNumber(opts.RawGetString("expire")))
+		}
+		id, err := p.Submit(o, hist, day)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LNumber(id))
+		return 1
+	}))
+
+	// cancel(id) — withdraws a submitted order; true if it was resting.
+	L.SetGlobal("cancel", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LBool(p.Cancel(L.ToInt(1))))
+		return 1
+	}))
+
+	// sell_all(ticker, price, [day=-1]) — closes the entire position,
+	// buying back a short one.
 	L.SetGlobal("sell_all", L.NewFunction(func(L *lua.LState) int {
 		ticker := L.ToString(1)
 		price := float64(L.ToNumber(2))
 		day := L.OptInt(3, -1)
-		if pos, _ := p.FindPosition(ticker); pos != nil {
-			p.Sell(ticker, pos.Amount, price, dateOf(ticker, day))
+		if pos, _ := p.FindPosition(ticker); pos != nil && pos.Amount < 0 {
+			return orderResult(L, p.Buy(ticker, -pos.Amount, price, dateOf(ticker, day)))
+		} else if pos != nil {
+			return orderResult(L, p.Sell(ticker, pos.Amount, price, dateOf(ticker, day)))
 		}
 		return 0
 	}))
 }
+
+// registerMacro exposes macro series ingested into macro_series (see
+// data.IngestMacro) for regime filters:
+//
+//	macro(series, day, [ticker=tickers[1]]) -> value or nil
+//
+// returns the latest observation published on or before the ticker's bar
+// at day (see data.MacroAsOf), so a script never sees a value released
+// after that bar, however early the period it is for. Each
+// series is loaded from the portfolio's store on first use; without one
+// every series is empty.
+func registerMacro(
+	L *lua.LState, p *Portfolio, hist map[string][]data.AssetData, gate luaGate,
+) {
+	loaded := make(map[string][]data.MacroPoint)
+	// through is the date each loaded series was queried up to; series
+	// that grow (feeds, the audit view) are reloaded once a bar passes it.
+	through := make(map[string]time.Time)
+	L.SetGlobal("macro", L.NewFunction(func(L *lua.LState) int {
+		name := L.ToString(1)
+		day := L.ToInt(2)
+		ticker := ""
+		if len(p.Tickers) > 0 {
+			ticker = p.Tickers[0]
+		}
+		ticker = L.OptString(3, ticker)
+		gate(L, ticker, day)
+		series := hist[ticker]
+		if day < 0 || day >= len(series) || p.store == nil {
+			L.Push(lua.LNil)
+			return 1
+		}
+		points, ok := loaded[name]
+		if !ok || series[day].Date.After(through[name]) {
+			through[name] = series[len(series)-1].Date
+			points = p.store.QueryMacro(p.context(), name, through[name])
+			loaded[name] = points
+		}
+		v, ok := data.MacroAsOf(points, series[day].Date)
+		if !ok {
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(lua.LNumber(v))
+		return 1
+	}))
+}
+
+// registerMetadata exposes the securities' metadata and the portfolio's
+// sector exposure (see StrategyContext.Metadata and SectorExposure):
+//
+//	metadata(ticker) -> {sector=, industry=, market_cap=} or nil
+//	sector_exposure(sector) -> fraction of equity
+func registerMetadata(L *lua.LState, p *Portfolio) {
+	c := p.Context(nil, 0)
+	L.SetGlobal("metadata", L.NewFunction(func(L *lua.LState) int {
+		m, ok := c.Metadata(L.CheckString(1))
+		if !ok {
+			L.Push(lua.LNil)
+			return 1
+		}
+		t := L.CreateTable(0, 3)
+		t.RawSetString("sector", lua.LString(m.Sector))
+		t.RawSetString("industry", lua.LString(m.Industry))
+		t.RawSetString("market_cap", lua.LNumber(m.MarketCap))
+		L.Push(t)
+		return 1
+	}))
+	L.SetGlobal("sector_exposure", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(c.SectorExposure(L.CheckString(1))))
+		return 1
+	}))
+}
+
+// registerRandom replaces math.random and math.randomseed with versions
+// drawing from the portfolio's seeded source (see Portfolio.Rand), so a
+// script's random choices repeat from run to run. Semantics match Lua's:
+// random() is in [0, 1), random(m) in [1, m] and random(m, n) in [m, n].
+func registerRandom(L *lua.LState, p *Portfolio) {
+	mathLib, ok := L.GetGlobal("math").(*lua.LTable)
+	if !ok {
+		return
+	}
+	mathLib.RawSetString("random", L.NewFunction(func(L *lua.LState) int {
+		r := p.Rand()
+		switch L.GetTop() {
+		case 0:
+			L.Push(lua.LNumber(r.Float64()))
+		case 1:
+			m := L.CheckInt(1)
+			if m < 1 {
+				L.ArgError(1, "interval is empty")
+			}
+			L.Push(lua.LNumber(1 + r.Intn(m)))
+		default:
+			m, n := L.CheckInt(1), L.CheckInt(2)
+			if m > n {
+				L.ArgError(2, "interval is empty")
+			}
+			L.Push(lua.LNumber(m + r.Intn(n-m+1)))
+		}
+		return 1
+	}))
+	mathLib.RawSetString("randomseed", L.NewFunction(func(L *lua.LState) int {
+		p.rng = rand.New(rand.NewSource(L.CheckInt64(1)))
+		return 0
+	}))
+}
@@ -2,8 +2,6 @@ package backtest
 
 import (
 	"fmt"
-	"io"
-	"log"
 	"math"
 	"math/rand"
 	"my-backtester/src/data"
@@ -25,12 +23,6 @@ const (
 	benchCash    = 100_000.0
 )
 
-func benchInit() {
-	if TransactionLogger == nil {
-		TransactionLogger = log.New(io.Discard, "", 0)
-	}
-}
-
 func generateBenchData() ([]string, map[string][]data.AssetData) {
 	tickers := make([]string, benchTickers)
 	hist := make(map[string][]data.AssetData, benchTickers)
@@ -55,6 +47,7 @@ func generateBenchData() ([]string, map[string][]data.AssetData) {
 				Volume: 1_000_000,
 			}
 		}
+		data.FillReturns(series)
 		hist[t] = series
 	}
 	return tickers, hist
@@ -113,7 +106,7 @@ func (s *benchSMACrossClose) Step(p *Portfolio, hist map[string][]data.AssetData
 		ps, pl := s.prevShort[ticker], s.prevLong[ticker]
 		if ps != 0 && pl != 0 {
 			if ss > ll && ps <= pl {
-				amount := generalBuy(p.BuyingPower, price, "equalWeights", p.Tickers)
+				amount := float64(greedyBuy(p.BuyingPower/float64(len(p.Tickers)), price))
 				p.Buy(ticker, amount, price, td[day].Date)
 			} else if ss < ll && ps >= pl {
 				if pos, _ := p.FindPosition(ticker); pos != nil {
@@ -126,7 +119,6 @@ func (s *benchSMACrossClose) Step(p *Portfolio, hist map[string][]data.AssetData
 }
 
 func BenchmarkSMACrossNative(b *testing.B) {
-	benchInit()
 	tickers, hist := generateBenchData()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -266,7 +258,7 @@ func newLuaBenchStrategy(
 	L.SetGlobal("buy", L.NewFunction(func(L *lua.LState) int {
 		ticker := L.ToString(1)
 		price := float64(L.ToNumber(2))
-		amount := generalBuy(p.BuyingPower, price, "equalWeights", p.Tickers)
+		amount := float64(greedyBuy(p.BuyingPower/float64(len(p.Tickers)), price))
 		p.Buy(ticker, amount, price, time.Time{})
 		return 0
 	}))
@@ -292,7 +284,6 @@ func newLuaBenchStrategy(
 }
 
 func benchLua(b *testing.B, useGoIndicators bool, script string) {
-	benchInit()
 	tickers, hist := generateBenchData()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
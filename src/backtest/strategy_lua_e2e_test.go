@@ -88,7 +88,6 @@ func TestE2EWallClock(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skip e2e wall-clock in -short mode")
 	}
-	benchInit()
 	tickers, hist := generateBenchData()
 	totalJobs := e2ePortfolios * e2eSimsPerPort
 	workers := runtime.NumCPU()
@@ -0,0 +1,81 @@
+package backtest
+
+import (
+	"math"
+	"my-backtester/src/data"
+	"my-backtester/src/indicators"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// The Lua indicator helpers agree with the indicators package fed the
+// same bars, including when a script skips days or looks back, and are
+// nil until the indicator has enough history.
+func TestLuaIndicators(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "ind.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  if day % 2 == 1 then return end
+  early = ema("A", 1, 3)
+  back = rsi("A", 2, 3)
+  e = ema("A", day, 3)
+  line, sig, hist = macd("A", day, 2, 3, 2)
+  mid, up, lo = bbands("A", day, 3, 2)
+  a = atr("A", day, 3)
+  k, d = stoch("A", day, 3, 2)
+  r = rsi("A", day, 3)
+  sm = sma("A", day, 3)
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var bars []data.AssetData
+	for i, c := range []float64{10, 11, 10.5, 12, 13, 12.5, 14, 13, 15} {
+		bars = append(bars, data.AssetData{
+			Date:  time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Close: c, High: c + 0.5, Low: c - 1,
+		})
+	}
+	hist := map[string][]data.AssetData{"A": bars}
+	p, err := NewPortfolio("p", 1000, []string{"A"}, "lua:"+script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := p.Strategy.(*LuaStrategy)
+	defer s.Close()
+	for day := range bars {
+		s.Step(p, hist, day)
+	}
+
+	ema, macd := indicators.NewEMA(3), indicators.NewMACD(2, 3, 2)
+	bb, atr := indicators.NewBollinger(3, 2), indicators.NewATR(3)
+	st, rsi := indicators.NewStochastic(3, 2), indicators.NewRSI(3)
+	want := map[string]float64{}
+	for _, b := range bars {
+		want["e"] = ema.Update(b.Close)
+		want["line"], want["sig"], want["hist"] = macd.Update(b.Close)
+		want["mid"], want["up"], want["lo"] = bb.Update(b.Close)
+		want["a"] = atr.Update(b.High, b.Low, b.Close)
+		want["k"], want["d"] = st.Update(b.High, b.Low, b.Close)
+		want["r"] = rsi.Update(b.Close)
+	}
+	// sma excludes the bar it is asked about.
+	n := len(bars)
+	want["sm"] = (bars[n-4].Close + bars[n-3].Close + bars[n-2].Close) / 3
+	for name, w := range want {
+		got, ok := s.L.GetGlobal(name).(lua.LNumber)
+		if !ok || math.Abs(float64(got)-w) > 1e-12 {
+			t.Errorf("%s = %v, want %v", name, s.L.GetGlobal(name), w)
+		}
+	}
+	if s.L.GetGlobal("early") != lua.LNil {
+		t.Errorf("ema on day 1 = %v, want nil before 3 bars", s.L.GetGlobal("early"))
+	}
+	if s.L.GetGlobal("back") != lua.LNumber(50) {
+		t.Errorf("rsi on day 2 = %v, want 50 before 4 closes", s.L.GetGlobal("back"))
+	}
+}
@@ -1,6 +1,7 @@
 package backtest
 
 import (
+	"context"
 	"math"
 	"math/rand"
 	"my-backtester/src/data"
@@ -52,6 +53,7 @@ func generateRealTestData(tickers []string, days int) (
 				Volume: 1_000_000,
 			}
 		}
+		data.FillReturns(series)
 		hist[ticker] = series
 	}
 	// Empty risk-free rates is fine — Sharpe/Sortino fall through to 0.
@@ -65,7 +67,6 @@ func newRealTestPortfolio(
 	spec string,
 	params map[string]any,
 ) *Portfolio {
-	benchInit()
 	strat, err := NewStrategy(spec, params)
 	if err != nil {
 		t.Fatalf("NewStrategy(%q, %v): %v", spec, params, err)
@@ -89,14 +90,11 @@ func TestLuaStrategy_BuyAndHold(t *testing.T) {
 	tickers := []string{"AAA", "BBB", "CCC"}
 	hist, rf := generateRealTestData(tickers, 120)
 
-	// NOTE: using greedy because the pre-existing equalWeights sizing in
-	// generalBuy (strategy.go) divides via integer math and yields 0
-	// shares for any N>1 tickers.
 	p := newRealTestPortfolio(t, tickers, 30_000,
 		"lua:"+filepath.Join(dir, "buy_and_hold.lua"),
 		map[string]any{"buyType": "greedy"},
 	)
-	runOne(p, hist, rf)
+	runOne(context.Background(), p, hist, rf)
 
 	if len(p.Positions) == 0 {
 		t.Fatalf("expected at least one open position after buy_and_hold")
@@ -126,7 +124,7 @@ func TestLuaStrategy_SMACross(t *testing.T) {
 			"buyType": "greedy",
 		},
 	)
-	runOne(p, hist, rf)
+	runOne(context.Background(), p, hist, rf)
 
 	if len(p.DailyReturns) == 0 {
 		t.Fatal("smaCross produced no daily returns")
@@ -158,7 +156,7 @@ func TestLuaStrategy_RSI(t *testing.T) {
 			"buyType":     "greedy",
 		},
 	)
-	runOne(p, hist, rf)
+	runOne(context.Background(), p, hist, rf)
 
 	if len(p.DailyReturns) == 0 {
 		t.Fatal("rsi produced no daily returns")
@@ -202,8 +200,8 @@ func TestLuaStrategy_CloneIsolation(t *testing.T) {
 	if c1.Strategy == c2.Strategy {
 		t.Fatal("clones share the same Strategy instance")
 	}
-	runOne(c1, hist, rf)
-	runOne(c2, hist, rf)
+	runOne(context.Background(), c1, hist, rf)
+	runOne(context.Background(), c2, hist, rf)
 
 	// Same inputs → same deterministic outputs. Comparing the daily
 	// portfolio value series is the cleanest equality check; Sharpe etc.
@@ -288,7 +286,7 @@ end
 		"nested":  map[string]any{"inner": int64(42)},
 	}
 	p := newRealTestPortfolio(t, tickers, 100_000, "lua:"+script, params)
-	runOne(p, hist, rf)
+	runOne(context.Background(), p, hist, rf)
 
 	pos, ok := p.FindPosition("AAA")
 	if !ok || pos.Amount != 7 {
@@ -296,4 +294,3 @@ end
 			"path executed); got pos=%+v ok=%v", pos, ok)
 	}
 }
-
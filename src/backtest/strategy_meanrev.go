@@ -0,0 +1,181 @@
+package backtest
+
+import (
+	"fmt"
+	"my-backtester/src/data"
+	"my-backtester/src/indicators"
+	"strconv"
+	"strings"
+)
+
+// BollingerReversion buys a ticker when it closes below its lower
+// Bollinger Band and sells once it closes back at or above the middle
+// band, betting that a stretched price returns to its average. Bands
+// include the bar being stepped, whose close the signal is taken on.
+//
+// Spec format: "bollinger:<period>:<k>:<sizer>", e.g.
+// "bollinger:20:2:equalWeights".
+type BollingerReversion struct {
+	Period  int
+	K       float64
+	BuyType string
+	bands   map[string]*reversionState
+}
+
+// ZScoreReversion buys a ticker when its close is more than Entry
+// standard deviations below the mean of the last Lookback closes and
+// sells once the z-score recovers above -Exit; Exit 0 waits for the
+// mean itself.
+//
+// Spec format: "zscore:<lookback>:<entry>:<exit>:<sizer>", e.g.
+// "zscore:20:2:0.5:equalWeights".
+type ZScoreReversion struct {
+	Lookback    int
+	Entry, Exit float64
+	BuyType     string
+	scores      map[string]*reversionState
+}
+
+// reversionState is one ticker's indicator, fed every Close up to and
+// including the bar being stepped.
+type reversionState struct {
+	update func(close float64) (enter, exit bool)
+	fed    int
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "bollinger",
+		Usage: "bollinger:<period>:<k>:<sizer>",
+		Doc:   "buys a close below the lower Bollinger Band, sells at the middle band",
+		Params: []Param{
+			{Name: "period", Type: "int", Doc: "bars in the moving average, e.g. 20"},
+			{Name: "k", Type: "float", Doc: "band width in standard deviations, e.g. 2"},
+			{Name: "sizer", Type: "sizer", Doc: "how buying power is split"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		sub := strings.SplitN(arg, ":", 3)
+		if len(sub) < 3 {
+			return nil, fmt.Errorf("bollinger spec needs period:k:sizer: %q", "bollinger:"+arg)
+		}
+		period, err := strconv.Atoi(sub[0])
+		if err != nil || period < 2 {
+			return nil, fmt.Errorf("bollinger period must be at least 2: %q", sub[0])
+		}
+		k, err := strconv.ParseFloat(sub[1], 64)
+		if err != nil || !(k > 0) {
+			return nil, fmt.Errorf("bollinger k must be positive: %q", sub[1])
+		}
+		if err := checkSizer(sub[2]); err != nil {
+			return nil, fmt.Errorf("bollinger: %w", err)
+		}
+		return &BollingerReversion{Period: period, K: k, BuyType: sub[2]}, nil
+	})
+	RegisterStrategy(Component{
+		Name:  "zscore",
+		Usage: "zscore:<lookback>:<entry>:<exit>:<sizer>",
+		Doc:   "buys when the close's z-score falls below -entry, sells when it rises above -exit",
+		Params: []Param{
+			{Name: "lookback", Type: "int", Doc: "closes in the mean and standard deviation"},
+			{Name: "entry", Type: "float", Doc: "standard deviations below the mean to buy at"},
+			{Name: "exit", Type: "float", Doc: "standard deviations below the mean to sell at; 0 is the mean"},
+			{Name: "sizer", Type: "sizer", Doc: "how buying power is split"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		sub := strings.SplitN(arg, ":", 4)
+		if len(sub) < 4 {
+			return nil, fmt.Errorf("zscore spec needs lookback:entry:exit:sizer: %q", "zscore:"+arg)
+		}
+		lookback, err := strconv.Atoi(sub[0])
+		if err != nil || lookback < 2 {
+			return nil, fmt.Errorf("zscore lookback must be at least 2: %q", sub[0])
+		}
+		entry, err := strconv.ParseFloat(sub[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("zscore entry: %w", err)
+		}
+		exit, err := strconv.ParseFloat(sub[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("zscore exit: %w", err)
+		}
+		if exit >= entry {
+			return nil, fmt.Errorf("zscore exit %v must be below entry %v", exit, entry)
+		}
+		if err := checkSizer(sub[3]); err != nil {
+			return nil, fmt.Errorf("zscore: %w", err)
+		}
+		return &ZScoreReversion{Lookback: lookback, Entry: entry, Exit: exit, BuyType: sub[3]}, nil
+	})
+}
+
+func (s *BollingerReversion) Name() string {
+	return fmt.Sprintf("bollinger:%d:%g:%s", s.Period, s.K, s.BuyType)
+}
+
+func (s *BollingerReversion) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if s.bands == nil {
+		s.bands = make(map[string]*reversionState, len(p.Tickers))
+	}
+	stepReversion(p, hist, day, s.BuyType, s.bands, func() func(float64) (bool, bool) {
+		bb := indicators.NewBollinger(s.Period, s.K)
+		return func(close float64) (bool, bool) {
+			mid, _, lo := bb.Update(close)
+			return bb.Ready() && close < lo, bb.Ready() && close >= mid
+		}
+	})
+}
+
+func (s *ZScoreReversion) Name() string {
+	return fmt.Sprintf("zscore:%d:%g:%g:%s", s.Lookback, s.Entry, s.Exit, s.BuyType)
+}
+
+func (s *ZScoreReversion) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if s.scores == nil {
+		s.scores = make(map[string]*reversionState, len(p.Tickers))
+	}
+	stepReversion(p, hist, day, s.BuyType, s.scores, func() func(float64) (bool, bool) {
+		zs := indicators.NewZScore(s.Lookback)
+		return func(close float64) (bool, bool) {
+			z := zs.Update(close)
+			return zs.Ready() && z < -s.Entry, zs.Ready() && z > -s.Exit
+		}
+	})
+}
+
+// stepReversion feeds each ticker's indicator up to day and buys on its
+// entry signal when flat, or sells the whole position on its exit signal.
+func stepReversion(
+	p *Portfolio, hist map[string][]data.AssetData, day int, buyType string,
+	states map[string]*reversionState, start func() func(float64) (bool, bool),
+) {
+	for _, ticker := range p.Context(hist, day).tradable() {
+		td := hist[ticker]
+		if day >= len(td) {
+			continue
+		}
+		st := states[ticker]
+		if st == nil {
+			st = &reversionState{update: start()}
+			states[ticker] = st
+		}
+		var enter, exit bool
+		for ; st.fed <= day; st.fed++ {
+			enter, exit = st.update(td[st.fed].Close)
+		}
+		pos, _ := p.FindPosition(ticker)
+		held := pos != nil && pos.Amount > 0
+		switch {
+		case enter && !held:
+			price := p.FillPrice(ticker, hist, day)
+			if amount := p.maxBuy(ticker, price, buyType); amount > 0 {
+				p.Order(ticker, "BUY", amount, hist, day)
+			}
+		case exit && held:
+			p.Order(ticker, "SELL", pos.Amount, hist, day)
+		}
+	}
+}
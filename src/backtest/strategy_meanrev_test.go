@@ -0,0 +1,45 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestMeanReversion(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	var bars []data.AssetData
+	for i, c := range []float64{10, 11, 10, 11, 10, 7, 8, 10.5, 11, 10} {
+		bars = append(bars, data.AssetData{Date: day(i), Open: c, High: c, Low: c, Close: c})
+	}
+	hist := map[string][]data.AssetData{"A": bars}
+	for _, tc := range []struct {
+		spec      string
+		buy, sell int
+	}{
+		// Over four bars the 7 is 1.67 standard deviations below the
+		// mean of 9.5 (lower band 7.25); the 10.5 two bars later is the
+		// first close back above the mean, and the 8 before it already
+		// has a z-score of -0.63.
+		{"bollinger:4:1.5:greedy", 5, 7},
+		{"zscore:4:1.5:0:greedy", 5, 7},
+		{"zscore:4:1.5:0.7:greedy", 5, 6},
+	} {
+		p, err := NewPortfolio("p", 1000, []string{"A"}, tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runOne(context.Background(), p, hist, nil)
+		if len(p.Trades) != 2 || p.Trades[0].Side != "BUY" || !p.Trades[0].Date.Equal(day(tc.buy)) ||
+			p.Trades[1].Side != "SELL" || !p.Trades[1].Date.Equal(day(tc.sell)) {
+			t.Errorf("%s: trades = %+v, want a buy on bar %d and a sell on bar %d", tc.spec, p.Trades, tc.buy, tc.sell)
+		}
+	}
+
+	for _, bad := range []string{"bollinger:1:2:greedy", "bollinger:20:0:greedy", "zscore:20:1:1:greedy", "zscore:20:2:0"} {
+		if _, err := NewStrategy(bad, nil); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
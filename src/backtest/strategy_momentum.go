@@ -0,0 +1,122 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Momentum rotates the portfolio into the tickers that have risen most.
+// On the first bar of each calendar month it scores every ticker by the
+// mean of its trailing returns over each of Months, ranks them, and
+// rebalances to hold the Top highest-scoring at equal weight, selling
+// the rest. A trailing return runs from the close of the last bar on or
+// before the same date that many months back, so a ticker is only
+// ranked once the run holds that much of its history; until one is,
+// the strategy sits in cash.
+//
+// Spec format: "momentum:<months>:<top>", e.g. "momentum:3,6,12:2".
+type Momentum struct {
+	Months []int
+	Top    int
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "momentum",
+		Usage: "momentum:<months>:<top>",
+		Doc:   "each month, holds the <top> tickers with the best trailing return at equal weight",
+		Params: []Param{
+			{Name: "months", Type: "ints", Doc: "comma-separated lookbacks in months, averaged, e.g. 3,6,12"},
+			{Name: "top", Type: "int", Doc: "tickers held at once"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		sub := strings.Split(arg, ":")
+		if len(sub) != 2 {
+			return nil, fmt.Errorf("momentum spec needs months:top: %q", "momentum:"+arg)
+		}
+		var months []int
+		for _, f := range strings.Split(sub[0], ",") {
+			m, err := strconv.Atoi(f)
+			if err != nil || m <= 0 {
+				return nil, fmt.Errorf("momentum lookback must be a positive month count: %q", f)
+			}
+			months = append(months, m)
+		}
+		top, err := strconv.Atoi(sub[1])
+		if err != nil || top <= 0 {
+			return nil, fmt.Errorf("momentum top must be positive: %q", sub[1])
+		}
+		return &Momentum{Months: months, Top: top}, nil
+	})
+}
+
+func (s *Momentum) Name() string {
+	months := make([]string, len(s.Months))
+	for i, m := range s.Months {
+		months[i] = strconv.Itoa(m)
+	}
+	return fmt.Sprintf("momentum:%s:%d", strings.Join(months, ","), s.Top)
+}
+
+func (s *Momentum) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if day == 0 || len(p.Tickers) == 0 {
+		return
+	}
+	lead := hist[p.Tickers[0]]
+	if day >= len(lead) || lead[day].Date.Month() == lead[day-1].Date.Month() {
+		return
+	}
+
+	type ranked struct {
+		ticker string
+		score  float64
+	}
+	var scores []ranked
+	for _, t := range p.Context(hist, day).Universe() {
+		if score, ok := s.score(hist[t], day); ok {
+			scores = append(scores, ranked{t, score})
+		}
+	}
+	if len(scores) == 0 {
+		return
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+	if len(scores) > s.Top {
+		scores = scores[:s.Top]
+	}
+	picks := make([]string, len(scores))
+	for i, r := range scores {
+		picks[i] = r.ticker
+	}
+	rebalanceTo(p, hist, day, picks)
+}
+
+// score is the mean trailing return of td to day over s.Months, and
+// false if td doesn't reach back far enough for all of them.
+func (s *Momentum) score(td []data.AssetData, day int) (float64, bool) {
+	if day >= len(td) || td[day].Close <= 0 {
+		return 0, false
+	}
+	total := 0.0
+	for _, m := range s.Months {
+		since := td[day].Date.AddDate(0, -m, 0)
+		// The last bar on or before since.
+		i := sort.Search(day+1, func(i int) bool { return td[i].Date.After(since) }) - 1
+		if i < 0 || td[i].Close <= 0 {
+			return 0, false
+		}
+		// The bars' log returns since then add up to the period's.
+		sum := 0.0
+		for _, r := range data.LogReturns(td[i:day+1], day+1-i) {
+			sum += r
+		}
+		total += math.Expm1(sum)
+	}
+	return total / float64(len(s.Months)), true
+}
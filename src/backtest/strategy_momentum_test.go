@@ -0,0 +1,69 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// A doubles in January and halves in February while B doubles in
+// February; C never moves. Ranked on one month's return and holding the
+// best one, the portfolio buys A on February 1 and rotates into B on
+// March 1.
+func TestMomentum_Rotates(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i <= 61; i++ {
+		a, b := 20.0, 10.0
+		if i == 0 || i > 31 {
+			a = 10
+		}
+		if i > 31 {
+			b = 20
+		}
+		for ticker, c := range map[string]float64{"A": a, "B": b, "C": 10} {
+			store.bars[ticker] = append(store.bars[ticker], data.AssetData{
+				Date: day(i), Open: c, High: c, Low: c, Close: c, Volume: 100,
+			})
+		}
+	}
+	p, err := NewPortfolio("mom", 1000, []string{"A", "B", "C"}, "momentum:1:1",
+		WithWindow(day(0), day(61)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Trade{
+		{Date: day(31), Ticker: "A", Side: "BUY", Amount: 50, Price: 20},
+		{Date: day(60), Ticker: "A", Side: "SELL", Amount: 50, Price: 10},
+		{Date: day(60), Ticker: "B", Side: "BUY", Amount: 25, Price: 20},
+	}
+	r := results[0]
+	if len(r.Trades) != len(want) {
+		t.Fatalf("trades = %+v", r.Trades)
+	}
+	for i, tr := range r.Trades {
+		if tr != want[i] {
+			t.Errorf("trade %d = %+v, want %+v", i, tr, want[i])
+		}
+	}
+}
+
+func TestMomentum_Spec(t *testing.T) {
+	s, err := NewStrategy("momentum:3,6,12:2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "momentum:3,6,12:2" {
+		t.Errorf("name = %q", s.Name())
+	}
+	for _, bad := range []string{"momentum:3", "momentum:0:2", "momentum:3,x:2", "momentum:3:0"} {
+		if _, err := NewStrategy(bad, nil); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/data"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// PairsTrading trades the spread between pairs of tickers. Each bar it
+// fits the Engle-Granger regression a = alpha + beta·b over each pair's
+// Lookback closes before the bar, and scores the bar's own spread
+// a - alpha - beta·b in standard deviations of the fit's residuals. When
+// the pair cointegrates at the 5% level and that score is beyond Entry,
+// it sells the rich leg short and buys the cheap one, beta shares of b
+// per share of a; it closes both legs once the score is back within
+// Exit. Each pair trades an equal share of the portfolio's value,
+// counting both legs. The portfolio must allow shorting (see
+// AllowShort), and a ticker may be in only one pair.
+//
+// Spec format: "pairs:<a>/<b>[,<c>/<d>...]:<lookback>:<entry>:<exit>",
+// e.g. "pairs:KO/PEP:60:2:0.5".
+type PairsTrading struct {
+	Pairs       [][2]string
+	Lookback    int
+	Entry, Exit float64
+	stats       []PairStats
+	open        []bool
+	warned      bool
+}
+
+// PairStats is how one pair of a PairsTrading run fared: its round trips
+// (both legs closed on one bar) and their profit net of costs, and the
+// pair's fit on the last bar it was fitted.
+type PairStats struct {
+	Pair         string // "a/b"
+	RoundTrips   int
+	Wins         int
+	PnL          float64
+	Correlation  float64 // of daily returns over the lookback
+	HedgeRatio   float64 // beta, shares of b per share of a
+	Cointegrated bool
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "pairs",
+		Usage: "pairs:<a>/<b>[,<c>/<d>...]:<lookback>:<entry>:<exit>",
+		Doc:   "trades the z-score of each cointegrated pair's spread; needs AllowShort",
+		Params: []Param{
+			{Name: "pairs", Type: "string", Doc: "comma-separated ticker pairs, e.g. KO/PEP"},
+			{Name: "lookback", Type: "int", Doc: "bars the hedge ratio and cointegration are fitted on, at least 30"},
+			{Name: "entry", Type: "float", Doc: "spread standard deviations to open at"},
+			{Name: "exit", Type: "float", Doc: "spread standard deviations to close within"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		sub := strings.Split(arg, ":")
+		if len(sub) != 4 {
+			return nil, fmt.Errorf("pairs spec needs pairs:lookback:entry:exit: %q", "pairs:"+arg)
+		}
+		s := &PairsTrading{}
+		seen := map[string]bool{}
+		for _, f := range strings.Split(sub[0], ",") {
+			a, b, ok := strings.Cut(f, "/")
+			if !ok || a == "" || b == "" || a == b {
+				return nil, fmt.Errorf("pairs: %q is not a pair of tickers like KO/PEP", f)
+			}
+			for _, t := range []string{a, b} {
+				if seen[t] {
+					return nil, fmt.Errorf("pairs: %s is in more than one pair", t)
+				}
+				seen[t] = true
+			}
+			s.Pairs = append(s.Pairs, [2]string{a, b})
+		}
+		var err error
+		if s.Lookback, err = strconv.Atoi(sub[1]); err != nil || s.Lookback < 30 {
+			return nil, fmt.Errorf("pairs lookback must be at least 30 bars: %q", sub[1])
+		}
+		if s.Entry, err = strconv.ParseFloat(sub[2], 64); err != nil || !(s.Entry > 0) {
+			return nil, fmt.Errorf("pairs entry must be positive: %q", sub[2])
+		}
+		if s.Exit, err = strconv.ParseFloat(sub[3], 64); err != nil || s.Exit < 0 || s.Exit >= s.Entry {
+			return nil, fmt.Errorf("pairs exit must be in [0, entry): %q", sub[3])
+		}
+		return s, nil
+	})
+}
+
+func (s *PairsTrading) Name() string {
+	pairs := make([]string, len(s.Pairs))
+	for i, pr := range s.Pairs {
+		pairs[i] = pr[0] + "/" + pr[1]
+	}
+	return fmt.Sprintf("pairs:%s:%d:%g:%g", strings.Join(pairs, ","), s.Lookback, s.Entry, s.Exit)
+}
+
+func (s *PairsTrading) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if !p.AllowShort {
+		if !s.warned {
+			logger.Warn("strategy needs AllowShort; not trading", "portfolio", p.Pname, "strategy", s.Name())
+			s.warned = true
+		}
+		return
+	}
+	if s.stats == nil {
+		s.stats = make([]PairStats, len(s.Pairs))
+		s.open = make([]bool, len(s.Pairs))
+	}
+	if day < s.Lookback {
+		return
+	}
+	budget := p.GetPortfolioValue(p.Tickers, hist, day) / float64(len(s.Pairs))
+	for i, pr := range s.Pairs {
+		a, b := hist[pr[0]], hist[pr[1]]
+		if day >= len(a) || day >= len(b) {
+			continue
+		}
+		ya := closesOnly(a[day-s.Lookback:], s.Lookback)
+		xb := closesOnly(b[day-s.Lookback:], s.Lookback)
+		fit, ok := engleGranger(ya, xb)
+		if !ok {
+			continue
+		}
+		st := &s.stats[i]
+		st.Correlation = returnCorrelation(ya, xb)
+		st.HedgeRatio, st.Cointegrated = fit.beta, fit.tStat < engleGranger5pct
+		sd := stat.StdDev(fit.res, nil)
+		if !(sd > 0) {
+			continue
+		}
+		z := (a[day].Close - fit.alpha - fit.beta*b[day].Close) / sd
+		switch {
+		case s.open[i] && math.Abs(z) <= s.Exit:
+			s.closePair(p, hist, day, pr)
+			s.open[i] = false
+		case !s.open[i] && st.Cointegrated && fit.beta > 0 && math.Abs(z) > s.Entry:
+			s.open[i] = s.openPair(p, hist, day, pr, z, fit.beta, budget)
+		}
+	}
+}
+
+// openPair shorts the rich leg of pr and buys the cheap one, and reports
+// whether it traded. A long leg the portfolio can't take has its short
+// bought back, so a failed pair never leaves a naked short.
+func (s *PairsTrading) openPair(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+	pr [2]string, z, beta, budget float64,
+) bool {
+	pa, pb := p.FillPrice(pr[0], hist, day), p.FillPrice(pr[1], hist, day)
+	if pa <= 0 || pb <= 0 {
+		return false
+	}
+	na := math.Floor(budget / (pa + beta*pb))
+	nb := math.Floor(beta * na)
+	if na < 1 || nb < 1 {
+		return false
+	}
+	short, long := pr[0], pr[1]
+	nShort, nLong := na, nb
+	if z < 0 {
+		short, long, nShort, nLong = long, short, nLong, nShort
+	}
+	if err := p.Order(short, "SELL", nShort, hist, day); err != nil {
+		return false
+	}
+	if err := p.Order(long, "BUY", nLong, hist, day); err != nil {
+		if err := p.Order(short, "BUY", nShort, hist, day); err != nil {
+			logger.Warn("unwind pair", "portfolio", p.Pname, "ticker", short, "day", day, "err", err)
+		}
+		return false
+	}
+	return true
+}
+
+// closePair flattens both legs of pr.
+func (s *PairsTrading) closePair(
+	p *Portfolio, hist map[string][]data.AssetData, day int, pr [2]string,
+) {
+	for _, t := range pr {
+		pos, _ := p.FindPosition(t)
+		switch {
+		case pos == nil:
+		case pos.Amount > 0:
+			p.Order(t, "SELL", pos.Amount, hist, day)
+		case pos.Amount < 0:
+			p.Order(t, "BUY", -pos.Amount, hist, day)
+		}
+	}
+}
+
+// PairStats reports each pair's round trips from p's closed trades,
+// grouping the legs closed on the same bar, with its last fit.
+func (s *PairsTrading) PairStats(p *Portfolio) []PairStats {
+	out := make([]PairStats, len(s.Pairs))
+	for i, pr := range s.Pairs {
+		if s.stats != nil {
+			out[i] = s.stats[i]
+		}
+		out[i].Pair = pr[0] + "/" + pr[1]
+		exits := map[int64]float64{}
+		for _, ct := range p.ClosedTrades {
+			if ct.Ticker == pr[0] || ct.Ticker == pr[1] {
+				exits[ct.Exit.Unix()] += ct.PnL
+			}
+		}
+		days := make([]int64, 0, len(exits))
+		for d := range exits {
+			days = append(days, d)
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+		for _, d := range days {
+			out[i].RoundTrips++
+			out[i].PnL += exits[d]
+			if exits[d] > 0 {
+				out[i].Wins++
+			}
+		}
+	}
+	return out
+}
+
+// returnCorrelation is the Pearson correlation of two price series'
+// simple returns, or 0 when it is undefined.
+func returnCorrelation(a, b []float64) float64 {
+	if len(a) < 3 || len(a) != len(b) {
+		return 0
+	}
+	ra := make([]float64, 0, len(a)-1)
+	rb := make([]float64, 0, len(b)-1)
+	for i := 1; i < len(a); i++ {
+		if a[i-1] <= 0 || b[i-1] <= 0 {
+			return 0
+		}
+		ra = append(ra, a[i]/a[i-1]-1)
+		rb = append(rb, b[i]/b[i-1]-1)
+	}
+	c := stat.Correlation(ra, rb, nil)
+	if math.IsNaN(c) {
+		return 0
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// A tracks twice B plus a little stationary noise until A jumps for
+// three bars: the strategy shorts A against B on the jump and closes
+// both legs once A falls back into line, for a profit.
+func TestPairsTrading(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	const jump = 45
+	for i := 0; i < 70; i++ {
+		b := 50 + 5*math.Sin(float64(i)/7) + 0.05*float64(i)
+		a := 2*b + math.Sin(1.3*float64(i))
+		if i >= jump && i < jump+3 {
+			a += 8
+		}
+		for ticker, c := range map[string]float64{"A": a, "B": b} {
+			store.bars[ticker] = append(store.bars[ticker], data.AssetData{
+				Date: day(i), Open: c, High: c, Low: c, Close: c, Volume: 100,
+			})
+		}
+	}
+	p, err := NewPortfolio("pairs", 10_000, []string{"A", "B"}, "pairs:A/B:30:2:0.5",
+		WithWindow(day(0), day(69)), WithShorting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if len(r.Trades) != 4 {
+		t.Fatalf("trades = %+v, want one round trip", r.Trades)
+	}
+	open := r.Trades[:2]
+	if open[0].Date != day(jump) || open[0].Ticker != "A" || open[0].Side != "SELL" ||
+		open[1].Ticker != "B" || open[1].Side != "BUY" {
+		t.Errorf("opening trades = %+v, want A shorted against B on the jump", open)
+	}
+	if ratio := open[1].Amount / open[0].Amount; math.Abs(ratio-2) > 0.2 {
+		t.Errorf("hedge = %.2f shares of B per A, want about 2", ratio)
+	}
+	if len(r.Pairs) != 1 {
+		t.Fatalf("pairs = %+v", r.Pairs)
+	}
+	ps := r.Pairs[0]
+	if ps.Pair != "A/B" || ps.RoundTrips != 1 || ps.Wins != 1 || !(ps.PnL > 0) {
+		t.Errorf("pair stats = %+v, want one winning round trip", ps)
+	}
+	if !closeTo(ps.PnL, r.EquityCurve[len(r.EquityCurve)-1]-10_000) {
+		t.Errorf("pair PnL %.2f is not the run's profit %.2f", ps.PnL, r.EquityCurve[len(r.EquityCurve)-1]-10_000)
+	}
+
+	// Without shorting the strategy stands aside.
+	p, _ = NewPortfolio("long-only", 10_000, []string{"A", "B"}, "pairs:A/B:30:2:0.5",
+		WithWindow(day(0), day(69)))
+	if results, _ := Run(context.Background(), store, []*Portfolio{p}, nil); len(results[0].Trades) != 0 {
+		t.Errorf("traded without AllowShort: %+v", results[0].Trades)
+	}
+}
+
+func TestPairsTrading_Spec(t *testing.T) {
+	s, err := NewStrategy("pairs:KO/PEP,XOM/CVX:60:2:0.5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "pairs:KO/PEP,XOM/CVX:60:2:0.5" {
+		t.Errorf("name = %q", s.Name())
+	}
+	for _, bad := range []string{
+		"pairs:KO/PEP:60:2",
+		"pairs:KO:60:2:0.5",
+		"pairs:KO/KO:60:2:0.5",
+		"pairs:KO/PEP,PEP/XOM:60:2:0.5",
+		"pairs:KO/PEP:20:2:0.5",
+		"pairs:KO/PEP:60:2:2",
+	} {
+		if _, err := NewStrategy(bad, nil); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+// A pair whose long leg the portfolio can't take buys its short back
+// rather than leave it naked.
+func TestPairsTrading_UnwindsFailedLongLeg(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	hist := map[string][]data.AssetData{
+		"A": {{Date: day, Open: 10, High: 10, Low: 10, Close: 10}},
+		"B": {{Date: day, Open: 10, High: 10, Low: 10, Close: 10}},
+	}
+	p := newPropPortfolio(1000, AccountingFloat)
+	p.AllowShort = true
+	s := &PairsTrading{}
+	// 75 shares a leg: the short fits within equity, both legs don't.
+	if s.openPair(p, hist, 0, [2]string{"A", "B"}, 3, 1, 1500) {
+		t.Error("reported the pair open")
+	}
+	if len(p.Positions) != 0 {
+		t.Errorf("positions = %v, want the short bought back", p.Positions)
+	}
+	if len(p.Trades) != 2 || p.Trades[0].Side != "SELL" || p.Trades[1].Side != "BUY" || p.Trades[1].Ticker != "A" {
+		t.Errorf("trades = %+v, want A shorted and covered", p.Trades)
+	}
+}
@@ -0,0 +1,144 @@
+package backtest
+
+import (
+	"fmt"
+	"my-backtester/src/data"
+	"strconv"
+	"strings"
+)
+
+// Random holds Count tickers of the universe drawn at random, at equal
+// weight, drawing again on Schedule's dates; its Weights are unused. It
+// has no edge by construction: runs of it over different Seeds show how
+// much of another strategy's result its tickers and window alone would
+// give (see RunBaselines).
+//
+// Spec format: "random:<every>[:<count>]", e.g. "random:month:5" for 5
+// tickers redrawn each month. Count defaults to half the universe,
+// rounded up.
+type Random struct {
+	Schedule Rebalance
+	Count    int
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "random",
+		Usage: "random:<every>[:<count>]",
+		Doc:   "holds <count> tickers drawn at random at an equal weight, redrawing them on rebalance's schedule",
+		Params: []Param{
+			{Name: "every", Type: "string", Doc: "bars between draws, or week, month, quarter or year, with -end for the period's last session"},
+			{Name: "count", Type: "int", Default: "half the universe", Doc: "tickers held at once"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		arg, count, _ := strings.Cut(arg, ":")
+		r, err := parseRebalanceEvery(arg)
+		if err != nil {
+			return nil, fmt.Errorf("random: %w", err)
+		}
+		s := &Random{Schedule: *r}
+		if count != "" {
+			if s.Count, err = strconv.Atoi(count); err != nil || s.Count <= 0 {
+				return nil, fmt.Errorf("random count must be positive: %q", count)
+			}
+		}
+		return s, nil
+	})
+}
+
+func (s *Random) Name() string {
+	name := "random" + strings.TrimPrefix(s.Schedule.Name(), "rebalance")
+	if s.Count > 0 {
+		name += ":" + strconv.Itoa(s.Count)
+	}
+	return name
+}
+
+func (s *Random) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if len(p.Tickers) == 0 || !s.Schedule.due(p, hist[p.Tickers[0]], day) {
+		return
+	}
+	universe := p.Context(hist, day).Universe()
+	n := s.Count
+	if n == 0 {
+		n = (len(universe) + 1) / 2
+	}
+	if n > len(universe) {
+		n = len(universe)
+	}
+	// A partial shuffle of a copy: the universe may be p.Tickers.
+	picks := append([]string(nil), universe...)
+	rng := p.Rand()
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(len(picks)-i)
+		picks[i], picks[j] = picks[j], picks[i]
+	}
+	rebalanceTo(p, hist, day, picks[:n])
+}
+
+// RandomEntry enters and leaves each ticker on random bars: a ticker it
+// doesn't hold is bought with probability 1/Hold on each bar, and one it
+// holds is sold with the same probability, so positions last Hold bars
+// on average and each ticker is held about half the time. The tickers
+// held share the portfolio equally. Like Random it has no edge by
+// construction; runs of it are what another strategy's timing on the
+// same tickers is measured against (see WithRandomEntry).
+//
+// Spec format: "random-entry[:<hold>]"; hold defaults to 20 bars.
+type RandomEntry struct {
+	Hold int
+	held map[string]bool
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "random-entry",
+		Usage: "random-entry[:<hold>]",
+		Doc:   "buys and sells each ticker on random bars, holding <hold> bars on average, with the tickers held at an equal weight",
+		Params: []Param{
+			{Name: "hold", Type: "int", Default: "20", Doc: "mean bars a position is held, and between positions"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		s := &RandomEntry{Hold: 20}
+		if arg != "" {
+			var err error
+			if s.Hold, err = strconv.Atoi(arg); err != nil || s.Hold <= 0 {
+				return nil, fmt.Errorf("random-entry hold must be positive: %q", arg)
+			}
+		}
+		return s, nil
+	})
+}
+
+func (s *RandomEntry) Name() string {
+	return "random-entry:" + strconv.Itoa(s.Hold)
+}
+
+func (s *RandomEntry) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if s.held == nil {
+		s.held = make(map[string]bool)
+	}
+	universe := p.Context(hist, day).Universe()
+	rng := p.Rand()
+	changed := false
+	for _, t := range universe {
+		if rng.Float64() < 1/float64(s.Hold) {
+			s.held[t] = !s.held[t]
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	var picks []string
+	for _, t := range universe {
+		if s.held[t] {
+			picks = append(picks, t)
+		}
+	}
+	rebalanceTo(p, hist, day, picks)
+}
@@ -0,0 +1,230 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"my-backtester/src/calendar"
+	"my-backtester/src/data"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rebalance holds every ticker in the portfolio at an equal share of its
+// total value, or at Weights, trading back to them on the first bar and then
+// on a schedule: every Every bars, or on the first session of each
+// Period (week, month, quarter or year), or with AtEnd on its last
+// session, which the portfolio's Calendar tells apart before the next
+// bar arrives. It is the simplest strategy that needs the whole
+// universe at once: runOne hands Step every ticker's bars aligned to the
+// same dates, so day is one date across all of them.
+//
+// Spec format: "rebalance:<every>[:<weights>]", e.g. "rebalance:21" for
+// every 21 bars, "rebalance:month" for the first session of each month,
+// "rebalance:month-end" for the last, or "rebalance:month:SPY=60,AGG=40"
+// for a 60/40 split. Weights are relative: those of the tickers held are
+// scaled to sum to the whole, and a ticker without one isn't held.
+type Rebalance struct {
+	Every   int
+	Period  string
+	AtEnd   bool
+	Weights map[string]float64
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "rebalance",
+		Usage: "rebalance:<every>[:<weights>]",
+		Doc:   "holds every ticker at an equal weight, or at <weights>, rebalancing every <every> bars or each week, month, quarter or year",
+		Params: []Param{
+			{Name: "every", Type: "string", Doc: "bars between rebalances, or week, month, quarter or year, with -end for the period's last session"},
+			{Name: "weights", Type: "string", Default: "equal", Doc: "comma-separated ticker=weight pairs, e.g. SPY=60,AGG=40"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		arg, weights, _ := strings.Cut(arg, ":")
+		s, err := parseRebalanceEvery(arg)
+		if err != nil {
+			return nil, err
+		}
+		if weights != "" {
+			if s.Weights, err = parseWeights(weights); err != nil {
+				return nil, fmt.Errorf("rebalance weights: %w", err)
+			}
+		}
+		return s, nil
+	})
+}
+
+// parseRebalanceEvery parses a rebalance schedule: a bar count, or a
+// period with an optional -end.
+func parseRebalanceEvery(arg string) (*Rebalance, error) {
+	if every, err := strconv.Atoi(arg); err == nil {
+		if every <= 0 {
+			return nil, fmt.Errorf("rebalance spec needs a positive bar count: %q", "rebalance:"+arg)
+		}
+		return &Rebalance{Every: every}, nil
+	}
+	period, atEnd := strings.CutSuffix(arg, "-end")
+	if _, err := calendar.ParsePeriod(period); err != nil {
+		return nil, fmt.Errorf("rebalance spec %q: want a positive bar count or week, month, quarter or year, optionally with -end", "rebalance:"+arg)
+	}
+	return &Rebalance{Period: period, AtEnd: atEnd}, nil
+}
+
+// parseWeights parses "ticker=weight,..." with positive weights.
+func parseWeights(s string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, f := range strings.Split(s, ",") {
+		t, w, ok := strings.Cut(f, "=")
+		weight, err := strconv.ParseFloat(w, 64)
+		if !ok || t == "" || err != nil || !(weight > 0) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("%q: want ticker=weight with a positive weight", f)
+		}
+		if _, dup := weights[t]; dup {
+			return nil, fmt.Errorf("%s weighted twice", t)
+		}
+		weights[t] = weight
+	}
+	return weights, nil
+}
+
+// formatWeights is parseWeights' inverse, in ticker order.
+func formatWeights(weights map[string]float64) string {
+	tickers := make([]string, 0, len(weights))
+	for t := range weights {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	for i, t := range tickers {
+		tickers[i] = t + "=" + strconv.FormatFloat(weights[t], 'g', -1, 64)
+	}
+	return strings.Join(tickers, ",")
+}
+
+func (s *Rebalance) Name() string {
+	name := "rebalance:" + s.Period
+	switch {
+	case s.Period == "":
+		name = fmt.Sprintf("rebalance:%d", s.Every)
+	case s.AtEnd:
+		name += "-end"
+	}
+	if s.Weights != nil {
+		name += ":" + formatWeights(s.Weights)
+	}
+	return name
+}
+
+func (s *Rebalance) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	if len(p.Tickers) == 0 || !s.due(p, hist[p.Tickers[0]], day) {
+		return
+	}
+	picks := p.Context(hist, day).Universe()
+	if s.Weights != nil {
+		var weighted []string
+		for _, t := range picks {
+			if s.Weights[t] > 0 {
+				weighted = append(weighted, t)
+			}
+		}
+		picks = weighted
+	}
+	rebalanceWeighted(p, hist, day, picks, s.Weights)
+}
+
+// due reports whether bar day of lead, the first ticker's aligned
+// series, is on s's schedule.
+func (s *Rebalance) due(p *Portfolio, lead []data.AssetData, day int) bool {
+	switch {
+	case s.Period == "":
+		return day%s.Every == 0
+	case day == 0:
+		return true
+	case day >= len(lead):
+		return false
+	case s.AtEnd:
+		return p.Calendar.Exchange().IsLastSession(lead[day].Date, s.Period)
+	}
+	return calendar.Period(lead[day].Date, s.Period) != calendar.Period(lead[day-1].Date, s.Period)
+}
+
+// rebalanceTo trades p to hold an equal share of its total value in each
+// of picks, in whole shares, and nothing else. A pick with no price on
+// day, such as an index member without bars yet, is left out.
+func rebalanceTo(
+	p *Portfolio, hist map[string][]data.AssetData, day int, picks []string,
+) {
+	rebalanceWeighted(p, hist, day, picks, nil)
+}
+
+// rebalanceWeighted is rebalanceTo with each pick's share of the value in
+// proportion to its weight in weights, or equal when weights is nil.
+func rebalanceWeighted(
+	p *Portfolio, hist map[string][]data.AssetData, day int, picks []string, weights map[string]float64,
+) {
+	picked := make(map[string]bool, len(picks))
+	for _, t := range picks {
+		picked[t] = true
+	}
+	prices := make(map[string]float64, len(p.Tickers))
+	held := make(map[string]float64, len(p.Tickers))
+	value := p.BuyingPower
+	for _, t := range p.Tickers {
+		pos, _ := p.FindPosition(t)
+		if pos == nil && !picked[t] {
+			continue
+		}
+		price := p.FillPrice(t, hist, day)
+		if price <= 0 && pos == nil {
+			delete(picked, t)
+			continue
+		}
+		if price <= 0 {
+			return // can't value the portfolio; try again next time
+		}
+		prices[t] = price
+		if pos != nil {
+			held[t] = pos.Amount
+			value += pos.Amount * price
+		}
+	}
+	weight := func(t string) float64 {
+		if weights == nil {
+			return 1
+		}
+		return weights[t]
+	}
+	total := 0.0
+	for _, t := range picks {
+		if picked[t] {
+			total += weight(t)
+		}
+	}
+	shares := func(t string) float64 {
+		if !picked[t] || total == 0 {
+			return 0
+		}
+		return math.Floor(value * weight(t) / total / prices[t])
+	}
+
+	// Sell first so the proceeds fund the buys.
+	for _, t := range p.Tickers {
+		if excess := held[t] - shares(t); excess > 0 {
+			p.Order(t, "SELL", excess, hist, day)
+		}
+	}
+	for _, t := range picks {
+		if !picked[t] {
+			continue
+		}
+		want := shares(t) - held[t]
+		if affordable := p.maxBuy(t, prices[t], "greedy"); want > affordable {
+			want = affordable
+		}
+		if want > 0 {
+			p.Order(t, "BUY", want, hist, day)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+// One portfolio holds both tickers; B's extra bar on a date A lacks is
+// dropped, so every Step sees the same date across the universe.
+func TestRebalance_MultiTicker(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	bar := func(d int, c float64) data.AssetData {
+		return data.AssetData{Date: day(d), Open: c, High: c, Low: c, Close: c, Volume: 100}
+	}
+	store := &fakeStore{
+		bars: map[string][]data.AssetData{
+			"A": {bar(0, 10), bar(1, 10), bar(3, 10), bar(4, 10)},
+			"B": {bar(0, 10), bar(1, 20), bar(2, 5), bar(3, 20), bar(4, 20)},
+		},
+		rates: map[int64]float64{},
+	}
+	p, err := NewPortfolio("rot", 1000, []string{"A", "B"}, "rebalance:2",
+		WithWindow(day(0), day(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if r.EffectiveStart != "2024-01-01" || len(r.Dates) != 3 || r.Dates[1] != "2024-01-04" {
+		t.Fatalf("dates = %s + %v, want only those both tickers share", r.EffectiveStart, r.Dates)
+	}
+	// Day 0 splits 1000 evenly; day 2 (Jan 4, B at 20) is worth 1500, so
+	// 13 of B's 50 shares are sold and 25 more of A bought.
+	want := []Trade{
+		{Date: day(0), Ticker: "A", Side: "BUY", Amount: 50, Price: 10},
+		{Date: day(0), Ticker: "B", Side: "BUY", Amount: 50, Price: 10},
+		{Date: day(3), Ticker: "B", Side: "SELL", Amount: 13, Price: 20},
+		{Date: day(3), Ticker: "A", Side: "BUY", Amount: 25, Price: 10},
+	}
+	if len(r.Trades) != len(want) {
+		t.Fatalf("trades = %+v", r.Trades)
+	}
+	for i, tr := range r.Trades {
+		if tr != want[i] {
+			t.Errorf("trade %d = %+v, want %+v", i, tr, want[i])
+		}
+	}
+
+	if _, err := NewStrategy("rebalance:0", nil); err == nil {
+		t.Error("expected error for a zero interval")
+	}
+}
+
+// "month-end" rebalances on the last session of each month, which the
+// NYSE calendar knows was Thursday March 28 2024, before Good Friday.
+func TestRebalance_MonthEnd(t *testing.T) {
+	var a, b []data.AssetData
+	for d := time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC); d.Before(time.Date(2024, 4, 6, 0, 0, 0, 0, time.UTC)); d = d.AddDate(0, 0, 1) {
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		pb := 10.0
+		if d.Day() >= 27 && d.Month() == time.March {
+			pb = 20
+		}
+		a = append(a, data.AssetData{Date: d, Open: 10, High: 10, Low: 10, Close: 10, Volume: 100})
+		b = append(b, data.AssetData{Date: d, Open: pb, High: pb, Low: pb, Close: pb, Volume: 100})
+	}
+	store := &fakeStore{bars: map[string][]data.AssetData{"A": a, "B": b}, rates: map[int64]float64{}}
+	p, err := NewPortfolio("me", 1000, []string{"A", "B"}, "rebalance:month-end", WithCalendar(CalendarNYSE),
+		WithWindow(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := p.Strategy.Name(); name != "rebalance:month-end" {
+		t.Errorf("name = %q", name)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dates []string
+	for _, tr := range results[0].Trades {
+		dates = append(dates, tr.Date.Format("2006-01-02"))
+	}
+	// Bought on the first bar, rebalanced on the 28th; the Good Friday
+	// bar is dropped and April has no month-end in the window.
+	if len(dates) != 4 || dates[0] != "2024-03-25" || dates[2] != "2024-03-28" || dates[3] != "2024-03-28" {
+		t.Errorf("trade dates = %v", dates)
+	}
+	if results[0].EffectiveEnd != "2024-04-05" || len(results[0].Dates) != 8 {
+		t.Errorf("simulated %s..%s, %d returns", results[0].EffectiveStart, results[0].EffectiveEnd, len(results[0].Dates))
+	}
+
+	for _, spec := range []string{"rebalance:month", "rebalance:week-end", "rebalance:year"} {
+		if _, err := NewStrategy(spec, nil); err != nil {
+			t.Errorf("%s: %v", spec, err)
+		}
+	}
+	if _, err := NewStrategy("rebalance:fortnight", nil); err == nil {
+		t.Error("rebalance:fortnight accepted")
+	}
+}
@@ -0,0 +1,48 @@
+package backtest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// SMACross's incremental averages trade exactly where averages
+// recomputed from the slice cross.
+func TestSMACross_MatchesRecomputedAverages(t *testing.T) {
+	tickers, hist := generateBenchData()
+	p, err := InitializePortfolio(
+		100_000, time.Time{}, time.Time{}, "sma", tickers, "smaCross:10:50:equalWeights", nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runOne(context.Background(), p, hist, nil)
+	if len(p.Trades) == 0 {
+		t.Fatal("no trades")
+	}
+	day := make(map[time.Time]int)
+	for i, b := range hist[tickers[0]] {
+		day[b.Date] = i
+	}
+	above := func(ticker string, d int) bool {
+		td := hist[ticker]
+		return SMA(td[d-10:d]) > SMA(td[d-50:d])
+	}
+	for _, tr := range p.Trades {
+		d := day[tr.Date]
+		if d <= 50 || above(tr.Ticker, d) != (tr.Side == "BUY") || above(tr.Ticker, d-1) == (tr.Side == "BUY") {
+			t.Errorf("%s %s on bar %d is not at a crossover", tr.Side, tr.Ticker, d)
+		}
+	}
+}
+
+// BenchmarkSMACrossLongPeriods shows a step's cost doesn't grow with
+// the averaging periods.
+func BenchmarkSMACrossLongPeriods(b *testing.B) {
+	tickers, hist := generateBenchData()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := newBenchPortfolio(tickers, &SMACross{Short: 50, Long: 400, BuyType: "equalWeights"})
+		runBenchSimulation(p, hist)
+	}
+}
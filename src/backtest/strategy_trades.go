@@ -0,0 +1,208 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"my-backtester/src/data"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TradeReplay is a Strategy that replays an externally produced trade
+// list instead of generating signals, so trades made elsewhere run
+// through the same Portfolio accounting and metrics as native strategies.
+//
+// Spec format: "trades:<path.csv>". The CSV needs a header naming the
+// columns ticker, side (buy/sell), qty, timestamp and price; extra columns
+// are ignored. See LoadTradeList for accepted aliases. Each trade fills at
+// its own price on the first bar dated on or after its timestamp's
+// calendar day. Trades dated before the backtest window, or that the
+// Portfolio rejects (insufficient cash or shares), are logged and skipped.
+type TradeReplay struct {
+	Path   string
+	trades []Trade
+	next   int
+}
+
+func NewTradeReplay(path string) (*TradeReplay, error) {
+	if path == "" {
+		return nil, fmt.Errorf("trade list path required")
+	}
+	trades, err := LoadTradeList(path)
+	if err != nil {
+		return nil, err
+	}
+	return &TradeReplay{Path: path, trades: trades}, nil
+}
+
+func init() {
+	RegisterStrategy(Component{
+		Name:  "trades",
+		Usage: "trades:<path.csv>",
+		Doc:   "replays an externally produced trade list",
+		Params: []Param{
+			{Name: "path", Type: "path", Doc: "CSV with ticker, side, qty, timestamp and price columns"},
+		},
+	}, func(arg string, _ map[string]any) (Strategy, error) {
+		if arg == "" {
+			return nil, fmt.Errorf("trades spec needs a CSV path: %q", "trades:")
+		}
+		return NewTradeReplay(arg)
+	})
+}
+
+func (s *TradeReplay) Name() string { return "trades:" + s.Path }
+
+// Tickers lists every ticker traded, sorted. InitializePortfolio uses it
+// when the portfolio config leaves Tickers empty.
+func (s *TradeReplay) Tickers() []string {
+	seen := make(map[string]bool)
+	var tickers []string
+	for _, t := range s.trades {
+		if !seen[t.Ticker] {
+			seen[t.Ticker] = true
+			tickers = append(tickers, t.Ticker)
+		}
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+func (s *TradeReplay) Step(
+	p *Portfolio, hist map[string][]data.AssetData, day int,
+) {
+	series := hist[p.Tickers[0]]
+	if day >= len(series) {
+		return
+	}
+	today := series[day].Date.Format("2006-01-02")
+	for ; s.next < len(s.trades); s.next++ {
+		t := s.trades[s.next]
+		tradeDay := t.Date.Format("2006-01-02")
+		if tradeDay > today {
+			return
+		}
+		if day == 0 && tradeDay < today {
+			logger.Warn("trade replay: trade before the window skipped", "portfolio", p.Pname,
+				"side", t.Side, "ticker", t.Ticker, "date", tradeDay)
+			continue
+		}
+		var err error
+		if t.Side == "BUY" {
+			err = p.Buy(t.Ticker, t.Amount, t.Price, series[day].Date)
+		} else {
+			err = p.Sell(t.Ticker, t.Amount, t.Price, series[day].Date)
+		}
+		if err != nil {
+			logger.Warn("trade replay: trade rejected", "portfolio", p.Pname, "date", tradeDay, "err", err)
+		}
+	}
+}
+
+// tradeTimeLayouts are the timestamp formats accepted in trade lists.
+var tradeTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05-07:00", // pandas to_csv of tz-aware timestamps
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// tradeColumnAliases maps each trade-list column to the header names
+// accepted for it, covering common exports (e.g. zipline transactions use
+// symbol, amount and dt).
+var tradeColumnAliases = map[string][]string{
+	"ticker":    {"ticker", "symbol", "sid"},
+	"side":      {"side"},
+	"qty":       {"qty", "quantity", "amount", "size"},
+	"timestamp": {"timestamp", "date", "dt", "time", "datetime"},
+	"price":     {"price"},
+}
+
+// LoadTradeList reads a trade-list CSV (see TradeReplay) and returns its
+// trades ordered by timestamp. Sides are normalized to "BUY"/"SELL".
+// When there is no side column, the sign of qty gives the side.
+func LoadTradeList(path string) ([]Trade, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("trade list %q: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("trade list %q: empty", path)
+	}
+
+	header := make(map[string]int)
+	for i, h := range rows[0] {
+		header[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	col := make(map[string]int)
+	for name, aliases := range tradeColumnAliases {
+		for _, a := range aliases {
+			if i, ok := header[a]; ok {
+				col[name] = i
+				break
+			}
+		}
+	}
+	for _, name := range []string{"ticker", "qty", "timestamp", "price"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("trade list %q: missing %q column", path, name)
+		}
+	}
+	sideCol, hasSide := col["side"]
+
+	trades := make([]Trade, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		line := i + 2
+		var t Trade
+		t.Ticker = strings.TrimSpace(row[col["ticker"]])
+		if t.Amount, err = strconv.ParseFloat(strings.TrimSpace(row[col["qty"]]), 64); err != nil {
+			return nil, fmt.Errorf("trade list %q line %d: qty: %w", path, line, err)
+		}
+		switch {
+		case !hasSide && t.Amount < 0:
+			t.Side, t.Amount = "SELL", -t.Amount
+		case !hasSide:
+			t.Side = "BUY"
+		case strings.EqualFold(strings.TrimSpace(row[sideCol]), "buy"):
+			t.Side = "BUY"
+		case strings.EqualFold(strings.TrimSpace(row[sideCol]), "sell"):
+			t.Side = "SELL"
+		default:
+			return nil, fmt.Errorf("trade list %q line %d: side %q", path, line, row[sideCol])
+		}
+		if t.Price, err = strconv.ParseFloat(strings.TrimSpace(row[col["price"]]), 64); err != nil {
+			return nil, fmt.Errorf("trade list %q line %d: price: %w", path, line, err)
+		}
+		if t.Date, err = parseTradeTime(strings.TrimSpace(row[col["timestamp"]])); err != nil {
+			return nil, fmt.Errorf("trade list %q line %d: %w", path, line, err)
+		}
+		if t.Ticker == "" || t.Amount <= 0 || t.Price <= 0 {
+			return nil, fmt.Errorf("trade list %q line %d: ticker, qty and price are required", path, line)
+		}
+		trades = append(trades, t)
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Date.Before(trades[j].Date)
+	})
+	return trades, nil
+}
+
+func parseTradeTime(s string) (time.Time, error) {
+	for _, layout := range tradeTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("timestamp %q: want YYYY-MM-DD or RFC 3339", s)
+}
@@ -0,0 +1,75 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTradeReplay_ReplaysThroughPortfolio(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trades.csv")
+	csv := "timestamp,ticker,side,qty,price,note\n" +
+		"2024-01-06,AAA,sell,5,12,weekend -> next bar\n" +
+		"2023-12-29,AAA,buy,1,9,before window\n" +
+		"2024-01-02T15:30:00Z,AAA,Buy,10,10,\n" +
+		"2024-01-03,AAA,sell,100,11,more than held\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	p, err := InitializePortfolio(
+		1000, start, start.AddDate(0, 0, 10), "ext", nil, "trades:"+path, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Tickers) != 1 || p.Tickers[0] != "AAA" {
+		t.Fatalf("Tickers = %v, want [AAA]", p.Tickers)
+	}
+
+	// Bars on Jan 2, 3, 4 and 8 (Jan 5-7 missing).
+	var bars []data.AssetData
+	for i, d := range []int{2, 3, 4, 8} {
+		c := 10 + float64(i)
+		bars = append(bars, data.AssetData{
+			Date: time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC),
+			Open: c, High: c, Low: c, Close: c,
+		})
+	}
+	runOne(context.Background(), p, map[string][]data.AssetData{"AAA": bars}, nil)
+
+	if len(p.Trades) != 2 {
+		t.Fatalf("trades = %+v, want the buy and the weekend sell", p.Trades)
+	}
+	if got := p.Trades[1].Date; !got.Equal(bars[3].Date) {
+		t.Errorf("weekend sell filled on %v, want %v", got, bars[3].Date)
+	}
+	// 1000 - 10*10 + 5*12 = 960 cash, 5 shares at the last close of 13.
+	last := p.PortfolioCloseValues[len(p.PortfolioCloseValues)-1]
+	if math.Abs(last-(960+5*13)) > 1e-9 {
+		t.Errorf("final value = %v, want %v", last, 960.0+5*13)
+	}
+}
+
+func TestLoadTradeList_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"missing column": "ticker,side,qty,price\nA,buy,1,1\n",
+		"bad side":       "ticker,side,qty,timestamp,price\nA,hold,1,2024-01-02,1\n",
+		"bad time":       "ticker,side,qty,timestamp,price\nA,buy,1,01/02/2024,1\n",
+	} {
+		path := filepath.Join(dir, "t.csv")
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTradeList(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my-backtester/src/data"
+	"time"
+)
+
+// BarStreamer is a Store that can stream bars instead of loading them;
+// *data.Store is one. RunStream needs it.
+type BarStreamer interface {
+	IterateBars(ctx context.Context, tickers []string, start, end time.Time, order data.BarOrder) (*data.BarIterator, error)
+}
+
+// StreamFeed replays stored history straight off a database cursor, one
+// date at a time, so only the subscribed tickers' bars up to the current
+// date are ever in memory (FeedTrader keeps those for the strategy to
+// look back over). The cursor is opened by the first Next.
+type StreamFeed struct {
+	store      BarStreamer
+	start, end time.Time
+	tickers    []string
+	it         *data.BarIterator
+	err        error
+}
+
+func NewStreamFeed(store BarStreamer, start, end time.Time) *StreamFeed {
+	return &StreamFeed{store: store, start: start, end: end}
+}
+
+func (f *StreamFeed) Subscribe(tickers []string) error {
+	if len(tickers) == 0 {
+		return fmt.Errorf("stream feed: no tickers")
+	}
+	f.tickers = tickers
+	return nil
+}
+
+// Next returns the next date's bars. Dates some subscribed ticker has no
+// bar on are returned too; FeedTrader skips them, as alignWindow does.
+func (f *StreamFeed) Next(ctx context.Context) (FeedEvent, bool) {
+	if f.err != nil || ctx.Err() != nil {
+		return FeedEvent{}, false
+	}
+	if f.it == nil {
+		end := f.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if f.it, f.err = f.store.IterateBars(ctx, f.tickers, f.start, end, data.BarsByDate); f.err != nil {
+			return FeedEvent{}, false
+		}
+	}
+	date, bars, ok := f.it.NextDate()
+	if !ok {
+		f.err = f.it.Err()
+		f.it.Close()
+		return FeedEvent{}, false
+	}
+	return FeedEvent{Date: date, Bars: bars}, true
+}
+
+// Err is the error that ended the feed early, if any.
+func (f *StreamFeed) Err() error { return f.err }
+
+// RunStream is Run for universes too large to load: each portfolio is
+// driven by a FeedTrader over a StreamFeed of its own tickers and
+// window, one portfolio at a time, instead of every portfolio sharing
+// the universe's history loaded up front. Results, output and the
+// benchmark are as Run's. Adjusted prices need whole series to
+// back-adjust, so portfolios with Prices "adjusted" are rejected; so are
+// AuditLookahead, which FeedTrader doesn't check, Margin, whose
+// interest is charged at rates aligned to the whole window, and DataGaps
+// other than skip, as FeedTrader only steps dates every ticker trades.
+func RunStream(ctx context.Context, store Store, portfolios []*Portfolio, output *OutputConfig) ([]Result, error) {
+	streamer, ok := store.(BarStreamer)
+	if !ok {
+		return nil, errors.New("run stream: the store can't stream bars")
+	}
+	for _, p := range portfolios {
+		if p.Prices == PricesAdjusted || p.AuditLookahead || p.Margin != nil || p.gaps() != GapsSkip {
+			return nil, fmt.Errorf("portfolio %s: adjusted prices, margin, the lookahead audit and data gaps other than skip need a batch run", p.Pname)
+		}
+	}
+	reporter, err := NewReporter(output)
+	if err != nil {
+		return nil, fmt.Errorf("output config: %w", err)
+	}
+	portfolios, _ = selectUniverses(ctx, store, portfolios)
+	var results []Result
+	prog, stop := startProgress(len(portfolios))
+	for _, p := range portfolios {
+		r, err := streamOne(ctx, store, streamer, p)
+		prog.Add()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			runLogger.Error("portfolio failed", "portfolio", p.Pname, "err", err)
+			continue
+		}
+		results = append(results, r)
+		if reporter != nil {
+			if werr := reporter.Write(r); werr != nil {
+				runLogger.Error("write result", "err", werr)
+			}
+		}
+	}
+	stop()
+	if reporter != nil {
+		if cerr := reporter.Close(); cerr != nil {
+			runLogger.Error("close output", "err", cerr)
+		}
+	}
+	if output != nil && output.Dir != "" {
+		if err := WriteArtifacts(output.Dir, results); err != nil {
+			runLogger.Error("write artifacts", "err", err)
+		}
+	}
+	if output != nil && output.Database {
+		if err := SaveResults(context.WithoutCancel(ctx), store, results); err != nil {
+			runLogger.Error("save results", "err", err)
+		}
+	}
+	return results, ctx.Err()
+}
+
+// streamOne runs a clone of p over a StreamFeed and returns its Result.
+func streamOne(ctx context.Context, store Store, streamer BarStreamer, p *Portfolio) (Result, error) {
+	clone, err := p.Clone()
+	if err != nil {
+		return Result{}, fmt.Errorf("clone: %w", err)
+	}
+	clone.store = store
+	if as, ok := store.(ActionStore); ok {
+		clone.actions = as.QueryActions(ctx, clone.Tickers, clone.StartTime, clone.EndTime)
+	}
+	clone.loadMetadata(ctx)
+	rf := clone.RiskFree
+	if rf == nil {
+		rf = DBRiskFree{store}
+	}
+	end := clone.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	rates := rf.RiskFreeRates(ctx, riskFreeStart(clone.StartTime), end)
+	feed := NewStreamFeed(streamer, clone.StartTime, clone.EndTime)
+	ft, err := NewFeedTrader(clone, feed, nil, rates)
+	if err != nil {
+		return Result{}, err
+	}
+	r := ft.Run(ctx)
+	if err := feed.Err(); err != nil {
+		return Result{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if clone.Benchmark != "" && !clone.EffectiveStart.IsZero() {
+		var bench []data.AssetData
+		if clone.BenchmarkSource != nil {
+			bench = clone.BenchmarkSource.BenchmarkBars(ctx, clone.Benchmark, clone.EffectiveStart, clone.EffectiveEnd)
+		} else {
+			bench = DBBenchmark{store}.BenchmarkBars(ctx, clone.Benchmark, clone.EffectiveStart, clone.EffectiveEnd)
+		}
+		clone.Metrics.BenchmarkReturn = benchmarkReturn(bench, clone.periodsPerYear())
+		r.Metrics.BenchmarkReturn = clone.Metrics.BenchmarkReturn
+		r.BenchmarkCurve = benchmarkCurve(bench, r.Dates, r.EquityCurve)
+	}
+	return r, nil
+}
@@ -0,0 +1,112 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"my-backtester/src/data"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// streamingStore streams fakeStore's bars merged by date.
+type streamingStore struct{ *fakeStore }
+
+func (s streamingStore) IterateBars(
+	ctx context.Context, tickers []string, start, end time.Time, order data.BarOrder,
+) (*data.BarIterator, error) {
+	var rows barRows
+	for _, t := range tickers {
+		for _, b := range s.bars[t] {
+			if !b.Date.Before(start) && !b.Date.After(end) {
+				rows = append(rows, barRow{t, b})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].bar.Date.Before(rows[j].bar.Date) })
+	return data.NewBarIterator(&rows), nil
+}
+
+type barRow struct {
+	ticker string
+	bar    data.AssetData
+}
+
+// barRows is a data.RowSource over a slice, consumed from the front.
+type barRows []barRow
+
+func (r *barRows) Next() bool { return len(*r) > 0 }
+func (r *barRows) Err() error { return nil }
+func (r *barRows) Close() error {
+	*r = nil
+	return nil
+}
+
+func (r *barRows) Scan(dest ...any) error {
+	row := (*r)[0]
+	*r = (*r)[1:]
+	*dest[0].(*time.Time) = row.bar.Date
+	*dest[1].(*string) = row.ticker
+	for i, v := range []float64{row.bar.Open, row.bar.High, row.bar.Low, row.bar.Close, row.bar.Volume} {
+		*dest[i+2].(*float64) = v
+	}
+	return nil
+}
+
+// A streamed run trades and reports exactly as the batch run, including
+// skipping the date one ticker has no bar on.
+func TestRunStream_MatchesRun(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 40; i++ {
+		a := 100 + 10*float64(i%7)
+		b := 50 + float64(i)
+		store.bars["A"] = append(store.bars["A"], data.AssetData{Date: day(i), Open: a, High: a, Low: a, Close: a, Volume: 1000})
+		if i != 17 {
+			store.bars["B"] = append(store.bars["B"], data.AssetData{Date: day(i), Open: b, High: b, Low: b, Close: b, Volume: 1000})
+		}
+		store.rates[day(i).Unix()] = 0.0001
+	}
+	newP := func() *Portfolio {
+		p, err := NewPortfolio("s", 10000, []string{"A", "B"}, "smaCross:3:5:equalWeights",
+			WithWindow(day(0), day(39)), WithBenchmark("B"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	batch, err := Run(context.Background(), store, []*Portfolio{newP()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed, err := RunStream(context.Background(), streamingStore{store}, []*Portfolio{newP()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, got := batch[0], streamed[0]
+	if len(want.Trades) == 0 || !reflect.DeepEqual(got.Trades, want.Trades) {
+		t.Errorf("trades = %+v\nwant %+v", got.Trades, want.Trades)
+	}
+	if !reflect.DeepEqual(got.EquityCurve, want.EquityCurve) || !reflect.DeepEqual(got.Dates, want.Dates) {
+		t.Errorf("equity curve differs:\n%v %v\n%v %v", got.Dates, got.EquityCurve, want.Dates, want.EquityCurve)
+	}
+	if got.Metrics.SharpeRatio != want.Metrics.SharpeRatio || got.Metrics.BenchmarkReturn != want.Metrics.BenchmarkReturn {
+		t.Errorf("Sharpe %v, benchmark %v; want %v, %v", got.Metrics.SharpeRatio, got.Metrics.BenchmarkReturn,
+			want.Metrics.SharpeRatio, want.Metrics.BenchmarkReturn)
+	}
+
+	if _, err := RunStream(context.Background(), store, []*Portfolio{newP()}, nil); err == nil {
+		t.Error("store without IterateBars: expected error")
+	}
+	adjusted := newP()
+	adjusted.Prices = PricesAdjusted
+	if _, err := RunStream(context.Background(), streamingStore{store}, []*Portfolio{adjusted}, nil); err == nil {
+		t.Error("adjusted prices: expected error")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunStream(ctx, streamingStore{store}, []*Portfolio{newP()}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled run: err = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// TaxConfig charges capital-gains tax on the gains the portfolio
+// realizes, as a taxable account pays it. Each ClosedTrade's PnL is a
+// short-term gain or loss, or a long-term one if it was held over
+// LongTermDays. Within a calendar year the two are netted as US rules
+// net them (a net loss on one side offsets a net gain on the other) and
+// the tax on the result is accrued as trades close, so a later loss in
+// the year refunds tax charged on earlier gains. A year's net loss
+// carries forward against the next year's gains. Unrealized gains are
+// untaxed until sold; Metrics.DeferredTax is what selling everything at
+// the end would owe.
+type TaxConfig struct {
+	ShortTermRate float64 `toml:"short_term_rate"` // e.g. 0.37
+	LongTermRate  float64 `toml:"long_term_rate"`  // e.g. 0.20
+	// LongTermDays is the holding period past which a gain is long-term;
+	// LongTermDays (365) when 0.
+	LongTermDays float64 `toml:"long_term_days"`
+}
+
+// WithTax charges tax on realized gains (see TaxConfig).
+func WithTax(t TaxConfig) Option {
+	return func(p *Portfolio) error {
+		for _, r := range []float64{t.ShortTermRate, t.LongTermRate} {
+			if !(r >= 0 && r < 1) {
+				return fmt.Errorf("tax rate %v: must be in [0, 1)", r)
+			}
+		}
+		if !(t.LongTermDays >= 0) || math.IsInf(t.LongTermDays, 1) {
+			return fmt.Errorf("tax long_term_days %v: must be non-negative", t.LongTermDays)
+		}
+		if t.LongTermDays == 0 {
+			t.LongTermDays = LongTermDays
+		}
+		p.Tax = &t
+		return nil
+	}
+}
+
+// owed is the tax on a year's net short- and long-term gains.
+func (t *TaxConfig) owed(short, long float64) float64 {
+	if short < 0 {
+		long += short
+		short = 0
+	}
+	if long < 0 {
+		short += long
+		long = 0
+	}
+	return math.Max(short, 0)*t.ShortTermRate + math.Max(long, 0)*t.LongTermRate
+}
+
+// taxState is the tax year in progress.
+type taxState struct {
+	year        int
+	short, long float64 // net gains realized so far; short starts at the loss carried in
+	charged     float64 // tax charged for the year so far
+	next        int     // first ClosedTrade not yet counted
+	paid        float64 // over the whole run
+}
+
+// add counts c's gain in its year, rolling the year over first if c
+// closed in a later one.
+func (s *taxState) add(t *TaxConfig, c ClosedTrade) {
+	if y := c.Exit.Year(); y != s.year {
+		carry := math.Min(s.short+s.long, 0)
+		*s = taxState{year: y, short: carry, next: s.next, paid: s.paid}
+	}
+	if c.HoldingDays > t.LongTermDays {
+		s.long += c.PnL
+	} else {
+		s.short += c.PnL
+	}
+}
+
+// accrueTax charges, or refunds, the change in the year's tax from the
+// trades closed since the last call.
+func (p *Portfolio) accrueTax(date time.Time) {
+	if p.Tax == nil {
+		return
+	}
+	s := &p.taxes
+	for _, c := range p.ClosedTrades[s.next:] {
+		s.add(p.Tax, c)
+	}
+	s.next = len(p.ClosedTrades)
+	due := p.Tax.owed(s.short, s.long) - s.charged
+	if due == 0 {
+		return
+	}
+	s.charged += due
+	s.paid += due
+	p.adjustCash(-due)
+	p.txLog().Debug("TAX", "portfolio", p.Pname, "tax", due, "short_term", s.short, "long_term", s.long, "date", formatDate(date))
+}
+
+// deferredTax is the further tax selling every open position at its
+// last price on end would owe, net of their entry fees; negative if the
+// sales would refund tax charged in end's year.
+func (p *Portfolio) deferredTax(end time.Time) float64 {
+	if p.Tax == nil {
+		return 0
+	}
+	s := p.taxes
+	// In ticker order, so the sum is the same from run to run.
+	tickers := make([]string, 0, len(p.Positions))
+	for t := range p.Positions {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	for _, ticker := range tickers {
+		pos := p.Positions[ticker]
+		if pos.Amount == 0 || pos.CurrentPrice == 0 {
+			continue
+		}
+		short := pos.Amount < 0
+		lots := pos.Lots
+		if len(lots) == 0 {
+			lots = []Lot{{Opened: pos.Opened, Amount: math.Abs(pos.Amount), Price: pos.AveragePrice, Fees: pos.Fees}}
+		}
+		for _, l := range lots {
+			s.add(p.Tax, closedTrade(ticker, l.Opened, end, l.Amount, l.Price, pos.CurrentPrice, l.Fees, short))
+		}
+	}
+	return p.Tax.owed(s.short, s.long) - s.charged
+}
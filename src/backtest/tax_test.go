@@ -0,0 +1,55 @@
+package backtest
+
+import (
+	"testing"
+	"time"
+)
+
+// Gains are taxed as they are realized, a later loss in the year
+// refunds the tax, the year's net loss carries into the next, and gains
+// held past a year are taxed at the long-term rate.
+func TestTax(t *testing.T) {
+	date := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	p, err := NewPortfolio("p", 100_000, []string{"A", "B", "C"}, "greedy",
+		WithTax(TaxConfig{ShortTermRate: 0.4, LongTermRate: 0.2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		trade func() error
+		paid  float64
+	}{
+		{func() error { return p.Buy("A", 10, 10, date(2024, 1, 1)) }, 0},
+		{func() error { return p.Sell("A", 10, 20, date(2024, 1, 11)) }, 40}, // +100 short-term
+		{func() error { return p.Buy("A", 10, 20, date(2024, 2, 1)) }, 40},
+		{func() error { return p.Sell("A", 10, 5, date(2024, 3, 1)) }, 0}, // -150: net -50, refunded
+		{func() error { return p.Buy("B", 10, 10, date(2024, 3, 1)) }, 0},
+		{func() error { return p.Buy("A", 10, 10, date(2025, 1, 2)) }, 0},
+		{func() error { return p.Sell("A", 10, 20, date(2025, 1, 10)) }, 20}, // +100 less the -50 carried
+		{func() error { return p.Sell("B", 10, 30, date(2025, 6, 2)) }, 60},  // +200 long-term
+		{func() error { return p.Buy("C", 10, 10, date(2025, 7, 1)) }, 60},
+	} {
+		if err := step.trade(); err != nil {
+			t.Fatal(err)
+		}
+		if !closeTo(p.taxes.paid, step.paid) {
+			t.Fatalf("after %+v: tax paid %v, want %v", p.Trades[len(p.Trades)-1], p.taxes.paid, step.paid)
+		}
+	}
+	// 100000 - 100 + 200 - 200 + 50 - 100 - 100 + 200 + 300 - 100, less the tax.
+	if !closeTo(p.BuyingPower, 100_150-60) {
+		t.Errorf("cash = %v", p.BuyingPower)
+	}
+	p.Positions["C"].CurrentPrice = 15
+	if got := p.deferredTax(date(2025, 12, 31)); !closeTo(got, 20) {
+		t.Errorf("deferred tax = %v, want 20", got)
+	}
+	p.Positions["C"].CurrentPrice = 5
+	if got := p.deferredTax(date(2025, 12, 31)); !closeTo(got, -20) {
+		t.Errorf("deferred tax on a loss = %v, want -20", got)
+	}
+
+	if _, err := NewPortfolio("p", 1000, []string{"A"}, "greedy", WithTax(TaxConfig{ShortTermRate: 1.5})); err == nil {
+		t.Error("a 150% rate was accepted")
+	}
+}
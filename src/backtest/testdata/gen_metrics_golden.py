@@ -0,0 +1,100 @@
+"""Reference implementation for metrics_golden.json.
+
+Recomputes the expected metrics for each case with Python's statistics
+module, independently of metrics.go. Run it only when a case is added or
+a formula is deliberately changed:
+
+    python3 gen_metrics_golden.py > metrics_golden.json
+"""
+import json
+import math
+import statistics
+
+ANNUAL = 252
+
+
+def lcg_returns(n, seed):
+    """Deterministic pseudo-random daily returns in [-2%, +2%)."""
+    out, x = [], seed
+    for _ in range(n):
+        x = (1103515245 * x + 12345) % 2**31
+        out.append(round((x / 2**31 - 0.5) * 0.04, 6))
+    return out
+
+
+def returns_from_equity(equity):
+    return [(b - a) / a for a, b in zip(equity, equity[1:])]
+
+
+def equity_from_returns(returns, start=100_000.0):
+    equity, v = [], start
+    for r in returns:
+        v *= 1 + r
+        equity.append(v)
+    return equity
+
+
+def sharpe(excess):
+    if len(excess) < 2:
+        return 0.0
+    sd = statistics.stdev(excess)
+    return 0.0 if sd == 0 else statistics.fmean(excess) / sd * math.sqrt(ANNUAL)
+
+
+def sortino(excess):
+    down = [e for e in excess if e < 0]
+    if len(down) < 2:
+        return 0.0
+    sd = statistics.stdev(down)
+    return 0.0 if sd == 0 else statistics.fmean(excess) / sd * math.sqrt(ANNUAL)
+
+
+def cagr(returns):
+    if not returns:
+        return 0.0
+    growth = math.prod(1 + r for r in returns)
+    if growth <= 0:
+        return -100.0
+    return (growth ** (ANNUAL / len(returns)) - 1) * 100
+
+
+def max_drawdown(equity):
+    peak, worst = equity[0], 0.0
+    for v in equity:
+        peak = max(peak, v)
+        if peak > 0:
+            worst = max(worst, (peak - v) / peak)
+    return worst * 100
+
+
+def case(name, returns, risk_free, equity=None):
+    if equity is None:
+        equity = equity_from_returns(returns)
+    excess = [r - f for r, f in zip(returns, risk_free)]
+    return {
+        "name": name,
+        "returns": returns,
+        "risk_free": risk_free,
+        "equity": equity,
+        "sharpe_ratio": sharpe(excess),
+        "sortino_ratio": sortino(excess),
+        "annual_return": cagr(returns),
+        "max_drawdown": max_drawdown(equity),
+        "standard_dev": statistics.stdev(returns) * math.sqrt(ANNUAL)
+        if len(returns) >= 2 else 0.0,
+    }
+
+
+drawdown_equity = [100.0, 120.0, 90.0, 130.0, 65.0, 80.0]
+walk = lcg_returns(120, 42)
+cases = [
+    case("alternating", [0.01, -0.02, 0.03, -0.01, 0.02], [0.0] * 5),
+    case("with_risk_free",
+         [0.004, -0.003, 0.006, 0.001, -0.005, 0.002, 0.003, -0.001, 0.004, 0.0],
+         [0.0002] * 10),
+    case("constant_year", [0.001] * ANNUAL, [0.0] * ANNUAL),
+    case("drawdown", returns_from_equity(drawdown_equity), [0.0001] * 5,
+         drawdown_equity[1:]),
+    case("random_walk", walk, [0.00015] * len(walk)),
+]
+print(json.dumps({"cases": cases}, indent=1))
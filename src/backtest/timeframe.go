@@ -0,0 +1,72 @@
+package backtest
+
+import (
+	"fmt"
+	"my-backtester/src/data"
+)
+
+// Timeframe is the bar length a portfolio simulates on. Data is stored
+// daily; longer timeframes resample it (see data.Resample), so the
+// strategy steps once a week or month and metrics annualize by weeks or
+// months rather than sessions.
+type Timeframe string
+
+const (
+	// TimeframeDaily simulates the stored daily bars. The default.
+	TimeframeDaily Timeframe = "daily"
+	// TimeframeWeekly simulates one bar per ISO week, dated on its last
+	// session: 52 a year.
+	TimeframeWeekly Timeframe = "weekly"
+	// TimeframeMonthly simulates one bar per calendar month, dated on its
+	// last session: 12 a year.
+	TimeframeMonthly Timeframe = "monthly"
+)
+
+// ParseTimeframe validates a Timeframe config value; "" selects
+// TimeframeDaily.
+func ParseTimeframe(s string) (Timeframe, error) {
+	switch tf := Timeframe(s); tf {
+	case "":
+		return TimeframeDaily, nil
+	case TimeframeDaily, TimeframeWeekly, TimeframeMonthly:
+		return tf, nil
+	}
+	return "", fmt.Errorf("timeframe %q: must be daily, weekly or monthly", s)
+}
+
+// period is tf's calendar.Period name, "" for daily bars.
+func (tf Timeframe) period() string {
+	switch tf {
+	case TimeframeWeekly:
+		return "week"
+	case TimeframeMonthly:
+		return "month"
+	}
+	return ""
+}
+
+// PeriodsPerYear is the number of tf's bars in a year, 0 for daily bars,
+// whose count is the Calendar's.
+func (tf Timeframe) PeriodsPerYear() float64 {
+	switch tf {
+	case TimeframeWeekly:
+		return 52
+	case TimeframeMonthly:
+		return 12
+	}
+	return 0
+}
+
+// resample aggregates the daily series of p's tickers into its
+// Timeframe's bars; hist itself for daily bars.
+func (p *Portfolio) resample(hist map[string][]data.AssetData) map[string][]data.AssetData {
+	every := p.Timeframe.period()
+	if every == "" {
+		return hist
+	}
+	out := make(map[string][]data.AssetData, len(p.Tickers))
+	for _, t := range p.Tickers {
+		out[t] = data.Resample(hist[t], every)
+	}
+	return out
+}
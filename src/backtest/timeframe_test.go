@@ -0,0 +1,45 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func TestTimeframe_Weekly(t *testing.T) {
+	var a []data.AssetData
+	price := 10.0
+	for d := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); d.Before(time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)); d = d.AddDate(0, 0, 1) {
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		a = append(a, data.AssetData{Date: d, Open: price, High: price, Low: price, Close: price, Volume: 100})
+		price++
+	}
+	store := &fakeStore{bars: map[string][]data.AssetData{"A": a}, rates: map[int64]float64{}}
+	p, err := NewPortfolio("weekly", 1000, []string{"A"}, "greedy", WithTimeframe(TimeframeWeekly),
+		WithWindow(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	// Three weeks of five sessions, each bar dated on its Friday.
+	if len(r.Trades) == 0 || !r.Trades[0].Date.Equal(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)) || r.Trades[0].Price != 14 {
+		t.Errorf("first trade = %+v, want the first week's close", r.Trades)
+	}
+	if len(r.Dates) != 2 || r.Dates[1] != "2024-01-19" {
+		t.Errorf("returns dated %v", r.Dates)
+	}
+	if n := p.periodsPerYear(); n != 52 {
+		t.Errorf("periods per year = %v, want 52", n)
+	}
+
+	if _, err := ParseTimeframe("hourly"); err == nil {
+		t.Error("ParseTimeframe accepted hourly")
+	}
+}
@@ -0,0 +1,333 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"my-backtester/src/calendar"
+	"my-backtester/src/data"
+	"sort"
+	"time"
+)
+
+// UniverseConfig picks a portfolio's tickers from the database instead
+// of listing them, judged point-in-time on the bars before the run
+// starts (see data.SelectUniverse): every ticker that trades through the
+// lookback window, less those the other filters set drop. With Every,
+// the universe is chosen again on the first day of each week, month,
+// quarter or year, each time on the bars before that day; the portfolio
+// then holds the union of them all, and StrategyContext.Universe says
+// which are members on a given bar.
+//
+// With Index, the candidates are instead the tickers that were members
+// of that index at any time during the run, from the index_members table
+// (see data.Store.SetIndexMembers), and a ticker is only in the universe
+// on the bars it was a member on. Tickers that later left the index or
+// stopped trading are included, so a universe-wide backtest isn't
+// confined to today's survivors. Only the filters set explicitly apply
+// on top of membership.
+type UniverseConfig struct {
+	// LookbackDays is the calendar days of bars before each selection
+	// that the filters judge; 365 when 0.
+	LookbackDays int `toml:"lookback_days"`
+	// MinCoverage is the share of the lookback's trading dates a ticker
+	// needs bars on; data.MinCoverage when 0.
+	MinCoverage     float64  `toml:"min_coverage"`
+	MinDollarVolume float64  `toml:"min_dollar_volume"` // mean Close*Volume a bar
+	MinPrice        float64  `toml:"min_price"`         // last close before the selection
+	Sectors         []string `toml:"sectors"`           // any of, from the ticker_sectors table
+	// ListFile names a file of candidate tickers, one per line or comma
+	// separated; the portfolio's Tickers, when set, are candidates too.
+	ListFile string `toml:"list_file"`
+	Every    string `toml:"every"` // "" selects once; "week", "month", "quarter" or "year"
+	Index    string `toml:"index"` // name in index_members, e.g. "SP500"; "" for none
+
+	list []string // read from ListFile by WithUniverse
+}
+
+// UniverseStore is a Store that can select a universe, as *data.Store
+// does.
+type UniverseStore interface {
+	SelectUniverse(ctx context.Context, asOf time.Time, lookbackDays int, filters ...data.UniverseFilter) ([]string, error)
+}
+
+// IndexStore is a Store holding index membership history, as
+// *data.Store does.
+type IndexStore interface {
+	IndexMembers(ctx context.Context, index string, start, end time.Time) ([]data.IndexMembership, error)
+}
+
+// WithUniverse selects p's tickers at the start of each run (see
+// UniverseConfig). Tickers already set are the candidates it chooses
+// from, along with u's ListFile.
+func WithUniverse(u UniverseConfig) Option {
+	return func(p *Portfolio) error {
+		if u.LookbackDays < 0 {
+			return fmt.Errorf("universe lookback_days %d: must be non-negative", u.LookbackDays)
+		}
+		if u.LookbackDays == 0 {
+			u.LookbackDays = 365
+		}
+		if !(u.MinCoverage >= 0 && u.MinCoverage <= 1) {
+			return fmt.Errorf("universe min_coverage %v: must be in [0, 1]", u.MinCoverage)
+		}
+		if u.Every != "" {
+			if _, err := calendar.ParsePeriod(u.Every); err != nil {
+				return fmt.Errorf("universe every: %w", err)
+			}
+		}
+		if u.ListFile != "" {
+			list, err := data.ReadTickerList(u.ListFile)
+			if err != nil {
+				return fmt.Errorf("universe list_file: %w", err)
+			}
+			u.list = list
+		}
+		p.Universe = &u
+		return nil
+	}
+}
+
+// filters are u's data.UniverseFilters judged on bars, less the
+// candidate list. Coverage always applies without an Index, and with one
+// only when MinCoverage is set: an index member that listed during the
+// lookback is still a member.
+func (u *UniverseConfig) filters() []data.UniverseFilter {
+	var filters []data.UniverseFilter
+	if u.Index == "" || u.MinCoverage > 0 {
+		filters = append(filters, data.Coverage(u.MinCoverage))
+	}
+	if u.MinDollarVolume > 0 {
+		filters = append(filters, data.MinDollarVolume(u.MinDollarVolume))
+	}
+	if u.MinPrice > 0 {
+		filters = append(filters, data.MinPrice(u.MinPrice))
+	}
+	if len(u.Sectors) > 0 {
+		filters = append(filters, data.InSectors(u.Sectors...))
+	}
+	return filters
+}
+
+// selections are the dates u is chosen on over [start, end]: start,
+// then with Every the first day of each later period.
+func (u *UniverseConfig) selections(start, end time.Time) []time.Time {
+	dates := []time.Time{start}
+	if u.Every == "" || end.IsZero() {
+		return dates
+	}
+	for d := start.AddDate(0, 0, 1); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if calendar.Period(d, u.Every) != calendar.Period(d.AddDate(0, 0, -1), u.Every) {
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+// universeMembers are the tickers selected on a date, members until the
+// next selection.
+type universeMembers struct {
+	from    time.Time
+	tickers []string
+}
+
+// selectUniverse chooses p's universe from store, setting its Tickers to
+// every ticker ever selected and recording each selection.
+func (p *Portfolio) selectUniverse(ctx context.Context, store Store) error {
+	u := p.Universe
+	candidates := append(append([]string(nil), p.Tickers...), u.list...)
+	if u.Index != "" {
+		if err := p.loadIndex(ctx, store); err != nil {
+			return err
+		}
+		candidates = indexCandidates(p.spells, candidates)
+		if len(candidates) == 0 {
+			return fmt.Errorf("universe: no ticker is in index %s during the run", u.Index)
+		}
+	}
+	filters := u.filters()
+	if u.Index != "" && len(filters) == 0 {
+		p.Tickers, p.members = candidates, nil
+		runLogger.Info("universe selected", "portfolio", p.Pname, "index", u.Index, "tickers", len(candidates))
+		return nil
+	}
+	if len(candidates) > 0 {
+		filters = append(filters, data.InList(candidates...))
+	}
+	us, ok := store.(UniverseStore)
+	if !ok {
+		return fmt.Errorf("universe: store can't select one")
+	}
+	union := make(map[string]bool)
+	var members []universeMembers
+	for _, d := range u.selections(p.StartTime, p.EndTime) {
+		tickers, err := us.SelectUniverse(ctx, d, u.LookbackDays, filters...)
+		if err != nil {
+			return fmt.Errorf("universe on %s: %w", formatDate(d), err)
+		}
+		for _, t := range tickers {
+			union[t] = true
+		}
+		members = append(members, universeMembers{d, tickers})
+	}
+	tickers := make([]string, 0, len(union))
+	for t := range union {
+		tickers = append(tickers, t)
+	}
+	if len(tickers) == 0 {
+		return fmt.Errorf("universe: no ticker passes its filters on %s", formatDate(p.StartTime))
+	}
+	sort.Strings(tickers)
+	p.Tickers, p.members = tickers, members
+	runLogger.Info("universe selected", "portfolio", p.Pname, "tickers", len(tickers), "selections", len(members))
+	return nil
+}
+
+// loadIndex reads the membership of p's Universe.Index over its window
+// from store.
+func (p *Portfolio) loadIndex(ctx context.Context, store Store) error {
+	is, ok := store.(IndexStore)
+	if !ok {
+		return fmt.Errorf("universe: store has no index membership")
+	}
+	spells, err := is.IndexMembers(ctx, p.Universe.Index, p.StartTime, p.EndTime)
+	if err != nil {
+		return fmt.Errorf("universe index %s: %w", p.Universe.Index, err)
+	}
+	p.spells = spells
+	return nil
+}
+
+// indexCandidates returns, sorted, the tickers with a spell in spells,
+// limited to list when it is non-empty.
+func indexCandidates(spells []data.IndexMembership, list []string) []string {
+	listed := make(map[string]bool, len(list))
+	for _, t := range list {
+		listed[t] = true
+	}
+	seen := make(map[string]bool)
+	var tickers []string
+	for _, s := range spells {
+		if !seen[s.Ticker] && (len(list) == 0 || listed[s.Ticker]) {
+			seen[s.Ticker] = true
+			tickers = append(tickers, s.Ticker)
+		}
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+// selectUniverses returns portfolios with those that have a Universe
+// replaced by clones holding the tickers it selected, and the names of
+// those whose universe couldn't be selected, which are left out.
+func selectUniverses(ctx context.Context, store Store, portfolios []*Portfolio) ([]*Portfolio, []string) {
+	out := make([]*Portfolio, 0, len(portfolios))
+	var skipped []string
+	for _, p := range portfolios {
+		if p.Universe == nil {
+			out = append(out, p)
+			continue
+		}
+		clone, err := p.Clone()
+		if err == nil {
+			err = clone.selectUniverse(ctx, store)
+		}
+		if err != nil {
+			runLogger.Error("select universe", "portfolio", p.Pname, "err", err)
+			skipped = append(skipped, p.Pname)
+			continue
+		}
+		out = append(out, clone)
+	}
+	return out, skipped
+}
+
+// ErrNotInUniverse refuses an order that would open or add to a position
+// in a ticker that isn't a member of the portfolio's universe on the
+// order's date. Orders that shrink or close a position always pass, so a
+// ticker that leaves the universe can still be sold.
+var ErrNotInUniverse = errors.New("not in universe")
+
+// Universe is the portfolio's tickers that are members of its universe
+// on the current bar: all of them unless its UniverseConfig has Every or
+// Index.
+func (c StrategyContext) Universe() []string {
+	return c.p.universeOn(c.Date())
+}
+
+// tradable is the current bar's Universe followed by the portfolio's
+// other tickers it still holds, in Tickers order: the built-in strategies
+// enter members only, but can exit a position whose ticker has left.
+func (c StrategyContext) tradable() []string {
+	tickers := c.Universe()
+	if len(tickers) == len(c.p.Tickers) {
+		return tickers
+	}
+	in := make(map[string]bool, len(tickers))
+	for _, t := range tickers {
+		in[t] = true
+	}
+	out := append([]string(nil), tickers...)
+	for _, t := range c.p.Tickers {
+		if pos, ok := c.p.Positions[t]; ok && pos.Amount != 0 && !in[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// inUniverse reports whether ticker is a member of p's universe on date.
+func (p *Portfolio) inUniverse(ticker string, date time.Time) bool {
+	if len(p.members) == 0 && p.spells == nil {
+		return true
+	}
+	for _, t := range p.universeOn(date) {
+		if t == ticker {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUniverse returns ErrNotInUniverse if moving ticker's position by
+// shares on date would open or grow it while ticker isn't a member.
+func (p *Portfolio) checkUniverse(ticker string, shares float64, date time.Time) error {
+	held := 0.0
+	if pos, ok := p.Positions[ticker]; ok {
+		held = pos.Amount
+	}
+	if after := held + shares; held*after >= 0 && math.Abs(after) <= math.Abs(held) {
+		return nil
+	}
+	if p.inUniverse(ticker, date) {
+		return nil
+	}
+	return ErrNotInUniverse
+}
+
+// universeOn is p's tickers that are members of its universe on now.
+func (p *Portfolio) universeOn(now time.Time) []string {
+	tickers, members := p.Tickers, p.members
+	if len(members) > 0 {
+		i := sort.Search(len(members), func(i int) bool { return members[i].from.After(now) }) - 1
+		if i < 0 {
+			i = 0
+		}
+		tickers = members[i].tickers
+	}
+	if p.spells == nil {
+		return tickers
+	}
+	in := make(map[string]bool)
+	for _, t := range data.MembersOn(p.spells, now) {
+		in[t] = true
+	}
+	var out []string
+	for _, t := range tickers {
+		if in[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,214 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my-backtester/src/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// universeStore selects A and B before March and B and C after.
+type universeStore struct {
+	*fakeStore
+	asOf []time.Time
+}
+
+func (s *universeStore) SelectUniverse(
+	ctx context.Context, asOf time.Time, lookbackDays int, filters ...data.UniverseFilter,
+) ([]string, error) {
+	s.asOf = append(s.asOf, asOf)
+	stats := []data.TickerStats{
+		{Ticker: "A", Spans: true, Bars: 1, Sessions: 1},
+		{Ticker: "B", Spans: true, Bars: 1, Sessions: 1},
+		{Ticker: "C", Spans: true, Bars: 1, Sessions: 1},
+	}
+	if asOf.Month() < time.March {
+		stats[2].Spans = false
+	} else {
+		stats[0].Spans = false
+	}
+	return data.FilterUniverse(stats, filters...), nil
+}
+
+// indexStore has A in the index throughout, B until it is delisted on
+// March 1st and C from its listing on March 4th.
+type indexStore struct {
+	*fakeStore
+}
+
+func (s *indexStore) IndexMembers(
+	ctx context.Context, index string, start, end time.Time,
+) ([]data.IndexMembership, error) {
+	if index != "TEST" {
+		return nil, nil
+	}
+	return []data.IndexMembership{
+		{Ticker: "A", Added: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Ticker: "B", Added: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Removed: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{Ticker: "C", Added: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+	}, nil
+}
+
+// indexBars are weekday bars from February 26th to March 8th 2024 for
+// A and D throughout, B until it leaves indexStore's index and C once it
+// joins.
+func indexBars() map[string][]data.AssetData {
+	bars := make(map[string][]data.AssetData)
+	for d := time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC); d.Before(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)); d = d.AddDate(0, 0, 1) {
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		tickers := []string{"A", "D"}
+		if d.Before(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+			tickers = append(tickers, "B")
+		} else {
+			tickers = append(tickers, "C")
+		}
+		for _, t := range tickers {
+			bars[t] = append(bars[t], data.AssetData{Date: d, Open: 10, High: 10, Low: 10, Close: 10, Volume: 100})
+		}
+	}
+	return bars
+}
+
+func TestUniverse_Index(t *testing.T) {
+	store := &indexStore{fakeStore: &fakeStore{bars: indexBars(), rates: map[int64]float64{}}}
+	p, err := NewPortfolio("u", 1000, nil, "rebalance:week",
+		WithWindow(time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)),
+		WithUniverse(UniverseConfig{Index: "TEST"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if !reflect.DeepEqual(r.Tickers, []string{"A", "B", "C"}) {
+		t.Errorf("tickers = %v, want every past and present member", r.Tickers)
+	}
+	if r.EffectiveStart != "2024-02-26" || r.EffectiveEnd != "2024-03-08" {
+		t.Errorf("ran %s to %s, want the whole window despite B and C's partial histories", r.EffectiveStart, r.EffectiveEnd)
+	}
+	// Bought A and B on the first bar; the next week's rebalance sells B,
+	// no longer a member, at its last close and buys C.
+	var trades []string
+	for _, tr := range r.Trades {
+		trades = append(trades, tr.Date.Format("01-02")+" "+tr.Side+" "+tr.Ticker)
+	}
+	want := []string{"02-26 BUY A", "02-26 BUY B", "03-04 SELL B", "03-04 BUY C"}
+	if !reflect.DeepEqual(trades, want) {
+		t.Errorf("trades = %v, want %v", trades, want)
+	}
+
+	p, err = NewPortfolio("u", 1000, nil, "rebalance:week", WithUniverse(UniverseConfig{Index: "NONE"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := Run(context.Background(), store, []*Portfolio{p}, nil); len(results) != 0 {
+		t.Error("ran a universe with no index members")
+	}
+}
+
+// Only members can be bought or shorted on a date, though a held ticker
+// that left can still be sold; buyAndHold and equalWeights see only the
+// first bar's members, not C.
+func TestUniverse_RefusesNonMembers(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 2, d, 0, 0, 0, 0, time.UTC) }
+	store := &indexStore{fakeStore: &fakeStore{bars: indexBars(), rates: map[int64]float64{}}}
+	p, err := NewPortfolio("u", 1000, nil, "buyAndHold:equalWeights",
+		WithWindow(day(26), day(26).AddDate(0, 0, 11)), WithUniverse(UniverseConfig{Index: "TEST"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bought []string
+	for _, tr := range results[0].Trades {
+		bought = append(bought, fmt.Sprintf("%s %s %s %.0f", tr.Date.Format("01-02"), tr.Side, tr.Ticker, tr.Amount))
+	}
+	// equalWeights splits the cash two ways, not three, and B is sold
+	// when it delists.
+	want := []string{"02-26 BUY A 50", "02-26 BUY B 25", "03-04 SELL B 25"}
+	if !reflect.DeepEqual(bought, want) {
+		t.Errorf("trades = %v, want %v", bought, want)
+	}
+
+	p, err = NewPortfolio("u", 1000, []string{"A", "B", "C"}, "greedy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.AllowShort = true
+	p.spells, _ = store.IndexMembers(context.Background(), "TEST", time.Time{}, time.Time{})
+	march := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	if err := p.Buy("C", 1, 10, day(27)); !errors.Is(err, ErrNotInUniverse) {
+		t.Errorf("buying C before it joined: %v, want ErrNotInUniverse", err)
+	}
+	if err := p.Buy("B", 2, 10, day(27)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Buy("B", 1, 10, march(4)); !errors.Is(err, ErrNotInUniverse) {
+		t.Errorf("adding to B after it left: %v, want ErrNotInUniverse", err)
+	}
+	if err := p.Sell("B", 2, 10, march(4)); err != nil {
+		t.Errorf("selling B after it left: %v", err)
+	}
+	if err := p.Sell("B", 1, 10, march(4)); !errors.Is(err, ErrNotInUniverse) {
+		t.Errorf("shorting B after it left: %v, want ErrNotInUniverse", err)
+	}
+	if err := p.Buy("C", 1, 10, march(4)); err != nil {
+		t.Errorf("buying C once a member: %v", err)
+	}
+}
+
+func TestUniverse(t *testing.T) {
+	bars := make(map[string][]data.AssetData)
+	for d := time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC); d.Before(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)); d = d.AddDate(0, 0, 1) {
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		for _, t := range []string{"A", "B", "C", "D"} {
+			bars[t] = append(bars[t], data.AssetData{Date: d, Open: 10, High: 10, Low: 10, Close: 10, Volume: 100})
+		}
+	}
+	store := &universeStore{fakeStore: &fakeStore{bars: bars, rates: map[int64]float64{}}}
+	p, err := NewPortfolio("u", 1000, nil, "rebalance:week",
+		WithWindow(time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)),
+		WithUniverse(UniverseConfig{Every: "month"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(context.Background(), store, []*Portfolio{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if !reflect.DeepEqual(r.Tickers, []string{"A", "B", "C"}) {
+		t.Errorf("tickers = %v, want every ticker ever selected", r.Tickers)
+	}
+	if len(store.asOf) != 2 || !store.asOf[1].Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("selected on %v, want the start and March 1st", store.asOf)
+	}
+	if len(p.Tickers) != 0 {
+		t.Errorf("the configured portfolio's tickers became %v", p.Tickers)
+	}
+	// Bought A and B on the first bar; the next week's rebalance, after
+	// March's selection, sells A for C.
+	var trades []string
+	for _, tr := range r.Trades {
+		trades = append(trades, tr.Date.Format("01-02")+" "+tr.Side+" "+tr.Ticker)
+	}
+	want := []string{"02-26 BUY A", "02-26 BUY B", "03-04 SELL A", "03-04 BUY C"}
+	if !reflect.DeepEqual(trades, want) {
+		t.Errorf("trades = %v, want %v", trades, want)
+	}
+
+	if _, err := NewPortfolio("u", 1000, nil, "greedy", WithUniverse(UniverseConfig{Every: "fortnight"})); err == nil {
+		t.Error("WithUniverse accepted every = fortnight")
+	}
+}
@@ -0,0 +1,369 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"my-backtester/src/data"
+	"sort"
+	"time"
+)
+
+// WalkForwardConfig controls walk-forward analysis (see WalkForward).
+// Window sizes count bars of the portfolio's aligned history.
+type WalkForwardConfig struct {
+	InSample    int `toml:"in_sample"`     // bars each candidate is scored on
+	OutOfSample int `toml:"out_of_sample"` // bars the winner then trades
+	Step        int `toml:"step"`          // bars between windows; default out_of_sample, at least that
+	// Objective is the result field the in-sample runs are ranked by,
+	// highest first, or lowest first with a leading "-" (e.g.
+	// "-MaxDrawdown"). Default "SharpeRatio".
+	Objective string `toml:"objective"`
+	// Strategies are the candidate specs; default the portfolio's own.
+	// Grid lists values for Params keys, and every combination is tried
+	// with every strategy, over the portfolio's own Params.
+	Strategies []string         `toml:"strategies"`
+	Grid       map[string][]any `toml:"grid"`
+}
+
+// WalkForwardWindow is one step of a walk-forward run: the in-sample
+// span, the candidate that scored best on it and its Score, and the
+// out-of-sample span it was then traded over with its Metrics there.
+type WalkForwardWindow struct {
+	InStart, InEnd   string // YYYY-MM-DD
+	OutStart, OutEnd string
+	Strategy         string
+	Params           map[string]any
+	Score            float64
+	Metrics          Metrics
+}
+
+// candidate is one strategy configuration tried in-sample.
+type candidate struct {
+	spec   string
+	params map[string]any
+}
+
+// objective parses a WalkForwardConfig.Objective into a result field and
+// whether lower is better.
+func (c *WalkForwardConfig) objective() (field string, minimize bool, err error) {
+	field, minimize, err = objectiveField(c.Objective)
+	if err != nil {
+		return "", false, fmt.Errorf("walk-forward %w", err)
+	}
+	return field, minimize, nil
+}
+
+func (c *WalkForwardConfig) validate() error {
+	if c.InSample < 2 || c.OutOfSample < 2 {
+		return fmt.Errorf("walk-forward windows %d/%d: need at least 2 bars each", c.InSample, c.OutOfSample)
+	}
+	if c.Step != 0 && c.Step < c.OutOfSample {
+		return fmt.Errorf("walk-forward step %d: must be at least out_of_sample (%d) so test windows don't overlap", c.Step, c.OutOfSample)
+	}
+	_, _, err := c.objective()
+	return err
+}
+
+// candidates is every strategy spec crossed with every combination of
+// Grid values, layered over base.
+func (c *WalkForwardConfig) candidates(spec string, base map[string]any) []candidate {
+	specs := c.Strategies
+	if len(specs) == 0 {
+		specs = []string{spec}
+	}
+	keys := make([]string, 0, len(c.Grid))
+	for k := range c.Grid {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	combos := []map[string]any{base}
+	for _, k := range keys {
+		var next []map[string]any
+		for _, combo := range combos {
+			for _, v := range c.Grid[k] {
+				m := make(map[string]any, len(combo)+1)
+				for ck, cv := range combo {
+					m[ck] = cv
+				}
+				m[k] = v
+				next = append(next, m)
+			}
+		}
+		combos = next
+	}
+	var out []candidate
+	for _, s := range specs {
+		for _, params := range combos {
+			out = append(out, candidate{s, params})
+		}
+	}
+	return out
+}
+
+// variant clones p to run c over [start, end] with cash.
+func (p *Portfolio) variant(c candidate, start, end time.Time, cash float64) (*Portfolio, error) {
+	v := *p
+	if c.spec != p.StrategySpec {
+		v.Sleeves = nil
+	}
+	v.StrategySpec, v.StrategyParams = c.spec, c.params
+	v.StartTime, v.EndTime = start, end
+	v.InitialBuyingPower = cash
+	clone, err := v.Clone()
+	if err != nil {
+		return nil, err
+	}
+	clone.store = p.store
+	return clone, nil
+}
+
+// history queries p's bars from store, and its risk-free rates unless
+// p has its own, and returns them with its first ticker's bars aligned
+// to its window, which time its runs.
+func (p *Portfolio) history(
+	ctx context.Context,
+	store Store,
+) (hist map[string][]data.AssetData, rf map[int64]float64, lead []data.AssetData, err error) {
+	hist = store.QueryAssetsForTickers(ctx, allTickers([]*Portfolio{p}), p.StartTime, p.EndTime)
+	if p.RiskFree == nil {
+		rf = DBRiskFree{store}.RiskFreeRates(ctx, riskFreeStart(p.StartTime), p.EndTime)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(p.Tickers) == 0 {
+		return nil, nil, nil, fmt.Errorf("no tickers")
+	}
+	lead = alignWindow(p.Tickers, hist, p.StartTime, p.EndTime, p.Calendar.sessionFilter())[p.Tickers[0]]
+	return hist, rf, lead, nil
+}
+
+// stitched is an empty portfolio configured as p, to join runs of its
+// variants into with stitch.
+func (p *Portfolio) stitched() *Portfolio {
+	return &Portfolio{
+		Pname:              p.Pname,
+		InitialBuyingPower: p.InitialBuyingPower,
+		Tickers:            p.Tickers,
+		Strategy:           p.Strategy,
+		StrategySpec:       p.StrategySpec,
+		StrategyParams:     p.StrategyParams,
+		StartTime:          p.StartTime,
+		EndTime:            p.EndTime,
+		Fill:               p.Fill,
+		Calendar:           p.Calendar,
+		VaRLevels:          p.VaRLevels,
+		RollingWindows:     p.RollingWindows,
+		MonteCarloConfig:   p.MonteCarloConfig,
+		SignificanceConfig: p.SignificanceConfig,
+		Seed:               p.Seed,
+	}
+}
+
+// stitch appends the run out, which starts where the last one stitched
+// ended, to s.
+func (s *Portfolio) stitch(out *Portfolio) {
+	s.DailyReturns = append(s.DailyReturns, out.DailyReturns...)
+	s.PortfolioCloseValues = append(s.PortfolioCloseValues, out.PortfolioCloseValues...)
+	s.addSectors(out.sectors, len(s.PortfolioCloseValues)-len(out.PortfolioCloseValues))
+	s.Trades = append(s.Trades, out.Trades...)
+	s.ClosedTrades = append(s.ClosedTrades, out.ClosedTrades...)
+	s.CashFlows = append(s.CashFlows, out.CashFlows...)
+	if s.books == nil {
+		s.books = make(map[bookKey]float64)
+	}
+	for k, v := range out.bookPnL() {
+		s.books[k] += v
+	}
+	if s.EffectiveStart.IsZero() {
+		s.EffectiveStart = out.EffectiveStart
+	}
+	s.EffectiveEnd = out.EffectiveEnd
+}
+
+// finishStitched computes the metrics of s, the runs of p's variants
+// stitched together, over the bars of hist they cover, and returns its
+// Result. rf is used unless p has its own risk-free rates.
+func (p *Portfolio) finishStitched(ctx context.Context, s *Portfolio, hist map[string][]data.AssetData, rf map[int64]float64) Result {
+	if p.RiskFree != nil {
+		rf = p.RiskFree.RiskFreeRates(ctx, riskFreeStart(s.EffectiveStart), s.EffectiveEnd)
+	}
+	span := alignWindow(p.Tickers, hist, s.EffectiveStart, s.EffectiveEnd, p.Calendar.sessionFilter())
+	s.GetBacktestingData(rf, span, len(span[p.Tickers[0]]))
+	if p.Benchmark != "" {
+		var bench []data.AssetData
+		if p.BenchmarkSource != nil {
+			bench = p.BenchmarkSource.BenchmarkBars(ctx, p.Benchmark, s.EffectiveStart, s.EffectiveEnd)
+		} else {
+			bench = clipSeries(hist[p.Benchmark], s.EffectiveStart, s.EffectiveEnd)
+		}
+		s.Metrics.BenchmarkReturn = benchmarkReturn(bench, s.periodsPerYear())
+	}
+	return newResult(s)
+}
+
+// WalkForward runs walk-forward analysis of p: its history is cut into
+// consecutive windows of cfg.InSample bars followed by cfg.OutOfSample
+// bars, advancing cfg.Step bars at a time. Every candidate is backtested
+// on each in-sample span and the best by cfg.Objective is then run on
+// the out-of-sample span, starting flat with the cash the previous
+// out-of-sample run ended with and entering at the close of the last
+// in-sample bar. The returned Result stitches those runs
+// together: its equity curve, trades and metrics cover only
+// out-of-sample bars, and Windows records each step.
+//
+// Each run starts from an empty history at its window's first bar, so
+// strategies with long lookbacks trade less of each window.
+func WalkForward(ctx context.Context, store Store, p *Portfolio, cfg *WalkForwardConfig) (Result, error) {
+	if err := cfg.validate(); err != nil {
+		return Result{}, err
+	}
+	field, minimize, _ := cfg.objective()
+	step := cfg.Step
+	if step == 0 {
+		step = cfg.OutOfSample
+	}
+
+	hist, rf, lead, err := p.history(ctx, store)
+	if err != nil {
+		return Result{}, fmt.Errorf("walk-forward %s: %w", p.Pname, err)
+	}
+	tmpl := *p
+	tmpl.store = store
+	if len(lead) < cfg.InSample+cfg.OutOfSample {
+		return Result{}, fmt.Errorf("walk-forward %s: %d bars is fewer than one in-sample plus out-of-sample window",
+			p.Pname, len(lead))
+	}
+
+	cands := cfg.candidates(p.StrategySpec, p.StrategyParams)
+	stitched := p.stitched()
+	cash := p.InitialBuyingPower
+	var windows []WalkForwardWindow
+	for i := 0; i+cfg.InSample+cfg.OutOfSample <= len(lead); i += step {
+		inStart, inEnd := lead[i].Date, lead[i+cfg.InSample-1].Date
+		outStart, outEnd := lead[i+cfg.InSample].Date, lead[i+cfg.InSample+cfg.OutOfSample-1].Date
+
+		runs := make([]*Portfolio, 0, len(cands))
+		for _, c := range cands {
+			v, err := tmpl.variant(c, inStart, inEnd, p.InitialBuyingPower)
+			if err != nil {
+				return Result{}, fmt.Errorf("walk-forward %s: candidate %s: %w", p.Pname, c.spec, err)
+			}
+			runs = append(runs, v)
+		}
+		scored := runPortfolios(ctx, runs, hist, rf, nil, nil)
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		best, bestScore := -1, 0.0
+		for j, r := range scored {
+			v, _ := resultValue(r, field)
+			score := v.(float64)
+			if minimize {
+				score = -score
+			}
+			if best < 0 || score > bestScore {
+				best, bestScore = j, score
+			}
+		}
+		if minimize {
+			bestScore = -bestScore
+		}
+
+		// The run opens on the last in-sample bar so the winner can take
+		// positions at its close and every out-of-sample bar has a return.
+		out, err := tmpl.variant(cands[best], inEnd, outEnd, cash)
+		if err != nil {
+			return Result{}, fmt.Errorf("walk-forward %s: %w", p.Pname, err)
+		}
+		if !runOne(ctx, out, hist, rf) {
+			return Result{}, ctx.Err()
+		}
+		outScore, _ := resultValue(newResult(out), field)
+		runLogger.Info("walk-forward window",
+			"portfolio", p.Pname, "in_start", formatDate(inStart), "in_end", formatDate(inEnd),
+			"strategy", cands[best].spec, "params", cands[best].params, "objective", field,
+			"in_score", bestScore, "out_start", formatDate(outStart), "out_end", formatDate(outEnd), "out_score", outScore)
+		windows = append(windows, WalkForwardWindow{
+			InStart: formatDate(inStart), InEnd: formatDate(inEnd),
+			OutStart: formatDate(outStart), OutEnd: formatDate(outEnd),
+			Strategy: cands[best].spec, Params: cands[best].params,
+			Score: bestScore, Metrics: out.Metrics,
+		})
+
+		stitched.stitch(out)
+		if n := len(out.PortfolioCloseValues); n > 0 {
+			cash = out.PortfolioCloseValues[n-1]
+		}
+	}
+
+	r := p.finishStitched(ctx, stitched, hist, rf)
+	r.Strategy = "walkForward"
+	r.Windows = windows
+	return r, nil
+}
+
+// RunWalkForward runs WalkForward for every portfolio, in order, and
+// writes the stitched Results through output as Run does. Every
+// portfolio's bars and risk-free rates are queried from store once,
+// up front, rather than per portfolio.
+func RunWalkForward(
+	ctx context.Context,
+	store Store,
+	portfolios []*Portfolio,
+	cfg *WalkForwardConfig,
+	output *OutputConfig,
+) ([]Result, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("walk-forward: no [WalkForward] config")
+	}
+	return runEach(ctx, store, portfolios, output, func(ctx context.Context, store Store, p *Portfolio) (Result, error) {
+		return WalkForward(ctx, store, p, cfg)
+	})
+}
+
+// runEach runs analyze for every portfolio, in order, over the
+// portfolios' bars and risk-free rates prefetched from store, and writes
+// the Results through output as Run does. It stops at the first error.
+func runEach(
+	ctx context.Context,
+	store Store,
+	portfolios []*Portfolio,
+	output *OutputConfig,
+	analyze func(context.Context, Store, *Portfolio) (Result, error),
+) ([]Result, error) {
+	reporter, err := NewReporter(output)
+	if err != nil {
+		return nil, fmt.Errorf("output config: %w", err)
+	}
+	cached := prefetch(ctx, store, portfolios)
+	var results []Result
+	defer func() { cached.logSummary(len(results)) }()
+	for _, p := range portfolios {
+		before := cached.stats()
+		r, err := analyze(ctx, cached, p)
+		cached.logJob(p, before)
+		if err != nil {
+			reporter.Close()
+			return results, err
+		}
+		results = append(results, r)
+		if werr := reporter.Write(r); werr != nil {
+			runLogger.Error("write result", "err", werr)
+		}
+	}
+	if err := reporter.Close(); err != nil {
+		return results, fmt.Errorf("close output: %w", err)
+	}
+	if output != nil && output.Dir != "" {
+		if err := WriteArtifacts(output.Dir, results); err != nil {
+			return results, err
+		}
+	}
+	if output != nil && output.Database {
+		if err := SaveResults(ctx, store, results); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
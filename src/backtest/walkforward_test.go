@@ -0,0 +1,227 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"my-backtester/src/data"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A ticker that rises for 15 bars and then falls: the in-sample runs
+// favour holding it until the in-sample span takes in the decline, and
+// the stitched result covers only the out-of-sample bars, each window
+// starting with the cash the previous one ended with.
+func TestWalkForward(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+	for i := 0; i < 25; i++ {
+		price := 10 + float64(i)
+		if i >= 15 {
+			price = 24 - 2*float64(i-14)
+		}
+		store.bars["A"] = append(store.bars["A"], data.AssetData{
+			Date: day(i), Open: price, High: price, Low: price, Close: price, Volume: 100,
+		})
+		store.rates[day(i).Unix()] = 0
+	}
+	script := filepath.Join(t.TempDir(), "hold.lua")
+	if err := os.WriteFile(script, []byte(`
+function step(day)
+  if params.hold == 1 and position("A") == nil then
+    buy("A", 10, close_at("A", day), day)
+  end
+end
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := InitializePortfolio(
+		1000, day(0), day(24), "wf", []string{"A"}, "lua:"+script, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &WalkForwardConfig{
+		InSample: 10, OutOfSample: 5, Objective: "AnnualReturn",
+		Grid: map[string][]any{"hold": {int64(0), int64(1)}},
+	}
+	r, err := WalkForward(context.Background(), store, p, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Windows) != 3 {
+		t.Fatalf("windows = %d, want 3", len(r.Windows))
+	}
+	for i, want := range []int64{1, 1, 0} {
+		w := r.Windows[i]
+		if w.Params["hold"] != want {
+			t.Errorf("window %d (%s..%s) picked hold=%v, want %d", i, w.InStart, w.InEnd, w.Params["hold"], want)
+		}
+	}
+	if w := r.Windows[2]; w.InStart != "2024-01-11" || w.OutStart != "2024-01-21" || w.OutEnd != "2024-01-25" {
+		t.Errorf("last window = %+v", w)
+	}
+	if len(r.Dates) != 15 || r.Dates[0] != "2024-01-11" || r.Dates[14] != "2024-01-25" {
+		t.Fatalf("dates = %v, want the 15 out-of-sample bars", r.Dates)
+	}
+	// 10 shares gain 5 a share over the first window and lose 10 over the
+	// second, bought at 24 as the rise ends; the last window sits in the
+	// cash that leaves.
+	if got := r.EquityCurve[14]; !closeTo(got, 950) || got != r.EquityCurve[9] {
+		t.Errorf("equity = %v, want the last window flat at 950", r.EquityCurve)
+	}
+	if r.Strategy != "walkForward" {
+		t.Errorf("strategy = %q", r.Strategy)
+	}
+}
+
+func TestWalkForwardConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  WalkForwardConfig
+		ok   bool
+	}{
+		{"defaults", WalkForwardConfig{InSample: 20, OutOfSample: 5}, true},
+		{"minimize", WalkForwardConfig{InSample: 20, OutOfSample: 5, Objective: "-MaxDrawdown"}, true},
+		{"short", WalkForwardConfig{InSample: 1, OutOfSample: 5}, false},
+		{"overlap", WalkForwardConfig{InSample: 20, OutOfSample: 5, Step: 3}, false},
+		{"unknown", WalkForwardConfig{InSample: 20, OutOfSample: 5, Objective: "Luck"}, false},
+		{"text", WalkForwardConfig{InSample: 20, OutOfSample: 5, Objective: "Strategy"}, false},
+	} {
+		if err := tc.cfg.validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: validate() = %v", tc.name, err)
+		}
+	}
+}
+
+// RunWalkForward queries each ticker and the risk-free rates once for
+// all its portfolios, and each portfolio's result is what a separate
+// WalkForward over the store gives.
+func TestRunWalkForward_Prefetch(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	newStore := func() *fakeStore {
+		store := &fakeStore{bars: map[string][]data.AssetData{}, rates: map[int64]float64{}}
+		for i := 0; i < 40; i++ {
+			a, b := 10+float64(i%6), 20-float64(i%4)
+			store.bars["A"] = append(store.bars["A"], data.AssetData{Date: day(i), Open: a, High: a, Low: a, Close: a, Volume: 100})
+			store.bars["B"] = append(store.bars["B"], data.AssetData{Date: day(i), Open: b, High: b, Low: b, Close: b, Volume: 100})
+			store.rates[day(i).Unix()] = 0.0001
+		}
+		return store
+	}
+	newPortfolios := func() []*Portfolio {
+		var ps []*Portfolio
+		for i, tickers := range [][]string{{"A"}, {"A", "B"}} {
+			p, err := NewPortfolio("wf", 1000, tickers, "buyAndHold:equalWeights", WithWindow(day(5*i), day(39-5*i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ps = append(ps, p)
+		}
+		return ps
+	}
+	cfg := &WalkForwardConfig{
+		InSample: 10, OutOfSample: 5, Objective: "AnnualReturn",
+		Strategies: []string{"buyAndHold:equalWeights", "smaCross:2:4:equalWeights"},
+	}
+	store := newStore()
+	results, err := RunWalkForward(context.Background(), store, newPortfolios(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.queried) != 2 || store.rateReads != 1 {
+		t.Errorf("queried %v and the rates %d times, want each once", store.queried, store.rateReads)
+	}
+	for i, p := range newPortfolios() {
+		want, err := WalkForward(context.Background(), newStore(), p, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := results[i]; !reflect.DeepEqual(got.EquityCurve, want.EquityCurve) || got.Metrics.SharpeRatio != want.Metrics.SharpeRatio {
+			t.Errorf("portfolio %d: equity %v, Sharpe %v; want %v, %v", i,
+				got.EquityCurve, got.Metrics.SharpeRatio, want.EquityCurve, want.Metrics.SharpeRatio)
+		}
+	}
+
+	// The cache counts the queries it answers, for the per-job log.
+	cached := prefetch(context.Background(), newStore(), newPortfolios())
+	cached.QueryAssetsForTickers(context.Background(), []string{"A"}, day(5), day(30))
+	cached.GetRiskFreeRates(context.Background(), day(5), day(30))
+	if s := cached.stats(); s.queries != 2 {
+		t.Errorf("stats = %+v, want 2 queries", s)
+	}
+}
+
+// Prefetching against querying DuckDB per job, for eight portfolios over
+// overlapping windows of the same tickers. Both runs log their query
+// time (see the "prefetch served" and "query assets" records).
+func BenchmarkRunWalkForward_Prefetch(b *testing.B) {
+	ctx := context.Background()
+	store, err := data.Open("")
+	if err != nil {
+		b.Skipf("no DuckDB driver: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.DB().ExecContext(ctx, `
+		CREATE TABLE stock_data_optimized (
+			Date TIMESTAMP_NS, Ticker VARCHAR,
+			Open DOUBLE, High DOUBLE, Low DOUBLE, Close DOUBLE, Volume BIGINT
+		);`); err != nil {
+		b.Fatal(err)
+	}
+	day := func(i int) time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i) }
+	tickers := []string{"A", "B", "C", "D", "E", "F"}
+	var rates []data.MacroPoint
+	for i := 0; i < 750; i++ {
+		rates = append(rates, data.MacroPoint{Date: day(i), Value: 2})
+	}
+	for n, t := range tickers {
+		bars := make([]data.AssetData, 750)
+		for i := range bars {
+			c := 50 + float64(n) + 10*math.Sin(float64(i+7*n)/20)
+			bars[i] = data.AssetData{Date: day(i), Open: c, High: c, Low: c, Close: c, Volume: 1000}
+		}
+		if err := store.InsertBars(ctx, t, bars); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := store.InsertRiskFree(ctx, rates); err != nil {
+		b.Fatal(err)
+	}
+	portfolios := func() []*Portfolio {
+		var ps []*Portfolio
+		for i := 0; i < 8; i++ {
+			p, err := NewPortfolio("wf", 10_000, tickers[i%3:i%3+4], "smaCross:5:20:equalWeights",
+				WithWindow(day(20*i), day(749-20*i)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			ps = append(ps, p)
+		}
+		return ps
+	}
+	cfg := &WalkForwardConfig{
+		InSample: 120, OutOfSample: 40, Objective: "AnnualReturn",
+		Strategies: []string{"smaCross:5:20:equalWeights", "smaCross:10:40:equalWeights"},
+	}
+	b.Run("prefetch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := RunWalkForward(ctx, store, portfolios(), cfg, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per_job", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, p := range portfolios() {
+				if _, err := WalkForward(ctx, store, p, cfg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
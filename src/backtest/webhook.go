@@ -0,0 +1,148 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebhookConfig posts generated signals as JSON to an external URL. In
+// paper mode every trade is posted as it happens; after a batch run the
+// trades from each portfolio's final bar are posted if OnRun is set.
+type WebhookConfig struct {
+	URL     Secret            `toml:"url"`     // may embed a token, so never logged
+	Headers map[string]Secret `toml:"headers"` // e.g. an auth token
+	OnRun   bool              `toml:"on_run"`  // post final-bar signals after a batch run
+}
+
+// Signal is the JSON body of one webhook POST.
+type Signal struct {
+	Mode      string  `json:"mode"` // "paper" or "backtest"
+	Portfolio string  `json:"portfolio"`
+	Strategy  string  `json:"strategy"`
+	Date      string  `json:"date"`
+	Ticker    string  `json:"ticker"`
+	Side      string  `json:"side"`
+	Amount    float64 `json:"amount"`
+	Price     float64 `json:"price"`
+}
+
+// Webhook is an Executor that posts each trade for one portfolio.
+type Webhook struct {
+	cfg       *WebhookConfig
+	client    *http.Client
+	mode      string
+	portfolio string
+	strategy  string
+}
+
+func NewWebhook(cfg *WebhookConfig, mode, portfolio, strategy string) (*Webhook, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	return &Webhook{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		mode:      mode,
+		portfolio: portfolio,
+		strategy:  strategy,
+	}, nil
+}
+
+func (w *Webhook) Execute(ctx context.Context, t Trade) error {
+	return w.post(ctx, Signal{
+		Mode:      w.mode,
+		Portfolio: w.portfolio,
+		Strategy:  w.strategy,
+		Date:      formatDate(t.Date),
+		Ticker:    t.Ticker,
+		Side:      t.Side,
+		Amount:    t.Amount,
+		Price:     t.Price,
+	})
+}
+
+func (w *Webhook) post(ctx context.Context, s Signal) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, string(w.cfg.URL), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, string(v))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", redactURLError(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// PostRunSignals posts the trades each Result made on its final bar —
+// the signals a daily batch run generates for the next session. It is a
+// no-op unless cfg.OnRun is set.
+func PostRunSignals(ctx context.Context, cfg *WebhookConfig, results []Result) error {
+	if cfg == nil || !cfg.OnRun {
+		return nil
+	}
+	var errs []error
+	for _, r := range results {
+		if len(r.Dates) == 0 {
+			continue
+		}
+		last := r.Dates[len(r.Dates)-1]
+		w, err := NewWebhook(cfg, "backtest", r.PortfolioName, r.Strategy)
+		if err != nil {
+			return err
+		}
+		for _, t := range r.Trades {
+			if t.Date.Format("2006-01-02") != last {
+				continue
+			}
+			if err := w.Execute(ctx, t); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", r.PortfolioName, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Executors hands each trade to several Executors in order, e.g. a
+// broker and then a webhook, stopping at the first that fails so later
+// ones never see a trade the portfolio refuses.
+type Executors []Executor
+
+func (es Executors) Execute(ctx context.Context, t Trade) error {
+	for _, e := range es {
+		if err := e.Execute(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifier is an Executor that only reports trades, as a webhook does:
+// its failures are logged, not returned, so an unreachable endpoint
+// doesn't refuse them.
+type notifier struct{ Executor }
+
+func (n notifier) Execute(ctx context.Context, t Trade) error {
+	if err := n.Executor.Execute(ctx, t); err != nil {
+		logger.Error("notify trade", "side", t.Side, "ticker", t.Ticker, "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostRunSignals_PostsFinalBarOnly(t *testing.T) {
+	var mu sync.Mutex
+	var got []Signal
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s Signal
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			t.Errorf("decode signal: %v", err)
+		}
+		mu.Lock()
+		got = append(got, s)
+		auth = r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	d1 := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	d2 := d1.AddDate(0, 0, 1)
+	results := []Result{{
+		PortfolioName: "P",
+		Strategy:      "buyAndHold",
+		Dates:         []string{"2024-01-02", "2024-01-03"},
+		Trades: []Trade{
+			{Date: d1, Ticker: "AAPL", Side: "BUY", Amount: 1, Price: 10},
+			{Date: d2, Ticker: "MSFT", Side: "SELL", Amount: 2, Price: 20},
+		},
+	}}
+	cfg := &WebhookConfig{
+		URL:     Secret(srv.URL),
+		Headers: map[string]Secret{"Authorization": "Bearer t"},
+		OnRun:   true,
+	}
+	if err := PostRunSignals(context.Background(), cfg, results); err != nil {
+		t.Fatalf("PostRunSignals: %v", err)
+	}
+	want := Signal{
+		Mode: "backtest", Portfolio: "P", Strategy: "buyAndHold",
+		Date: "2024-01-03", Ticker: "MSFT", Side: "SELL", Amount: 2, Price: 20,
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("signals = %+v, want [%+v]", got, want)
+	}
+	if auth != "Bearer t" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	got = nil
+	cfg.OnRun = false
+	if err := PostRunSignals(context.Background(), cfg, results); err != nil || len(got) != 0 {
+		t.Errorf("OnRun=false posted %d signals, err %v", len(got), err)
+	}
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(&WebhookConfig{URL: Secret(srv.URL)}, "paper", "P", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Execute(context.Background(), Trade{Date: time.Now(), Ticker: "X", Side: "BUY"}); err == nil {
+		t.Error("expected error for 502 response")
+	}
+}
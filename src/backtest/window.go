@@ -0,0 +1,204 @@
+package backtest
+
+import (
+	"my-backtester/src/data"
+	"sort"
+	"time"
+)
+
+// alignWindow restricts each ticker's series to [start, end] (a zero
+// time leaves that side open) and then to the dates every ticker has a
+// bar on, so day i is the same date in every series and a ticker that
+// listed late or stopped trading early shortens the backtest instead of
+// skewing it. A non-nil open also drops bars dated on days it says the
+// market was closed, such as a vendor's stray weekend or holiday bars.
+// Series already aligned are returned as subslices of hist; otherwise
+// the kept bars are copied and returns recomputed across the dropped
+// ones.
+func alignWindow(
+	tickers []string,
+	hist map[string][]data.AssetData,
+	start, end time.Time,
+	open func(time.Time) bool,
+) map[string][]data.AssetData {
+	out := make(map[string][]data.AssetData, len(tickers))
+	for _, t := range tickers {
+		out[t] = clipSeries(hist[t], start, end)
+	}
+	if datesAligned(tickers, out) && allOpen(tickers, out, open) {
+		return out
+	}
+
+	counts := make(map[int64]int)
+	for _, t := range tickers {
+		for _, bar := range out[t] {
+			counts[bar.Date.Unix()]++
+		}
+	}
+	for _, t := range tickers {
+		series := out[t]
+		kept := make([]data.AssetData, 0, len(series))
+		prev := -1
+		for i, bar := range series {
+			if counts[bar.Date.Unix()] != len(tickers) || (open != nil && !open(bar.Date)) {
+				continue
+			}
+			if len(kept) > 0 && i != prev+1 {
+				kept = data.AppendBar(kept, bar)
+			} else {
+				kept = append(kept, bar)
+			}
+			prev = i
+		}
+		out[t] = kept
+	}
+	return out
+}
+
+// alignUnion is alignWindow for universes whose tickers come and go,
+// such as an index's past members: it keeps every date any ticker has a
+// bar on, so one ticker listing late or being delisted doesn't shorten
+// the run for the rest. A ticker's dates before its first bar get a
+// zero bar, which FillPrice reports as untradeable; its later missing
+// dates, including every date after it stopped trading, repeat its last
+// close with no volume (see GapsForwardFill for what then happens to a
+// delisted ticker).
+func alignUnion(
+	tickers []string,
+	hist map[string][]data.AssetData,
+	start, end time.Time,
+	open func(time.Time) bool,
+) map[string][]data.AssetData {
+	out := make(map[string][]data.AssetData, len(tickers))
+	seen := make(map[int64]bool)
+	var dates []time.Time
+	for _, t := range tickers {
+		out[t] = clipSeries(hist[t], start, end)
+		for _, bar := range out[t] {
+			if !seen[bar.Date.Unix()] && (open == nil || open(bar.Date)) {
+				seen[bar.Date.Unix()] = true
+				dates = append(dates, bar.Date)
+			}
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	for _, t := range tickers {
+		series := out[t]
+		kept := make([]data.AssetData, 0, len(dates))
+		j := 0
+		for _, d := range dates {
+			for j < len(series) && series[j].Date.Before(d) {
+				j++
+			}
+			switch {
+			case j < len(series) && series[j].Date.Equal(d):
+				kept = data.AppendBar(kept, series[j])
+			case len(kept) == 0 || kept[len(kept)-1].Close == 0:
+				kept = append(kept, data.AssetData{Date: d})
+			default:
+				last := kept[len(kept)-1].Close
+				kept = data.AppendBar(kept, data.AssetData{
+					Date: d, Open: last, High: last, Low: last, Close: last,
+				})
+			}
+		}
+		out[t] = kept
+	}
+	return out
+}
+
+// firstGap returns the earliest date in [start, end] that some of
+// tickers have a bar on and another doesn't, with the first ticker
+// missing it; ok is false if there is none. Dates open rejects don't
+// count.
+func firstGap(
+	tickers []string,
+	hist map[string][]data.AssetData,
+	start, end time.Time,
+	open func(time.Time) bool,
+) (ticker string, date time.Time, ok bool) {
+	clipped := make(map[string][]data.AssetData, len(tickers))
+	counts := make(map[int64]int)
+	for _, t := range tickers {
+		clipped[t] = clipSeries(hist[t], start, end)
+		for _, bar := range clipped[t] {
+			if open == nil || open(bar.Date) {
+				counts[bar.Date.Unix()]++
+			}
+		}
+	}
+	for _, t := range tickers {
+		for _, bar := range clipped[t] {
+			if counts[bar.Date.Unix()] != len(tickers) && (!ok || bar.Date.Before(date)) && (open == nil || open(bar.Date)) {
+				date, ok = bar.Date, true
+			}
+		}
+	}
+	if !ok {
+		return "", time.Time{}, false
+	}
+	for _, t := range tickers {
+		i := sort.Search(len(clipped[t]), func(i int) bool { return !clipped[t][i].Date.Before(date) })
+		if i == len(clipped[t]) || !clipped[t][i].Date.Equal(date) {
+			return t, date, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// clipSeries returns the subslice of a date-ordered series dated within
+// [start, end]; zero times leave that side open.
+func clipSeries(series []data.AssetData, start, end time.Time) []data.AssetData {
+	lo, hi := 0, len(series)
+	if !start.IsZero() {
+		lo = sort.Search(len(series), func(i int) bool {
+			return !series[i].Date.Before(start)
+		})
+	}
+	if !end.IsZero() {
+		hi = sort.Search(len(series), func(i int) bool {
+			return series[i].Date.After(end)
+		})
+	}
+	if lo >= hi {
+		return nil
+	}
+	return series[lo:hi]
+}
+
+// allOpen reports whether open, if set, holds for every bar.
+func allOpen(tickers []string, hist map[string][]data.AssetData, open func(time.Time) bool) bool {
+	if open == nil {
+		return true
+	}
+	for _, t := range tickers {
+		for _, bar := range hist[t] {
+			if !open(bar.Date) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// datesAligned reports whether every ticker's series carries the same
+// dates as the first's.
+func datesAligned(tickers []string, hist map[string][]data.AssetData) bool {
+	if len(tickers) == 0 {
+		return true
+	}
+	lead := hist[tickers[0]]
+	for _, t := range tickers[1:] {
+		series := hist[t]
+		if len(series) != len(lead) {
+			return false
+		}
+		for i := range series {
+			if !series[i].Date.Equal(lead[i].Date) {
+				return false
+			}
+		}
+	}
+	return true
+}
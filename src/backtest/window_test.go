@@ -0,0 +1,161 @@
+package backtest
+
+import (
+	"context"
+	"my-backtester/src/data"
+	"testing"
+	"time"
+)
+
+func windowBars(closes map[int]float64) []data.AssetData {
+	var series []data.AssetData
+	for d := 0; d < 10; d++ {
+		if c, ok := closes[d]; ok {
+			series = append(series, data.AssetData{
+				Date:  time.Date(2024, 1, 1+d, 0, 0, 0, 0, time.UTC),
+				Close: c,
+			})
+		}
+	}
+	data.FillReturns(series)
+	return series
+}
+
+func TestAlignWindow_TrimsToCommonCoverage(t *testing.T) {
+	full := map[int]float64{}
+	for d := 0; d < 10; d++ {
+		full[d] = 100 + float64(d)
+	}
+	// LATE lists on day 3, misses day 5 and delists after day 7.
+	late := map[int]float64{3: 10, 4: 11, 6: 13, 7: 14}
+	hist := map[string][]data.AssetData{
+		"FULL": windowBars(full),
+		"LATE": windowBars(late),
+	}
+	got := alignWindow([]string{"FULL", "LATE"}, hist, time.Time{}, time.Time{}, nil)
+
+	for _, tk := range []string{"FULL", "LATE"} {
+		if n := len(got[tk]); n != 4 {
+			t.Fatalf("%s has %d bars, want 4", tk, n)
+		}
+		for i, bar := range got[tk] {
+			if !bar.Date.Equal(got["FULL"][i].Date) {
+				t.Fatalf("%s bar %d dated %s, misaligned", tk, i, bar.Date)
+			}
+		}
+	}
+	if d := got["FULL"][0].Date.Day(); d != 4 {
+		t.Errorf("window starts on Jan %d, want Jan 4", d)
+	}
+	// Day 5 was dropped, so day 6's return spans days 4..6.
+	if r, want := got["FULL"][2].Return, (106.0-104.0)/104.0; r != want {
+		t.Errorf("return across the gap = %g, want %g", r, want)
+	}
+	if r := hist["FULL"][6].Return; r != (106.0-105.0)/105.0 {
+		t.Errorf("source series was modified: return %g", r)
+	}
+}
+
+func TestAlignUnion_KeepsEveryDate(t *testing.T) {
+	full := map[int]float64{}
+	for d := 0; d < 10; d++ {
+		full[d] = 100 + float64(d)
+	}
+	late := map[int]float64{3: 10, 4: 11, 6: 13, 7: 14}
+	hist := map[string][]data.AssetData{
+		"FULL": windowBars(full),
+		"LATE": windowBars(late),
+	}
+	got := alignUnion([]string{"FULL", "LATE"}, hist, time.Time{}, time.Time{}, nil)
+
+	if len(got["FULL"]) != 10 || len(got["LATE"]) != 10 {
+		t.Fatalf("got %d and %d bars, want 10 each", len(got["FULL"]), len(got["LATE"]))
+	}
+	var closes []float64
+	for i, bar := range got["LATE"] {
+		if !bar.Date.Equal(got["FULL"][i].Date) {
+			t.Fatalf("LATE bar %d dated %s, misaligned", i, bar.Date)
+		}
+		closes = append(closes, bar.Close)
+	}
+	// Zero before listing; day 5 and days after delisting repeat the
+	// last close.
+	want := []float64{0, 0, 0, 10, 11, 11, 13, 14, 14, 14}
+	for i := range want {
+		if closes[i] != want[i] {
+			t.Fatalf("LATE closes = %v, want %v", closes, want)
+		}
+	}
+	if b := got["LATE"][8]; b.Volume != 0 || b.Return != 0 {
+		t.Errorf("filled bar %+v has volume or a return", b)
+	}
+	if r, want := got["LATE"][6].Return, (13.0-11.0)/11.0; r != want {
+		t.Errorf("return after the gap = %g, want %g", r, want)
+	}
+}
+
+func TestAlignWindow_ClipsToPortfolioWindow(t *testing.T) {
+	full := map[int]float64{}
+	for d := 0; d < 10; d++ {
+		full[d] = 100
+	}
+	hist := map[string][]data.AssetData{"A": windowBars(full), "B": windowBars(full)}
+	start := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	got := alignWindow([]string{"A", "B"}, hist, start, end, nil)
+	if n := len(got["A"]); n != 6 {
+		t.Fatalf("clipped to %d bars, want 6 (Jan 3..8 inclusive)", n)
+	}
+	if &got["A"][0] != &hist["A"][2] {
+		t.Error("aligned series were copied instead of subsliced")
+	}
+}
+
+// Under CalendarNYSE, New Year's Day and the weekend's bars are dropped
+// even for a lone ticker.
+func TestAlignWindow_DropsClosedSessions(t *testing.T) {
+	full := map[int]float64{}
+	for d := 0; d < 10; d++ {
+		full[d] = 100 + float64(d)
+	}
+	hist := map[string][]data.AssetData{"A": windowBars(full)}
+	got := alignWindow([]string{"A"}, hist, time.Time{}, time.Time{}, CalendarNYSE.sessionFilter())["A"]
+	if len(got) != 7 || got[0].Date.Day() != 2 || got[4].Date.Day() != 8 {
+		t.Fatalf("kept %v", got)
+	}
+	if r, want := got[4].Return, (107.0-104.0)/104.0; r != want {
+		t.Errorf("return across the weekend = %g, want %g", r, want)
+	}
+	if got := alignWindow([]string{"A"}, hist, time.Time{}, time.Time{}, CalendarEquities.sessionFilter())["A"]; len(got) != 10 {
+		t.Errorf("equities calendar dropped bars: %d left", len(got))
+	}
+}
+
+func TestRunOne_RecordsEffectiveWindow(t *testing.T) {
+	full := map[int]float64{}
+	for d := 0; d < 10; d++ {
+		full[d] = 100 + float64(d)
+	}
+	hist := map[string][]data.AssetData{
+		"FULL": windowBars(full),
+		"LATE": windowBars(map[int]float64{2: 50, 3: 51, 4: 52, 5: 53, 6: 54}),
+	}
+	p, err := InitializePortfolio(
+		10_000,
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+		"partial", []string{"FULL", "LATE"}, "equalWeights", nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runOne(context.Background(), p, hist, nil)
+	res := newResult(p)
+	if res.EffectiveStart != "2024-01-03" || res.EffectiveEnd != "2024-01-07" {
+		t.Errorf("effective window = %s..%s, want 2024-01-03..2024-01-07",
+			res.EffectiveStart, res.EffectiveEnd)
+	}
+	if n := len(res.Dates); n != 4 {
+		t.Errorf("recorded %d daily returns, want 4 over the 5 common bars", n)
+	}
+}
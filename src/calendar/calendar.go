@@ -0,0 +1,282 @@
+// Package calendar knows when exchanges trade: weekends, holidays and
+// half days. The backtest package uses it to annualize by the sessions
+// a market actually had, to drop bars dated on days it was closed, and
+// to schedule work on the first or last session of a week, month,
+// quarter or year without looking at the next bar.
+//
+// Dates are calendar dates: only a time's year, month and day, in its
+// own location, matter.
+package calendar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Exchange is a market's trading calendar.
+type Exchange struct {
+	Name string
+	// weekends closes Saturdays and Sundays; rules lists a year's
+	// closures and half days, by date key (see key).
+	weekends bool
+	rules    func(year int) (closed, half map[int]string)
+
+	mu    sync.Mutex
+	years map[int]*yearRules
+}
+
+type yearRules struct {
+	closed, half map[int]string
+}
+
+var (
+	// NYSE is the New York Stock Exchange: closed on weekends, its
+	// regular holidays and the unscheduled closures since 1990, with the
+	// 1 pm early closes around Independence Day, Thanksgiving and
+	// Christmas.
+	NYSE = &Exchange{Name: "nyse", weekends: true, rules: nyseRules}
+	// Weekdays trades every Monday to Friday.
+	Weekdays = &Exchange{Name: "weekdays", weekends: true}
+	// AlwaysOpen trades every day, as crypto markets do.
+	AlwaysOpen = &Exchange{Name: "24/7"}
+)
+
+// key is d's date as yyyymmdd.
+func key(d time.Time) int {
+	y, m, day := d.Date()
+	return y*10000 + int(m)*100 + day
+}
+
+func (e *Exchange) year(y int) *yearRules {
+	if e.rules == nil {
+		return &yearRules{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r, ok := e.years[y]; ok {
+		return r
+	}
+	closed, half := e.rules(y)
+	r := &yearRules{closed, half}
+	if e.years == nil {
+		e.years = make(map[int]*yearRules)
+	}
+	e.years[y] = r
+	return r
+}
+
+// IsOpen reports whether e has a session on d.
+func (e *Exchange) IsOpen(d time.Time) bool {
+	if wd := d.Weekday(); e.weekends && (wd == time.Saturday || wd == time.Sunday) {
+		return false
+	}
+	_, closed := e.year(d.Year()).closed[key(d)]
+	return !closed
+}
+
+// Holiday names the holiday or closure that shuts e on d, a weekday.
+func (e *Exchange) Holiday(d time.Time) (string, bool) {
+	name, ok := e.year(d.Year()).closed[key(d)]
+	return name, ok
+}
+
+// HalfDay reports whether d's session closes early, and why.
+func (e *Exchange) HalfDay(d time.Time) (string, bool) {
+	name, ok := e.year(d.Year()).half[key(d)]
+	return name, ok
+}
+
+// Next is the first session after d's date.
+func (e *Exchange) Next(d time.Time) time.Time {
+	for d = d.AddDate(0, 0, 1); !e.IsOpen(d); d = d.AddDate(0, 0, 1) {
+	}
+	return d
+}
+
+// Prev is the last session before d's date.
+func (e *Exchange) Prev(d time.Time) time.Time {
+	for d = d.AddDate(0, 0, -1); !e.IsOpen(d); d = d.AddDate(0, 0, -1) {
+	}
+	return d
+}
+
+// AddSessions is the nth session after d's date, or before it for
+// negative n; d itself for 0.
+func (e *Exchange) AddSessions(d time.Time, n int) time.Time {
+	for ; n > 0; n-- {
+		d = e.Next(d)
+	}
+	for ; n < 0; n++ {
+		d = e.Prev(d)
+	}
+	return d
+}
+
+// Sessions counts e's sessions from from's date to to's, inclusive.
+func (e *Exchange) Sessions(from, to time.Time) int {
+	n := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if e.IsOpen(d) {
+			n++
+		}
+	}
+	return n
+}
+
+// SessionsPerYear is the mean number of sessions in the calendar years
+// from's through to's: the annualization factor of daily returns over
+// that span. Whole years are counted, so a short span isn't skewed by
+// the holidays that happen to fall in it.
+func (e *Exchange) SessionsPerYear(from, to time.Time) float64 {
+	if to.Before(from) {
+		from, to = to, from
+	}
+	first := time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(to.Year(), 12, 31, 0, 0, 0, 0, time.UTC)
+	return float64(e.Sessions(first, last)) / float64(to.Year()-from.Year()+1)
+}
+
+// Period numbers the day, week (ISO), month, quarter or year d falls
+// in, so two dates with different numbers straddle a boundary. Periods
+// are "day", "week", "month", "quarter" and "year"; anything else is
+// "month".
+func Period(d time.Time, every string) int {
+	switch every {
+	case "day":
+		return key(d)
+	case "week":
+		y, w := d.ISOWeek()
+		return y*100 + w
+	case "quarter":
+		return d.Year()*10 + (int(d.Month())-1)/3
+	case "year":
+		return d.Year()
+	}
+	return d.Year()*100 + int(d.Month())
+}
+
+// ParsePeriod validates a period name for Period.
+func ParsePeriod(s string) (string, error) {
+	switch s {
+	case "day", "week", "month", "quarter", "year":
+		return s, nil
+	}
+	return "", fmt.Errorf("period %q: must be day, week, month, quarter or year", s)
+}
+
+// IsLastSession reports whether d is e's last session of its period:
+// a session whose next one falls in a later period. Unlike spotting the
+// first session of a period, which only needs the bar before, this
+// needs the calendar, since the next bar hasn't happened yet.
+func (e *Exchange) IsLastSession(d time.Time, every string) bool {
+	return e.IsOpen(d) && Period(e.Next(d), every) != Period(d, every)
+}
+
+// nyseRules are the NYSE's closures and early closes in year.
+func nyseRules(year int) (closed, half map[int]string) {
+	closed, half = make(map[int]string), make(map[int]string)
+	date := func(m time.Month, d int) time.Time { return time.Date(year, m, d, 0, 0, 0, 0, time.UTC) }
+	// observed moves a Saturday holiday to the Friday before and a
+	// Sunday one to the Monday after.
+	observed := func(d time.Time) time.Time {
+		switch d.Weekday() {
+		case time.Saturday:
+			return d.AddDate(0, 0, -1)
+		case time.Sunday:
+			return d.AddDate(0, 0, 1)
+		}
+		return d
+	}
+	add := func(d time.Time, name string) {
+		if d.Year() == year {
+			closed[key(d)] = name
+		}
+	}
+
+	// A Saturday New Year's Day isn't observed on the Friday before.
+	if ny := date(time.January, 1); ny.Weekday() != time.Saturday {
+		add(observed(ny), "New Year's Day")
+	}
+	if year >= 1998 {
+		add(nthWeekday(year, time.January, time.Monday, 3), "Martin Luther King Jr. Day")
+	}
+	add(nthWeekday(year, time.February, time.Monday, 3), "Washington's Birthday")
+	add(easter(year).AddDate(0, 0, -2), "Good Friday")
+	add(nthWeekday(year, time.May, time.Monday, -1), "Memorial Day")
+	if year >= 2022 {
+		add(observed(date(time.June, 19)), "Juneteenth")
+	}
+	add(observed(date(time.July, 4)), "Independence Day")
+	add(nthWeekday(year, time.September, time.Monday, 1), "Labor Day")
+	thanksgiving := nthWeekday(year, time.November, time.Thursday, 4)
+	add(thanksgiving, "Thanksgiving Day")
+	add(observed(date(time.December, 25)), "Christmas Day")
+	for _, c := range nyseClosures {
+		if c.date.Year() == year {
+			add(c.date, c.name)
+		}
+	}
+
+	// Early closes fall on weekdays the exchange is otherwise open.
+	early := func(d time.Time, name string) {
+		if wd := d.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			if _, shut := closed[key(d)]; !shut {
+				half[key(d)] = name
+			}
+		}
+	}
+	// July 3 closes early when Independence Day falls Tuesday to Friday.
+	if wd := date(time.July, 4).Weekday(); wd >= time.Tuesday && wd <= time.Friday {
+		early(date(time.July, 3), "Independence Day eve")
+	}
+	early(thanksgiving.AddDate(0, 0, 1), "Day after Thanksgiving")
+	early(date(time.December, 24), "Christmas Eve")
+	return closed, half
+}
+
+// nyseClosures are the NYSE's unscheduled closures since 1990.
+var nyseClosures = []struct {
+	date time.Time
+	name string
+}{
+	{time.Date(1994, 4, 27, 0, 0, 0, 0, time.UTC), "National Day of Mourning (Nixon)"},
+	{time.Date(2001, 9, 11, 0, 0, 0, 0, time.UTC), "September 11"},
+	{time.Date(2001, 9, 12, 0, 0, 0, 0, time.UTC), "September 11"},
+	{time.Date(2001, 9, 13, 0, 0, 0, 0, time.UTC), "September 11"},
+	{time.Date(2001, 9, 14, 0, 0, 0, 0, time.UTC), "September 11"},
+	{time.Date(2004, 6, 11, 0, 0, 0, 0, time.UTC), "National Day of Mourning (Reagan)"},
+	{time.Date(2007, 1, 2, 0, 0, 0, 0, time.UTC), "National Day of Mourning (Ford)"},
+	{time.Date(2012, 10, 29, 0, 0, 0, 0, time.UTC), "Hurricane Sandy"},
+	{time.Date(2012, 10, 30, 0, 0, 0, 0, time.UTC), "Hurricane Sandy"},
+	{time.Date(2018, 12, 5, 0, 0, 0, 0, time.UTC), "National Day of Mourning (G. H. W. Bush)"},
+	{time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC), "National Day of Mourning (Carter)"},
+}
+
+// nthWeekday is the nth wd of month in year, counting from the end for
+// negative n.
+func nthWeekday(year int, month time.Month, wd time.Weekday, n int) time.Time {
+	if n > 0 {
+		d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		d = d.AddDate(0, 0, (int(wd)-int(d.Weekday())+7)%7)
+		return d.AddDate(0, 0, 7*(n-1))
+	}
+	d := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	d = d.AddDate(0, 0, -((int(d.Weekday()) - int(wd) + 7) % 7))
+	return d.AddDate(0, 0, 7*(n+1))
+}
+
+// easter is Easter Sunday in year, by the anonymous Gregorian algorithm.
+func easter(year int) time.Time {
+	a, b, c := year%19, year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+func TestNYSE(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Time
+		open bool
+		half bool
+	}{
+		{date(2024, 1, 1), false, false},  // New Year's Day
+		{date(2024, 1, 15), false, false}, // Martin Luther King Jr. Day
+		{date(2024, 3, 29), false, false}, // Good Friday
+		{date(2024, 5, 27), false, false}, // Memorial Day
+		{date(2024, 6, 19), false, false}, // Juneteenth
+		{date(2021, 6, 18), true, false},  // before Juneteenth was a holiday
+		{date(2024, 7, 3), true, true},
+		{date(2024, 7, 4), false, false},
+		{date(2020, 7, 3), false, false}, // Independence Day observed on Friday
+		{date(2024, 11, 28), false, false},
+		{date(2024, 11, 29), true, true},
+		{date(2024, 12, 24), true, true},
+		{date(2022, 12, 26), false, false}, // Christmas observed on Monday
+		{date(2021, 12, 31), true, false},  // New Year's Day on a Saturday isn't observed
+		{date(2012, 10, 29), false, false}, // Hurricane Sandy
+		{date(2024, 6, 15), false, false},  // Saturday
+		{date(2024, 6, 17), true, false},
+	} {
+		if got := NYSE.IsOpen(tc.d); got != tc.open {
+			t.Errorf("IsOpen(%s) = %v, want %v", tc.d.Format("2006-01-02"), got, tc.open)
+		}
+		if _, got := NYSE.HalfDay(tc.d); got != tc.half {
+			t.Errorf("HalfDay(%s) = %v, want %v", tc.d.Format("2006-01-02"), got, tc.half)
+		}
+	}
+	if name, ok := NYSE.Holiday(date(2025, 4, 18)); !ok || name != "Good Friday" {
+		t.Errorf("Holiday(2025-04-18) = %q, %v", name, ok)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	// The NYSE had 252 sessions in 2024 and 250 in 2023.
+	if n := NYSE.Sessions(date(2024, 1, 1), date(2024, 12, 31)); n != 252 {
+		t.Errorf("2024 sessions = %d", n)
+	}
+	if n := NYSE.SessionsPerYear(date(2023, 3, 1), date(2024, 2, 1)); n != 251 {
+		t.Errorf("sessions per year over 2023-24 = %v", n)
+	}
+	if n := AlwaysOpen.SessionsPerYear(date(2024, 5, 1), date(2024, 5, 2)); n != 366 {
+		t.Errorf("24/7 sessions in 2024 = %v", n)
+	}
+	if d := NYSE.Next(date(2024, 3, 28)); !d.Equal(date(2024, 4, 1)) {
+		t.Errorf("Next(Thursday before Good Friday) = %v", d)
+	}
+	if d := NYSE.AddSessions(date(2024, 12, 23), 3); !d.Equal(date(2024, 12, 27)) {
+		t.Errorf("3 sessions after 2024-12-23 = %v", d)
+	}
+	if d := NYSE.AddSessions(date(2024, 1, 2), -1); !d.Equal(date(2023, 12, 29)) {
+		t.Errorf("session before 2024-01-02 = %v", d)
+	}
+}
+
+func TestIsLastSession(t *testing.T) {
+	// March 2024 ended on Good Friday, so Thursday the 28th was its last
+	// session; 2024's week 26 ended on Friday the 28th of June.
+	for _, tc := range []struct {
+		d     time.Time
+		every string
+		want  bool
+	}{
+		{date(2024, 3, 28), "month", true},
+		{date(2024, 3, 27), "month", false},
+		{date(2024, 3, 28), "quarter", true},
+		{date(2024, 6, 28), "week", true},
+		{date(2024, 6, 27), "week", false},
+		{date(2024, 12, 31), "year", true},
+	} {
+		if got := NYSE.IsLastSession(tc.d, tc.every); got != tc.want {
+			t.Errorf("IsLastSession(%s, %s) = %v", tc.d.Format("2006-01-02"), tc.every, got)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package charts renders a backtest Result's equity curve, drawdown and
+// rolling Sharpe ratios, or several results' equity curves overlaid, to
+// PNG or SVG files with gonum/plot, for a quick look at a run without a
+// browser.
+package charts
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"my-backtester/src/backtest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// Width and Height are the size of each chart file.
+var (
+	Width  = 10 * vg.Inch
+	Height = 4 * vg.Inch
+)
+
+var (
+	equityColor    = color.RGBA{R: 31, G: 111, B: 235, A: 255}
+	benchmarkColor = color.RGBA{R: 150, G: 150, B: 150, A: 255}
+	drawdownColor  = color.RGBA{R: 207, G: 34, B: 46, A: 255}
+)
+
+type chart struct {
+	suffix string
+	draw   func(backtest.Result) (*plot.Plot, error)
+}
+
+// Write renders each result's charts into dir as <portfolio>_equity,
+// _drawdown and, when it has rolling windows, _rolling_sharpe, with
+// format ("png" or "svg") as the extension. dir is created if needed;
+// file names are made safe as WriteArtifacts makes them.
+func Write(dir, format string, results []backtest.Result) error {
+	if format != "png" && format != "svg" {
+		return fmt.Errorf("charts: format %q: must be png or svg", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("charts: %w", err)
+	}
+	for _, r := range results {
+		base := filepath.Join(dir, backtest.ArtifactName(r.PortfolioName))
+		charts := []chart{{"_equity", Equity}, {"_drawdown", Drawdown}}
+		if len(r.Rolling) > 0 {
+			charts = append(charts, chart{"_rolling_sharpe", RollingSharpe})
+		}
+		for _, c := range charts {
+			p, err := c.draw(r)
+			if err != nil {
+				return fmt.Errorf("charts: %s: %w", r.PortfolioName, err)
+			}
+			if err := p.Save(Width, Height, base+c.suffix+"."+format); err != nil {
+				return fmt.Errorf("charts: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteComparison renders c's equity curves, overlaid, into dir as
+// comparison_equity with format ("png" or "svg") as the extension.
+func WriteComparison(dir, format string, c backtest.StrategyComparison) error {
+	if format != "png" && format != "svg" {
+		return fmt.Errorf("charts: format %q: must be png or svg", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("charts: %w", err)
+	}
+	p, err := Comparison(c)
+	if err != nil {
+		return fmt.Errorf("charts: comparison: %w", err)
+	}
+	if err := p.Save(Width, Height, filepath.Join(dir, "comparison_equity."+format)); err != nil {
+		return fmt.Errorf("charts: %w", err)
+	}
+	return nil
+}
+
+// Comparison plots every equity curve of c, each scaled to start at 100,
+// on one chart.
+func Comparison(c backtest.StrategyComparison) (*plot.Plot, error) {
+	p := newPlot("Equity compared", "Value (start = 100)")
+	for i, name := range c.Portfolios {
+		xys, err := series(c.Dates, c.Equity[i], 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := addLine(p, name, xys, plotutil.Color(i), false); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Equity plots r's equity curve, and its benchmark's when it has one.
+func Equity(r backtest.Result) (*plot.Plot, error) {
+	p := newPlot(r.PortfolioName+" equity", "Value")
+	equity, err := series(r.Dates, r.EquityCurve, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := addLine(p, "Portfolio", equity, equityColor, false); err != nil {
+		return nil, err
+	}
+	if len(r.BenchmarkCurve) > 0 {
+		bench, err := series(r.Dates, r.BenchmarkCurve, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := addLine(p, "Benchmark", bench, benchmarkColor, true); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Drawdown plots how far, in percent, r's equity is below its running
+// peak on each date.
+func Drawdown(r backtest.Result) (*plot.Plot, error) {
+	p := newPlot(r.PortfolioName+" drawdown", "Drawdown (%)")
+	dd := make([]float64, len(r.EquityCurve))
+	peak := math.Inf(-1)
+	for i, v := range r.EquityCurve {
+		peak = math.Max(peak, v)
+		if peak > 0 {
+			dd[i] = (v/peak - 1) * 100
+		}
+	}
+	xys, err := series(r.Dates, dd, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := addLine(p, "", xys, drawdownColor, false); err != nil {
+		return nil, err
+	}
+	p.Legend.Top = false
+	return p, nil
+}
+
+// RollingSharpe plots r's annualized rolling Sharpe ratio for each of
+// its windows, from the date each window first fills.
+func RollingSharpe(r backtest.Result) (*plot.Plot, error) {
+	p := newPlot(r.PortfolioName+" rolling Sharpe", "Sharpe")
+	for i, ro := range r.Rolling {
+		xys, err := series(r.Dates, ro.Sharpe, ro.Window-1)
+		if err != nil {
+			return nil, err
+		}
+		if err := addLine(p, strconv.Itoa(ro.Window)+" bars", xys, plotutil.Color(i), false); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func newPlot(title, yLabel string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = title
+	p.Y.Label.Text = yLabel
+	p.X.Tick.Marker = plot.TimeTicks{Format: "2006-01-02"}
+	p.Add(plotter.NewGrid())
+	p.Legend.Top = true
+	p.Legend.Left = true
+	return p
+}
+
+// series pairs values with dates, the first value falling on
+// dates[offset], as Unix seconds for TimeTicks.
+func series(dates []string, values []float64, offset int) (plotter.XYs, error) {
+	xys := make(plotter.XYs, 0, len(values))
+	for i, v := range values {
+		if offset+i >= len(dates) {
+			break
+		}
+		d, err := time.Parse("2006-01-02", dates[offset+i])
+		if err != nil {
+			// An intraday bar's date carries its time.
+			d, err = time.Parse("2006-01-02 15:04:05", dates[offset+i])
+		}
+		if err != nil {
+			return nil, err
+		}
+		xys = append(xys, plotter.XY{X: float64(d.Unix()), Y: v})
+	}
+	return xys, nil
+}
+
+func addLine(p *plot.Plot, name string, xys plotter.XYs, c color.Color, dashed bool) error {
+	if len(xys) == 0 {
+		return nil
+	}
+	l, err := plotter.NewLine(xys)
+	if err != nil {
+		return err
+	}
+	l.Color = c
+	l.Width = vg.Points(1.5)
+	if dashed {
+		l.Dashes = []vg.Length{vg.Points(4), vg.Points(3)}
+	}
+	p.Add(l)
+	if name != "" {
+		p.Legend.Add(name, l)
+	}
+	return nil
+}
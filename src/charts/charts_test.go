@@ -0,0 +1,70 @@
+package charts
+
+import (
+	"bytes"
+	"my-backtester/src/backtest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := backtest.Result{
+		PortfolioName:  "a/b",
+		EquityCurve:    []float64{1000, 1100, 990, 1045},
+		BenchmarkCurve: []float64{1000, 1010, 1020, 1030},
+		Dates:          []string{"2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"},
+		Rolling:        []backtest.Rolling{{Window: 2, Sharpe: []float64{1, -1, 0.5}}},
+	}
+	dir := t.TempDir()
+	if err := Write(dir, "png", []backtest.Result{r}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a_b_equity.png", "a_b_drawdown.png", "a_b_rolling_sharpe.png"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(b, []byte("\x89PNG")) {
+			t.Errorf("%s is not a PNG", name)
+		}
+	}
+
+	r.Rolling = nil
+	if err := Write(dir, "svg", []backtest.Result{r}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "a_b_equity.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("<svg")) || !bytes.Contains(b, []byte("Benchmark")) {
+		t.Errorf("equity SVG lacks the chart or its legend")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a_b_rolling_sharpe.svg")); !os.IsNotExist(err) {
+		t.Errorf("rolling Sharpe chart written without rolling windows")
+	}
+
+	if err := Write(dir, "gif", []backtest.Result{r}); err == nil {
+		t.Error("gif: expected error")
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	dates := []string{"2024-01-02", "2024-01-03", "2024-01-04"}
+	c := backtest.CompareStrategies([]backtest.Result{
+		{PortfolioName: "fast", EquityCurve: []float64{1000, 1100, 1050}, Dates: dates},
+		{PortfolioName: "slow", EquityCurve: []float64{500, 505, 510}, Dates: dates},
+	})
+	dir := t.TempDir()
+	if err := WriteComparison(dir, "svg", c); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "comparison_equity.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("fast")) || !bytes.Contains(b, []byte("slow")) {
+		t.Errorf("comparison SVG lacks a portfolio's legend entry")
+	}
+}
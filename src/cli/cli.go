@@ -0,0 +1,1479 @@
+// Package cli is the backtester's command line: its subcommands, their
+// flags and the output they print. The binary in cmd/backtester only
+// calls Main, so another program can embed the same commands.
+package cli
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"my-backtester/src/api"
+	"my-backtester/src/backtest"
+	"my-backtester/src/charts"
+	"my-backtester/src/dashboard"
+	"my-backtester/src/data"
+	"my-backtester/src/logging"
+	"my-backtester/src/rpc"
+	"my-backtester/src/tui"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// commands are the subcommands Main dispatches on. Without one the
+// arguments are run's flags, so invocations from before subcommands
+// existed (e.g. `backtester -paper`) keep working.
+var commands = []struct {
+	name, args, help string
+}{
+	{"run", "[flags]", "backtest the config's portfolios, or one given by -strategy and -tickers"},
+	{"paper", "[flags]", "paper-trade the config's portfolios against live quotes until interrupted"},
+	{"walkforward", "[flags]", "walk-forward test the config's portfolios per its [WalkForward] block"},
+	{"optimize", "[flags]", "search the config's portfolios' strategies and params per its [Optimize] block"},
+	{"crossvalidate", "[flags]", "K-fold cross-validate the config's portfolios over blocks of their history per its [CrossValidation] block"},
+	{"compare", "[flags]", "backtest the config's portfolios, or several -strategy specs, and compare them side by side"},
+	{"data", "[flags]", "download Binance or macro series into the DB, or list its tickers"},
+	{"fetch", "[flags] [ticker ...]", "download daily Yahoo bars for tickers or a ticker file into the DB"},
+	{"import", "[flags] <file> ...", "validate and load OHLCV CSV or Parquet files into the DB"},
+	{"validate-data", "[flags]", "check the DB's bars for duplicates, bad prices, jumps, gaps and OHLC errors"},
+	{"list", "[kind]", "print the compiled-in strategies, sizers, commissions and indicators"},
+	{"list-strategies", "", "same as `list strategies`"},
+	{"verify", "<manifest>", "re-run a manifest against the current DB and report differences"},
+	{"dashboard", "[flags]", "serve a browser dashboard of the results saved in the DB"},
+	{"serve", "[flags]", "serve a JSON (and with -grpc, gRPC) API that queues, runs and reports backtests"},
+	{"schema", "config|results", "print the JSON Schema of the config or results format"},
+}
+
+// handlers run each command, by name, on the arguments after it.
+var handlers = map[string]func(ctx context.Context, args []string){
+	"run":           runAs("run"),
+	"paper":         runAs("paper"),
+	"walkforward":   runAs("walkforward"),
+	"optimize":      runAs("optimize"),
+	"crossvalidate": runAs("crossvalidate"),
+	"compare":       runAs("compare"),
+	"data":          dataCmd,
+	"fetch":         fetchCmd,
+	"import":        importCmd,
+	"validate-data": validateDataCmd,
+	"list":          func(_ context.Context, args []string) { listCmd(args) },
+	"list-strategies": func(_ context.Context, args []string) {
+		listCmd(append([]string{"strategies"}, args...))
+	},
+	"verify":    verifyCmd,
+	"dashboard": dashboardCmd,
+	"serve":     serveCmd,
+	"schema": func(_ context.Context, args []string) {
+		if len(args) != 1 {
+			log.Fatal("usage: backtester schema config|results")
+		}
+		printSchema(args[0])
+	},
+	"help": func(context.Context, []string) { usage(os.Stdout) },
+}
+
+// runAs is runCmd as cmdName, one of the commands sharing run's flags.
+func runAs(cmdName string) func(ctx context.Context, args []string) {
+	return func(ctx context.Context, args []string) { runCmd(ctx, args, cmdName) }
+}
+
+// command splits args into the command and its arguments. Without a
+// command they are run's flags.
+func command(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "run", args
+}
+
+// Main runs the command line args, the arguments after the program
+// name. It exits the process on a usage or fatal error.
+func Main(args []string) {
+	cmd, args := command(args)
+	run, ok := handlers[cmd]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "backtester: unknown command %q\n\n", cmd)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	// Ctrl-C cancels the queries and downloads in flight. Paper trading
+	// treats it as the end of the session and still writes its results.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	run(ctx, args)
+}
+
+// verifyCmd re-runs the manifest named by its one argument.
+func verifyCmd(ctx context.Context, args []string) {
+	fs := newFlagSet("verify")
+	var lf logFlags
+	lf.register(fs)
+	fs.Parse(args)
+	setupLogging(lf)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := verify(ctx, fs.Arg(0), dbPath()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: backtester <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.help)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run `backtester <command> -h` for a command's flags. Without a command, flags are run's.")
+}
+
+// newFlagSet builds a subcommand's flag set, whose -h names the command.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: backtester %s %s\n\n%s.\n\n", c.name, c.args, c.help)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// logFlags are the logging flags every command takes.
+type logFlags struct {
+	debug               bool
+	level, format, file string
+	files               string
+}
+
+func (lf *logFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&lf.debug, "debug", false,
+		"Log at debug level to backtester.log, trades to transactions.log, and serve pprof and expvar on localhost:6060")
+	fs.StringVar(&lf.level, "log-level", "", "Least severe level logged: debug, info, warn or error (default info, or debug under -debug)")
+	fs.StringVar(&lf.format, "log-format", "text", "Log encoding: text or json")
+	fs.StringVar(&lf.file, "log-file", "", "Write logs to this file instead of stderr")
+	fs.StringVar(&lf.files, "log-files", "",
+		"Give components their own files, e.g. transactions=tx.log,data=data.log (components: "+strings.Join(logging.Components, ", ")+")")
+}
+
+// setupLogging applies lf. Under -debug it also serves pprof and expvar
+// (including the run's progress at /debug/vars).
+func setupLogging(lf logFlags) {
+	cfg := logging.Config{Level: slog.LevelInfo, Format: lf.format, Files: map[string]string{}}
+	if lf.debug {
+		cfg.Level = slog.LevelDebug
+		cfg.Files["transactions"] = "transactions.log"
+		if lf.file == "" {
+			lf.file = "backtester.log"
+		}
+	}
+	if lf.level != "" {
+		level, err := logging.ParseLevel(lf.level)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Level = level
+	}
+	files, err := logging.ParseFiles(lf.files)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for component, path := range files {
+		cfg.Files[component] = path
+	}
+	if lf.file != "" {
+		f, err := os.OpenFile(lf.file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		cfg.Output = f
+	}
+	if err := logging.Setup(cfg); err != nil {
+		log.Fatal(err)
+	}
+	if !lf.debug {
+		return
+	}
+	expvar.Publish("progress", expvar.Func(func() any {
+		if s, ok := backtest.CurrentProgress(); ok {
+			return s
+		}
+		return nil
+	}))
+	go func() {
+		log.Println(http.ListenAndServe("localhost:6060", nil))
+	}()
+}
+
+// dbPath is the DuckDB file used unless a config names another.
+func dbPath() string {
+	if p := os.Getenv(backtest.EnvDBPath); p != "" {
+		return p
+	}
+	return "../stock_data.db"
+}
+
+func printSchema(kind string) {
+	b, err := backtest.JSONSchema(kind)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+}
+
+// listCmd prints the registered components of the kind named by args[0],
+// or of every kind.
+func listCmd(args []string) {
+	var kind backtest.Kind
+	if len(args) > 0 {
+		k, err := backtest.ParseKind(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		kind = k
+	}
+	if err := backtest.WriteComponents(os.Stdout, kind); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ingestFlags are the download settings shared by `data` and run's
+// legacy -ingest-* flags.
+type ingestFlags struct {
+	binance, macro, interval, start, end string
+	every                                time.Duration
+}
+
+// dataCmd downloads the requested series, or lists the tickers in the
+// DB when none are requested.
+func dataCmd(ctx context.Context, args []string) {
+	fs := newFlagSet("data")
+	var lf logFlags
+	lf.register(fs)
+	var in ingestFlags
+	fs.StringVar(&in.binance, "binance", "", "Comma-separated Binance symbols (e.g. BTCUSDT,ETHUSDT) to download")
+	fs.StringVar(&in.macro, "macro", "", "Comma-separated macro series (e.g. fred:DGS10,fred:VIXCLS) to download")
+	fs.StringVar(&in.interval, "interval", "1d", "Binance kline interval")
+	fs.StringVar(&in.start, "start", "2020-01-01", "First date to download (YYYY-MM-DD)")
+	fs.StringVar(&in.end, "end", "", "Last date to download (YYYY-MM-DD); default today")
+	fs.DurationVar(&in.every, "every", 0, "Repeat the download at this interval (e.g. 24h) until interrupted")
+	actions := fs.String("actions", "", "Load dividends and splits from a CSV of ticker,date,dividend,split rows")
+	riskFree := fs.String("risk-free", "", "FRED yield series (e.g. DTB3) to download into 3MTreasuryYields as daily rates")
+	sectors := fs.String("sectors", "", "Load ticker sectors, for universe filters, from a CSV of ticker,sector rows")
+	metadata := fs.String("metadata", "", "Load security metadata from a CSV of ticker,sector,industry,market_cap rows")
+	indexMembers := fs.String("index-members", "", "Load index membership history from a CSV of index,ticker,added,removed rows")
+	fs.Parse(args)
+	setupLogging(lf)
+
+	if *actions != "" {
+		loadActions(ctx, dbPath(), *actions)
+		return
+	}
+	if *sectors != "" {
+		loadSectors(ctx, dbPath(), *sectors)
+		return
+	}
+	if *metadata != "" {
+		loadMetadata(ctx, dbPath(), *metadata)
+		return
+	}
+	if *indexMembers != "" {
+		loadIndexMembers(ctx, dbPath(), *indexMembers)
+		return
+	}
+	if *riskFree != "" {
+		loadRiskFree(ctx, dbPath(), *riskFree, in.start, in.end)
+		return
+	}
+
+	if in.binance != "" || in.macro != "" {
+		runIngest(ctx, dbPath(), in)
+		return
+	}
+	store, err := data.Open(dbPath())
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	tickers, err := store.ListTickers(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, t := range tickers {
+		fmt.Println(t)
+	}
+}
+
+// loadActions upserts the corporate actions in the CSV at csvPath into
+// the DB at path.
+func loadActions(ctx context.Context, path, csvPath string) {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	byTicker, err := data.ReadActionsCSV(f)
+	if err != nil {
+		log.Fatalf("%s: %v", csvPath, err)
+	}
+	store, err := data.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	n := 0
+	for ticker, actions := range byTicker {
+		if err := store.InsertActions(ctx, ticker, actions); err != nil {
+			log.Fatal(err)
+		}
+		n += len(actions)
+	}
+	log.Printf("Loaded %d corporate actions for %d tickers", n, len(byTicker))
+}
+
+// loadSectors upserts the ticker sectors in the CSV at csvPath into the
+// DB at path.
+func loadSectors(ctx context.Context, path, csvPath string) {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	sectors, err := data.ReadSectorsCSV(f)
+	if err != nil {
+		log.Fatalf("%s: %v", csvPath, err)
+	}
+	store, err := data.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	if err := store.SetSectors(ctx, sectors); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded sectors for %d tickers", len(sectors))
+}
+
+// loadMetadata upserts the security metadata in the CSV at csvPath into
+// the DB at path.
+func loadMetadata(ctx context.Context, path, csvPath string) {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	meta, err := data.ReadMetadataCSV(f)
+	if err != nil {
+		log.Fatalf("%s: %v", csvPath, err)
+	}
+	store, err := data.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	if err := store.SetMetadata(ctx, meta); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded metadata for %d tickers", len(meta))
+}
+
+// loadIndexMembers replaces the membership history of each index in the
+// CSV at csvPath with the CSV's rows for it.
+func loadIndexMembers(ctx context.Context, path, csvPath string) {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	indexes, err := data.ReadIndexMembersCSV(f)
+	if err != nil {
+		log.Fatalf("%s: %v", csvPath, err)
+	}
+	store, err := data.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := store.SetIndexMembers(ctx, name, indexes[name]); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Loaded %d membership spells for %s", len(indexes[name]), name)
+	}
+}
+
+// validateDataCmd checks the bars in the DB (see data.Store.ValidateData)
+// and prints each problem found, followed by a count of each kind. It
+// exits 1 if there were any, so it can gate a data refresh.
+func validateDataCmd(ctx context.Context, args []string) {
+	fs := newFlagSet("validate-data")
+	var lf logFlags
+	lf.register(fs)
+	tickers := fs.String("tickers", "", "Comma-separated tickers to check; default all")
+	maxJump := fs.Float64("max-jump", data.DefaultMaxJump, "Flag close-to-close moves larger than this fraction either way")
+	maxGap := fs.Int("max-gap-days", data.DefaultMaxGapDays, "Flag more than this many calendar days between a ticker's bars")
+	quarantine := fs.Bool("quarantine", false, "Rewrite the quarantine_bars table with the flagged bars")
+	fs.Parse(args)
+	setupLogging(lf)
+	if *maxJump <= 0 || *maxGap <= 0 {
+		log.Fatal("-max-jump and -max-gap-days must be positive")
+	}
+
+	store, err := data.Open(dbPath())
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	opts := data.QualityOptions{MaxJump: *maxJump, MaxGapDays: *maxGap, Quarantine: *quarantine}
+	if *tickers != "" {
+		opts.Tickers = strings.Split(*tickers, ",")
+	}
+	issues, err := store.ValidateData(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	counts := make(map[data.QualityCheck]int)
+	for _, is := range issues {
+		date := is.Bar.Date.Format("2006-01-02")
+		ticker := is.Ticker
+		if is.Interval > 0 {
+			date = is.Bar.Date.Format("2006-01-02 15:04:05")
+			ticker += " " + is.Interval.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", ticker, date, is.Check, is.Detail)
+		counts[is.Check]++
+	}
+	tw.Flush()
+	if len(issues) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+	var summary []string
+	for _, c := range []data.QualityCheck{data.CheckDuplicate, data.CheckPrice, data.CheckOHLC, data.CheckJump, data.CheckGap} {
+		if counts[c] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[c], c))
+		}
+	}
+	fmt.Printf("\n%d problems: %s\n", len(issues), strings.Join(summary, ", "))
+	if *quarantine {
+		fmt.Println("Flagged bars written to quarantine_bars.")
+	}
+	os.Exit(1)
+}
+
+// fetchCmd downloads daily Yahoo bars for the tickers named on the
+// command line or in -file into the DB. Each ticker resumes the day
+// after its last stored bar unless -full is given, so an interrupted
+// fetch can simply be re-run.
+func fetchCmd(ctx context.Context, args []string) {
+	fs := newFlagSet("fetch")
+	var lf logFlags
+	lf.register(fs)
+	tickerList := fs.String("tickers", "", "Comma-separated tickers or index symbols (e.g. AAPL,MSFT,^GSPC)")
+	file := fs.String("file", "", "Read tickers from this file: comma- or space-separated, # comments")
+	startFlag := fs.String("start", "2000-01-01", "First date to download (YYYY-MM-DD)")
+	endFlag := fs.String("end", "", "Last date to download (YYYY-MM-DD); default today")
+	delay := fs.Duration("delay", time.Second, "Minimum time between Yahoo requests")
+	full := fs.Bool("full", false, "Re-download from -start even for tickers already in the DB")
+	fs.Parse(args)
+	setupLogging(lf)
+
+	tickers := append(splitList(*tickerList), fs.Args()...)
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		listed, err := data.ReadTickers(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *file, err)
+		}
+		tickers = append(tickers, listed...)
+	}
+	if len(tickers) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	start, err := time.Parse("2006-01-02", *startFlag)
+	if err != nil {
+		log.Fatalf("fetch start: %v", err)
+	}
+	end := time.Now().UTC()
+	if *endFlag != "" {
+		if end, err = time.Parse("2006-01-02", *endFlag); err != nil {
+			log.Fatalf("fetch end: %v", err)
+		}
+	}
+	store, err := data.Open(dbPath())
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	if err := fetch(ctx, store, tickers, start, end, *delay, !*full); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// fetch downloads each ticker's bars between start and end, waiting at
+// least delay between requests. With resume a ticker already in the DB
+// starts the day after its last bar, and is skipped when that is past
+// end.
+func fetch(
+	ctx context.Context, store *data.Store, tickers []string,
+	start, end time.Time, delay time.Duration, resume bool,
+) error {
+	var limit <-chan time.Time
+	if delay > 0 {
+		t := time.NewTicker(delay)
+		defer t.Stop()
+		limit = t.C
+	}
+	failed, requested := 0, false
+	for _, ticker := range tickers {
+		from := start
+		if resume {
+			last, ok, err := store.LastDate(ctx, ticker)
+			if err != nil {
+				return err
+			}
+			if next := last.AddDate(0, 0, 1); ok && next.After(from) {
+				from = next
+			}
+		}
+		if from.After(end) {
+			log.Printf("%s is up to date", ticker)
+			continue
+		}
+		if requested && limit != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-limit:
+			}
+		}
+		requested = true
+		n, err := store.IngestYahoo(ctx, ticker, from, end)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("fetch %s: %v", ticker, err)
+			failed++
+			continue
+		}
+		log.Printf("fetched %d bars for %s from %s", n, ticker, from.Format("2006-01-02"))
+	}
+	if failed > 0 {
+		return fmt.Errorf("fetch: %d of %d tickers failed", failed, len(tickers))
+	}
+	return nil
+}
+
+// importCmd loads the OHLCV files named on the command line into the
+// DB, as CSV or Parquet by their extension unless -format says.
+func importCmd(ctx context.Context, args []string) {
+	fs := newFlagSet("import")
+	var lf logFlags
+	lf.register(fs)
+	format := fs.String("format", "", "csv or parquet; default from each file's extension")
+	columns := fs.String("columns", "", "Map bar fields to file columns (e.g. date=Datetime,close=Adj Close)")
+	var opts data.ImportOptions
+	fs.StringVar(&opts.Ticker, "ticker", "", "Ticker of every row, for files without a ticker column")
+	fs.StringVar(&opts.DateFormat, "date-format", "", "Go layout of CSV dates (e.g. 01/02/2006); default YYYY-MM-DD")
+	interval := fs.String("interval", "", "Bar interval of intraday files (e.g. 5m, 1h); default daily")
+	fs.Parse(args)
+	setupLogging(lf)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var err error
+	if opts.Columns, err = data.ParseColumns(*columns); err != nil {
+		log.Fatal(err)
+	}
+	if opts.Interval, err = data.ParseInterval(*interval); err != nil {
+		log.Fatal(err)
+	}
+	store, err := data.Open(dbPath())
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	for _, path := range fs.Args() {
+		kind := strings.ToLower(*format)
+		if kind == "" {
+			kind = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		}
+		var n int
+		switch kind {
+		case "csv":
+			n, err = store.ImportCSV(ctx, path, opts)
+		case "parquet":
+			n, err = store.ImportParquet(ctx, path, opts)
+		default:
+			log.Fatalf("%s: unknown format %q; use -format csv or parquet", path, kind)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("imported %d bars from %s", n, path)
+	}
+}
+
+// loadRiskFree downloads the FRED series between start and end (default
+// today) into the DB at path's risk-free table.
+func loadRiskFree(ctx context.Context, path, series, start, end string) {
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		log.Fatalf("risk-free start: %v", err)
+	}
+	to := time.Now().UTC()
+	if end != "" {
+		if to, err = time.Parse("2006-01-02", end); err != nil {
+			log.Fatalf("risk-free end: %v", err)
+		}
+	}
+	store, err := data.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	n, err := store.RefreshRiskFree(ctx, series, from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded %d risk-free rates from fred:%s", n, series)
+}
+
+// runIngest downloads in's series into the DB at path, once or every
+// in.every until ctx is done.
+func runIngest(ctx context.Context, path string, in ingestFlags) {
+	store, err := data.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	start, err := time.Parse("2006-01-02", in.start)
+	if err != nil {
+		log.Fatalf("ingest start: %v", err)
+	}
+	var fixedEnd time.Time
+	if in.end != "" {
+		if fixedEnd, err = time.Parse("2006-01-02", in.end); err != nil {
+			log.Fatalf("ingest end: %v", err)
+		}
+	}
+	for {
+		end := fixedEnd
+		if end.IsZero() {
+			end = time.Now().UTC()
+		}
+		if err := ingest(ctx, store, in.binance, in.macro, in.interval, start, end); err != nil {
+			if in.every <= 0 {
+				log.Fatal(err)
+			}
+			log.Print(err)
+		}
+		if in.every <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(in.every):
+		}
+	}
+}
+
+// runFlags are the flags of run and the commands that share them.
+type runFlags struct {
+	lf             logFlags
+	jsonOut        bool
+	ndjsonOut      bool
+	auditLookahead bool
+	compareExt     string
+	compareFormat  string
+	compareTrades  string
+	comparePort    string
+	configPath     string
+	manifestPath   string
+	verifyPath     string
+	schemaKind     string
+	ingest         ingestFlags
+	name           string
+	strategy       string
+	strategies     stringList
+	tickers        string
+	start          string
+	end            string
+	cash           float64
+	outDir         string
+	seed           int64
+	prices         string
+	stream         bool
+	htmlReport     string
+	chartFormat    string
+	useTUI         bool
+	baselines      bool
+	attribution    bool
+	paper          bool
+	wf             backtest.WalkForwardConfig
+	opt            backtest.OptimizeConfig
+	cv             backtest.CrossValidationConfig
+}
+
+// newRunFlags defines cmdName's flags, which set the returned runFlags
+// once the flag set parses.
+func newRunFlags(cmdName string) (*runFlags, *flag.FlagSet) {
+	f := &runFlags{paper: cmdName == "paper"}
+	fs := newFlagSet(cmdName)
+	if cmdName == "walkforward" {
+		fs.IntVar(&f.wf.InSample, "in-sample", 0, "Bars per in-sample window (overrides [WalkForward] in_sample)")
+		fs.IntVar(&f.wf.OutOfSample, "out-of-sample", 0, "Bars per out-of-sample window (overrides out_of_sample)")
+		fs.IntVar(&f.wf.Step, "step", 0, "Bars between windows (overrides step)")
+		fs.StringVar(&f.wf.Objective, "objective", "", "Result field to maximize in-sample, or -Field to minimize (overrides objective)")
+	}
+	if cmdName == "optimize" {
+		fs.StringVar((*string)(&f.opt.Method), "method", "", "Search: genetic, bayes or grid (overrides [Optimize] method)")
+		fs.IntVar(&f.opt.Population, "population", 0, "Configurations run per generation (overrides [Optimize] population)")
+		fs.IntVar(&f.opt.Generations, "generations", 0, "Most generations or rounds run (overrides generations)")
+		fs.IntVar(&f.opt.Patience, "patience", 0, "Generations without a better score before stopping (overrides patience)")
+		fs.StringVar(&f.opt.Objective, "objective", "", "Result field to maximize, or -Field to minimize (overrides objective)")
+	}
+	if cmdName == "crossvalidate" {
+		fs.IntVar(&f.cv.Folds, "folds", 0, "Blocks the history is cut into (overrides [CrossValidation] folds)")
+		fs.StringVar(&f.cv.Objective, "objective", "", "Result field to maximize, or -Field to minimize (overrides objective)")
+	}
+	f.lf.register(fs)
+	fs.BoolVar(
+		&f.paper, "paper", f.paper,
+		"Paper-trade the configured portfolios against live quotes until interrupted",
+	)
+	fs.BoolVar(
+		&f.jsonOut, "json", false,
+		"Write results to stdout as a JSON document (see README) instead of logging them",
+	)
+	fs.BoolVar(
+		&f.ndjsonOut, "ndjson", false,
+		"Write results to stdout as newline-delimited JSON, one result per line",
+	)
+	fs.BoolVar(
+		&f.auditLookahead, "audit-lookahead", false,
+		"Fail any portfolio whose strategy reads a bar later than the one being processed",
+	)
+	defaultConfig := os.Getenv(backtest.EnvConfigPath)
+	if defaultConfig == "" {
+		defaultConfig = "../config.toml"
+	}
+	fs.StringVar(
+		&f.configPath, "config", defaultConfig,
+		"Path to portfolio config (TOML, or JSON if it ends in .json); - reads stdin",
+	)
+	if cmdName == "compare" {
+		fs.Var(&f.strategies, "strategy", "Compare a portfolio with this strategy spec, named after it, instead of the config's; repeat for each strategy")
+	} else {
+		fs.StringVar(&f.strategy, "strategy", "", "Run one portfolio with this strategy spec (see `backtester list strategies`) instead of the config's")
+	}
+	fs.StringVar(&f.tickers, "tickers", "", "Comma-separated tickers for the -strategy portfolio")
+	fs.StringVar(&f.start, "start", "", "First date of the -strategy portfolio's window (YYYY-MM-DD)")
+	fs.StringVar(&f.end, "end", "", "Last date of the -strategy portfolio's window (YYYY-MM-DD); default today")
+	fs.Float64Var(&f.cash, "cash", 10000, "Starting buying power of the -strategy portfolio")
+	fs.StringVar(&f.name, "name", "cli", "Name of the -strategy portfolio")
+	fs.StringVar(
+		&f.outDir, "outdir", "",
+		"Write each portfolio's equity curve and trade blotter CSVs to this directory (overrides [Output] dir)",
+	)
+	fs.Int64Var(
+		&f.seed, "seed", 0,
+		"Seed every portfolio without its own Seed with this plus its index (overrides the config's Seed)",
+	)
+	fs.StringVar(
+		&f.prices, "prices", "",
+		"Apply dividends and splits as raw (paid as they happen) or adjusted (in the prices) for every portfolio (overrides Prices)",
+	)
+	fs.DurationVar(
+		&backtest.ProgressInterval, "progress", 0,
+		"Print portfolios done, throughput and ETA to stderr this often (e.g. 5s)",
+	)
+	fs.BoolVar(
+		&f.useTUI, "tui", false,
+		"Show live progress, the best returns so far and the current portfolio's equity in the terminal while the run goes",
+	)
+	fs.BoolVar(
+		&f.stream, "stream", false,
+		"Stream each portfolio's bars from the database one date at a time instead of loading every ticker's history up front",
+	)
+	fs.StringVar(
+		&f.htmlReport, "html-report", "",
+		"Write a self-contained HTML report of the results (charts, metrics, attribution, trades) to this file",
+	)
+	fs.StringVar(
+		&f.chartFormat, "charts", "",
+		"Render each portfolio's equity, drawdown and rolling Sharpe charts as png or svg into the -outdir directory",
+	)
+	fs.BoolVar(
+		&f.baselines, "baselines", true,
+		"Also run the equal-weight, 60/40 and random baseline portfolios and print the results against them (see [Baselines])",
+	)
+	fs.BoolVar(
+		&f.attribution, "attribution", false,
+		"Print each portfolio's return attributed by ticker, strategy and long or short book",
+	)
+	fs.StringVar(
+		&f.manifestPath, "manifest", "",
+		"Write the run's reproducibility manifest here (default: beside the [Output] file)",
+	)
+	fs.StringVar(
+		&f.compareExt, "compare-external", "",
+		"Compare a portfolio's run against this export from another backtester",
+	)
+	fs.StringVar(
+		&f.compareFormat, "compare-format", "csv",
+		"Format of -compare-external: backtrader, zipline, quantconnect, or csv",
+	)
+	fs.StringVar(
+		&f.compareTrades, "compare-trades", "",
+		"Optional trade-list CSV for the external run (when the export has no fills)",
+	)
+	fs.StringVar(
+		&f.comparePort, "compare-portfolio", "",
+		"Portfolio to compare; defaults to the first in the config",
+	)
+	// Flags from before the verify, schema and data commands.
+	fs.StringVar(&f.verifyPath, "verify", "", "Same as `backtester verify <path>`")
+	fs.StringVar(&f.schemaKind, "schema", "", "Same as `backtester schema <kind>`")
+	fs.StringVar(&f.ingest.binance, "ingest-binance", "", "Same as `backtester data -binance`")
+	fs.StringVar(&f.ingest.macro, "ingest-macro", "", "Same as `backtester data -macro`")
+	fs.DurationVar(&f.ingest.every, "ingest-every", 0, "Same as `backtester data -every`")
+	fs.StringVar(&f.ingest.interval, "ingest-interval", "1d", "Same as `backtester data -interval`")
+	fs.StringVar(&f.ingest.start, "ingest-start", "2020-01-01", "Same as `backtester data -start`")
+	fs.StringVar(&f.ingest.end, "ingest-end", "", "Same as `backtester data -end`")
+	return f, fs
+}
+
+// adHoc reports whether -strategy or -tickers describe the portfolios
+// instead of the config.
+func (f *runFlags) adHoc() bool {
+	return f.strategy != "" || len(f.strategies) > 0 || f.tickers != ""
+}
+
+// portfolios are the portfolios the flags describe: the -strategy one,
+// or for compare one per -strategy, named after its spec.
+func (f *runFlags) portfolios(cmdName string) ([]backtest.PortfolioConfig, error) {
+	specs := []string{f.strategy}
+	if cmdName == "compare" && len(f.strategies) > 0 {
+		specs = f.strategies
+	}
+	var out []backtest.PortfolioConfig
+	for _, spec := range specs {
+		name := f.name
+		if cmdName == "compare" {
+			name = spec
+		}
+		pc, err := flagPortfolio(name, spec, f.tickers, f.start, f.end, f.cash)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pc)
+	}
+	return out, nil
+}
+
+// runCmd backtests, paper-trades or walk-forward tests the configured
+// portfolios, as cmdName says. -strategy and -tickers replace them with
+// one portfolio described by flags, so a quick run needs no config file.
+func runCmd(ctx context.Context, args []string, cmdName string) {
+	f, fs := newRunFlags(cmdName)
+	fs.Parse(args)
+	setupLogging(f.lf)
+
+	// `backtester list` before subcommands took the list as arguments.
+	if fs.Arg(0) == "list" {
+		listCmd(fs.Args()[1:])
+		return
+	}
+	if f.schemaKind != "" {
+		printSchema(f.schemaKind)
+		return
+	}
+
+	duckDBPath := dbPath()
+
+	if f.ingest.binance != "" || f.ingest.macro != "" {
+		runIngest(ctx, duckDBPath, f.ingest)
+		return
+	}
+
+	if f.verifyPath != "" {
+		if err := verify(ctx, f.verifyPath, duckDBPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	adHoc := f.adHoc()
+
+	// Load configuration from file, or stdin for scripted callers
+	var config *backtest.Config
+	var err error
+	if f.configPath == "-" {
+		var text []byte
+		if text, err = io.ReadAll(os.Stdin); err == nil {
+			config, err = backtest.ParseConfig(string(text), "")
+		}
+	} else {
+		config, err = backtest.LoadConfig(f.configPath)
+		// A flag-described run borrows [Database], [Output], ... from
+		// the config when there is one, but doesn't need it.
+		if adHoc && errors.Is(err, os.ErrNotExist) {
+			config, err = &backtest.Config{}, nil
+		}
+	}
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if adHoc {
+		if config.Portfolios, err = f.portfolios(cmdName); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cmdName == "compare" && len(config.Portfolios) < 2 {
+		log.Fatal("compare needs at least two portfolios: repeat -strategy, or configure them")
+	}
+	config.ApplyEnv(os.Getenv)
+	if f.seed != 0 {
+		config.Seed = f.seed
+	}
+	config.ApplySeed()
+	if f.outDir != "" {
+		if config.Output == nil {
+			config.Output = &backtest.OutputConfig{}
+		}
+		config.Output.Dir = f.outDir
+	}
+	if config.Database != nil && config.Database.Path != "" {
+		duckDBPath = config.Database.Path
+	}
+
+	store, closeStore, err := config.Database.OpenStore(duckDBPath)
+	if err != nil {
+		log.Fatalf("Failed to open the database: %v", err)
+	}
+	defer closeStore()
+
+	var priceMode backtest.PriceMode
+	if f.prices != "" {
+		if priceMode, err = backtest.ParsePriceMode(f.prices); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Convert config to portfolios
+	portfolios := make([]*backtest.Portfolio, 0, len(config.Portfolios))
+	for _, pc := range config.Portfolios {
+		portfolio, err := pc.ToPortfolio()
+		if err != nil {
+			log.Fatalf(
+				"Failed to convert portfolio %s: %v", pc.Name, err,
+			)
+		}
+		if f.auditLookahead {
+			portfolio.AuditLookahead = true
+		}
+		if priceMode != "" {
+			portfolio.Prices = priceMode
+		}
+		portfolios = append(portfolios, portfolio)
+	}
+	if db, ok := store.(*data.Store); ok && config.Database != nil && config.Database.RiskFreeSeries != "" {
+		refreshRiskFree(ctx, db, config.Database.RiskFreeSeries, portfolios)
+	}
+
+	if f.paper {
+		if _, err := backtest.RunPaper(
+			ctx, store, portfolios, config.Paper, config.Output, config.Webhook,
+		); err != nil {
+			log.Fatalf("RunPaper: %v", err)
+		}
+		return
+	}
+
+	runBacktest := func(ctx context.Context) ([]backtest.Result, error) {
+		switch {
+		case cmdName == "walkforward":
+			results, err := backtest.RunWalkForward(ctx, store, portfolios, walkForwardConfig(config.WalkForward, f.wf), config.Output)
+			if err != nil {
+				err = fmt.Errorf("walk-forward: %w", err)
+			}
+			return results, err
+		case cmdName == "optimize":
+			results, err := backtest.RunOptimize(ctx, store, portfolios, optimizeConfig(config.Optimize, f.opt), config.Output)
+			if err != nil {
+				err = fmt.Errorf("optimize: %w", err)
+			}
+			return results, err
+		case cmdName == "crossvalidate":
+			results, err := backtest.RunCrossValidate(ctx, store, portfolios, crossValidationConfig(config.CrossValidation, f.cv), config.Output)
+			if err != nil {
+				err = fmt.Errorf("cross-validation: %w", err)
+			}
+			return results, err
+		case f.stream:
+			return backtest.RunStream(ctx, store, portfolios, config.Output)
+		}
+		return backtest.Run(ctx, store, portfolios, config.Output)
+	}
+	var results []backtest.Result
+	if f.useTUI {
+		// The screen replaces the progress lines.
+		backtest.ProgressInterval = 0
+		tracker := tui.NewTracker()
+		for _, p := range portfolios {
+			backtest.WithEngineHook(tracker.Hook)(p)
+		}
+		results, err = tui.Run(ctx, tracker, runBacktest)
+	} else {
+		results, err = runBacktest(ctx)
+	}
+	if err != nil {
+		log.Fatalf("Run: %v", err)
+	}
+	if f.manifestPath == "" {
+		f.manifestPath = backtest.ManifestPath(config)
+	}
+	if f.manifestPath != "" {
+		m, err := backtest.NewManifest(ctx, store, config, portfolios, results)
+		if err == nil {
+			err = backtest.WriteManifest(f.manifestPath, m)
+		}
+		if err != nil {
+			log.Printf("manifest: %v", err)
+		}
+	}
+	if f.htmlReport != "" {
+		if err := writeHTMLReport(f.htmlReport, results); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	if f.chartFormat != "" {
+		dir := "."
+		if config.Output != nil && config.Output.Dir != "" {
+			dir = config.Output.Dir
+		}
+		if err := charts.Write(dir, f.chartFormat, results); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	if err := backtest.PostRunSignals(ctx, config.Webhook, results); err != nil {
+		log.Printf("webhook: %v", err)
+	}
+	if f.jsonOut {
+		if err := backtest.WriteResultsJSON(os.Stdout, results); err != nil {
+			log.Fatalf("write results: %v", err)
+		}
+	} else if f.ndjsonOut {
+		if err := backtest.WriteResultsNDJSON(os.Stdout, results); err != nil {
+			log.Fatalf("write results: %v", err)
+		}
+	}
+	// -json already owns stdout.
+	var out io.Writer = os.Stdout
+	if f.jsonOut || f.ndjsonOut {
+		out = os.Stderr
+	}
+	if cmdName == "compare" {
+		if err := writeComparison(out, results, config.Output, f.chartFormat); err != nil {
+			log.Printf("compare: %v", err)
+		}
+	}
+	if f.baselines && cmdName != "walkforward" && !f.stream && len(results) > 0 {
+		b, err := backtest.RunBaselines(ctx, store, portfolios, config.Baselines)
+		if err == nil {
+			err = backtest.WriteBaselines(out, b, results)
+		}
+		if err != nil {
+			log.Printf("baselines: %v", err)
+		}
+	}
+	if f.attribution {
+		if err := backtest.WriteAttribution(out, results); err != nil {
+			log.Printf("attribution: %v", err)
+		}
+	}
+	if f.compareExt != "" {
+		if err := compareExternal(
+			out, results, f.comparePort, f.compareExt, f.compareFormat, f.compareTrades,
+		); err != nil {
+			log.Fatalf("compare: %v", err)
+		}
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Lookahead != "" {
+			log.Print(r.Lookahead)
+			failed++
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("lookahead audit: %d portfolio(s) read future bars", failed)
+	}
+}
+
+// flagPortfolio describes the portfolio given by run's -strategy,
+// -tickers, -start, -end, -cash and -name flags.
+func flagPortfolio(name, strategy, tickers, start, end string, cash float64) (backtest.PortfolioConfig, error) {
+	pc := backtest.PortfolioConfig{
+		Name:        name,
+		BuyingPower: cash,
+		StartTime:   start,
+		EndTime:     end,
+		Tickers:     splitList(tickers),
+		Strategy:    strategy,
+	}
+	switch {
+	case pc.Strategy == "":
+		return pc, fmt.Errorf("-tickers needs -strategy")
+	case len(pc.Tickers) == 0 && !strings.HasPrefix(pc.Strategy, "trades:"):
+		return pc, fmt.Errorf("-strategy needs -tickers")
+	case pc.StartTime == "":
+		return pc, fmt.Errorf("-strategy needs -start")
+	}
+	if pc.EndTime == "" {
+		pc.EndTime = time.Now().UTC().Format("2006-01-02")
+	}
+	return pc, nil
+}
+
+// writeComparison prints results side by side to out and, when output
+// names a directory, writes their equity curves there overlaid, as
+// comparison_equity.csv and a chart in chartFormat (png by default).
+func writeComparison(out io.Writer, results []backtest.Result, output *backtest.OutputConfig, chartFormat string) error {
+	c := backtest.CompareStrategies(results)
+	if err := backtest.WriteStrategyComparison(out, c); err != nil {
+		return err
+	}
+	if output == nil || output.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(output.Dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(output.Dir, "comparison_equity.csv"))
+	if err != nil {
+		return err
+	}
+	if err := backtest.WriteComparisonEquityCSV(f, c); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if chartFormat == "" {
+		chartFormat = "png"
+	}
+	return charts.WriteComparison(output.Dir, chartFormat, c)
+}
+
+// stringList is a flag that may be repeated, collecting each value.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// walkForwardConfig layers the walkforward command's flags over the
+// config's [WalkForward] block.
+func walkForwardConfig(cfg *backtest.WalkForwardConfig, flags backtest.WalkForwardConfig) *backtest.WalkForwardConfig {
+	out := backtest.WalkForwardConfig{}
+	if cfg != nil {
+		out = *cfg
+	}
+	if flags.InSample != 0 {
+		out.InSample = flags.InSample
+	}
+	if flags.OutOfSample != 0 {
+		out.OutOfSample = flags.OutOfSample
+	}
+	if flags.Step != 0 {
+		out.Step = flags.Step
+	}
+	if flags.Objective != "" {
+		out.Objective = flags.Objective
+	}
+	return &out
+}
+
+// optimizeConfig layers the optimize command's flags over the config's
+// [Optimize] block.
+func optimizeConfig(cfg *backtest.OptimizeConfig, flags backtest.OptimizeConfig) *backtest.OptimizeConfig {
+	out := backtest.OptimizeConfig{}
+	if cfg != nil {
+		out = *cfg
+	}
+	if flags.Method != "" {
+		out.Method = flags.Method
+	}
+	if flags.Population != 0 {
+		out.Population = flags.Population
+	}
+	if flags.Generations != 0 {
+		out.Generations = flags.Generations
+	}
+	if flags.Patience != 0 {
+		out.Patience = flags.Patience
+	}
+	if flags.Objective != "" {
+		out.Objective = flags.Objective
+	}
+	return &out
+}
+
+// crossValidationConfig layers the crossvalidate command's flags over
+// the config's [CrossValidation] block.
+func crossValidationConfig(cfg *backtest.CrossValidationConfig, flags backtest.CrossValidationConfig) *backtest.CrossValidationConfig {
+	out := backtest.CrossValidationConfig{}
+	if cfg != nil {
+		out = *cfg
+	}
+	if flags.Folds != 0 {
+		out.Folds = flags.Folds
+	}
+	if flags.Objective != "" {
+		out.Objective = flags.Objective
+	}
+	return &out
+}
+
+// dashboardCmd serves the results dashboard until interrupted.
+func dashboardCmd(ctx context.Context, args []string) {
+	fs := newFlagSet("dashboard")
+	var lf logFlags
+	lf.register(fs)
+	addr := fs.String("addr", "localhost:8080", "Address to serve the dashboard on")
+	fs.IntVar(&dashboard.Limit, "limit", dashboard.Limit, "List at most this many of the newest results")
+	fs.Parse(args)
+	setupLogging(lf)
+
+	store, err := data.Open(dbPath())
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	srv := &http.Server{Addr: *addr, Handler: dashboard.Handler(store)}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	fmt.Printf("Serving results dashboard on http://%s/\n", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// serveCmd serves the run API, and with -grpc the gRPC service over the
+// same queue, until interrupted, which also cancels the run in flight.
+func serveCmd(ctx context.Context, args []string) {
+	fs := newFlagSet("serve")
+	var lf logFlags
+	lf.register(fs)
+	addr := fs.String("addr", "localhost:8081", "Address to serve the API on")
+	grpcAddr := fs.String("grpc", "", "Also serve the gRPC service on this address (e.g. localhost:50051)")
+	fs.IntVar(&api.QueueLimit, "queue", api.QueueLimit, "Refuse new runs while this many are queued")
+	fs.IntVar(&api.Retain, "retain", api.Retain, "Keep at most this many runs in memory")
+	fs.Parse(args)
+	setupLogging(lf)
+
+	store, err := data.Open(dbPath())
+	if err != nil {
+		log.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer store.Close()
+	runs := api.New(ctx, store)
+	srv := &http.Server{Addr: *addr, Handler: runs}
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g := grpc.NewServer()
+		rpc.Register(g, runs)
+		go g.Serve(lis)
+		defer g.Stop()
+		fmt.Printf("Serving gRPC on %s\n", *grpcAddr)
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	fmt.Printf("Serving the run API on http://%s/runs\n", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// writeHTMLReport writes results' HTML report to path.
+func writeHTMLReport(path string, results []backtest.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("html report: %w", err)
+	}
+	if err := backtest.WriteHTMLReport(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// refreshRiskFree brings the DB's risk-free rates up to date from the
+// FRED series, back to a month before the earliest portfolio start. A
+// failed download is logged and the run uses the rates already stored.
+func refreshRiskFree(ctx context.Context, store *data.Store, series string, portfolios []*backtest.Portfolio) {
+	end := time.Now().UTC()
+	start := end
+	for _, p := range portfolios {
+		if !p.StartTime.IsZero() && p.StartTime.Before(start) {
+			start = p.StartTime
+		}
+	}
+	n, err := store.RefreshRiskFree(ctx, series, start.AddDate(0, -1, 0), end)
+	if err != nil {
+		log.Printf("risk-free refresh: %v; using the stored rates", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("refreshed %d risk-free rates from fred:%s", n, series)
+	}
+}
+
+// verify re-runs the manifest at path and fails unless inputs and
+// results match it exactly. The manifest's own [Database] settings win
+// over dbPath, as they did for the recorded run.
+func verify(ctx context.Context, path, dbPath string) error {
+	m, err := backtest.LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := backtest.ParseConfig(m.Config, "toml")
+	if err != nil {
+		return fmt.Errorf("manifest config: %w", err)
+	}
+	if cfg.Database != nil && cfg.Database.Path != "" {
+		dbPath = cfg.Database.Path
+	}
+	store, closeStore, err := cfg.Database.OpenStore(dbPath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer closeStore()
+	diffs, notes, err := backtest.VerifyManifest(ctx, store, m)
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		fmt.Println("note:", n)
+	}
+	for _, d := range diffs {
+		fmt.Println("differs:", d)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("verify %s: %d difference(s)", path, len(diffs))
+	}
+	fmt.Printf("verify %s: reproduced bit for bit\n", path)
+	return nil
+}
+
+// compareExternal writes a report of how the named portfolio's result
+// matches an export from another backtester.
+func compareExternal(
+	out io.Writer,
+	results []backtest.Result,
+	portfolio, path, format, tradesPath string,
+) error {
+	ext, err := backtest.LoadExternal(path, format)
+	if err != nil {
+		return err
+	}
+	if tradesPath != "" {
+		if ext.Trades, err = backtest.LoadTradeList(tradesPath); err != nil {
+			return err
+		}
+	}
+	for _, r := range results {
+		if portfolio == "" || r.PortfolioName == portfolio {
+			return backtest.WriteComparison(out, backtest.CompareExternal(r, ext))
+		}
+	}
+	return fmt.Errorf("no result for portfolio %q", portfolio)
+}
+
+// ingest downloads the comma-separated Binance symbols and macro series
+// into store. Every item is attempted and failures are logged.
+func ingest(ctx context.Context, store *data.Store, binance, macro, interval string, start, end time.Time) error {
+	failed := 0
+	for _, sym := range splitList(binance) {
+		n, err := store.IngestBinance(ctx, sym, interval, start, end)
+		if err != nil {
+			log.Printf("ingest %s: %v", sym, err)
+			failed++
+			continue
+		}
+		log.Printf("ingested %d %s bars for %s", n, interval, sym)
+	}
+	for _, spec := range splitList(macro) {
+		n, err := store.IngestMacro(ctx, spec, start, end)
+		if err != nil {
+			log.Printf("ingest %s: %v", spec, err)
+			failed++
+			continue
+		}
+		log.Printf("ingested %d observations for %s", n, spec)
+	}
+	if failed > 0 {
+		return fmt.Errorf("ingest: %d series failed", failed)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
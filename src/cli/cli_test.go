@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		cmd  string
+		rest []string
+	}{
+		{nil, "run", nil},
+		{[]string{"paper"}, "paper", []string{}},
+		{[]string{"optimize", "-population", "8"}, "optimize", []string{"-population", "8"}},
+		{[]string{"list", "strategies"}, "list", []string{"strategies"}},
+		// Before subcommands, every invocation was run's flags.
+		{[]string{"-paper", "-debug"}, "run", []string{"-paper", "-debug"}},
+		{[]string{"-verify", "m.json"}, "run", []string{"-verify", "m.json"}},
+		{[]string{"bogus", "-x"}, "bogus", []string{"-x"}},
+	} {
+		cmd, rest := command(c.args)
+		if cmd != c.cmd || !reflect.DeepEqual(rest, c.rest) {
+			t.Errorf("command(%q) = %q, %q; want %q, %q", c.args, cmd, rest, c.cmd, c.rest)
+		}
+	}
+}
+
+// Every command usage lists dispatches, and nothing else does.
+func TestHandlers(t *testing.T) {
+	for _, c := range commands {
+		if handlers[c.name] == nil {
+			t.Errorf("%s: listed in usage but has no handler", c.name)
+		}
+	}
+	if handlers["help"] == nil {
+		t.Error("help has no handler")
+	}
+	if len(handlers) != len(commands)+1 {
+		t.Errorf("%d handlers for %d commands and help", len(handlers), len(commands))
+	}
+	for _, bad := range []string{"", "bogus", "Run", "-paper", "list strategies"} {
+		if _, ok := handlers[bad]; ok {
+			t.Errorf("%q dispatches", bad)
+		}
+	}
+}
+
+// parseRunFlags parses args as cmdName's flags, returning the error
+// flag.ExitOnError would exit on.
+func parseRunFlags(t *testing.T, cmdName string, args []string) (*runFlags, *flag.FlagSet, error) {
+	t.Helper()
+	f, fs := newRunFlags(cmdName)
+	fs.Init(cmdName, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return f, fs, fs.Parse(args)
+}
+
+func TestRunFlags(t *testing.T) {
+	for _, c := range []struct {
+		cmd   string
+		args  []string
+		check func(*runFlags, *flag.FlagSet) bool
+	}{
+		{"run", nil, func(f *runFlags, _ *flag.FlagSet) bool {
+			return !f.paper && f.baselines && f.cash == 10000 && f.name == "cli" && !f.adHoc()
+		}},
+		{"paper", nil, func(f *runFlags, _ *flag.FlagSet) bool { return f.paper }},
+		{"run", []string{"-json", "-seed", "7", "-outdir", "out"}, func(f *runFlags, _ *flag.FlagSet) bool {
+			return f.jsonOut && f.seed == 7 && f.outDir == "out"
+		}},
+		{"run", []string{"-strategy", "smaCross:5:20:greedy", "-tickers", "A,B"}, func(f *runFlags, _ *flag.FlagSet) bool {
+			return f.adHoc() && f.strategy == "smaCross:5:20:greedy" && f.tickers == "A,B"
+		}},
+		{"compare", []string{"-strategy", "buyAndHold", "-strategy", "dca:fixedDollar:500"}, func(f *runFlags, _ *flag.FlagSet) bool {
+			return f.adHoc() && reflect.DeepEqual([]string(f.strategies), []string{"buyAndHold", "dca:fixedDollar:500"})
+		}},
+		{"walkforward", []string{"-in-sample", "250", "-out-of-sample", "50"}, func(f *runFlags, _ *flag.FlagSet) bool {
+			return f.wf.InSample == 250 && f.wf.OutOfSample == 50
+		}},
+		{"optimize", []string{"-method", "grid", "-population", "8"}, func(f *runFlags, _ *flag.FlagSet) bool {
+			return f.opt.Method == "grid" && f.opt.Population == 8
+		}},
+		{"crossvalidate", []string{"-folds", "5"}, func(f *runFlags, _ *flag.FlagSet) bool { return f.cv.Folds == 5 }},
+		// The flat flags from before subcommands.
+		{"run", []string{"-paper"}, func(f *runFlags, _ *flag.FlagSet) bool { return f.paper }},
+		{"run", []string{"-verify", "m.json"}, func(f *runFlags, _ *flag.FlagSet) bool { return f.verifyPath == "m.json" }},
+		{"run", []string{"-schema", "results"}, func(f *runFlags, _ *flag.FlagSet) bool { return f.schemaKind == "results" }},
+		{"run", []string{"-ingest-binance", "BTCUSDT", "-ingest-interval", "1h"}, func(f *runFlags, _ *flag.FlagSet) bool {
+			return f.ingest.binance == "BTCUSDT" && f.ingest.interval == "1h" && f.ingest.start == "2020-01-01"
+		}},
+		{"run", []string{"-debug", "list", "sizers"}, func(f *runFlags, fs *flag.FlagSet) bool {
+			return f.lf.debug && reflect.DeepEqual(fs.Args(), []string{"list", "sizers"})
+		}},
+	} {
+		f, fs, err := parseRunFlags(t, c.cmd, c.args)
+		if err != nil {
+			t.Errorf("%s %q: %v", c.cmd, c.args, err)
+			continue
+		}
+		if !c.check(f, fs) {
+			t.Errorf("%s %q: parsed %+v", c.cmd, c.args, *f)
+		}
+	}
+
+	// Each command's own flags belong to it alone.
+	for _, c := range []struct {
+		cmd  string
+		args []string
+	}{
+		{"run", []string{"-in-sample", "250"}},
+		{"walkforward", []string{"-method", "grid"}},
+		{"paper", []string{"-folds", "5"}},
+		{"run", []string{"-no-such-flag"}},
+	} {
+		if _, _, err := parseRunFlags(t, c.cmd, c.args); err == nil {
+			t.Errorf("%s %q: expected error", c.cmd, c.args)
+		}
+	}
+}
+
+func TestRunFlags_Portfolios(t *testing.T) {
+	for _, c := range []struct {
+		cmd   string
+		args  []string
+		names []string
+		err   string
+	}{
+		{"run", []string{"-strategy", "buyAndHold", "-tickers", "A, B", "-start", "2024-01-02", "-end", "2024-06-28", "-cash", "5000"}, []string{"cli"}, ""},
+		{"run", []string{"-strategy", "buyAndHold", "-tickers", "A", "-start", "2024-01-02", "-name", "quick"}, []string{"quick"}, ""},
+		{"compare", []string{"-strategy", "buyAndHold", "-strategy", "dca:greedy", "-tickers", "A", "-start", "2024-01-02"}, []string{"buyAndHold", "dca:greedy"}, ""},
+		{"run", []string{"-tickers", "A", "-start", "2024-01-02"}, nil, "-tickers needs -strategy"},
+		{"run", []string{"-strategy", "buyAndHold", "-start", "2024-01-02"}, nil, "-strategy needs -tickers"},
+		{"run", []string{"-strategy", "buyAndHold", "-tickers", "A"}, nil, "-strategy needs -start"},
+		{"compare", []string{"-strategy", "buyAndHold", "-strategy", "dca:greedy", "-tickers", "A"}, nil, "-strategy needs -start"},
+	} {
+		f, _, err := parseRunFlags(t, c.cmd, c.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pcs, err := f.portfolios(c.cmd)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s %q: err = %v, want %q", c.cmd, c.args, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: %v", c.cmd, c.args, err)
+			continue
+		}
+		var names []string
+		for _, pc := range pcs {
+			names = append(names, pc.Name)
+			if !reflect.DeepEqual(pc.Tickers, splitList(f.tickers)) || pc.StartTime != f.start || pc.BuyingPower != f.cash || pc.EndTime == "" {
+				t.Errorf("%s %q: portfolio %+v", c.cmd, c.args, pc)
+			}
+		}
+		if !reflect.DeepEqual(names, c.names) {
+			t.Errorf("%s %q: portfolios %q, want %q", c.cmd, c.args, names, c.names)
+		}
+	}
+}
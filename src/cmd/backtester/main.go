@@ -0,0 +1,11 @@
+// Command backtester is the backtester's CLI; see package cli.
+package main
+
+import (
+	"my-backtester/src/cli"
+	"os"
+)
+
+func main() {
+	cli.Main(os.Args[1:])
+}
@@ -0,0 +1,200 @@
+// Package dashboard serves a browser dashboard over the results table
+// that runs with [Output] database = true append to: every saved result
+// with sortable metrics, and the equity and drawdown charts of the runs
+// picked for comparison.
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"my-backtester/src/backtest"
+	"my-backtester/src/data"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source is where the dashboard reads results; *data.Store is one.
+type Source interface {
+	QueryResults(ctx context.Context, limit int) ([]data.ResultRow, error)
+	QueryEquity(ctx context.Context, runAt time.Time, seq int) ([]data.EquityPoint, error)
+}
+
+// Limit is how many of the newest results the dashboard lists.
+var Limit = 5000
+
+// Handler serves the dashboard:
+//
+//	/             the results, newest run first, sortable by any column
+//	/run?id=...   a backtest.WriteHTMLReport of the chosen results
+//	/api/results  the results as JSON
+func Handler(src Source) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		rows, err := src.QueryResults(r.Context(), Limit)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := indexTemplate.Execute(w, indexPage{Rows: rows, Limit: Limit}); err != nil {
+			log.Printf("dashboard: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /run", func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query()["id"]
+		if len(ids) == 0 {
+			http.Error(w, "no id", http.StatusBadRequest)
+			return
+		}
+		rows, err := src.QueryResults(r.Context(), Limit)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		var results []backtest.Result
+		for _, id := range ids {
+			row, ok := findRow(rows, id)
+			if !ok {
+				http.Error(w, fmt.Sprintf("no result %q", id), http.StatusNotFound)
+				return
+			}
+			if row.Seq >= 0 {
+				if row.Equity, err = src.QueryEquity(r.Context(), row.RunAt, row.Seq); err != nil {
+					serverError(w, err)
+					return
+				}
+			}
+			results = append(results, backtest.RowResult(row))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := backtest.WriteHTMLReport(w, results); err != nil {
+			log.Printf("dashboard: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /api/results", func(w http.ResponseWriter, r *http.Request) {
+		rows, err := src.QueryResults(r.Context(), Limit)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rows)
+	})
+	return mux
+}
+
+func serverError(w http.ResponseWriter, err error) {
+	log.Printf("dashboard: %v", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// rowID identifies a result by its run's time and its place in the run.
+func rowID(row data.ResultRow) string {
+	return strconv.FormatInt(row.RunAt.UnixNano(), 10) + "-" + strconv.Itoa(row.Seq)
+}
+
+func findRow(rows []data.ResultRow, id string) (data.ResultRow, bool) {
+	for _, row := range rows {
+		if rowID(row) == id {
+			return row, true
+		}
+	}
+	return data.ResultRow{}, false
+}
+
+type indexPage struct {
+	Rows  []data.ResultRow
+	Limit int
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"id":  rowID,
+	"day": func(t time.Time) string { return t.Format("2006-01-02") },
+	"num": func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) },
+	"tickers": func(s string) string {
+		if t := strings.Split(s, ","); len(t) > 5 {
+			return strings.Join(t[:5], ",") + fmt.Sprintf(" +%d", len(t)-5)
+		}
+		return s
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Backtest results</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 8px; border-bottom: 1px solid #eee; text-align: right; white-space: nowrap; }
+th { cursor: pointer; background: #f4f4f4; position: sticky; top: 0; }
+td.text { text-align: left; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+#filter { margin-bottom: 1em; width: 20em; }
+</style>
+</head>
+<body>
+<h1>Backtest results</h1>
+{{if .Rows}}
+<form action="run" method="get">
+<p><input id="filter" type="search" placeholder="Filter by portfolio, strategy or ticker">
+<button type="submit">Compare selected</button>
+Click a column to sort; showing the newest {{len .Rows}} (at most {{.Limit}}).</p>
+<table id="results">
+<thead><tr><th></th><th>Run</th><th>Portfolio</th><th>Strategy</th><th>Tickers</th><th>Start</th><th>End</th>
+<th>AnnualReturn</th><th>SharpeRatio</th><th>SortinoRatio</th><th>MaxDrawdown</th><th>CalmarRatio</th><th>StandardDev</th>
+<th>BenchmarkReturn</th><th>ClosedTrades</th><th>WinRate</th><th>ProfitFactor</th></tr></thead>
+<tbody>
+{{range .Rows}}<tr><td><input type="checkbox" name="id" value="{{id .}}"></td>
+<td class="text">{{.RunAt.Format "2006-01-02 15:04:05"}}</td>
+<td class="text"><a href="run?id={{id .}}">{{.Portfolio}}</a></td>
+<td class="text">{{.Strategy}}</td><td class="text" title="{{.Tickers}}">{{tickers .Tickers}}</td>
+<td class="text">{{day .EffectiveStart}}</td><td class="text">{{day .EffectiveEnd}}</td>
+<td>{{num .AnnualReturn}}</td><td>{{num .SharpeRatio}}</td><td>{{num .SortinoRatio}}</td><td>{{num .MaxDrawdown}}</td>
+<td>{{num .CalmarRatio}}</td><td>{{num .StandardDev}}</td><td>{{num .BenchmarkReturn}}</td><td>{{.ClosedTrades}}</td>
+<td>{{num .WinRate}}</td><td>{{num .ProfitFactor}}</td></tr>
+{{end}}</tbody>
+</table>
+</form>
+<script>
+const table = document.getElementById("results");
+const body = table.tBodies[0];
+table.tHead.querySelectorAll("th").forEach((th, col) => {
+	if (col === 0) return;
+	th.addEventListener("click", () => {
+		const desc = !th.classList.contains("desc");
+		table.tHead.querySelectorAll("th").forEach(h => h.classList.remove("asc", "desc"));
+		th.classList.add(desc ? "desc" : "asc");
+		const key = tr => {
+			const s = tr.cells[col].textContent.trim();
+			const n = parseFloat(s);
+			return tr.cells[col].classList.contains("text") || isNaN(n) ? s : n;
+		};
+		const rows = Array.from(body.rows);
+		rows.sort((a, b) => {
+			const x = key(a), y = key(b);
+			const c = typeof x === "number" ? x - y : String(x).localeCompare(String(y));
+			return desc ? -c : c;
+		});
+		rows.forEach(tr => body.appendChild(tr));
+	});
+});
+document.getElementById("filter").addEventListener("input", e => {
+	const q = e.target.value.toLowerCase();
+	Array.from(body.rows).forEach(tr => {
+		const text = tr.cells[2].textContent + " " + tr.cells[3].textContent + " " + tr.cells[4].title;
+		tr.hidden = q !== "" && !text.toLowerCase().includes(q);
+	});
+});
+</script>
+{{else}}
+<p>No results yet. Run a backtest with <code>database = true</code> in its <code>[Output]</code> block to save them here.</p>
+{{end}}
+</body>
+</html>
+`))
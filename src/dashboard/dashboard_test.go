@@ -0,0 +1,86 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"my-backtester/src/backtest"
+	"my-backtester/src/data"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	rows   []data.ResultRow
+	equity map[int][]data.EquityPoint
+}
+
+func (s fakeSource) QueryResults(context.Context, int) ([]data.ResultRow, error) {
+	return s.rows, nil
+}
+
+func (s fakeSource) QueryEquity(_ context.Context, _ time.Time, seq int) ([]data.EquityPoint, error) {
+	return s.equity[seq], nil
+}
+
+func TestHandler(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	runAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	results := []backtest.Result{
+		{PortfolioName: "smaFast", Strategy: "smaCross", Tickers: []string{"A"},
+			Dates: []string{"2024-01-01", "2024-01-02"}, EquityCurve: []float64{1000, 1100}, Returns: []float64{0, 0.1},
+			Metrics: backtest.Metrics{SharpeRatio: 1.25}},
+		{PortfolioName: "<hold>", Strategy: "buyAndHold", Tickers: []string{"B"},
+			Dates: []string{"2024-01-01", "2024-01-02"}, EquityCurve: []float64{1000, 990}, Returns: []float64{0, -0.01}},
+	}
+	rows, err := backtest.ResultRows(runAt, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fakeSource{equity: map[int][]data.EquityPoint{}}
+	for _, r := range rows {
+		src.equity[r.Seq] = r.Equity
+		r.Equity = nil
+		src.rows = append(src.rows, r)
+	}
+	if got := src.equity[0][1]; !got.Date.Equal(day(1)) || got.Value != 1100 || got.Return != 0.1 {
+		t.Errorf("equity point = %+v", got)
+	}
+	srv := httptest.NewServer(Handler(src))
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	code, page := get("/")
+	id := rowID(src.rows[0])
+	for _, want := range []string{`value="` + id + `"`, "<td>1.25</td>", "&lt;hold&gt;"} {
+		if code != 200 || !strings.Contains(page, want) {
+			t.Errorf("index (%d) lacks %s", code, want)
+		}
+	}
+
+	code, page = get("/run?id=" + id + "&id=" + rowID(src.rows[1]))
+	if code != 200 || !strings.Contains(page, "<h2>smaFast</h2>") || !strings.Contains(page, "<h2>&lt;hold&gt;</h2>") ||
+		!strings.Contains(page, `<polyline class="equity"`) {
+		t.Errorf("run page (%d):\n%s", code, page)
+	}
+	if code, _ := get("/run?id=1-9"); code != http.StatusNotFound {
+		t.Errorf("unknown id: status %d", code)
+	}
+
+	code, body := get("/api/results")
+	var decoded []data.ResultRow
+	if err := json.Unmarshal([]byte(body), &decoded); code != 200 || err != nil || len(decoded) != 2 || decoded[1].Seq != 1 {
+		t.Errorf("api (%d, %v): %s", code, err, body)
+	}
+}
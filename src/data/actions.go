@@ -0,0 +1,260 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dividends, splits, symbol changes and spin-offs live in one table
+// keyed by ticker and ex-date. A row may carry several; a Split of 0 and
+// an empty NewTicker or SpinOff mean none. Tables from before symbol
+// changes and spin-offs gain their columns on first use.
+const actionsTableDDL = `
+	CREATE TABLE IF NOT EXISTS corporate_actions (
+		Ticker       VARCHAR,
+		Date         TIMESTAMP,
+		Dividend     DOUBLE,
+		Split        DOUBLE,
+		NewTicker    VARCHAR,
+		SpinOff      VARCHAR,
+		SpinOffRatio DOUBLE
+	);
+	ALTER TABLE corporate_actions ADD COLUMN IF NOT EXISTS NewTicker VARCHAR;
+	ALTER TABLE corporate_actions ADD COLUMN IF NOT EXISTS SpinOff VARCHAR;
+	ALTER TABLE corporate_actions ADD COLUMN IF NOT EXISTS SpinOffRatio DOUBLE;
+`
+
+// CorporateAction is a ticker's cash dividend, split, symbol change
+// and/or spin-off taking effect on Date, the ex-date: the first bar that
+// trades without the dividend or spun-off shares, at the post-split
+// share count, or under the new symbol. Dividend is cash per pre-split
+// share; Split is new shares per old share (2 for a 2-for-1, 0.1 for a
+// 1-for-10 reverse split), 0 when there is none.
+type CorporateAction struct {
+	Date     time.Time
+	Dividend float64
+	Split    float64
+	// NewTicker is the symbol the ticker trades under from Date, as FB
+	// became META; its bars before Date are stored under the old symbol
+	// and from Date under the new (see SymbolHistory).
+	NewTicker string
+	// SpinOff is a ticker whose shares are distributed on Date,
+	// SpinOffRatio of them per share held.
+	SpinOff      string
+	SpinOffRatio float64
+}
+
+// InsertActions upserts a ticker's actions into corporate_actions,
+// replacing any rows in the same date range. actions must be
+// date-ordered.
+func (s *Store) InsertActions(ctx context.Context, ticker string, actions []CorporateAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, actionsTableDDL); err != nil {
+		return fmt.Errorf("create corporate_actions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM corporate_actions
+		WHERE Ticker = ? AND Date BETWEEN ? AND ?;
+	`, ticker, actions[0].Date, actions[len(actions)-1].Date); err != nil {
+		return fmt.Errorf("clear %s actions: %w", ticker, err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO corporate_actions
+			(Ticker, Date, Dividend, Split, NewTicker, SpinOff, SpinOffRatio)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, a := range actions {
+		if _, err := stmt.ExecContext(ctx,
+			ticker, a.Date, a.Dividend, a.Split, a.NewTicker, a.SpinOff, a.SpinOffRatio,
+		); err != nil {
+			return fmt.Errorf("insert %s action %s: %w",
+				ticker, a.Date.Format("2006-01-02"), err)
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryActions returns the actions of tickers with ex-dates between
+// start and end, date-ordered per ticker. A database without the
+// corporate_actions table has none.
+func (s *Store) QueryActions(
+	ctx context.Context, tickers []string, start, end time.Time,
+) map[string][]CorporateAction {
+	out := map[string][]CorporateAction{}
+	if len(tickers) == 0 {
+		return out
+	}
+	if _, err := s.db.ExecContext(ctx, actionsTableDDL); err != nil {
+		logger.Error("create corporate_actions", "err", err)
+		return out
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(tickers)), ",")
+	query := fmt.Sprintf(`
+		SELECT Ticker, Date, Dividend, Split,
+		       COALESCE(NewTicker, ''), COALESCE(SpinOff, ''), COALESCE(SpinOffRatio, 0)
+		FROM corporate_actions
+		WHERE Ticker IN (%s)
+		  AND Date BETWEEN CAST(? AS TIMESTAMP_NS) AND CAST(? AS TIMESTAMP_NS)
+		ORDER BY Ticker, Date;
+	`, placeholders)
+	args := make([]any, 0, len(tickers)+2)
+	for _, t := range tickers {
+		args = append(args, t)
+	}
+	args = append(args,
+		start.Format("2006-01-02 15:04:05.000000000"),
+		end.Format("2006-01-02 15:04:05.000000000"),
+	)
+	// Unprepared, like QueryAssets: the IN list varies with the tickers.
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("query corporate actions", "err", err)
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ticker string
+		var a CorporateAction
+		if err := rows.Scan(&ticker, &a.Date, &a.Dividend, &a.Split, &a.NewTicker, &a.SpinOff, &a.SpinOffRatio); err != nil {
+			logger.Error("scan corporate action", "err", err)
+			continue
+		}
+		out[ticker] = append(out[ticker], a)
+	}
+	return out
+}
+
+// ReadActionsCSV parses "ticker,date,dividend,split" rows, optionally
+// followed by ",new_ticker,spin_off,spin_off_ratio" (a header row is
+// skipped; empty fields are 0 or none), into date-ordered actions by
+// ticker.
+func ReadActionsCSV(r io.Reader) (map[string][]CorporateAction, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	out := map[string][]CorporateAction{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(rec[0], "ticker") {
+			continue
+		}
+		a, err := parseAction(rec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		out[rec[0]] = append(out[rec[0]], a)
+	}
+	for _, as := range out {
+		sort.SliceStable(as, func(i, j int) bool { return as[i].Date.Before(as[j].Date) })
+	}
+	return out, nil
+}
+
+func parseAction(rec []string) (CorporateAction, error) {
+	var a CorporateAction
+	if len(rec) != 4 && len(rec) != 7 {
+		return a, fmt.Errorf("%d fields: want ticker,date,dividend,split and optionally new_ticker,spin_off,spin_off_ratio", len(rec))
+	}
+	if rec[0] == "" {
+		return a, errors.New("empty ticker")
+	}
+	date, err := time.Parse("2006-01-02", rec[1])
+	if err != nil {
+		return a, fmt.Errorf("date: %w", err)
+	}
+	a.Date = date
+	for _, f := range []struct {
+		name string
+		s    string
+		v    *float64
+	}{{"dividend", rec[2], &a.Dividend}, {"split", rec[3], &a.Split}} {
+		if f.s == "" {
+			continue
+		}
+		if *f.v, err = strconv.ParseFloat(f.s, 64); err != nil || *f.v < 0 {
+			return a, fmt.Errorf("%s %q: must be a non-negative number", f.name, f.s)
+		}
+	}
+	if len(rec) == 4 {
+		return a, nil
+	}
+	a.NewTicker, a.SpinOff = rec[4], rec[5]
+	if a.NewTicker == rec[0] {
+		return a, fmt.Errorf("new_ticker %q: same as the ticker", a.NewTicker)
+	}
+	if rec[6] != "" {
+		if a.SpinOffRatio, err = strconv.ParseFloat(rec[6], 64); err != nil || !(a.SpinOffRatio > 0) {
+			return a, fmt.Errorf("spin_off_ratio %q: must be a positive number", rec[6])
+		}
+	}
+	if (a.SpinOff == "") != (a.SpinOffRatio == 0) {
+		return a, errors.New("spin_off and spin_off_ratio go together")
+	}
+	return a, nil
+}
+
+// AdjustBars returns a copy of a ticker's date-ordered bars back-adjusted
+// for the actions that take effect after the first bar: prices before a
+// split are divided by it and volumes multiplied, and prices before a
+// dividend's ex-date are scaled by 1 - dividend/close of the bar before
+// it, so the series' returns are total returns and its last bar is
+// unchanged. An action between two bars applies to the later one.
+func AdjustBars(bars []AssetData, actions []CorporateAction) []AssetData {
+	out := make([]AssetData, len(bars))
+	copy(out, bars)
+	price, volume := 1.0, 1.0
+	j := len(actions) - 1
+	for i := len(out) - 1; i >= 0; i-- {
+		b := &out[i]
+		b.Open *= price
+		b.High *= price
+		b.Low *= price
+		b.Close *= price
+		b.Volume *= volume
+		if i == 0 {
+			break
+		}
+		// Actions dated after the previous bar, up to this one, split this
+		// bar from the ones before it.
+		for ; j >= 0 && actions[j].Date.After(bars[i-1].Date); j-- {
+			a := actions[j]
+			if a.Date.After(bars[i].Date) {
+				continue
+			}
+			if a.Split > 0 {
+				price /= a.Split
+				volume *= a.Split
+			}
+			if prev := bars[i-1].Close; a.Dividend > 0 && prev > 0 {
+				price *= math.Max(1-a.Dividend/prev, 0)
+			}
+		}
+	}
+	FillReturns(out)
+	return out
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdjustBars(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	bars := []AssetData{
+		{Date: day(2), Close: 100, Volume: 10},
+		{Date: day(3), Close: 100, Volume: 10},
+		{Date: day(5), Close: 50, Volume: 20},
+		{Date: day(8), Close: 49, Volume: 20},
+	}
+	actions := []CorporateAction{
+		{Date: day(2), Dividend: 5}, // in the first bar's price already
+		{Date: day(4), Split: 2},    // between bars: applies to day 5
+		{Date: day(8), Dividend: 1}, // 2% of the 50 close before it
+	}
+	got := AdjustBars(bars, actions)
+	for i, b := range got {
+		if math.Abs(b.Close-49) > 1e-9 {
+			t.Errorf("bar %d close = %v, want 49", i, b.Close)
+		}
+		if i > 0 && math.Abs(b.Return) > 1e-9 {
+			t.Errorf("bar %d return = %v, want 0", i, b.Return)
+		}
+	}
+	if got[0].Volume != 20 || got[2].Volume != 20 {
+		t.Errorf("volumes = %v, %v; want split-adjusted 20s", got[0].Volume, got[2].Volume)
+	}
+	if bars[0].Close != 100 {
+		t.Errorf("AdjustBars modified its input")
+	}
+}
+
+func TestReadActionsCSV(t *testing.T) {
+	in := "ticker,date,dividend,split\n" +
+		"AAPL,2020-08-31,,4\n" +
+		"KO,2024-03-14,0.485,\n" +
+		"AAPL,2020-08-07,0.82,\n"
+	got, err := ReadActionsCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aapl := got["AAPL"]
+	if len(aapl) != 2 || aapl[0].Dividend != 0.82 || aapl[1].Split != 4 || len(got["KO"]) != 1 {
+		t.Errorf("actions = %+v", got)
+	}
+	in = "FB,2022-06-09,,,META,,\n" +
+		"GE,2024-04-02,,,,GEV,0.25\n" +
+		"KO,2024-03-14,0.485,\n"
+	if got, err = ReadActionsCSV(strings.NewReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	if fb, ge := got["FB"], got["GE"]; len(fb) != 1 || fb[0].NewTicker != "META" ||
+		len(ge) != 1 || ge[0].SpinOff != "GEV" || ge[0].SpinOffRatio != 0.25 {
+		t.Errorf("actions = %+v", got)
+	}
+	for _, bad := range []string{
+		"AAPL,2020-13-01,,4\n", "AAPL,2020-08-31,x,\n", ",2020-08-31,1,\n", "AAPL,2020-08-31,1\n",
+		"FB,2022-06-09,,,FB,,\n", "GE,2024-04-02,,,,GEV,\n", "GE,2024-04-02,,,,GEV,-1\n",
+	} {
+		if _, err := ReadActionsCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// BinanceURL is the public spot REST endpoint used for klines and quotes.
+// Market data needs no API key.
+var BinanceURL = "https://api.binance.com"
+
+var binanceClient = &http.Client{Timeout: 15 * time.Second}
+
+// binanceKlineLimit is the maximum number of klines Binance returns per
+// request; FetchBinanceKlines pages through longer ranges.
+const binanceKlineLimit = 1000
+
+// FetchBinanceKlines downloads OHLCV klines for symbol (e.g. "BTCUSDT")
+// between start and end at the given Binance interval ("1d", "4h", ...).
+// Bars are dated by their open time in UTC and returned date-ordered with
+// returns filled. Crypto trades every day, so daily series include
+// weekends and holidays.
+func FetchBinanceKlines(
+	ctx context.Context,
+	symbol, interval string,
+	start, end time.Time,
+) ([]AssetData, error) {
+	if interval == "" {
+		interval = "1d"
+	}
+	var bars []AssetData
+	from := start.UnixMilli()
+	to := end.UnixMilli()
+	for from <= to {
+		q := url.Values{}
+		q.Set("symbol", symbol)
+		q.Set("interval", interval)
+		q.Set("startTime", strconv.FormatInt(from, 10))
+		q.Set("endTime", strconv.FormatInt(to, 10))
+		q.Set("limit", strconv.Itoa(binanceKlineLimit))
+
+		var raw [][]any
+		if err := binanceGet(ctx, "/api/v3/klines", q, &raw); err != nil {
+			return nil, fmt.Errorf("binance klines %s: %w", symbol, err)
+		}
+		if len(raw) == 0 {
+			break
+		}
+		for _, k := range raw {
+			bar, err := parseKline(k)
+			if err != nil {
+				return nil, fmt.Errorf("binance klines %s: %w", symbol, err)
+			}
+			bars = append(bars, bar)
+		}
+		if len(raw) < binanceKlineLimit {
+			break
+		}
+		from = bars[len(bars)-1].Date.UnixMilli() + 1
+	}
+	FillReturns(bars)
+	return bars, nil
+}
+
+// IngestBinance downloads symbol's klines and upserts them into
+// stock_data_optimized under the symbol as ticker, or into intraday_bars
+// for an interval under a day such as "1h" (see ParseInterval).
+func (s *Store) IngestBinance(ctx context.Context, symbol, interval string, start, end time.Time) (int, error) {
+	bars, err := FetchBinanceKlines(ctx, symbol, interval, start, end)
+	if err != nil {
+		return 0, err
+	}
+	if d, perr := ParseInterval(interval); perr == nil && d > 0 {
+		err = s.InsertIntradayBars(ctx, symbol, d, bars)
+	} else {
+		err = s.InsertBars(ctx, symbol, bars)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(bars), nil
+}
+
+// parseKline decodes one kline row:
+// [openTime, open, high, low, close, volume, closeTime, ...] with prices
+// and volume encoded as strings.
+func parseKline(k []any) (AssetData, error) {
+	if len(k) < 6 {
+		return AssetData{}, fmt.Errorf("short kline row: %v", k)
+	}
+	openMs, ok := k[0].(float64)
+	if !ok {
+		return AssetData{}, fmt.Errorf("kline open time %v", k[0])
+	}
+	var vals [5]float64
+	for i := range vals {
+		s, ok := k[i+1].(string)
+		if !ok {
+			return AssetData{}, fmt.Errorf("kline field %d: %v", i+1, k[i+1])
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return AssetData{}, fmt.Errorf("kline field %d: %w", i+1, err)
+		}
+		vals[i] = f
+	}
+	return AssetData{
+		Date:   time.UnixMilli(int64(openMs)).UTC(),
+		Open:   vals[0],
+		High:   vals[1],
+		Low:    vals[2],
+		Close:  vals[3],
+		Volume: vals[4],
+	}, nil
+}
+
+// BinanceQuotes serves rolling 24h ticker statistics as the current bar
+// for paper trading.
+type BinanceQuotes struct{}
+
+func (BinanceQuotes) Quote(ctx context.Context, ticker string) (AssetData, error) {
+	q := url.Values{}
+	q.Set("symbol", ticker)
+	var raw struct {
+		OpenPrice string `json:"openPrice"`
+		HighPrice string `json:"highPrice"`
+		LowPrice  string `json:"lowPrice"`
+		LastPrice string `json:"lastPrice"`
+		Volume    string `json:"volume"`
+		CloseTime int64  `json:"closeTime"`
+	}
+	if err := binanceGet(ctx, "/api/v3/ticker/24hr", q, &raw); err != nil {
+		return AssetData{}, fmt.Errorf("binance quote %s: %w", ticker, err)
+	}
+	bar := AssetData{Date: time.UnixMilli(raw.CloseTime).UTC()}
+	for _, f := range []struct {
+		dst *float64
+		src string
+	}{
+		{&bar.Open, raw.OpenPrice},
+		{&bar.High, raw.HighPrice},
+		{&bar.Low, raw.LowPrice},
+		{&bar.Close, raw.LastPrice},
+		{&bar.Volume, raw.Volume},
+	} {
+		v, err := strconv.ParseFloat(f.src, 64)
+		if err != nil {
+			return AssetData{}, fmt.Errorf("binance quote %s: %w", ticker, err)
+		}
+		*f.dst = v
+	}
+	return bar, nil
+}
+
+func binanceGet(ctx context.Context, path string, q url.Values, out any) error {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, BinanceURL+path+"?"+q.Encode(), nil,
+	)
+	if err != nil {
+		return err
+	}
+	resp, err := binanceClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFetchBinanceKlines_Pages(t *testing.T) {
+	day := int64(24 * time.Hour / time.Millisecond)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	total := binanceKlineLimit + 5
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		from, _ := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+		first := int((from - base + day - 1) / day)
+		w.Write([]byte("["))
+		n := 0
+		for i := first; i < total && n < binanceKlineLimit; i++ {
+			if n > 0 {
+				w.Write([]byte(","))
+			}
+			price := 100 + float64(i)
+			fmt.Fprintf(w, `[%d,"%g","%g","%g","%g","10",%d]`,
+				base+int64(i)*day, price, price, price, price, base+int64(i+1)*day-1)
+			n++
+		}
+		w.Write([]byte("]"))
+	}))
+	defer srv.Close()
+	old := BinanceURL
+	BinanceURL = srv.URL
+	defer func() { BinanceURL = old }()
+
+	bars, err := FetchBinanceKlines(
+		context.Background(),
+		"BTCUSDT", "1d",
+		time.UnixMilli(base).UTC(), time.UnixMilli(base+int64(total)*day).UTC(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2 pages", requests)
+	}
+	if len(bars) != total {
+		t.Fatalf("got %d bars, want %d", len(bars), total)
+	}
+	for i := 1; i < len(bars); i++ {
+		if !bars[i].Date.After(bars[i-1].Date) {
+			t.Fatalf("bars out of order at %d", i)
+		}
+	}
+	if want := 1.0 / 100; bars[1].Return < want-1e-12 || bars[1].Return > want+1e-12 {
+		t.Errorf("bars[1].Return = %v, want %v", bars[1].Return, want)
+	}
+}
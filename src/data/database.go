@@ -1,34 +1,130 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"my-backtester/src/logging"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/marcboeker/go-duckdb"
 )
 
-var db *sql.DB
+// logger records query timings at debug level and query errors.
+var logger = logging.For("data")
 
-func InitDB(dataSourceName string) (*sql.DB, error) {
-	var err error
-	db, err = sql.Open("duckdb", dataSourceName)
+// Store is a handle on one DuckDB database and its prepared statements.
+// It is safe for concurrent use: *sql.DB hands each goroutine its own
+// pooled connection, and go-duckdb opens all of them against the same
+// in-process DuckDB instance, so runner workers can query in parallel
+// without extra locking and every connection sees the settings from
+// Options. A process may hold several Stores on different files.
+//
+// Every query takes a context; cancelling it interrupts the query and
+// the method returns what it had read so far (usually nothing).
+type Store struct {
+	db *sql.DB
+
+	stmtMu sync.Mutex
+	stmts  map[string]*sql.Stmt
+}
+
+// Options tunes the DuckDB instance. Zero values keep DuckDB's defaults.
+type Options struct {
+	Threads       int    // worker threads DuckDB may use per query
+	MemoryLimit   string // e.g. "4GB"
+	TempDirectory string // spill directory for out-of-core operators
+	MaxOpenConns  int    // cap on pooled connections; 0 is unlimited
+}
+
+// dsn appends the non-zero options to path as DSN query parameters, which
+// go-duckdb applies as database config when the instance is created.
+func (o Options) dsn(path string) string {
+	v := url.Values{}
+	if o.Threads > 0 {
+		v.Set("threads", strconv.Itoa(o.Threads))
+	}
+	if o.MemoryLimit != "" {
+		v.Set("memory_limit", o.MemoryLimit)
+	}
+	if o.TempDirectory != "" {
+		v.Set("temp_directory", o.TempDirectory)
+	}
+	if len(v) == 0 {
+		return path
+	}
+	return path + "?" + v.Encode()
+}
+
+// Open opens the DuckDB file at path with default options.
+func Open(path string) (*Store, error) {
+	return OpenWithOptions(path, Options{})
+}
+
+// OpenWithOptions opens the DuckDB file at path with the given tuning
+// options.
+func OpenWithOptions(path string, opts Options) (*Store, error) {
+	if opts.Threads < 0 {
+		return nil, fmt.Errorf("threads %d: must be >= 0", opts.Threads)
+	}
+	conn, err := sql.Open("duckdb", opts.dsn(path))
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxOpenConns > 0 {
+		conn.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	return NewStore(conn), nil
+}
+
+// NewStore wraps an already-open database, e.g. an in-memory DuckDB
+// seeded by a test.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// DB is the underlying connection pool.
+func (s *Store) DB() *sql.DB { return s.db }
+
+// Close closes the prepared statements and the database.
+func (s *Store) Close() error {
+	s.stmtMu.Lock()
+	for _, st := range s.stmts {
+		st.Close()
+	}
+	s.stmts = make(map[string]*sql.Stmt)
+	s.stmtMu.Unlock()
+	return s.db.Close()
+}
+
+// prepared returns a cached prepared statement for query, preparing it
+// on first use. Statements are prepared lazily so opening a database
+// that lacks one of the tables doesn't fail until that table is queried.
+// Only fixed query text belongs here: a query built per call, like
+// QueryAssets' IN list, would leave a statement behind for every shape
+// and grow the cache without bound, so those run unprepared.
+func (s *Store) prepared(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.stmtMu.Lock()
+	defer s.stmtMu.Unlock()
+	if st, ok := s.stmts[query]; ok {
+		return st, nil
+	}
+	st, err := s.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	return db, nil
+	s.stmts[query] = st
+	return st, nil
 }
 
 // ListTickers returns the distinct ticker symbols available in the price
 // table, sorted alphabetically. Used to populate the UI's ticker picker.
-// Requires InitDB to have been called.
-func ListTickers() ([]string, error) {
-	if db == nil {
-		return nil, fmt.Errorf("database not initialized")
-	}
-	rows, err := db.Query(
+func (s *Store) ListTickers(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx,
 		`SELECT DISTINCT Ticker FROM stock_data_optimized ORDER BY Ticker`,
 	)
 	if err != nil {
@@ -54,45 +150,60 @@ type AssetData struct {
 	Low    float64
 	Close  float64
 	Volume float64
+	// Return and LogReturn are the simple and log close-to-close changes
+	// from the previous bar. They are filled once at load time by
+	// FillReturns so strategies and metrics don't recompute them per
+	// simulation; both are 0 on a ticker's first bar.
+	Return    float64
+	LogReturn float64
 }
 
-func ReadStocks(rows *sql.Rows) map[string][]AssetData {
+// ReadStocks buckets (Date, Ticker, OHLCV) rows ordered by ticker into
+// series with returns filled. On a scan or iteration error, including
+// the query's context being cancelled part way through, it returns the
+// error with what was read before it; the last series may be cut short.
+func ReadStocks(rows *sql.Rows) (map[string][]AssetData, error) {
 	allAssetData := make(map[string][]AssetData)
 	var currentTicker string
 	var dailyAssets []AssetData
+	var err error
 
 	for rows.Next() {
 		var assetData AssetData
 		var ticker string
-		err := rows.Scan(&assetData.Date, &ticker, &assetData.Open,
+		err = rows.Scan(&assetData.Date, &ticker, &assetData.Open,
 			&assetData.High, &assetData.Low, &assetData.Close, &assetData.Volume)
 		if err != nil {
-			log.Fatalf("Failed to scan row: %v", err)
+			err = fmt.Errorf("scan row: %w", err)
+			break
 		}
 
 		if currentTicker != "" && ticker != currentTicker {
+			FillReturns(dailyAssets)
 			allAssetData[currentTicker] = dailyAssets
 			dailyAssets = nil
 		}
 		currentTicker = ticker
 		dailyAssets = append(dailyAssets, assetData)
 	}
-	if err := rows.Err(); err != nil {
-		log.Fatalf("Error during rows iteration: %v", err)
+	if err == nil {
+		err = rows.Err()
 	}
 
 	// Add the last ticker
 	if currentTicker != "" {
+		FillReturns(dailyAssets)
 		allAssetData[currentTicker] = dailyAssets
 	}
 
-	return allAssetData
+	return allAssetData, err
 }
 
-func QueryAllAssets(
+func (s *Store) QueryAllAssets(
+	ctx context.Context,
 	startTime time.Time,
 	endTime time.Time,
-) map[string][]AssetData {
+) (map[string][]AssetData, error) {
 	timeQuery := time.Now()
 	var rows *sql.Rows
 	var err error
@@ -104,25 +215,46 @@ func QueryAllAssets(
 	startTimeStr := startTime.Format("2006-01-02 15:04:05.000000000")
 	endTimeStr := endTime.Format("2006-01-02 15:04:05.000000000")
 
-	rows, err = db.Query(query, startTimeStr, endTimeStr)
+	rows, err = s.db.QueryContext(ctx, query, startTimeStr, endTimeStr)
 	if err != nil {
-		log.Printf("Error querying data: %v", err)
+		return map[string][]AssetData{}, fmt.Errorf("query assets: %w", err)
 	}
 	defer rows.Close()
-	stocks := ReadStocks(rows)
-	log.Printf("Query time: %s\n", time.Since(timeQuery))
-	return stocks
+	stocks, err := ReadStocks(rows)
+	logger.Debug("query all assets", "elapsed", time.Since(timeQuery))
+	if err != nil {
+		return stocks, fmt.Errorf("query assets: %w", err)
+	}
+	return stocks, nil
 }
 
 // QueryAssetsForTickers fetches OHLCV data for a known set of tickers
-// in a single round-trip, bucketing rows by ticker via ReadStocks.
-func QueryAssetsForTickers(
+// in a single round-trip, bucketing rows by ticker via ReadStocks. It
+// logs a failed query and returns what was read; QueryAssets returns
+// the error instead.
+func (s *Store) QueryAssetsForTickers(
+	ctx context.Context,
 	tickers []string,
 	startTime time.Time,
 	endTime time.Time,
 ) map[string][]AssetData {
+	result, err := s.QueryAssets(ctx, tickers, startTime, endTime)
+	if err != nil {
+		logger.Error("query assets", "tickers", len(tickers), "err", err)
+	}
+	return result
+}
+
+// QueryAssets is QueryAssetsForTickers returning its error along with
+// whatever bars were read before it.
+func (s *Store) QueryAssets(
+	ctx context.Context,
+	tickers []string,
+	startTime time.Time,
+	endTime time.Time,
+) (map[string][]AssetData, error) {
 	if len(tickers) == 0 {
-		return map[string][]AssetData{}
+		return map[string][]AssetData{}, nil
 	}
 
 	placeholders := strings.Repeat("?,", len(tickers))
@@ -145,23 +277,25 @@ func QueryAssetsForTickers(
 	)
 
 	queryTime := time.Now()
-	rows, err := db.Query(query, args...)
+	// The IN list's length varies with the tickers, so this isn't
+	// prepared (see prepared).
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Printf("Error querying assets for %d tickers: %v", len(tickers), err)
-		return map[string][]AssetData{}
+		return map[string][]AssetData{}, err
 	}
 	defer rows.Close()
 
-	result := ReadStocks(rows)
-	log.Printf("Query time for %d tickers: %s\n", len(tickers), time.Since(queryTime))
-	return result
+	result, err := ReadStocks(rows)
+	logger.Debug("query assets", "tickers", len(tickers), "elapsed", time.Since(queryTime))
+	return result, err
 }
 
-func QueryAssetData(
+func (s *Store) QueryAssetData(
+	ctx context.Context,
 	ticker string,
 	startTime time.Time,
 	endTime time.Time,
-) []AssetData {
+) ([]AssetData, error) {
 	queryTime := time.Now()
 	query := `
 	SELECT Date, Ticker, Open, High, Low, Close, Volume FROM stock_data_optimized
@@ -173,46 +307,60 @@ func QueryAssetData(
 	startTimeStr := startTime.Format("2006-01-02 15:04:05.000000000")
 	endTimeStr := endTime.Format("2006-01-02 15:04:05.000000000")
 
-	rows, err := db.Query(query, ticker, startTimeStr, endTimeStr)
+	stmt, err := s.prepared(ctx, query)
 	if err != nil {
-		log.Printf("Error querying data for ticker %s: %v", ticker, err)
-		return nil
+		return nil, fmt.Errorf("prepare query for %s: %w", ticker, err)
+	}
+	rows, err := stmt.QueryContext(ctx, ticker, startTimeStr, endTimeStr)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", ticker, err)
 	}
 	defer rows.Close()
 
-	var dailyAssets []AssetData
-	for rows.Next() {
-		var assetData AssetData
-		var ticker string
-		err := rows.Scan(&assetData.Date, &ticker, &assetData.Open,
-			&assetData.High, &assetData.Low, &assetData.Close, &assetData.Volume)
-		if err != nil {
-			log.Printf("Failed to scan row for ticker %s: %v", ticker, err)
-			continue
-		}
-		dailyAssets = append(dailyAssets, assetData)
-	}
-	if err := rows.Err(); err != nil {
-		log.Printf("Error during rows iteration for ticker %s: %v", ticker, err)
+	stocks, err := ReadStocks(rows)
+	logger.Debug("query asset", "ticker", ticker, "elapsed", time.Since(queryTime))
+	if err != nil {
+		return stocks[ticker], fmt.Errorf("query %s: %w", ticker, err)
 	}
-	log.Printf("Query time for %s: %s\n", ticker, time.Since(queryTime))
-	return dailyAssets
+	return stocks[ticker], nil
 }
 
-func GetRiskFreeRates(
+// GetRiskFreeRates returns the daily risk-free rates between startTime
+// and endTime, keyed by the Unix seconds of their date. It logs a failed
+// query and returns what was read; QueryRiskFree returns the error
+// instead.
+func (s *Store) GetRiskFreeRates(
+	ctx context.Context,
 	startTime time.Time,
 	endTime time.Time,
 ) map[int64]float64 {
+	rates, err := s.QueryRiskFree(ctx, startTime, endTime)
+	if err != nil {
+		logger.Error("query risk-free rates", "err", err)
+	}
+	return rates
+}
+
+// QueryRiskFree is GetRiskFreeRates returning its error along with
+// whatever rates were read before it.
+func (s *Store) QueryRiskFree(
+	ctx context.Context,
+	startTime time.Time,
+	endTime time.Time,
+) (map[int64]float64, error) {
 	query := "SELECT daily_risk_free_rate_decimal, Date FROM " +
 		"\"3MTreasuryYields\" WHERE Date BETWEEN CAST(? AS " +
 		"TIMESTAMP_NS) AND CAST(? AS TIMESTAMP_NS) ORDER BY Date;"
 	startTimeStr := startTime.Format("2006-01-02 15:04:05.000000000")
 	endTimeStr := endTime.Format("2006-01-02 15:04:05.000000000")
 
-	rows, err := db.Query(query, startTimeStr, endTimeStr)
+	stmt, err := s.prepared(ctx, query)
 	if err != nil {
-		log.Printf("Error querying risk free rates: %v, returning empty map", err)
-		return make(map[int64]float64)
+		return make(map[int64]float64), fmt.Errorf("prepare query: %w", err)
+	}
+	rows, err := stmt.QueryContext(ctx, startTimeStr, endTimeStr)
+	if err != nil {
+		return make(map[int64]float64), err
 	}
 	defer rows.Close()
 	riskFreeRates := make(map[int64]float64)
@@ -220,51 +368,11 @@ func GetRiskFreeRates(
 		var rate sql.NullFloat64
 		var date time.Time
 		if err := rows.Scan(&rate, &date); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			continue
+			return riskFreeRates, fmt.Errorf("scan row: %w", err)
 		}
 		if rate.Valid {
 			riskFreeRates[date.Unix()] = rate.Float64
 		}
 	}
-	return riskFreeRates
-}
-
-func GetTickersWithSufficientData(
-	startTime time.Time,
-	endTime time.Time,
-) []string {
-	var rows *sql.Rows
-	var err error
-
-	// Calculate the minimum number of data points required
-	years := endTime.Sub(startTime).Hours() / 24 / 365
-	requiredDataPoints := int(years * 250)
-
-	query := `
-        SELECT Ticker
-        FROM stock_data_optimized
-        WHERE Date BETWEEN CAST(? AS TIMESTAMP_NS) AND CAST(? AS TIMESTAMP_NS)
-        GROUP BY Ticker
-        HAVING COUNT(*) >= ?
-    `
-	startTimeStr := startTime.Format("2006-01-02 15:04:05.000000000")
-	endTimeStr := endTime.Format("2006-01-02 15:04:05.000000000")
-
-	rows, err = db.Query(query, startTimeStr, endTimeStr, requiredDataPoints)
-	if err != nil {
-		log.Printf("Error querying data: %v", err)
-	}
-	defer rows.Close()
-
-	tickers := make([]string, 0)
-	for rows.Next() {
-		var ticker string
-		err := rows.Scan(&ticker)
-		if err != nil {
-			log.Printf("Error scanning row: %v", err)
-		}
-		tickers = append(tickers, ticker)
-	}
-	return tickers
+	return riskFreeRates, rows.Err()
 }
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Workers querying different universes at once each get their own bars,
+// and the per-shape IN lists leave no statements behind in the cache.
+// Run with -race to check the Store's connection and cache sharing.
+func TestQueryAssets_Concurrent(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Skipf("no DuckDB driver: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if _, err := s.DB().ExecContext(ctx, `
+		CREATE TABLE stock_data_optimized (
+			Date TIMESTAMP_NS, Ticker VARCHAR,
+			Open DOUBLE, High DOUBLE, Low DOUBLE, Close DOUBLE, Volume BIGINT
+		);`); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tickers []string
+	for i := 0; i < 8; i++ {
+		tk := fmt.Sprintf("T%d", i)
+		tickers = append(tickers, tk)
+		for d := 0; d < 5; d++ {
+			if _, err := s.DB().ExecContext(ctx,
+				`INSERT INTO stock_data_optimized VALUES (?, ?, ?, ?, ?, ?, ?);`,
+				start.AddDate(0, 0, d), tk, float64(i), float64(i), float64(i), float64(i), 100,
+			); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*len(tickers))
+	for w := 0; w < 4; w++ {
+		for n := 1; n <= len(tickers); n++ {
+			wg.Add(1)
+			go func(want []string) {
+				defer wg.Done()
+				got, err := s.QueryAssets(ctx, want, start, start.AddDate(0, 0, 4))
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(got) != len(want) {
+					errs <- fmt.Errorf("%d tickers: got %d", len(want), len(got))
+					return
+				}
+				for _, tk := range want {
+					if len(got[tk]) != 5 {
+						errs <- fmt.Errorf("%s: %d bars, want 5", tk, len(got[tk]))
+					}
+				}
+			}(tickers[:n])
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	s.stmtMu.Lock()
+	defer s.stmtMu.Unlock()
+	if len(s.stmts) != 0 {
+		t.Errorf("%d statements cached, want none for QueryAssets", len(s.stmts))
+	}
+}
@@ -0,0 +1,5 @@
+// Package data loads market data for the backtest engine: OHLCV bars and
+// risk-free rates from DuckDB through a Store, plus the Yahoo, Binance
+// and FRED/Quandl fetchers and the CSV and Parquet importers that
+// ingest into it.
+package data
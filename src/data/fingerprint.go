@@ -0,0 +1,71 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+	"sort"
+	"time"
+)
+
+// Fingerprint summarizes one input series — a ticker's bars or the
+// risk-free rates — so a later run can tell whether it still reads
+// exactly the same data.
+type Fingerprint struct {
+	Name     string `json:"name"`
+	First    string `json:"first"` // YYYY-MM-DD; "" when Rows is 0
+	Last     string `json:"last"`
+	Rows     int    `json:"rows"`
+	Checksum string `json:"checksum"` // hex SHA-256 over every row's exact values
+}
+
+func writeInt(h hash.Hash, v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	h.Write(b[:])
+}
+
+func writeFloat(h hash.Hash, v float64) {
+	writeInt(h, int64(math.Float64bits(v)))
+}
+
+// FingerprintBars fingerprints a date-ordered series by the date and
+// OHLCV of every bar. Derived fields (returns) are left out.
+func FingerprintBars(name string, bars []AssetData) Fingerprint {
+	h := sha256.New()
+	for _, b := range bars {
+		writeInt(h, b.Date.Unix())
+		for _, v := range []float64{b.Open, b.High, b.Low, b.Close, b.Volume} {
+			writeFloat(h, v)
+		}
+	}
+	fp := Fingerprint{Name: name, Rows: len(bars), Checksum: hex.EncodeToString(h.Sum(nil))}
+	if len(bars) > 0 {
+		fp.First = bars[0].Date.Format("2006-01-02")
+		fp.Last = bars[len(bars)-1].Date.Format("2006-01-02")
+	}
+	return fp
+}
+
+// FingerprintRates fingerprints a series keyed by Unix date, such as
+// GetRiskFreeRates' result, in date order.
+func FingerprintRates(name string, rates map[int64]float64) Fingerprint {
+	dates := make([]int64, 0, len(rates))
+	for d := range rates {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+	h := sha256.New()
+	for _, d := range dates {
+		writeInt(h, d)
+		writeFloat(h, rates[d])
+	}
+	fp := Fingerprint{Name: name, Rows: len(dates), Checksum: hex.EncodeToString(h.Sum(nil))}
+	if len(dates) > 0 {
+		fp.First = time.Unix(dates[0], 0).UTC().Format("2006-01-02")
+		fp.Last = time.Unix(dates[len(dates)-1], 0).UTC().Format("2006-01-02")
+	}
+	return fp
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFingerprintBars_DetectsValueChange(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	bars := []AssetData{
+		{Date: day(2), Open: 10, High: 11, Low: 9, Close: 10.5, Volume: 100},
+		{Date: day(3), Open: 10.5, High: 12, Low: 10, Close: 11.5, Volume: 120},
+	}
+	fp := FingerprintBars("A", bars)
+	if fp.Rows != 2 || fp.First != "2024-01-02" || fp.Last != "2024-01-03" {
+		t.Fatalf("fingerprint = %+v", fp)
+	}
+	if again := FingerprintBars("A", bars); again != fp {
+		t.Errorf("not stable: %+v vs %+v", again, fp)
+	}
+
+	bars[1].Return = 0.1 // derived fields are not part of the input
+	if got := FingerprintBars("A", bars); got != fp {
+		t.Errorf("Return changed checksum")
+	}
+	bars[1].Volume = 121
+	if got := FingerprintBars("A", bars); got.Checksum == fp.Checksum {
+		t.Errorf("Volume change kept checksum %s", got.Checksum)
+	}
+
+	empty := FingerprintBars("B", nil)
+	if empty.Rows != 0 || empty.First != "" || empty.Checksum == "" {
+		t.Errorf("empty = %+v", empty)
+	}
+}
+
+func TestFingerprintRates_OrderIndependent(t *testing.T) {
+	a := make(map[int64]float64)
+	b := make(map[int64]float64)
+	for i := int64(0); i < 50; i++ {
+		a[1704153600+i*86400] = float64(i) / 100
+	}
+	for i := int64(49); i >= 0; i-- {
+		b[1704153600+i*86400] = float64(i) / 100
+	}
+	fa, fb := FingerprintRates("risk_free", a), FingerprintRates("risk_free", b)
+	if fa != fb {
+		t.Errorf("%+v != %+v", fa, fb)
+	}
+	if fa.First != "2024-01-02" || fa.Rows != 50 {
+		t.Errorf("fingerprint = %+v", fa)
+	}
+	b[1704153600] = 0.0001
+	if FingerprintRates("risk_free", b).Checksum == fa.Checksum {
+		t.Error("rate change kept checksum")
+	}
+}
@@ -0,0 +1,310 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns names the file columns holding each bar field. Names match
+// case-insensitively; an empty name means the field's own name (Date,
+// Ticker, Open, ...).
+type Columns struct {
+	Date, Ticker, Open, High, Low, Close, Volume string
+}
+
+// ImportOptions map an OHLCV file onto stock_data_optimized.
+type ImportOptions struct {
+	Columns Columns
+	// Ticker is every row's ticker when the file has no ticker column
+	// (one file per ticker); it overrides the column when both exist.
+	Ticker string
+	// DateFormat is the Go layout of CSV dates; default 2006-01-02, with
+	// "2006-01-02 15:04:05" and RFC 3339 also accepted.
+	DateFormat string
+	// Interval, when non-zero, loads the bars into intraday_bars as bars
+	// of that length (see ParseInterval) instead of the daily table.
+	Interval time.Duration
+}
+
+// ParseColumns parses a "field=column,..." mapping such as
+// "date=Datetime,close=Adj Close".
+func ParseColumns(s string) (Columns, error) {
+	var c Columns
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, col, ok := strings.Cut(pair, "=")
+		col = strings.TrimSpace(col)
+		if !ok || col == "" {
+			return c, fmt.Errorf("column mapping %q: want field=column", pair)
+		}
+		dst := c.field(strings.TrimSpace(field))
+		if dst == nil {
+			return c, fmt.Errorf("column mapping %q: unknown field %q", pair, field)
+		}
+		*dst = col
+	}
+	return c, nil
+}
+
+func (c *Columns) field(name string) *string {
+	switch strings.ToLower(name) {
+	case "date":
+		return &c.Date
+	case "ticker":
+		return &c.Ticker
+	case "open":
+		return &c.Open
+	case "high":
+		return &c.High
+	case "low":
+		return &c.Low
+	case "close":
+		return &c.Close
+	case "volume":
+		return &c.Volume
+	}
+	return nil
+}
+
+// barFields are the fields of a bar in import order, Ticker last since
+// it is optional.
+var barFields = []string{"Date", "Open", "High", "Low", "Close", "Volume", "Ticker"}
+
+// resolve returns each of barFields' column index in header, -1 for a
+// missing Ticker (which needs opts.Ticker instead).
+func (o ImportOptions) resolve(header []string) ([]int, error) {
+	idx := make([]int, len(barFields))
+	for i, f := range barFields {
+		name := f
+		if col := *o.Columns.field(f); col != "" {
+			name = col
+		}
+		idx[i] = -1
+		for j, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				idx[i] = j
+				break
+			}
+		}
+		if idx[i] >= 0 {
+			continue
+		}
+		if f != "Ticker" {
+			return nil, fmt.Errorf("no %s column %q in %v", strings.ToLower(f), name, header)
+		}
+		if o.Ticker == "" {
+			return nil, fmt.Errorf("no ticker column %q and no ticker given", name)
+		}
+	}
+	return idx, nil
+}
+
+// ReadBarsCSV parses a headed OHLCV CSV into validated (see ValidateBars),
+// date-ordered bars by ticker with returns filled.
+func ReadBarsCSV(r io.Reader, opts ImportOptions) (map[string][]AssetData, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	idx, err := opts.resolve(header)
+	if err != nil {
+		return nil, err
+	}
+	layouts := []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339}
+	if opts.DateFormat != "" {
+		layouts = []string{opts.DateFormat}
+	}
+	out := map[string][]AssetData{}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var b AssetData
+		b.Date, err = parseDate(rec[idx[0]], layouts)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: date: %w", line, err)
+		}
+		for i, v := range []*float64{&b.Open, &b.High, &b.Low, &b.Close, &b.Volume} {
+			s := strings.TrimSpace(rec[idx[i+1]])
+			if *v, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("line %d: %s %q: not a number", line, strings.ToLower(barFields[i+1]), s)
+			}
+		}
+		ticker := opts.Ticker
+		if ticker == "" {
+			ticker = strings.TrimSpace(rec[idx[6]])
+		}
+		if ticker == "" {
+			return nil, fmt.Errorf("line %d: empty ticker", line)
+		}
+		out[ticker] = append(out[ticker], b)
+	}
+	return out, finishImport(out)
+}
+
+func parseDate(s string, layouts []string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	var err error
+	for _, l := range layouts {
+		var t time.Time
+		if t, err = time.Parse(l, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// finishImport date-orders and validates each ticker's bars and fills
+// their returns.
+func finishImport(byTicker map[string][]AssetData) error {
+	for ticker, bars := range byTicker {
+		sort.SliceStable(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+		if err := ValidateBars(bars); err != nil {
+			return fmt.Errorf("%s: %w", ticker, err)
+		}
+		FillReturns(bars)
+	}
+	return nil
+}
+
+// ValidateBars checks a ticker's date-ordered bars: one bar per date,
+// positive prices with High and Low bracketing Open and Close, and a
+// non-negative volume.
+func ValidateBars(bars []AssetData) error {
+	for i, b := range bars {
+		date := b.Date.Format("2006-01-02")
+		if i > 0 && b.Date.Equal(bars[i-1].Date) {
+			return fmt.Errorf("%s: duplicate date", date)
+		}
+		if !(b.Open > 0 && b.High > 0 && b.Low > 0 && b.Close > 0) {
+			return fmt.Errorf("%s: prices must be positive", date)
+		}
+		if b.High < b.Low || b.High < b.Open || b.High < b.Close || b.Low > b.Open || b.Low > b.Close {
+			return fmt.Errorf("%s: high %v and low %v don't bracket open %v and close %v",
+				date, b.High, b.Low, b.Open, b.Close)
+		}
+		if !(b.Volume >= 0) {
+			return fmt.Errorf("%s: negative volume", date)
+		}
+	}
+	return nil
+}
+
+// ImportCSV validates the OHLCV CSV at path and upserts its bars into
+// stock_data_optimized, returning the number of bars loaded. Nothing is
+// loaded if any row fails validation.
+func (s *Store) ImportCSV(ctx context.Context, path string, opts ImportOptions) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	byTicker, err := ReadBarsCSV(f, opts)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return s.insertImport(ctx, byTicker, opts.Interval)
+}
+
+// ImportParquet is ImportCSV for a Parquet file, read by DuckDB's
+// read_parquet. The date column may be a DATE, a TIMESTAMP or a string
+// DuckDB can cast to one; opts.DateFormat is ignored.
+func (s *Store) ImportParquet(ctx context.Context, path string, opts ImportOptions) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT * FROM read_parquet(?) LIMIT 0;`, path)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	header, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	idx, err := opts.resolve(header)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	cols := make([]string, len(idx))
+	for i, j := range idx {
+		switch {
+		case i == 0:
+			cols[i] = fmt.Sprintf("CAST(%s AS TIMESTAMP)", quoteIdent(header[j]))
+		case j < 0 || opts.Ticker != "" && barFields[i] == "Ticker":
+			cols[i] = "CAST(? AS VARCHAR)"
+		case barFields[i] == "Ticker":
+			cols[i] = fmt.Sprintf("CAST(%s AS VARCHAR)", quoteIdent(header[j]))
+		default:
+			cols[i] = fmt.Sprintf("CAST(%s AS DOUBLE)", quoteIdent(header[j]))
+		}
+	}
+	args := []any{path}
+	if strings.Contains(cols[6], "?") {
+		args = []any{opts.Ticker, path}
+	}
+	rows, err = s.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT %s FROM read_parquet(?);`, strings.Join(cols, ", ")), args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	defer rows.Close()
+	byTicker := map[string][]AssetData{}
+	for rows.Next() {
+		var b AssetData
+		var ticker string
+		if err := rows.Scan(&b.Date, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume, &ticker); err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		if ticker == "" {
+			return 0, fmt.Errorf("%s: %s: empty ticker", path, b.Date.Format("2006-01-02"))
+		}
+		byTicker[ticker] = append(byTicker[ticker], b)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := finishImport(byTicker); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return s.insertImport(ctx, byTicker, opts.Interval)
+}
+
+func (s *Store) insertImport(ctx context.Context, byTicker map[string][]AssetData, interval time.Duration) (int, error) {
+	tickers := make([]string, 0, len(byTicker))
+	for t := range byTicker {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	insert := s.InsertBars
+	if interval > 0 {
+		insert = func(ctx context.Context, t string, bars []AssetData) error {
+			return s.InsertIntradayBars(ctx, t, interval, bars)
+		}
+	}
+	n := 0
+	for _, t := range tickers {
+		if err := insert(ctx, t, byTicker[t]); err != nil {
+			return n, err
+		}
+		n += len(byTicker[t])
+	}
+	return n, nil
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
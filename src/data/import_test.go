@@ -0,0 +1,56 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadBarsCSV(t *testing.T) {
+	cols, err := ParseColumns("date=Day, close=Adj Close, ticker=Symbol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := "Symbol,Day,Open,High,Low,Close,Adj Close,Volume\n" +
+		"MSFT,01/03/2024,10,11,9,10,10.5,100\n" +
+		"AAPL,01/02/2024,20,21,19,20,20,50\n" +
+		"MSFT,01/02/2024,10,11,9,10,10,200\n"
+	got, err := ReadBarsCSV(strings.NewReader(in), ImportOptions{Columns: cols, DateFormat: "01/02/2006"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msft := got["MSFT"]
+	if len(got) != 2 || len(got["AAPL"]) != 1 || len(msft) != 2 {
+		t.Fatalf("got %+v", got)
+	}
+	if !msft[0].Date.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) || msft[1].Close != 10.5 || msft[1].Return != 0.05 {
+		t.Errorf("MSFT = %+v, want date-ordered bars closing at the adjusted close", msft)
+	}
+
+	// One file per ticker.
+	got, err = ReadBarsCSV(strings.NewReader("date,open,high,low,close,volume\n2024-01-02,1,1,1,1,0\n"),
+		ImportOptions{Ticker: "SPY"})
+	if err != nil || len(got["SPY"]) != 1 {
+		t.Errorf("got %+v, %v; want one SPY bar", got, err)
+	}
+}
+
+func TestReadBarsCSV_Rejects(t *testing.T) {
+	const header = "date,ticker,open,high,low,close,volume\n"
+	for name, in := range map[string]string{
+		"missing column": "date,ticker,open,high,low,close\n2024-01-02,A,1,1,1,1\n",
+		"bad number":     header + "2024-01-02,A,1,1,1,x,0\n",
+		"bad date":       header + "2024-13-02,A,1,1,1,1,0\n",
+		"duplicate date": header + "2024-01-02,A,1,1,1,1,0\n2024-01-02,A,1,1,1,1,0\n",
+		"zero price":     header + "2024-01-02,A,0,1,1,1,0\n",
+		"high below":     header + "2024-01-02,A,1,1,1,2,0\n",
+		"empty ticker":   header + "2024-01-02,,1,1,1,1,0\n",
+	} {
+		if _, err := ReadBarsCSV(strings.NewReader(in), ImportOptions{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := ParseColumns("price=Close"); err == nil {
+		t.Error("unknown field: expected error")
+	}
+}
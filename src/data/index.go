@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Index membership is stored as spells: a ticker joined IndexName on
+// Added and left it on Removed, NULL while it is still a member. A
+// ticker that left and rejoined has a row for each spell.
+const indexMembersTableDDL = `
+	CREATE TABLE IF NOT EXISTS index_members (
+		IndexName VARCHAR,
+		Ticker    VARCHAR,
+		Added     TIMESTAMP,
+		Removed   TIMESTAMP
+	);
+`
+
+// IndexMembership is one spell of a ticker in an index: a member from
+// Added up to, but not including, Removed, or to date when Removed is
+// zero.
+type IndexMembership struct {
+	Ticker         string
+	Added, Removed time.Time
+}
+
+// On reports whether m was a member on d.
+func (m IndexMembership) On(d time.Time) bool {
+	return !d.Before(m.Added) && (m.Removed.IsZero() || d.Before(m.Removed))
+}
+
+// MembersOn returns, sorted, the tickers in ms that were members on d.
+func MembersOn(ms []IndexMembership, d time.Time) []string {
+	seen := make(map[string]bool)
+	var tickers []string
+	for _, m := range ms {
+		if m.On(d) && !seen[m.Ticker] {
+			seen[m.Ticker] = true
+			tickers = append(tickers, m.Ticker)
+		}
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+// ReadIndexMembersCSV parses index,ticker,added,removed rows, with an
+// optional header, into each index's spells; removed is blank for a
+// current member.
+func ReadIndexMembersCSV(r io.Reader) (map[string][]IndexMembership, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+	out := map[string][]IndexMembership{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(rec[0], "index") {
+			continue
+		}
+		if rec[0] == "" || rec[1] == "" {
+			return nil, fmt.Errorf("line %d: empty index or ticker", line)
+		}
+		m := IndexMembership{Ticker: rec[1]}
+		if m.Added, err = time.Parse("2006-01-02", rec[2]); err != nil {
+			return nil, fmt.Errorf("line %d: added: %w", line, err)
+		}
+		if rec[3] != "" {
+			if m.Removed, err = time.Parse("2006-01-02", rec[3]); err != nil {
+				return nil, fmt.Errorf("line %d: removed: %w", line, err)
+			}
+			if !m.Removed.After(m.Added) {
+				return nil, fmt.Errorf("line %d: removed %s isn't after added %s", line, rec[3], rec[2])
+			}
+		}
+		out[rec[0]] = append(out[rec[0]], m)
+	}
+	return out, nil
+}
+
+// SetIndexMembers replaces index's membership history in index_members
+// with ms.
+func (s *Store) SetIndexMembers(ctx context.Context, index string, ms []IndexMembership) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, indexMembersTableDDL); err != nil {
+		return fmt.Errorf("create index_members: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM index_members WHERE IndexName = ?;`, index); err != nil {
+		return fmt.Errorf("clear %s members: %w", index, err)
+	}
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO index_members (IndexName, Ticker, Added, Removed) VALUES (?, ?, ?, ?);`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, m := range ms {
+		var removed any
+		if !m.Removed.IsZero() {
+			removed = m.Removed
+		}
+		if _, err := stmt.ExecContext(ctx, index, m.Ticker, m.Added, removed); err != nil {
+			return fmt.Errorf("insert %s member %s: %w", index, m.Ticker, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// IndexMembers returns index's spells that overlap [start, end], by
+// ticker and date added; none if the index, or index_members, isn't
+// loaded. A zero end leaves the window open.
+func (s *Store) IndexMembers(ctx context.Context, index string, start, end time.Time) ([]IndexMembership, error) {
+	if end.IsZero() {
+		end = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+	if _, err := s.db.ExecContext(ctx, indexMembersTableDDL); err != nil {
+		return nil, fmt.Errorf("create index_members: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT Ticker, Added, Removed
+		FROM index_members
+		WHERE IndexName = ?
+		  AND Added <= CAST(? AS TIMESTAMP_NS)
+		  AND (Removed IS NULL OR Removed > CAST(? AS TIMESTAMP_NS))
+		ORDER BY Ticker, Added;
+	`, index,
+		end.Format("2006-01-02 15:04:05.000000000"),
+		start.Format("2006-01-02 15:04:05.000000000"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query %s members: %w", index, err)
+	}
+	defer rows.Close()
+	var ms []IndexMembership
+	for rows.Next() {
+		var m IndexMembership
+		var removed sql.NullTime
+		if err := rows.Scan(&m.Ticker, &m.Added, &removed); err != nil {
+			return ms, fmt.Errorf("scan row: %w", err)
+		}
+		if removed.Valid {
+			m.Removed = removed.Time
+		}
+		ms = append(ms, m)
+	}
+	return ms, rows.Err()
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadIndexMembersCSV(t *testing.T) {
+	got, err := ReadIndexMembersCSV(strings.NewReader(
+		"index,ticker,added,removed\n" +
+			"SP500,AAPL,1982-11-30,\n" +
+			"SP500,LEH,1994-05-31,2008-09-16\n" +
+			"NDX,AAPL,1985-01-31,\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got["SP500"]) != 2 || len(got["NDX"]) != 1 {
+		t.Fatalf("got %v", got)
+	}
+	leh := got["SP500"][1]
+	if leh.Ticker != "LEH" || !leh.Removed.Equal(time.Date(2008, 9, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("LEH = %+v", leh)
+	}
+	if !got["SP500"][0].Removed.IsZero() {
+		t.Errorf("AAPL's blank removed date parsed as %s", got["SP500"][0].Removed)
+	}
+	for _, bad := range []string{
+		"SP500,,2020-01-01,\n",
+		"SP500,X,2020-01-01,2019-01-01\n",
+		"SP500,X,01/01/2020,\n",
+	} {
+		if _, err := ReadIndexMembersCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestMembersOn(t *testing.T) {
+	day := func(m, d int) time.Time { return time.Date(2024, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	spells := []IndexMembership{
+		{Ticker: "B", Added: day(1, 1), Removed: day(3, 1)},
+		{Ticker: "A", Added: day(1, 1)},
+		{Ticker: "B", Added: day(6, 1)},
+		{Ticker: "C", Added: day(3, 1)},
+	}
+	for _, tc := range []struct {
+		on   time.Time
+		want []string
+	}{
+		{day(2, 29), []string{"A", "B"}},
+		{day(3, 1), []string{"A", "C"}},
+		{day(6, 1), []string{"A", "B", "C"}},
+		{day(12, 31).AddDate(-1, 0, 0), nil},
+	} {
+		if got := MembersOn(spells, tc.on); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("on %s: got %v, want %v", tc.on.Format("2006-01-02"), got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"fmt"
+)
+
+// InsertBars upserts a ticker's bars into stock_data_optimized: rows for
+// the ticker inside [first bar, last bar] are replaced in one
+// transaction, so re-ingesting an overlapping window never duplicates
+// dates. bars must be date-ordered.
+func (s *Store) InsertBars(ctx context.Context, ticker string, bars []AssetData) error {
+	if len(bars) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM stock_data_optimized
+		WHERE Ticker = ? AND Date BETWEEN ? AND ?;
+	`, ticker, bars[0].Date, bars[len(bars)-1].Date); err != nil {
+		return fmt.Errorf("clear %s: %w", ticker, err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO stock_data_optimized
+			(Date, Ticker, Open, High, Low, Close, Volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, b := range bars {
+		if _, err := stmt.ExecContext(ctx,
+			b.Date, ticker, b.Open, b.High, b.Low, b.Close, b.Volume,
+		); err != nil {
+			return fmt.Errorf("insert %s %s: %w",
+				ticker, b.Date.Format("2006-01-02"), err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Intraday bars live apart from the daily ones, keyed by their interval
+// in seconds so one ticker can hold, say, both 1m and 1h series. A bar's
+// Date is the time its interval opens.
+const intradayTableDDL = `
+	CREATE TABLE IF NOT EXISTS intraday_bars (
+		Ticker     VARCHAR,
+		BarSeconds BIGINT,
+		Date       TIMESTAMP,
+		Open       DOUBLE,
+		High       DOUBLE,
+		Low        DOUBLE,
+		Close      DOUBLE,
+		Volume     DOUBLE
+	);
+`
+
+// ParseInterval parses a bar interval such as "1m", "5m", "15m" or "1h"
+// (any Go duration of a whole number of seconds under a day). "", "1d"
+// and "daily" are the daily bars of stock_data_optimized, returned as 0.
+func ParseInterval(s string) (time.Duration, error) {
+	switch strings.ToLower(s) {
+	case "", "1d", "daily":
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Second || d >= 24*time.Hour || d%time.Second != 0 {
+		return 0, fmt.Errorf("bar interval %q: must be a whole number of seconds under a day, e.g. 5m or 1h, or 1d", s)
+	}
+	return d, nil
+}
+
+// InsertIntradayBars is InsertBars for a ticker's bars of interval into
+// intraday_bars, creating the table on first use.
+func (s *Store) InsertIntradayBars(ctx context.Context, ticker string, interval time.Duration, bars []AssetData) error {
+	if len(bars) == 0 {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, intradayTableDDL); err != nil {
+		return fmt.Errorf("create intraday_bars: %w", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	secs := int64(interval / time.Second)
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM intraday_bars
+		WHERE Ticker = ? AND BarSeconds = ? AND Date BETWEEN ? AND ?;
+	`, ticker, secs, bars[0].Date, bars[len(bars)-1].Date); err != nil {
+		return fmt.Errorf("clear %s %s: %w", ticker, interval, err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO intraday_bars
+			(Ticker, BarSeconds, Date, Open, High, Low, Close, Volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, b := range bars {
+		if _, err := stmt.ExecContext(ctx,
+			ticker, secs, b.Date, b.Open, b.High, b.Low, b.Close, b.Volume,
+		); err != nil {
+			return fmt.Errorf("insert %s %s: %w",
+				ticker, b.Date.Format("2006-01-02 15:04:05"), err)
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryIntraday is QueryAssets for the tickers' bars of interval dated
+// within [startTime, endTime]. A database without intraday_bars has no
+// intraday bars rather than an error.
+func (s *Store) QueryIntraday(
+	ctx context.Context,
+	tickers []string,
+	interval time.Duration,
+	startTime time.Time,
+	endTime time.Time,
+) (map[string][]AssetData, error) {
+	if len(tickers) == 0 {
+		return map[string][]AssetData{}, nil
+	}
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_name = 'intraday_bars';`,
+	).Scan(&exists); err != nil {
+		return map[string][]AssetData{}, fmt.Errorf("query intraday bars: %w", err)
+	}
+	if !exists {
+		return map[string][]AssetData{}, nil
+	}
+
+	placeholders := strings.Repeat("?,", len(tickers))
+	placeholders = placeholders[:len(placeholders)-1]
+	query := fmt.Sprintf(`
+		SELECT Date, Ticker, Open, High, Low, Close, Volume
+		FROM intraday_bars
+		WHERE Ticker IN (%s)
+		  AND BarSeconds = ?
+		  AND Date BETWEEN CAST(? AS TIMESTAMP_NS) AND CAST(? AS TIMESTAMP_NS)
+		ORDER BY Ticker, Date;
+	`, placeholders)
+
+	args := make([]any, 0, len(tickers)+3)
+	for _, t := range tickers {
+		args = append(args, t)
+	}
+	args = append(args,
+		int64(interval/time.Second),
+		startTime.Format("2006-01-02 15:04:05.000000000"),
+		endTime.Format("2006-01-02 15:04:05.000000000"),
+	)
+
+	queryTime := time.Now()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return map[string][]AssetData{}, fmt.Errorf("query intraday bars: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := ReadStocks(rows)
+	logger.Debug("query intraday bars", "tickers", len(tickers), "interval", interval, "elapsed", time.Since(queryTime))
+	return result, err
+}
@@ -0,0 +1,26 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"":      0,
+		"1d":    0,
+		"daily": 0,
+		"1m":    time.Minute,
+		"15m":   15 * time.Minute,
+		"1h":    time.Hour,
+	} {
+		if got, err := ParseInterval(in); err != nil || got != want {
+			t.Errorf("ParseInterval(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"24h", "-5m", "500ms", "5", "hourly"} {
+		if _, err := ParseInterval(in); err == nil {
+			t.Errorf("ParseInterval(%q) accepted", in)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Macro series (yields, CPI, VIX, ...) live in one long table keyed by
+// series name, so adding a series never needs a schema change. Published
+// is when an observation became known; tables from before it was added
+// gain it empty, and their rows count as published on their Date.
+const macroTableDDL = `
+	CREATE TABLE IF NOT EXISTS macro_series (
+		Series    VARCHAR,
+		Date      TIMESTAMP,
+		Value     DOUBLE,
+		Published TIMESTAMP
+	);
+`
+
+// macroPublishedDDL adds Published to a macro_series from before it.
+const macroPublishedDDL = `ALTER TABLE macro_series ADD COLUMN IF NOT EXISTS Published TIMESTAMP;`
+
+// createMacroTable creates macro_series, or brings an older one up to
+// date, through db or a transaction.
+func createMacroTable(ctx context.Context, db interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}) error {
+	for _, ddl := range []string{macroTableDDL, macroPublishedDDL} {
+		if _, err := db.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("create macro_series: %w", err)
+		}
+	}
+	return nil
+}
+
+// FREDURL and QuandlURL are the provider endpoints; tests point them at
+// local servers. API keys come from $FRED_API_KEY and $QUANDL_API_KEY.
+var (
+	FREDURL   = "https://api.stlouisfed.org"
+	QuandlURL = "https://data.nasdaq.com"
+)
+
+var macroClient = &http.Client{Timeout: 30 * time.Second}
+
+// MacroPoint is one observation of a macro series: the value for the
+// period dated Date, first published at Published. A zero Published is
+// taken to be Date.
+type MacroPoint struct {
+	Date      time.Time
+	Value     float64
+	Published time.Time
+}
+
+// published is when pt became known.
+func (pt MacroPoint) published() time.Time {
+	if pt.Published.Before(pt.Date) {
+		return pt.Date
+	}
+	return pt.Published
+}
+
+// ParseMacroSpec splits "provider:code[@lag]" (e.g. "fred:DGS10",
+// "quandl:FRED/GDP@30") into its parts. The code alone is the series
+// name stored in macro_series and used by strategies. lag is the number
+// of days after its date an observation is taken to be published at the
+// earliest, for sources that don't say (see IngestMacro).
+func ParseMacroSpec(spec string) (provider, code string, lag int, err error) {
+	provider, code, ok := strings.Cut(spec, ":")
+	if !ok || code == "" {
+		return "", "", 0, fmt.Errorf("macro series %q: want provider:code", spec)
+	}
+	provider = strings.ToLower(provider)
+	if provider != "fred" && provider != "quandl" {
+		return "", "", 0, fmt.Errorf("macro series %q: unknown provider %q", spec, provider)
+	}
+	if c, l, ok := strings.Cut(code, "@"); ok {
+		if lag, err = strconv.Atoi(l); err != nil || lag < 0 || c == "" {
+			return "", "", 0, fmt.Errorf("macro series %q: lag %q must be a whole number of days", spec, l)
+		}
+		code = c
+	}
+	return provider, code, lag, nil
+}
+
+// IngestMacro downloads the series named by spec between start and end
+// and upserts it into macro_series. FRED observations are dated by
+// ALFRED as first released (see FetchFRED); Quandl's carry no release
+// date and count as published on their own date. The spec's lag, if
+// any, holds either back further.
+func (s *Store) IngestMacro(ctx context.Context, spec string, start, end time.Time) (int, error) {
+	provider, code, lag, err := ParseMacroSpec(spec)
+	if err != nil {
+		return 0, err
+	}
+	var points []MacroPoint
+	switch provider {
+	case "fred":
+		points, err = FetchFRED(ctx, code, start, end)
+	case "quandl":
+		points, err = FetchQuandl(ctx, code, start, end)
+	}
+	if err != nil {
+		return 0, err
+	}
+	for i, pt := range points {
+		if earliest := pt.Date.AddDate(0, 0, lag); pt.published().Before(earliest) {
+			points[i].Published = earliest
+		}
+	}
+	if err := s.InsertMacro(ctx, code, points); err != nil {
+		return 0, err
+	}
+	return len(points), nil
+}
+
+// FetchFRED downloads observations of a FRED series as first released,
+// from its ALFRED vintages: each point's value is the one published at
+// the start of its real-time period, which is its Published date, so a
+// month's CPI is dated weeks after the month, and later revisions are
+// ignored. FRED marks missing observations (e.g. market holidays) with
+// "."; those are dropped.
+func FetchFRED(ctx context.Context, series string, start, end time.Time) ([]MacroPoint, error) {
+	q := url.Values{}
+	q.Set("series_id", series)
+	q.Set("api_key", os.Getenv("FRED_API_KEY"))
+	q.Set("file_type", "json")
+	q.Set("observation_start", start.Format("2006-01-02"))
+	q.Set("observation_end", end.Format("2006-01-02"))
+	q.Set("realtime_start", "1776-07-04")
+	q.Set("realtime_end", "9999-12-31")
+	q.Set("output_type", "4") // initial release only
+	var raw struct {
+		Observations []struct {
+			RealtimeStart string `json:"realtime_start"`
+			Date          string `json:"date"`
+			Value         string `json:"value"`
+		} `json:"observations"`
+	}
+	if err := macroGet(ctx, FREDURL+"/fred/series/observations?"+q.Encode(), &raw); err != nil {
+		return nil, fmt.Errorf("fred %s: %w", series, err)
+	}
+	points := make([]MacroPoint, 0, len(raw.Observations))
+	for _, o := range raw.Observations {
+		if o.Value == "." {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", o.Date)
+		if err != nil {
+			return nil, fmt.Errorf("fred %s: %w", series, err)
+		}
+		v, err := strconv.ParseFloat(o.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("fred %s %s: %w", series, o.Date, err)
+		}
+		pt := MacroPoint{Date: d, Value: v}
+		if o.RealtimeStart != "" {
+			if pt.Published, err = time.Parse("2006-01-02", o.RealtimeStart); err != nil {
+				return nil, fmt.Errorf("fred %s %s: %w", series, o.Date, err)
+			}
+		}
+		points = append(points, pt)
+	}
+	return points, nil
+}
+
+// FetchQuandl downloads a Quandl (Nasdaq Data Link) dataset, keeping its
+// first value column. Rows arrive newest first and are returned
+// date-ordered.
+func FetchQuandl(ctx context.Context, code string, start, end time.Time) ([]MacroPoint, error) {
+	q := url.Values{}
+	q.Set("api_key", os.Getenv("QUANDL_API_KEY"))
+	q.Set("start_date", start.Format("2006-01-02"))
+	q.Set("end_date", end.Format("2006-01-02"))
+	var raw struct {
+		DatasetData struct {
+			Data [][]any `json:"data"`
+		} `json:"dataset_data"`
+	}
+	u := QuandlURL + "/api/v3/datasets/" + code + "/data.json?" + q.Encode()
+	if err := macroGet(ctx, u, &raw); err != nil {
+		return nil, fmt.Errorf("quandl %s: %w", code, err)
+	}
+	points := make([]MacroPoint, 0, len(raw.DatasetData.Data))
+	for _, row := range raw.DatasetData.Data {
+		if len(row) < 2 {
+			continue
+		}
+		ds, _ := row[0].(string)
+		v, ok := row[1].(float64)
+		if !ok {
+			continue // null value
+		}
+		d, err := time.Parse("2006-01-02", ds)
+		if err != nil {
+			return nil, fmt.Errorf("quandl %s: %w", code, err)
+		}
+		points = append(points, MacroPoint{Date: d, Value: v})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Date.Before(points[j].Date)
+	})
+	return points, nil
+}
+
+func macroGet(ctx context.Context, u string, out any) error {
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err == nil {
+		resp, err = macroClient.Do(req)
+	}
+	if err != nil {
+		// URL and client errors quote the URL, which carries api_key.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return ue.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// InsertMacro upserts points for series into macro_series, replacing any
+// rows in the same date range. points must be date-ordered.
+func (s *Store) InsertMacro(ctx context.Context, series string, points []MacroPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := createMacroTable(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM macro_series
+		WHERE Series = ? AND Date BETWEEN ? AND ?;
+	`, series, points[0].Date, points[len(points)-1].Date); err != nil {
+		return fmt.Errorf("clear %s: %w", series, err)
+	}
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO macro_series (Series, Date, Value, Published) VALUES (?, ?, ?, ?);`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, pt := range points {
+		if _, err := stmt.ExecContext(ctx, series, pt.Date, pt.Value, pt.published()); err != nil {
+			return fmt.Errorf("insert %s %s: %w",
+				series, pt.Date.Format("2006-01-02"), err)
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryMacro returns series' observations dated up to and including
+// end, date-ordered, with when each was published. Earlier history is
+// included so as-of lookups at the start of a backtest still find the
+// latest prior value.
+func (s *Store) QueryMacro(ctx context.Context, series string, end time.Time) []MacroPoint {
+	// Adds Published to a table from before it, which the query reads.
+	if err := createMacroTable(ctx, s.db); err != nil {
+		logger.Error("query macro series", "series", series, "err", err)
+		return nil
+	}
+	stmt, err := s.prepared(ctx, `
+		SELECT Date, Value, COALESCE(Published, Date) FROM macro_series
+		WHERE Series = ? AND Date <= CAST(? AS TIMESTAMP_NS)
+		ORDER BY Date;
+	`)
+	if err != nil {
+		logger.Error("prepare macro query", "series", series, "err", err)
+		return nil
+	}
+	rows, err := stmt.QueryContext(ctx, series, end.Format("2006-01-02 15:04:05.000000000"))
+	if err != nil {
+		logger.Error("query macro series", "series", series, "err", err)
+		return nil
+	}
+	defer rows.Close()
+	var points []MacroPoint
+	for rows.Next() {
+		var pt MacroPoint
+		if err := rows.Scan(&pt.Date, &pt.Value, &pt.Published); err != nil {
+			logger.Error("scan macro row", "series", series, "err", err)
+			continue
+		}
+		points = append(points, pt)
+	}
+	return points
+}
+
+// MacroAsOf returns the value of the latest observation in points that
+// had been published by date, so a regime filter only sees data known
+// at that bar: on 10 February, January's CPI, released mid-February,
+// isn't, and December's is the latest. points must be date-ordered.
+func MacroAsOf(points []MacroPoint, date time.Time) (float64, bool) {
+	i := sort.Search(len(points), func(i int) bool {
+		return points[i].Date.After(date)
+	})
+	// Nothing is published before its date, so only points[:i] can
+	// count, and the latest of those usually does.
+	for i--; i >= 0; i-- {
+		if !points[i].published().After(date) {
+			return points[i].Value, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchFRED_DropsMissing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("series_id") != "VIXCLS" || q.Get("output_type") != "4" || q.Get("realtime_start") == "" {
+			t.Errorf("query = %v, want VIXCLS's initial releases", q)
+		}
+		w.Write([]byte(`{"observations":[
+			{"realtime_start":"2024-01-02","date":"2024-01-01","value":"."},
+			{"realtime_start":"2024-01-03","date":"2024-01-02","value":"13.2"},
+			{"realtime_start":"2024-01-04","date":"2024-01-03","value":"14.04"}]}`))
+	}))
+	defer srv.Close()
+	old := FREDURL
+	FREDURL = srv.URL
+	defer func() { FREDURL = old }()
+
+	pts, err := FetchFRED(context.Background(), "VIXCLS", time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 2 || pts[0].Value != 13.2 || pts[1].Value != 14.04 {
+		t.Errorf("points = %+v", pts)
+	}
+	if want := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC); !pts[0].Published.Equal(want) {
+		t.Errorf("published %v, want the release date %v", pts[0].Published, want)
+	}
+}
+
+func TestFetchQuandl_Ordered(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"dataset_data":{"data":[
+			["2024-01-03",2.0],["2024-01-02",null],["2024-01-01",1.0]]}}`))
+	}))
+	defer srv.Close()
+	old := QuandlURL
+	QuandlURL = srv.URL
+	defer func() { QuandlURL = old }()
+
+	pts, err := FetchQuandl(context.Background(), "FRED/GDP", time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 2 || pts[0].Value != 1 || pts[1].Value != 2 {
+		t.Errorf("points = %+v", pts)
+	}
+}
+
+func TestFetchFRED_Cancelled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	old := FREDURL
+	FREDURL = srv.URL
+	defer func() { FREDURL = old }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := FetchFRED(ctx, "VIXCLS", time.Time{}, time.Now()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestMacroAsOf(t *testing.T) {
+	d := func(day int) time.Time { return time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC) }
+	pts := []MacroPoint{{Date: d(2), Value: 1}, {Date: d(5), Value: 2}}
+	for _, c := range []struct {
+		day  int
+		want float64
+		ok   bool
+	}{{1, 0, false}, {2, 1, true}, {4, 1, true}, {5, 2, true}, {9, 2, true}} {
+		got, ok := MacroAsOf(pts, d(c.day))
+		if got != c.want || ok != c.ok {
+			t.Errorf("day %d: got %v,%v want %v,%v", c.day, got, ok, c.want, c.ok)
+		}
+	}
+	if _, _, _, err := ParseMacroSpec("DGS10"); err == nil {
+		t.Error("expected error for spec without provider")
+	}
+}
+
+// A monthly figure released weeks after its month isn't known until its
+// release: before then the previous month's is the latest.
+func TestMacroAsOf_Published(t *testing.T) {
+	d := func(month, day int) time.Time { return time.Date(2024, time.Month(month), day, 0, 0, 0, 0, time.UTC) }
+	pts := []MacroPoint{
+		{Date: d(1, 1), Value: 3.1, Published: d(2, 13)},
+		{Date: d(2, 1), Value: 3.2, Published: d(3, 12)},
+	}
+	for _, c := range []struct {
+		month, day int
+		want       float64
+		ok         bool
+	}{{1, 31, 0, false}, {2, 12, 0, false}, {2, 13, 3.1, true}, {3, 11, 3.1, true}, {3, 12, 3.2, true}} {
+		got, ok := MacroAsOf(pts, d(c.month, c.day))
+		if got != c.want || ok != c.ok {
+			t.Errorf("%d/%d: got %v,%v want %v,%v", c.month, c.day, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestParseMacroSpec(t *testing.T) {
+	provider, code, lag, err := ParseMacroSpec("Quandl:FRED/GDP@30")
+	if err != nil || provider != "quandl" || code != "FRED/GDP" || lag != 30 {
+		t.Errorf("got %q %q %d %v", provider, code, lag, err)
+	}
+	if _, code, lag, err := ParseMacroSpec("fred:DGS10"); err != nil || code != "DGS10" || lag != 0 {
+		t.Errorf("fred:DGS10: got %q %d %v", code, lag, err)
+	}
+	for _, bad := range []string{"DGS10", "bloomberg:X", "fred:X@-1", "fred:X@a", "fred:@3"} {
+		if _, _, _, err := ParseMacroSpec(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
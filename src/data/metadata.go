@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Security metadata lives in its own table, one row per ticker, loaded
+// from a CSV of ticker,sector,industry,market_cap rows (see SetMetadata).
+const metadataTableDDL = `
+	CREATE TABLE IF NOT EXISTS ticker_metadata (
+		Ticker VARCHAR,
+		Sector VARCHAR,
+		Industry VARCHAR,
+		MarketCap DOUBLE
+	);
+`
+
+// SecurityMetadata classifies a ticker. Empty fields, or a MarketCap of
+// 0, are unknown.
+type SecurityMetadata struct {
+	Sector    string
+	Industry  string
+	MarketCap float64 // in the ticker's currency
+}
+
+// SetMetadata upserts each ticker's metadata into ticker_metadata.
+func (s *Store) SetMetadata(ctx context.Context, meta map[string]SecurityMetadata) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, metadataTableDDL); err != nil {
+		return fmt.Errorf("create ticker_metadata: %w", err)
+	}
+	tickers := make([]string, 0, len(meta))
+	for t := range meta {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	for _, t := range tickers {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM ticker_metadata WHERE Ticker = ?;`, t); err != nil {
+			return fmt.Errorf("clear %s metadata: %w", t, err)
+		}
+		m := meta[t]
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO ticker_metadata (Ticker, Sector, Industry, MarketCap) VALUES (?, ?, ?, ?);`,
+			t, m.Sector, m.Industry, m.MarketCap,
+		); err != nil {
+			return fmt.Errorf("insert %s metadata: %w", t, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Metadata returns every ticker's metadata; none if ticker_metadata
+// hasn't been loaded. A ticker with only a sector in ticker_sectors (see
+// SetSectors) has that.
+func (s *Store) Metadata(ctx context.Context) (map[string]SecurityMetadata, error) {
+	meta, err := s.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sectors, err := s.sectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for t, sector := range sectors {
+		if m := meta[t]; m.Sector == "" {
+			m.Sector = sector
+			meta[t] = m
+		}
+	}
+	return meta, nil
+}
+
+// metadata reads ticker_metadata alone.
+func (s *Store) metadata(ctx context.Context) (map[string]SecurityMetadata, error) {
+	if _, err := s.db.ExecContext(ctx, metadataTableDDL); err != nil {
+		return nil, fmt.Errorf("create ticker_metadata: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT Ticker, Sector, Industry, MarketCap FROM ticker_metadata;`)
+	if err != nil {
+		return nil, fmt.Errorf("query metadata: %w", err)
+	}
+	defer rows.Close()
+	meta := make(map[string]SecurityMetadata)
+	for rows.Next() {
+		var t string
+		var m SecurityMetadata
+		if err := rows.Scan(&t, &m.Sector, &m.Industry, &m.MarketCap); err != nil {
+			return meta, fmt.Errorf("scan row: %w", err)
+		}
+		meta[t] = m
+	}
+	return meta, rows.Err()
+}
+
+// ReadMetadataCSV parses ticker,sector,industry,market_cap rows, with an
+// optional header. Trailing columns may be left off, and market_cap
+// empty.
+func ReadMetadataCSV(r io.Reader) (map[string]SecurityMetadata, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	out := map[string]SecurityMetadata{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(rec[0], "ticker") {
+			continue
+		}
+		if len(rec) < 2 || len(rec) > 4 {
+			return nil, fmt.Errorf("line %d: want 2 to 4 fields, got %d", line, len(rec))
+		}
+		if rec[0] == "" {
+			return nil, fmt.Errorf("line %d: empty ticker", line)
+		}
+		m := SecurityMetadata{Sector: rec[1]}
+		if len(rec) > 2 {
+			m.Industry = rec[2]
+		}
+		if len(rec) > 3 && rec[3] != "" {
+			cap, err := strconv.ParseFloat(rec[3], 64)
+			if err != nil || !(cap >= 0) {
+				return nil, fmt.Errorf("line %d: market_cap %q: must be a non-negative number", line, rec[3])
+			}
+			m.MarketCap = cap
+		}
+		out[rec[0]] = m
+	}
+	return out, nil
+}
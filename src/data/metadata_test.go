@@ -0,0 +1,29 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadMetadataCSV(t *testing.T) {
+	got, err := ReadMetadataCSV(strings.NewReader(
+		"ticker,sector,industry,market_cap\nAAPL,Technology,Consumer Electronics,3.4e12\nXOM,Energy,,\nSPY,ETF\n"))
+	want := map[string]SecurityMetadata{
+		"AAPL": {"Technology", "Consumer Electronics", 3.4e12},
+		"XOM":  {Sector: "Energy"},
+		"SPY":  {Sector: "ETF"},
+	}
+	if err != nil || len(got) != len(want) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	for tk, m := range want {
+		if got[tk] != m {
+			t.Errorf("%s = %+v, want %+v", tk, got[tk], m)
+		}
+	}
+	for _, bad := range []string{",Energy\n", "XOM\n", "XOM,Energy,Oil,big\n", "XOM,Energy,Oil,-1\n", "A,B,C,1,2\n"} {
+		if _, err := ReadMetadataCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DataProvider is a source of bars and risk-free rates. *Store is the
+// DuckDB implementation and the default; CSVDir reads a directory of
+// CSV files. Like Store's queries, GetBars and GetRiskFree log what they
+// can't read and return the rest.
+type DataProvider interface {
+	// GetBars returns tickers' date-ordered bars between start and end,
+	// with returns filled. Tickers without bars are absent.
+	GetBars(ctx context.Context, tickers []string, start, end time.Time) map[string][]AssetData
+	// GetTickers lists the tickers with bars, sorted.
+	GetTickers(ctx context.Context) ([]string, error)
+	// GetRiskFree returns the daily decimal risk-free rates between start
+	// and end, keyed by the Unix seconds of their date.
+	GetRiskFree(ctx context.Context, start, end time.Time) map[int64]float64
+}
+
+var (
+	_ DataProvider = (*Store)(nil)
+	_ DataProvider = (*CSVDir)(nil)
+)
+
+// GetBars is QueryAssetsForTickers.
+func (s *Store) GetBars(ctx context.Context, tickers []string, start, end time.Time) map[string][]AssetData {
+	return s.QueryAssetsForTickers(ctx, tickers, start, end)
+}
+
+// GetTickers is ListTickers.
+func (s *Store) GetTickers(ctx context.Context) ([]string, error) {
+	return s.ListTickers(ctx)
+}
+
+// GetRiskFree is GetRiskFreeRates.
+func (s *Store) GetRiskFree(ctx context.Context, start, end time.Time) map[int64]float64 {
+	return s.GetRiskFreeRates(ctx, start, end)
+}
+
+// RiskFreeFile is the file in a CSVDir holding risk-free rates, as
+// "date,rate" rows of daily decimal rates under a header.
+const RiskFreeFile = "risk_free.csv"
+
+// CSVDir serves each ticker's bars from <Dir>/<ticker>.csv, in the
+// format ImportCSV reads with Options (the file name is the ticker), and
+// risk-free rates from <Dir>/risk_free.csv. A file is read and validated
+// the first time it is needed and then kept in memory.
+type CSVDir struct {
+	Dir     string
+	Options ImportOptions
+
+	mu    sync.Mutex
+	bars  map[string][]AssetData
+	rates map[int64]float64
+}
+
+// NewCSVDir returns a provider over the CSV files in dir.
+func NewCSVDir(dir string, opts ImportOptions) *CSVDir {
+	return &CSVDir{Dir: dir, Options: opts, bars: map[string][]AssetData{}}
+}
+
+func (c *CSVDir) GetBars(ctx context.Context, tickers []string, start, end time.Time) map[string][]AssetData {
+	out := make(map[string][]AssetData, len(tickers))
+	for _, t := range tickers {
+		if ctx.Err() != nil {
+			break
+		}
+		bars, err := c.load(t)
+		if err != nil {
+			logger.Error("read bars", "ticker", t, "err", err)
+			continue
+		}
+		lo := sort.Search(len(bars), func(i int) bool { return !bars[i].Date.Before(start) })
+		hi := sort.Search(len(bars), func(i int) bool { return bars[i].Date.After(end) })
+		if lo < hi {
+			window := append([]AssetData(nil), bars[lo:hi]...)
+			FillReturns(window)
+			out[t] = window
+		}
+	}
+	return out
+}
+
+func (c *CSVDir) load(ticker string) ([]AssetData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if bars, ok := c.bars[ticker]; ok {
+		return bars, nil
+	}
+	f, err := os.Open(filepath.Join(c.Dir, ticker+".csv"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	opts := c.Options
+	opts.Ticker = ticker
+	byTicker, err := ReadBarsCSV(f, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.bars[ticker] = byTicker[ticker]
+	return c.bars[ticker], nil
+}
+
+func (c *CSVDir) GetTickers(context.Context) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(c.Dir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	var tickers []string
+	for _, f := range files {
+		if name := filepath.Base(f); name != RiskFreeFile {
+			tickers = append(tickers, strings.TrimSuffix(name, ".csv"))
+		}
+	}
+	sort.Strings(tickers)
+	return tickers, nil
+}
+
+func (c *CSVDir) GetRiskFree(_ context.Context, start, end time.Time) map[int64]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rates == nil {
+		rates, err := readRatesCSV(filepath.Join(c.Dir, RiskFreeFile))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Error("read risk-free rates", "err", err)
+		}
+		c.rates = rates
+		if c.rates == nil {
+			c.rates = map[int64]float64{}
+		}
+	}
+	out := make(map[int64]float64)
+	for day, r := range c.rates {
+		if d := time.Unix(day, 0); !d.Before(start) && !d.After(end) {
+			out[day] = r
+		}
+	}
+	return out
+}
+
+func readRatesCSV(path string) (map[int64]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	rates := map[int64]float64{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", rec[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		r, err := strconv.ParseFloat(rec[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: rate %q: not a number", path, line, rec[1])
+		}
+		rates[d.Unix()] = r
+	}
+}
@@ -0,0 +1,245 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Bars that fail a quality check can be copied into quarantine_bars for
+// review, with the check and what it found. The bars themselves stay
+// where they are; the table is rewritten by each ValidateData that
+// quarantines.
+const quarantineTableDDL = `
+	CREATE TABLE IF NOT EXISTS quarantine_bars (
+		Source     VARCHAR,
+		Ticker     VARCHAR,
+		BarSeconds BIGINT,
+		Date       TIMESTAMP,
+		Open       DOUBLE,
+		High       DOUBLE,
+		Low        DOUBLE,
+		Close      DOUBLE,
+		Volume     DOUBLE,
+		"Check"    VARCHAR,
+		Detail     VARCHAR
+	);
+`
+
+// QualityCheck names a data quality problem ValidateData looks for.
+type QualityCheck string
+
+const (
+	CheckDuplicate QualityCheck = "duplicate" // a second bar on the same date
+	CheckPrice     QualityCheck = "price"     // a zero, negative or NaN price
+	CheckOHLC      QualityCheck = "ohlc"      // High and Low don't bracket Open and Close, or negative volume
+	CheckJump      QualityCheck = "jump"      // a close-to-close move past MaxJump
+	CheckGap       QualityCheck = "gap"       // more than MaxGapDays calendar days since the last bar
+)
+
+// Defaults for QualityOptions left zero.
+const (
+	DefaultMaxJump    = 0.5
+	DefaultMaxGapDays = 10
+)
+
+// QualityOptions tune ValidateData.
+type QualityOptions struct {
+	// Tickers limits the scan; all tickers when empty.
+	Tickers []string
+	// MaxJump is the largest close-to-close change, as a fraction either
+	// way, not flagged; DefaultMaxJump when 0. Splits not yet adjusted
+	// for show up as jumps.
+	MaxJump float64
+	// MaxGapDays is the longest run of calendar days between a ticker's
+	// bars not flagged; DefaultMaxGapDays when 0.
+	MaxGapDays int
+	// Quarantine rewrites quarantine_bars with the flagged bars.
+	Quarantine bool
+}
+
+func (o QualityOptions) withDefaults() QualityOptions {
+	if o.MaxJump == 0 {
+		o.MaxJump = DefaultMaxJump
+	}
+	if o.MaxGapDays == 0 {
+		o.MaxGapDays = DefaultMaxGapDays
+	}
+	return o
+}
+
+// QualityIssue is one bar failing one check. Source is the table it is
+// in, and Interval its bar length there, 0 for daily bars.
+type QualityIssue struct {
+	Source   string
+	Ticker   string
+	Interval time.Duration
+	Bar      AssetData
+	Check    QualityCheck
+	Detail   string
+}
+
+// CheckBars runs every check over one ticker's date-ordered bars and
+// returns what it finds, with only Bar, Check and Detail set. A bar that
+// fails the price check isn't used to judge the next one's jump.
+func CheckBars(bars []AssetData, opts QualityOptions) []QualityIssue {
+	opts = opts.withDefaults()
+	var issues []QualityIssue
+	flag := func(b AssetData, check QualityCheck, format string, args ...any) {
+		issues = append(issues, QualityIssue{Bar: b, Check: check, Detail: fmt.Sprintf(format, args...)})
+	}
+	maxGap := time.Duration(opts.MaxGapDays) * 24 * time.Hour
+	prevClose := 0.0
+	for i, b := range bars {
+		if i > 0 {
+			prev := bars[i-1].Date
+			switch {
+			case b.Date.Equal(prev):
+				flag(b, CheckDuplicate, "another bar on %s", formatBarDate(prev))
+			case b.Date.Sub(prev) > maxGap:
+				flag(b, CheckGap, "%d days since %s", int(b.Date.Sub(prev).Hours()/24), formatBarDate(prev))
+			}
+		}
+		if !(b.Open > 0 && b.High > 0 && b.Low > 0 && b.Close > 0) {
+			flag(b, CheckPrice, "open %v high %v low %v close %v", b.Open, b.High, b.Low, b.Close)
+			continue
+		}
+		switch {
+		case b.High < b.Low || b.High < math.Max(b.Open, b.Close) || b.Low > math.Min(b.Open, b.Close):
+			flag(b, CheckOHLC, "high %v and low %v don't bracket open %v and close %v", b.High, b.Low, b.Open, b.Close)
+		case !(b.Volume >= 0):
+			flag(b, CheckOHLC, "volume %v", b.Volume)
+		}
+		if prevClose > 0 {
+			if move := b.Close/prevClose - 1; math.Abs(move) > opts.MaxJump {
+				flag(b, CheckJump, "close %v is %+.0f%% on %v", b.Close, move*100, prevClose)
+			}
+		}
+		prevClose = b.Close
+	}
+	return issues
+}
+
+// formatBarDate formats a bar's date, with its time when it has one.
+func formatBarDate(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// ValidateData runs CheckBars over every ticker's bars in
+// stock_data_optimized and, if it exists, each interval's in
+// intraday_bars, one series in memory at a time, returning the issues in
+// table, ticker and date order. With opts.Quarantine it also rewrites
+// quarantine_bars with them.
+func (s *Store) ValidateData(ctx context.Context, opts QualityOptions) ([]QualityIssue, error) {
+	issues, err := s.checkTable(ctx, "stock_data_optimized", "0", opts)
+	if err != nil {
+		return nil, err
+	}
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_name = 'intraday_bars';`,
+	).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("validate intraday bars: %w", err)
+	}
+	if exists {
+		intraday, err := s.checkTable(ctx, "intraday_bars", "BarSeconds", opts)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, intraday...)
+	}
+	if opts.Quarantine {
+		if err := s.quarantine(ctx, issues); err != nil {
+			return issues, err
+		}
+	}
+	return issues, nil
+}
+
+// checkTable runs CheckBars over each ticker and bar length's series in
+// table, whose bar length in seconds is the SQL expression secs.
+func (s *Store) checkTable(ctx context.Context, table, secs string, opts QualityOptions) ([]QualityIssue, error) {
+	where, args := "", []any{}
+	if len(opts.Tickers) > 0 {
+		where = "WHERE Ticker IN (" + strings.TrimSuffix(strings.Repeat("?,", len(opts.Tickers)), ",") + ")"
+		for _, t := range opts.Tickers {
+			args = append(args, t)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT Ticker, %s, Date, Open, High, Low, Close, Volume
+		FROM %s
+		%s
+		ORDER BY Ticker, 2, Date;
+	`, secs, table, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("validate %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var issues []QualityIssue
+	var series []AssetData
+	var ticker string
+	var seconds int64
+	flush := func() {
+		for _, is := range CheckBars(series, opts) {
+			is.Source, is.Ticker, is.Interval = table, ticker, time.Duration(seconds)*time.Second
+			issues = append(issues, is)
+		}
+		series = series[:0]
+	}
+	for rows.Next() {
+		var t string
+		var secs int64
+		var b AssetData
+		if err := rows.Scan(&t, &secs, &b.Date, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume); err != nil {
+			return issues, fmt.Errorf("scan row: %w", err)
+		}
+		if t != ticker || secs != seconds {
+			flush()
+			ticker, seconds = t, secs
+		}
+		series = append(series, b)
+	}
+	flush()
+	return issues, rows.Err()
+}
+
+// quarantine replaces quarantine_bars' rows with issues' bars.
+func (s *Store) quarantine(ctx context.Context, issues []QualityIssue) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, quarantineTableDDL); err != nil {
+		return fmt.Errorf("create quarantine_bars: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM quarantine_bars;`); err != nil {
+		return fmt.Errorf("clear quarantine_bars: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO quarantine_bars
+			(Source, Ticker, BarSeconds, Date, Open, High, Low, Close, Volume, "Check", Detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, is := range issues {
+		b := is.Bar
+		if _, err := stmt.ExecContext(ctx,
+			is.Source, is.Ticker, int64(is.Interval/time.Second), b.Date,
+			b.Open, b.High, b.Low, b.Close, b.Volume, string(is.Check), is.Detail,
+		); err != nil {
+			return fmt.Errorf("quarantine %s %s: %w", is.Ticker, formatBarDate(b.Date), err)
+		}
+	}
+	return tx.Commit()
+}
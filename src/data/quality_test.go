@@ -0,0 +1,46 @@
+package data
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckBars(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	bar := func(d int, o, h, l, c float64) AssetData {
+		return AssetData{Date: day(d), Open: o, High: h, Low: l, Close: c, Volume: 100}
+	}
+	negVol := bar(4, 10, 11, 9, 10)
+	negVol.Volume = -5
+	bars := []AssetData{
+		bar(1, 10, 11, 9, 10),
+		bar(2, 10, 11, 9, 10),
+		bar(2, 10, 11, 9, 10),       // duplicate
+		bar(3, 10, 9, 8, 10),        // high below the close
+		negVol,                      // negative volume
+		bar(5, 10, 30, 9, 25),       // +150%
+		bar(6, 0, 25, 20, 25),       // zero open
+		bar(7, math.NaN(), 1, 1, 1), // NaN open
+		bar(20, 24, 26, 23, 25),     // 13 days on; the jump is from day 5's close
+	}
+	var got []string
+	for _, is := range CheckBars(bars, QualityOptions{}) {
+		got = append(got, is.Bar.Date.Format("02")+" "+string(is.Check))
+	}
+	want := []string{"02 duplicate", "03 ohlc", "04 ohlc", "05 jump", "06 price", "07 price", "20 gap"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A looser jump threshold and a longer gap pass both.
+	got = nil
+	for _, is := range CheckBars(bars, QualityOptions{MaxJump: 2, MaxGapDays: 30}) {
+		got = append(got, is.Bar.Date.Format("02")+" "+string(is.Check))
+	}
+	want = []string{"02 duplicate", "03 ohlc", "04 ohlc", "06 price", "07 price"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loose: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/piquette/finance-go/quote"
+)
+
+// QuoteSource returns the latest (live or delayed) bar for a ticker.
+// Implementations are used by paper trading in place of the DuckDB
+// history, so the returned AssetData must carry the quote's own
+// timestamp in Date rather than the time it was fetched. Quote returns
+// ctx's error once ctx is done.
+type QuoteSource interface {
+	Quote(ctx context.Context, ticker string) (AssetData, error)
+}
+
+// YahooQuotes fetches delayed quotes from Yahoo Finance via finance-go.
+// The bar is the current session so far: Open/High/Low are the day's
+// values and Close is the last trade price. finance-go takes no context,
+// so ctx is only checked before the request is made.
+type YahooQuotes struct{}
+
+func (YahooQuotes) Quote(ctx context.Context, ticker string) (AssetData, error) {
+	if err := ctx.Err(); err != nil {
+		return AssetData{}, err
+	}
+	q, err := quote.Get(ticker)
+	if err != nil {
+		return AssetData{}, fmt.Errorf("yahoo quote %s: %w", ticker, err)
+	}
+	if q == nil || q.RegularMarketPrice <= 0 {
+		return AssetData{}, fmt.Errorf("yahoo quote %s: no price", ticker)
+	}
+	return AssetData{
+		Date:   time.Unix(int64(q.RegularMarketTime), 0).UTC(),
+		Open:   q.RegularMarketOpen,
+		High:   q.RegularMarketDayHigh,
+		Low:    q.RegularMarketDayLow,
+		Close:  q.RegularMarketPrice,
+		Volume: float64(q.RegularMarketVolume),
+	}, nil
+}
+
+// NewQuoteSource returns the QuoteSource registered under name. An empty
+// name selects Yahoo.
+func NewQuoteSource(name string) (QuoteSource, error) {
+	switch name {
+	case "", "yahoo":
+		return YahooQuotes{}, nil
+	case "binance":
+		return BinanceQuotes{}, nil
+	}
+	return nil, fmt.Errorf("unknown quote source %q", name)
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"math"
+	"my-backtester/src/calendar"
+)
+
+// Resample aggregates a date-ordered daily series into one bar per
+// calendar period (see calendar.Period: "week", "month", "quarter" or
+// "year"): the first day's Open, the period's highest High and lowest
+// Low, the last day's Close and the summed Volume, dated on the period's
+// last bar so it's only known once the period has closed. Returns are
+// recomputed across the new bars. The last bar covers whatever of its
+// period series has, which may be a partial week or month.
+func Resample(series []AssetData, every string) []AssetData {
+	if len(series) == 0 {
+		return nil
+	}
+	out := make([]AssetData, 0, len(series)/4+1)
+	var bar AssetData
+	period := 0
+	for i, day := range series {
+		if p := calendar.Period(day.Date, every); i == 0 || p != period {
+			if i > 0 {
+				out = append(out, bar)
+			}
+			period = p
+			bar = AssetData{Date: day.Date, Open: day.Open, High: day.High, Low: day.Low, Close: day.Close, Volume: day.Volume}
+			continue
+		}
+		bar.Date = day.Date
+		bar.High = math.Max(bar.High, day.High)
+		bar.Low = math.Min(bar.Low, day.Low)
+		bar.Close = day.Close
+		bar.Volume += day.Volume
+	}
+	out = append(out, bar)
+	FillReturns(out)
+	return out
+}
+
+// ResampleAll resamples every series in hist (see Resample).
+func ResampleAll(hist map[string][]AssetData, every string) map[string][]AssetData {
+	out := make(map[string][]AssetData, len(hist))
+	for t, series := range hist {
+		out[t] = Resample(series, every)
+	}
+	return out
+}
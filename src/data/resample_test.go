@@ -0,0 +1,37 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestResample(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC) }
+	daily := []AssetData{
+		{Date: day(1, 29), Open: 10, High: 12, Low: 9, Close: 11, Volume: 100},
+		{Date: day(1, 31), Open: 11, High: 15, Low: 10, Close: 14, Volume: 200},
+		{Date: day(2, 1), Open: 14, High: 14, Low: 8, Close: 9, Volume: 50},
+		{Date: day(2, 5), Open: 9, High: 10, Low: 9, Close: 10, Volume: 10},
+	}
+
+	weeks := Resample(daily, "week")
+	if len(weeks) != 2 {
+		t.Fatalf("got %d weekly bars, want 2", len(weeks))
+	}
+	want := AssetData{Date: day(2, 1), Open: 10, High: 15, Low: 8, Close: 9, Volume: 350}
+	if w := weeks[0]; w != want {
+		t.Errorf("week 5 = %+v, want %+v", w, want)
+	}
+	if r := weeks[1].Return; math.Abs(r-1.0/9) > 1e-12 {
+		t.Errorf("week 6 return = %v, want %v", r, 1.0/9)
+	}
+
+	months := Resample(daily, "month")
+	if len(months) != 2 || !months[0].Date.Equal(day(1, 31)) || months[0].Close != 14 || months[1].Open != 14 {
+		t.Errorf("monthly bars = %+v", months)
+	}
+	if Resample(nil, "week") != nil {
+		t.Error("empty series resampled to bars")
+	}
+}
@@ -0,0 +1,261 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// Backtest results are appended to one table, one row per portfolio per
+// run, so past runs can be compared with plain SQL, e.g.
+//
+//	SELECT Strategy, ParamsHash, avg(SharpeRatio) FROM results GROUP BY ALL;
+const resultsTableDDL = `
+	CREATE TABLE IF NOT EXISTS results (
+		RunAt             TIMESTAMP,
+		Portfolio         VARCHAR,
+		Strategy          VARCHAR,
+		Tickers           VARCHAR,
+		ParamsHash        VARCHAR,
+		Params            VARCHAR,
+		Seed              BIGINT,
+		StartDate         TIMESTAMP,
+		EndDate           TIMESTAMP,
+		EffectiveStart    TIMESTAMP,
+		EffectiveEnd      TIMESTAMP,
+		SharpeRatio       DOUBLE,
+		SortinoRatio      DOUBLE,
+		MaxDrawdown       DOUBLE,
+		AnnualReturn      DOUBLE,
+		StandardDev       DOUBLE,
+		AvgCorrelation    DOUBLE,
+		CointegratedPairs BIGINT,
+		Observations      BIGINT,
+		RiskFreeFilled    BIGINT,
+		BenchmarkReturn   DOUBLE,
+		CalmarRatio       DOUBLE,
+		ClosedTrades      BIGINT,
+		WinRate           DOUBLE,
+		AvgWin            DOUBLE,
+		AvgLoss           DOUBLE,
+		ProfitFactor      DOUBLE,
+		Expectancy        DOUBLE,
+		AvgHoldingDays    DOUBLE,
+		VaRConfidence     DOUBLE,
+		VaR               DOUBLE,
+		ParametricVaR     DOUBLE,
+		CVaR              DOUBLE,
+		MaxDrawdownDays   BIGINT,
+		RecoveryDays      BIGINT,
+		Seq               BIGINT
+	);
+	ALTER TABLE results ADD COLUMN IF NOT EXISTS Seq BIGINT;
+	CREATE TABLE IF NOT EXISTS result_equity (
+		RunAt       TIMESTAMP,
+		Seq         BIGINT,
+		Date        TIMESTAMP,
+		Value       DOUBLE,
+		DailyReturn DOUBLE
+	);
+`
+
+// resultColumns are the results table's columns in ResultRow's order.
+const resultColumns = `RunAt, Portfolio, Strategy, Tickers, ParamsHash, Params, Seed,
+	StartDate, EndDate, EffectiveStart, EffectiveEnd,
+	SharpeRatio, SortinoRatio, MaxDrawdown, AnnualReturn, StandardDev, AvgCorrelation,
+	CointegratedPairs, Observations, RiskFreeFilled, BenchmarkReturn, CalmarRatio,
+	ClosedTrades, WinRate, AvgWin, AvgLoss, ProfitFactor, Expectancy, AvgHoldingDays,
+	VaRConfidence, VaR, ParametricVaR, CVaR, MaxDrawdownDays, RecoveryDays, Seq`
+
+// ResultRow is one row of the results table. Zero times are stored as
+// NULL.
+type ResultRow struct {
+	RunAt      time.Time // shared by every row of one run
+	Seq        int       // the result's position in its run; -1 if not recorded
+	Portfolio  string
+	Strategy   string
+	Tickers    string // comma-separated
+	ParamsHash string
+	Params     string // JSON
+	Seed       int64
+
+	StartDate, EndDate           time.Time
+	EffectiveStart, EffectiveEnd time.Time
+
+	SharpeRatio       float64
+	SortinoRatio      float64
+	MaxDrawdown       float64
+	AnnualReturn      float64
+	StandardDev       float64
+	AvgCorrelation    float64
+	CointegratedPairs int
+	Observations      int
+	RiskFreeFilled    int
+	BenchmarkReturn   float64
+	CalmarRatio       float64
+	ClosedTrades      int
+	WinRate           float64
+	AvgWin            float64
+	AvgLoss           float64
+	ProfitFactor      float64
+	Expectancy        float64
+	AvgHoldingDays    float64
+	// VaR at the portfolio's first confidence level.
+	VaRConfidence float64
+	VaR           float64
+	ParametricVaR float64
+	CVaR          float64
+
+	MaxDrawdownDays int
+	RecoveryDays    int
+
+	// Equity is the portfolio's daily value, stored in the result_equity
+	// table under RunAt and Seq. QueryResults leaves it empty; see
+	// QueryEquity.
+	Equity []EquityPoint
+}
+
+// EquityPoint is a portfolio's value at one date's close and its return
+// from the previous close.
+type EquityPoint struct {
+	Date   time.Time
+	Value  float64
+	Return float64
+}
+
+// InsertResults appends rows to the results table, and their equity
+// curves to result_equity, creating them if needed. Rows go through
+// DuckDB's appender on one connection, which is far cheaper than an
+// INSERT per row for large sweeps; they become visible together when the
+// appender is closed.
+func (s *Store) InsertResults(ctx context.Context, rows []ResultRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, resultsTableDDL); err != nil {
+		return fmt.Errorf("create results: %w", err)
+	}
+	return conn.Raw(func(dc any) error {
+		a, err := duckdb.NewAppenderFromConn(dc.(driver.Conn), "", "results")
+		if err != nil {
+			return fmt.Errorf("results appender: %w", err)
+		}
+		for _, r := range rows {
+			if err := a.AppendRow(
+				r.RunAt, r.Portfolio, r.Strategy, r.Tickers,
+				r.ParamsHash, r.Params, r.Seed,
+				nullTime(r.StartDate), nullTime(r.EndDate),
+				nullTime(r.EffectiveStart), nullTime(r.EffectiveEnd),
+				r.SharpeRatio, r.SortinoRatio, r.MaxDrawdown, r.AnnualReturn,
+				r.StandardDev, r.AvgCorrelation, int64(r.CointegratedPairs),
+				int64(r.Observations), int64(r.RiskFreeFilled), r.BenchmarkReturn,
+				r.CalmarRatio, int64(r.ClosedTrades), r.WinRate, r.AvgWin, r.AvgLoss,
+				r.ProfitFactor, r.Expectancy, r.AvgHoldingDays,
+				r.VaRConfidence, r.VaR, r.ParametricVaR, r.CVaR,
+				int64(r.MaxDrawdownDays), int64(r.RecoveryDays), int64(r.Seq),
+			); err != nil {
+				a.Close()
+				return fmt.Errorf("append result %s: %w", r.Portfolio, err)
+			}
+		}
+		if err := a.Close(); err != nil {
+			return err
+		}
+		ea, err := duckdb.NewAppenderFromConn(dc.(driver.Conn), "", "result_equity")
+		if err != nil {
+			return fmt.Errorf("result_equity appender: %w", err)
+		}
+		for _, r := range rows {
+			for _, e := range r.Equity {
+				if err := ea.AppendRow(r.RunAt, int64(r.Seq), e.Date, e.Value, e.Return); err != nil {
+					ea.Close()
+					return fmt.Errorf("append equity %s: %w", r.Portfolio, err)
+				}
+			}
+		}
+		return ea.Close()
+	})
+}
+
+// QueryResults returns the newest limit rows of the results table (all
+// of them if limit <= 0), newest run first and in run order within a
+// run. A database without results has none.
+func (s *Store) QueryResults(ctx context.Context, limit int) ([]ResultRow, error) {
+	if _, err := s.db.ExecContext(ctx, resultsTableDDL); err != nil {
+		return nil, fmt.Errorf("create results: %w", err)
+	}
+	query := "SELECT " + resultColumns + " FROM results ORDER BY RunAt DESC, Seq"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query results: %w", err)
+	}
+	defer rows.Close()
+	var out []ResultRow
+	for rows.Next() {
+		var r ResultRow
+		var params sql.NullString
+		var start, end, effStart, effEnd sql.NullTime
+		var seq sql.NullInt64
+		if err := rows.Scan(
+			&r.RunAt, &r.Portfolio, &r.Strategy, &r.Tickers, &r.ParamsHash, &params, &r.Seed,
+			&start, &end, &effStart, &effEnd,
+			&r.SharpeRatio, &r.SortinoRatio, &r.MaxDrawdown, &r.AnnualReturn, &r.StandardDev, &r.AvgCorrelation,
+			&r.CointegratedPairs, &r.Observations, &r.RiskFreeFilled, &r.BenchmarkReturn, &r.CalmarRatio,
+			&r.ClosedTrades, &r.WinRate, &r.AvgWin, &r.AvgLoss, &r.ProfitFactor, &r.Expectancy, &r.AvgHoldingDays,
+			&r.VaRConfidence, &r.VaR, &r.ParametricVaR, &r.CVaR, &r.MaxDrawdownDays, &r.RecoveryDays, &seq,
+		); err != nil {
+			return out, fmt.Errorf("scan result: %w", err)
+		}
+		r.Params = params.String
+		r.StartDate, r.EndDate = start.Time, end.Time
+		r.EffectiveStart, r.EffectiveEnd = effStart.Time, effEnd.Time
+		r.Seq = -1
+		if seq.Valid {
+			r.Seq = int(seq.Int64)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// QueryEquity returns the equity curve stored for the result at seq in
+// the run at runAt, in date order; none for results saved before
+// equity curves were.
+func (s *Store) QueryEquity(ctx context.Context, runAt time.Time, seq int) ([]EquityPoint, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT Date, Value, DailyReturn FROM result_equity WHERE RunAt = ? AND Seq = ? ORDER BY Date`,
+		runAt, seq)
+	if err != nil {
+		return nil, fmt.Errorf("query equity: %w", err)
+	}
+	defer rows.Close()
+	var out []EquityPoint
+	for rows.Next() {
+		var e EquityPoint
+		if err := rows.Scan(&e.Date, &e.Value, &e.Return); err != nil {
+			return out, fmt.Errorf("scan equity: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) driver.Value {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
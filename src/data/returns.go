@@ -0,0 +1,69 @@
+package data
+
+import "math"
+
+// FillReturns populates Return and LogReturn on every bar of a single
+// ticker's date-ordered series. Bars whose previous Close is non-positive
+// keep zero returns rather than producing Inf/NaN.
+func FillReturns(series []AssetData) {
+	if len(series) == 0 {
+		return
+	}
+	series[0].Return = 0
+	series[0].LogReturn = 0
+	for i := 1; i < len(series); i++ {
+		series[i].Return, series[i].LogReturn = barReturn(series[i-1].Close, series[i].Close)
+	}
+}
+
+// barReturn is the simple and log return of a bar closing at curr after
+// one closing at prev: zero unless both closes are positive.
+func barReturn(prev, curr float64) (float64, float64) {
+	if prev <= 0 || curr <= 0 {
+		return 0, 0
+	}
+	return (curr - prev) / prev, math.Log(curr / prev)
+}
+
+// AppendBar appends bar to a date-ordered series, filling its returns
+// against the current last bar. Used by feeds that grow a series one bar
+// at a time instead of loading it whole.
+func AppendBar(series []AssetData, bar AssetData) []AssetData {
+	bar.Return, bar.LogReturn = 0, 0
+	if n := len(series); n > 0 {
+		bar.Return, bar.LogReturn = barReturn(series[n-1].Close, bar.Close)
+	}
+	return append(series, bar)
+}
+
+// Returns copies the precomputed simple returns of series[1:n] into a
+// contiguous slice, ready for vectorized stat functions. n is clamped to
+// len(series); n < 2 yields nil.
+func Returns(series []AssetData, n int) []float64 {
+	if n > len(series) {
+		n = len(series)
+	}
+	if n < 2 {
+		return nil
+	}
+	out := make([]float64, n-1)
+	for i := 1; i < n; i++ {
+		out[i-1] = series[i].Return
+	}
+	return out
+}
+
+// LogReturns is Returns for the precomputed log returns.
+func LogReturns(series []AssetData, n int) []float64 {
+	if n > len(series) {
+		n = len(series)
+	}
+	if n < 2 {
+		return nil
+	}
+	out := make([]float64, n-1)
+	for i := 1; i < n; i++ {
+		out[i-1] = series[i].LogReturn
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultRiskFreeSeries is the FRED series RefreshRiskFree loads when
+// none is given: the 3-month Treasury bill secondary-market rate.
+const DefaultRiskFreeSeries = "DTB3"
+
+// riskFreeDays is the number of days a year the annual rates are
+// compounded over to give daily ones, matching the equities calendar.
+const riskFreeDays = 252
+
+const riskFreeTableDDL = `
+	CREATE TABLE IF NOT EXISTS "3MTreasuryYields" (
+		Date                         TIMESTAMP,
+		daily_risk_free_rate_decimal DOUBLE
+	);
+`
+
+// DailyRiskFree converts an annual rate in percent, as FRED publishes
+// yields, to the daily decimal rate that compounds to it over 252
+// trading days.
+func DailyRiskFree(annualPercent float64) float64 {
+	return math.Pow(1+annualPercent/100, 1.0/riskFreeDays) - 1
+}
+
+// RefreshRiskFree downloads the FRED series (annual percent yields, e.g.
+// DTB3 or DGS1MO) and upserts it into "3MTreasuryYields" as daily
+// decimal rates, returning the number of rates written. Only dates after
+// the table's last are fetched, unless start is before its first date
+// (or it is empty), in which case start..end is.
+func (s *Store) RefreshRiskFree(ctx context.Context, series string, start, end time.Time) (int, error) {
+	if series == "" {
+		series = DefaultRiskFreeSeries
+	}
+	if _, err := s.db.ExecContext(ctx, riskFreeTableDDL); err != nil {
+		return 0, fmt.Errorf("create 3MTreasuryYields: %w", err)
+	}
+	var first, last sql.NullTime
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT MIN(Date), MAX(Date) FROM "3MTreasuryYields";`,
+	).Scan(&first, &last); err != nil {
+		return 0, fmt.Errorf("3MTreasuryYields span: %w", err)
+	}
+	if first.Valid && !start.Before(first.Time) {
+		start = last.Time.AddDate(0, 0, 1)
+	}
+	if start.After(end) {
+		return 0, nil
+	}
+	points, err := FetchFRED(ctx, series, start, end)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.InsertRiskFree(ctx, points); err != nil {
+		return 0, err
+	}
+	return len(points), nil
+}
+
+// InsertRiskFree upserts date-ordered annual percent yields into
+// "3MTreasuryYields" as daily decimal rates (see DailyRiskFree),
+// replacing any rows in the same date range.
+func (s *Store) InsertRiskFree(ctx context.Context, points []MacroPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, riskFreeTableDDL); err != nil {
+		return fmt.Errorf("create 3MTreasuryYields: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM "3MTreasuryYields" WHERE Date BETWEEN ? AND ?;
+	`, points[0].Date, points[len(points)-1].Date); err != nil {
+		return fmt.Errorf("clear risk-free rates: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO "3MTreasuryYields" (Date, daily_risk_free_rate_decimal) VALUES (?, ?);`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range points {
+		if _, err := stmt.ExecContext(ctx, p.Date, DailyRiskFree(p.Value)); err != nil {
+			return fmt.Errorf("insert risk-free rate %s: %w", p.Date.Format("2006-01-02"), err)
+		}
+	}
+	return tx.Commit()
+}
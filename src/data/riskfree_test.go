@@ -0,0 +1,15 @@
+package data
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDailyRiskFree(t *testing.T) {
+	for _, annual := range []float64{0, 5.25, -0.1} {
+		d := DailyRiskFree(annual)
+		if got := math.Pow(1+d, 252); math.Abs(got-(1+annual/100)) > 1e-12 {
+			t.Errorf("DailyRiskFree(%v) = %v compounds to %v a year, want %v", annual, d, got, 1+annual/100)
+		}
+	}
+}
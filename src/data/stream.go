@@ -0,0 +1,138 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BarOrder is the order a BarIterator returns rows in.
+type BarOrder int
+
+const (
+	// BarsByDate merges every ticker's bars by date (tickers
+	// alphabetically within a date), for stepping a simulation forward.
+	BarsByDate BarOrder = iota
+	// BarsByTicker returns one ticker's bars at a time, date-ordered.
+	BarsByTicker
+)
+
+// RowSource is the part of *sql.Rows a BarIterator reads: rows of Date,
+// Ticker, Open, High, Low, Close, Volume.
+type RowSource interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close() error
+}
+
+// BarIterator streams bars from a DuckDB cursor one row at a time, so a
+// long history is never held in memory at once. Returns are filled
+// against each ticker's previous bar as rows arrive. Close it when done.
+type BarIterator struct {
+	rows   RowSource
+	ticker string
+	bar    AssetData
+	prev   map[string]float64 // last close per ticker
+	peeked bool               // ticker and bar hold a row not yet returned
+	err    error
+}
+
+// IterateBars opens a cursor over tickers' bars between start and end in
+// the given order.
+func (s *Store) IterateBars(
+	ctx context.Context, tickers []string, start, end time.Time, order BarOrder,
+) (*BarIterator, error) {
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("iterate bars: no tickers")
+	}
+	orderBy := "Date, Ticker"
+	if order == BarsByTicker {
+		orderBy = "Ticker, Date"
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(tickers)), ",")
+	args := make([]any, 0, len(tickers)+2)
+	for _, t := range tickers {
+		args = append(args, t)
+	}
+	args = append(args,
+		start.Format("2006-01-02 15:04:05.000000000"),
+		end.Format("2006-01-02 15:04:05.000000000"),
+	)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT Date, Ticker, Open, High, Low, Close, Volume
+		FROM stock_data_optimized
+		WHERE Ticker IN (%s)
+		  AND Date BETWEEN CAST(? AS TIMESTAMP_NS) AND CAST(? AS TIMESTAMP_NS)
+		ORDER BY %s;
+	`, placeholders, orderBy), args...)
+	if err != nil {
+		return nil, fmt.Errorf("iterate bars: %w", err)
+	}
+	return NewBarIterator(rows), nil
+}
+
+// NewBarIterator iterates rows, which must already be in a BarOrder.
+// Backends other than DuckDB can stream through it.
+func NewBarIterator(rows RowSource) *BarIterator {
+	return &BarIterator{rows: rows, prev: map[string]float64{}}
+}
+
+// Next advances to the next row, reporting false at the end of the
+// cursor or on an error (see Err).
+func (it *BarIterator) Next() bool {
+	if it.peeked {
+		it.peeked = false
+		return true
+	}
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	var b AssetData
+	var ticker string
+	if err := it.rows.Scan(&b.Date, &ticker, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume); err != nil {
+		it.err = fmt.Errorf("scan bar: %w", err)
+		return false
+	}
+	if prev, ok := it.prev[ticker]; ok {
+		b.Return, b.LogReturn = barReturn(prev, b.Close)
+	}
+	it.prev[ticker] = b.Close
+	it.ticker, it.bar = ticker, b
+	return true
+}
+
+// Ticker and Bar are the current row.
+func (it *BarIterator) Ticker() string { return it.ticker }
+func (it *BarIterator) Bar() AssetData { return it.bar }
+
+// NextDate advances past every row of the next date and returns them by
+// ticker. It is meant for BarsByDate cursors and reports false at the
+// end or on an error.
+func (it *BarIterator) NextDate() (time.Time, map[string]AssetData, bool) {
+	if !it.Next() {
+		return time.Time{}, nil, false
+	}
+	date := it.bar.Date
+	bars := map[string]AssetData{it.ticker: it.bar}
+	for it.Next() {
+		if !it.bar.Date.Equal(date) {
+			it.peeked = true
+			break
+		}
+		bars[it.ticker] = it.bar
+	}
+	return date, bars, true
+}
+
+// Err is the error that stopped iteration, if any.
+func (it *BarIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+// Close releases the cursor.
+func (it *BarIterator) Close() error { return it.rows.Close() }
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SymbolChange is a rename: Ticker trades as NewTicker from Date.
+type SymbolChange struct {
+	Ticker    string
+	NewTicker string
+	Date      time.Time
+}
+
+// SymbolSpan is one symbol a security traded under, from From up to,
+// but not including, Until; a zero time leaves that side open.
+type SymbolSpan struct {
+	Ticker      string
+	From, Until time.Time
+}
+
+// SymbolChanges returns every rename in corporate_actions, by date; none
+// if the table hasn't been loaded.
+func (s *Store) SymbolChanges(ctx context.Context) ([]SymbolChange, error) {
+	if _, err := s.db.ExecContext(ctx, actionsTableDDL); err != nil {
+		return nil, fmt.Errorf("create corporate_actions: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT Ticker, NewTicker, Date FROM corporate_actions
+		WHERE NewTicker IS NOT NULL AND NewTicker <> ''
+		ORDER BY Date, Ticker;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query symbol changes: %w", err)
+	}
+	defer rows.Close()
+	var changes []SymbolChange
+	for rows.Next() {
+		var c SymbolChange
+		if err := rows.Scan(&c.Ticker, &c.NewTicker, &c.Date); err != nil {
+			return changes, fmt.Errorf("scan row: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// SymbolHistory returns the symbols the security trading as ticker went
+// by, oldest first: the renames to ticker followed back, then those from
+// it followed forward. Either its old or its new symbol finds the same
+// history. A ticker never renamed is one open span.
+func SymbolHistory(changes []SymbolChange, ticker string) []SymbolSpan {
+	changes = append([]SymbolChange(nil), changes...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Date.Before(changes[j].Date) })
+	spans := []SymbolSpan{{Ticker: ticker}}
+	// Each change is followed at most once, so a cycle of renames ends.
+	used := make([]bool, len(changes))
+	for {
+		first, found := &spans[0], -1
+		for i, c := range changes {
+			if !used[i] && c.NewTicker == first.Ticker &&
+				(first.Until.IsZero() || c.Date.Before(first.Until)) {
+				found = i // the latest rename into first before it ends
+			}
+		}
+		if found < 0 {
+			break
+		}
+		used[found] = true
+		c := changes[found]
+		first.From = c.Date
+		spans = append([]SymbolSpan{{Ticker: c.Ticker, Until: c.Date}}, spans...)
+	}
+	for {
+		last, found := &spans[len(spans)-1], -1
+		for i, c := range changes {
+			if !used[i] && c.Ticker == last.Ticker && c.Date.After(last.From) {
+				found = i // the earliest rename out of last after it starts
+				break
+			}
+		}
+		if found < 0 {
+			break
+		}
+		used[found] = true
+		c := changes[found]
+		last.Until = c.Date
+		spans = append(spans, SymbolSpan{Ticker: c.NewTicker, From: c.Date})
+	}
+	return spans
+}
+
+// StitchSymbols returns one date-ordered series for the security spans
+// describe: each symbol's bars in bars within its span, with returns
+// recomputed across the renames.
+func StitchSymbols(bars map[string][]AssetData, spans []SymbolSpan) []AssetData {
+	var out []AssetData
+	for _, s := range spans {
+		for _, b := range bars[s.Ticker] {
+			if (s.From.IsZero() || !b.Date.Before(s.From)) && (s.Until.IsZero() || b.Date.Before(s.Until)) {
+				out = append(out, b)
+			}
+		}
+	}
+	FillReturns(out)
+	return out
+}
@@ -0,0 +1,51 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSymbolHistory(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	changes := []SymbolChange{
+		{Ticker: "B", NewTicker: "C", Date: day(20)},
+		{Ticker: "A", NewTicker: "B", Date: day(10)},
+		{Ticker: "X", NewTicker: "Y", Date: day(5)},
+	}
+	want := []SymbolSpan{
+		{Ticker: "A", Until: day(10)},
+		{Ticker: "B", From: day(10), Until: day(20)},
+		{Ticker: "C", From: day(20)},
+	}
+	for _, ticker := range []string{"A", "B", "C"} {
+		if got := SymbolHistory(changes, ticker); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", ticker, got, want)
+		}
+	}
+	if got := SymbolHistory(changes, "Z"); !reflect.DeepEqual(got, []SymbolSpan{{Ticker: "Z"}}) {
+		t.Errorf("Z: got %+v, want one open span", got)
+	}
+	// A rename back to an old symbol doesn't loop.
+	cycle := []SymbolChange{{Ticker: "P", NewTicker: "Q", Date: day(1)}, {Ticker: "Q", NewTicker: "P", Date: day(2)}}
+	if got := SymbolHistory(cycle, "P"); len(got) > 3 {
+		t.Errorf("cycle: got %+v", got)
+	}
+
+	bars := map[string][]AssetData{
+		"A": {{Date: day(8), Close: 10}, {Date: day(9), Close: 10}, {Date: day(10), Close: 99}},
+		"B": {{Date: day(9), Close: 99}, {Date: day(10), Close: 11}, {Date: day(19), Close: 11}},
+		"C": {{Date: day(20), Close: 12}},
+	}
+	var closes []float64
+	series := StitchSymbols(bars, want)
+	for _, b := range series {
+		closes = append(closes, b.Close)
+	}
+	if !reflect.DeepEqual(closes, []float64{10, 10, 11, 11, 12}) {
+		t.Errorf("stitched closes = %v, want each symbol's within its span", closes)
+	}
+	if r := series[2].Return; r < 0.09 || r > 0.11 {
+		t.Errorf("return across the rename = %v, want 10%%", r)
+	}
+}
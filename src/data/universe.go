@@ -0,0 +1,291 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sectors live in their own table, one row per ticker, loaded from a
+// CSV of ticker,sector rows (see SetSectors).
+const sectorsTableDDL = `
+	CREATE TABLE IF NOT EXISTS ticker_sectors (
+		Ticker VARCHAR,
+		Sector VARCHAR
+	);
+`
+
+// MinCoverage is the fraction of a window's trading dates a ticker must
+// have bars on for the Coverage filter's default to select it. It allows
+// for the odd trading halt without admitting tickers with real gaps.
+const MinCoverage = 0.95
+
+// TickerStats summarizes a ticker's bars over a universe's lookback
+// window, the facts its filters decide on.
+type TickerStats struct {
+	Ticker string
+	// Bars is the ticker's bar count and Sessions the number of dates
+	// any ticker has a bar on; Spans reports whether the ticker's first
+	// and last bars fall on the window's first and last such dates.
+	Bars     int
+	Sessions int
+	Spans    bool
+	// AvgDollarVolume is the mean of Close times Volume and LastClose the
+	// close of the ticker's last bar before the as-of date.
+	AvgDollarVolume float64
+	LastClose       float64
+	Sector          string // "" when the ticker has none
+}
+
+// UniverseFilter keeps a ticker when it returns true. Filters compose
+// by listing them: a ticker is in the universe only if every one keeps
+// it.
+type UniverseFilter func(TickerStats) bool
+
+// Coverage keeps tickers that trade through the whole lookback window
+// with a bar on at least min of its dates; MinCoverage when min is 0.
+// The calendar comes from the data itself, so weekends, holidays and
+// 24/7 crypto series are all judged against the days that traded.
+func Coverage(min float64) UniverseFilter {
+	if min == 0 {
+		min = MinCoverage
+	}
+	return func(s TickerStats) bool {
+		return s.Spans && float64(s.Bars) >= min*float64(s.Sessions)
+	}
+}
+
+// MinDollarVolume keeps tickers averaging at least v of Close*Volume a
+// bar over the lookback window.
+func MinDollarVolume(v float64) UniverseFilter {
+	return func(s TickerStats) bool { return s.AvgDollarVolume >= v }
+}
+
+// MinPrice keeps tickers whose last close before the as-of date is at
+// least price.
+func MinPrice(price float64) UniverseFilter {
+	return func(s TickerStats) bool { return s.LastClose >= price }
+}
+
+// InSectors keeps tickers in any of sectors, matched case-insensitively.
+func InSectors(sectors ...string) UniverseFilter {
+	want := make(map[string]bool, len(sectors))
+	for _, s := range sectors {
+		want[strings.ToLower(s)] = true
+	}
+	return func(s TickerStats) bool { return want[strings.ToLower(s.Sector)] }
+}
+
+// InList keeps only the tickers listed.
+func InList(tickers ...string) UniverseFilter {
+	want := make(map[string]bool, len(tickers))
+	for _, t := range tickers {
+		want[t] = true
+	}
+	return func(s TickerStats) bool { return want[s.Ticker] }
+}
+
+// ReadTickerList reads a list file: tickers separated by newlines or
+// commas, with blank lines and # comments ignored.
+func ReadTickerList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tickers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		for _, t := range strings.Split(line, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tickers = append(tickers, t)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tickers, nil
+}
+
+// FilterUniverse returns, sorted, the tickers in stats that every
+// filter keeps.
+func FilterUniverse(stats []TickerStats, filters ...UniverseFilter) []string {
+	tickers := make([]string, 0, len(stats))
+next:
+	for _, s := range stats {
+		for _, keep := range filters {
+			if !keep(s) {
+				continue next
+			}
+		}
+		tickers = append(tickers, s.Ticker)
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+// SelectUniverse returns, sorted, the tickers every filter keeps judged
+// point-in-time at asOf: on their bars in the lookback days before it,
+// and not on asOf's own bars or any later, so a universe chosen at the
+// start of a run knows nothing the run is about to simulate.
+func (s *Store) SelectUniverse(
+	ctx context.Context,
+	asOf time.Time,
+	lookbackDays int,
+	filters ...UniverseFilter,
+) ([]string, error) {
+	stats, err := s.UniverseStats(ctx, asOf.AddDate(0, 0, -lookbackDays), asOf)
+	if err != nil {
+		return nil, err
+	}
+	return FilterUniverse(stats, filters...), nil
+}
+
+// UniverseStats summarizes every ticker's bars dated in [from, before),
+// with its sector, in ticker order.
+func (s *Store) UniverseStats(ctx context.Context, from, before time.Time) ([]TickerStats, error) {
+	sectors, err := s.Sectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        WITH w AS (
+            SELECT Ticker, Date, Close, Volume
+            FROM stock_data_optimized
+            WHERE Date >= CAST(? AS TIMESTAMP_NS) AND Date < CAST(? AS TIMESTAMP_NS)
+        ), cal AS (
+            SELECT MIN(Date) AS lo, MAX(Date) AS hi, COUNT(DISTINCT Date) AS n
+            FROM w
+        )
+        SELECT w.Ticker, COUNT(*), cal.n,
+               MIN(w.Date) = cal.lo AND MAX(w.Date) = cal.hi,
+               COALESCE(AVG(w.Close * w.Volume), 0),
+               arg_max(w.Close, w.Date)
+        FROM w, cal
+        GROUP BY w.Ticker, cal.lo, cal.hi, cal.n
+        ORDER BY w.Ticker
+    `
+	queryTime := time.Now()
+	rows, err := s.db.QueryContext(ctx, query,
+		from.Format("2006-01-02 15:04:05.000000000"),
+		before.Format("2006-01-02 15:04:05.000000000"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("universe stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []TickerStats
+	for rows.Next() {
+		var st TickerStats
+		if err := rows.Scan(&st.Ticker, &st.Bars, &st.Sessions, &st.Spans, &st.AvgDollarVolume, &st.LastClose); err != nil {
+			return stats, fmt.Errorf("scan row: %w", err)
+		}
+		st.Sector = sectors[st.Ticker]
+		stats = append(stats, st)
+	}
+	logger.Debug("universe stats", "tickers", len(stats), "elapsed", time.Since(queryTime))
+	return stats, rows.Err()
+}
+
+// SetSectors upserts each ticker's sector into ticker_sectors.
+func (s *Store) SetSectors(ctx context.Context, sectors map[string]string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, sectorsTableDDL); err != nil {
+		return fmt.Errorf("create ticker_sectors: %w", err)
+	}
+	tickers := make([]string, 0, len(sectors))
+	for t := range sectors {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	for _, t := range tickers {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM ticker_sectors WHERE Ticker = ?;`, t); err != nil {
+			return fmt.Errorf("clear %s sector: %w", t, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO ticker_sectors (Ticker, Sector) VALUES (?, ?);`, t, sectors[t],
+		); err != nil {
+			return fmt.Errorf("insert %s sector: %w", t, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Sectors returns every ticker's sector, from ticker_metadata (see
+// SetMetadata) or else ticker_sectors; none if neither has been loaded.
+func (s *Store) Sectors(ctx context.Context) (map[string]string, error) {
+	sectors, err := s.sectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := s.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for t, m := range meta {
+		if m.Sector != "" {
+			sectors[t] = m.Sector
+		}
+	}
+	return sectors, nil
+}
+
+// sectors reads ticker_sectors alone.
+func (s *Store) sectors(ctx context.Context) (map[string]string, error) {
+	if _, err := s.db.ExecContext(ctx, sectorsTableDDL); err != nil {
+		return nil, fmt.Errorf("create ticker_sectors: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT Ticker, Sector FROM ticker_sectors;`)
+	if err != nil {
+		return nil, fmt.Errorf("query sectors: %w", err)
+	}
+	defer rows.Close()
+	sectors := make(map[string]string)
+	for rows.Next() {
+		var t, sector string
+		if err := rows.Scan(&t, &sector); err != nil {
+			return sectors, fmt.Errorf("scan row: %w", err)
+		}
+		sectors[t] = sector
+	}
+	return sectors, rows.Err()
+}
+
+// ReadSectorsCSV parses ticker,sector rows, with an optional header.
+func ReadSectorsCSV(r io.Reader) (map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	out := map[string]string{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(rec[0], "ticker") {
+			continue
+		}
+		if rec[0] == "" {
+			return nil, fmt.Errorf("line %d: empty ticker", line)
+		}
+		out[rec[0]] = rec[1]
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterUniverse(t *testing.T) {
+	stats := []TickerStats{
+		{Ticker: "BIG", Bars: 250, Sessions: 250, Spans: true, AvgDollarVolume: 5e7, LastClose: 120, Sector: "Technology"},
+		{Ticker: "GAPPY", Bars: 200, Sessions: 250, Spans: true, AvgDollarVolume: 5e7, LastClose: 50, Sector: "Technology"},
+		{Ticker: "NEW", Bars: 100, Sessions: 250, Spans: false, AvgDollarVolume: 9e7, LastClose: 30, Sector: "Energy"},
+		{Ticker: "PENNY", Bars: 250, Sessions: 250, Spans: true, AvgDollarVolume: 2e6, LastClose: 0.8, Sector: "Energy"},
+		{Ticker: "OIL", Bars: 245, Sessions: 250, Spans: true, AvgDollarVolume: 3e7, LastClose: 80, Sector: "energy"},
+	}
+	for _, tc := range []struct {
+		name    string
+		filters []UniverseFilter
+		want    []string
+	}{
+		{"coverage", []UniverseFilter{Coverage(0)}, []string{"BIG", "OIL", "PENNY"}},
+		{"liquid", []UniverseFilter{Coverage(0), MinDollarVolume(1e7)}, []string{"BIG", "OIL"}},
+		{"price floor", []UniverseFilter{MinPrice(5)}, []string{"BIG", "GAPPY", "NEW", "OIL"}},
+		{"sector", []UniverseFilter{Coverage(0), InSectors("Energy")}, []string{"OIL", "PENNY"}},
+		{"list", []UniverseFilter{InList("NEW", "OIL", "XYZ")}, []string{"NEW", "OIL"}},
+	} {
+		if got := FilterUniverse(stats, tc.filters...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestReadTickerList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tickers.txt")
+	if err := os.WriteFile(path, []byte("# large caps\nAAPL, MSFT\n\nSPY # the index\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadTickerList(path)
+	if err != nil || !reflect.DeepEqual(got, []string{"AAPL", "MSFT", "SPY"}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestReadSectorsCSV(t *testing.T) {
+	got, err := ReadSectorsCSV(strings.NewReader("ticker,sector\nAAPL,Technology\nXOM,Energy\n"))
+	if err != nil || len(got) != 2 || got["XOM"] != "Energy" {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := ReadSectorsCSV(strings.NewReader(",Energy\n")); err == nil {
+		t.Error("accepted an empty ticker")
+	}
+}
@@ -0,0 +1,106 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/piquette/finance-go/chart"
+	"github.com/piquette/finance-go/datetime"
+)
+
+// FetchYahooBars downloads ticker's daily OHLCV bars between start and
+// end from Yahoo Finance's chart API via finance-go. Index symbols such
+// as ^GSPC work like any other ticker. Bars are dated midnight UTC of
+// their trading day in the exchange's time zone and returned
+// date-ordered with returns filled; rows Yahoo leaves empty (a halted or
+// not yet closed session) are dropped. Prices are as traded, not
+// adjusted for splits or dividends.
+func FetchYahooBars(ctx context.Context, ticker string, start, end time.Time) ([]AssetData, error) {
+	params := &chart.Params{
+		Symbol:   ticker,
+		Start:    datetime.New(&start),
+		End:      datetime.New(&end),
+		Interval: datetime.OneDay,
+	}
+	params.Context = &ctx
+	iter := chart.Get(params)
+	var bars []AssetData
+	var stamps []int
+	for iter.Next() {
+		b := iter.Bar()
+		bar := AssetData{Volume: float64(b.Volume)}
+		bar.Open, _ = b.Open.Float64()
+		bar.High, _ = b.High.Float64()
+		bar.Low, _ = b.Low.Float64()
+		bar.Close, _ = b.Close.Float64()
+		if bar.Close <= 0 {
+			continue
+		}
+		bars = append(bars, bar)
+		stamps = append(stamps, b.Timestamp)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("yahoo chart %s: %w", ticker, err)
+	}
+	if len(bars) == 0 {
+		return nil, nil
+	}
+	offset := iter.Meta().Gmtoffset
+	for i, ts := range stamps {
+		y, m, d := time.Unix(int64(ts+offset), 0).UTC().Date()
+		bars[i].Date = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	FillReturns(bars)
+	return bars, nil
+}
+
+// IngestYahoo downloads ticker's daily bars from Yahoo and upserts them
+// into stock_data_optimized.
+func (s *Store) IngestYahoo(ctx context.Context, ticker string, start, end time.Time) (int, error) {
+	bars, err := FetchYahooBars(ctx, ticker, start, end)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.InsertBars(ctx, ticker, bars); err != nil {
+		return 0, err
+	}
+	return len(bars), nil
+}
+
+// LastDate returns the date of ticker's latest bar in
+// stock_data_optimized, and false when it has none.
+func (s *Store) LastDate(ctx context.Context, ticker string) (time.Time, bool, error) {
+	var last sql.NullTime
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT MAX(Date) FROM stock_data_optimized WHERE Ticker = ?;`, ticker,
+	).Scan(&last); err != nil {
+		return time.Time{}, false, fmt.Errorf("last date of %s: %w", ticker, err)
+	}
+	return last.Time, last.Valid, nil
+}
+
+// ReadTickers parses a ticker list: one or more comma- or
+// whitespace-separated tickers per line, with blank lines and everything
+// after a # ignored. Duplicates are dropped, keeping the first.
+func ReadTickers(r io.Reader) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		for _, t := range strings.FieldsFunc(line, func(c rune) bool {
+			return c == ',' || c == ' ' || c == '\t'
+		}) {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out, sc.Err()
+}
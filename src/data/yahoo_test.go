@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	finance "github.com/piquette/finance-go"
+)
+
+func TestFetchYahooBars(t *testing.T) {
+	// Three sessions opening 09:30 New York time; Yahoo leaves the last,
+	// still trading, empty.
+	open := time.Date(2024, 1, 2, 14, 30, 0, 0, time.UTC).Unix()
+	day := int64(24 * 60 * 60)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/v8/finance/chart/^GSPC") || r.URL.Query().Get("interval") != "1d" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"chart":{"result":[{
+			"meta":{"symbol":"^GSPC","gmtoffset":-18000},
+			"timestamp":[%d,%d,%d],
+			"indicators":{"quote":[{
+				"open":[100,101,null],"high":[102,103,null],"low":[99,100,null],
+				"close":[101,102,null],"volume":[10,20,null]
+			}]}
+		}],"error":null}}`, open, open+day, open+2*day)
+	}))
+	defer srv.Close()
+	old := finance.GetBackend(finance.YFinBackend)
+	finance.SetBackend(finance.YFinBackend, &finance.BackendConfiguration{
+		Type: finance.YFinBackend, URL: srv.URL, HTTPClient: srv.Client(),
+	})
+	defer finance.SetBackend(finance.YFinBackend, old)
+
+	bars, err := FetchYahooBars(context.Background(), "^GSPC",
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AssetData{
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Open: 100, High: 102, Low: 99, Close: 101, Volume: 10},
+		{Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Open: 101, High: 103, Low: 100, Close: 102, Volume: 20, Return: 1.0 / 101, LogReturn: math.Log(102.0 / 101)},
+	}
+	if !reflect.DeepEqual(bars, want) {
+		t.Errorf("bars = %+v\nwant %+v", bars, want)
+	}
+}
+
+func TestReadTickers(t *testing.T) {
+	got, err := ReadTickers(strings.NewReader("# S&P sectors\nXLK, XLF\n\nXLE XLK\t^GSPC # index\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"XLK", "XLF", "XLE", "^GSPC"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadTickers = %v, want %v", got, want)
+	}
+}
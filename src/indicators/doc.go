@@ -0,0 +1,11 @@
+// Package indicators implements technical indicators incrementally: each
+// is a small state machine fed one bar at a time with Update, so a
+// strategy pays O(1) (or O(period)) per bar instead of rescanning its
+// history, and the values match the standard definitions (TA-Lib,
+// StockCharts) from the first bar they are defined on.
+//
+// Every indicator reports Ready once it has seen enough bars; until then
+// Update returns zeros (RSI and Stochastic return the neutral 50).
+// Constructors panic on a period below 1, like a bad regexp.MustCompile,
+// since periods are fixed by the strategy's code or validated config.
+package indicators
@@ -0,0 +1,98 @@
+package indicators
+
+import "fmt"
+
+// SMA is the simple moving average of the last Period values.
+type SMA struct {
+	window *ring
+	sum    float64
+}
+
+func NewSMA(period int) *SMA {
+	mustPeriod("SMA", period)
+	return &SMA{window: newRing(period)}
+}
+
+// Update adds x and returns the average, 0 until Ready.
+func (s *SMA) Update(x float64) float64 {
+	if old, full := s.window.push(x); full {
+		s.sum -= old
+	}
+	s.sum += x
+	return s.Value()
+}
+
+func (s *SMA) Ready() bool { return s.window.full() }
+
+// Value is the current average, 0 until Ready.
+func (s *SMA) Value() float64 {
+	if !s.Ready() {
+		return 0
+	}
+	return s.sum / float64(len(s.window.buf))
+}
+
+// ring holds the last len(buf) values pushed.
+type ring struct {
+	buf   []float64
+	next  int
+	count int
+}
+
+func newRing(n int) *ring { return &ring{buf: make([]float64, n)} }
+
+// push adds x, returning the value it evicted once the ring is full.
+func (r *ring) push(x float64) (evicted float64, full bool) {
+	evicted, full = r.buf[r.next], r.full()
+	r.buf[r.next] = x
+	r.next = (r.next + 1) % len(r.buf)
+	if !full {
+		r.count++
+	}
+	return evicted, full
+}
+
+func (r *ring) full() bool { return r.count == len(r.buf) }
+
+// each calls f on the values held, in no particular order.
+func (r *ring) each(f func(float64)) {
+	for _, x := range r.buf[:r.count] {
+		f(x)
+	}
+}
+
+// EMA is the exponential moving average with smoothing 2/(Period+1). It
+// is seeded with the SMA of its first Period values, so it is Ready,
+// and agrees with TA-Lib, from the Period-th value on.
+type EMA struct {
+	period int
+	alpha  float64
+	seed   *SMA
+	value  float64
+}
+
+func NewEMA(period int) *EMA {
+	mustPeriod("EMA", period)
+	return &EMA{period: period, alpha: 2 / float64(period+1), seed: NewSMA(period)}
+}
+
+// Update adds x and returns the average, 0 until Ready.
+func (e *EMA) Update(x float64) float64 {
+	if !e.seed.Ready() {
+		e.value = e.seed.Update(x)
+		return e.value
+	}
+	e.value += e.alpha * (x - e.value)
+	return e.value
+}
+
+func (e *EMA) Ready() bool { return e.seed.Ready() }
+
+// Value is the current average, 0 until Ready.
+func (e *EMA) Value() float64 { return e.value }
+
+func mustPeriod(name string, period int) {
+	if period < 1 {
+		panic(fmt.Sprintf("indicators: %s period %d: must be at least 1", name, period))
+	}
+}
@@ -0,0 +1,53 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSMA(t *testing.T) {
+	s := NewSMA(3)
+	for i, want := range []float64{0, 0, 2, 3, 4} {
+		if got := s.Update(float64(i + 1)); got != want {
+			t.Errorf("update %d = %v, want %v", i, got, want)
+		}
+	}
+	if !s.Ready() {
+		t.Error("not ready after 5 values")
+	}
+}
+
+// StockCharts' 10-day EMA worked example, rounded as published.
+func TestEMA_Reference(t *testing.T) {
+	closes := []float64{
+		22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+		22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+		23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17,
+	}
+	want := []float64{
+		22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28,
+		23.34, 23.43, 23.51, 23.53, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92,
+	}
+	e := NewEMA(10)
+	for i, c := range closes {
+		got := e.Update(c)
+		if i < 9 {
+			if got != 0 || e.Ready() {
+				t.Fatalf("close %d: EMA %v before 10 values", i, got)
+			}
+			continue
+		}
+		if w := want[i-9]; math.Abs(got-w) > 0.005 {
+			t.Errorf("close %d: EMA %.4f, want %.2f", i, got, w)
+		}
+	}
+}
+
+func TestNewSMA_BadPeriod(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSMA(0) did not panic")
+		}
+	}()
+	NewSMA(0)
+}
@@ -0,0 +1,128 @@
+package indicators
+
+import "math"
+
+// RSI is Wilder's relative strength index of closes: average gains and
+// losses over the first Period changes are simple means, and after that
+// each is smoothed as avg = (avg*(Period-1) + change) / Period. It is
+// Ready after Period+1 closes.
+type RSI struct {
+	period     int
+	prev       float64
+	closes     int
+	gain, loss float64 // sums while seeding, then Wilder averages
+	value      float64
+}
+
+func NewRSI(period int) *RSI {
+	mustPeriod("RSI", period)
+	return &RSI{period: period, value: 50}
+}
+
+// Update adds a close and returns the RSI, 50 until Ready. With no
+// losses over the averaging it is 100, and with flat prices 50.
+func (r *RSI) Update(close float64) float64 {
+	r.closes++
+	if r.closes == 1 {
+		r.prev = close
+		return r.value
+	}
+	change := close - r.prev
+	r.prev = close
+	gain, loss := 0.0, 0.0
+	if change > 0 {
+		gain = change
+	} else {
+		loss = -change
+	}
+	n := float64(r.period)
+	switch changes := r.closes - 1; {
+	case changes < r.period:
+		r.gain += gain
+		r.loss += loss
+		return r.value
+	case changes == r.period:
+		r.gain = (r.gain + gain) / n
+		r.loss = (r.loss + loss) / n
+	default:
+		r.gain = (r.gain*(n-1) + gain) / n
+		r.loss = (r.loss*(n-1) + loss) / n
+	}
+	switch {
+	case r.loss == 0 && r.gain == 0:
+		r.value = 50
+	case r.loss == 0:
+		r.value = 100
+	default:
+		r.value = 100 - 100/(1+r.gain/r.loss)
+	}
+	return r.value
+}
+
+func (r *RSI) Ready() bool { return r.closes > r.period }
+
+// Value is the current RSI, 50 until Ready.
+func (r *RSI) Value() float64 { return r.value }
+
+// MACD is the moving average convergence/divergence of closes: the Fast
+// EMA less the Slow one, its Signal-period EMA, and their difference, the
+// histogram. The signal line starts once the slow EMA is Ready, so MACD
+// is Ready after Slow+Signal-1 closes. The usual periods are 12, 26, 9.
+type MACD struct {
+	fast, slow, signal *EMA
+}
+
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+// Update adds a close and returns the MACD line, signal line and
+// histogram. The line is 0 until the slow EMA is Ready and the signal
+// and histogram until the MACD is.
+func (m *MACD) Update(close float64) (line, signal, hist float64) {
+	f, s := m.fast.Update(close), m.slow.Update(close)
+	if !m.slow.Ready() {
+		return 0, 0, 0
+	}
+	line = f - s
+	signal = m.signal.Update(line)
+	if !m.signal.Ready() {
+		return line, 0, 0
+	}
+	return line, signal, line - signal
+}
+
+func (m *MACD) Ready() bool { return m.signal.Ready() }
+
+// Stochastic is the stochastic oscillator: %K places the close within the
+// high-low range of the last K bars, from 0 at the low to 100 at the
+// high, and %D is the D-bar SMA of %K. It is Ready after K+D-1 bars.
+type Stochastic struct {
+	highs, lows *ring // the last K highs and lows
+	d           *SMA
+}
+
+func NewStochastic(k, d int) *Stochastic {
+	mustPeriod("Stochastic %K", k)
+	return &Stochastic{highs: newRing(k), lows: newRing(k), d: NewSMA(d)}
+}
+
+// Update adds a bar and returns %K and %D. %K is 50 until K bars have
+// been seen and when the range is flat; %D is 0 until Ready.
+func (s *Stochastic) Update(high, low, close float64) (k, d float64) {
+	s.highs.push(high)
+	s.lows.push(low)
+	if !s.highs.full() {
+		return 50, 0
+	}
+	hh, ll := high, low
+	s.highs.each(func(h float64) { hh = math.Max(hh, h) })
+	s.lows.each(func(l float64) { ll = math.Min(ll, l) })
+	k = 50
+	if hh > ll {
+		k = 100 * (close - ll) / (hh - ll)
+	}
+	return k, s.d.Update(k)
+}
+
+func (s *Stochastic) Ready() bool { return s.d.Ready() }
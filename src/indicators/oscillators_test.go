@@ -0,0 +1,80 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+// StockCharts' 14-day RSI worked example, from its spreadsheet's
+// unrounded closes.
+func TestRSI_Reference(t *testing.T) {
+	closes := []float64{
+		44.3389, 44.0902, 44.1497, 43.6124, 44.2778, 44.8264, 45.0955, 45.4245, 45.8433, 46.0826,
+		45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028, 46.0328, 46.4116, 46.2222, 45.6439,
+	}
+	want := []float64{70.53, 66.32, 66.55, 69.41, 66.36, 57.97}
+	r := NewRSI(14)
+	for i, c := range closes {
+		got := r.Update(c)
+		if i < 14 {
+			if got != 50 || r.Ready() {
+				t.Fatalf("close %d: RSI %v before 15 closes", i, got)
+			}
+			continue
+		}
+		if w := want[i-14]; math.Abs(got-w) > 0.005 {
+			t.Errorf("close %d: RSI %.4f, want %.2f", i, got, w)
+		}
+	}
+}
+
+func TestRSI_Edges(t *testing.T) {
+	flat, up := NewRSI(2), NewRSI(2)
+	for i := 0; i < 4; i++ {
+		flat.Update(10)
+		up.Update(float64(10 + i))
+	}
+	if flat.Value() != 50 || up.Value() != 100 {
+		t.Errorf("flat %v, rising %v; want 50, 100", flat.Value(), up.Value())
+	}
+}
+
+// The MACD line is the difference of the two EMAs and the signal line
+// an EMA of it, started once the slow EMA is ready.
+func TestMACD(t *testing.T) {
+	m := NewMACD(2, 3, 2)
+	fast, slow, signal := NewEMA(2), NewEMA(3), NewEMA(2)
+	for i, c := range []float64{10, 11, 13, 12, 15, 14, 16} {
+		line, sig, hist := m.Update(c)
+		f, s := fast.Update(c), slow.Update(c)
+		if i < 2 {
+			if line != 0 || sig != 0 || hist != 0 {
+				t.Errorf("close %d: %v %v %v before the slow EMA", i, line, sig, hist)
+			}
+			continue
+		}
+		wantSig := signal.Update(f - s)
+		if math.Abs(line-(f-s)) > 1e-12 || math.Abs(sig-wantSig) > 1e-12 ||
+			math.Abs(hist-(line-sig)) > 1e-12 && i > 2 {
+			t.Errorf("close %d: %v %v %v", i, line, sig, hist)
+		}
+		if (i >= 3) != m.Ready() {
+			t.Errorf("close %d: Ready = %v", i, m.Ready())
+		}
+	}
+}
+
+func TestStochastic(t *testing.T) {
+	s := NewStochastic(3, 2)
+	bars := [][3]float64{{10, 8, 9}, {12, 9, 11}, {11, 7, 8}, {13, 10, 13}}
+	want := [][2]float64{{50, 0}, {50, 0}, {20, 0}, {100, 60}}
+	for i, b := range bars {
+		k, d := s.Update(b[0], b[1], b[2])
+		if math.Abs(k-want[i][0]) > 1e-12 || math.Abs(d-want[i][1]) > 1e-12 {
+			t.Errorf("bar %d: %%K %v %%D %v, want %v", i, k, d, want[i])
+		}
+	}
+	if !s.Ready() {
+		t.Error("not ready after K+D-1 bars")
+	}
+}
@@ -0,0 +1,101 @@
+package indicators
+
+import "math"
+
+// Bollinger is Bollinger Bands: the Period-bar SMA of closes with bands
+// K population standard deviations above and below it. The usual
+// parameters are 20 and 2.
+type Bollinger struct {
+	sma *SMA
+	k   float64
+}
+
+func NewBollinger(period int, k float64) *Bollinger {
+	return &Bollinger{sma: NewSMA(period), k: k}
+}
+
+// Update adds a close and returns the middle, upper and lower bands, all
+// 0 until Ready.
+func (b *Bollinger) Update(close float64) (middle, upper, lower float64) {
+	middle = b.sma.Update(close)
+	if !b.sma.Ready() {
+		return 0, 0, 0
+	}
+	// Deviations from the mean rather than a running sum of squares,
+	// which cancels badly at stock-price magnitudes.
+	ss := 0.0
+	b.sma.window.each(func(x float64) { ss += (x - middle) * (x - middle) })
+	width := b.k * math.Sqrt(ss/float64(len(b.sma.window.buf)))
+	return middle, middle + width, middle - width
+}
+
+func (b *Bollinger) Ready() bool { return b.sma.Ready() }
+
+// ZScore is how many population standard deviations a close sits from
+// the mean of the last Period closes, itself included.
+type ZScore struct {
+	bands *Bollinger
+}
+
+func NewZScore(period int) *ZScore {
+	return &ZScore{bands: NewBollinger(period, 1)}
+}
+
+// Update adds a close and returns its z-score, 0 until Ready and while
+// the closes are flat.
+func (z *ZScore) Update(close float64) float64 {
+	mid, up, _ := z.bands.Update(close)
+	if sd := up - mid; sd > 0 {
+		return (close - mid) / sd
+	}
+	return 0
+}
+
+func (z *ZScore) Ready() bool { return z.bands.Ready() }
+
+// ATR is Wilder's average true range. A bar's true range is its high less
+// its low, widened to reach the previous close across a gap; the first
+// ATR is the mean of the first Period true ranges, and after that
+// atr = (atr*(Period-1) + tr) / Period. It is Ready after Period bars.
+type ATR struct {
+	period int
+	prev   float64 // previous close
+	bars   int
+	value  float64 // sum of true ranges while seeding
+}
+
+func NewATR(period int) *ATR {
+	mustPeriod("ATR", period)
+	return &ATR{period: period}
+}
+
+// Update adds a bar and returns the ATR, 0 until Ready.
+func (a *ATR) Update(high, low, close float64) float64 {
+	tr := high - low
+	if a.bars > 0 {
+		tr = math.Max(tr, math.Max(math.Abs(high-a.prev), math.Abs(low-a.prev)))
+	}
+	a.prev = close
+	a.bars++
+	n := float64(a.period)
+	switch {
+	case a.bars < a.period:
+		a.value += tr
+		return 0
+	case a.bars == a.period:
+		a.value = (a.value + tr) / n
+	default:
+		a.value = (a.value*(n-1) + tr) / n
+	}
+	return a.value
+}
+
+func (a *ATR) Ready() bool { return a.bars >= a.period }
+
+// Value is the current ATR, 0 until Ready.
+func (a *ATR) Value() float64 {
+	if !a.Ready() {
+		return 0
+	}
+	return a.value
+}
@@ -0,0 +1,54 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBollinger(t *testing.T) {
+	b := NewBollinger(8, 2)
+	var mid, up, lo float64
+	for _, c := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		mid, up, lo = b.Update(c)
+	}
+	// Mean 5, population standard deviation 2.
+	if mid != 5 || math.Abs(up-9) > 1e-12 || math.Abs(lo-1) > 1e-12 {
+		t.Errorf("bands = %v %v %v, want 5 9 1", mid, up, lo)
+	}
+	// Large prices don't lose the spread to cancellation.
+	b = NewBollinger(2, 1)
+	b.Update(1e9 + 1)
+	if mid, up, _ := b.Update(1e9 + 3); mid != 1e9+2 || up != 1e9+3 {
+		t.Errorf("bands at 1e9 = %v %v", mid, up)
+	}
+}
+
+func TestATR(t *testing.T) {
+	a := NewATR(3)
+	bars := [][3]float64{{10, 8, 9}, {11, 9, 10}, {12, 10, 11}, {15, 13, 14}, {13, 9, 10}}
+	// The fourth bar gaps up from 11 (true range 4) and the fifth gaps
+	// back below 14 (5).
+	want := []float64{0, 0, 2, 8.0 / 3, 31.0 / 9}
+	for i, b := range bars {
+		if got := a.Update(b[0], b[1], b[2]); math.Abs(got-want[i]) > 1e-12 {
+			t.Errorf("bar %d: ATR %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestZScore(t *testing.T) {
+	z := NewZScore(8)
+	var got float64
+	for _, c := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		got = z.Update(c)
+	}
+	// Mean 5, standard deviation 2.
+	if !z.Ready() || math.Abs(got-2) > 1e-12 {
+		t.Errorf("z = %v, want 2", got)
+	}
+	flat := NewZScore(2)
+	flat.Update(3)
+	if got := flat.Update(3); got != 0 {
+		t.Errorf("flat z = %v, want 0", got)
+	}
+}
@@ -0,0 +1,234 @@
+// Package logging sets up the backtester's structured logs. Packages log
+// through a per-component *slog.Logger from For; Setup decides, for the
+// whole process, the level, the text or JSON encoding, and where each
+// component's records go: its own file if one is named for it, the main
+// output otherwise. Loggers made before Setup follow it, so packages can
+// hold theirs in variables.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Config is how Setup routes records.
+type Config struct {
+	// Level is the least severe level written.
+	Level slog.Level
+	// Format is "text" (the default) or "json".
+	Format string
+	// Output receives the records of components without a file;
+	// os.Stderr if nil.
+	Output io.Writer
+	// Files maps components to the files their records are written to
+	// instead of Output, e.g. "transactions": "transactions.log".
+	// Components may share a file. Files are truncated when opened.
+	Files map[string]string
+}
+
+// router is one Setup's handlers.
+type router struct {
+	level      slog.Level
+	main       slog.Handler
+	components map[string]slog.Handler // with their file, if any
+	mu         sync.Mutex
+	files      []*os.File
+}
+
+var current atomic.Pointer[router]
+
+// out is the writer the main handler writes to, swapped by SetOutput.
+var out = &swapWriter{w: os.Stderr}
+
+func init() {
+	current.Store(newRouter(slog.LevelInfo, "text", nil))
+}
+
+func newRouter(level slog.Level, format string, files map[string]io.Writer) *router {
+	r := &router{level: level, components: make(map[string]slog.Handler)}
+	newHandler := func(w io.Writer) slog.Handler {
+		opts := &slog.HandlerOptions{Level: level}
+		if format == "json" {
+			return slog.NewJSONHandler(w, opts)
+		}
+		return slog.NewTextHandler(w, opts)
+	}
+	r.main = newHandler(out)
+	for component, w := range files {
+		r.components[component] = newHandler(w)
+	}
+	return r
+}
+
+// handler is component's handler, tagged with its name.
+func (r *router) handler(component string) slog.Handler {
+	h, ok := r.components[component]
+	if !ok {
+		h = r.main
+	}
+	return h.WithAttrs([]slog.Attr{slog.String("component", component)})
+}
+
+// Setup routes every logger's records per cfg from now on, closing the
+// files of any earlier Setup, and makes the main handler slog's default,
+// so the standard log package writes through it at info level too.
+func Setup(cfg Config) error {
+	switch cfg.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("log format %q: must be text or json", cfg.Format)
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stderr
+	}
+	opened := make(map[string]*os.File)
+	files := make(map[string]io.Writer, len(cfg.Files))
+	for component, path := range cfg.Files {
+		f, ok := opened[path]
+		if !ok {
+			var err error
+			if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666); err != nil {
+				for _, f := range opened {
+					f.Close()
+				}
+				return fmt.Errorf("log file for %s: %w", component, err)
+			}
+			opened[path] = f
+		}
+		files[component] = f
+	}
+	out.Swap(cfg.Output)
+	r := newRouter(cfg.Level, cfg.Format, files)
+	for _, f := range opened {
+		r.files = append(r.files, f)
+	}
+	prev := current.Swap(r)
+	slog.SetDefault(slog.New(r.main))
+	prev.close()
+	return nil
+}
+
+func (r *router) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.files {
+		f.Close()
+	}
+	r.files = nil
+}
+
+// SetOutput replaces the main output, returning the one it replaces;
+// component files are unaffected. The terminal UI holds records back
+// this way while it owns the screen.
+func SetOutput(w io.Writer) io.Writer { return out.Swap(w) }
+
+// For returns component's logger.
+func For(component string) *slog.Logger {
+	return slog.New(&lazyHandler{component: component})
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("log level %q: must be debug, info, warn or error", s)
+	}
+	return l, nil
+}
+
+// ParseFiles parses a comma-separated list of component=path pairs,
+// e.g. "transactions=transactions.log,data=data.log".
+func ParseFiles(s string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		component, path, ok := strings.Cut(pair, "=")
+		if !ok || component == "" || path == "" {
+			return nil, fmt.Errorf("log file %q: want component=path", pair)
+		}
+		files[component] = path
+	}
+	return files, nil
+}
+
+// Components are the components the backtester's packages log under:
+// a portfolio's simulation, runs as a whole (data loading, output), the
+// trade records, queries, and the run API.
+var Components = []string{"backtest", "runner", "transactions", "data", "api"}
+
+// lazyHandler sends a component's records to the current router's
+// handler for it, rebuilding its attributes and groups on that handler
+// after each Setup.
+type lazyHandler struct {
+	component string
+	ops       []func(slog.Handler) slog.Handler
+	cache     atomic.Pointer[resolved]
+}
+
+type resolved struct {
+	r *router
+	h slog.Handler
+}
+
+func (h *lazyHandler) target() slog.Handler {
+	r := current.Load()
+	if c := h.cache.Load(); c != nil && c.r == r {
+		return c.h
+	}
+	t := r.handler(h.component)
+	for _, op := range h.ops {
+		t = op(t)
+	}
+	h.cache.Store(&resolved{r, t})
+	return t
+}
+
+func (h *lazyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().level
+}
+
+func (h *lazyHandler) Handle(ctx context.Context, rec slog.Record) error {
+	return h.target().Handle(ctx, rec)
+}
+
+func (h *lazyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(t slog.Handler) slog.Handler { return t.WithAttrs(attrs) })
+}
+
+func (h *lazyHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(t slog.Handler) slog.Handler { return t.WithGroup(name) })
+}
+
+func (h *lazyHandler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := append(append([]func(slog.Handler) slog.Handler(nil), h.ops...), op)
+	return &lazyHandler{component: h.component, ops: ops}
+}
+
+// swapWriter is an io.Writer whose destination can be replaced while
+// handlers write to it.
+type swapWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *swapWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *swapWriter) Swap(w io.Writer) io.Writer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.w
+	s.w = w
+	return prev
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	defer Setup(Config{})
+	// Made before Setup, as package variables are.
+	tx := For("transactions").With("portfolio", "p")
+	data := For("data")
+
+	var out bytes.Buffer
+	txPath := filepath.Join(t.TempDir(), "tx.log")
+	if err := Setup(Config{Level: slog.LevelInfo, Format: "json", Output: &out,
+		Files: map[string]string{"transactions": txPath}}); err != nil {
+		t.Fatal(err)
+	}
+	data.Debug("query", "ticker", "A")
+	data.Warn("query", "ticker", "B")
+	tx.Warn("BUY", "ticker", "A")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("main output = %q", out.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["level"] != "WARN" || rec["component"] != "data" || rec["ticker"] != "B" {
+		t.Errorf("record = %v", rec)
+	}
+
+	// A second Setup closes the first one's file and reroutes the same loggers.
+	out.Reset()
+	if err := Setup(Config{Level: slog.LevelDebug, Output: &out}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(txPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"msg":"BUY","component":"transactions","portfolio":"p"`) {
+		t.Errorf("transactions file = %s", got)
+	}
+	tx.Debug("SELL")
+	if !strings.Contains(out.String(), "level=DEBUG msg=SELL component=transactions portfolio=p") {
+		t.Errorf("main output = %q", out.String())
+	}
+
+	if err := Setup(Config{Format: "xml"}); err == nil {
+		t.Error("xml format accepted")
+	}
+}
+
+func TestParse(t *testing.T) {
+	if l, err := ParseLevel("warn"); err != nil || l != slog.LevelWarn {
+		t.Errorf("ParseLevel(warn) = %v, %v", l, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud) succeeded")
+	}
+	files, err := ParseFiles("transactions=tx.log, data=data.log")
+	if err != nil || len(files) != 2 || files["data"] != "data.log" {
+		t.Errorf("ParseFiles = %v, %v", files, err)
+	}
+	if _, err := ParseFiles("data"); err == nil {
+		t.Error("ParseFiles(data) succeeded")
+	}
+}
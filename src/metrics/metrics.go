@@ -0,0 +1,239 @@
+// Package metrics computes performance statistics from plain return and
+// value series: annualized return, Sharpe and Sortino ratios, drawdown,
+// the Calmar ratio and value at risk, with risk-free rates aligned by
+// calendar day. It knows nothing of portfolios, so a program can score
+// its own series exactly as the backtest package scores a run.
+package metrics
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// AnnualReturn is the CAGR, in percent, of compounding dailyAvg over
+// len(dailyAvg)/periodsPerYear years. An empty series is 0; a series
+// that loses everything is -100.
+func AnnualReturn(dailyAvg []float64, periodsPerYear float64) float64 {
+	if len(dailyAvg) == 0 {
+		return 0
+	}
+	startValue := 1.0
+
+	for i := range dailyAvg {
+		startValue *= (1 + dailyAvg[i])
+	}
+	if startValue <= 0 {
+		return -100
+	}
+	numYears := float64(len(dailyAvg)) / periodsPerYear
+	// Compound Annual Growth Rate - (end/start) ^ 1/n - 1
+	CAGR := math.Pow(startValue, 1/numYears) - 1
+	return CAGR * 100
+}
+
+// Sharpe is the mean of excessReturns over their standard deviation,
+// annualized by periodsPerYear.
+func Sharpe(excessReturns []float64, periodsPerYear float64) float64 {
+	// Fewer than two returns, or a constant series, has no volatility to
+	// scale by; report 0 rather than NaN or ±Inf.
+	if len(excessReturns) < 2 {
+		return 0.0
+	}
+	excessStdev := stat.StdDev(excessReturns, nil)
+	if excessStdev == 0 || math.IsNaN(excessStdev) {
+		return 0.0
+	}
+	ratio := stat.Mean(excessReturns, nil) / excessStdev
+	annualizedSharpe := ratio * math.Sqrt(periodsPerYear)
+	return annualizedSharpe
+}
+
+// Sortino is the mean of excessReturns over the standard deviation of
+// the negative ones, annualized by periodsPerYear.
+func Sortino(excessReturns []float64, periodsPerYear float64) float64 {
+	downsideReturns := make([]float64, 0)
+	for _, excessReturn := range excessReturns {
+		if excessReturn < 0 {
+			downsideReturns = append(downsideReturns, excessReturn)
+		}
+	}
+
+	// The sample stddev needs two downside returns; with fewer, or with
+	// all of them equal, the ratio is undefined and reported as 0.
+	if len(downsideReturns) < 2 {
+		return 0.0
+	}
+
+	averageExcessReturn := stat.Mean(excessReturns, nil)
+	downsideDeviation := stat.StdDev(downsideReturns, nil)
+
+	if downsideDeviation == 0 || math.IsNaN(downsideDeviation) {
+		return 0.0 // Avoid division by zero
+	}
+
+	ratio := averageExcessReturn / downsideDeviation
+	// Annualize
+	annualizedSortino := ratio * math.Sqrt(periodsPerYear)
+	return annualizedSortino
+}
+
+// ExcessReturns returns dailyAvg minus the risk-free rate for each
+// day, in date order, and how many days' rates were filled (see
+// AlignRiskFree). Iterating the maps directly would sum in random order
+// and make the float results vary from run to run.
+func ExcessReturns(
+	riskFreeRates map[int64]float64,
+	dailyAvg map[int64]float64,
+) ([]float64, int) {
+	days := make([]int64, 0, len(dailyAvg))
+	for day := range dailyAvg {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	rates, filled := AlignRiskFree(riskFreeRates, days)
+	if rates == nil {
+		return []float64{}, 0
+	}
+	excessReturns := make([]float64, len(days))
+	for i, day := range days {
+		excessReturns[i] = dailyAvg[day] - rates[i]
+	}
+	return excessReturns, filled
+}
+
+// AlignRiskFree maps each of days (Unix seconds, ascending) onto the
+// risk-free series by calendar day: a day uses the rate published that
+// day, else the latest earlier rate (forward fill over holidays and data
+// gaps), else — before the first published rate — the first one. It
+// returns the rates and how many days were filled, or nil if there are
+// no rates at all.
+func AlignRiskFree(riskFreeRates map[int64]float64, days []int64) ([]float64, int) {
+	if len(riskFreeRates) == 0 {
+		return nil, 0
+	}
+	byDay := make(map[int64]float64, len(riskFreeRates))
+	published := make([]int64, 0, len(riskFreeRates))
+	for ts, rate := range riskFreeRates {
+		d := calendarDay(ts)
+		if _, dup := byDay[d]; !dup {
+			published = append(published, d)
+		}
+		byDay[d] = rate
+	}
+	sort.Slice(published, func(i, j int) bool { return published[i] < published[j] })
+
+	rates := make([]float64, len(days))
+	filled := 0
+	for i, ts := range days {
+		d := calendarDay(ts)
+		if rate, ok := byDay[d]; ok {
+			rates[i] = rate
+			continue
+		}
+		filled++
+		// Index of the first published day after d; the one before it is
+		// the latest rate in force.
+		j := sort.Search(len(published), func(k int) bool { return published[k] > d })
+		if j == 0 {
+			rates[i] = byDay[published[0]]
+		} else {
+			rates[i] = byDay[published[j-1]]
+		}
+	}
+	return rates, filled
+}
+
+// calendarDay numbers the UTC calendar day of a Unix timestamp, so bars
+// and rates stamped at different times of day still line up.
+func calendarDay(unix int64) int64 {
+	d := unix / 86400
+	if unix%86400 < 0 {
+		d--
+	}
+	return d
+}
+
+// MaxDrawdown is the largest peak-to-trough fall of
+// portfolioCloseValues, in percent. It is 0 for an empty series, and
+// drawdowns are only measured once a positive peak has been seen.
+func MaxDrawdown(portfolioCloseValues []float64) float64 {
+	if len(portfolioCloseValues) == 0 {
+		return 0.0
+	}
+	peak := portfolioCloseValues[0]
+	maxDrawdown := 0.0
+
+	for _, value := range portfolioCloseValues {
+		if value > peak {
+			peak = value
+		}
+		if peak <= 0 {
+			continue
+		}
+		drawdown := (peak - value) / peak
+		if drawdown > maxDrawdown {
+			maxDrawdown = drawdown
+		}
+	}
+
+	return maxDrawdown * 100
+}
+
+// Calmar is annual return over max drawdown, both in percent.
+func Calmar(annual, maxDrawdown float64) float64 {
+	if maxDrawdown == 0 {
+		return 0
+	}
+	return annual / maxDrawdown
+}
+
+// Growth is the value of 1 compounded over returns, one value per return.
+func Growth(returns []float64) []float64 {
+	out := make([]float64, len(returns))
+	v := 1.0
+	for i, r := range returns {
+		v *= 1 + r
+		out[i] = v
+	}
+	return out
+}
+
+// VaR is the one-day loss, in percent of portfolio value, not exceeded
+// with probability Confidence. Historical reads it off the daily returns;
+// Parametric assumes they are normal; CVaR (expected shortfall) is the
+// mean loss over the same worst days. Losses are positive.
+type VaR struct {
+	Confidence float64
+	Historical float64
+	Parametric float64
+	CVaR       float64
+}
+
+// ValueAtRisk computes VaR at confidence from daily returns. Fewer than
+// two returns leave it 0.
+func ValueAtRisk(returns []float64, confidence float64) VaR {
+	v := VaR{Confidence: confidence}
+	if len(returns) < 2 {
+		return v
+	}
+	sorted := append([]float64(nil), returns...)
+	sort.Float64s(sorted)
+	// The tail is the worst (1-confidence) of days, at least one. The
+	// epsilon keeps e.g. 1-0.95 from rounding up to an extra day.
+	n := int(math.Ceil((1-confidence)*float64(len(sorted)) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	v.Historical = -sorted[n-1] * 100
+	v.CVaR = -stat.Mean(sorted[:n], nil) * 100
+
+	mean, sd := stat.MeanStdDev(returns, nil)
+	if sd > 0 {
+		mean = distuv.Normal{Mu: mean, Sigma: sd}.Quantile(1 - confidence)
+	}
+	v.Parametric = -mean * 100
+	return v
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want))
+}
+
+func TestAlignRiskFree_FillsGaps(t *testing.T) {
+	day := func(d int) int64 { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC).Unix() }
+	// Rates are stamped mid-day; bars at midnight must still match them.
+	at := func(d int) int64 { return day(d) + 12*3600 }
+	rf := map[int64]float64{at(3): 0.3, at(4): 0.4, at(8): 0.8}
+	days := []int64{day(2), day(3), day(4), day(5), day(6), day(8), day(9)}
+
+	rates, filled := AlignRiskFree(rf, days)
+	want := []float64{0.3, 0.3, 0.4, 0.4, 0.4, 0.8, 0.8}
+	for i := range want {
+		if rates[i] != want[i] {
+			t.Errorf("rate on day %d = %v, want %v", i, rates[i], want[i])
+		}
+	}
+	if filled != 4 {
+		t.Errorf("filled = %d, want 4 (days 2, 5, 6 and 9)", filled)
+	}
+	if rates, _ := AlignRiskFree(nil, days); rates != nil {
+		t.Errorf("no rates at all gave %v, want nil", rates)
+	}
+}
+
+func TestValueAtRisk(t *testing.T) {
+	returns := make([]float64, 20) // -5% .. +14%
+	for i := range returns {
+		returns[i] = float64(i-5) / 100
+	}
+	v := ValueAtRisk(returns, 0.95)
+	if !closeTo(v.Historical, 5) || !closeTo(v.CVaR, 5) {
+		t.Errorf("95%%: %+v, want historical and CVaR 5", v)
+	}
+	mean, sd := 0.045, math.Sqrt(35)/100
+	if want := -(mean - 1.6448536269514722*sd) * 100; math.Abs(v.Parametric-want) > 1e-9 {
+		t.Errorf("parametric = %v, want %v", v.Parametric, want)
+	}
+	if v := ValueAtRisk(returns, 0.9); !closeTo(v.Historical, 4) || !closeTo(v.CVaR, 4.5) {
+		t.Errorf("90%%: %+v, want historical 4 and CVaR 4.5", v)
+	}
+	if v := ValueAtRisk(returns[:1], 0.95); v != (VaR{Confidence: 0.95}) {
+		t.Errorf("one return: %+v, want 0", v)
+	}
+
+}
@@ -0,0 +1,259 @@
+// The backtester's gRPC service: queue a config, follow the run, fetch
+// its results. It fronts the same queue as the JSON API (see src/api).
+//
+// Regenerate the Go code from src/ after editing:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative rpc/pb/backtester.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: rpc/pb/backtester.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Backtester_RunBacktest_FullMethodName    = "/backtester.v1.Backtester/RunBacktest"
+	Backtester_StreamProgress_FullMethodName = "/backtester.v1.Backtester/StreamProgress"
+	Backtester_GetResults_FullMethodName     = "/backtester.v1.Backtester/GetResults"
+	Backtester_CancelRun_FullMethodName      = "/backtester.v1.Backtester/CancelRun"
+)
+
+// BacktesterClient is the client API for Backtester service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BacktesterClient interface {
+	// RunBacktest queues a config and returns at once with its run's id.
+	RunBacktest(ctx context.Context, in *RunBacktestRequest, opts ...grpc.CallOption) (*RunBacktestResponse, error)
+	// StreamProgress sends a run's status now, whenever it changes and
+	// every second while it runs, ending once the run has finished.
+	StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunStatus], error)
+	// GetResults returns a finished run's results; FAILED_PRECONDITION
+	// while it is queued or running.
+	GetResults(ctx context.Context, in *GetResultsRequest, opts ...grpc.CallOption) (*GetResultsResponse, error)
+	// CancelRun cancels a queued or running run.
+	CancelRun(ctx context.Context, in *CancelRunRequest, opts ...grpc.CallOption) (*RunStatus, error)
+}
+
+type backtesterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBacktesterClient(cc grpc.ClientConnInterface) BacktesterClient {
+	return &backtesterClient{cc}
+}
+
+func (c *backtesterClient) RunBacktest(ctx context.Context, in *RunBacktestRequest, opts ...grpc.CallOption) (*RunBacktestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunBacktestResponse)
+	err := c.cc.Invoke(ctx, Backtester_RunBacktest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *backtesterClient) StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Backtester_ServiceDesc.Streams[0], Backtester_StreamProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamProgressRequest, RunStatus]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Backtester_StreamProgressClient = grpc.ServerStreamingClient[RunStatus]
+
+func (c *backtesterClient) GetResults(ctx context.Context, in *GetResultsRequest, opts ...grpc.CallOption) (*GetResultsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResultsResponse)
+	err := c.cc.Invoke(ctx, Backtester_GetResults_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *backtesterClient) CancelRun(ctx context.Context, in *CancelRunRequest, opts ...grpc.CallOption) (*RunStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, Backtester_CancelRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BacktesterServer is the server API for Backtester service.
+// All implementations must embed UnimplementedBacktesterServer
+// for forward compatibility.
+type BacktesterServer interface {
+	// RunBacktest queues a config and returns at once with its run's id.
+	RunBacktest(context.Context, *RunBacktestRequest) (*RunBacktestResponse, error)
+	// StreamProgress sends a run's status now, whenever it changes and
+	// every second while it runs, ending once the run has finished.
+	StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[RunStatus]) error
+	// GetResults returns a finished run's results; FAILED_PRECONDITION
+	// while it is queued or running.
+	GetResults(context.Context, *GetResultsRequest) (*GetResultsResponse, error)
+	// CancelRun cancels a queued or running run.
+	CancelRun(context.Context, *CancelRunRequest) (*RunStatus, error)
+	mustEmbedUnimplementedBacktesterServer()
+}
+
+// UnimplementedBacktesterServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBacktesterServer struct{}
+
+func (UnimplementedBacktesterServer) RunBacktest(context.Context, *RunBacktestRequest) (*RunBacktestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunBacktest not implemented")
+}
+func (UnimplementedBacktesterServer) StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[RunStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamProgress not implemented")
+}
+func (UnimplementedBacktesterServer) GetResults(context.Context, *GetResultsRequest) (*GetResultsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetResults not implemented")
+}
+func (UnimplementedBacktesterServer) CancelRun(context.Context, *CancelRunRequest) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelRun not implemented")
+}
+func (UnimplementedBacktesterServer) mustEmbedUnimplementedBacktesterServer() {}
+func (UnimplementedBacktesterServer) testEmbeddedByValue()                    {}
+
+// UnsafeBacktesterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BacktesterServer will
+// result in compilation errors.
+type UnsafeBacktesterServer interface {
+	mustEmbedUnimplementedBacktesterServer()
+}
+
+func RegisterBacktesterServer(s grpc.ServiceRegistrar, srv BacktesterServer) {
+	// If the following call pancis, it indicates UnimplementedBacktesterServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Backtester_ServiceDesc, srv)
+}
+
+func _Backtester_RunBacktest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunBacktestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BacktesterServer).RunBacktest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Backtester_RunBacktest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BacktesterServer).RunBacktest(ctx, req.(*RunBacktestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Backtester_StreamProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BacktesterServer).StreamProgress(m, &grpc.GenericServerStream[StreamProgressRequest, RunStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Backtester_StreamProgressServer = grpc.ServerStreamingServer[RunStatus]
+
+func _Backtester_GetResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BacktesterServer).GetResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Backtester_GetResults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BacktesterServer).GetResults(ctx, req.(*GetResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Backtester_CancelRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BacktesterServer).CancelRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Backtester_CancelRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BacktesterServer).CancelRun(ctx, req.(*CancelRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Backtester_ServiceDesc is the grpc.ServiceDesc for Backtester service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Backtester_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "backtester.v1.Backtester",
+	HandlerType: (*BacktesterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RunBacktest",
+			Handler:    _Backtester_RunBacktest_Handler,
+		},
+		{
+			MethodName: "GetResults",
+			Handler:    _Backtester_GetResults_Handler,
+		},
+		{
+			MethodName: "CancelRun",
+			Handler:    _Backtester_CancelRun_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProgress",
+			Handler:       _Backtester_StreamProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rpc/pb/backtester.proto",
+}
@@ -0,0 +1,147 @@
+// Package rpc serves the Backtester gRPC service defined in
+// pb/backtester.proto over an api.Server's run queue, so Python or any
+// other gRPC client can drive a long-lived backtester, sharing its queue
+// with the JSON API.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"my-backtester/src/api"
+	"my-backtester/src/backtest"
+	"my-backtester/src/rpc/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Register adds the Backtester service, running configs on runs' queue,
+// to g.
+func Register(g *grpc.Server, runs *api.Server) {
+	pb.RegisterBacktesterServer(g, &server{runs: runs})
+}
+
+type server struct {
+	pb.UnimplementedBacktesterServer
+	runs *api.Server
+}
+
+func (s *server) RunBacktest(ctx context.Context, req *pb.RunBacktestRequest) (*pb.RunBacktestResponse, error) {
+	if len(req.Config) > api.MaxConfigBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "config exceeds %d bytes", api.MaxConfigBytes)
+	}
+	doc, err := s.runs.Submit(req.Config, req.Format)
+	if err != nil {
+		if !errors.Is(err, api.ErrQueueFull) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+	return &pb.RunBacktestResponse{RunId: doc.ID}, nil
+}
+
+func (s *server) StreamProgress(req *pb.StreamProgressRequest, stream grpc.ServerStreamingServer[pb.RunStatus]) error {
+	err := s.runs.Watch(stream.Context(), req.RunId, func(doc api.RunJSON) error {
+		return stream.Send(runStatus(doc))
+	})
+	return grpcError(err)
+}
+
+func (s *server) GetResults(ctx context.Context, req *pb.GetResultsRequest) (*pb.GetResultsResponse, error) {
+	results, err := s.runs.Results(req.RunId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	doc, err := s.runs.Run(req.RunId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.GetResultsResponse{Status: runStatus(doc)}
+	for _, r := range results {
+		resp.Results = append(resp.Results, result(r))
+	}
+	return resp, nil
+}
+
+func (s *server) CancelRun(ctx context.Context, req *pb.CancelRunRequest) (*pb.RunStatus, error) {
+	doc, err := s.runs.Cancel(req.RunId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return runStatus(doc), nil
+}
+
+// grpcError gives err, from an api.Server method, its gRPC status code.
+func grpcError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, api.ErrNoRun):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, api.ErrNotFinished):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, api.ErrQueueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.FromContextError(err).Err()
+}
+
+func runStatus(doc api.RunJSON) *pb.RunStatus {
+	st := &pb.RunStatus{
+		RunId:      doc.ID,
+		Status:     string(doc.Status),
+		Error:      doc.Error,
+		Portfolios: doc.Portfolios,
+	}
+	if p := doc.Progress; p != nil {
+		st.Done = int32(p.Done)
+		st.Total = int32(p.Total)
+		st.ElapsedSeconds = p.Elapsed.Seconds()
+		st.PerSecond = p.PerSecond
+		st.EtaSeconds = p.ETA.Seconds()
+	}
+	return st
+}
+
+func result(r backtest.Result) *pb.Result {
+	m := r.Metrics
+	out := &pb.Result{
+		Portfolio: r.PortfolioName,
+		Strategy:  r.Strategy,
+		Tickers:   r.Tickers,
+		Start:     r.Start,
+		End:       r.End,
+		Metrics: &pb.Metrics{
+			AnnualReturn:    m.AnnualReturn,
+			SharpeRatio:     m.SharpeRatio,
+			SortinoRatio:    m.SortinoRatio,
+			MaxDrawdown:     m.MaxDrawdown,
+			CalmarRatio:     m.CalmarRatio,
+			StandardDev:     m.StandardDev,
+			BenchmarkReturn: m.BenchmarkReturn,
+			Observations:    int32(m.Observations),
+			ClosedTrades:    int32(m.ClosedTrades),
+			WinRate:         m.WinRate,
+			ProfitFactor:    m.ProfitFactor,
+			Expectancy:      m.Expectancy,
+			AvgHoldingDays:  m.AvgHoldingDays,
+			MaxDrawdownDays: int32(m.MaxDrawdownDays),
+			RecoveryDays:    int32(m.RecoveryDays),
+		},
+		Dates:          r.Dates,
+		EquityCurve:    r.EquityCurve,
+		BenchmarkCurve: r.BenchmarkCurve,
+	}
+	for _, t := range r.Trades {
+		out.Trades = append(out.Trades, &pb.Trade{
+			Date:   t.Date.Format("2006-01-02"),
+			Ticker: t.Ticker,
+			Side:   t.Side,
+			Amount: t.Amount,
+			Price:  t.Price,
+			Fee:    t.Fee,
+		})
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"my-backtester/src/api"
+	"my-backtester/src/data"
+	"my-backtester/src/rpc/pb"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeStore struct{ bars []data.AssetData }
+
+func (f fakeStore) QueryAssetsForTickers(_ context.Context, tickers []string, start, end time.Time) map[string][]data.AssetData {
+	out := map[string][]data.AssetData{"A": append([]data.AssetData(nil), f.bars...)}
+	data.FillReturns(out["A"])
+	return out
+}
+
+func (fakeStore) GetRiskFreeRates(context.Context, time.Time, time.Time) map[int64]float64 {
+	return nil
+}
+
+func (fakeStore) QueryMacro(context.Context, string, time.Time) []data.MacroPoint { return nil }
+
+func TestService(t *testing.T) {
+	var store fakeStore
+	for i := range 30 {
+		px := 50 + float64(i)
+		store.bars = append(store.bars, data.AssetData{
+			Date: time.Date(2024, 3, 1+i, 0, 0, 0, 0, time.UTC),
+			Open: px, High: px, Low: px, Close: px, Volume: 100,
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	Register(g, api.New(ctx, store))
+	go g.Serve(lis)
+	defer g.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewBacktesterClient(conn)
+
+	if _, err := client.RunBacktest(ctx, &pb.RunBacktestRequest{Config: "nonsense = ["}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("bad config: %v", err)
+	}
+	run, err := client.RunBacktest(ctx, &pb.RunBacktestRequest{Config: `{"portfolio": [{"Name": "hold", "Tickers": ["A"],
+		"Strategy": "buyAndHold:greedy", "BuyingPower": 5000, "StartDate": "2024-01-01", "EndDate": "2024-12-31"}]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.StreamProgress(ctx, &pb.StreamProgressRequest{RunId: run.RunId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last *pb.RunStatus
+	for {
+		st, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = st
+	}
+	if last == nil || last.Status != "done" || len(last.Portfolios) != 1 {
+		t.Fatalf("last status = %v", last)
+	}
+
+	res, err := client.GetResults(ctx, &pb.GetResultsRequest{RunId: run.RunId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 {
+		t.Fatalf("results = %v", res.Results)
+	}
+	r := res.Results[0]
+	if r.Portfolio != "hold" || r.Metrics.AnnualReturn <= 0 || len(r.EquityCurve) != len(r.Dates) ||
+		len(r.Trades) == 0 || r.Trades[0].Side != "BUY" {
+		t.Errorf("result = %v", r)
+	}
+
+	if _, err := client.GetResults(ctx, &pb.GetResultsRequest{RunId: "9"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown run: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"my-backtester/src/backtest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tracker follows the portfolios of a run bar by bar through an engine
+// hook: each one's return so far, and the equity curve of the portfolio
+// started most recently. It is safe for the run's workers to share.
+type Tracker struct {
+	mu      sync.Mutex
+	entries []*entry
+	current *entry
+}
+
+type entry struct {
+	name, tickers string
+	first, last   float64
+	// equity is kept only while the entry is current.
+	equity []float64
+}
+
+// Standing is one portfolio's return so far.
+type Standing struct {
+	Portfolio string
+	Tickers   string
+	Return    float64
+}
+
+// Snapshot is what a Tracker has seen so far.
+type Snapshot struct {
+	// Top are the best returns so far, best first.
+	Top []Standing
+	// Current is the portfolio started most recently, and Equity its
+	// values so far.
+	Current string
+	Equity  []float64
+	// Tracked is how many portfolios have started.
+	Tracked int
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker { return &Tracker{} }
+
+// Hook is the EngineHook that feeds t; install it on every portfolio
+// with backtest.WithEngineHook.
+func (t *Tracker) Hook(e *backtest.Engine) {
+	p := e.Portfolio()
+	en := &entry{name: p.Pname, tickers: strings.Join(p.Tickers, ",")}
+	t.mu.Lock()
+	t.entries = append(t.entries, en)
+	if t.current != nil {
+		t.current.equity = nil
+	}
+	t.current = en
+	t.mu.Unlock()
+	// Added after the default pipeline, so the bar is marked to market.
+	e.OnBar(func(backtest.BarEvent) {
+		values := p.PortfolioCloseValues
+		if len(values) == 0 {
+			return
+		}
+		v := values[len(values)-1]
+		t.mu.Lock()
+		if en.first == 0 {
+			en.first = v
+		}
+		en.last = v
+		if t.current == en {
+			en.equity = append(en.equity, v)
+		}
+		t.mu.Unlock()
+	})
+}
+
+// Snapshot returns the top n returns so far and the current portfolio's
+// equity.
+func (t *Tracker) Snapshot(n int) Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := Snapshot{Tracked: len(t.entries)}
+	for _, en := range t.entries {
+		if en.first > 0 {
+			s.Top = append(s.Top, Standing{en.name, en.tickers, en.last/en.first - 1})
+		}
+	}
+	sort.SliceStable(s.Top, func(i, j int) bool { return s.Top[i].Return > s.Top[j].Return })
+	if len(s.Top) > n {
+		s.Top = s.Top[:n]
+	}
+	if t.current != nil {
+		s.Current = t.current.name
+		s.Equity = append([]float64(nil), t.current.equity...)
+	}
+	return s
+}
@@ -0,0 +1,224 @@
+// Package tui shows a run in the terminal as it happens: progress and
+// ETA, the best returns so far, and a sparkline of the equity of the
+// portfolio being simulated. The CLI uses it for run -tui.
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"my-backtester/src/backtest"
+	"my-backtester/src/logging"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RefreshInterval is how often the screen is redrawn, and TopN how many
+// of the best returns it lists.
+var (
+	RefreshInterval = 200 * time.Millisecond
+	TopN            = 10
+)
+
+// Run shows t's run on the terminal while run executes, and returns
+// what run returns. Quitting (q, esc or ctrl+c) cancels run's context
+// and waits for it to return. Log output is held back while the screen
+// is up and written once it closes.
+func Run(ctx context.Context, t *Tracker, run func(context.Context) ([]backtest.Result, error)) ([]backtest.Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	logs := &syncBuffer{}
+	out := log.Writer()
+	log.SetOutput(logs)
+	slogOut := logging.SetOutput(logs)
+	defer func() {
+		log.SetOutput(out)
+		logging.SetOutput(slogOut)
+		slogOut.Write(logs.Bytes())
+	}()
+
+	prog := tea.NewProgram(newModel(t), tea.WithContext(ctx))
+	var (
+		results []backtest.Result
+		err     error
+	)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		results, err = run(ctx)
+		prog.Send(doneMsg{err})
+	}()
+	// A run cancelled from outside kills the program; that's no error.
+	if _, perr := prog.Run(); perr != nil && !errors.Is(perr, tea.ErrProgramKilled) {
+		log.Printf("tui: %v", perr)
+	}
+	cancel()
+	<-finished
+	return results, err
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+type (
+	tickMsg struct{}
+	doneMsg struct{ err error }
+)
+
+type model struct {
+	tracker  *Tracker
+	snap     Snapshot
+	progress backtest.ProgressSnapshot
+	running  bool
+	width    int
+	err      error
+	done     bool
+}
+
+func newModel(t *Tracker) model { return model{tracker: t, width: 80} }
+
+func (m model) Init() tea.Cmd { return tick() }
+
+func tick() tea.Cmd {
+	return tea.Tick(RefreshInterval, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case tickMsg:
+		m.refresh()
+		return m, tick()
+	case doneMsg:
+		m.refresh()
+		m.done, m.err = true, msg.err
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m *model) refresh() {
+	m.snap = m.tracker.Snapshot(TopN)
+	m.progress, m.running = backtest.CurrentProgress()
+}
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true)
+	dimStyle   = lipgloss.NewStyle().Faint(true)
+	upStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	downStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+func (m model) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Backtest") + "\n\n")
+	barWidth := max(10, min(m.width, 100)-30)
+	switch {
+	case m.done && m.err != nil:
+		b.WriteString(downStyle.Render("Stopped: "+m.err.Error()) + "\n")
+	case m.done:
+		b.WriteString(fmt.Sprintf("Finished: %d portfolios simulated.\n", m.snap.Tracked))
+	case m.running:
+		p := m.progress
+		b.WriteString(progressBar(p.Done, p.Total, barWidth) + " " + p.String() + "\n")
+	default:
+		b.WriteString(fmt.Sprintf("%d portfolios started\n", m.snap.Tracked))
+	}
+
+	b.WriteString("\n" + titleStyle.Render("Best so far") + "\n")
+	if len(m.snap.Top) == 0 {
+		b.WriteString(dimStyle.Render("  (waiting for the first bars)") + "\n")
+	}
+	for i, s := range m.snap.Top {
+		style := upStyle
+		if s.Return < 0 {
+			style = downStyle
+		}
+		b.WriteString(fmt.Sprintf("%3d. %-24s %-20s %s\n", i+1,
+			truncate(s.Portfolio, 24), truncate(s.Tickers, 20), style.Render(fmt.Sprintf("%+8.2f%%", 100*s.Return))))
+	}
+
+	if m.snap.Current != "" {
+		b.WriteString("\n" + titleStyle.Render("Equity: "+m.snap.Current) + "\n")
+		b.WriteString(Sparkline(m.snap.Equity, max(10, min(m.width, 120)-2)) + "\n")
+		if n := len(m.snap.Equity); n > 0 {
+			b.WriteString(dimStyle.Render(fmt.Sprintf("%d bars, last %.2f", n, m.snap.Equity[n-1])) + "\n")
+		}
+	}
+	b.WriteString("\n" + dimStyle.Render("q to stop the run") + "\n")
+	return b.String()
+}
+
+func progressBar(done, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = width * min(done, total) / total
+	}
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as at most width block characters, each the
+// mean of its share of the values, scaled from their minimum to their
+// maximum.
+func Sparkline(values []float64, width int) string {
+	if len(values) == 0 || width <= 0 {
+		return ""
+	}
+	cols := min(width, len(values))
+	means := make([]float64, cols)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for c := range cols {
+		from, to := c*len(values)/cols, (c+1)*len(values)/cols
+		sum := 0.0
+		for _, v := range values[from:to] {
+			sum += v
+		}
+		means[c] = sum / float64(to-from)
+		lo, hi = math.Min(lo, means[c]), math.Max(hi, means[c])
+	}
+	out := make([]rune, cols)
+	for c, v := range means {
+		i := len(sparks) / 2
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(sparks)-1))
+		}
+		out[c] = sparks[i]
+	}
+	return string(out)
+}
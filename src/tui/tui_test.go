@@ -0,0 +1,88 @@
+package tui
+
+import (
+	"context"
+	"my-backtester/src/backtest"
+	"my-backtester/src/data"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStore map[string][]data.AssetData
+
+func (f fakeStore) QueryAssetsForTickers(_ context.Context, tickers []string, _, _ time.Time) map[string][]data.AssetData {
+	out := make(map[string][]data.AssetData)
+	for _, t := range tickers {
+		out[t] = append([]data.AssetData(nil), f[t]...)
+		data.FillReturns(out[t])
+	}
+	return out
+}
+
+func (fakeStore) GetRiskFreeRates(context.Context, time.Time, time.Time) map[int64]float64 {
+	return nil
+}
+
+func (fakeStore) QueryMacro(context.Context, string, time.Time) []data.MacroPoint { return nil }
+
+func TestTracker(t *testing.T) {
+	store := fakeStore{}
+	for i := range 20 {
+		day := time.Date(2024, 1, 2+i, 0, 0, 0, 0, time.UTC)
+		up, down := 100+float64(i), 100-float64(i)
+		store["UP"] = append(store["UP"], data.AssetData{Date: day, Open: up, High: up, Low: up, Close: up})
+		store["DOWN"] = append(store["DOWN"], data.AssetData{Date: day, Open: down, High: down, Low: down, Close: down})
+	}
+	tracker := NewTracker()
+	var portfolios []*backtest.Portfolio
+	for _, ticker := range []string{"DOWN", "UP"} {
+		p, err := backtest.NewPortfolio(strings.ToLower(ticker), 1000, []string{ticker}, "buyAndHold:greedy",
+			backtest.WithWindow(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+			backtest.WithEngineHook(tracker.Hook))
+		if err != nil {
+			t.Fatal(err)
+		}
+		portfolios = append(portfolios, p)
+	}
+	if _, err := backtest.Run(context.Background(), store, portfolios, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s := tracker.Snapshot(1)
+	if s.Tracked != 2 || len(s.Top) != 1 || s.Top[0].Portfolio != "up" || s.Top[0].Tickers != "UP" || s.Top[0].Return <= 0 {
+		t.Errorf("snapshot = %+v", s)
+	}
+	if s.Current == "" || len(s.Equity) == 0 {
+		t.Errorf("no current equity: %+v", s)
+	}
+	if s = tracker.Snapshot(5); len(s.Top) != 2 || s.Top[1].Return >= 0 {
+		t.Errorf("top = %+v", s.Top)
+	}
+
+	m := newModel(tracker)
+	next, _ := m.Update(doneMsg{})
+	view := next.View()
+	for _, want := range []string{"Finished: 2 portfolios", "up", "Equity: " + s.Current} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view lacks %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	for _, tc := range []struct {
+		values []float64
+		width  int
+		want   string
+	}{
+		{nil, 10, ""},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8}, 8, "▁▂▃▄▅▆▇█"},
+		{[]float64{1, 1, 8, 8}, 2, "▁█"},
+		{[]float64{5, 5, 5}, 10, "▅▅▅"},
+	} {
+		if got := Sparkline(tc.values, tc.width); got != tc.want {
+			t.Errorf("Sparkline(%v, %d) = %q, want %q", tc.values, tc.width, got, tc.want)
+		}
+	}
+}
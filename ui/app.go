@@ -57,7 +57,7 @@ func (a *App) RunBacktest(cfgText, dbPath, defaultLuaPath string) (results []Run
 	if dbPath == "" {
 		return nil, fmt.Errorf("db path is empty")
 	}
-	raw, err := backtest.RunFromConfigText(cfgText, dbPath, defaultLuaPath)
+	raw, err := backtest.RunFromConfigText(a.ctx, cfgText, dbPath, defaultLuaPath)
 	if err != nil {
 		return nil, err
 	}
@@ -108,10 +108,12 @@ func (a *App) ListTickers(dbPath string) ([]string, error) {
 	if dbPath == "" {
 		return nil, fmt.Errorf("db path is empty")
 	}
-	if _, err := data.InitDB(dbPath); err != nil {
+	store, err := data.Open(dbPath)
+	if err != nil {
 		return nil, fmt.Errorf("open db %q: %w", dbPath, err)
 	}
-	return data.ListTickers()
+	defer store.Close()
+	return store.ListTickers(a.ctx)
 }
 
 // PickLuaFile opens a native file picker for Lua strategy scripts.